@@ -0,0 +1,80 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// 请求体改写操作类型
+const (
+	BodyTransformSet     = "set"     // 设置字段（覆盖已有值）
+	BodyTransformRemove  = "remove"  // 删除字段
+	BodyTransformDefault = "default" // 字段不存在时设置
+)
+
+// BodyTransform 渠道级请求体改写规则，在发往上游前按顺序应用于最终请求体
+// 用于适配个别上游的特殊要求（如固定 max_tokens、注入默认 system、移除不支持的参数）
+type BodyTransform struct {
+	Op    string          `json:"op"`              // set / remove / default
+	Path  string          `json:"path"`            // 点分隔的 JSON 路径，如 max_tokens、metadata.user_id、tools.0.cache_control
+	Value json.RawMessage `json:"value,omitempty"` // set / default 写入的 JSON 值
+}
+
+// bodyTransformPathReservedChars gjson 查询语法字符（通配、条件、修饰符等），改写路径中不允许使用
+const bodyTransformPathReservedChars = "*?#@|!=<>%"
+
+// ValidateBodyTransforms 校验请求体改写规则
+func ValidateBodyTransforms(transforms []BodyTransform) error {
+	for i, t := range transforms {
+		if t.Path == "" || strings.HasPrefix(t.Path, ".") || strings.HasSuffix(t.Path, ".") || strings.Contains(t.Path, "..") {
+			return fmt.Errorf("规则 %d: 无效的 path %q", i, t.Path)
+		}
+		if strings.ContainsAny(t.Path, bodyTransformPathReservedChars) {
+			return fmt.Errorf("规则 %d: path %q 不支持通配或查询语法", i, t.Path)
+		}
+		switch t.Op {
+		case BodyTransformSet, BodyTransformDefault:
+			if len(t.Value) == 0 || !json.Valid(t.Value) {
+				return fmt.Errorf("规则 %d: %s 操作需要合法的 JSON value", i, t.Op)
+			}
+		case BodyTransformRemove:
+		default:
+			return fmt.Errorf("规则 %d: 未知的 op %q（可选值: set, remove, default）", i, t.Op)
+		}
+	}
+	return nil
+}
+
+// ApplyBodyTransforms 按顺序将改写规则应用到 JSON 请求体
+// 无规则或请求体为空时原样返回
+func ApplyBodyTransforms(body []byte, transforms []BodyTransform) ([]byte, error) {
+	if len(transforms) == 0 || len(body) == 0 {
+		return body, nil
+	}
+	if !gjson.ValidBytes(body) {
+		return nil, fmt.Errorf("请求体改写失败: 请求体不是合法 JSON")
+	}
+
+	var err error
+	for _, t := range transforms {
+		switch t.Op {
+		case BodyTransformSet:
+			body, err = sjson.SetRawBytes(body, t.Path, t.Value)
+		case BodyTransformDefault:
+			if gjson.GetBytes(body, t.Path).Exists() {
+				continue
+			}
+			body, err = sjson.SetRawBytes(body, t.Path, t.Value)
+		case BodyTransformRemove:
+			body, err = sjson.DeleteBytes(body, t.Path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("请求体改写失败 (%s %s): %w", t.Op, t.Path, err)
+		}
+	}
+	return body, nil
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyBodyTransforms(t *testing.T) {
+	body := []byte(`{"model":"claude-test","max_tokens":64000,"top_k":40,"metadata":{"user_id":"u-1"},"messages":[{"role":"user","content":"hi"}]}`)
+
+	tests := []struct {
+		name       string
+		transforms []BodyTransform
+		want       map[string]string // path -> 期望的原始 JSON 值（空字符串表示字段不存在）
+	}{
+		{
+			name:       "set 覆盖已有字段",
+			transforms: []BodyTransform{{Op: BodyTransformSet, Path: "max_tokens", Value: json.RawMessage(`8192`)}},
+			want:       map[string]string{"max_tokens": "8192", "model": `"claude-test"`},
+		},
+		{
+			name:       "set 创建嵌套字段",
+			transforms: []BodyTransform{{Op: BodyTransformSet, Path: "thinking.type", Value: json.RawMessage(`"disabled"`)}},
+			want:       map[string]string{"thinking.type": `"disabled"`},
+		},
+		{
+			name: "remove 删除字段，不存在时忽略",
+			transforms: []BodyTransform{
+				{Op: BodyTransformRemove, Path: "top_k"},
+				{Op: BodyTransformRemove, Path: "metadata.user_id"},
+				{Op: BodyTransformRemove, Path: "not_exists"},
+			},
+			want: map[string]string{"top_k": "", "metadata.user_id": "", "metadata": "{}"},
+		},
+		{
+			name: "default 仅在字段不存在时设置",
+			transforms: []BodyTransform{
+				{Op: BodyTransformDefault, Path: "system", Value: json.RawMessage(`"You are a helpful assistant."`)},
+				{Op: BodyTransformDefault, Path: "max_tokens", Value: json.RawMessage(`1024`)},
+			},
+			want: map[string]string{"system": `"You are a helpful assistant."`, "max_tokens": "64000"},
+		},
+		{
+			name: "按顺序应用",
+			transforms: []BodyTransform{
+				{Op: BodyTransformRemove, Path: "max_tokens"},
+				{Op: BodyTransformDefault, Path: "max_tokens", Value: json.RawMessage(`4096`)},
+			},
+			want: map[string]string{"max_tokens": "4096"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyBodyTransforms(body, tt.transforms)
+			if err != nil {
+				t.Fatalf("ApplyBodyTransforms() err = %v", err)
+			}
+			for path, want := range tt.want {
+				if raw := gjson.GetBytes(got, path).Raw; raw != want {
+					t.Errorf("%s = %q, want %q (body=%s)", path, raw, want, got)
+				}
+			}
+		})
+	}
+
+	if got, err := ApplyBodyTransforms(body, nil); err != nil || string(got) != string(body) {
+		t.Errorf("无规则时应原样返回: %s, %v", got, err)
+	}
+	if _, err := ApplyBodyTransforms([]byte("not json"), []BodyTransform{{Op: BodyTransformRemove, Path: "a"}}); err == nil {
+		t.Error("非 JSON 请求体应返回错误")
+	}
+}
+
+func TestValidateBodyTransforms(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform BodyTransform
+		wantErr   bool
+	}{
+		{"set", BodyTransform{Op: "set", Path: "max_tokens", Value: json.RawMessage(`1024`)}, false},
+		{"remove 无需 value", BodyTransform{Op: "remove", Path: "top_k"}, false},
+		{"default 数组下标", BodyTransform{Op: "default", Path: "messages.0.role", Value: json.RawMessage(`"user"`)}, false},
+		{"未知 op", BodyTransform{Op: "rename", Path: "a"}, true},
+		{"空 path", BodyTransform{Op: "remove"}, true},
+		{"非法 path", BodyTransform{Op: "remove", Path: "a..b"}, true},
+		{"通配 path", BodyTransform{Op: "remove", Path: "tools.*.cache_control"}, true},
+		{"set 缺少 value", BodyTransform{Op: "set", Path: "a"}, true},
+		{"value 不是合法 JSON", BodyTransform{Op: "default", Path: "a", Value: json.RawMessage(`{bad`)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateBodyTransforms([]BodyTransform{tt.transform})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateBodyTransforms() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
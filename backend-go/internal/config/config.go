@@ -3,7 +3,9 @@
 import (
 	"fmt"
 	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/BenedictKing/ccx/internal/utils"
@@ -39,10 +41,48 @@ type UpstreamConfig struct {
 	StripThoughtSignature       bool `json:"stripThoughtSignature,omitempty"`       // 移除 thought_signature 字段（兼容旧版 Gemini API）
 	// 自定义请求头
 	CustomHeaders map[string]string `json:"customHeaders,omitempty"` // 自定义请求头（覆盖或添加到上游请求）
+	// 客户端请求头转发策略（默认透传全部客户端请求头）
+	ForwardHeaderAllowlist []string `json:"forwardHeaderAllowlist,omitempty"` // 非空时仅转发列表中的客户端请求头，支持通配符如 anthropic-*
+	StripHeaders           []string `json:"stripHeaders,omitempty"`           // 转发前移除的客户端请求头（如 x-stainless-*），优先于 allowlist
 	// 渠道级代理
-	ProxyURL string `json:"proxyUrl,omitempty"` // HTTP/HTTPS/SOCKS5 代理地址
+	ProxyURL  string   `json:"proxyUrl,omitempty"`  // HTTP/HTTPS/SOCKS5 代理地址
+	ProxyURLs []string `json:"proxyUrls,omitempty"` // 多代理轮换（每次请求轮询选择，连接失败时切换到下一个）
+	// 上游请求体压缩（超过 UPSTREAM_GZIP_MIN_BYTES 时 gzip 压缩并设置 Content-Encoding，需上游支持）
+	CompressUpstreamRequests bool `json:"compressUpstreamRequests,omitempty"`
+	// 渠道级 failover 状态码策略（优先于默认判断逻辑）
+	FailoverStatusCodes   []int `json:"failoverStatusCodes,omitempty"`   // 强制 failover 的状态码（如该上游缺少模型时返回的 404）
+	NoFailoverStatusCodes []int `json:"noFailoverStatusCodes,omitempty"` // 不 failover、直接返回客户端的状态码
+	// Azure OpenAI 特定配置（serviceType 为 azure 时生效）
+	Deployment string `json:"deployment,omitempty"` // 部署名称，为空时使用模型映射后的模型名
+	APIVersion string `json:"apiVersion,omitempty"` // api-version 查询参数，为空时使用默认版本
+	// 渠道级 TLS 配置（自建上游的自定义 CA、客户端证书与 SNI）
+	CACertPath     string `json:"caCertPath,omitempty"`     // 自定义 CA 证书（PEM）路径，追加到系统根证书
+	ClientCertPath string `json:"clientCertPath,omitempty"` // 客户端证书（PEM）路径，需与 clientKeyPath 同时配置
+	ClientKeyPath  string `json:"clientKeyPath,omitempty"`  // 客户端私钥（PEM）路径
+	ServerName     string `json:"serverName,omitempty"`     // 覆盖 TLS SNI 及证书校验使用的主机名
 	// 模型白名单
 	SupportedModels []string `json:"supportedModels,omitempty"` // 支持的模型白名单（空=全部），支持通配符如 gpt-4*
+	// Key 自动重排
+	AutoReorderKeys bool `json:"autoReorderKeys,omitempty"` // 按近期成功率定期重排 Key 顺序（成功率高的 Key 优先尝试）
+	// 渠道级超时（秒，0 表示使用全局环境变量配置）
+	ResponseHeaderTimeout int `json:"responseHeaderTimeout,omitempty"` // 连接 + 等待响应头超时
+	StreamIdleTimeout     int `json:"streamIdleTimeout,omitempty"`     // 流式响应空闲超时（每收到数据重置计时）
+	// 渠道级并发排队（MaxConcurrent 为 0 表示不限制）
+	MaxConcurrent  int `json:"maxConcurrent,omitempty"`  // 最大并发请求数，达到上限后排队等待
+	QueueTimeoutMs int `json:"queueTimeoutMs,omitempty"` // 排队等待超时（毫秒），超时后 failover 到下一个渠道；0 表示不等待
+	// 每日 token 预算（对渠道内每个 Key 单独生效，0 表示不限制；当日用量达到预算后跳过该 Key，本地零点重置）
+	DailyTokenBudget int64 `json:"dailyTokenBudget,omitempty"`
+	// 流式事件过滤
+	StreamEventDenylist []string `json:"streamEventDenylist,omitempty"` // 透传时丢弃的 SSE 事件类型（如 ping），支持通配符如 x-*
+	// 请求体改写规则（set/remove/default），发往上游前按顺序应用
+	BodyTransforms []BodyTransform `json:"bodyTransforms,omitempty"`
+	// 渠道级熔断灵敏度（0 表示使用全局默认；低质量渠道未配置时使用更宽松的默认值）
+	CircuitFailureThreshold float64 `json:"circuitFailureThreshold,omitempty"` // 熔断失败率阈值，取值 (0, 1]
+	CircuitRecoverySeconds  int     `json:"circuitRecoverySeconds,omitempty"`  // 熔断自动恢复时间（秒）
+	// 金丝雀灰度：按该百分比（1-100）将普通请求分流到本渠道（不含亲和与促销请求），其余请求按正常优先级调度；0 表示不启用
+	CanaryPercent int `json:"canaryPercent,omitempty"`
+	// Key 并行竞速：同时向前 N 个 Key 发起请求，采用最先成功的响应并取消其余请求（以额度换延迟，受 MAX_KEY_SPRAY_CONCURRENCY 限制）；0/1 表示不启用
+	KeySprayCount int `json:"keySprayCount,omitempty"`
 }
 
 // UpstreamUpdate 用于部分更新 UpstreamConfig
@@ -70,10 +110,46 @@ type UpstreamUpdate struct {
 	StripThoughtSignature       *bool `json:"stripThoughtSignature"`
 	// 自定义请求头
 	CustomHeaders map[string]string `json:"customHeaders"`
+	// 客户端请求头转发策略
+	ForwardHeaderAllowlist []string `json:"forwardHeaderAllowlist"`
+	StripHeaders           []string `json:"stripHeaders"`
 	// 渠道级代理
-	ProxyURL *string `json:"proxyUrl"`
+	ProxyURL  *string  `json:"proxyUrl"`
+	ProxyURLs []string `json:"proxyUrls"`
+	// 上游请求体压缩
+	CompressUpstreamRequests *bool `json:"compressUpstreamRequests"`
+	// 渠道级 failover 状态码策略
+	FailoverStatusCodes   []int `json:"failoverStatusCodes"`
+	NoFailoverStatusCodes []int `json:"noFailoverStatusCodes"`
+	// Azure OpenAI 特定配置
+	Deployment *string `json:"deployment"`
+	APIVersion *string `json:"apiVersion"`
+	// 渠道级 TLS 配置
+	CACertPath     *string `json:"caCertPath"`
+	ClientCertPath *string `json:"clientCertPath"`
+	ClientKeyPath  *string `json:"clientKeyPath"`
+	ServerName     *string `json:"serverName"`
 	// 模型白名单
 	SupportedModels []string `json:"supportedModels"` // 支持的模型白名单（空=全部），支持通配符如 gpt-4*
+	// Key 自动重排
+	AutoReorderKeys *bool `json:"autoReorderKeys"`
+	// 渠道级超时
+	ResponseHeaderTimeout *int `json:"responseHeaderTimeout"`
+	StreamIdleTimeout     *int `json:"streamIdleTimeout"`
+	// 渠道级并发排队
+	MaxConcurrent       *int     `json:"maxConcurrent"`
+	QueueTimeoutMs      *int     `json:"queueTimeoutMs"`
+	DailyTokenBudget    *int64   `json:"dailyTokenBudget"`
+	StreamEventDenylist []string `json:"streamEventDenylist"`
+	// 请求体改写规则
+	BodyTransforms []BodyTransform `json:"bodyTransforms"`
+	// 渠道级熔断灵敏度
+	CircuitFailureThreshold *float64 `json:"circuitFailureThreshold"`
+	CircuitRecoverySeconds  *int     `json:"circuitRecoverySeconds"`
+	// 金丝雀灰度
+	CanaryPercent *int `json:"canaryPercent"`
+	// Key 并行竞速
+	KeySprayCount *int `json:"keySprayCount"`
 }
 
 // Config 配置结构
@@ -96,6 +172,52 @@ type Config struct {
 
 	// 移除计费头中的 cch= 参数：启用时自动从 system 数组中移除 cch=xxx; 部分
 	StripBillingHeader bool `json:"stripBillingHeader"`
+
+	// 影子渠道：主渠道成功处理非流式请求后，异步将相同请求镜像到影子渠道（仅记录指标，丢弃响应）
+	// key 为接口类型（messages/responses/gemini/chat），value 为该类型下的渠道索引
+	ShadowChannels map[string]int `json:"shadowChannels,omitempty"`
+
+	// 全局限流：所有接口、所有渠道共享的每分钟请求数 / token 数上限（0 表示不限制）
+	GlobalMaxRPM int `json:"globalMaxRpm,omitempty"`
+	GlobalMaxTPM int `json:"globalMaxTpm,omitempty"`
+
+	// 模型定价：用于估算用量汇总中的费用，key 为模型名（支持 claude-* 前缀通配）
+	ModelPricing map[string]ModelPrice `json:"modelPricing,omitempty"`
+
+	// 模型名归一化：映射与指标记录前将同一模型的不同写法统一为规范名称
+	// key 为别名（精确匹配）或 "re:" 前缀的正则（整串匹配，value 可用 $1 引用捕获组），value 为规范模型名
+	ModelAliases map[string]string `json:"modelAliases,omitempty"`
+
+	// Trace 亲和模式："default"（空）每次请求重新检查健康度，"sticky" 固定渠道直到该会话实际失败
+	AffinityMode string `json:"affinityMode,omitempty"`
+
+	// 渠道调度模式："priority"（空）按优先级选择首个健康渠道，"least_loaded" 在健康渠道中选择进行中请求最少的
+	SchedulingMode string `json:"schedulingMode,omitempty"`
+
+	// 模型访问控制：key 为接口类型（messages/responses/gemini/chat），value 为模型名列表（支持 xxx* 前缀通配）
+	// 命中 deniedModels 的请求直接拒绝；allowedModels 非空时仅允许列表内的模型
+	AllowedModels map[string][]string `json:"allowedModels,omitempty"`
+	DeniedModels  map[string][]string `json:"deniedModels,omitempty"`
+}
+
+// Trace 亲和模式
+const (
+	AffinityModeDefault = "default" // 每次请求检查亲和渠道健康度，不健康时按优先级重新选择
+	AffinityModeSticky  = "sticky"  // 固定使用亲和渠道，直到该会话在此渠道上发生 failover 错误
+)
+
+// 渠道调度模式（促销期与 Trace 亲和始终优先）
+const (
+	SchedulingModePriority    = "priority"     // 按优先级选择首个健康渠道
+	SchedulingModeLeastLoaded = "least_loaded" // 在所有健康渠道中选择进行中请求数最少的，相同时按优先级
+)
+
+// ModelPrice 模型单价（USD / 百万 tokens）
+type ModelPrice struct {
+	Input         float64 `json:"input"`
+	Output        float64 `json:"output"`
+	CacheRead     float64 `json:"cacheRead,omitempty"`
+	CacheCreation float64 `json:"cacheCreation,omitempty"`
 }
 
 // FailedKey 失败密钥记录
@@ -104,6 +226,9 @@ type FailedKey struct {
 	FailureCount int
 }
 
+// KeyBudgetChecker 判断 Key 今日用量是否已达到渠道的每日 token 预算
+type KeyBudgetChecker func(upstream *UpstreamConfig, apiKey string) bool
+
 // ConfigManager 配置管理器
 type ConfigManager struct {
 	mu              sync.RWMutex
@@ -115,6 +240,14 @@ type ConfigManager struct {
 	maxFailureCount int
 	stopChan        chan struct{} // 用于通知 goroutine 停止
 	closeOnce       sync.Once     // 确保 Close 只执行一次
+
+	keyBudgetCheckers map[string]KeyBudgetChecker // 按接口类型注入的每日预算检查（config 不直接依赖 metrics）
+	budgetMu          sync.Mutex
+	overBudgetState   map[string]bool // 已超出每日预算的密钥（apiType:apiKey），仅在状态变化时记录日志
+
+	changeHooks     []ConfigChangeHook              // 内存配置被替换后的回调（如向指标管理器推送熔断覆盖）
+	modelNormalizer atomic.Pointer[modelNormalizer] // 当前生效的模型名归一化规则，配置写入成功后整体替换
+	lastValidConfig Config                          // 最近一次生效配置的深拷贝，保存校验失败时用于恢复内存配置
 }
 
 // failedKeyCacheKey 构造 FailedKeysCache 的复合键（apiType:apiKey）
@@ -128,42 +261,74 @@ func failedKeyCacheKey(apiType, apiKey string) string {
 func (cm *ConfigManager) GetConfig() Config {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
+	return cm.config.clone()
+}
 
+// clone 深拷贝配置（渠道列表与各 map 均复制，修改副本不影响原配置）
+func (c *Config) clone() Config {
 	// 深拷贝整个 Config 结构体
-	cloned := cm.config
+	cloned := *c
 
 	// 深拷贝 Upstream slice
-	if cm.config.Upstream != nil {
-		cloned.Upstream = make([]UpstreamConfig, len(cm.config.Upstream))
-		for i := range cm.config.Upstream {
-			cloned.Upstream[i] = *cm.config.Upstream[i].Clone()
+	if c.Upstream != nil {
+		cloned.Upstream = make([]UpstreamConfig, len(c.Upstream))
+		for i := range c.Upstream {
+			cloned.Upstream[i] = *c.Upstream[i].Clone()
 		}
 	}
 
 	// 深拷贝 ResponsesUpstream slice
-	if cm.config.ResponsesUpstream != nil {
-		cloned.ResponsesUpstream = make([]UpstreamConfig, len(cm.config.ResponsesUpstream))
-		for i := range cm.config.ResponsesUpstream {
-			cloned.ResponsesUpstream[i] = *cm.config.ResponsesUpstream[i].Clone()
+	if c.ResponsesUpstream != nil {
+		cloned.ResponsesUpstream = make([]UpstreamConfig, len(c.ResponsesUpstream))
+		for i := range c.ResponsesUpstream {
+			cloned.ResponsesUpstream[i] = *c.ResponsesUpstream[i].Clone()
 		}
 	}
 
 	// 深拷贝 GeminiUpstream slice
-	if cm.config.GeminiUpstream != nil {
-		cloned.GeminiUpstream = make([]UpstreamConfig, len(cm.config.GeminiUpstream))
-		for i := range cm.config.GeminiUpstream {
-			cloned.GeminiUpstream[i] = *cm.config.GeminiUpstream[i].Clone()
+	if c.GeminiUpstream != nil {
+		cloned.GeminiUpstream = make([]UpstreamConfig, len(c.GeminiUpstream))
+		for i := range c.GeminiUpstream {
+			cloned.GeminiUpstream[i] = *c.GeminiUpstream[i].Clone()
 		}
 	}
 
 	// 深拷贝 ChatUpstream slice
-	if len(cm.config.ChatUpstream) > 0 {
-		cloned.ChatUpstream = make([]UpstreamConfig, len(cm.config.ChatUpstream))
-		for i := range cm.config.ChatUpstream {
-			cloned.ChatUpstream[i] = *cm.config.ChatUpstream[i].Clone()
+	if len(c.ChatUpstream) > 0 {
+		cloned.ChatUpstream = make([]UpstreamConfig, len(c.ChatUpstream))
+		for i := range c.ChatUpstream {
+			cloned.ChatUpstream[i] = *c.ChatUpstream[i].Clone()
+		}
+	}
+
+	// 深拷贝 ModelPricing map
+	if c.ModelPricing != nil {
+		cloned.ModelPricing = make(map[string]ModelPrice, len(c.ModelPricing))
+		for k, v := range c.ModelPricing {
+			cloned.ModelPricing[k] = v
+		}
+	}
+
+	// 深拷贝 ModelAliases map
+	if c.ModelAliases != nil {
+		cloned.ModelAliases = make(map[string]string, len(c.ModelAliases))
+		for k, v := range c.ModelAliases {
+			cloned.ModelAliases[k] = v
 		}
 	}
 
+	// 深拷贝 ShadowChannels map
+	if c.ShadowChannels != nil {
+		cloned.ShadowChannels = make(map[string]int, len(c.ShadowChannels))
+		for k, v := range c.ShadowChannels {
+			cloned.ShadowChannels[k] = v
+		}
+	}
+
+	// 深拷贝模型访问控制列表
+	cloned.AllowedModels = cloneModelLists(c.AllowedModels)
+	cloned.DeniedModels = cloneModelLists(c.DeniedModels)
+
 	return cloned
 }
 
@@ -174,15 +339,21 @@ func (cm *ConfigManager) GetNextAPIKey(upstream *UpstreamConfig, failedKeys map[
 		return "", fmt.Errorf("上游 %s 没有可用的API密钥", upstream.Name)
 	}
 
+	// 超出每日 token 预算的密钥直接跳过（不参与恢复尝试）
+	overBudgetKeys := cm.overBudgetKeys(upstream, apiType)
+	if len(overBudgetKeys) == len(upstream.APIKeys) {
+		return "", fmt.Errorf("上游 %s 的所有API密钥都已达到每日 token 预算", upstream.Name)
+	}
+
 	// 单 Key 直接返回
 	if len(upstream.APIKeys) == 1 {
 		return upstream.APIKeys[0], nil
 	}
 
-	// 筛选可用密钥：排除临时失败密钥和内存中的失败密钥
+	// 筛选可用密钥：排除临时失败密钥、内存中的失败密钥和超出预算的密钥
 	availableKeys := []string{}
 	for _, key := range upstream.APIKeys {
-		if !failedKeys[key] && !cm.isKeyFailed(key, apiType) {
+		if !failedKeys[key] && !overBudgetKeys[key] && !cm.isKeyFailed(key, apiType) {
 			availableKeys = append(availableKeys, key)
 		}
 	}
@@ -194,7 +365,7 @@ func (cm *ConfigManager) GetNextAPIKey(upstream *UpstreamConfig, failedKeys map[
 
 		cm.mu.RLock()
 		for _, key := range upstream.APIKeys {
-			if !failedKeys[key] { // 排除本次请求已经尝试过的密钥
+			if !failedKeys[key] && !overBudgetKeys[key] { // 排除本次请求已经尝试过的密钥和超出预算的密钥
 				cacheKey := failedKeyCacheKey(apiType, key)
 				if failure, exists := cm.failedKeysCache[cacheKey]; exists {
 					if failure.Timestamp.Before(oldestTime) {
@@ -228,6 +399,64 @@ func (cm *ConfigManager) GetNextAPIKey(upstream *UpstreamConfig, failedKeys map[
 	return selectedKey, nil
 }
 
+// SetKeyBudgetChecker 注入指定接口类型的每日 token 预算检查
+// apiType 与 GetNextAPIKey 的日志标签一致（Messages/Responses/Gemini/Chat）
+func (cm *ConfigManager) SetKeyBudgetChecker(apiType string, checker KeyBudgetChecker) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.keyBudgetCheckers == nil {
+		cm.keyBudgetCheckers = make(map[string]KeyBudgetChecker)
+	}
+	cm.keyBudgetCheckers[apiType] = checker
+}
+
+// overBudgetKeys 返回渠道内今日用量已达到每日 token 预算的密钥
+func (cm *ConfigManager) overBudgetKeys(upstream *UpstreamConfig, apiType string) map[string]bool {
+	if upstream.DailyTokenBudget <= 0 {
+		return nil
+	}
+
+	cm.mu.RLock()
+	checker := cm.keyBudgetCheckers[apiType]
+	cm.mu.RUnlock()
+	if checker == nil {
+		return nil
+	}
+
+	overBudget := make(map[string]bool)
+	for _, key := range upstream.APIKeys {
+		exceeded := checker(upstream, key)
+		if exceeded {
+			overBudget[key] = true
+		}
+		cm.logBudgetStateChange(apiType, key, exceeded, upstream.DailyTokenBudget)
+	}
+	return overBudget
+}
+
+// logBudgetStateChange 密钥预算状态变化（超出预算 / 跨天或调整预算后恢复）时记录日志，避免每个请求重复输出
+func (cm *ConfigManager) logBudgetStateChange(apiType, apiKey string, exceeded bool, budget int64) {
+	stateKey := failedKeyCacheKey(apiType, apiKey)
+
+	cm.budgetMu.Lock()
+	defer cm.budgetMu.Unlock()
+
+	if cm.overBudgetState[stateKey] == exceeded {
+		return
+	}
+	if exceeded {
+		if cm.overBudgetState == nil {
+			cm.overBudgetState = make(map[string]bool)
+		}
+		cm.overBudgetState[stateKey] = true
+		log.Printf("[%s-Key] 密钥 %s 今日用量已达到每日 token 预算 %d，暂停使用", apiType, utils.MaskAPIKey(apiKey), budget)
+		return
+	}
+	delete(cm.overBudgetState, stateKey)
+	log.Printf("[%s-Key] 密钥 %s 今日用量已低于每日 token 预算 %d，恢复使用", apiType, utils.MaskAPIKey(apiKey), budget)
+}
+
 // MarkKeyAsFailed 标记密钥失败
 // apiType: 接口类型（Messages/Responses/Gemini/Chat），用于日志标签前缀和缓存键隔离
 func (cm *ConfigManager) MarkKeyAsFailed(apiKey string, apiType string) {
@@ -348,6 +577,126 @@ func (cm *ConfigManager) SetFuzzyModeEnabled(enabled bool) error {
 	return nil
 }
 
+// ============== 全局限流相关方法 ==============
+
+// GetGlobalRateLimit 获取全局限流配置（0 表示不限制）
+func (cm *ConfigManager) GetGlobalRateLimit() (maxRPM, maxTPM int) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config.GlobalMaxRPM, cm.config.GlobalMaxTPM
+}
+
+// SetGlobalRateLimit 设置全局限流配置（0 表示不限制）
+func (cm *ConfigManager) SetGlobalRateLimit(maxRPM, maxTPM int) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.config.GlobalMaxRPM = maxRPM
+	cm.config.GlobalMaxTPM = maxTPM
+
+	if err := cm.saveConfigLocked(cm.config); err != nil {
+		return err
+	}
+
+	log.Printf("[Config-RateLimit] 全局限流已更新: RPM=%d, TPM=%d", maxRPM, maxTPM)
+	return nil
+}
+
+// ============== Trace 亲和模式相关方法 ==============
+
+// GetAffinityMode 获取 Trace 亲和模式（未配置时返回 default）
+func (cm *ConfigManager) GetAffinityMode() string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	if cm.config.AffinityMode == "" {
+		return AffinityModeDefault
+	}
+	return cm.config.AffinityMode
+}
+
+// SetAffinityMode 设置 Trace 亲和模式
+func (cm *ConfigManager) SetAffinityMode(mode string) error {
+	if mode != AffinityModeDefault && mode != AffinityModeSticky {
+		return fmt.Errorf("无效的亲和模式: %s", mode)
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.config.AffinityMode = mode
+
+	if err := cm.saveConfigLocked(cm.config); err != nil {
+		return err
+	}
+
+	log.Printf("[Config-Affinity] Trace 亲和模式已设置为: %s", mode)
+	return nil
+}
+
+// ============== 渠道调度模式相关方法 ==============
+
+// GetSchedulingMode 获取渠道调度模式（未配置时返回 priority）
+func (cm *ConfigManager) GetSchedulingMode() string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	if cm.config.SchedulingMode == "" {
+		return SchedulingModePriority
+	}
+	return cm.config.SchedulingMode
+}
+
+// SetSchedulingMode 设置渠道调度模式
+func (cm *ConfigManager) SetSchedulingMode(mode string) error {
+	if mode != SchedulingModePriority && mode != SchedulingModeLeastLoaded {
+		return fmt.Errorf("无效的调度模式: %s", mode)
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.config.SchedulingMode = mode
+
+	if err := cm.saveConfigLocked(cm.config); err != nil {
+		return err
+	}
+
+	log.Printf("[Config-Scheduling] 渠道调度模式已设置为: %s", mode)
+	return nil
+}
+
+// ============== 模型定价相关方法 ==============
+
+// GetModelPrice 获取模型单价：精确匹配优先，其次按最长前缀匹配 "xxx*" 通配规则
+func (cm *ConfigManager) GetModelPrice(model string) (ModelPrice, bool) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if price, ok := cm.config.ModelPricing[model]; ok {
+		return price, true
+	}
+
+	var matched ModelPrice
+	matchedLen := -1
+	for pattern, price := range cm.config.ModelPricing {
+		if !strings.HasSuffix(pattern, "*") {
+			continue
+		}
+		prefix := strings.TrimSuffix(pattern, "*")
+		if strings.HasPrefix(model, prefix) && len(prefix) > matchedLen {
+			matched, matchedLen = price, len(prefix)
+		}
+	}
+	return matched, matchedLen >= 0
+}
+
+// EstimateCost 按单价估算费用（USD）
+func (p ModelPrice) EstimateCost(inputTokens, outputTokens, cacheReadTokens, cacheCreationTokens int64) float64 {
+	return (float64(inputTokens)*p.Input +
+		float64(outputTokens)*p.Output +
+		float64(cacheReadTokens)*p.CacheRead +
+		float64(cacheCreationTokens)*p.CacheCreation) / 1_000_000
+}
+
 // ============== StripBillingHeader 相关方法 ==============
 
 // GetStripBillingHeader 获取移除计费头状态
@@ -375,3 +724,175 @@ func (cm *ConfigManager) SetStripBillingHeader(enabled bool) error {
 	log.Printf("[Config-StripBillingHeader] 移除计费头已%s", status)
 	return nil
 }
+
+// ============== ShadowChannel 相关方法 ==============
+
+// upstreamsByKindLocked 根据接口类型获取渠道列表（调用方需持有锁）
+func (cm *ConfigManager) upstreamsByKindLocked(kind string) ([]UpstreamConfig, bool) {
+	switch kind {
+	case "messages":
+		return cm.config.Upstream, true
+	case "responses":
+		return cm.config.ResponsesUpstream, true
+	case "gemini":
+		return cm.config.GeminiUpstream, true
+	case "chat":
+		return cm.config.ChatUpstream, true
+	default:
+		return nil, false
+	}
+}
+
+// ExpiredPromotion 已到期并被清除的渠道促销期
+type ExpiredPromotion struct {
+	Kind         string
+	ChannelIndex int
+	ChannelName  string
+	Until        time.Time
+}
+
+// ClearExpiredPromotions 清除所有接口类型中在 now 之前到期的促销期并持久化
+// 返回被清除的渠道列表；没有到期渠道时不写配置文件
+func (cm *ConfigManager) ClearExpiredPromotions(now time.Time) ([]ExpiredPromotion, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	var expired []ExpiredPromotion
+	for _, kind := range []string{"messages", "responses", "gemini", "chat"} {
+		upstreams, _ := cm.upstreamsByKindLocked(kind)
+		for i := range upstreams {
+			until := upstreams[i].PromotionUntil
+			if until == nil || now.Before(*until) {
+				continue
+			}
+			expired = append(expired, ExpiredPromotion{
+				Kind:         kind,
+				ChannelIndex: i,
+				ChannelName:  upstreams[i].Name,
+				Until:        *until,
+			})
+			upstreams[i].PromotionUntil = nil
+		}
+	}
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	if err := cm.saveConfigLocked(cm.config); err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+// CircuitOverride 渠道级熔断失败率阈值与恢复时间（0 表示使用全局默认）
+type CircuitOverride struct {
+	FailureThreshold float64
+	RecoveryTime     time.Duration
+}
+
+// CircuitOverridesByBaseURL 按 BaseURL 汇总指定接口类型的渠道级熔断覆盖（未配置覆盖的 BaseURL 不出现在结果中）
+// 多个渠道共用同一 BaseURL 时取第一个配置了覆盖的渠道
+func (c *Config) CircuitOverridesByBaseURL(kind string) map[string]CircuitOverride {
+	var upstreams []UpstreamConfig
+	switch kind {
+	case "messages":
+		upstreams = c.Upstream
+	case "responses":
+		upstreams = c.ResponsesUpstream
+	case "gemini":
+		upstreams = c.GeminiUpstream
+	case "chat":
+		upstreams = c.ChatUpstream
+	}
+
+	overrides := make(map[string]CircuitOverride)
+	for i := range upstreams {
+		threshold, recovery := upstreams[i].CircuitOverrides()
+		if threshold == 0 && recovery == 0 {
+			continue
+		}
+		for _, url := range upstreams[i].GetAllBaseURLs() {
+			if _, exists := overrides[url]; !exists {
+				overrides[url] = CircuitOverride{FailureThreshold: threshold, RecoveryTime: recovery}
+			}
+		}
+	}
+	return overrides
+}
+
+// shiftShadowChannelOnRemoveLocked 删除渠道后修正影子渠道索引（调用方需持有锁）
+// 删除的正是影子渠道时清除配置，删除位置在其之前时索引前移
+func (cm *ConfigManager) shiftShadowChannelOnRemoveLocked(kind string, removedIndex int) {
+	index, exists := cm.config.ShadowChannels[kind]
+	if !exists {
+		return
+	}
+	if index == removedIndex {
+		delete(cm.config.ShadowChannels, kind)
+	} else if index > removedIndex {
+		cm.config.ShadowChannels[kind] = index - 1
+	}
+}
+
+// GetShadowChannels 获取所有接口类型的影子渠道配置
+func (cm *ConfigManager) GetShadowChannels() map[string]int {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	result := make(map[string]int, len(cm.config.ShadowChannels))
+	for k, v := range cm.config.ShadowChannels {
+		result[k] = v
+	}
+	return result
+}
+
+// GetShadowUpstream 获取指定接口类型的影子渠道（返回深拷贝）
+// 未配置或索引失效时 ok 为 false
+func (cm *ConfigManager) GetShadowUpstream(kind string) (upstream *UpstreamConfig, index int, ok bool) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	index, exists := cm.config.ShadowChannels[kind]
+	if !exists {
+		return nil, -1, false
+	}
+	upstreams, valid := cm.upstreamsByKindLocked(kind)
+	if !valid || index < 0 || index >= len(upstreams) {
+		return nil, -1, false
+	}
+	return upstreams[index].Clone(), index, true
+}
+
+// SetShadowChannel 设置指定接口类型的影子渠道（index < 0 表示清除）
+func (cm *ConfigManager) SetShadowChannel(kind string, index int) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	upstreams, valid := cm.upstreamsByKindLocked(kind)
+	if !valid {
+		return fmt.Errorf("无效的接口类型: %s", kind)
+	}
+
+	if index < 0 {
+		delete(cm.config.ShadowChannels, kind)
+	} else {
+		if index >= len(upstreams) {
+			return fmt.Errorf("无效的渠道索引: %d", index)
+		}
+		if cm.config.ShadowChannels == nil {
+			cm.config.ShadowChannels = make(map[string]int)
+		}
+		cm.config.ShadowChannels[kind] = index
+	}
+
+	if err := cm.saveConfigLocked(cm.config); err != nil {
+		return err
+	}
+
+	if index < 0 {
+		log.Printf("[Config-Shadow] %s 影子渠道已清除", kind)
+	} else {
+		log.Printf("[Config-Shadow] %s 影子渠道已设置为 [%d] %s", kind, index, upstreams[index].Name)
+	}
+	return nil
+}
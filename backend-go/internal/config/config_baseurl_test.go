@@ -230,6 +230,13 @@ func TestGetAllBaseURLs_Priority(t *testing.T) {
 			},
 			want: nil,
 		},
+		{
+			name: "baseUrls 重复地址按首次出现顺序去重",
+			upstream: UpstreamConfig{
+				BaseURLs: []string{"https://multi-1.example.com", "https://multi-2.example.com", "https://multi-1.example.com/"},
+			},
+			want: []string{"https://multi-1.example.com", "https://multi-2.example.com"},
+		},
 		{
 			name: "baseUrls 为空切片时回退到 baseUrl",
 			upstream: UpstreamConfig{
@@ -0,0 +1,56 @@
+package config
+
+import "testing"
+
+func TestGetNextAPIKey_SkipsOverBudgetKeys(t *testing.T) {
+	configFile := writeTestConfigFile(t, Config{
+		Upstream: []UpstreamConfig{{Name: "a", BaseURL: "https://a.example.com", APIKeys: []string{"sk-1", "sk-2"}, ServiceType: "claude", DailyTokenBudget: 1000}},
+	})
+
+	cm, err := NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("NewConfigManager() err = %v", err)
+	}
+	defer cm.Close()
+
+	usage := map[string]int64{}
+	cm.SetKeyBudgetChecker("Messages", func(upstream *UpstreamConfig, apiKey string) bool {
+		return usage[apiKey] >= upstream.DailyTokenBudget
+	})
+
+	upstream := cm.GetConfig().Upstream[0]
+	if key, err := cm.GetNextAPIKey(&upstream, nil, "Messages"); err != nil || key != "sk-1" {
+		t.Fatalf("GetNextAPIKey() = %q, %v, want sk-1", key, err)
+	}
+
+	// sk-1 超出预算后跳过
+	usage["sk-1"] = 1200
+	if key, err := cm.GetNextAPIKey(&upstream, nil, "Messages"); err != nil || key != "sk-2" {
+		t.Fatalf("GetNextAPIKey() = %q, %v, want sk-2", key, err)
+	}
+
+	// 全部超出预算时返回错误
+	usage["sk-2"] = 1000
+	if _, err := cm.GetNextAPIKey(&upstream, nil, "Messages"); err == nil {
+		t.Fatalf("所有 Key 超出预算时应返回错误")
+	}
+	// 超出预算的状态按 Key 记录（仅状态变化时输出日志）
+	if len(cm.overBudgetState) != 2 {
+		t.Fatalf("overBudgetState = %v, want 2 个超出预算的 Key", cm.overBudgetState)
+	}
+
+	// 跨天后用量清零，Key 恢复可用
+	usage = map[string]int64{}
+	if key, err := cm.GetNextAPIKey(&upstream, nil, "Messages"); err != nil || key != "sk-1" {
+		t.Fatalf("GetNextAPIKey() = %q, %v, want sk-1", key, err)
+	}
+	if len(cm.overBudgetState) != 0 {
+		t.Fatalf("恢复后 overBudgetState = %v, want 空", cm.overBudgetState)
+	}
+
+	// 其他接口类型未注入检查时不受影响
+	usage["sk-1"], usage["sk-2"] = 5000, 5000
+	if _, err := cm.GetNextAPIKey(&upstream, nil, "Chat"); err != nil {
+		t.Fatalf("未注入预算检查时不应返回错误: %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package config
+
+import "testing"
+
+// TestAddConfigChangeHook_PushesCircuitOverrides 注册时立即回调，配置保存后再次回调最新的熔断覆盖
+func TestAddConfigChangeHook_PushesCircuitOverrides(t *testing.T) {
+	cm, err := NewConfigManager(writeTestConfigFile(t, Config{
+		Upstream: []UpstreamConfig{{Name: "a", BaseURL: "https://a.example.com", APIKeys: []string{"sk-a"}}},
+	}))
+	if err != nil {
+		t.Fatalf("NewConfigManager() err = %v", err)
+	}
+	defer cm.Close()
+
+	var got map[string]CircuitOverride
+	calls := 0
+	cm.AddConfigChangeHook(func(cfg *Config) {
+		calls++
+		got = cfg.CircuitOverridesByBaseURL("messages")
+	})
+	if calls != 1 || len(got) != 0 {
+		t.Fatalf("注册后 calls=%d overrides=%v, want 1 次回调且无覆盖", calls, got)
+	}
+
+	lowQuality := true
+	if _, err := cm.UpdateUpstream(0, UpstreamUpdate{LowQuality: &lowQuality}); err != nil {
+		t.Fatalf("UpdateUpstream() err = %v", err)
+	}
+	want := CircuitOverride{FailureThreshold: LowQualityCircuitFailureThreshold, RecoveryTime: LowQualityCircuitRecovery}
+	if calls < 2 || got["https://a.example.com"] != want {
+		t.Fatalf("保存后 calls=%d overrides=%v, want %v", calls, got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ReorderAPIKeys 按给定顺序整体重排渠道的 API Key，并只保存一次配置
+// ordered 必须与当前 Key 列表包含相同的 Key（Key 在计算顺序期间被增删时返回错误，由调用方下个周期重试）
+func (cm *ConfigManager) ReorderAPIKeys(kind string, index int, ordered []string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	upstreams, valid := cm.upstreamsByKindLocked(kind)
+	if !valid {
+		return fmt.Errorf("无效的接口类型: %s", kind)
+	}
+	if index < 0 || index >= len(upstreams) {
+		return fmt.Errorf("无效的上游索引: %d", index)
+	}
+
+	upstream := &upstreams[index]
+	if len(ordered) != len(upstream.APIKeys) {
+		return fmt.Errorf("API密钥列表已变化")
+	}
+	remaining := make(map[string]int, len(upstream.APIKeys))
+	for _, key := range upstream.APIKeys {
+		remaining[key]++
+	}
+	for _, key := range ordered {
+		if remaining[key] == 0 {
+			return fmt.Errorf("API密钥列表已变化")
+		}
+		remaining[key]--
+	}
+	if slices.Equal(upstream.APIKeys, ordered) {
+		return nil
+	}
+
+	upstream.APIKeys = slices.Clone(ordered)
+	return cm.saveConfigLocked(cm.config)
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestReorderAPIKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		ordered  []string
+		wantErr  bool
+		wantKeys []string
+	}{
+		{name: "整体重排", ordered: []string{"sk-c", "sk-a", "sk-b"}, wantKeys: []string{"sk-c", "sk-a", "sk-b"}},
+		{name: "Key 列表已变化", ordered: []string{"sk-c", "sk-a", "sk-d"}, wantErr: true, wantKeys: []string{"sk-a", "sk-b", "sk-c"}},
+		{name: "Key 数量不同", ordered: []string{"sk-b", "sk-a"}, wantErr: true, wantKeys: []string{"sk-a", "sk-b", "sk-c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{ChatUpstream: []UpstreamConfig{{Name: "a", BaseURL: "https://a.example.com", APIKeys: []string{"sk-a", "sk-b", "sk-c"}}}}
+			path := writeTestConfigFile(t, cfg)
+			cm, err := NewConfigManager(path)
+			if err != nil {
+				t.Fatalf("NewConfigManager() err = %v", err)
+			}
+			defer cm.Close()
+
+			err = cm.ReorderAPIKeys("chat", 0, tt.ordered)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReorderAPIKeys() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := cm.GetConfig().ChatUpstream[0].APIKeys; !slices.Equal(got, tt.wantKeys) {
+				t.Fatalf("APIKeys = %v, want %v", got, tt.wantKeys)
+			}
+
+			// 重排结果已持久化
+			reloaded, err := NewConfigManager(path)
+			if err != nil {
+				t.Fatalf("NewConfigManager() err = %v", err)
+			}
+			defer reloaded.Close()
+			if got := reloaded.GetConfig().ChatUpstream[0].APIKeys; !slices.Equal(got, tt.wantKeys) {
+				t.Fatalf("持久化 APIKeys = %v, want %v", got, tt.wantKeys)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/BenedictKing/ccx/internal/utils"
+)
+
+// RotateAPIKey 原地轮换渠道内的 API Key：新 Key 占据旧 Key 的位置（保持 Key 顺序）
+// 旧 Key 的统计由调用方迁移到新 Key，因此旧 Key 不进入历史列表，避免聚合统计重复计算
+// 返回轮换后的渠道深拷贝，供调用方获取 BaseURL 列表迁移指标
+func (cm *ConfigManager) RotateAPIKey(kind string, index int, oldKey, newKey string) (*UpstreamConfig, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	upstreams, valid := cm.upstreamsByKindLocked(kind)
+	if !valid {
+		return nil, fmt.Errorf("无效的接口类型: %s", kind)
+	}
+	if index < 0 || index >= len(upstreams) {
+		return nil, fmt.Errorf("无效的上游索引: %d", index)
+	}
+	if newKey == "" {
+		return nil, fmt.Errorf("新API密钥不能为空")
+	}
+	if oldKey == newKey {
+		return nil, fmt.Errorf("新旧API密钥相同")
+	}
+
+	upstream := &upstreams[index]
+	position := -1
+	for i, key := range upstream.APIKeys {
+		if key == newKey {
+			return nil, fmt.Errorf("API密钥已存在")
+		}
+		if key == oldKey {
+			position = i
+		}
+	}
+	if position == -1 {
+		return nil, fmt.Errorf("API密钥不存在")
+	}
+
+	upstream.APIKeys[position] = newKey
+
+	// 新 Key 曾被移入历史列表时移除（其统计已作为当前 Key 展示）
+	var newHistoricalKeys []string
+	for _, hk := range upstream.HistoricalAPIKeys {
+		if hk != newKey {
+			newHistoricalKeys = append(newHistoricalKeys, hk)
+		}
+	}
+	upstream.HistoricalAPIKeys = newHistoricalKeys
+
+	if err := cm.saveConfigLocked(cm.config); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Config-Key] %s 上游 [%d] %s: Key %s 已轮换为 %s",
+		kind, index, upstream.Name, utils.MaskAPIKey(oldKey), utils.MaskAPIKey(newKey))
+	return upstream.Clone(), nil
+}
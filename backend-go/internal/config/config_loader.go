@@ -48,6 +48,11 @@ func (cm *ConfigManager) loadConfig() error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
+	return cm.loadConfigLocked()
+}
+
+// loadConfigLocked 加载配置（调用方需持有 cm.mu 写锁）
+func (cm *ConfigManager) loadConfigLocked() error {
 	// 如果配置文件不存在，创建默认配置
 	if _, err := os.Stat(cm.configFile); os.IsNotExist(err) {
 		return cm.createDefaultConfig()
@@ -59,9 +64,17 @@ func (cm *ConfigManager) loadConfig() error {
 		return err
 	}
 
-	if err := json.Unmarshal(data, &cm.config); err != nil {
+	var loaded Config
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return err
+	}
+
+	// 校验失败时保留当前配置（热重载场景下避免错误配置覆盖运行中的配置）
+	if err := ValidateConfig(&loaded); err != nil {
 		return err
 	}
+	cm.config = loaded
+	cm.notifyConfigChangedLocked()
 
 	// 兼容旧配置：检查 FuzzyModeEnabled 字段是否存在
 	// 如果不存在，默认设为 true（新功能默认启用）
@@ -251,6 +264,12 @@ func (cm *ConfigManager) validateChannelKeys() bool {
 
 // saveConfigLocked 保存配置（已加锁）
 func (cm *ConfigManager) saveConfigLocked(config Config) error {
+	if err := ValidateConfig(&config); err != nil {
+		// 调用方可能已就地修改 cm.config，校验失败时恢复最近一次生效的配置
+		cm.restoreConfigLocked()
+		return err
+	}
+
 	// 备份当前配置
 	cm.backupConfig()
 
@@ -264,7 +283,41 @@ func (cm *ConfigManager) saveConfigLocked(config Config) error {
 	}
 
 	cm.config = config
-	return os.WriteFile(cm.configFile, data, 0600) // 仅所有者可读写，保护敏感配置
+	// 仅所有者可读写，保护敏感配置；写入成功后才切换派生状态（归一化规则等），避免未落盘的配置提前生效
+	if err := os.WriteFile(cm.configFile, data, 0600); err != nil {
+		return err
+	}
+	cm.notifyConfigChangedLocked()
+	return nil
+}
+
+// ConfigChangeHook 内存配置被替换（加载、保存、恢复）后的回调
+// 在持有配置写锁时调用，cfg 只读；实现中不能回调 ConfigManager
+type ConfigChangeHook func(cfg *Config)
+
+// AddConfigChangeHook 注册配置变更回调，注册时立即以当前配置调用一次
+func (cm *ConfigManager) AddConfigChangeHook(hook ConfigChangeHook) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.changeHooks = append(cm.changeHooks, hook)
+	hook(&cm.config)
+}
+
+// notifyConfigChangedLocked 内存配置被替换后同步派生状态并通知回调（调用方需持有写锁）
+func (cm *ConfigManager) notifyConfigChangedLocked() {
+	cm.lastValidConfig = cm.config.clone()
+	rebuildModelRegexCache(&cm.config)
+	cm.modelNormalizer.Store(newModelNormalizer(cm.config.ModelAliases))
+	for _, hook := range cm.changeHooks {
+		hook(&cm.config)
+	}
+}
+
+// restoreConfigLocked 将内存配置恢复为最近一次生效的配置（已加锁）
+// 快照在加载/保存成功时更新，已通过校验且应用了默认值与迁移；派生状态与快照一致，无需重新通知
+func (cm *ConfigManager) restoreConfigLocked() {
+	cm.config = cm.lastValidConfig.clone()
 }
 
 // SaveConfig 保存配置
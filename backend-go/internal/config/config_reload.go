@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+)
+
+// ReloadSummary 配置热重载的渠道变更摘要，key 为接口类型（messages/responses/gemini/chat），value 为渠道名称
+type ReloadSummary struct {
+	Added   map[string][]string `json:"added"`
+	Removed map[string][]string `json:"removed"`
+	Changed map[string][]string `json:"changed"`
+}
+
+// HasChanges 是否存在渠道增删改
+func (s *ReloadSummary) HasChanges() bool {
+	return len(s.Added) > 0 || len(s.Removed) > 0 || len(s.Changed) > 0
+}
+
+// ReloadConfig 从磁盘重新读取配置，校验通过后在写锁下整体替换内存配置
+// 配置文件不存在或校验失败时保留当前配置并返回错误
+func (cm *ConfigManager) ReloadConfig() (*ReloadSummary, error) {
+	if _, err := os.Stat(cm.configFile); err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	summary, err := cm.reloadConfigWithSummary()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[Config-Reload] 配置已从磁盘重载 (新增: %v, 移除: %v, 变更: %v)", summary.Added, summary.Removed, summary.Changed)
+	return summary, nil
+}
+
+// reloadConfigWithSummary 在同一次写锁内重载配置并对比重载前后的渠道，避免并发写入被计入变更摘要
+func (cm *ConfigManager) reloadConfigWithSummary() (*ReloadSummary, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	before := cm.config
+	if err := cm.loadConfigLocked(); err != nil {
+		return nil, err
+	}
+	return diffConfigChannels(before, cm.config), nil
+}
+
+// diffConfigChannels 按渠道名称对比两份配置的渠道列表
+func diffConfigChannels(before, after Config) *ReloadSummary {
+	summary := &ReloadSummary{
+		Added:   make(map[string][]string),
+		Removed: make(map[string][]string),
+		Changed: make(map[string][]string),
+	}
+
+	kinds := []struct {
+		name   string
+		before []UpstreamConfig
+		after  []UpstreamConfig
+	}{
+		{"messages", before.Upstream, after.Upstream},
+		{"responses", before.ResponsesUpstream, after.ResponsesUpstream},
+		{"gemini", before.GeminiUpstream, after.GeminiUpstream},
+		{"chat", before.ChatUpstream, after.ChatUpstream},
+	}
+
+	for _, kind := range kinds {
+		oldByName := make(map[string]UpstreamConfig, len(kind.before))
+		for _, up := range kind.before {
+			oldByName[up.Name] = up
+		}
+		newNames := make(map[string]bool, len(kind.after))
+		for _, up := range kind.after {
+			newNames[up.Name] = true
+			old, existed := oldByName[up.Name]
+			if !existed {
+				summary.Added[kind.name] = append(summary.Added[kind.name], up.Name)
+			} else if !reflect.DeepEqual(old, up) {
+				summary.Changed[kind.name] = append(summary.Changed[kind.name], up.Name)
+			}
+		}
+		for _, up := range kind.before {
+			if !newNames[up.Name] {
+				summary.Removed[kind.name] = append(summary.Removed[kind.name], up.Name)
+			}
+		}
+	}
+
+	return summary
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestReloadConfig_PicksUpNewChannel(t *testing.T) {
+	configFile := writeTestConfigFile(t, Config{
+		Upstream: []UpstreamConfig{{Name: "a", BaseURL: "https://a.example.com", APIKeys: []string{"sk-1"}, ServiceType: "claude"}},
+	})
+
+	cm, err := NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("NewConfigManager() err = %v", err)
+	}
+	// 停止文件监听，确保变更只由 ReloadConfig 读取
+	cm.Close()
+
+	newCfg := Config{
+		Upstream: []UpstreamConfig{
+			{Name: "a", BaseURL: "https://a2.example.com", APIKeys: []string{"sk-1"}, ServiceType: "claude"},
+			{Name: "b", BaseURL: "https://b.example.com", APIKeys: []string{"sk-2"}, ServiceType: "claude"},
+		},
+	}
+	data, _ := json.MarshalIndent(newCfg, "", "  ")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+
+	summary, err := cm.ReloadConfig()
+	if err != nil {
+		t.Fatalf("ReloadConfig() err = %v", err)
+	}
+	if got := summary.Added["messages"]; !reflect.DeepEqual(got, []string{"b"}) {
+		t.Fatalf("Added[messages] = %v, want [b]", got)
+	}
+	if got := summary.Changed["messages"]; !reflect.DeepEqual(got, []string{"a"}) {
+		t.Fatalf("Changed[messages] = %v, want [a]", got)
+	}
+	if len(summary.Removed) != 0 {
+		t.Fatalf("Removed = %v, want empty", summary.Removed)
+	}
+	if got := len(cm.GetConfig().Upstream); got != 2 {
+		t.Fatalf("len(Upstream) = %d, want 2", got)
+	}
+
+	// 无效配置不替换当前配置
+	if err := os.WriteFile(configFile, []byte(`{"upstream": [{"name": "", "baseUrl": ""}]}`), 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	if _, err := cm.ReloadConfig(); err == nil {
+		t.Fatal("ReloadConfig() 期望返回校验错误")
+	}
+	if got := len(cm.GetConfig().Upstream); got != 2 {
+		t.Fatalf("无效配置后 len(Upstream) = %d, want 2", got)
+	}
+}
@@ -162,6 +162,10 @@ func (cm *ConfigManager) UpdateResponsesUpstream(index int, updates UpstreamUpda
 				upstream.Status = "active"
 				log.Printf("[Config-Upstream] Responses 渠道 [%d] %s 已从暂停状态自动激活（单 key 更换）", index, upstream.Name)
 			}
+		} else if shouldReactivateOnKeyAdd(upstream.Status, upstream.APIKeys, updates.APIKeys) {
+			// 因没有 key 被自动暂停的渠道新增 key 后自动激活（手动暂停、认证探测暂停保持不变）
+			upstream.Status = "active"
+			log.Printf("[Config-Upstream] Responses 渠道 [%d] %s 已从暂停状态自动激活（新增 key）", index, upstream.Name)
 		}
 		upstream.APIKeys = deduplicateStrings(updates.APIKeys)
 	}
@@ -177,6 +181,42 @@ func (cm *ConfigManager) UpdateResponsesUpstream(index int, updates UpstreamUpda
 	if updates.FastMode != nil {
 		upstream.FastMode = *updates.FastMode
 	}
+	if updates.AutoReorderKeys != nil {
+		upstream.AutoReorderKeys = *updates.AutoReorderKeys
+	}
+	if updates.ResponseHeaderTimeout != nil {
+		upstream.ResponseHeaderTimeout = *updates.ResponseHeaderTimeout
+	}
+	if updates.StreamIdleTimeout != nil {
+		upstream.StreamIdleTimeout = *updates.StreamIdleTimeout
+	}
+	if updates.MaxConcurrent != nil {
+		upstream.MaxConcurrent = *updates.MaxConcurrent
+	}
+	if updates.QueueTimeoutMs != nil {
+		upstream.QueueTimeoutMs = *updates.QueueTimeoutMs
+	}
+	if updates.DailyTokenBudget != nil {
+		upstream.DailyTokenBudget = *updates.DailyTokenBudget
+	}
+	if updates.StreamEventDenylist != nil {
+		upstream.StreamEventDenylist = updates.StreamEventDenylist
+	}
+	if updates.BodyTransforms != nil {
+		upstream.BodyTransforms = updates.BodyTransforms
+	}
+	if updates.CircuitFailureThreshold != nil {
+		upstream.CircuitFailureThreshold = *updates.CircuitFailureThreshold
+	}
+	if updates.CircuitRecoverySeconds != nil {
+		upstream.CircuitRecoverySeconds = *updates.CircuitRecoverySeconds
+	}
+	if updates.CanaryPercent != nil {
+		upstream.CanaryPercent = *updates.CanaryPercent
+	}
+	if updates.KeySprayCount != nil {
+		upstream.KeySprayCount = *updates.KeySprayCount
+	}
 	if updates.InsecureSkipVerify != nil {
 		upstream.InsecureSkipVerify = *updates.InsecureSkipVerify
 	}
@@ -198,9 +238,45 @@ func (cm *ConfigManager) UpdateResponsesUpstream(index int, updates UpstreamUpda
 	if updates.CustomHeaders != nil {
 		upstream.CustomHeaders = updates.CustomHeaders
 	}
+	if updates.ForwardHeaderAllowlist != nil {
+		upstream.ForwardHeaderAllowlist = updates.ForwardHeaderAllowlist
+	}
+	if updates.StripHeaders != nil {
+		upstream.StripHeaders = updates.StripHeaders
+	}
 	if updates.ProxyURL != nil {
 		upstream.ProxyURL = *updates.ProxyURL
 	}
+	if updates.ProxyURLs != nil {
+		upstream.ProxyURLs = updates.ProxyURLs
+	}
+	if updates.CompressUpstreamRequests != nil {
+		upstream.CompressUpstreamRequests = *updates.CompressUpstreamRequests
+	}
+	if updates.FailoverStatusCodes != nil {
+		upstream.FailoverStatusCodes = updates.FailoverStatusCodes
+	}
+	if updates.NoFailoverStatusCodes != nil {
+		upstream.NoFailoverStatusCodes = updates.NoFailoverStatusCodes
+	}
+	if updates.Deployment != nil {
+		upstream.Deployment = *updates.Deployment
+	}
+	if updates.APIVersion != nil {
+		upstream.APIVersion = *updates.APIVersion
+	}
+	if updates.CACertPath != nil {
+		upstream.CACertPath = *updates.CACertPath
+	}
+	if updates.ClientCertPath != nil {
+		upstream.ClientCertPath = *updates.ClientCertPath
+	}
+	if updates.ClientKeyPath != nil {
+		upstream.ClientKeyPath = *updates.ClientKeyPath
+	}
+	if updates.ServerName != nil {
+		upstream.ServerName = *updates.ServerName
+	}
 	if updates.SupportedModels != nil {
 		upstream.SupportedModels = updates.SupportedModels
 	}
@@ -227,6 +303,7 @@ func (cm *ConfigManager) RemoveResponsesUpstream(index int) (*UpstreamConfig, er
 
 	// 清理被删除渠道的失败 key 冷却记录
 	cm.clearFailedKeysForUpstream(&removed, "Responses")
+	cm.shiftShadowChannelOnRemoveLocked("responses", index)
 
 	if err := cm.saveConfigLocked(cm.config); err != nil {
 		return nil, err
@@ -252,6 +329,11 @@ func (cm *ConfigManager) AddResponsesAPIKey(index int, apiKey string) error {
 		}
 	}
 
+	upstream := &cm.config.ResponsesUpstream[index]
+	if shouldReactivateOnKeyAdd(upstream.Status, upstream.APIKeys, []string{apiKey}) {
+		upstream.Status = "active"
+		log.Printf("[Config-Upstream] Responses 渠道 [%d] %s 已从暂停状态自动激活（新增 key）", index, upstream.Name)
+	}
 	cm.config.ResponsesUpstream[index].APIKeys = append(cm.config.ResponsesUpstream[index].APIKeys, apiKey)
 
 	// 如果该 Key 在历史列表中，从历史列表移除（换回来了）
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// HasCustomTLS 是否配置了渠道级 TLS 选项（不含 insecureSkipVerify）
+func (u *UpstreamConfig) HasCustomTLS() bool {
+	return u.CACertPath != "" || u.ClientCertPath != "" || u.ClientKeyPath != "" || u.ServerName != ""
+}
+
+// TLSCacheKey 渠道 TLS 配置的缓存键（相同配置的渠道共享 HTTP 客户端）
+func (u *UpstreamConfig) TLSCacheKey() string {
+	return fmt.Sprintf("%t|%s|%s|%s|%s", u.InsecureSkipVerify, u.CACertPath, u.ClientCertPath, u.ClientKeyPath, u.ServerName)
+}
+
+// BuildTLSConfig 按渠道配置构建 *tls.Config
+// 未配置任何 TLS 选项时返回 nil（使用默认配置）；证书文件无法读取或解析时返回错误
+func (u *UpstreamConfig) BuildTLSConfig() (*tls.Config, error) {
+	if !u.InsecureSkipVerify && !u.HasCustomTLS() {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: u.InsecureSkipVerify,
+		ServerName:         u.ServerName,
+	}
+
+	if u.CACertPath != "" {
+		pem, err := os.ReadFile(u.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取 caCertPath 失败: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("caCertPath %s 中没有有效的 PEM 证书", u.CACertPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if u.ClientCertPath != "" || u.ClientKeyPath != "" {
+		if u.ClientCertPath == "" || u.ClientKeyPath == "" {
+			return nil, fmt.Errorf("clientCertPath 与 clientKeyPath 必须同时配置")
+		}
+		cert, err := tls.LoadX509KeyPair(u.ClientCertPath, u.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,103 @@
+package config
+
+import "testing"
+
+// TestUpdateUpstream_AddKeyActivatesSuspendedChannel 向因没有 Key 而暂停的渠道新增 Key 后自动激活；
+// 手动暂停（已有 Key）的渠道新增 Key 后保持暂停（各渠道类型、PUT 与 POST 新增 Key 一致）
+func TestUpdateUpstream_AddKeyActivatesSuspendedChannel(t *testing.T) {
+	kinds := []struct {
+		name   string
+		config func(up UpstreamConfig) Config
+		update func(cm *ConfigManager, updates UpstreamUpdate) (bool, error)
+		addKey func(cm *ConfigManager, apiKey string) error
+		status func(cfg Config) string
+	}{
+		{
+			name:   "messages",
+			config: func(up UpstreamConfig) Config { return Config{Upstream: []UpstreamConfig{up}} },
+			update: func(cm *ConfigManager, updates UpstreamUpdate) (bool, error) { return cm.UpdateUpstream(0, updates) },
+			addKey: func(cm *ConfigManager, apiKey string) error { return cm.AddAPIKey(0, apiKey) },
+			status: func(cfg Config) string { return cfg.Upstream[0].Status },
+		},
+		{
+			name:   "responses",
+			config: func(up UpstreamConfig) Config { return Config{ResponsesUpstream: []UpstreamConfig{up}} },
+			update: func(cm *ConfigManager, updates UpstreamUpdate) (bool, error) {
+				return cm.UpdateResponsesUpstream(0, updates)
+			},
+			addKey: func(cm *ConfigManager, apiKey string) error { return cm.AddResponsesAPIKey(0, apiKey) },
+			status: func(cfg Config) string { return cfg.ResponsesUpstream[0].Status },
+		},
+		{
+			name:   "gemini",
+			config: func(up UpstreamConfig) Config { return Config{GeminiUpstream: []UpstreamConfig{up}} },
+			update: func(cm *ConfigManager, updates UpstreamUpdate) (bool, error) {
+				return cm.UpdateGeminiUpstream(0, updates)
+			},
+			addKey: func(cm *ConfigManager, apiKey string) error { return cm.AddGeminiAPIKey(0, apiKey) },
+			status: func(cfg Config) string { return cfg.GeminiUpstream[0].Status },
+		},
+		{
+			name:   "chat",
+			config: func(up UpstreamConfig) Config { return Config{ChatUpstream: []UpstreamConfig{up}} },
+			update: func(cm *ConfigManager, updates UpstreamUpdate) (bool, error) {
+				return cm.UpdateChatUpstream(0, updates)
+			},
+			addKey: func(cm *ConfigManager, apiKey string) error { return cm.AddChatAPIKey(0, apiKey) },
+			status: func(cfg Config) string { return cfg.ChatUpstream[0].Status },
+		},
+	}
+
+	scenarios := []struct {
+		name       string
+		keys       []string
+		viaPost    bool
+		wantStatus string
+	}{
+		{name: "手动暂停 PUT 新增", keys: []string{"sk-1"}, wantStatus: "suspended"},
+		{name: "手动暂停 POST 新增", keys: []string{"sk-1"}, viaPost: true, wantStatus: "suspended"},
+		{name: "无 Key 暂停 PUT 新增", keys: nil, wantStatus: "active"},
+		{name: "无 Key 暂停 POST 新增", keys: nil, viaPost: true, wantStatus: "active"},
+	}
+
+	for _, kind := range kinds {
+		for _, sc := range scenarios {
+			t.Run(kind.name+"/"+sc.name, func(t *testing.T) {
+				up := UpstreamConfig{Name: "a", BaseURL: "https://a.example.com", APIKeys: sc.keys, Status: "suspended"}
+				cm, err := NewConfigManager(writeTestConfigFile(t, kind.config(up)))
+				if err != nil {
+					t.Fatalf("NewConfigManager() err = %v", err)
+				}
+				defer cm.Close()
+
+				if len(sc.keys) > 0 {
+					// 仅调整顺序、不新增 Key：保持暂停
+					if _, err := kind.update(cm, UpstreamUpdate{APIKeys: sc.keys}); err != nil {
+						t.Fatalf("update err = %v", err)
+					}
+					if got := kind.status(cm.GetConfig()); got != "suspended" {
+						t.Fatalf("未新增 Key 时 status = %q, want suspended", got)
+					}
+				}
+
+				if sc.viaPost {
+					if err := kind.addKey(cm, "sk-new"); err != nil {
+						t.Fatalf("addKey err = %v", err)
+					}
+				} else {
+					// 新增 Key 不触发整渠道熔断重置
+					shouldReset, err := kind.update(cm, UpstreamUpdate{APIKeys: append(append([]string{}, sc.keys...), "sk-new", "sk-other")})
+					if err != nil {
+						t.Fatalf("update err = %v", err)
+					}
+					if shouldReset {
+						t.Fatalf("新增 Key 不应重置整个渠道的熔断状态")
+					}
+				}
+				if got := kind.status(cm.GetConfig()); got != sc.wantStatus {
+					t.Fatalf("新增 Key 后 status = %q, want %s", got, sc.wantStatus)
+				}
+			})
+		}
+	}
+}
@@ -1,13 +1,36 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // ============== 工具函数 ==============
 
+// hasNewAPIKeys 判断 newKeys 中是否包含 oldKeys 之外的 Key
+func hasNewAPIKeys(oldKeys, newKeys []string) bool {
+	for _, key := range newKeys {
+		if !slices.Contains(oldKeys, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldReactivateOnKeyAdd 新增 Key 时是否自动激活暂停的渠道
+// 仅因没有 Key 被自检自动暂停（原 Key 列表为空）的渠道会被激活；手动暂停或认证探测失败暂停的渠道保持暂停。
+// 熔断不修改渠道状态，新增 Key 的熔断状态由调度器 ResetAddedKeysFailureState 重置
+func shouldReactivateOnKeyAdd(status string, oldKeys, newKeys []string) bool {
+	return status == "suspended" && len(oldKeys) == 0 && hasNewAPIKeys(oldKeys, newKeys)
+}
+
 // deduplicateStrings 去重字符串切片，保持原始顺序
 func deduplicateStrings(items []string) []string {
 	if len(items) <= 1 {
@@ -52,15 +75,119 @@ func (e *ConfigError) Error() string {
 
 // ============== 模型重定向 ==============
 
-// RedirectModel 模型重定向
+// 模型映射匹配类型
+const (
+	ModelMatchExact    = "exact"    // 精确匹配映射源
+	ModelMatchWildcard = "wildcard" // 模糊匹配（源与模型互相包含，最长源优先）
+	ModelMatchRegex    = "regex"    // 正则匹配（映射源以 re: 开头，整串匹配）
+	ModelMatchNone     = "none"     // 未命中任何映射规则
+)
+
+// ModelMappingRegexPrefix 正则映射规则前缀，如 "re:gpt-4(.*)" -> "claude-sonnet$1"
+const ModelMappingRegexPrefix = "re:"
+
+// modelRegexCache 已编译的正则映射规则（pattern -> *regexp.Regexp）
+// 仅在配置生效时按当前配置整体重建，校验未通过的配置不会写入缓存，不再使用的规则随旧缓存释放
+var modelRegexCache atomic.Pointer[sync.Map]
+
+// compileModelRegex 编译正则映射规则（整串匹配，目标可通过 $1 引用捕获组）
+// 优先复用生效配置的缓存；未缓存的规则（如校验中的配置）只编译不缓存
+func compileModelRegex(pattern string) (*regexp.Regexp, error) {
+	if cache := modelRegexCache.Load(); cache != nil {
+		if cached, ok := cache.Load(pattern); ok {
+			return cached.(*regexp.Regexp), nil
+		}
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// rebuildModelRegexCache 按配置中仍在使用的正则规则（各渠道 modelMapping 与全局 modelAliases）重建缓存
+// 已编译的规则直接复用；配置已通过校验，无效正则直接忽略
+func rebuildModelRegexCache(cfg *Config) {
+	fresh := &sync.Map{}
+	add := func(mapping map[string]string) {
+		for source := range mapping {
+			pattern, ok := strings.CutPrefix(source, ModelMappingRegexPrefix)
+			if !ok {
+				continue
+			}
+			if re, err := compileModelRegex(pattern); err == nil {
+				fresh.Store(pattern, re)
+			}
+		}
+	}
+
+	for _, upstreams := range [][]UpstreamConfig{cfg.Upstream, cfg.ResponsesUpstream, cfg.GeminiUpstream, cfg.ChatUpstream} {
+		for i := range upstreams {
+			add(upstreams[i].ModelMapping)
+		}
+	}
+	add(cfg.ModelAliases)
+	modelRegexCache.Store(fresh)
+}
+
+// validateModelMapping 校验渠道的正则映射规则可编译
+func validateModelMapping(mapping map[string]string) error {
+	for source := range mapping {
+		pattern, ok := strings.CutPrefix(source, ModelMappingRegexPrefix)
+		if !ok {
+			continue
+		}
+		if _, err := compileModelRegex(pattern); err != nil {
+			return fmt.Errorf("modelMapping 正则 %q 无效: %w", source, err)
+		}
+	}
+	return nil
+}
+
+// ModelMatch 模型重定向命中的映射规则
+type ModelMatch struct {
+	Type   string `json:"type"`             // exact, wildcard, regex, none
+	Source string `json:"source,omitempty"` // 命中的映射源（Type 为 none 时为空）
+	Target string `json:"target,omitempty"` // 映射目标模型
+}
+
+// RedirectModel 模型重定向（仅按原始模型名匹配映射）
 func RedirectModel(model string, upstream *UpstreamConfig) string {
-	if upstream.ModelMapping == nil || len(upstream.ModelMapping) == 0 {
-		return model
+	redirected, _ := RedirectModelWithMatch(model, upstream, nil)
+	return redirected
+}
+
+// PinNormalizedModelMapping 按归一化模型名命中映射时，返回把该映射固定为原始模型名精确映射的渠道副本，
+// 使请求构建阶段的 RedirectModel 得到相同结果；原始模型名已命中相同映射或未命中任何映射时返回 upstream 本身
+func PinNormalizedModelMapping(upstream *UpstreamConfig, model string, normalize func(string) string) *UpstreamConfig {
+	if upstream == nil || normalize == nil {
+		return upstream
+	}
+	redirected, _ := RedirectModelWithMatch(model, upstream, normalize)
+	if redirected == RedirectModel(model, upstream) {
+		return upstream
+	}
+	pinned := upstream.Clone()
+	pinned.ModelMapping[model] = redirected
+	return pinned
+}
+
+// RedirectModelWithMatch 模型重定向，同时返回命中的映射规则（用于调试映射配置）
+// normalize 非空时，原始模型名未精确命中的情况下按归一化后的模型名匹配其余规则
+func RedirectModelWithMatch(model string, upstream *UpstreamConfig, normalize func(string) string) (string, ModelMatch) {
+	if upstream == nil || len(upstream.ModelMapping) == 0 {
+		return model, ModelMatch{Type: ModelMatchNone}
 	}
 
 	// 直接匹配（精确匹配优先）
 	if mapped, ok := upstream.ModelMapping[model]; ok {
-		return mapped
+		return mapped, ModelMatch{Type: ModelMatchExact, Source: model, Target: mapped}
+	}
+
+	// 其余规则按归一化后的模型名匹配，使同一模型的不同写法命中同一条映射；
+	// 未命中任何映射时仍返回客户端原始模型名
+	original := model
+	if normalize != nil {
+		model = normalize(model)
+		if mapped, ok := upstream.ModelMapping[model]; ok {
+			return mapped, ModelMatch{Type: ModelMatchExact, Source: model, Target: mapped}
+		}
 	}
 
 	// 模糊匹配：按源模型长度从长到短排序，确保最长匹配优先
@@ -69,7 +196,12 @@ type mapping struct {
 		target string
 	}
 	mappings := make([]mapping, 0, len(upstream.ModelMapping))
+	regexMappings := make([]mapping, 0)
 	for source, target := range upstream.ModelMapping {
+		if strings.HasPrefix(source, ModelMappingRegexPrefix) {
+			regexMappings = append(regexMappings, mapping{source, target})
+			continue
+		}
 		mappings = append(mappings, mapping{source, target})
 	}
 	sort.Slice(mappings, func(i, j int) bool {
@@ -78,11 +210,31 @@ type mapping struct {
 
 	for _, m := range mappings {
 		if strings.Contains(model, m.source) || strings.Contains(m.source, model) {
-			return m.target
+			return m.target, ModelMatch{Type: ModelMatchWildcard, Source: m.source, Target: m.target}
+		}
+	}
+
+	// 正则匹配：优先级低于模糊匹配，按源长度从长到短（同长按字典序）保证结果稳定
+	sort.Slice(regexMappings, func(i, j int) bool {
+		if len(regexMappings[i].source) != len(regexMappings[j].source) {
+			return len(regexMappings[i].source) > len(regexMappings[j].source)
 		}
+		return regexMappings[i].source < regexMappings[j].source
+	})
+	for _, m := range regexMappings {
+		re, err := compileModelRegex(strings.TrimPrefix(m.source, ModelMappingRegexPrefix))
+		if err != nil {
+			continue // 无效正则已在配置校验阶段拦截，此处仅防御
+		}
+		submatches := re.FindStringSubmatchIndex(model)
+		if submatches == nil {
+			continue
+		}
+		target := string(re.ExpandString(nil, m.target, model, submatches))
+		return target, ModelMatch{Type: ModelMatchRegex, Source: m.source, Target: target}
 	}
 
-	return model
+	return original, ModelMatch{Type: ModelMatchNone}
 }
 
 // ResolveReasoningEffort 根据原始模型名解析 reasoning effort
@@ -155,6 +307,18 @@ func (u *UpstreamConfig) Clone() *UpstreamConfig {
 		cloned.APIKeys = make([]string, len(u.APIKeys))
 		copy(cloned.APIKeys, u.APIKeys)
 	}
+	if u.ProxyURLs != nil {
+		cloned.ProxyURLs = make([]string, len(u.ProxyURLs))
+		copy(cloned.ProxyURLs, u.ProxyURLs)
+	}
+	if u.FailoverStatusCodes != nil {
+		cloned.FailoverStatusCodes = make([]int, len(u.FailoverStatusCodes))
+		copy(cloned.FailoverStatusCodes, u.FailoverStatusCodes)
+	}
+	if u.NoFailoverStatusCodes != nil {
+		cloned.NoFailoverStatusCodes = make([]int, len(u.NoFailoverStatusCodes))
+		copy(cloned.NoFailoverStatusCodes, u.NoFailoverStatusCodes)
+	}
 	if u.HistoricalAPIKeys != nil {
 		cloned.HistoricalAPIKeys = make([]string, len(u.HistoricalAPIKeys))
 		copy(cloned.HistoricalAPIKeys, u.HistoricalAPIKeys)
@@ -179,6 +343,22 @@ func (u *UpstreamConfig) Clone() *UpstreamConfig {
 		cloned.SupportedModels = make([]string, len(u.SupportedModels))
 		copy(cloned.SupportedModels, u.SupportedModels)
 	}
+	if u.StreamEventDenylist != nil {
+		cloned.StreamEventDenylist = make([]string, len(u.StreamEventDenylist))
+		copy(cloned.StreamEventDenylist, u.StreamEventDenylist)
+	}
+	if u.ForwardHeaderAllowlist != nil {
+		cloned.ForwardHeaderAllowlist = make([]string, len(u.ForwardHeaderAllowlist))
+		copy(cloned.ForwardHeaderAllowlist, u.ForwardHeaderAllowlist)
+	}
+	if u.StripHeaders != nil {
+		cloned.StripHeaders = make([]string, len(u.StripHeaders))
+		copy(cloned.StripHeaders, u.StripHeaders)
+	}
+	if u.BodyTransforms != nil {
+		cloned.BodyTransforms = make([]BodyTransform, len(u.BodyTransforms))
+		copy(cloned.BodyTransforms, u.BodyTransforms)
+	}
 
 	return &cloned
 }
@@ -189,16 +369,8 @@ func (u *UpstreamConfig) SupportsModel(model string) bool {
 	if len(u.SupportedModels) == 0 {
 		return true
 	}
-	for _, pattern := range u.SupportedModels {
-		if strings.HasSuffix(pattern, "*") {
-			if strings.HasPrefix(model, strings.TrimSuffix(pattern, "*")) {
-				return true
-			}
-		} else if pattern == model {
-			return true
-		}
-	}
-	return false
+	_, ok := firstMatchingPattern(u.SupportedModels, model)
+	return ok
 }
 
 // GetEffectiveBaseURL 获取当前应使用的 BaseURL（纯 failover 模式）
@@ -217,13 +389,79 @@ func (u *UpstreamConfig) GetEffectiveBaseURL() string {
 	return ""
 }
 
-// GetAllBaseURLs 获取所有 BaseURL（用于延迟测试）
+// 低质量渠道未显式配置时的熔断覆盖：容忍更高失败率并更快恢复，使其保留为兜底渠道而非长期熔断
+const (
+	LowQualityCircuitFailureThreshold = 0.8
+	LowQualityCircuitRecovery         = 5 * time.Minute
+)
+
+// CircuitOverrides 返回渠道级熔断失败率阈值与恢复时间（0 表示使用全局默认）
+func (u *UpstreamConfig) CircuitOverrides() (failureThreshold float64, recoveryTime time.Duration) {
+	failureThreshold = u.CircuitFailureThreshold
+	recoveryTime = time.Duration(u.CircuitRecoverySeconds) * time.Second
+	if u.LowQuality {
+		if failureThreshold == 0 {
+			failureThreshold = LowQualityCircuitFailureThreshold
+		}
+		if recoveryTime == 0 {
+			recoveryTime = LowQualityCircuitRecovery
+		}
+	}
+	return failureThreshold, recoveryTime
+}
+
+// DefaultAzureAPIVersion 未配置 apiVersion 时使用的 Azure OpenAI API 版本
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// AzureChatCompletionsURL 构建 Azure OpenAI 部署式 Chat Completions URL
+// 格式: {baseURL}/openai/deployments/{deployment}/chat/completions?api-version=...
+// 未配置 deployment 时使用模型映射后的模型名作为部署名（通过 modelMapping 将模型映射到部署）
+func (u *UpstreamConfig) AzureChatCompletionsURL(baseURL, mappedModel string) string {
+	deployment := u.Deployment
+	if deployment == "" {
+		deployment = mappedModel
+	}
+	apiVersion := u.APIVersion
+	if apiVersion == "" {
+		apiVersion = DefaultAzureAPIVersion
+	}
+	baseURL = strings.TrimSuffix(strings.TrimRight(baseURL, "/"), "#")
+	baseURL = strings.TrimSuffix(strings.TrimRight(baseURL, "/"), "/openai")
+	return fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		baseURL, url.PathEscape(deployment), url.QueryEscape(apiVersion))
+}
+
+// GetAllBaseURLs 获取所有 BaseURL（用于延迟测试与 failover）
+// 手工编辑的配置文件可能包含重复地址，按首次出现顺序去重，避免同一端点在一轮重试中被尝试两次
 func (u *UpstreamConfig) GetAllBaseURLs() []string {
 	if len(u.BaseURLs) > 0 {
-		return u.BaseURLs
+		return deduplicateBaseURLs(u.BaseURLs)
 	}
 	if u.BaseURL != "" {
 		return []string{u.BaseURL}
 	}
 	return nil
 }
+
+// GetAllProxyURLs 获取所有代理地址（ProxyURL 在前，ProxyURLs 依次追加，去重并忽略空值）
+func (u *UpstreamConfig) GetAllProxyURLs() []string {
+	if len(u.ProxyURLs) == 0 {
+		if u.ProxyURL != "" {
+			return []string{u.ProxyURL}
+		}
+		return nil
+	}
+	seen := make(map[string]struct{}, len(u.ProxyURLs)+1)
+	result := make([]string, 0, len(u.ProxyURLs)+1)
+	for _, proxyURL := range append([]string{u.ProxyURL}, u.ProxyURLs...) {
+		proxyURL = strings.TrimSpace(proxyURL)
+		if proxyURL == "" {
+			continue
+		}
+		if _, exists := seen[proxyURL]; !exists {
+			seen[proxyURL] = struct{}{}
+			result = append(result, proxyURL)
+		}
+	}
+	return result
+}
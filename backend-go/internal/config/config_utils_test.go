@@ -1,6 +1,9 @@
 package config
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestSupportsModel(t *testing.T) {
 	tests := []struct {
@@ -61,3 +64,147 @@ func TestResolveReasoningEffort(t *testing.T) {
 		})
 	}
 }
+
+func TestRedirectModelWithMatch(t *testing.T) {
+	upstream := &UpstreamConfig{
+		ModelMapping: map[string]string{
+			"opus":            "claude-opus-4",
+			"claude-sonnet-4": "gpt-5",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		model      string
+		wantModel  string
+		wantType   string
+		wantSource string
+	}{
+		{"精确匹配", "opus", "claude-opus-4", ModelMatchExact, "opus"},
+		{"模糊匹配", "claude-sonnet-4-20250514", "gpt-5", ModelMatchWildcard, "claude-sonnet-4"},
+		{"模糊匹配短源", "claude-3-opus-latest", "claude-opus-4", ModelMatchWildcard, "opus"},
+		{"未命中", "gemini-2.5-pro", "gemini-2.5-pro", ModelMatchNone, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, match := RedirectModelWithMatch(tt.model, upstream, nil)
+			if got != tt.wantModel {
+				t.Errorf("RedirectModelWithMatch(%q) model = %q, want %q", tt.model, got, tt.wantModel)
+			}
+			if match.Type != tt.wantType || match.Source != tt.wantSource {
+				t.Errorf("RedirectModelWithMatch(%q) match = %+v, want type=%q source=%q", tt.model, match, tt.wantType, tt.wantSource)
+			}
+			if legacy := RedirectModel(tt.model, upstream); legacy != got {
+				t.Errorf("RedirectModel(%q) = %q, want %q", tt.model, legacy, got)
+			}
+		})
+	}
+
+	if got, match := RedirectModelWithMatch("any", &UpstreamConfig{}, nil); got != "any" || match.Type != ModelMatchNone {
+		t.Errorf("无映射时应原样返回, got %q %+v", got, match)
+	}
+}
+
+func TestRedirectModelWithMatch_RegexPrecedence(t *testing.T) {
+	upstream := &UpstreamConfig{
+		ModelMapping: map[string]string{
+			"gpt-4o":                  "exact-target",
+			"gpt-4-turbo":             "wildcard-target",
+			"re:gpt-4.*":              "regex-target",
+			"re:claude-(\\w+)-(\\d+)": "anthropic-$1-v$2",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		model      string
+		wantModel  string
+		wantType   string
+		wantSource string
+	}{
+		{"精确优先于正则", "gpt-4o", "exact-target", ModelMatchExact, "gpt-4o"},
+		{"模糊优先于正则", "gpt-4-turbo-preview", "wildcard-target", ModelMatchWildcard, "gpt-4-turbo"},
+		{"正则兜底", "gpt-4.1-mini", "regex-target", ModelMatchRegex, "re:gpt-4.*"},
+		{"正则捕获组复用", "claude-opus-4", "anthropic-opus-v4", ModelMatchRegex, "re:claude-(\\w+)-(\\d+)"},
+		{"正则需整串匹配", "my-gpt-4.1", "my-gpt-4.1", ModelMatchNone, ""},
+		{"均未命中", "gemini-2.5-pro", "gemini-2.5-pro", ModelMatchNone, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, match := RedirectModelWithMatch(tt.model, upstream, nil)
+			if got != tt.wantModel {
+				t.Errorf("RedirectModelWithMatch(%q) model = %q, want %q", tt.model, got, tt.wantModel)
+			}
+			if match.Type != tt.wantType || match.Source != tt.wantSource {
+				t.Errorf("RedirectModelWithMatch(%q) match = %+v, want type=%q source=%q", tt.model, match, tt.wantType, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestCircuitOverrides(t *testing.T) {
+	tests := []struct {
+		name          string
+		upstream      UpstreamConfig
+		wantThreshold float64
+		wantRecovery  time.Duration
+	}{
+		{"普通渠道使用全局默认", UpstreamConfig{}, 0, 0},
+		{"低质量渠道使用宽松默认", UpstreamConfig{LowQuality: true}, LowQualityCircuitFailureThreshold, LowQualityCircuitRecovery},
+		{"低质量渠道显式配置优先", UpstreamConfig{LowQuality: true, CircuitFailureThreshold: 0.9, CircuitRecoverySeconds: 60}, 0.9, time.Minute},
+		{"普通渠道显式配置", UpstreamConfig{CircuitRecoverySeconds: 120}, 0, 2 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			threshold, recovery := tt.upstream.CircuitOverrides()
+			if threshold != tt.wantThreshold || recovery != tt.wantRecovery {
+				t.Errorf("CircuitOverrides() = (%v, %v), want (%v, %v)", threshold, recovery, tt.wantThreshold, tt.wantRecovery)
+			}
+		})
+	}
+}
+
+// TestModelRegexCache_RebuiltOnConfigChange 配置保存后不再使用的正则规则从缓存中移除，校验未通过的配置不写入缓存
+func TestModelRegexCache_RebuiltOnConfigChange(t *testing.T) {
+	cm, err := NewConfigManager(writeTestConfigFile(t, Config{
+		Upstream: []UpstreamConfig{
+			{Name: "a", BaseURL: "https://a.example.com", APIKeys: []string{"sk-a"}, ModelMapping: map[string]string{"re:old-(.*)": "claude-$1"}},
+			{Name: "b", BaseURL: "https://b.example.com", APIKeys: []string{"sk-b"}},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("NewConfigManager() err = %v", err)
+	}
+	defer cm.Close()
+
+	if _, ok := modelRegexCache.Load().Load("old-(.*)"); !ok {
+		t.Fatal("加载配置后应缓存正在使用的正则规则")
+	}
+
+	if _, err := cm.UpdateUpstream(0, UpstreamUpdate{ModelMapping: map[string]string{"re:new-(.*)": "claude-$1"}}); err != nil {
+		t.Fatalf("UpdateUpstream() err = %v", err)
+	}
+	cache := modelRegexCache.Load()
+	if _, ok := cache.Load("old-(.*)"); ok {
+		t.Fatal("不再使用的正则规则应从缓存中移除")
+	}
+	if _, ok := cache.Load("new-(.*)"); !ok {
+		t.Fatal("新配置的正则规则应被缓存")
+	}
+
+	// 正则规则校验通过、渠道重名导致整体被拒绝
+	dupName := "b"
+	if _, err := cm.UpdateUpstream(0, UpstreamUpdate{Name: &dupName, ModelMapping: map[string]string{"re:rejected-(.*)": "claude-$1"}}); err == nil {
+		t.Fatal("UpdateUpstream() 应拒绝重名渠道")
+	}
+	cache = modelRegexCache.Load()
+	if _, ok := cache.Load("rejected-(.*)"); ok {
+		t.Fatal("校验未通过的配置中的正则规则不应写入缓存")
+	}
+	if _, ok := cache.Load("new-(.*)"); !ok {
+		t.Fatal("校验失败后生效配置的正则规则应仍在缓存中")
+	}
+}
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/BenedictKing/ccx/internal/utils"
+)
+
+// validServiceTypes 支持的上游服务类型（空值表示使用各接口的默认类型）
+var validServiceTypes = map[string]bool{
+	"":          true,
+	"openai":    true,
+	"gemini":    true,
+	"claude":    true,
+	"responses": true,
+	"azure":     true,
+}
+
+// azureServiceKinds 支持 azure serviceType 的接口类型（Azure OpenAI 仅提供 Chat Completions 兼容接口）
+var azureServiceKinds = map[string]bool{
+	"responses": true,
+	"chat":      true,
+}
+
+// ValidateConfig 校验配置合法性，返回第一条描述性错误
+// 校验项：未知 serviceType、负数 priority/超时、无效的 modelMapping 正则/customHeaders 模板/请求头转发策略、同类型渠道重名、baseUrl 与 baseUrls 同时为空、影子渠道索引越界、负数全局限流/模型定价
+func ValidateConfig(config *Config) error {
+	kinds := []struct {
+		name      string
+		upstreams []UpstreamConfig
+	}{
+		{"messages", config.Upstream},
+		{"responses", config.ResponsesUpstream},
+		{"gemini", config.GeminiUpstream},
+		{"chat", config.ChatUpstream},
+	}
+
+	for _, kind := range kinds {
+		if err := validateUpstreams(kind.name, kind.upstreams); err != nil {
+			return err
+		}
+	}
+
+	if config.GlobalMaxRPM < 0 || config.GlobalMaxTPM < 0 {
+		return &ConfigError{Message: fmt.Sprintf("全局限流上限不能为负数: RPM=%d, TPM=%d", config.GlobalMaxRPM, config.GlobalMaxTPM)}
+	}
+
+	switch config.AffinityMode {
+	case "", AffinityModeDefault, AffinityModeSticky:
+	default:
+		return &ConfigError{Message: fmt.Sprintf("无效的亲和模式: %s（可选 default / sticky）", config.AffinityMode)}
+	}
+
+	switch config.SchedulingMode {
+	case "", SchedulingModePriority, SchedulingModeLeastLoaded:
+	default:
+		return &ConfigError{Message: fmt.Sprintf("无效的调度模式: %s（可选 priority / least_loaded）", config.SchedulingMode)}
+	}
+
+	if err := validateModelAliases(config.ModelAliases); err != nil {
+		return &ConfigError{Message: err.Error()}
+	}
+
+	for model, price := range config.ModelPricing {
+		if price.Input < 0 || price.Output < 0 || price.CacheRead < 0 || price.CacheCreation < 0 {
+			return &ConfigError{Message: fmt.Sprintf("模型 %s 的定价不能为负数", model)}
+		}
+	}
+
+	for _, kind := range kinds {
+		index, exists := config.ShadowChannels[kind.name]
+		if exists && (index < 0 || index >= len(kind.upstreams)) {
+			return &ConfigError{Message: fmt.Sprintf("%s 影子渠道索引越界: %d", kind.name, index)}
+		}
+	}
+	for kind := range config.ShadowChannels {
+		switch kind {
+		case "messages", "responses", "gemini", "chat":
+		default:
+			return &ConfigError{Message: fmt.Sprintf("未知的影子渠道接口类型: %s", kind)}
+		}
+	}
+
+	if err := validateModelLists("allowedModels", config.AllowedModels); err != nil {
+		return err
+	}
+	if err := validateModelLists("deniedModels", config.DeniedModels); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateUpstreams 校验单个接口类型下的渠道列表
+func validateUpstreams(kind string, upstreams []UpstreamConfig) error {
+	seenNames := make(map[string]int, len(upstreams))
+
+	for i := range upstreams {
+		upstream := &upstreams[i]
+		label := fmt.Sprintf("%s 渠道 [%d] %s", kind, i, upstream.Name)
+
+		if !validServiceTypes[upstream.ServiceType] {
+			return &ConfigError{Message: fmt.Sprintf("%s: 未知的 serviceType %q（可选值: openai, claude, gemini, responses, azure）", label, upstream.ServiceType)}
+		}
+		if upstream.ServiceType == "azure" && !azureServiceKinds[kind] {
+			return &ConfigError{Message: fmt.Sprintf("%s: serviceType azure 仅支持 responses 和 chat 渠道", label)}
+		}
+
+		if upstream.Priority < 0 {
+			return &ConfigError{Message: fmt.Sprintf("%s: priority 不能为负数: %d", label, upstream.Priority)}
+		}
+
+		if upstream.ResponseHeaderTimeout < 0 || upstream.StreamIdleTimeout < 0 {
+			return &ConfigError{Message: fmt.Sprintf("%s: 超时时间不能为负数", label)}
+		}
+
+		if upstream.MaxConcurrent < 0 || upstream.QueueTimeoutMs < 0 {
+			return &ConfigError{Message: fmt.Sprintf("%s: maxConcurrent 和 queueTimeoutMs 不能为负数", label)}
+		}
+
+		if upstream.DailyTokenBudget < 0 {
+			return &ConfigError{Message: fmt.Sprintf("%s: dailyTokenBudget 不能为负数: %d", label, upstream.DailyTokenBudget)}
+		}
+
+		if upstream.CircuitFailureThreshold < 0 || upstream.CircuitFailureThreshold > 1 {
+			return &ConfigError{Message: fmt.Sprintf("%s: circuitFailureThreshold 必须在 0-1 之间: %v", label, upstream.CircuitFailureThreshold)}
+		}
+
+		if upstream.CircuitRecoverySeconds < 0 {
+			return &ConfigError{Message: fmt.Sprintf("%s: circuitRecoverySeconds 不能为负数: %d", label, upstream.CircuitRecoverySeconds)}
+		}
+
+		if upstream.CanaryPercent < 0 || upstream.CanaryPercent > 100 {
+			return &ConfigError{Message: fmt.Sprintf("%s: canaryPercent 必须在 0-100 之间: %d", label, upstream.CanaryPercent)}
+		}
+
+		if upstream.KeySprayCount < 0 {
+			return &ConfigError{Message: fmt.Sprintf("%s: keySprayCount 不能为负数: %d", label, upstream.KeySprayCount)}
+		}
+
+		for key, value := range upstream.CustomHeaders {
+			if err := utils.ValidateHeaderTemplate(value); err != nil {
+				return &ConfigError{Message: fmt.Sprintf("%s: customHeaders %s: %v", label, key, err)}
+			}
+		}
+
+		if err := validateHeaderNames("forwardHeaderAllowlist", upstream.ForwardHeaderAllowlist); err != nil {
+			return &ConfigError{Message: fmt.Sprintf("%s: %v", label, err)}
+		}
+		if err := validateHeaderNames("stripHeaders", upstream.StripHeaders); err != nil {
+			return &ConfigError{Message: fmt.Sprintf("%s: %v", label, err)}
+		}
+
+		if err := ValidateBodyTransforms(upstream.BodyTransforms); err != nil {
+			return &ConfigError{Message: fmt.Sprintf("%s: bodyTransforms %v", label, err)}
+		}
+
+		for _, proxyURL := range upstream.ProxyURLs {
+			if err := validateProxyURL(proxyURL); err != nil {
+				return &ConfigError{Message: fmt.Sprintf("%s: proxyUrls: %v", label, err)}
+			}
+		}
+
+		if err := validateFailoverStatusCodes(upstream.FailoverStatusCodes, upstream.NoFailoverStatusCodes); err != nil {
+			return &ConfigError{Message: fmt.Sprintf("%s: %v", label, err)}
+		}
+
+		if err := validateModelMapping(upstream.ModelMapping); err != nil {
+			return &ConfigError{Message: fmt.Sprintf("%s: %v", label, err)}
+		}
+
+		if upstream.HasCustomTLS() {
+			if _, err := upstream.BuildTLSConfig(); err != nil {
+				return &ConfigError{Message: fmt.Sprintf("%s: TLS 配置无效: %v", label, err)}
+			}
+		}
+
+		if strings.TrimSpace(upstream.BaseURL) == "" && len(upstream.BaseURLs) == 0 {
+			return &ConfigError{Message: fmt.Sprintf("%s: baseUrl 和 baseUrls 不能同时为空", label)}
+		}
+
+		// 名称为空的渠道不参与重名检查
+		if upstream.Name != "" {
+			if prev, exists := seenNames[upstream.Name]; exists {
+				return &ConfigError{Message: fmt.Sprintf("%s: 名称与渠道 [%d] 重复", label, prev)}
+			}
+			seenNames[upstream.Name] = i
+		}
+	}
+
+	return nil
+}
+
+// validateProxyURL 校验代理地址格式（仅支持 http/https/socks5/socks5h）
+func validateProxyURL(proxyURL string) error {
+	u, err := url.Parse(strings.TrimSpace(proxyURL))
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("无效的代理地址 %q", utils.RedactURLCredentials(proxyURL))
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "socks5", "socks5h":
+		return nil
+	default:
+		return fmt.Errorf("不支持的代理协议 %q（可选值: http, https, socks5, socks5h）", u.Scheme)
+	}
+}
+
+// validateFailoverStatusCodes 校验渠道级 failover 状态码策略
+// 状态码必须为非 2xx 的合法 HTTP 状态码，且同一状态码不能同时出现在两个列表中
+func validateFailoverStatusCodes(failoverCodes, noFailoverCodes []int) error {
+	seen := make(map[int]bool, len(failoverCodes))
+	for _, code := range failoverCodes {
+		if code < 300 || code > 599 {
+			return fmt.Errorf("failoverStatusCodes 包含无效状态码: %d（允许 300-599）", code)
+		}
+		seen[code] = true
+	}
+	for _, code := range noFailoverCodes {
+		if code < 300 || code > 599 {
+			return fmt.Errorf("noFailoverStatusCodes 包含无效状态码: %d（允许 300-599）", code)
+		}
+		if seen[code] {
+			return fmt.Errorf("状态码 %d 不能同时出现在 failoverStatusCodes 与 noFailoverStatusCodes 中", code)
+		}
+	}
+	return nil
+}
+
+// validateHeaderNames 校验请求头转发策略中的头部名称（不能为空或包含空白字符，* 仅允许出现在末尾）
+func validateHeaderNames(field string, names []string) error {
+	for _, name := range names {
+		trimmed := strings.TrimSpace(name)
+		if trimmed == "" || strings.ContainsAny(trimmed, " \t:") {
+			return fmt.Errorf("%s 包含无效的请求头名称: %q", field, name)
+		}
+		if i := strings.Index(trimmed, "*"); i >= 0 && i != len(trimmed)-1 {
+			return fmt.Errorf("%s 的通配符只能出现在末尾: %q", field, name)
+		}
+	}
+	return nil
+}
@@ -3,8 +3,20 @@
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// metricsKinds 拥有独立指标管理器的接口类型
+var metricsKinds = []string{"messages", "responses", "gemini", "chat"}
+
+// MetricsKindSettings 单个接口类型指标管理器的熔断参数（零值表示沿用全局配置）
+type MetricsKindSettings struct {
+	WindowSize          int
+	FailureThreshold    float64
+	CircuitRecoveryTime time.Duration
+}
+
 type EnvConfig struct {
 	Port                 int
 	Env                  string
@@ -18,6 +30,7 @@ type EnvConfig struct {
 	QuietPollingLogs     bool   // 静默轮询端点日志
 	RawLogOutput         bool   // 原始日志输出（不缩进、不截断、不重排序）
 	SSEDebugLevel        string // SSE 调试级别: off, summary, full
+	PreserveSSEEvents    bool   // Claude 透传流式响应中保证每个事件带 event: 行（上游缺失时按 data 的 type 补全）
 	RewriteResponseModel bool   // 是否改写响应中的 model 字段为请求的 model（默认 false）
 
 	RequestTimeout     int
@@ -25,13 +38,84 @@ type EnvConfig struct {
 	EnableCORS         bool
 	CORSOrigin         string
 	// 指标配置
-	MetricsWindowSize       int     // 滑动窗口大小
-	MetricsFailureThreshold float64 // 失败率阈值
+	MetricsWindowSize         int     // 滑动窗口大小
+	MetricsFailureThreshold   float64 // 失败率阈值
+	MetricsCircuitRecovery    int     // 熔断自动恢复时间（秒）
+	MetricsTPMIncludeThinking bool    // TPM 是否计入上游单独返回的思考 tokens
+	// TPM 统计口径：output（仅输出）、output+input（输出 + 输入）或 total_incl_cache（含缓存 tokens 的总量）
+	MetricsTPMDefinition string
+	// 按接口类型覆盖滑动窗口/失败率阈值/恢复时间（METRICS_<KIND>_* 环境变量），未配置的项沿用全局值
+	MetricsKindOverrides map[string]MetricsKindSettings
+	// 自适应熔断阈值（按 Key 最近 RPM 在宽松与严格阈值之间线性插值）
+	AdaptiveThresholdEnabled bool
+	AdaptiveThresholdLowRPM  float64 // 不高于该 RPM 时使用宽松阈值
+	AdaptiveThresholdHighRPM float64 // 不低于该 RPM 时使用严格阈值
+	AdaptiveThresholdLenient float64 // 低流量失败率阈值
+	AdaptiveThresholdStrict  float64 // 高流量失败率阈值
+	// 渠道健康度聚合策略：pooled（合并所有 Key 的结果）或 per_key_mean（各 Key 失败率等权平均）
+	MetricsHealthAggregation string
+	// 仪表盘成功率最小样本数：低于该值时标记 insufficientData（成功率不具参考意义），0 表示不限制
+	MetricsDashboardMinSamples int
+	// 过期 Key 指标清理配置
+	MetricsCleanupChunkSize int // 每次持有写锁处理的 Key 数量
+	MetricsCleanupJitter    int // 清理周期的最大随机抖动（秒），0 表示不加抖动
 	// 指标持久化配置
-	MetricsPersistenceEnabled bool // 是否启用 SQLite 持久化
-	MetricsRetentionDays      int  // 数据保留天数（3-30）
+	MetricsPersistenceEnabled bool   // 是否启用 SQLite 持久化
+	MetricsRetentionDays      int    // 数据保留天数（3-30）
+	MetricsDBPath             string // SQLite 数据库文件路径
 	// HTTP 客户端配置
-	ResponseHeaderTimeout int // 等待响应头超时时间（秒）
+	ResponseHeaderTimeout int // 连接 + 等待响应头超时时间（秒）
+	StreamIdleTimeout     int // 流式响应空闲超时时间（秒，每收到数据重置），0 表示禁用
+	// 请求级超时覆盖（X-CCX-Timeout-Ms 请求头）
+	EnableTimeoutOverrideHeader bool // 是否允许客户端按请求覆盖上游超时
+	MaxTimeoutOverrideMs        int  // 覆盖值上限（毫秒），超过时截断
+	// 慢客户端背压保护
+	StreamBackpressureTimeout int // 流式事件缓冲区持续满载超过该时间（秒）即中止请求，0 表示禁用
+	// 流式周期性用量事件
+	StreamUsageInterval int // 流式响应期间每隔该时间（秒）输出一次累计用量估算事件，0 表示禁用
+	// 单客户端并发流限制
+	MaxStreamsPerClient  int    // 单个客户端同时进行的流式请求数上限，0 表示不限制
+	StreamClientIdentity string // 客户端识别方式：ip（客户端 IP）或 key（代理访问密钥）
+	// 流式断线续传配置
+	EnableStreamResume    bool // 是否为流式请求分配续传令牌（X-CCX-Resume-Token）
+	StreamResumeMaxEvents int  // 每个流保留的最近事件数上限
+	StreamResumeTTL       int  // 流结束后续传令牌的保留时间（秒）
+	// Key 自动重排配置
+	KeyReorderInterval int // Key 自动重排周期（秒），0 表示禁用
+	// 多端点渠道 URL 延迟探测配置
+	LatencySamplerEnabled  bool // 是否在后台探测多 BaseURL 渠道的延迟
+	LatencySamplerInterval int  // 探测周期（秒）
+	// 幂等缓存配置
+	IdempotencyTTL int // Idempotency-Key 响应缓存时间（秒），0 表示禁用
+	// 渠道固定配置
+	EnableChannelPinHeader bool // 是否允许客户端通过 X-CCX-Channel 请求头指定渠道（共享部署建议关闭）
+	// Trace 亲和配置
+	TraceAffinityKindTTLs string // 按 kind 覆盖亲和 TTL，格式 "messages=2h,chat=10m"，未配置的 kind 使用默认 30 分钟
+	// Failover 配置
+	MaxFailoverAttempts    int // 单次请求跨渠道的上游尝试总次数上限，0 表示不限制
+	MaxKeySprayConcurrency int // 渠道 keySprayCount 并行竞速的并发上限，<=1 表示全局禁用竞速
+	// 快速失败配置
+	FastFail bool // 所有渠道均已熔断时直接返回 503，不再逐个尝试（促销渠道存在时不生效）
+	// 模型回退配置
+	FallbackModel string // 请求模型没有任何渠道支持时替换为该模型重试（空表示禁用；Gemini 原生接口的模型位于 URL 路径，不参与回退）
+	// Chat → Claude 转换配置
+	ChatUnsupportedParams string // Claude 上游不支持的参数（n>1、logprobs）处理方式：drop（默认，警告并忽略）或 error（返回 400）
+	ChatEmptyContent      string // content 为 null/空的消息（无 tool_calls）转换为 Claude 时的处理方式：drop（丢弃）或 placeholder（填充占位文本）
+	// 上游请求体压缩配置
+	UpstreamGzipMinBytes int // 开启 compressUpstreamRequests 的渠道，请求体达到该大小（字节）才压缩
+	// 上游响应大小限制（字节，由 MB 配置转换），0 表示不限制
+	MaxResponseBodySize int64 // 非流式响应体最大大小
+	MaxStreamBytes      int64 // 流式响应累计最大字节数
+	// 请求合并配置
+	EnableRequestCoalescing bool // 是否合并并发的相同非流式请求（共享同一次上游调用的响应）
+	// 响应缓存配置
+	ResponseCacheTTL        int // 确定性请求（temperature=0）响应缓存时间（秒），0 表示禁用
+	ResponseCacheMaxEntries int // 响应缓存最大条目数，超出后淘汰最久未使用的条目
+	// 影子渠道重放配置
+	ShadowConcurrency int // 影子重放 worker 数量，0 表示不限制（每次重放单独起 goroutine）
+	ShadowQueueSize   int // 影子重放等待队列长度，队列满时丢弃最旧的重放
+	// 响应头转发配置
+	ForwardResponseHeaders string // 始终转发给客户端的上游响应头白名单（逗号分隔，支持 "前缀*" 通配）
 	// 日志文件相关配置
 	LogDir        string
 	LogFile       string
@@ -63,6 +147,7 @@ func NewEnvConfig() *EnvConfig {
 		QuietPollingLogs:     getEnv("QUIET_POLLING_LOGS", "true") != "false",
 		RawLogOutput:         getEnv("RAW_LOG_OUTPUT", "false") == "true",
 		SSEDebugLevel:        getEnv("SSE_DEBUG_LEVEL", "off"),
+		PreserveSSEEvents:    getEnv("PRESERVE_SSE_EVENTS", "false") == "true",
 		RewriteResponseModel: getEnv("REWRITE_RESPONSE_MODEL", "false") == "true",
 
 		RequestTimeout:     getEnvAsInt("REQUEST_TIMEOUT", 300000),
@@ -70,13 +155,83 @@ func NewEnvConfig() *EnvConfig {
 		EnableCORS:         getEnv("ENABLE_CORS", "false") == "true",
 		CORSOrigin:         getEnv("CORS_ORIGIN", "*"),
 		// 指标配置
-		MetricsWindowSize:       getEnvAsInt("METRICS_WINDOW_SIZE", 10),
-		MetricsFailureThreshold: getEnvAsFloat("METRICS_FAILURE_THRESHOLD", 0.5),
+		MetricsWindowSize:         getEnvAsInt("METRICS_WINDOW_SIZE", 10),
+		MetricsFailureThreshold:   getEnvAsFloat("METRICS_FAILURE_THRESHOLD", 0.5),
+		MetricsCircuitRecovery:    getEnvAsInt("METRICS_CIRCUIT_RECOVERY", 900),
+		MetricsKindOverrides:      loadMetricsKindOverrides(),
+		MetricsTPMIncludeThinking: getEnv("METRICS_TPM_INCLUDE_THINKING", "false") == "true",
+		// TPM 统计口径（默认 output，保持原有行为）
+		MetricsTPMDefinition: getEnv("METRICS_TPM_DEFINITION", "output"),
+		// 自适应熔断阈值（默认关闭，使用固定 METRICS_FAILURE_THRESHOLD）
+		AdaptiveThresholdEnabled: getEnv("ADAPTIVE_THRESHOLD_ENABLED", "false") == "true",
+		AdaptiveThresholdLowRPM:  getEnvAsFloat("ADAPTIVE_THRESHOLD_LOW_RPM", 10),
+		AdaptiveThresholdHighRPM: getEnvAsFloat("ADAPTIVE_THRESHOLD_HIGH_RPM", 120),
+		AdaptiveThresholdLenient: getEnvAsFloat("ADAPTIVE_THRESHOLD_LENIENT", 0.8),
+		AdaptiveThresholdStrict:  getEnvAsFloat("ADAPTIVE_THRESHOLD_STRICT", 0.3),
+		// 渠道健康度聚合策略（默认 pooled，保持原有行为）
+		MetricsHealthAggregation: getEnv("METRICS_HEALTH_AGGREGATION", "pooled"),
+		// 仪表盘成功率最小样本数（默认 5）
+		MetricsDashboardMinSamples: getEnvAsInt("METRICS_DASHBOARD_MIN_SAMPLES", 5),
+		// 过期 Key 指标清理（每小时一次，分块加锁）
+		MetricsCleanupChunkSize: getEnvAsInt("METRICS_CLEANUP_CHUNK_SIZE", 500),
+		MetricsCleanupJitter:    getEnvAsInt("METRICS_CLEANUP_JITTER", 300),
 		// 指标持久化配置
 		MetricsPersistenceEnabled: getEnv("METRICS_PERSISTENCE_ENABLED", "true") != "false",
 		MetricsRetentionDays:      clampInt(getEnvAsInt("METRICS_RETENTION_DAYS", 7), 3, 30),
+		MetricsDBPath:             getEnv("METRICS_DB_PATH", ".config/metrics.db"),
 		// HTTP 客户端配置
 		ResponseHeaderTimeout: clampInt(getEnvAsInt("RESPONSE_HEADER_TIMEOUT", 60), 30, 120), // 30-120 秒
+		StreamIdleTimeout:     getEnvAsInt("STREAM_IDLE_TIMEOUT", 300),
+		// 请求级超时覆盖（默认关闭，上限 10 分钟）
+		EnableTimeoutOverrideHeader: getEnv("ENABLE_TIMEOUT_OVERRIDE_HEADER", "false") == "true",
+		MaxTimeoutOverrideMs:        getEnvAsInt("MAX_TIMEOUT_OVERRIDE_MS", 600000),
+		// 慢客户端背压保护（默认关闭，避免客户端消费过慢时长期占用上游连接）
+		StreamBackpressureTimeout: getEnvAsInt("STREAM_BACKPRESSURE_TIMEOUT", 0),
+		// 流式周期性用量事件（默认关闭，最终真实用量仍在 message_delta 中输出）
+		StreamUsageInterval: getEnvAsInt("STREAM_USAGE_INTERVAL", 0),
+		// 单客户端并发流限制（默认关闭，按客户端 IP 计数）
+		MaxStreamsPerClient:  getEnvAsInt("MAX_STREAMS_PER_CLIENT", 0),
+		StreamClientIdentity: getEnv("STREAM_CLIENT_IDENTITY", "ip"),
+		// 流式断线续传（默认关闭；开启后客户端断开不再中止上游请求）
+		EnableStreamResume:    getEnv("ENABLE_STREAM_RESUME", "false") == "true",
+		StreamResumeMaxEvents: getEnvAsInt("STREAM_RESUME_MAX_EVENTS", 2000),
+		StreamResumeTTL:       getEnvAsInt("STREAM_RESUME_TTL", 120),
+		// Key 自动重排配置（仅对开启 autoReorderKeys 的渠道生效）
+		KeyReorderInterval: getEnvAsInt("KEY_REORDER_INTERVAL", 300),
+		// 多端点渠道 URL 延迟探测（默认关闭，URL 排序仅依赖真实请求结果）
+		LatencySamplerEnabled:  getEnv("LATENCY_SAMPLER_ENABLED", "false") == "true",
+		LatencySamplerInterval: getEnvAsInt("LATENCY_SAMPLER_INTERVAL", 60),
+		// 幂等缓存配置（默认关闭，仅缓存非流式的成功响应）
+		IdempotencyTTL: getEnvAsInt("IDEMPOTENCY_TTL", 0),
+		// 渠道固定配置（默认关闭）
+		EnableChannelPinHeader: getEnv("ENABLE_CHANNEL_PIN_HEADER", "false") == "true",
+		// Trace 亲和配置（默认所有 kind 共用 30 分钟 TTL）
+		TraceAffinityKindTTLs: getEnv("TRACE_AFFINITY_KIND_TTLS", ""),
+		// Failover 配置（默认不限制，保持原有行为）
+		MaxFailoverAttempts:    getEnvAsInt("MAX_FAILOVER_ATTEMPTS", 0),
+		MaxKeySprayConcurrency: getEnvAsInt("MAX_KEY_SPRAY_CONCURRENCY", 3),
+		// 快速失败配置（默认关闭：全部熔断时仍按降级顺序探测上游）
+		FastFail: getEnv("FAST_FAIL", "false") == "true",
+		// 模型回退配置（默认关闭：不支持的模型直接返回错误）
+		FallbackModel: getEnv("FALLBACK_MODEL", ""),
+		// Chat → Claude 转换配置（默认 error：避免静默丢弃参数导致结果不符合预期）
+		ChatUnsupportedParams: getEnv("CHAT_UNSUPPORTED_PARAMS", "drop"),
+		ChatEmptyContent:      getEnv("CHAT_EMPTY_CONTENT", "drop"),
+		// 上游请求体压缩配置（仅对开启 compressUpstreamRequests 的渠道生效）
+		UpstreamGzipMinBytes: getEnvAsInt("UPSTREAM_GZIP_MIN_BYTES", 8192),
+		// 上游响应大小限制（防止异常上游返回超大响应耗尽内存或带宽）
+		MaxResponseBodySize: getEnvAsInt64("MAX_RESPONSE_BODY_SIZE_MB", 100) * 1024 * 1024,
+		MaxStreamBytes:      getEnvAsInt64("MAX_STREAM_BYTES_MB", 1024) * 1024 * 1024,
+		// 请求合并配置（默认关闭：合并后多个客户端会收到完全相同的响应）
+		EnableRequestCoalescing: getEnv("ENABLE_REQUEST_COALESCING", "false") == "true",
+		// 响应缓存配置（默认关闭，仅缓存非流式的确定性请求）
+		ResponseCacheTTL:        getEnvAsInt("RESPONSE_CACHE_TTL", 0),
+		ResponseCacheMaxEntries: getEnvAsInt("RESPONSE_CACHE_MAX_ENTRIES", 500),
+		// 影子渠道重放配置（有界工作池，避免镜像流量挤占主请求资源）
+		ShadowConcurrency: getEnvAsInt("SHADOW_CONCURRENCY", 4),
+		ShadowQueueSize:   getEnvAsInt("SHADOW_QUEUE_SIZE", 100),
+		// 响应头转发配置（限流头部在成功与 failover 最终错误响应中都会转发）
+		ForwardResponseHeaders: getEnv("FORWARD_RESPONSE_HEADERS", "anthropic-ratelimit-*,x-ratelimit-*,retry-after,retry-after-ms,request-id,x-request-id"),
 		// 日志文件配置
 		LogDir:        getEnv("LOG_DIR", "logs"),
 		LogFile:       getEnv("LOG_FILE", "app.log"),
@@ -141,6 +296,46 @@ func (c *EnvConfig) ShouldLog(level string) bool {
 	return requestLevel <= currentLevel
 }
 
+// MetricsSettingsFor 返回指定接口类型的指标配置（按类型覆盖优先，否则使用全局配置）
+func (c *EnvConfig) MetricsSettingsFor(kind string) MetricsKindSettings {
+	settings := MetricsKindSettings{
+		WindowSize:          c.MetricsWindowSize,
+		FailureThreshold:    c.MetricsFailureThreshold,
+		CircuitRecoveryTime: time.Duration(c.MetricsCircuitRecovery) * time.Second,
+	}
+	override, ok := c.MetricsKindOverrides[kind]
+	if !ok {
+		return settings
+	}
+	if override.WindowSize > 0 {
+		settings.WindowSize = override.WindowSize
+	}
+	if override.FailureThreshold > 0 {
+		settings.FailureThreshold = override.FailureThreshold
+	}
+	if override.CircuitRecoveryTime > 0 {
+		settings.CircuitRecoveryTime = override.CircuitRecoveryTime
+	}
+	return settings
+}
+
+// loadMetricsKindOverrides 读取 METRICS_<KIND>_WINDOW_SIZE / _FAILURE_THRESHOLD / _CIRCUIT_RECOVERY
+func loadMetricsKindOverrides() map[string]MetricsKindSettings {
+	overrides := make(map[string]MetricsKindSettings)
+	for _, kind := range metricsKinds {
+		prefix := "METRICS_" + strings.ToUpper(kind) + "_"
+		settings := MetricsKindSettings{
+			WindowSize:          getEnvAsInt(prefix+"WINDOW_SIZE", 0),
+			FailureThreshold:    getEnvAsFloat(prefix+"FAILURE_THRESHOLD", 0),
+			CircuitRecoveryTime: time.Duration(getEnvAsInt(prefix+"CIRCUIT_RECOVERY", 0)) * time.Second,
+		}
+		if settings != (MetricsKindSettings{}) {
+			overrides[kind] = settings
+		}
+	}
+	return overrides
+}
+
 // getEnv 获取环境变量，如果不存在则返回默认值
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadMetricsKindOverrides(t *testing.T) {
+	t.Setenv("METRICS_GEMINI_WINDOW_SIZE", "20")
+	t.Setenv("METRICS_GEMINI_FAILURE_THRESHOLD", "0.7")
+	t.Setenv("METRICS_CHAT_CIRCUIT_RECOVERY", "120")
+
+	cfg := &EnvConfig{
+		MetricsWindowSize:       10,
+		MetricsFailureThreshold: 0.5,
+		MetricsCircuitRecovery:  900,
+		MetricsKindOverrides:    loadMetricsKindOverrides(),
+	}
+	if _, ok := cfg.MetricsKindOverrides["messages"]; ok {
+		t.Fatalf("未配置的 kind 不应出现在覆盖表中")
+	}
+
+	tests := []struct {
+		kind string
+		want MetricsKindSettings
+	}{
+		{kind: "messages", want: MetricsKindSettings{WindowSize: 10, FailureThreshold: 0.5, CircuitRecoveryTime: 15 * time.Minute}},
+		{kind: "gemini", want: MetricsKindSettings{WindowSize: 20, FailureThreshold: 0.7, CircuitRecoveryTime: 15 * time.Minute}},
+		{kind: "chat", want: MetricsKindSettings{WindowSize: 10, FailureThreshold: 0.5, CircuitRecoveryTime: 2 * time.Minute}},
+	}
+	for _, tt := range tests {
+		if got := cfg.MetricsSettingsFor(tt.kind); got != tt.want {
+			t.Errorf("MetricsSettingsFor(%q)=%+v, want %+v", tt.kind, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// modelAccessKinds 支持模型访问控制的接口类型
+var modelAccessKinds = []string{"messages", "responses", "gemini", "chat"}
+
+// ModelAccessList 单个接口类型的模型允许/拒绝列表
+type ModelAccessList struct {
+	AllowedModels []string `json:"allowedModels"`
+	DeniedModels  []string `json:"deniedModels"`
+}
+
+// matchModelPattern 模型名匹配：以 * 结尾的规则按前缀匹配，否则精确匹配
+func matchModelPattern(pattern, model string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(model, prefix)
+	}
+	return pattern == model
+}
+
+// firstMatchingPattern 返回列表中第一个匹配模型的规则
+func firstMatchingPattern(patterns []string, model string) (string, bool) {
+	for _, pattern := range patterns {
+		if matchModelPattern(pattern, model) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// CheckModelAccess 检查指定接口类型是否允许请求该模型
+// 拒绝列表优先；允许列表非空时模型必须命中其中一条规则。不允许时 reason 说明原因
+// 规则同时按原始模型名与归一化后的规范名称匹配，避免别名写法绕过 deniedModels
+func (cm *ConfigManager) CheckModelAccess(kind, model string) (allowed bool, reason string) {
+	names := []string{model}
+	if normalized := cm.NormalizeModel(model); normalized != model {
+		names = append(names, normalized)
+	}
+
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	for _, name := range names {
+		if pattern, ok := firstMatchingPattern(cm.config.DeniedModels[kind], name); ok {
+			return false, fmt.Sprintf("model %q is denied by rule %q", model, pattern)
+		}
+	}
+	if allowList := cm.config.AllowedModels[kind]; len(allowList) > 0 {
+		for _, name := range names {
+			if _, ok := firstMatchingPattern(allowList, name); ok {
+				return true, ""
+			}
+		}
+		return false, fmt.Sprintf("model %q is not in the allowed model list", model)
+	}
+	return true, ""
+}
+
+// GetModelAccess 获取所有接口类型的模型访问控制配置
+func (cm *ConfigManager) GetModelAccess() map[string]ModelAccessList {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	result := make(map[string]ModelAccessList, len(modelAccessKinds))
+	for _, kind := range modelAccessKinds {
+		result[kind] = ModelAccessList{
+			AllowedModels: append([]string{}, cm.config.AllowedModels[kind]...),
+			DeniedModels:  append([]string{}, cm.config.DeniedModels[kind]...),
+		}
+	}
+	return result
+}
+
+// SetModelAccess 设置指定接口类型的模型允许/拒绝列表（空列表表示清除）
+func (cm *ConfigManager) SetModelAccess(kind string, access ModelAccessList) error {
+	if !isModelAccessKind(kind) {
+		return fmt.Errorf("无效的接口类型: %s", kind)
+	}
+	if err := validateModelPatterns("allowedModels", kind, access.AllowedModels); err != nil {
+		return err
+	}
+	if err := validateModelPatterns("deniedModels", kind, access.DeniedModels); err != nil {
+		return err
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.config.AllowedModels = setModelList(cm.config.AllowedModels, kind, access.AllowedModels)
+	cm.config.DeniedModels = setModelList(cm.config.DeniedModels, kind, access.DeniedModels)
+
+	if err := cm.saveConfigLocked(cm.config); err != nil {
+		return err
+	}
+
+	log.Printf("[Config-ModelAccess] %s 模型访问控制已更新: allowed=%v, denied=%v", kind, access.AllowedModels, access.DeniedModels)
+	return nil
+}
+
+// setModelList 写入单个接口类型的列表，空列表时删除条目，map 为空时返回 nil
+func setModelList(lists map[string][]string, kind string, models []string) map[string][]string {
+	if len(models) == 0 {
+		delete(lists, kind)
+		if len(lists) == 0 {
+			return nil
+		}
+		return lists
+	}
+	if lists == nil {
+		lists = make(map[string][]string)
+	}
+	lists[kind] = append([]string{}, models...)
+	return lists
+}
+
+func isModelAccessKind(kind string) bool {
+	for _, k := range modelAccessKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneModelLists 深拷贝模型列表 map
+func cloneModelLists(lists map[string][]string) map[string][]string {
+	if lists == nil {
+		return nil
+	}
+	cloned := make(map[string][]string, len(lists))
+	for kind, models := range lists {
+		cloned[kind] = append([]string{}, models...)
+	}
+	return cloned
+}
+
+// validateModelLists 校验模型访问控制配置：接口类型必须有效，规则不能为空
+func validateModelLists(field string, lists map[string][]string) error {
+	for kind, models := range lists {
+		if !isModelAccessKind(kind) {
+			return &ConfigError{Message: fmt.Sprintf("%s 包含未知的接口类型: %s", field, kind)}
+		}
+		if err := validateModelPatterns(field, kind, models); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateModelPatterns(field, kind string, models []string) error {
+	for _, model := range models {
+		if strings.TrimSpace(model) == "" {
+			return &ConfigError{Message: fmt.Sprintf("%s.%s 包含空的模型规则", field, kind)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import "testing"
+
+func TestCheckModelAccess(t *testing.T) {
+	configFile := writeTestConfigFile(t, Config{
+		Upstream:      []UpstreamConfig{{Name: "a", BaseURL: "https://a.example.com", APIKeys: []string{"sk-1"}, ServiceType: "claude"}},
+		AllowedModels: map[string][]string{"messages": {"claude-*", "glm-4.6"}},
+		DeniedModels:  map[string][]string{"messages": {"claude-opus-*"}, "chat": {"gpt-4*"}},
+		ModelAliases:  map[string]string{"opus-latest": "claude-opus-4-1", "sonnet": "claude-sonnet-4-5"},
+	})
+
+	cm, err := NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("NewConfigManager() err = %v", err)
+	}
+	defer cm.Close()
+
+	tests := []struct {
+		name  string
+		kind  string
+		model string
+		want  bool
+	}{
+		{name: "通配允许", kind: "messages", model: "claude-sonnet-4-5", want: true},
+		{name: "精确允许", kind: "messages", model: "glm-4.6", want: true},
+		{name: "不在允许列表", kind: "messages", model: "gpt-4o", want: false},
+		{name: "拒绝列表优先于允许列表", kind: "messages", model: "claude-opus-4-1", want: false},
+		{name: "通配拒绝", kind: "chat", model: "gpt-4o-mini", want: false},
+		{name: "未命中拒绝且无允许列表", kind: "chat", model: "gpt-5", want: true},
+		{name: "未配置的接口类型", kind: "gemini", model: "gpt-4o", want: true},
+		{name: "别名写法按规范名称拒绝", kind: "messages", model: "opus-latest", want: false},
+		{name: "别名写法按规范名称允许", kind: "messages", model: "sonnet", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, reason := cm.CheckModelAccess(tt.kind, tt.model)
+			if allowed != tt.want {
+				t.Fatalf("CheckModelAccess(%s, %s) = %v (%s), want %v", tt.kind, tt.model, allowed, reason, tt.want)
+			}
+			if !allowed && reason == "" {
+				t.Fatalf("拒绝时应返回原因")
+			}
+		})
+	}
+
+	// 清空允许列表后不再限制
+	if err := cm.SetModelAccess("messages", ModelAccessList{DeniedModels: []string{"claude-opus-*"}}); err != nil {
+		t.Fatalf("SetModelAccess() err = %v", err)
+	}
+	if allowed, _ := cm.CheckModelAccess("messages", "gpt-4o"); !allowed {
+		t.Fatalf("清空允许列表后 gpt-4o 应被允许")
+	}
+	if err := cm.SetModelAccess("embeddings", ModelAccessList{}); err == nil {
+		t.Fatalf("无效接口类型应返回错误")
+	}
+}
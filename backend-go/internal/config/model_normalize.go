@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// modelNormalizer 模型名归一化规则（由全局 modelAliases 配置构建）
+type modelNormalizer struct {
+	exact map[string]string
+	regex []modelNormalizeRule
+}
+
+type modelNormalizeRule struct {
+	re     *regexp.Regexp
+	target string
+}
+
+// newModelNormalizer 根据全局 modelAliases 配置构建归一化规则（配置已通过校验，无效正则直接忽略）
+// 未配置别名时返回 nil
+func newModelNormalizer(aliases map[string]string) *modelNormalizer {
+	if len(aliases) == 0 {
+		return nil
+	}
+
+	n := &modelNormalizer{exact: make(map[string]string, len(aliases))}
+	sources := make([]string, 0, len(aliases))
+	for source, target := range aliases {
+		pattern, ok := strings.CutPrefix(source, ModelMappingRegexPrefix)
+		if !ok {
+			n.exact[source] = target
+			continue
+		}
+		if _, err := compileModelRegex(pattern); err == nil {
+			sources = append(sources, source)
+		}
+	}
+	// 与 modelMapping 正则规则一致：按源长度从长到短（同长按字典序）保证结果稳定
+	sort.Slice(sources, func(i, j int) bool {
+		if len(sources[i]) != len(sources[j]) {
+			return len(sources[i]) > len(sources[j])
+		}
+		return sources[i] < sources[j]
+	})
+	for _, source := range sources {
+		re, _ := compileModelRegex(strings.TrimPrefix(source, ModelMappingRegexPrefix))
+		n.regex = append(n.regex, modelNormalizeRule{re: re, target: aliases[source]})
+	}
+	return n
+}
+
+// normalize 按归一化规则改写模型名，未配置或未命中时原样返回
+func (n *modelNormalizer) normalize(model string) string {
+	if n == nil || model == "" {
+		return model
+	}
+	if canonical, ok := n.exact[model]; ok {
+		return canonical
+	}
+	for _, rule := range n.regex {
+		submatches := rule.re.FindStringSubmatchIndex(model)
+		if submatches == nil {
+			continue
+		}
+		return string(rule.re.ExpandString(nil, rule.target, model, submatches))
+	}
+	return model
+}
+
+// NormalizeModel 将客户端发送的模型名变体归一化为规范名称（如 claude-3.5-sonnet、
+// claude-3-5-sonnet-20241022 → claude-3-5-sonnet），未配置或未命中时原样返回
+func (cm *ConfigManager) NormalizeModel(model string) string {
+	return cm.modelNormalizer.Load().normalize(model)
+}
+
+// validateModelAliases 校验模型别名配置：别名与规范名称不能为空，正则规则必须可编译
+func validateModelAliases(aliases map[string]string) error {
+	for source, target := range aliases {
+		if strings.TrimSpace(source) == "" || strings.TrimSpace(target) == "" {
+			return fmt.Errorf("modelAliases 别名与规范名称不能为空: %q -> %q", source, target)
+		}
+		pattern, ok := strings.CutPrefix(source, ModelMappingRegexPrefix)
+		if !ok {
+			continue
+		}
+		if _, err := compileModelRegex(pattern); err != nil {
+			return fmt.Errorf("modelAliases 正则 %q 无效: %w", source, err)
+		}
+	}
+	return nil
+}
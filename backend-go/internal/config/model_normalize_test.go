@@ -0,0 +1,139 @@
+package config
+
+import (
+	"testing"
+)
+
+// newAliasConfigManager 创建配置了全局模型别名的配置管理器
+func newAliasConfigManager(t *testing.T, aliases map[string]string) *ConfigManager {
+	t.Helper()
+	cm, err := NewConfigManager(writeTestConfigFile(t, Config{ModelAliases: aliases}))
+	if err != nil {
+		t.Fatalf("NewConfigManager() err = %v", err)
+	}
+	t.Cleanup(func() { cm.Close() })
+	return cm
+}
+
+func TestNormalizeModel(t *testing.T) {
+	cm := newAliasConfigManager(t, map[string]string{
+		"claude-3.5-sonnet":            "claude-3-5-sonnet",
+		`re:(claude-3-5-sonnet)-\d{8}`: "$1",
+		`re:claude-(\w+)-(\d)-(\d)-.*`: "claude-$2-$3-$1",
+	})
+
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"claude-3.5-sonnet", "claude-3-5-sonnet"},
+		{"claude-3-5-sonnet", "claude-3-5-sonnet"},
+		{"claude-3-5-sonnet-20241022", "claude-3-5-sonnet"},
+		{"claude-haiku-3-5-latest", "claude-3-5-haiku"},
+		{"gpt-4o", "gpt-4o"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			if got := cm.NormalizeModel(tt.model); got != tt.want {
+				t.Errorf("NormalizeModel(%q) = %q, want %q", tt.model, got, tt.want)
+			}
+		})
+	}
+
+	if got := newAliasConfigManager(t, nil).NormalizeModel("claude-3.5-sonnet"); got != "claude-3.5-sonnet" {
+		t.Errorf("未配置别名时 NormalizeModel() = %q, want 原样返回", got)
+	}
+
+	// 配置写入失败时不切换归一化规则
+	cm.mu.Lock()
+	failing := cm.config
+	failing.ModelAliases = map[string]string{"claude-3.5-sonnet": "other"}
+	configFile := cm.configFile
+	cm.configFile = t.TempDir() // 写入目录必然失败
+	err := cm.saveConfigLocked(failing)
+	cm.configFile = configFile
+	cm.mu.Unlock()
+	if err == nil {
+		t.Fatal("写入目录应失败")
+	}
+	if got := cm.NormalizeModel("claude-3.5-sonnet"); got != "claude-3-5-sonnet" {
+		t.Errorf("写入失败后 NormalizeModel() = %q, want 保持原规则 claude-3-5-sonnet", got)
+	}
+}
+
+// TestRedirectModelWithMatch_Normalized 映射按归一化后的模型名匹配，未命中映射时保留客户端原始模型名
+func TestRedirectModelWithMatch_Normalized(t *testing.T) {
+	cm := newAliasConfigManager(t, map[string]string{
+		"claude-3.5-sonnet":            "claude-3-5-sonnet",
+		`re:(claude-3-5-sonnet)-\d{8}`: "$1",
+	})
+
+	upstream := &UpstreamConfig{ModelMapping: map[string]string{
+		"claude-3-5-sonnet":          "sonnet-upstream",
+		"claude-3-5-sonnet-20241022": "sonnet-pinned",
+	}}
+
+	tests := []struct {
+		model     string
+		want      string
+		wantMatch string
+	}{
+		{"claude-3.5-sonnet", "sonnet-upstream", ModelMatchExact},
+		{"claude-3-5-sonnet-20240620", "sonnet-upstream", ModelMatchExact},
+		// 原始模型名精确命中时优先于归一化
+		{"claude-3-5-sonnet-20241022", "sonnet-pinned", ModelMatchExact},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got, match := RedirectModelWithMatch(tt.model, upstream, cm.NormalizeModel)
+			if got != tt.want || match.Type != tt.wantMatch {
+				t.Errorf("RedirectModelWithMatch(%q) = %q (%s), want %q (%s)", tt.model, got, match.Type, tt.want, tt.wantMatch)
+			}
+		})
+	}
+
+	// RedirectModel 不做归一化
+	if got := RedirectModel("claude-3.5-sonnet", upstream); got != "claude-3.5-sonnet" {
+		t.Errorf("RedirectModel() = %q, want 原始模型名 claude-3.5-sonnet", got)
+	}
+
+	// 按归一化名称命中的映射固定到渠道副本上，原渠道配置不变
+	pinned := PinNormalizedModelMapping(upstream, "claude-3.5-sonnet", cm.NormalizeModel)
+	if pinned == upstream || RedirectModel("claude-3.5-sonnet", pinned) != "sonnet-upstream" {
+		t.Errorf("PinNormalizedModelMapping() 应返回固定映射的副本, got %v", pinned.ModelMapping)
+	}
+	if _, exists := upstream.ModelMapping["claude-3.5-sonnet"]; exists {
+		t.Error("PinNormalizedModelMapping() 不应修改原渠道配置")
+	}
+
+	// 未命中映射时不改写上游模型名
+	other := &UpstreamConfig{ModelMapping: map[string]string{"gpt-4o": "gpt-4o-mini"}}
+	if got := PinNormalizedModelMapping(other, "claude-3.5-sonnet", cm.NormalizeModel); got != other {
+		t.Error("未命中映射时应返回原渠道配置")
+	}
+	if got, _ := RedirectModelWithMatch("claude-3.5-sonnet", other, cm.NormalizeModel); got != "claude-3.5-sonnet" {
+		t.Errorf("RedirectModelWithMatch() = %q, want 原始模型名 claude-3.5-sonnet", got)
+	}
+}
+
+func TestValidateModelAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		aliases map[string]string
+		wantErr bool
+	}{
+		{"未配置", nil, false},
+		{"精确与正则", map[string]string{"a": "b", "re:c-(.*)": "c"}, false},
+		{"空规范名称", map[string]string{"a": " "}, true},
+		{"无效正则", map[string]string{"re:(": "a"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(&Config{ModelAliases: tt.aliases})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -139,6 +139,8 @@ func ConvertOpenAIChatToResponses(ctx context.Context, modelName string, origina
 	// 解析 choices
 	choices := root.Get("choices")
 	if !choices.Exists() || !choices.IsArray() {
+		// 部分上游在流末尾单独发送仅含 usage 的 chunk（不带 choices 字段）
+		st.recordUsage(root.Get("usage"))
 		return out
 	}
 
@@ -326,77 +328,82 @@ func ConvertOpenAIChatToResponses(ctx context.Context, modelName string, origina
 		}
 	}
 
-	// 处理 usage（完整支持多格式详细字段，参考 claude-code-hub）
-	if usage := root.Get("usage"); usage.Exists() {
-		st.UsageSeen = true
+	st.recordUsage(root.Get("usage"))
 
-		// OpenAI 格式基础字段
-		if v := usage.Get("prompt_tokens"); v.Exists() {
-			st.InputTokens = v.Int()
-		}
-		if v := usage.Get("completion_tokens"); v.Exists() {
-			st.OutputTokens = v.Int()
-		}
+	return out
+}
 
-		// OpenAI 格式详细字段
-		if v := usage.Get("prompt_tokens_details.cached_tokens"); v.Exists() {
-			st.CachedTokens = v.Int()
-		}
-		if v := usage.Get("completion_tokens_details.reasoning_tokens"); v.Exists() {
-			st.ReasoningTokens = v.Int()
-		}
+// recordUsage 记录 chunk 中的 usage（完整支持多格式详细字段，参考 claude-code-hub）
+func (st *chatToResponsesState) recordUsage(usage gjson.Result) {
+	if !usage.Exists() || usage.Type == gjson.Null {
+		return
+	}
+	st.UsageSeen = true
 
-		// Claude 格式基础字段（优先级高于 OpenAI）
-		if v := usage.Get("input_tokens"); v.Exists() {
-			st.InputTokens = v.Int()
-		}
-		if v := usage.Get("output_tokens"); v.Exists() {
-			st.OutputTokens = v.Int()
-		}
+	// OpenAI 格式基础字段
+	if v := usage.Get("prompt_tokens"); v.Exists() {
+		st.InputTokens = v.Int()
+	}
+	if v := usage.Get("completion_tokens"); v.Exists() {
+		st.OutputTokens = v.Int()
+	}
 
-		// Claude 格式缓存字段
-		if v := usage.Get("cache_read_input_tokens"); v.Exists() {
-			st.CachedTokens = v.Int()
-		}
-		if v := usage.Get("cache_creation_input_tokens"); v.Exists() {
-			st.CacheCreationTokens = v.Int()
-		}
-		if v := usage.Get("cache_creation_5m_input_tokens"); v.Exists() {
-			st.CacheCreation5mTokens = v.Int()
-		}
-		if v := usage.Get("cache_creation_1h_input_tokens"); v.Exists() {
-			st.CacheCreation1hTokens = v.Int()
-		}
+	// OpenAI 格式详细字段
+	if v := usage.Get("prompt_tokens_details.cached_tokens"); v.Exists() {
+		st.CachedTokens = v.Int()
+	}
+	if v := usage.Get("completion_tokens_details.reasoning_tokens"); v.Exists() {
+		st.ReasoningTokens = v.Int()
+	}
 
-		// 设置缓存 TTL 标识
-		has5m := st.CacheCreation5mTokens > 0
-		has1h := st.CacheCreation1hTokens > 0
-		if has5m && has1h {
-			st.CacheTTL = "mixed"
-		} else if has1h {
-			st.CacheTTL = "1h"
-		} else if has5m {
-			st.CacheTTL = "5m"
-		}
+	// Claude 格式基础字段（优先级高于 OpenAI）
+	if v := usage.Get("input_tokens"); v.Exists() {
+		st.InputTokens = v.Int()
+	}
+	if v := usage.Get("output_tokens"); v.Exists() {
+		st.OutputTokens = v.Int()
+	}
 
-		// Gemini 格式（自动去重）
-		if v := usage.Get("promptTokenCount"); v.Exists() {
-			promptTokens := v.Int()
-			cachedTokens := usage.Get("cachedContentTokenCount").Int()
-			// Gemini 的 promptTokenCount 已包含 cachedContentTokenCount，需要扣除
-			actualInput := promptTokens - cachedTokens
-			if actualInput < 0 {
-				actualInput = 0
-			}
-			st.InputTokens = actualInput
-			st.CachedTokens = cachedTokens
-		}
-		if v := usage.Get("candidatesTokenCount"); v.Exists() {
-			st.OutputTokens = v.Int()
-		}
+	// Claude 格式缓存字段
+	if v := usage.Get("cache_read_input_tokens"); v.Exists() {
+		st.CachedTokens = v.Int()
+	}
+	if v := usage.Get("cache_creation_input_tokens"); v.Exists() {
+		st.CacheCreationTokens = v.Int()
+	}
+	if v := usage.Get("cache_creation_5m_input_tokens"); v.Exists() {
+		st.CacheCreation5mTokens = v.Int()
+	}
+	if v := usage.Get("cache_creation_1h_input_tokens"); v.Exists() {
+		st.CacheCreation1hTokens = v.Int()
 	}
 
-	return out
+	// 设置缓存 TTL 标识
+	has5m := st.CacheCreation5mTokens > 0
+	has1h := st.CacheCreation1hTokens > 0
+	if has5m && has1h {
+		st.CacheTTL = "mixed"
+	} else if has1h {
+		st.CacheTTL = "1h"
+	} else if has5m {
+		st.CacheTTL = "5m"
+	}
+
+	// Gemini 格式（自动去重）
+	if v := usage.Get("promptTokenCount"); v.Exists() {
+		promptTokens := v.Int()
+		cachedTokens := usage.Get("cachedContentTokenCount").Int()
+		// Gemini 的 promptTokenCount 已包含 cachedContentTokenCount，需要扣除
+		actualInput := promptTokens - cachedTokens
+		if actualInput < 0 {
+			actualInput = 0
+		}
+		st.InputTokens = actualInput
+		st.CachedTokens = cachedTokens
+	}
+	if v := usage.Get("candidatesTokenCount"); v.Exists() {
+		st.OutputTokens = v.Int()
+	}
 }
 
 // closeReasoningBlock 关闭 reasoning block
@@ -4,6 +4,7 @@
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/BenedictKing/ccx/internal/types"
 )
@@ -154,6 +155,10 @@ func GeminiToOpenAIRequest(geminiReq *types.GeminiRequest, model string) (map[st
 		}
 		if len(openaiTools) > 0 {
 			openaiReq["tools"] = openaiTools
+			// toolConfig → tool_choice（OpenAI 要求 tool_choice 与 tools 同时出现）
+			if toolChoice := geminiToolConfigToOpenAI(geminiReq.ToolConfig); toolChoice != nil {
+				openaiReq["tool_choice"] = toolChoice
+			}
 		}
 	}
 
@@ -334,6 +339,60 @@ function, ok := toolCall["function"].(map[string]interface{})
 	return geminiResp, nil
 }
 
+// GeminiResponseToOpenAIChat 将 Gemini 原生响应转换为 OpenAI Chat Completions 格式
+// functionCall → tool_calls（arguments 为 JSON 字符串），functionResponse → tool 角色消息
+func GeminiResponseToOpenAIChat(geminiResp *types.GeminiResponse, model string) map[string]interface{} {
+	choices := []map[string]interface{}{}
+	for i, candidate := range geminiResp.Candidates {
+		var message map[string]interface{}
+		if candidate.Content != nil {
+			// 响应中的候选角色可能为空，按模型输出处理；思考内容不计入 content
+			content := &types.GeminiContent{Role: "model", Parts: make([]types.GeminiPart, 0, len(candidate.Content.Parts))}
+			for _, part := range candidate.Content.Parts {
+				if !part.Thought {
+					content.Parts = append(content.Parts, part)
+				}
+			}
+			message, _ = geminiContentToOpenAIMessage(content)
+		}
+		if message == nil {
+			message = map[string]interface{}{"role": "assistant", "content": ""}
+		}
+
+		finishReason := geminiFinishReasonToOpenAI(candidate.FinishReason)
+		if toolCalls, ok := message["tool_calls"].([]map[string]interface{}); ok && len(toolCalls) > 0 {
+			for idx, toolCall := range toolCalls {
+				toolCall["index"] = idx
+			}
+			finishReason = "tool_calls"
+		}
+
+		choices = append(choices, map[string]interface{}{
+			"index":         i,
+			"message":       message,
+			"finish_reason": finishReason,
+		})
+	}
+
+	result := map[string]interface{}{
+		"id":      fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": choices,
+	}
+
+	if usage := geminiResp.UsageMetadata; usage != nil {
+		result["usage"] = map[string]interface{}{
+			"prompt_tokens":     usage.PromptTokenCount,
+			"completion_tokens": usage.CandidatesTokenCount,
+			"total_tokens":      usage.TotalTokenCount,
+		}
+	}
+
+	return result
+}
+
 // ============== 辅助函数 ==============
 
 // geminiContentToClaudeMessage 将 Gemini Content 转换为 Claude Message
@@ -3,6 +3,7 @@
 import (
 	"testing"
 
+	"github.com/BenedictKing/ccx/internal/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -141,3 +142,21 @@ func TestOpenAIResponseToGemini_WithThoughtSignature(t *testing.T) {
 		}
 	})
 }
+
+// TestGeminiRequest_PreservesStopSequences 测试 Gemini 请求转换时 stopSequences 映射到目标协议
+func TestGeminiRequest_PreservesStopSequences(t *testing.T) {
+	geminiReq := &types.GeminiRequest{
+		Contents: []types.GeminiContent{
+			{Role: "user", Parts: []types.GeminiPart{{Text: "hi"}}},
+		},
+		GenerationConfig: &types.GeminiGenerationConfig{StopSequences: []string{"END", "\n\n"}},
+	}
+
+	claudeReq, err := GeminiToClaudeRequest(geminiReq, "claude-test")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"END", "\n\n"}, claudeReq["stop_sequences"])
+
+	openaiReq, err := GeminiToOpenAIRequest(geminiReq, "gpt-test")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"END", "\n\n"}, openaiReq["stop"])
+}
@@ -0,0 +1,97 @@
+package converters
+
+import (
+	"strings"
+
+	"github.com/BenedictKing/ccx/internal/types"
+)
+
+// Gemini functionCallingConfig.mode 取值
+const (
+	geminiFunctionCallingAuto = "AUTO"
+	geminiFunctionCallingAny  = "ANY"
+	geminiFunctionCallingNone = "NONE"
+)
+
+// openAIToolChoiceToGemini 将 OpenAI tool_choice 转换为 Gemini toolConfig
+// 支持 Chat 与 Responses 两种格式：
+//   - "auto" / "none" / "required" → AUTO / NONE / ANY
+//   - {"type":"function","function":{"name":...}}（Chat）或 {"type":"function","name":...}（Responses）→ ANY + allowedFunctionNames
+//   - {"type":"allowed_tools","mode":...,"tools":[...]}（Responses）→ AUTO/ANY + allowedFunctionNames
+//
+// 无法表达的取值（如内置工具）返回 nil，由上游按默认 AUTO 处理
+func openAIToolChoiceToGemini(toolChoice interface{}) *types.GeminiToolConfig {
+	switch choice := toolChoice.(type) {
+	case string:
+		switch choice {
+		case "auto":
+			return newGeminiToolConfig(geminiFunctionCallingAuto)
+		case "none":
+			return newGeminiToolConfig(geminiFunctionCallingNone)
+		case "required":
+			return newGeminiToolConfig(geminiFunctionCallingAny)
+		}
+	case map[string]interface{}:
+		choiceType, _ := choice["type"].(string)
+		switch choiceType {
+		case "function":
+			name, _ := choice["name"].(string)
+			if function, ok := choice["function"].(map[string]interface{}); ok && name == "" {
+				name, _ = function["name"].(string)
+			}
+			if name == "" {
+				return nil
+			}
+			return newGeminiToolConfig(geminiFunctionCallingAny, name)
+		case "allowed_tools":
+			mode := geminiFunctionCallingAuto
+			if m, _ := choice["mode"].(string); m == "required" {
+				mode = geminiFunctionCallingAny
+			}
+			tools, _ := choice["tools"].([]interface{})
+			var names []string
+			for _, tool := range tools {
+				if toolMap, ok := tool.(map[string]interface{}); ok {
+					if name, _, _ := extractResponsesToolFields(toolMap); name != "" {
+						names = append(names, name)
+					}
+				}
+			}
+			return newGeminiToolConfig(mode, names...)
+		}
+	}
+	return nil
+}
+
+// newGeminiToolConfig 构建 toolConfig（allowedFunctionNames 仅在 ANY 模式下有意义）
+func newGeminiToolConfig(mode string, allowedFunctionNames ...string) *types.GeminiToolConfig {
+	cfg := &types.GeminiFunctionCallingConfig{Mode: mode}
+	if mode == geminiFunctionCallingAny && len(allowedFunctionNames) > 0 {
+		cfg.AllowedFunctionNames = allowedFunctionNames
+	}
+	return &types.GeminiToolConfig{FunctionCallingConfig: cfg}
+}
+
+// geminiToolConfigToOpenAI 将 Gemini toolConfig 转换为 OpenAI Chat tool_choice
+// ANY 且仅允许一个函数时强制调用该函数，允许多个或未限制时转换为 "required"；未设置或无法识别的模式返回 nil
+func geminiToolConfigToOpenAI(toolConfig *types.GeminiToolConfig) interface{} {
+	if toolConfig == nil || toolConfig.FunctionCallingConfig == nil {
+		return nil
+	}
+	cfg := toolConfig.FunctionCallingConfig
+	switch strings.ToUpper(cfg.Mode) {
+	case geminiFunctionCallingAuto:
+		return "auto"
+	case geminiFunctionCallingNone:
+		return "none"
+	case geminiFunctionCallingAny:
+		if len(cfg.AllowedFunctionNames) == 1 {
+			return map[string]interface{}{
+				"type":     "function",
+				"function": map[string]interface{}{"name": cfg.AllowedFunctionNames[0]},
+			}
+		}
+		return "required"
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package converters
+
+import (
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/session"
+	"github.com/BenedictKing/ccx/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestResponsesToGeminiRequest_ToolChoice tool_choice 转换为 Gemini toolConfig.functionCallingConfig
+func TestResponsesToGeminiRequest_ToolChoice(t *testing.T) {
+	tools := []map[string]interface{}{
+		{"type": "function", "name": "get_weather", "parameters": map[string]interface{}{"type": "object"}},
+		{"type": "function", "name": "get_time", "parameters": map[string]interface{}{"type": "object"}},
+	}
+
+	tests := []struct {
+		name       string
+		toolChoice interface{}
+		want       *types.GeminiFunctionCallingConfig
+	}{
+		{name: "未指定", toolChoice: nil, want: nil},
+		{name: "auto", toolChoice: "auto", want: &types.GeminiFunctionCallingConfig{Mode: "AUTO"}},
+		{name: "none", toolChoice: "none", want: &types.GeminiFunctionCallingConfig{Mode: "NONE"}},
+		{name: "required", toolChoice: "required", want: &types.GeminiFunctionCallingConfig{Mode: "ANY"}},
+		{
+			name:       "强制指定函数（Responses 格式）",
+			toolChoice: map[string]interface{}{"type": "function", "name": "get_weather"},
+			want:       &types.GeminiFunctionCallingConfig{Mode: "ANY", AllowedFunctionNames: []string{"get_weather"}},
+		},
+		{
+			name:       "强制指定函数（Chat 格式）",
+			toolChoice: map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "get_time"}},
+			want:       &types.GeminiFunctionCallingConfig{Mode: "ANY", AllowedFunctionNames: []string{"get_time"}},
+		},
+		{
+			name: "allowed_tools required",
+			toolChoice: map[string]interface{}{
+				"type": "allowed_tools",
+				"mode": "required",
+				"tools": []interface{}{
+					map[string]interface{}{"type": "function", "name": "get_weather"},
+					map[string]interface{}{"type": "function", "name": "get_time"},
+				},
+			},
+			want: &types.GeminiFunctionCallingConfig{Mode: "ANY", AllowedFunctionNames: []string{"get_weather", "get_time"}},
+		},
+		{name: "内置工具无法表达", toolChoice: map[string]interface{}{"type": "file_search"}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &types.ResponsesRequest{Input: "hi", Tools: tools, ToolChoice: tt.toolChoice}
+			geminiReq, err := ResponsesToGeminiRequest(&session.Session{ID: "sess_test"}, req, "gemini-2.5-pro")
+			assert.NoError(t, err)
+			if tt.want == nil {
+				assert.Nil(t, geminiReq.ToolConfig)
+				return
+			}
+			if assert.NotNil(t, geminiReq.ToolConfig) {
+				assert.Equal(t, tt.want, geminiReq.ToolConfig.FunctionCallingConfig)
+			}
+		})
+	}
+
+	// 没有可转换的函数工具时不输出 toolConfig
+	req := &types.ResponsesRequest{Input: "hi", ToolChoice: "required"}
+	geminiReq, err := ResponsesToGeminiRequest(&session.Session{ID: "sess_test"}, req, "gemini-2.5-pro")
+	assert.NoError(t, err)
+	assert.Nil(t, geminiReq.ToolConfig)
+}
+
+// TestGeminiToOpenAIRequest_ToolConfig Gemini toolConfig 转换为 OpenAI tool_choice
+func TestGeminiToOpenAIRequest_ToolConfig(t *testing.T) {
+	tools := []types.GeminiTool{{FunctionDeclarations: []types.GeminiFunctionDeclaration{
+		{Name: "get_weather"},
+		{Name: "get_time"},
+	}}}
+
+	tests := []struct {
+		name   string
+		config *types.GeminiFunctionCallingConfig
+		want   interface{}
+	}{
+		{name: "未指定", config: nil, want: nil},
+		{name: "AUTO", config: &types.GeminiFunctionCallingConfig{Mode: "AUTO"}, want: "auto"},
+		{name: "NONE", config: &types.GeminiFunctionCallingConfig{Mode: "NONE"}, want: "none"},
+		{name: "ANY 不限制函数", config: &types.GeminiFunctionCallingConfig{Mode: "ANY"}, want: "required"},
+		{
+			name:   "ANY 强制单个函数",
+			config: &types.GeminiFunctionCallingConfig{Mode: "ANY", AllowedFunctionNames: []string{"get_weather"}},
+			want: map[string]interface{}{
+				"type":     "function",
+				"function": map[string]interface{}{"name": "get_weather"},
+			},
+		},
+		{
+			name:   "ANY 允许多个函数",
+			config: &types.GeminiFunctionCallingConfig{Mode: "ANY", AllowedFunctionNames: []string{"get_weather", "get_time"}},
+			want:   "required",
+		},
+		{name: "MODE_UNSPECIFIED", config: &types.GeminiFunctionCallingConfig{Mode: "MODE_UNSPECIFIED"}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			geminiReq := &types.GeminiRequest{
+				Contents: []types.GeminiContent{{Role: "user", Parts: []types.GeminiPart{{Text: "hi"}}}},
+				Tools:    tools,
+			}
+			if tt.config != nil {
+				geminiReq.ToolConfig = &types.GeminiToolConfig{FunctionCallingConfig: tt.config}
+			}
+
+			openaiReq, err := GeminiToOpenAIRequest(geminiReq, "gpt-4o")
+			assert.NoError(t, err)
+			toolChoice, exists := openaiReq["tool_choice"]
+			if tt.want == nil {
+				assert.False(t, exists, "不应输出 tool_choice: %v", toolChoice)
+				return
+			}
+			assert.Equal(t, tt.want, toolChoice)
+		})
+	}
+}
@@ -63,6 +63,10 @@ func ResponsesToGeminiRequest(sess *session.Session, req *types.ResponsesRequest
 	// 5. 转换 tools
 	if len(req.Tools) > 0 {
 		geminiReq.Tools = responsesToolsToGemini(req.Tools)
+		// tool_choice → toolConfig（强制调用指定函数时为 ANY + allowedFunctionNames）
+		if geminiReq.Tools != nil {
+			geminiReq.ToolConfig = openAIToolChoiceToGemini(req.ToolChoice)
+		}
 	}
 
 	return geminiReq, nil
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/session"
+	"github.com/BenedictKing/ccx/internal/warmup"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetMultiChannelHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Config{
+		Upstream: []config.UpstreamConfig{
+			{Name: "a", BaseURL: "https://a.example.com", APIKeys: []string{"sk-a"}},
+			{Name: "b", BaseURL: "https://b.example.com", APIKeys: []string{"sk-b"}},
+			{Name: "c", BaseURL: "https://c.example.com", APIKeys: []string{"sk-c"}},
+			{Name: "d", BaseURL: "https://d.example.com", APIKeys: []string{"sk-d"}},
+		},
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		t.Fatalf("序列化配置失败: %v", err)
+	}
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	cfgManager, err := config.NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("创建配置管理器失败: %v", err)
+	}
+	t.Cleanup(func() { cfgManager.Close() })
+
+	messagesMetrics := metrics.NewMetricsManager()
+	responsesMetrics := metrics.NewMetricsManager()
+	geminiMetrics := metrics.NewMetricsManager()
+	chatMetrics := metrics.NewMetricsManager()
+	t.Cleanup(func() {
+		messagesMetrics.Stop()
+		responsesMetrics.Stop()
+		geminiMetrics.Stop()
+		chatMetrics.Stop()
+	})
+	sch := scheduler.NewChannelScheduler(cfgManager, messagesMetrics, responsesMetrics, geminiMetrics, chatMetrics, session.NewTraceAffinityManager(), warmup.NewURLManager(30*time.Second, 3))
+
+	messagesMetrics.RecordSuccess("https://a.example.com", "sk-a")
+	messagesMetrics.RecordSuccess("https://c.example.com", "sk-c")
+	messagesMetrics.RecordFailure("https://c.example.com", "sk-c")
+
+	r := gin.New()
+	r.GET("/channels/metrics/history/batch", GetMultiChannelHistory(sch))
+
+	type response struct {
+		Kind     string                   `json:"kind"`
+		Interval string                   `json:"interval"`
+		Series   []MetricsHistoryResponse `json:"series"`
+	}
+	get := func(t *testing.T, query string) (int, response) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/channels/metrics/history/batch"+query, nil))
+		var resp response
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("解析响应失败: %v", err)
+			}
+		}
+		return w.Code, resp
+	}
+
+	t.Run("指定三个渠道且时间戳对齐", func(t *testing.T) {
+		code, resp := get(t, "?kind=messages&duration=1h&channels=0,2,3")
+		if code != http.StatusOK {
+			t.Fatalf("status=%d", code)
+		}
+		if resp.Interval != time.Minute.String() {
+			t.Fatalf("interval=%q, want 1m0s", resp.Interval)
+		}
+		if len(resp.Series) != 3 {
+			t.Fatalf("series=%d, want 3", len(resp.Series))
+		}
+		wantIndexes := []int{0, 2, 3}
+		base := resp.Series[0].DataPoints
+		for i, series := range resp.Series {
+			if series.ChannelIndex != wantIndexes[i] {
+				t.Fatalf("series[%d].channelIndex=%d, want %d", i, series.ChannelIndex, wantIndexes[i])
+			}
+			if len(series.DataPoints) != len(base) || len(base) == 0 {
+				t.Fatalf("series[%d] 数据点数量 %d 与首个序列 %d 不一致", i, len(series.DataPoints), len(base))
+			}
+			for j := range base {
+				if !series.DataPoints[j].Timestamp.Equal(base[j].Timestamp) {
+					t.Fatalf("series[%d] 第 %d 个时间戳 %v 未与首个序列 %v 对齐", i, j, series.DataPoints[j].Timestamp, base[j].Timestamp)
+				}
+			}
+		}
+
+		var totalC int64
+		for _, p := range resp.Series[1].DataPoints {
+			totalC += p.RequestCount
+		}
+		if totalC != 2 {
+			t.Fatalf("渠道 c 请求数=%d, want 2", totalC)
+		}
+	})
+
+	t.Run("未指定渠道时返回全部", func(t *testing.T) {
+		code, resp := get(t, "")
+		if code != http.StatusOK {
+			t.Fatalf("status=%d", code)
+		}
+		if resp.Kind != "messages" || len(resp.Series) != 4 {
+			t.Fatalf("kind=%q series=%d, want messages/4", resp.Kind, len(resp.Series))
+		}
+	})
+
+	t.Run("bucket 数超过上限时自动放宽 interval", func(t *testing.T) {
+		code, resp := get(t, "?kind=messages&duration=24h&interval=1m&channels=0")
+		if code != http.StatusOK {
+			t.Fatalf("status=%d", code)
+		}
+		if resp.Interval != (3 * time.Minute).String() {
+			t.Fatalf("interval=%q, want 3m0s", resp.Interval)
+		}
+		if n := len(resp.Series[0].DataPoints); n > maxHistoryBuckets {
+			t.Fatalf("数据点数量 %d 超过上限 %d", n, maxHistoryBuckets)
+		}
+	})
+
+	t.Run("无效参数", func(t *testing.T) {
+		for _, query := range []string{"?kind=unknown", "?channels=0,9", "?channels=x", "?interval=abc", "?duration=abc"} {
+			if code, _ := get(t, query); code != http.StatusBadRequest {
+				t.Fatalf("%s status=%d, want 400", query, code)
+			}
+		}
+	})
+}
+
+func TestResolveHistoryInterval_CapsBuckets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		query    string
+		duration time.Duration
+		want     time.Duration
+	}{
+		{name: "未超过上限保持不变", query: "?interval=1m", duration: 6 * time.Hour, want: time.Minute},
+		{name: "恰好达到上限", query: "?interval=1m", duration: 500 * time.Minute, want: time.Minute},
+		{name: "24h 按 1m 聚合放宽到 3m", query: "?interval=1m", duration: 24 * time.Hour, want: 3 * time.Minute},
+		{name: "小于 1m 先钳制再放宽", query: "?interval=1s", duration: 24 * time.Hour, want: 3 * time.Minute},
+		{name: "自动选择", query: "", duration: 24 * time.Hour, want: 15 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/history"+tt.query, nil)
+
+			got, ok := resolveHistoryInterval(c, tt.duration)
+			if !ok {
+				t.Fatalf("resolveHistoryInterval 返回失败")
+			}
+			if got != tt.want {
+				t.Fatalf("interval=%v, want %v", got, tt.want)
+			}
+			if h := w.Header().Get(historyIntervalHeader); h != tt.want.String() {
+				t.Fatalf("%s=%q, want %q", historyIntervalHeader, h, tt.want.String())
+			}
+			if tt.duration/got > maxHistoryBuckets {
+				t.Fatalf("bucket 数 %d 超过上限", tt.duration/got)
+			}
+		})
+	}
+
+	if got := selectIntervalForDuration("1m", 24*time.Hour); got != 3*time.Minute {
+		t.Fatalf("selectIntervalForDuration=%v, want 3m", got)
+	}
+}
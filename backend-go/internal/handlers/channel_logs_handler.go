@@ -28,3 +28,31 @@ func GetChannelLogs(channelLogStore *metrics.ChannelLogStore) gin.HandlerFunc {
 		})
 	}
 }
+
+// GetChannelKeyErrors 获取渠道内单个 Key 最近的失败样本（状态码、截断的错误响应、时间）
+// GET /api/{kind}/channels/:id/keys/errors?key=<脱敏后的 Key>
+func GetChannelKeyErrors(channelLogStore *metrics.ChannelLogStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		channelIndex, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid channel ID"})
+			return
+		}
+		keyMask := c.Query("key")
+		if keyMask == "" {
+			c.JSON(400, gin.H{"error": "key is required"})
+			return
+		}
+
+		samples := channelLogStore.GetKeyRecentErrors(channelIndex, keyMask)
+		if samples == nil {
+			samples = make([]*metrics.KeyErrorSample, 0)
+		}
+
+		c.JSON(200, gin.H{
+			"channelIndex": channelIndex,
+			"keyMask":      keyMask,
+			"errors":       samples,
+		})
+	}
+}
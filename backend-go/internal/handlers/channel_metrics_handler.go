@@ -36,10 +36,13 @@ func GetChannelMetricsWithConfig(metricsManager *metrics.MetricsManager, cfgMana
 				"failureCount":        resp.FailureCount,
 				"successRate":         resp.SuccessRate,
 				"errorRate":           resp.ErrorRate,
+				"insufficientData":    resp.InsufficientData,
 				"consecutiveFailures": resp.ConsecutiveFailures,
 				"latency":             resp.Latency,
 				"keyMetrics":          resp.KeyMetrics,  // 各 Key 的详细指标
 				"timeWindows":         resp.TimeWindows, // 分时段统计 (15m, 1h, 6h, 24h)
+				"uptime":              resp.Uptime,      // 分时段可用率 (0-1)
+				"activityProfile":     resp.ActivityProfile,
 			}
 
 			if resp.LastSuccessAt != nil {
@@ -84,6 +87,7 @@ func GetAllKeyMetrics(metricsManager *metrics.MetricsManager) gin.HandlerFunc {
 				"failureCount":        m.FailureCount,
 				"successRate":         successRate,
 				"consecutiveFailures": m.ConsecutiveFailures,
+				"activeRequests":      m.ActiveRequests.Load(),
 			}
 
 			if m.LastSuccessAt != nil {
@@ -204,11 +208,17 @@ func GetSchedulerStats(sch *scheduler.ChannelScheduler) gin.HandlerFunc {
 			"multiChannelMode":    sch.IsMultiChannelMode(kind),
 			"activeChannelCount":  sch.GetActiveChannelCount(kind),
 			"traceAffinityCount":  sch.GetTraceAffinityManager().Size(),
-			"traceAffinityTTL":    sch.GetTraceAffinityManager().GetTTL().String(),
+			"traceAffinityTTL":    sch.GetTraceAffinityManager().GetKindTTL(string(kind)).String(),
 			"failureThreshold":    metricsManager.GetFailureThreshold() * 100,
 			"windowSize":          metricsManager.GetWindowSize(),
 			"circuitRecoveryTime": metricsManager.GetCircuitRecoveryTime().String(),
+			"responseCacheHits":   sch.GetResponseCacheHits(kind),
+			"selectionCounts":     sch.GetChannelSelectionCounts(kind),
+			"selectionReasons":    sch.GetSelectionReasonStats(kind),
 		}
+		shadowQueued, shadowDropped := sch.GetShadowReplayStats()
+		stats["shadowReplayQueued"] = shadowQueued
+		stats["shadowReplayDropped"] = shadowDropped
 
 		c.JSON(200, stats)
 	}
@@ -331,32 +341,9 @@ func GetChannelMetricsHistory(metricsManager *metrics.MetricsManager, cfgManager
 		}
 
 		// 解析或自动选择 interval
-		intervalStr := c.Query("interval")
-		var interval time.Duration
-		if intervalStr != "" {
-			interval, err = time.ParseDuration(intervalStr)
-			if err != nil {
-				c.JSON(400, gin.H{"error": "Invalid interval parameter"})
-				return
-			}
-			// 限制 interval 最小值为 1 分钟，防止生成过多 bucket
-			if interval < time.Minute {
-				interval = time.Minute
-			}
-		} else {
-			// 根据 duration 自动选择合适的聚合粒度
-			// 目标：每个时间段约 60-100 个数据点，保持图表清晰
-			// 1h = 60 points (1m interval)
-			// 6h = 72 points (5m interval)
-			// 24h = 96 points (15m interval)
-			switch {
-			case duration <= time.Hour:
-				interval = time.Minute
-			case duration <= 6*time.Hour:
-				interval = 5 * time.Minute
-			default:
-				interval = 15 * time.Minute
-			}
+		interval, ok := resolveHistoryInterval(c, duration)
+		if !ok {
+			return
 		}
 
 		cfg := cfgManager.GetConfig()
@@ -383,6 +370,81 @@ func GetChannelMetricsHistory(metricsManager *metrics.MetricsManager, cfgManager
 	}
 }
 
+// GetMultiChannelHistory 批量获取多个渠道的指标历史数据（减少多渠道仪表盘的请求次数）
+// GET /api/channels/metrics/history/batch?kind=messages&duration=6h&interval=5m&channels=0,2,3
+// Query params:
+//   - kind: 接口类型 (messages, responses, gemini, chat)，默认 messages
+//   - duration: 时间范围 (1h, 6h, 24h)，默认 24h
+//   - interval: 时间间隔，默认根据 duration 自动选择
+//   - channels: 逗号分隔的渠道索引，为空时返回全部渠道
+func GetMultiChannelHistory(sch *scheduler.ChannelScheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kind := strings.ToLower(c.DefaultQuery("kind", "messages"))
+		switch scheduler.ChannelKind(kind) {
+		case scheduler.ChannelKindMessages, scheduler.ChannelKindResponses, scheduler.ChannelKindGemini, scheduler.ChannelKindChat:
+		default:
+			c.JSON(400, gin.H{"error": "Invalid kind. Use: messages, responses, gemini, or chat"})
+			return
+		}
+
+		duration, err := time.ParseDuration(c.DefaultQuery("duration", "24h"))
+		if err != nil || duration <= 0 {
+			c.JSON(400, gin.H{"error": "Invalid duration parameter"})
+			return
+		}
+		// 限制最大查询范围为 24 小时
+		if duration > 24*time.Hour {
+			duration = 24 * time.Hour
+		}
+
+		interval, ok := resolveHistoryInterval(c, duration)
+		if !ok {
+			return
+		}
+
+		upstreams := sch.GetUpstreams(scheduler.ChannelKind(kind))
+		indexes := make([]int, 0, len(upstreams))
+		if channelsStr := strings.TrimSpace(c.Query("channels")); channelsStr != "" {
+			seen := make(map[int]bool)
+			for _, part := range strings.Split(channelsStr, ",") {
+				index, err := strconv.Atoi(strings.TrimSpace(part))
+				if err != nil || index < 0 || index >= len(upstreams) {
+					c.JSON(400, gin.H{"error": "Invalid channel index: " + strings.TrimSpace(part)})
+					return
+				}
+				if !seen[index] {
+					seen[index] = true
+					indexes = append(indexes, index)
+				}
+			}
+		} else {
+			for i := range upstreams {
+				indexes = append(indexes, i)
+			}
+		}
+
+		metricsManager := sch.GetMetricsManagerByKind(scheduler.ChannelKind(kind))
+		series := make([]MetricsHistoryResponse, 0, len(indexes))
+		for _, index := range indexes {
+			upstream := upstreams[index]
+			// 使用多 URL 聚合方法获取历史数据（支持 failover 多端点场景）
+			dataPoints := metricsManager.GetHistoricalStatsMultiURL(upstream.GetAllBaseURLs(), upstream.APIKeys, duration, interval)
+			series = append(series, MetricsHistoryResponse{
+				ChannelIndex: index,
+				ChannelName:  upstream.Name,
+				DataPoints:   dataPoints,
+			})
+		}
+
+		c.JSON(200, gin.H{
+			"kind":     kind,
+			"duration": duration.String(),
+			"interval": interval.String(),
+			"series":   series,
+		})
+	}
+}
+
 // ChannelKeyMetricsHistoryResponse Key 级别历史指标响应
 type ChannelKeyMetricsHistoryResponse struct {
 	ChannelIndex int                       `json:"channelIndex"`
@@ -438,32 +500,9 @@ func GetChannelKeyMetricsHistory(metricsManager *metrics.MetricsManager, cfgMana
 		}
 
 		// 解析或自动选择 interval
-		intervalStr := c.Query("interval")
-		var interval time.Duration
-		if intervalStr != "" {
-			interval, err = time.ParseDuration(intervalStr)
-			if err != nil {
-				c.JSON(400, gin.H{"error": "Invalid interval parameter"})
-				return
-			}
-			// 限制 interval 最小值为 1 分钟，防止生成过多 bucket
-			if interval < time.Minute {
-				interval = time.Minute
-			}
-		} else {
-			// 根据 duration 自动选择合适的聚合粒度
-			// 目标：每个时间段约 60-100 个数据点，保持图表清晰
-			// 1h = 60 points (1m interval)
-			// 6h = 72 points (5m interval)
-			// 24h = 96 points (15m interval)
-			switch {
-			case duration <= time.Hour:
-				interval = time.Minute
-			case duration <= 6*time.Hour:
-				interval = 5 * time.Minute
-			default:
-				interval = 15 * time.Minute
-			}
+		interval, ok := resolveHistoryInterval(c, duration)
+		if !ok {
+			return
 		}
 
 		// 解析 channel ID
@@ -611,28 +650,52 @@ func GetChannelDashboard(cfgManager *config.ConfigManager, sch *scheduler.Channe
 			priority := config.GetChannelPriority(&up, i)
 
 			channel := gin.H{
-				"index":              i,
-				"name":               up.Name,
-				"serviceType":        up.ServiceType,
-				"baseUrl":            up.BaseURL,
-				"baseUrls":           up.BaseURLs,
-				"apiKeys":            up.APIKeys,
-				"description":        up.Description,
-				"website":            up.Website,
-				"insecureSkipVerify": up.InsecureSkipVerify,
-				"modelMapping":       up.ModelMapping,
-				"reasoningMapping":   up.ReasoningMapping,
-				"textVerbosity":      up.TextVerbosity,
-				"fastMode":           up.FastMode,
-				"customHeaders":      up.CustomHeaders,
-				"proxyUrl":           up.ProxyURL,
-				"supportedModels":    up.SupportedModels,
-				"latency":            nil,
-				"status":             status,
-				"priority":           priority,
-				"promotionUntil":     up.PromotionUntil,
-				"lowQuality":         up.LowQuality,
-				"rpm":                up.RPM,
+				"index":                    i,
+				"name":                     up.Name,
+				"serviceType":              up.ServiceType,
+				"baseUrl":                  up.BaseURL,
+				"baseUrls":                 up.BaseURLs,
+				"apiKeys":                  up.APIKeys,
+				"description":              up.Description,
+				"website":                  up.Website,
+				"insecureSkipVerify":       up.InsecureSkipVerify,
+				"modelMapping":             up.ModelMapping,
+				"reasoningMapping":         up.ReasoningMapping,
+				"textVerbosity":            up.TextVerbosity,
+				"fastMode":                 up.FastMode,
+				"customHeaders":            up.CustomHeaders,
+				"forwardHeaderAllowlist":   up.ForwardHeaderAllowlist,
+				"stripHeaders":             up.StripHeaders,
+				"proxyUrl":                 up.ProxyURL,
+				"proxyUrls":                up.ProxyURLs,
+				"compressUpstreamRequests": up.CompressUpstreamRequests,
+				"failoverStatusCodes":      up.FailoverStatusCodes,
+				"noFailoverStatusCodes":    up.NoFailoverStatusCodes,
+				"deployment":               up.Deployment,
+				"apiVersion":               up.APIVersion,
+				"caCertPath":               up.CACertPath,
+				"clientCertPath":           up.ClientCertPath,
+				"clientKeyPath":            up.ClientKeyPath,
+				"serverName":               up.ServerName,
+				"supportedModels":          up.SupportedModels,
+				"autoReorderKeys":          up.AutoReorderKeys,
+				"responseHeaderTimeout":    up.ResponseHeaderTimeout,
+				"streamIdleTimeout":        up.StreamIdleTimeout,
+				"maxConcurrent":            up.MaxConcurrent,
+				"queueTimeoutMs":           up.QueueTimeoutMs,
+				"dailyTokenBudget":         up.DailyTokenBudget,
+				"streamEventDenylist":      up.StreamEventDenylist,
+				"bodyTransforms":           up.BodyTransforms,
+				"circuitFailureThreshold":  up.CircuitFailureThreshold,
+				"circuitRecoverySeconds":   up.CircuitRecoverySeconds,
+				"canaryPercent":            up.CanaryPercent,
+				"keySprayCount":            up.KeySprayCount,
+				"latency":                  nil,
+				"status":                   status,
+				"priority":                 priority,
+				"promotionUntil":           up.PromotionUntil,
+				"lowQuality":               up.LowQuality,
+				"rpm":                      up.RPM,
 			}
 
 			// Gemini 特有字段
@@ -657,10 +720,14 @@ func GetChannelDashboard(cfgManager *config.ConfigManager, sch *scheduler.Channe
 				"failureCount":        resp.FailureCount,
 				"successRate":         resp.SuccessRate,
 				"errorRate":           resp.ErrorRate,
+				"insufficientData":    resp.InsufficientData,
 				"consecutiveFailures": resp.ConsecutiveFailures,
 				"latency":             resp.Latency,
 				"keyMetrics":          resp.KeyMetrics,
 				"timeWindows":         resp.TimeWindows,
+				"uptime":              resp.Uptime,
+				"activityProfile":     resp.ActivityProfile,
+				"selectionCount":      sch.GetChannelSelectionCount(kind, i),
 			}
 
 			if resp.LastSuccessAt != nil {
@@ -681,7 +748,7 @@ func GetChannelDashboard(cfgManager *config.ConfigManager, sch *scheduler.Channe
 			"multiChannelMode":    sch.IsMultiChannelMode(kind),
 			"activeChannelCount":  sch.GetActiveChannelCount(kind),
 			"traceAffinityCount":  sch.GetTraceAffinityManager().Size(),
-			"traceAffinityTTL":    sch.GetTraceAffinityManager().GetTTL().String(),
+			"traceAffinityTTL":    sch.GetTraceAffinityManager().GetKindTTL(string(kind)).String(),
 			"failureThreshold":    metricsManager.GetFailureThreshold() * 100,
 			"windowSize":          metricsManager.GetWindowSize(),
 			"circuitRecoveryTime": metricsManager.GetCircuitRecoveryTime().String(),
@@ -723,28 +790,9 @@ func GetGeminiChannelMetricsHistory(metricsManager *metrics.MetricsManager, cfgM
 		}
 
 		// 解析或自动选择 interval
-		intervalStr := c.Query("interval")
-		var interval time.Duration
-		if intervalStr != "" {
-			interval, err = time.ParseDuration(intervalStr)
-			if err != nil {
-				c.JSON(400, gin.H{"error": "Invalid interval parameter"})
-				return
-			}
-			// 限制 interval 最小值为 1 分钟，防止生成过多 bucket
-			if interval < time.Minute {
-				interval = time.Minute
-			}
-		} else {
-			// 根据 duration 自动选择合适的聚合粒度
-			switch {
-			case duration <= time.Hour:
-				interval = time.Minute
-			case duration <= 6*time.Hour:
-				interval = 5 * time.Minute
-			default:
-				interval = 15 * time.Minute
-			}
+		interval, ok := resolveHistoryInterval(c, duration)
+		if !ok {
+			return
 		}
 
 		cfg := cfgManager.GetConfig()
@@ -797,28 +845,9 @@ func GetGeminiChannelKeyMetricsHistory(metricsManager *metrics.MetricsManager, c
 		}
 
 		// 解析或自动选择 interval
-		intervalStr := c.Query("interval")
-		var interval time.Duration
-		if intervalStr != "" {
-			interval, err = time.ParseDuration(intervalStr)
-			if err != nil {
-				c.JSON(400, gin.H{"error": "Invalid interval parameter"})
-				return
-			}
-			// 限制 interval 最小值为 1 分钟，防止生成过多 bucket
-			if interval < time.Minute {
-				interval = time.Minute
-			}
-		} else {
-			// 根据 duration 自动选择合适的聚合粒度
-			switch {
-			case duration <= time.Hour:
-				interval = time.Minute
-			case duration <= 6*time.Hour:
-				interval = 5 * time.Minute
-			default:
-				interval = 15 * time.Minute
-			}
+		interval, ok := resolveHistoryInterval(c, duration)
+		if !ok {
+			return
 		}
 
 		// 解析 channel ID
@@ -894,10 +923,13 @@ func GetGeminiChannelMetrics(metricsManager *metrics.MetricsManager, cfgManager
 				"failureCount":        resp.FailureCount,
 				"successRate":         resp.SuccessRate,
 				"errorRate":           resp.ErrorRate,
+				"insufficientData":    resp.InsufficientData,
 				"consecutiveFailures": resp.ConsecutiveFailures,
 				"latency":             resp.Latency,
 				"keyMetrics":          resp.KeyMetrics,  // 各 Key 的详细指标
 				"timeWindows":         resp.TimeWindows, // 分时段统计 (15m, 1h, 6h, 24h)
+				"uptime":              resp.Uptime,      // 分时段可用率 (0-1)
+				"activityProfile":     resp.ActivityProfile,
 			}
 
 			if resp.LastSuccessAt != nil {
@@ -932,10 +964,13 @@ func GetChatChannelMetrics(metricsManager *metrics.MetricsManager, cfgManager *c
 				"failureCount":        resp.FailureCount,
 				"successRate":         resp.SuccessRate,
 				"errorRate":           resp.ErrorRate,
+				"insufficientData":    resp.InsufficientData,
 				"consecutiveFailures": resp.ConsecutiveFailures,
 				"latency":             resp.Latency,
 				"keyMetrics":          resp.KeyMetrics,
 				"timeWindows":         resp.TimeWindows,
+				"uptime":              resp.Uptime,
+				"activityProfile":     resp.ActivityProfile,
 			}
 			if resp.LastSuccessAt != nil {
 				item["lastSuccessAt"] = *resp.LastSuccessAt
@@ -1012,7 +1047,9 @@ func parseHistoryDuration(c *gin.Context) (time.Duration, time.Duration) {
 	if duration <= 0 || duration > 24*time.Hour {
 		duration = 24 * time.Hour
 	}
-	return duration, selectIntervalForDuration(c.Query("interval"), duration)
+	interval := selectIntervalForDuration(c.Query("interval"), duration)
+	c.Header(historyIntervalHeader, interval.String())
+	return duration, interval
 }
 
 // parseKeyHistoryDuration 解析 Key 历史数据查询参数（支持 today）
@@ -1030,17 +1067,67 @@ func parseKeyHistoryDuration(c *gin.Context) (time.Duration, time.Duration) {
 	if duration <= 0 || duration > 24*time.Hour {
 		duration = 24 * time.Hour
 	}
-	return duration, selectIntervalForDuration(c.Query("interval"), duration)
+	interval := selectIntervalForDuration(c.Query("interval"), duration)
+	c.Header(historyIntervalHeader, interval.String())
+	return duration, interval
 }
 
-// selectIntervalForDuration 解析或自动选择 interval
+// maxHistoryBuckets 单个历史序列允许的最大 bucket 数
+const maxHistoryBuckets = 500
+
+// historyIntervalHeader 响应头：实际生效的聚合粒度（可能因 bucket 上限被放宽）
+const historyIntervalHeader = "X-CCX-History-Interval"
+
+// selectIntervalForDuration 解析或自动选择 interval（无效参数时回退到自动选择）
 func selectIntervalForDuration(intervalStr string, duration time.Duration) time.Duration {
 	if intervalStr != "" {
 		interval, err := time.ParseDuration(intervalStr)
 		if err == nil && interval >= time.Minute {
-			return interval
+			return capHistoryInterval(duration, interval)
 		}
 	}
+	return autoHistoryInterval(duration)
+}
+
+// resolveHistoryInterval 解析 interval 参数，未指定时根据 duration 自动选择
+// 参数无效时写入 400 响应并返回 false
+func resolveHistoryInterval(c *gin.Context, duration time.Duration) (time.Duration, bool) {
+	intervalStr := c.Query("interval")
+	if intervalStr == "" {
+		interval := autoHistoryInterval(duration)
+		c.Header(historyIntervalHeader, interval.String())
+		return interval, true
+	}
+	interval, err := time.ParseDuration(intervalStr)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid interval parameter"})
+		return 0, false
+	}
+	// 限制 interval 最小值为 1 分钟，防止生成过多 bucket
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	interval = capHistoryInterval(duration, interval)
+	c.Header(historyIntervalHeader, interval.String())
+	return interval, true
+}
+
+// capHistoryInterval 当 duration/interval 超过 maxHistoryBuckets 时自动放宽 interval（按整分钟向上取整）
+// 避免 duration=24h&interval=1m 这类查询在多渠道/多 Key 下生成大量 bucket
+func capHistoryInterval(duration, interval time.Duration) time.Duration {
+	if interval <= 0 || duration <= 0 || duration <= interval*maxHistoryBuckets {
+		return interval
+	}
+	minInterval := (duration + maxHistoryBuckets - 1) / maxHistoryBuckets
+	return ((minInterval + time.Minute - 1) / time.Minute) * time.Minute
+}
+
+// autoHistoryInterval 根据 duration 自动选择合适的聚合粒度
+// 目标：每个时间段约 60-100 个数据点，保持图表清晰
+// 1h = 60 points (1m interval)
+// 6h = 72 points (5m interval)
+// 24h = 96 points (15m interval)
+func autoHistoryInterval(duration time.Duration) time.Duration {
 	switch {
 	case duration <= time.Hour:
 		return time.Minute
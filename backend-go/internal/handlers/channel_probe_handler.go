@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/httpclient"
+	"github.com/BenedictKing/ccx/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ProbeChannel 对已添加的渠道发送一次最小请求，返回健康状态
+// POST /api/{kind}/channels/:id/probe
+// 添加渠道本身不做探测（保持添加操作快速），需要即时反馈时在添加后调用此端点
+// 上游返回 401/403（认证失败）时自动将渠道设为 suspended，避免无效渠道进入调度
+func ProbeChannel(cfgManager *config.ConfigManager, channelKind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+			return
+		}
+
+		channel, err := getCapabilityTestChannel(cfgManager, channelKind, id)
+		if err != nil {
+			statusCode := http.StatusBadRequest
+			if err.Error() == "channel not found" {
+				statusCode = http.StatusNotFound
+			}
+			c.JSON(statusCode, gin.H{"error": err.Error()})
+			return
+		}
+		if len(channel.APIKeys) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No API keys configured"})
+			return
+		}
+		protocol, ok := keyTestProtocols[channel.ServiceType]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("serviceType %q 不支持探测", channel.ServiceType)})
+			return
+		}
+		model, err := getCapabilityProbeModel(protocol)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		httpReq, err := buildTestRequestWithModel(protocol, channel, model)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), upstreamKeyTestTimeout)
+		defer cancel()
+		httpReq = httpReq.WithContext(ctx)
+
+		client := httpclient.GetManager().GetStandardClientForUpstream(upstreamKeyTestTimeout, 0, channel, channel.ProxyURL)
+		startTime := time.Now()
+		resp, err := client.Do(httpReq)
+		latency := time.Since(startTime).Milliseconds()
+
+		result := gin.H{
+			"model":     model,
+			"latency":   latency,
+			"suspended": false,
+		}
+		if err != nil {
+			result["success"] = false
+			result["statusCode"] = 0
+			result["error"] = classifyError(err, 0, ctx)
+			log.Printf("[Channel-Probe] %s 渠道 [%d] %s 探测失败: %v", channelKind, id, channel.Name, err)
+			c.JSON(http.StatusOK, result)
+			return
+		}
+		defer resp.Body.Close()
+
+		result["statusCode"] = resp.StatusCode
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			result["success"] = true
+			log.Printf("[Channel-Probe] %s 渠道 [%d] %s 探测成功 (模型: %s, 耗时: %dms)", channelKind, id, channel.Name, model, latency)
+			c.JSON(http.StatusOK, result)
+			return
+		}
+
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, upstreamKeyTestMaxErrorBody))
+		result["success"] = false
+		result["error"] = classifyError(nil, resp.StatusCode, ctx)
+		result["errorBody"] = string(errorBody)
+
+		// 认证失败属于硬性错误：自动暂停渠道
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			if err := setChannelStatusByKind(cfgManager, channelKind, id, "suspended"); err != nil {
+				log.Printf("[Channel-Probe] 警告: %s 渠道 [%d] %s 自动暂停失败: %v", channelKind, id, channel.Name, err)
+			} else {
+				result["suspended"] = true
+				log.Printf("[Channel-Probe] %s 渠道 [%d] %s 认证失败 (HTTP %d, Key: %s)，已自动暂停",
+					channelKind, id, channel.Name, resp.StatusCode, utils.MaskAPIKey(channel.APIKeys[0]))
+			}
+		} else {
+			log.Printf("[Channel-Probe] %s 渠道 [%d] %s 探测失败: HTTP %d", channelKind, id, channel.Name, resp.StatusCode)
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// setChannelStatusByKind 按渠道类型设置渠道状态
+func setChannelStatusByKind(cfgManager *config.ConfigManager, channelKind string, id int, status string) error {
+	switch channelKind {
+	case "messages":
+		return cfgManager.SetChannelStatus(id, status)
+	case "responses":
+		return cfgManager.SetResponsesChannelStatus(id, status)
+	case "gemini":
+		return cfgManager.SetGeminiChannelStatus(id, status)
+	case "chat":
+		return cfgManager.SetChatChannelStatus(id, status)
+	default:
+		return fmt.Errorf("invalid channel kind")
+	}
+}
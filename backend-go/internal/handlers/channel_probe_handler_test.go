@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestProbeChannel 探测成功时渠道保持 active，认证失败时自动暂停
+func TestProbeChannel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		upstreamCode  int
+		wantSuccess   bool
+		wantSuspended bool
+		wantStatus    string
+	}{
+		{name: "探测成功", upstreamCode: http.StatusOK, wantSuccess: true, wantStatus: "active"},
+		{name: "401 自动暂停", upstreamCode: http.StatusUnauthorized, wantSuspended: true, wantStatus: "suspended"},
+		{name: "500 不暂停", upstreamCode: http.StatusInternalServerError, wantStatus: "active"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.upstreamCode != http.StatusOK {
+					w.WriteHeader(tt.upstreamCode)
+					w.Write([]byte(`{"error":{"message":"probe failed"}}`))
+					return
+				}
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n"))
+			}))
+			defer upstream.Close()
+
+			cfg := config.Config{
+				ChatUpstream: []config.UpstreamConfig{
+					{Name: "new-channel", BaseURL: upstream.URL, APIKeys: []string{"sk-test"}, ServiceType: "openai", Status: "active"},
+				},
+			}
+			data, err := json.MarshalIndent(cfg, "", "  ")
+			if err != nil {
+				t.Fatalf("序列化配置失败: %v", err)
+			}
+			configFile := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(configFile, data, 0644); err != nil {
+				t.Fatalf("写入配置文件失败: %v", err)
+			}
+			cfgManager, err := config.NewConfigManager(configFile)
+			if err != nil {
+				t.Fatalf("创建配置管理器失败: %v", err)
+			}
+			t.Cleanup(func() { cfgManager.Close() })
+
+			r := gin.New()
+			r.POST("/chat/channels/:id/probe", ProbeChannel(cfgManager, "chat"))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chat/channels/0/probe", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status=%d, body=%s", w.Code, w.Body.String())
+			}
+
+			var resp struct {
+				Success    bool `json:"success"`
+				StatusCode int  `json:"statusCode"`
+				Suspended  bool `json:"suspended"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("解析响应失败: %v", err)
+			}
+			if resp.Success != tt.wantSuccess || resp.Suspended != tt.wantSuspended || resp.StatusCode != tt.upstreamCode {
+				t.Fatalf("resp=%+v, want success=%v suspended=%v statusCode=%d", resp, tt.wantSuccess, tt.wantSuspended, tt.upstreamCode)
+			}
+			if got := config.GetChannelStatus(&cfgManager.GetConfig().ChatUpstream[0]); got != tt.wantStatus {
+				t.Fatalf("channel status=%s, want %s", got, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package chat
+
+import "strings"
+
+// chatEmptyContentPlaceholder 空内容消息的处理方式：填充占位文本（默认丢弃）
+const chatEmptyContentPlaceholder = "placeholder"
+
+// chatEmptyContentPlaceholderText 空内容消息填充的占位文本（Claude 不接受空文本块）
+const chatEmptyContentPlaceholderText = "(empty)"
+
+// isEmptyChatContent 判断 Chat 消息 content 是否为空（null、空白字符串、空数组）
+func isEmptyChatContent(content interface{}) bool {
+	switch v := content.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
@@ -4,6 +4,7 @@
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,12 +13,14 @@
 	"time"
 
 	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/converters"
 	"github.com/BenedictKing/ccx/internal/handlers/common"
 	"github.com/BenedictKing/ccx/internal/middleware"
 	"github.com/BenedictKing/ccx/internal/scheduler"
 	"github.com/BenedictKing/ccx/internal/types"
 	"github.com/BenedictKing/ccx/internal/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
 )
 
 // Handler Chat Completions API 代理处理器
@@ -28,12 +31,22 @@ func Handler(
 	channelScheduler *scheduler.ChannelScheduler,
 ) gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
+		common.EnsureCorrelationID(c)
+
 		// Chat 代理端点统一使用代理访问密钥鉴权（x-api-key / Authorization: Bearer）
 		middleware.ProxyAuthMiddleware(envCfg)(c)
 		if c.IsAborted() {
 			return
 		}
 
+		// 请求级超时覆盖（X-CCX-Timeout-Ms，需开启 ENABLE_TIMEOUT_OVERRIDE_HEADER）
+		common.ApplyTimeoutOverride(c, envCfg)
+
+		// 全局限流（未配置 globalMaxRpm/globalMaxTpm 时不生效）
+		if !common.CheckGlobalRateLimit(c, channelScheduler, "Chat") {
+			return
+		}
+
 		startTime := time.Now()
 
 		// 读取原始请求体
@@ -71,8 +84,30 @@ func Handler(
 			return
 		}
 
+		// 模型访问控制（allowedModels/deniedModels）
+		if !common.CheckModelAccess(c, cfgManager, string(scheduler.ChannelKindChat), "Chat", model) {
+			return
+		}
+
+		// 请求模型无渠道支持时替换为回退模型（FALLBACK_MODEL）
+		bodyBytes, model = common.ApplyFallbackModel(c, envCfg, cfgManager, channelScheduler, scheduler.ChannelKindChat, "Chat", bodyBytes, model)
+
 		// 从请求体提取 stream（默认 false）
 		isStream, _ := reqMap["stream"].(bool)
+		if isStream {
+			release, ok := common.AcquireClientStream(c, envCfg, "Chat")
+			if !ok {
+				return
+			}
+			defer release()
+
+			// 断线续传：携带续传令牌时回放缓冲事件，否则为本次流分配令牌（ENABLE_STREAM_RESUME）
+			if common.ResumeStream(c, envCfg, "Chat") {
+				return
+			}
+			finishResume := common.BeginResumableStream(c, envCfg, "Chat")
+			defer finishResume()
+		}
 
 		// 提取 user 字段用于 Trace 亲和性
 		userID, _ := reqMap["user"].(string)
@@ -83,8 +118,8 @@ func Handler(
 		// 记录原始请求信息
 		common.LogOriginalRequest(c, bodyBytes, envCfg, "Chat")
 
-		// 检查是否为多渠道模式
-		isMultiChannel := channelScheduler.IsMultiChannelMode(scheduler.ChannelKindChat)
+		// 检查是否为多渠道模式（携带固定渠道请求头时统一走多渠道流程）
+		isMultiChannel := channelScheduler.IsMultiChannelMode(scheduler.ChannelKindChat) || common.HasChannelPin(c, envCfg)
 
 		if isMultiChannel {
 			handleMultiChannel(c, envCfg, cfgManager, channelScheduler, bodyBytes, model, isStream, userID, startTime)
@@ -106,7 +141,6 @@ func handleMultiChannel(
 	userID string,
 	startTime time.Time,
 ) {
-	metricsManager := channelScheduler.GetChatMetricsManager()
 	common.HandleMultiChannelFailover(
 		c,
 		envCfg,
@@ -116,68 +150,91 @@ func handleMultiChannel(
 		userID,
 		model,
 		func(selection *scheduler.SelectionResult) common.MultiChannelAttemptResult {
-			upstream := selection.Upstream
-			channelIndex := selection.ChannelIndex
-
-			if upstream == nil {
-				return common.MultiChannelAttemptResult{}
-			}
-
-			baseURLs := upstream.GetAllBaseURLs()
-			sortedURLResults := channelScheduler.GetSortedURLsForChannel(scheduler.ChannelKindChat, channelIndex, baseURLs)
-
-			handled, successKey, successBaseURLIdx, failoverErr, usage, lastErr := common.TryUpstreamWithAllKeys(
-				c,
-				envCfg,
-				cfgManager,
-				channelScheduler,
-				scheduler.ChannelKindChat,
-				"Chat",
-				metricsManager,
-				upstream,
-				sortedURLResults,
-				bodyBytes,
-				isStream,
-				func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error) {
-					return cfgManager.GetNextChatAPIKey(upstream, failedKeys)
-				},
-				func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
-					return buildProviderRequest(c, upstreamCopy, upstreamCopy.BaseURL, apiKey, bodyBytes, model, isStream)
-				},
-				func(apiKey string) {
-					_ = cfgManager.DeprioritizeAPIKey(apiKey)
-				},
-				func(url string) {
-					channelScheduler.MarkURLFailure(scheduler.ChannelKindChat, channelIndex, url)
-				},
-				func(url string) {
-					channelScheduler.MarkURLSuccess(scheduler.ChannelKindChat, channelIndex, url)
-				},
-				func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, apiKey string) (*types.Usage, error) {
-					return handleSuccess(c, resp, upstreamCopy.ServiceType, envCfg, startTime, model, isStream)
-				},
-				model,
-				selection.ChannelIndex,
-				channelScheduler.GetChannelLogStore(scheduler.ChannelKindChat),
-			)
-
-			return common.MultiChannelAttemptResult{
-				Handled:           handled,
-				Attempted:         true,
-				SuccessKey:        successKey,
-				SuccessBaseURLIdx: successBaseURLIdx,
-				FailoverError:     failoverErr,
-				Usage:             usage,
-				LastError:         lastErr,
+			return tryChannel(c, envCfg, cfgManager, channelScheduler, selection.Upstream, selection.ChannelIndex, bodyBytes, model, isStream, startTime)
+		},
+		func(selection *scheduler.SelectionResult, result common.MultiChannelAttemptResult) {
+			if result.SuccessKey != "" {
+				replayToShadowChannel(c, envCfg, cfgManager, channelScheduler, selection.ChannelIndex, bodyBytes, model, isStream)
 			}
 		},
-		nil,
 		func(ctx *gin.Context, failoverErr *common.FailoverError, lastError error) {
 			handleAllChannelsFailed(ctx, failoverErr, lastError)
 		},
 	)
 }
 
+// tryChannel 在指定渠道内按 Key/BaseURL 轮转尝试请求（多渠道调度与死信重放共用）
+func tryChannel(
+	c *gin.Context,
+	envCfg *config.EnvConfig,
+	cfgManager *config.ConfigManager,
+	channelScheduler *scheduler.ChannelScheduler,
+	upstream *config.UpstreamConfig,
+	channelIndex int,
+	bodyBytes []byte,
+	model string,
+	isStream bool,
+	startTime time.Time,
+) common.MultiChannelAttemptResult {
+	if upstream == nil {
+		return common.MultiChannelAttemptResult{}
+	}
+
+	// Claude 上游无法支持 n>1 / logprobs：严格模式下跳过该渠道，全部无法支持时返回 400
+	if paramErr := checkClaudeUnsupportedParams(envCfg, upstream, bodyBytes); paramErr != nil {
+		return common.MultiChannelAttemptResult{FailoverError: paramErr}
+	}
+
+	metricsManager := channelScheduler.GetChatMetricsManager()
+	baseURLs := upstream.GetAllBaseURLs()
+	sortedURLResults := channelScheduler.GetSortedURLsForChannel(scheduler.ChannelKindChat, channelIndex, baseURLs)
+
+	handled, successKey, successBaseURLIdx, failoverErr, usage, lastErr := common.TryUpstreamWithAllKeys(
+		c,
+		envCfg,
+		cfgManager,
+		channelScheduler,
+		scheduler.ChannelKindChat,
+		"Chat",
+		metricsManager,
+		upstream,
+		sortedURLResults,
+		bodyBytes,
+		isStream,
+		func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error) {
+			return cfgManager.GetNextChatAPIKey(upstream, failedKeys)
+		},
+		func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
+			return buildProviderRequest(c, envCfg, upstreamCopy, upstreamCopy.BaseURL, apiKey, bodyBytes, model, isStream)
+		},
+		func(apiKey string) {
+			_ = cfgManager.DeprioritizeAPIKey(apiKey)
+		},
+		func(url string) {
+			channelScheduler.MarkURLFailure(scheduler.ChannelKindChat, channelIndex, url)
+		},
+		func(url string) {
+			channelScheduler.MarkURLSuccess(scheduler.ChannelKindChat, channelIndex, url)
+		},
+		func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, apiKey string) (*types.Usage, error) {
+			return handleSuccess(c, resp, upstreamCopy.ServiceType, upstreamCopy.StreamEventDenylist, bodyBytes, envCfg, startTime, model, isStream)
+		},
+		model,
+		channelIndex,
+		channelScheduler.GetChannelLogStore(scheduler.ChannelKindChat),
+	)
+
+	return common.MultiChannelAttemptResult{
+		Handled:           handled,
+		Attempted:         true,
+		SuccessKey:        successKey,
+		SuccessBaseURLIdx: successBaseURLIdx,
+		FailoverError:     failoverErr,
+		Usage:             usage,
+		LastError:         lastErr,
+	}
+}
+
 // handleSingleChannel 处理单渠道 Chat 请求
 func handleSingleChannel(
 	c *gin.Context,
@@ -200,11 +257,16 @@ func handleSingleChannel(
 		return
 	}
 
+	if paramErr := checkClaudeUnsupportedParams(envCfg, upstream, bodyBytes); paramErr != nil {
+		c.Data(paramErr.Status, "application/json", paramErr.Body)
+		return
+	}
+
 	metricsManager := channelScheduler.GetChatMetricsManager()
 	baseURLs := upstream.GetAllBaseURLs()
 	urlResults := common.BuildDefaultURLResults(baseURLs)
 
-	handled, _, _, lastFailoverError, _, lastError := common.TryUpstreamWithAllKeys(
+	handled, successKey, _, lastFailoverError, _, lastError := common.TryUpstreamWithAllKeys(
 		c,
 		envCfg,
 		cfgManager,
@@ -220,7 +282,7 @@ func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error
 			return cfgManager.GetNextChatAPIKey(upstream, failedKeys)
 		},
 		func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
-			return buildProviderRequest(c, upstreamCopy, upstreamCopy.BaseURL, apiKey, bodyBytes, model, isStream)
+			return buildProviderRequest(c, envCfg, upstreamCopy, upstreamCopy.BaseURL, apiKey, bodyBytes, model, isStream)
 		},
 		func(apiKey string) {
 			_ = cfgManager.DeprioritizeAPIKey(apiKey)
@@ -228,13 +290,16 @@ func(apiKey string) {
 		nil,
 		nil,
 		func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, apiKey string) (*types.Usage, error) {
-			return handleSuccess(c, resp, upstreamCopy.ServiceType, envCfg, startTime, model, isStream)
+			return handleSuccess(c, resp, upstreamCopy.ServiceType, upstreamCopy.StreamEventDenylist, bodyBytes, envCfg, startTime, model, isStream)
 		},
 		model,
 		channelIndex,
 		channelScheduler.GetChannelLogStore(scheduler.ChannelKindChat),
 	)
 	if handled {
+		if successKey != "" {
+			replayToShadowChannel(c, envCfg, cfgManager, channelScheduler, channelIndex, bodyBytes, model, isStream)
+		}
 		return
 	}
 
@@ -242,9 +307,45 @@ func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, a
 	handleAllKeysFailed(c, lastFailoverError, lastError)
 }
 
+// replayToShadowChannel 主渠道成功后将非流式请求异步镜像到影子渠道（流式请求不镜像）
+func replayToShadowChannel(
+	c *gin.Context,
+	envCfg *config.EnvConfig,
+	cfgManager *config.ConfigManager,
+	channelScheduler *scheduler.ChannelScheduler,
+	channelIndex int,
+	bodyBytes []byte,
+	model string,
+	isStream bool,
+) {
+	if isStream {
+		return
+	}
+
+	common.ReplayToShadowChannel(
+		c,
+		envCfg,
+		cfgManager,
+		channelScheduler,
+		scheduler.ChannelKindChat,
+		"Chat",
+		channelScheduler.GetChatMetricsManager(),
+		channelIndex,
+		bodyBytes,
+		model,
+		func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error) {
+			return cfgManager.GetNextChatAPIKey(upstream, failedKeys)
+		},
+		func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
+			return buildProviderRequest(c, envCfg, upstreamCopy, upstreamCopy.BaseURL, apiKey, bodyBytes, model, false)
+		},
+	)
+}
+
 // buildProviderRequest 构建上游请求
 func buildProviderRequest(
 	c *gin.Context,
+	envCfg *config.EnvConfig,
 	upstream *config.UpstreamConfig,
 	baseURL string,
 	apiKey string,
@@ -261,8 +362,8 @@ func buildProviderRequest(
 	var url string
 
 	switch upstream.ServiceType {
-	case "openai", "responses", "":
-		// OpenAI 兼容上游：透传请求，仅替换 model 并注入高级参数
+	case "openai", "responses", "azure", "":
+		// OpenAI 兼容上游（含 Azure OpenAI）：透传请求，仅替换 model 并注入高级参数
 		var reqMap map[string]interface{}
 		if err := json.Unmarshal(bodyBytes, &reqMap); err != nil {
 			return nil, err
@@ -282,7 +383,10 @@ func buildProviderRequest(
 		if err != nil {
 			return nil, err
 		}
-		if skipVersionPrefix {
+		if upstream.ServiceType == "azure" {
+			// Azure OpenAI：部署式路径，模型通过 deployment 或 modelMapping 确定
+			url = upstream.AzureChatCompletionsURL(baseURL, mappedModel)
+		} else if skipVersionPrefix {
 			url = fmt.Sprintf("%s/chat/completions", strings.TrimRight(baseURL, "/"))
 		} else {
 			url = fmt.Sprintf("%s/v1/chat/completions", strings.TrimRight(baseURL, "/"))
@@ -290,7 +394,11 @@ func buildProviderRequest(
 
 	case "claude":
 		// Claude 上游：转换 OpenAI Chat 格式为 Claude Messages 格式
-		claudeReq, err := convertChatToClaudeRequest(bodyBytes, mappedModel, isStream)
+		emptyContentMode := ""
+		if envCfg != nil {
+			emptyContentMode = envCfg.ChatEmptyContent
+		}
+		claudeReq, err := convertChatToClaudeRequest(bodyBytes, mappedModel, isStream, emptyContentMode)
 		if err != nil {
 			return nil, err
 		}
@@ -349,6 +457,12 @@ func buildProviderRequest(
 		}
 	}
 
+	// 渠道级请求体改写
+	requestBody, err := config.ApplyBodyTransforms(requestBody, upstream.BodyTransforms)
+	if err != nil {
+		return nil, err
+	}
+
 	req, err := http.NewRequestWithContext(c.Request.Context(), "POST", url, bytes.NewReader(requestBody))
 	if err != nil {
 		return nil, err
@@ -356,6 +470,7 @@ func buildProviderRequest(
 
 	// 使用统一的头部处理逻辑（透明代理）
 	req.Header = utils.PrepareUpstreamHeaders(c, req.URL.Host)
+	utils.FilterForwardedHeaders(req.Header, upstream.ForwardHeaderAllowlist, upstream.StripHeaders)
 
 	// 设置 Content-Type
 	req.Header.Set("Content-Type", "application/json")
@@ -365,19 +480,22 @@ func buildProviderRequest(
 	case "claude":
 		utils.SetAuthenticationHeader(req.Header, apiKey)
 		req.Header.Set("anthropic-version", "2023-06-01")
+	case "azure":
+		utils.SetAzureAuthenticationHeader(req.Header, apiKey)
 	default:
 		// OpenAI / Gemini / Responses 等都使用 Bearer token
 		utils.SetAuthenticationHeader(req.Header, apiKey)
 	}
 
 	// 应用自定义请求头
-	utils.ApplyCustomHeaders(req.Header, upstream.CustomHeaders)
+	utils.ApplyCustomHeaders(req.Header, upstream.CustomHeaders, utils.HeaderTemplateVars{Model: mappedModel, APIKey: apiKey})
 
 	return req, nil
 }
 
 // convertChatToClaudeRequest 将 OpenAI Chat 请求转换为 Claude Messages 格式
-func convertChatToClaudeRequest(bodyBytes []byte, model string, isStream bool) (map[string]interface{}, error) {
+// emptyContentMode 控制 content 为 null/空的 user/assistant 消息（Claude 会拒绝）：drop（默认，丢弃）或 placeholder（填充占位文本）
+func convertChatToClaudeRequest(bodyBytes []byte, model string, isStream bool, emptyContentMode string) (map[string]interface{}, error) {
 	var reqMap map[string]interface{}
 	if err := json.Unmarshal(bodyBytes, &reqMap); err != nil {
 		return nil, err
@@ -407,6 +525,29 @@ func convertChatToClaudeRequest(bodyBytes []byte, model string, isStream bool) (
 		claudeReq["top_p"] = topP
 	}
 
+	// 转换 stop → stop_sequences（OpenAI 允许字符串或数组，Claude 只接受数组）
+	switch stop := reqMap["stop"].(type) {
+	case string:
+		if stop != "" {
+			claudeReq["stop_sequences"] = []string{stop}
+		}
+	case []interface{}:
+		var stopSequences []string
+		for _, item := range stop {
+			if seq, ok := item.(string); ok && seq != "" {
+				stopSequences = append(stopSequences, seq)
+			}
+		}
+		if len(stopSequences) > 0 {
+			claudeReq["stop_sequences"] = stopSequences
+		}
+	}
+
+	// 转换 user → metadata.user_id（用于上游的用户归因）
+	if user, ok := reqMap["user"].(string); ok && user != "" {
+		claudeReq["metadata"] = map[string]interface{}{"user_id": user}
+	}
+
 	// 转换 messages：提取 system 消息，其余转为 Claude 格式
 	if messages, ok := reqMap["messages"].([]interface{}); ok {
 		var claudeMessages []map[string]interface{}
@@ -420,6 +561,16 @@ func convertChatToClaudeRequest(bodyBytes []byte, model string, isStream bool) (
 			role, _ := m["role"].(string)
 			content, _ := m["content"]
 
+			// 无 tool_calls 的空内容消息：丢弃或填充占位文本（仅含 tool_calls 的 assistant 消息在下方转为 tool_use）
+			if role != "system" && role != "tool" && isEmptyChatContent(content) {
+				if toolCalls, _ := m["tool_calls"].([]interface{}); role != "assistant" || len(toolCalls) == 0 {
+					if emptyContentMode != chatEmptyContentPlaceholder {
+						continue
+					}
+					content = []map[string]interface{}{{"type": "text", "text": chatEmptyContentPlaceholderText}}
+				}
+			}
+
 			switch role {
 			case "system":
 				if text, ok := content.(string); ok {
@@ -536,6 +687,9 @@ func convertChatToClaudeRequest(bodyBytes []byte, model string, isStream bool) (
 		}
 	}
 
+	// 转换 response_format：JSON 模式 / json_schema 结构化输出
+	applyClaudeResponseFormat(bodyBytes, claudeReq)
+
 	return claudeReq, nil
 }
 
@@ -544,6 +698,8 @@ func handleSuccess(
 	c *gin.Context,
 	resp *http.Response,
 	upstreamType string,
+	eventDenylist []string,
+	requestBody []byte,
 	envCfg *config.EnvConfig,
 	startTime time.Time,
 	model string,
@@ -552,13 +708,12 @@ func handleSuccess(
 	defer resp.Body.Close()
 
 	if isStream {
-		return handleStreamSuccess(c, resp, upstreamType, envCfg, startTime, model), nil
+		return handleStreamSuccess(c, resp, upstreamType, eventDenylist, requestBody, envCfg, startTime, model)
 	}
 
 	// 非流式响应处理
-	bodyBytes, err := io.ReadAll(resp.Body)
+	bodyBytes, err := common.ReadNonStreamBody(resp.Body, "Chat")
 	if err != nil {
-		chatErrorResponse(c, 500, "Failed to read response", "server_error")
 		return nil, err
 	}
 
@@ -574,6 +729,9 @@ func handleSuccess(
 		if err := json.Unmarshal(bodyBytes, &claudeResp); err != nil {
 			return nil, fmt.Errorf("%w: %v", common.ErrInvalidResponseBody, err)
 		}
+		if toolName, _, ok := structuredOutputTool(requestBody); ok {
+			unwrapStructuredOutput(claudeResp, toolName)
+		}
 		openaiResp := convertClaudeResponseToChat(claudeResp, model)
 		respBytes, err := json.Marshal(openaiResp)
 		if err != nil {
@@ -594,24 +752,58 @@ func handleSuccess(
 		}
 		return usage, nil
 
-	default:
-		// OpenAI / Gemini / Responses 等：直接透传（已经是 OpenAI Chat 格式）
-		c.Data(resp.StatusCode, "application/json", bodyBytes)
-
-		// 尝试提取 usage
-		var respMap map[string]interface{}
-		if err := json.Unmarshal(bodyBytes, &respMap); err == nil {
-			if u, ok := respMap["usage"].(map[string]interface{}); ok {
-				promptTokens, _ := u["prompt_tokens"].(float64)
-				completionTokens, _ := u["completion_tokens"].(float64)
-				return &types.Usage{
-					InputTokens:  int(promptTokens),
-					OutputTokens: int(completionTokens),
-				}, nil
+	case "gemini":
+		// Gemini 原生响应（candidates）：转换为 OpenAI Chat 格式，包括 functionCall → tool_calls
+		// OpenAI 兼容端点返回的响应已是 Chat 格式，按默认逻辑透传
+		if gjson.GetBytes(bodyBytes, "candidates").Exists() {
+			var geminiResp types.GeminiResponse
+			if err := json.Unmarshal(bodyBytes, &geminiResp); err != nil {
+				return nil, fmt.Errorf("%w: %v", common.ErrInvalidResponseBody, err)
+			}
+			respBytes, err := json.Marshal(converters.GeminiResponseToOpenAIChat(&geminiResp, model))
+			if err != nil {
+				return nil, err
+			}
+			c.Data(resp.StatusCode, "application/json", respBytes)
+
+			var usage *types.Usage
+			if geminiResp.UsageMetadata != nil {
+				usage = &types.Usage{
+					InputTokens:  geminiResp.UsageMetadata.PromptTokenCount,
+					OutputTokens: geminiResp.UsageMetadata.CandidatesTokenCount,
+				}
 			}
+			return usage, nil
+		}
+		return passthroughChatResponse(c, resp.StatusCode, bodyBytes)
+
+	default:
+		// OpenAI / Responses 等：直接透传（已经是 OpenAI Chat 格式）
+		return passthroughChatResponse(c, resp.StatusCode, bodyBytes)
+	}
+}
+
+// passthroughChatResponse 透传 OpenAI Chat 格式的非流式响应并提取 usage
+func passthroughChatResponse(c *gin.Context, statusCode int, bodyBytes []byte) (*types.Usage, error) {
+	// 透传前校验 JSON 完整性，截断响应在写入客户端前 failover
+	if err := common.ValidateJSONBody(bodyBytes, "Chat"); err != nil {
+		return nil, err
+	}
+	c.Data(statusCode, "application/json", bodyBytes)
+
+	// 尝试提取 usage
+	var respMap map[string]interface{}
+	if err := json.Unmarshal(bodyBytes, &respMap); err == nil {
+		if u, ok := respMap["usage"].(map[string]interface{}); ok {
+			promptTokens, _ := u["prompt_tokens"].(float64)
+			completionTokens, _ := u["completion_tokens"].(float64)
+			return &types.Usage{
+				InputTokens:  int(promptTokens),
+				OutputTokens: int(completionTokens),
+			}, nil
 		}
-		return nil, nil
 	}
+	return nil, nil
 }
 
 // convertClaudeResponseToChat 将 Claude 非流式响应转换为 OpenAI Chat 格式
@@ -713,16 +905,21 @@ func convertClaudeResponseToChat(claudeResp map[string]interface{}, model string
 }
 
 // handleStreamSuccess 处理流式响应
+// eventDenylist: 透传时丢弃的 SSE 事件类型（渠道级配置）
+// requestBody: 上游未返回 usage 时用于估算输入 token
 func handleStreamSuccess(
 	c *gin.Context,
 	resp *http.Response,
 	upstreamType string,
+	eventDenylist []string,
+	requestBody []byte,
 	envCfg *config.EnvConfig,
 	startTime time.Time,
 	model string,
-) *types.Usage {
-	// 设置 SSE 响应头
-	c.Header("Content-Type", "text/event-stream")
+) (*types.Usage, error) {
+	// 设置流式响应头（?format=ndjson 时切换为 NDJSON 输出）
+	format := common.ApplyStreamFormat(c)
+	c.Header("Content-Type", common.StreamContentType(format))
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 	c.Header("X-Accel-Buffering", "no")
@@ -732,14 +929,41 @@ func handleStreamSuccess(
 		log.Printf("[Chat-Stream] 警告: ResponseWriter 不支持 Flusher")
 	}
 
+	// 慢客户端背压保护（STREAM_BACKPRESSURE_TIMEOUT > 0 时启用）
+	body, stopBackpressure := common.WrapStreamBackpressure(c.Writer, resp.Body, envCfg)
+	defer stopBackpressure()
+
 	var totalUsage *types.Usage
+	var streamErr error
+	var outputText strings.Builder
 
 	switch upstreamType {
 	case "claude":
-		totalUsage = streamClaudeToChat(c, resp, flusher, model)
+		structuredTool, _, _ := structuredOutputTool(requestBody)
+		totalUsage, streamErr = streamClaudeToChat(c, body, flusher, model, structuredTool, &outputText)
 	default:
 		// OpenAI / Gemini / Responses 等：直接透传 SSE 流
-		totalUsage = streamPassthrough(c, resp, flusher)
+		totalUsage, streamErr = streamPassthrough(c, body, flusher, common.NewSSEEventFilter(eventDenylist), &outputText)
+	}
+
+	// 慢客户端中止或客户端取消：按客户端侧错误返回，由调用方记录为客户端取消而非成功
+	switch {
+	case errors.Is(streamErr, common.ErrSlowClient):
+		log.Printf("[Chat-Stream] 警告: 客户端消费过慢，读取缓冲区持续满载，已中止请求以释放上游连接")
+		return nil, streamErr
+	case c.Request.Context().Err() != nil:
+		if envCfg.ShouldLog("info") {
+			log.Printf("[Chat-Stream] 客户端已取消请求，停止读取上游流")
+		}
+		return nil, c.Request.Context().Err()
+	}
+
+	// 上游未返回 usage 时按请求体与已输出文本估算，避免 token 漏记
+	if totalUsage == nil {
+		totalUsage = common.EstimateStreamUsage(requestBody, outputText.String())
+		if envCfg.EnableResponseLogs {
+			log.Printf("[Chat-Stream-Usage] 上游未返回 usage，使用估算值: input=%d, output=%d", totalUsage.InputTokens, totalUsage.OutputTokens)
+		}
 	}
 
 	if envCfg.EnableResponseLogs {
@@ -747,21 +971,29 @@ func handleStreamSuccess(
 		log.Printf("[Chat-Stream-Timing] 流式响应完成: %dms", responseTime)
 	}
 
-	return totalUsage
+	return totalUsage, nil
 }
 
 // streamPassthrough 直接透传 SSE 流（用于 OpenAI 兼容上游）
+// eventFilter 非空时按事件丢弃黑名单中的事件，其余字节原样转发
+// outputText 累积 delta 文本，供上游未返回 usage 时估算
 func streamPassthrough(
 	c *gin.Context,
-	resp *http.Response,
+	body io.Reader,
 	flusher http.Flusher,
-) *types.Usage {
+	eventFilter *common.SSEEventFilter,
+	outputText *strings.Builder,
+) (*types.Usage, error) {
 	var totalUsage *types.Usage
 	buf := make([]byte, 32*1024)
 	var remainder string
 
 	for {
-		n, err := resp.Body.Read(buf)
+		// 客户端已断开：停止读取上游（上游请求与客户端 context 绑定，读取也会随之中断）
+		if err := c.Request.Context().Err(); err != nil {
+			return nil, err
+		}
+		n, err := body.Read(buf)
 		if n > 0 {
 			// 使用行缓冲机制避免跨 chunk 截断
 			data := remainder + string(buf[:n])
@@ -780,6 +1012,14 @@ func streamPassthrough(
 				}
 				var parsed map[string]interface{}
 				if json.Unmarshal([]byte(jsonData), &parsed) == nil {
+					if choices, ok := parsed["choices"].([]interface{}); ok && len(choices) > 0 {
+						if choice, ok := choices[0].(map[string]interface{}); ok {
+							if delta, ok := choice["delta"].(map[string]interface{}); ok {
+								content, _ := delta["content"].(string)
+								outputText.WriteString(content)
+							}
+						}
+					}
 					if u, ok := parsed["usage"].(map[string]interface{}); ok {
 						promptTokens, _ := u["prompt_tokens"].(float64)
 						completionTokens, _ := u["completion_tokens"].(float64)
@@ -791,33 +1031,85 @@ func streamPassthrough(
 				}
 			}
 
-			c.Writer.Write(buf[:n])
-			if flusher != nil {
-				flusher.Flush()
+			if out := eventFilter.Filter(buf[:n]); len(out) > 0 {
+				c.Writer.Write(out)
+				if flusher != nil {
+					flusher.Flush()
+				}
 			}
 		}
+		if errors.Is(err, common.ErrSlowClient) {
+			return nil, err
+		}
 		if err != nil {
 			break
 		}
 	}
 
-	return totalUsage
+	if rest := eventFilter.Flush(); len(rest) > 0 {
+		c.Writer.Write(rest)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	return totalUsage, nil
 }
 
 // streamClaudeToChat Claude 流式响应转换为 OpenAI Chat 格式
+// 转换后的 chunk 只输出 data: 行：上游 event: 行是 Anthropic 事件名，与 chat.completion.chunk 负载不对应，统一丢弃
+// structuredTool 非空时，该工具的调用（json_schema 结构化输出）还原为 content 文本增量
 func streamClaudeToChat(
 	c *gin.Context,
-	resp *http.Response,
+	body io.Reader,
 	flusher http.Flusher,
 	model string,
-) *types.Usage {
+	structuredTool string,
+	outputText *strings.Builder,
+) (*types.Usage, error) {
 	var totalUsage *types.Usage
 	var doneSent bool
 	buf := make([]byte, 32*1024)
 	var remainder string
 
+	// writeChunk 输出单个 SSE 事件，保证 \n\n 分隔
+	writeChunk := func(chunkBytes []byte) {
+		fmt.Fprintf(c.Writer, "data: %s\n\n", string(chunkBytes))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	// Claude content block 索引 -> OpenAI tool_calls 索引（仅 tool_use 块）
+	toolCallIndexes := make(map[int]int)
+	nextToolCallIndex := 0
+	// 结构化输出工具对应的 content block 索引
+	structuredBlocks := make(map[int]bool)
+
+	// writeDelta 输出单个 chat.completion.chunk 增量
+	writeDelta := func(delta map[string]interface{}) {
+		chatChunk := map[string]interface{}{
+			"id":      "chatcmpl-claude",
+			"object":  "chat.completion.chunk",
+			"created": time.Now().Unix(),
+			"model":   model,
+			"choices": []map[string]interface{}{
+				{
+					"index":         0,
+					"delta":         delta,
+					"finish_reason": nil,
+				},
+			},
+		}
+		chunkBytes, _ := json.Marshal(chatChunk)
+		writeChunk(chunkBytes)
+	}
+
 	for {
-		n, readErr := resp.Body.Read(buf)
+		if err := c.Request.Context().Err(); err != nil {
+			return nil, err
+		}
+		n, readErr := body.Read(buf)
 		if n > 0 {
 			data := remainder + string(buf[:n])
 			lines := strings.Split(data, "\n")
@@ -826,6 +1118,7 @@ func streamClaudeToChat(
 			lines = lines[:len(lines)-1]
 
 			for _, line := range lines {
+				line = strings.TrimRight(line, "\r")
 				if !strings.HasPrefix(line, "data: ") {
 					continue
 				}
@@ -847,38 +1140,106 @@ func streamClaudeToChat(
 				eventType, _ := event["type"].(string)
 
 				switch eventType {
+				case "content_block_start":
+					// Claude tool_use 块开始 → OpenAI tool_calls 首个增量（携带 id 与函数名）
+					block, ok := event["content_block"].(map[string]interface{})
+					if !ok {
+						continue
+					}
+					if blockType, _ := block["type"].(string); blockType != "tool_use" {
+						continue
+					}
+					blockIndex, _ := event["index"].(float64)
+					toolName, _ := block["name"].(string)
+					if structuredTool != "" && toolName == structuredTool {
+						structuredBlocks[int(blockIndex)] = true
+						continue
+					}
+					toolCallIndex := nextToolCallIndex
+					nextToolCallIndex++
+					toolCallIndexes[int(blockIndex)] = toolCallIndex
+					toolID, _ := block["id"].(string)
+					writeDelta(map[string]interface{}{
+						"tool_calls": []map[string]interface{}{
+							{
+								"index": toolCallIndex,
+								"id":    toolID,
+								"type":  "function",
+								"function": map[string]interface{}{
+									"name":      toolName,
+									"arguments": "",
+								},
+							},
+						},
+					})
+
 				case "content_block_delta":
 					delta, ok := event["delta"].(map[string]interface{})
 					if !ok {
 						continue
 					}
 					deltaType, _ := delta["type"].(string)
-					if deltaType == "text_delta" {
+					switch deltaType {
+					case "text_delta":
 						text, _ := delta["text"].(string)
-						chatChunk := map[string]interface{}{
-							"id":      "chatcmpl-claude",
-							"object":  "chat.completion.chunk",
-							"created": time.Now().Unix(),
-							"model":   model,
-							"choices": []map[string]interface{}{
+						outputText.WriteString(text)
+						writeDelta(map[string]interface{}{
+							"content": text,
+						})
+					case "input_json_delta":
+						// 工具参数增量：原样透传部分 JSON，由客户端拼接
+						blockIndex, _ := event["index"].(float64)
+						if structuredBlocks[int(blockIndex)] {
+							partialJSON, _ := delta["partial_json"].(string)
+							if partialJSON == "" {
+								continue
+							}
+							outputText.WriteString(partialJSON)
+							writeDelta(map[string]interface{}{
+								"content": partialJSON,
+							})
+							continue
+						}
+						toolCallIndex, exists := toolCallIndexes[int(blockIndex)]
+						if !exists {
+							continue
+						}
+						partialJSON, _ := delta["partial_json"].(string)
+						if partialJSON == "" {
+							continue
+						}
+						outputText.WriteString(partialJSON)
+						writeDelta(map[string]interface{}{
+							"tool_calls": []map[string]interface{}{
 								{
-									"index": 0,
-									"delta": map[string]interface{}{
-										"content": text,
+									"index": toolCallIndex,
+									"function": map[string]interface{}{
+										"arguments": partialJSON,
 									},
-									"finish_reason": nil,
 								},
 							},
-						}
-						chunkBytes, _ := json.Marshal(chatChunk)
-						fmt.Fprintf(c.Writer, "data: %s\n\n", string(chunkBytes))
-						if flusher != nil {
-							flusher.Flush()
-						}
+						})
 					}
 
+				case "content_block_stop":
+					// OpenAI 没有块结束事件，tool_calls 参数已在增量中完整输出
+					blockIndex, _ := event["index"].(float64)
+					delete(toolCallIndexes, int(blockIndex))
+
 				case "message_delta":
-					// 消息完成
+					// 消息完成：映射 stop_reason
+					finishReason := "stop"
+					if delta, ok := event["delta"].(map[string]interface{}); ok {
+						switch stopReason, _ := delta["stop_reason"].(string); stopReason {
+						case "max_tokens":
+							finishReason = "length"
+						case "tool_use":
+							// 仅调用了结构化输出工具时按普通文本结束
+							if nextToolCallIndex > 0 || len(structuredBlocks) == 0 {
+								finishReason = "tool_calls"
+							}
+						}
+					}
 					stopChunk := map[string]interface{}{
 						"id":      "chatcmpl-claude",
 						"object":  "chat.completion.chunk",
@@ -888,7 +1249,7 @@ func streamClaudeToChat(
 							{
 								"index":         0,
 								"delta":         map[string]interface{}{},
-								"finish_reason": "stop",
+								"finish_reason": finishReason,
 							},
 						},
 					}
@@ -909,10 +1270,7 @@ func streamClaudeToChat(
 					}
 
 					chunkBytes, _ := json.Marshal(stopChunk)
-					fmt.Fprintf(c.Writer, "data: %s\n\n", string(chunkBytes))
-					if flusher != nil {
-						flusher.Flush()
-					}
+					writeChunk(chunkBytes)
 
 				case "message_start":
 					// 提取初始 usage（input_tokens）
@@ -928,6 +1286,9 @@ func streamClaudeToChat(
 				}
 			}
 		}
+		if errors.Is(readErr, common.ErrSlowClient) {
+			return nil, readErr
+		}
 		if readErr != nil {
 			break
 		}
@@ -941,7 +1302,7 @@ func streamClaudeToChat(
 		}
 	}
 
-	return totalUsage
+	return totalUsage, nil
 }
 
 // chatErrorResponse 返回 OpenAI 格式的错误响应
@@ -957,7 +1318,10 @@ func chatErrorResponse(c *gin.Context, statusCode int, message string, code stri
 
 // handleAllChannelsFailed 处理所有渠道失败的情况
 func handleAllChannelsFailed(c *gin.Context, failoverErr *common.FailoverError, lastError error) {
+	common.RecordDeadLetter(c, "Chat", failoverErr, lastError)
+
 	if failoverErr != nil {
+		common.SetFailoverSummaryHeader(c, failoverErr)
 		c.Data(failoverErr.Status, "application/json", failoverErr.Body)
 		return
 	}
@@ -972,6 +1336,8 @@ func handleAllChannelsFailed(c *gin.Context, failoverErr *common.FailoverError,
 
 // handleAllKeysFailed 处理所有 Key 失败的情况
 func handleAllKeysFailed(c *gin.Context, failoverErr *common.FailoverError, lastError error) {
+	common.RecordDeadLetter(c, "Chat", failoverErr, lastError)
+
 	if failoverErr != nil {
 		c.Data(failoverErr.Status, "application/json", failoverErr.Body)
 		return
@@ -1,13 +1,22 @@
 package chat
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
+	"github.com/BenedictKing/ccx/internal/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -30,7 +39,7 @@ func TestBuildProviderRequest_InjectsReasoningBeforeModelRedirect(t *testing.T)
 		FastMode:      true,
 	}
 
-	req, err := buildProviderRequest(c, upstream, "https://api.example.com", "sk-test", bodyBytes, "gpt-5.1-codex", false)
+	req, err := buildProviderRequest(c, nil, upstream, "https://api.example.com", "sk-test", bodyBytes, "gpt-5.1-codex", false)
 	if err != nil {
 		t.Fatalf("buildProviderRequest() err = %v", err)
 	}
@@ -58,3 +67,664 @@ func TestBuildProviderRequest_InjectsReasoningBeforeModelRedirect(t *testing.T)
 		t.Fatalf("service_tier = %v, want priority", got["service_tier"])
 	}
 }
+
+func TestBuildProviderRequest_AzureDeploymentURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	bodyBytes := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	tests := []struct {
+		name     string
+		upstream *config.UpstreamConfig
+		baseURL  string
+		wantURL  string
+	}{
+		{
+			name:     "modelMapping 映射到部署名",
+			upstream: &config.UpstreamConfig{ServiceType: "azure", ModelMapping: map[string]string{"gpt-4o": "prod-gpt4o"}, APIVersion: "2024-06-01"},
+			baseURL:  "https://my-resource.openai.azure.com",
+			wantURL:  "https://my-resource.openai.azure.com/openai/deployments/prod-gpt4o/chat/completions?api-version=2024-06-01",
+		},
+		{
+			name:     "显式 deployment 与默认 api-version",
+			upstream: &config.UpstreamConfig{ServiceType: "azure", Deployment: "fixed-deploy"},
+			baseURL:  "https://my-resource.openai.azure.com/openai/",
+			wantURL:  "https://my-resource.openai.azure.com/openai/deployments/fixed-deploy/chat/completions?api-version=" + config.DefaultAzureAPIVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil).WithContext(context.Background())
+			c.Request.Header.Set("Authorization", "Bearer client-key")
+
+			req, err := buildProviderRequest(c, nil, tt.upstream, tt.baseURL, "azure-key-123", bodyBytes, "gpt-4o", false)
+			if err != nil {
+				t.Fatalf("buildProviderRequest() err = %v", err)
+			}
+			if got := req.URL.String(); got != tt.wantURL {
+				t.Fatalf("url = %q, want %q", got, tt.wantURL)
+			}
+			if got := req.Header.Get("api-key"); got != "azure-key-123" {
+				t.Fatalf("api-key = %q, want azure-key-123", got)
+			}
+			if got := req.Header.Get("Authorization"); got != "" {
+				t.Fatalf("Authorization = %q, want empty", got)
+			}
+		})
+	}
+}
+
+func TestBuildProviderRequest_CustomHeaderTemplates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil).WithContext(context.Background())
+
+	bodyBytes := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	upstream := &config.UpstreamConfig{
+		ServiceType:  "openai",
+		ModelMapping: map[string]string{"gpt-4o": "gpt-4o-2024-11-20"},
+		CustomHeaders: map[string]string{
+			"User-Agent":   "ccx/{{model}}",
+			"X-Request-Id": "{{uuid}}",
+			"X-Trace":      "{{uuid}}|{{keyMask}}",
+			"X-Static":     "literal-value",
+		},
+	}
+
+	req, err := buildProviderRequest(c, nil, upstream, "https://api.example.com", "sk-test-1234567890", bodyBytes, "gpt-4o", false)
+	if err != nil {
+		t.Fatalf("buildProviderRequest() err = %v", err)
+	}
+
+	if got := req.Header.Get("User-Agent"); got != "ccx/gpt-4o-2024-11-20" {
+		t.Fatalf("User-Agent = %q, want redirected model", got)
+	}
+	if got := req.Header.Get("X-Static"); got != "literal-value" {
+		t.Fatalf("X-Static = %q, want literal-value", got)
+	}
+	requestID := req.Header.Get("X-Request-Id")
+	if len(requestID) != 36 {
+		t.Fatalf("X-Request-Id = %q, want uuid", requestID)
+	}
+	if got, want := req.Header.Get("X-Trace"), requestID+"|"+utils.MaskAPIKey("sk-test-1234567890"); got != want {
+		t.Fatalf("X-Trace = %q, want %q (同一请求内 uuid 一致)", got, want)
+	}
+}
+
+func TestHandleStreamSuccess_NDJSONFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		upstreamType string
+		upstreamBody string
+		wantLines    int // 不含终止对象
+	}{
+		{
+			name:         "openai 透传",
+			upstreamType: "openai",
+			upstreamBody: "data: {\"id\":\"c1\",\"choices\":[{\"delta\":{\"content\":\"he\"}}]}\n\n" +
+				"data: {\"id\":\"c1\",\"choices\":[{\"delta\":{\"content\":\"llo\"}}],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":2}}\n\n" +
+				"data: [DONE]\n\n",
+			wantLines: 2,
+		},
+		{
+			name:         "claude 转换",
+			upstreamType: "claude",
+			upstreamBody: "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":3}}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"hello\"}}\n\n" +
+				"event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":2}}\n\n",
+			wantLines: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions?format=ndjson", nil)
+
+			// HalfReader 模拟上游 chunk 在行中间截断
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(iotest.HalfReader(strings.NewReader(tt.upstreamBody))),
+			}
+
+			usage, _ := handleStreamSuccess(c, resp, tt.upstreamType, nil, nil, &config.EnvConfig{}, time.Now(), "gpt-test")
+			if usage == nil || usage.OutputTokens != 2 {
+				t.Fatalf("usage = %+v, want OutputTokens=2", usage)
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+				t.Fatalf("Content-Type = %q, want application/x-ndjson", ct)
+			}
+
+			var lines []map[string]interface{}
+			scanner := bufio.NewScanner(strings.NewReader(w.Body.String()))
+			for scanner.Scan() {
+				line := scanner.Text()
+				var obj map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &obj); err != nil {
+					t.Fatalf("line %d is not valid JSON: %q (%v)", len(lines)+1, line, err)
+				}
+				lines = append(lines, obj)
+			}
+
+			if len(lines) != tt.wantLines+1 {
+				t.Fatalf("got %d lines, want %d: %s", len(lines), tt.wantLines+1, w.Body.String())
+			}
+			if last := lines[len(lines)-1]; last["type"] != "done" {
+				t.Fatalf("last line = %#v, want terminal done object", last)
+			}
+		})
+	}
+}
+
+func TestHandleStreamSuccess_DropsDeniedEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	chunk1 := "data: {\"id\":\"c1\",\"choices\":[{\"delta\":{\"content\":\"he\"}}]}\n\n"
+	chunk2 := "data: {\"id\":\"c1\",\"choices\":[{\"delta\":{\"content\":\"llo\"}}],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":2}}\n\n"
+	upstreamBody := "event: ping\ndata: {}\n\n" +
+		chunk1 +
+		"event: x-vendor-meta\ndata: {\"trace\":\"abc\"}\n\n" +
+		chunk2 +
+		"data: [DONE]\n\n"
+
+	// HalfReader 模拟上游 chunk 在行中间截断
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(iotest.HalfReader(strings.NewReader(upstreamBody))),
+	}
+
+	usage, _ := handleStreamSuccess(c, resp, "openai", []string{"ping", "x-*"}, nil, &config.EnvConfig{}, time.Now(), "gpt-test")
+	if usage == nil || usage.OutputTokens != 2 {
+		t.Fatalf("usage = %+v, want OutputTokens=2", usage)
+	}
+
+	want := chunk1 + chunk2 + "data: [DONE]\n\n"
+	if got := w.Body.String(); got != want {
+		t.Fatalf("body = %q, want %q", got, want)
+	}
+}
+
+func TestHandleStreamSuccess_EstimatesMissingUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	requestBody := []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"please say hello to the world"}]}`)
+
+	tests := []struct {
+		name          string
+		upstreamType  string
+		upstreamBody  string
+		wantEstimated bool
+	}{
+		{
+			name:         "openai 返回 usage 时不估算",
+			upstreamType: "openai",
+			upstreamBody: "data: {\"choices\":[{\"delta\":{\"content\":\"hello world\"}}]}\n\n" +
+				"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":2}}\n\n" +
+				"data: [DONE]\n\n",
+			wantEstimated: false,
+		},
+		{
+			name:         "openai 缺失 usage 时估算",
+			upstreamType: "openai",
+			upstreamBody: "data: {\"choices\":[{\"delta\":{\"content\":\"hello world, this is a streamed answer\"}}]}\n\n" +
+				"data: [DONE]\n\n",
+			wantEstimated: true,
+		},
+		{
+			name:          "claude 缺失 usage 时估算",
+			upstreamType:  "claude",
+			upstreamBody:  "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"hello world, this is a streamed answer\"}}\n\n",
+			wantEstimated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(tt.upstreamBody)),
+			}
+
+			usage, _ := handleStreamSuccess(c, resp, tt.upstreamType, nil, requestBody, &config.EnvConfig{}, time.Now(), "gpt-test")
+			if usage == nil {
+				t.Fatalf("usage = nil, want non-nil")
+			}
+			if usage.Estimated != tt.wantEstimated {
+				t.Fatalf("Estimated = %v, want %v", usage.Estimated, tt.wantEstimated)
+			}
+			if !tt.wantEstimated {
+				if usage.InputTokens != 3 || usage.OutputTokens != 2 {
+					t.Fatalf("usage = %+v, want upstream values 3/2", usage)
+				}
+				return
+			}
+			if usage.InputTokens <= 0 || usage.OutputTokens <= 0 {
+				t.Fatalf("usage = %+v, want positive estimated tokens", usage)
+			}
+		})
+	}
+}
+
+func TestHandleStreamSuccess_ClaudeToolUseDeltas(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	events := []string{
+		`{"type":"message_start","message":{"usage":{"input_tokens":12}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking."}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_01","name":"get_weather","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\": \"Par"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"is\", \"unit\": \"c\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"input_tokens":12,"output_tokens":20}}`,
+	}
+	var upstreamBody strings.Builder
+	for _, event := range events {
+		upstreamBody.WriteString("data: " + event + "\n\n")
+	}
+
+	// HalfReader 模拟工具参数增量在行中间被截断
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(iotest.HalfReader(strings.NewReader(upstreamBody.String()))),
+	}
+
+	usage, _ := handleStreamSuccess(c, resp, "claude", nil, nil, &config.EnvConfig{}, time.Now(), "gpt-test")
+	if usage == nil || usage.OutputTokens != 20 {
+		t.Fatalf("usage = %+v, want OutputTokens=20", usage)
+	}
+
+	type toolCallDelta struct {
+		Index    int    `json:"index"`
+		ID       string `json:"id"`
+		Type     string `json:"type"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	}
+	var (
+		content      string
+		toolCalls    []toolCallDelta
+		finishReason string
+	)
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content   string          `json:"content"`
+					ToolCalls []toolCallDelta `json:"tool_calls"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("解析 chunk 失败: %v, data=%s", err, data)
+		}
+		for _, choice := range chunk.Choices {
+			content += choice.Delta.Content
+			toolCalls = append(toolCalls, choice.Delta.ToolCalls...)
+			if choice.FinishReason != nil {
+				finishReason = *choice.FinishReason
+			}
+		}
+	}
+
+	if content != "Checking." {
+		t.Fatalf("content = %q, want %q", content, "Checking.")
+	}
+	if len(toolCalls) != 3 {
+		t.Fatalf("tool_calls 增量数 = %d, want 3 (首块 + 2 个参数增量): %+v", len(toolCalls), toolCalls)
+	}
+	first := toolCalls[0]
+	if first.Index != 0 || first.ID != "toolu_01" || first.Type != "function" || first.Function.Name != "get_weather" {
+		t.Fatalf("首个 tool_calls 增量 = %+v, want id/name preserved", first)
+	}
+	var arguments string
+	for _, tc := range toolCalls {
+		if tc.Index != 0 {
+			t.Fatalf("tool_calls index = %d, want 0", tc.Index)
+		}
+		arguments += tc.Function.Arguments
+	}
+	if arguments != `{"city": "Paris", "unit": "c"}` {
+		t.Fatalf("拼接后的 arguments = %q", arguments)
+	}
+	if finishReason != "tool_calls" {
+		t.Fatalf("finish_reason = %q, want tool_calls", finishReason)
+	}
+}
+
+// TestHandleStreamSuccess_ClaudeDropsEventLines 转换后的流只输出 data: 行，不携带上游 Anthropic 事件名，且事件以空行分隔
+func TestHandleStreamSuccess_ClaudeDropsEventLines(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstreamBody := "event: message_start\r\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":5}}}\r\n\r\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n" +
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":1}}\n\n"
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(iotest.HalfReader(strings.NewReader(upstreamBody))),
+	}
+	handleStreamSuccess(c, resp, "claude", nil, nil, &config.EnvConfig{}, time.Now(), "gpt-test")
+
+	frames := strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n")
+	for _, frame := range frames {
+		if strings.Contains(frame, "\n") || !strings.HasPrefix(frame, "data: ") {
+			t.Fatalf("每个 SSE 帧应只有单个 data 行: %q\nbody=%s", frame, w.Body.String())
+		}
+	}
+	if len(frames) != 3 || !strings.Contains(frames[0], `"content":"Hi"`) || !strings.Contains(frames[1], `"finish_reason":"stop"`) {
+		t.Fatalf("转换结果异常, body=%s", w.Body.String())
+	}
+	if frames[2] != "data: [DONE]" {
+		t.Fatalf("流应以 data: [DONE] 结束, body=%s", w.Body.String())
+	}
+}
+
+func TestHandleSuccess_GeminiFunctionCallToToolCalls(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	upstreamBody := `{"candidates":[{"content":{"role":"model","parts":[` +
+		`{"text":"thinking...","thought":true},` +
+		`{"text":"Let me check."},` +
+		`{"functionCall":{"name":"get_weather","args":{"city":"Paris","unit":"c"}}}` +
+		`]},"finishReason":"STOP"}],` +
+		`"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":8,"totalTokenCount":20}}`
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(upstreamBody)),
+	}
+
+	usage, err := handleSuccess(c, resp, "gemini", nil, nil, &config.EnvConfig{}, time.Now(), "gpt-test", false)
+	if err != nil {
+		t.Fatalf("handleSuccess error: %v", err)
+	}
+	if usage == nil || usage.InputTokens != 12 || usage.OutputTokens != 8 {
+		t.Fatalf("usage = %+v, want 12/8", usage)
+	}
+
+	var chatResp struct {
+		Object  string `json:"object"`
+		Model   string `json:"model"`
+		Choices []struct {
+			FinishReason string `json:"finish_reason"`
+			Message      struct {
+				Role      string  `json:"role"`
+				Content   *string `json:"content"`
+				ToolCalls []struct {
+					ID       string `json:"id"`
+					Type     string `json:"type"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &chatResp); err != nil {
+		t.Fatalf("解析响应失败: %v, body=%s", err, w.Body.String())
+	}
+	if chatResp.Object != "chat.completion" || chatResp.Model != "gpt-test" || len(chatResp.Choices) != 1 {
+		t.Fatalf("响应结构异常: %s", w.Body.String())
+	}
+	choice := chatResp.Choices[0]
+	if choice.FinishReason != "tool_calls" || choice.Message.Role != "assistant" {
+		t.Fatalf("finish_reason=%q role=%q, want tool_calls/assistant", choice.FinishReason, choice.Message.Role)
+	}
+	if choice.Message.Content == nil || *choice.Message.Content != "Let me check." {
+		t.Fatalf("content=%v, want 'Let me check.'（不包含思考内容）", choice.Message.Content)
+	}
+	if len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("tool_calls=%d, want 1", len(choice.Message.ToolCalls))
+	}
+	toolCall := choice.Message.ToolCalls[0]
+	if toolCall.ID == "" || toolCall.Type != "function" || toolCall.Function.Name != "get_weather" {
+		t.Fatalf("tool_call=%+v", toolCall)
+	}
+	var args map[string]string
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil || args["city"] != "Paris" || args["unit"] != "c" {
+		t.Fatalf("arguments=%q, want city=Paris unit=c", toolCall.Function.Arguments)
+	}
+	if chatResp.Usage.TotalTokens != 20 {
+		t.Fatalf("total_tokens=%d, want 20", chatResp.Usage.TotalTokens)
+	}
+}
+
+func TestConvertChatToClaudeRequest_StopAndUser(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantStop     []string
+		wantMetadata map[string]interface{}
+	}{
+		{
+			name:         "stop 字符串与 user",
+			body:         `{"model":"gpt-4o","stop":"END","user":"user-123","messages":[{"role":"user","content":"hi"}]}`,
+			wantStop:     []string{"END"},
+			wantMetadata: map[string]interface{}{"user_id": "user-123"},
+		},
+		{
+			name:     "stop 数组",
+			body:     `{"model":"gpt-4o","stop":["\n\n","END"],"messages":[{"role":"user","content":"hi"}]}`,
+			wantStop: []string{"\n\n", "END"},
+		},
+		{
+			name: "未设置 stop 与 user",
+			body: `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claudeReq, err := convertChatToClaudeRequest([]byte(tt.body), "claude-test", false, "")
+			if err != nil {
+				t.Fatalf("convertChatToClaudeRequest() err = %v", err)
+			}
+
+			stop, hasStop := claudeReq["stop_sequences"].([]string)
+			if len(tt.wantStop) == 0 {
+				if _, exists := claudeReq["stop_sequences"]; exists {
+					t.Fatalf("stop_sequences = %v, want absent", claudeReq["stop_sequences"])
+				}
+			} else if !hasStop || strings.Join(stop, "|") != strings.Join(tt.wantStop, "|") {
+				t.Fatalf("stop_sequences = %v, want %v", claudeReq["stop_sequences"], tt.wantStop)
+			}
+
+			metadata, hasMetadata := claudeReq["metadata"].(map[string]interface{})
+			if tt.wantMetadata == nil {
+				if hasMetadata {
+					t.Fatalf("metadata = %v, want absent", metadata)
+				}
+			} else if !hasMetadata || metadata["user_id"] != tt.wantMetadata["user_id"] {
+				t.Fatalf("metadata = %v, want %v", claudeReq["metadata"], tt.wantMetadata)
+			}
+		})
+	}
+}
+
+func TestConvertChatToClaudeRequest_EmptyContent(t *testing.T) {
+	body := `{"model":"gpt-4o","messages":[
+		{"role":"user","content":"weather?"},
+		{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},
+		{"role":"tool","tool_call_id":"call_1","content":"sunny"},
+		{"role":"assistant","content":null},
+		{"role":"user","content":"  "},
+		{"role":"user","content":"thanks"}
+	]}`
+
+	tests := []struct {
+		name      string
+		mode      string
+		wantRoles []string
+	}{
+		{name: "默认丢弃空内容消息", mode: "", wantRoles: []string{"user", "assistant", "user", "user"}},
+		{name: "填充占位文本", mode: chatEmptyContentPlaceholder, wantRoles: []string{"user", "assistant", "user", "assistant", "user", "user"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claudeReq, err := convertChatToClaudeRequest([]byte(body), "claude-test", false, tt.mode)
+			if err != nil {
+				t.Fatalf("convertChatToClaudeRequest() err = %v", err)
+			}
+			// 经 JSON 往返后按 Claude 请求结构校验
+			data, _ := json.Marshal(claudeReq)
+			var parsed struct {
+				Messages []struct {
+					Role    string          `json:"role"`
+					Content json.RawMessage `json:"content"`
+				} `json:"messages"`
+			}
+			if err := json.Unmarshal(data, &parsed); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+
+			var roles []string
+			for _, msg := range parsed.Messages {
+				roles = append(roles, msg.Role)
+				if content := strings.TrimSpace(string(msg.Content)); content == "null" || content == `""` || content == `"  "` || content == "[]" {
+					t.Fatalf("消息 content 不应为空: %s", data)
+				}
+			}
+			if strings.Join(roles, ",") != strings.Join(tt.wantRoles, ",") {
+				t.Fatalf("roles=%v, want %v", roles, tt.wantRoles)
+			}
+
+			// 仅含 tool_calls 的 assistant 消息转为只包含 tool_use 的 assistant 消息
+			var toolTurn []map[string]interface{}
+			if err := json.Unmarshal(parsed.Messages[1].Content, &toolTurn); err != nil || len(toolTurn) != 1 || toolTurn[0]["type"] != "tool_use" || toolTurn[0]["id"] != "call_1" {
+				t.Fatalf("tool-call-only assistant content=%s", parsed.Messages[1].Content)
+			}
+
+			if tt.mode == chatEmptyContentPlaceholder {
+				var placeholder []map[string]interface{}
+				if err := json.Unmarshal(parsed.Messages[3].Content, &placeholder); err != nil || len(placeholder) != 1 || placeholder[0]["text"] != chatEmptyContentPlaceholderText {
+					t.Fatalf("placeholder content=%s", parsed.Messages[3].Content)
+				}
+			}
+		})
+	}
+}
+
+// slowResponseWriter 每次写入都阻塞一段时间，模拟消费过慢的客户端
+type slowResponseWriter struct {
+	*httptest.ResponseRecorder
+	delay time.Duration
+}
+
+func (w *slowResponseWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return w.ResponseRecorder.Write(p)
+}
+
+// TestHandleStreamSuccess_AbortsSlowClient 透传流同样接入背压保护，慢客户端按客户端侧错误中止
+func TestHandleStreamSuccess_AbortsSlowClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// 上游持续快速产出事件，直到连接被关闭
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; ; i++ {
+			chunk := fmt.Sprintf("data: {\"id\":\"c1\",\"choices\":[{\"delta\":{\"content\":\"chunk-%d\"}}]}\n\n", i)
+			if _, err := pw.Write([]byte(chunk)); err != nil {
+				return
+			}
+		}
+	}()
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: pr}
+
+	writer := &slowResponseWriter{ResponseRecorder: httptest.NewRecorder(), delay: 50 * time.Millisecond}
+	c, _ := gin.CreateTestContext(writer)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := handleStreamSuccess(c, resp, "openai", nil, nil, &config.EnvConfig{LogLevel: "error", StreamBackpressureTimeout: 1}, time.Now(), "gpt-test")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, common.ErrSlowClient) {
+			t.Fatalf("err = %v, want ErrSlowClient", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("慢客户端请求未在阈值后中止")
+	}
+}
+
+// TestHandleStreamSuccess_ClientCanceled 客户端取消按客户端侧错误返回，不按成功记录估算用量
+func TestHandleStreamSuccess_ClientCanceled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		upstreamType string
+		upstreamBody string
+	}{
+		{upstreamType: "openai", upstreamBody: "data: {\"choices\":[{\"delta\":{\"content\":\"hello\"}}]}\n\n"},
+		{upstreamType: "claude", upstreamBody: "data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hello\"}}\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.upstreamType, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil).WithContext(ctx)
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(tt.upstreamBody)),
+			}
+
+			usage, err := handleStreamSuccess(c, resp, tt.upstreamType, nil, nil, &config.EnvConfig{}, time.Now(), "gpt-test")
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("err = %v, want context.Canceled", err)
+			}
+			if usage != nil {
+				t.Fatalf("usage = %+v, want nil", usage)
+			}
+		})
+	}
+}
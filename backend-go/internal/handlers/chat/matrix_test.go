@@ -37,7 +37,7 @@ func TestChatEntry_RequestMatrix_AllFourUpstreams(t *testing.T) {
 			if tt.name == "chat_hash_baseurl" {
 				upstream.BaseURL = "https://core.blink.new/api/v1/ai#"
 			}
-			req, err := buildProviderRequest(c, upstream, upstream.BaseURL, "sk-test", bodyBytes, "gpt-5", false)
+			req, err := buildProviderRequest(c, nil, upstream, upstream.BaseURL, "sk-test", bodyBytes, "gpt-5", false)
 			if err != nil {
 				t.Fatalf("buildProviderRequest() err = %v", err)
 			}
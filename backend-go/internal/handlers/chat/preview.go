@@ -0,0 +1,31 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
+	"github.com/gin-gonic/gin"
+)
+
+// BuildPreviewRequest 按真实请求路径的转换逻辑构建上游请求（不发送），用于请求预览
+func BuildPreviewRequest(c *gin.Context, upstream *config.UpstreamConfig, apiKey string, bodyBytes []byte) (*http.Request, error) {
+	var reqMap map[string]interface{}
+	if err := json.Unmarshal(bodyBytes, &reqMap); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	model, _ := reqMap["model"].(string)
+	if model == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	isStream, _ := reqMap["stream"].(bool)
+
+	previewCtx, err := common.NewPreviewContext(c, "/v1/chat/completions", bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	// 预览不依赖环境配置，空内容消息按默认方式（丢弃）转换
+	return buildProviderRequest(previewCtx, nil, upstream, upstream.BaseURL, apiKey, bodyBytes, model, isStream)
+}
@@ -0,0 +1,27 @@
+package chat
+
+import (
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// ReplayToChannel 将死信中保存的请求重放到指定渠道，仅在该渠道内的 Key/BaseURL 之间 failover
+// 上游响应直接写回 c，全部失败时按 Chat 错误格式返回
+func ReplayToChannel(c *gin.Context, envCfg *config.EnvConfig, cfgManager *config.ConfigManager, channelScheduler *scheduler.ChannelScheduler, upstream *config.UpstreamConfig, channelIndex int, bodyBytes []byte) (succeeded bool, err error) {
+	if err := common.PrepareReplayRequest(c, "/v1/chat/completions", bodyBytes); err != nil {
+		return false, err
+	}
+	model := gjson.GetBytes(bodyBytes, "model").String()
+	isStream := gjson.GetBytes(bodyBytes, "stream").Bool()
+
+	result := tryChannel(c, envCfg, cfgManager, channelScheduler, upstream, channelIndex, bodyBytes, model, isStream, time.Now())
+	if !result.Handled {
+		handleAllChannelsFailed(c, result.FailoverError, result.LastError)
+	}
+	return result.SuccessKey != "", nil
+}
@@ -0,0 +1,110 @@
+package chat
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"github.com/tidwall/gjson"
+)
+
+// OpenAI response_format → Claude 映射（尽力而为）：
+//   - json_object：在 system 中追加"仅输出 JSON 对象"的指令，Claude 通常遵守但不做强制校验
+//   - json_schema：转为强制调用的 Claude 工具（input_schema 即该 schema），响应中的工具调用再还原为 JSON 文本；
+//     请求已带 tools（强制工具会屏蔽客户端工具）或 schema 顶层不是 object（Claude 工具只接受 object）时，
+//     退化为在 system 中附带 schema 的指令
+//   - text 或其他类型：忽略
+
+const (
+	// jsonModeInstruction json_object 模式追加到 system 的指令
+	jsonModeInstruction = "Respond only with a single valid JSON object. Do not include any text, explanation or markdown code fences outside the JSON."
+
+	// structuredOutputDefaultToolName json_schema 未提供合法 name 时使用的工具名
+	structuredOutputDefaultToolName = "json_response"
+)
+
+// claudeToolNamePattern Claude 工具名的合法格式
+var claudeToolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// structuredOutputTool 返回 json_schema 响应格式对应的 Claude 工具名与 schema
+// 仅当该请求会以强制工具方式实现结构化输出时 ok 为 true（请求与响应转换共用此判断）
+func structuredOutputTool(bodyBytes []byte) (name string, schema map[string]interface{}, ok bool) {
+	rf := gjson.GetBytes(bodyBytes, "response_format")
+	if rf.Get("type").String() != "json_schema" {
+		return "", nil, false
+	}
+	if tools := gjson.GetBytes(bodyBytes, "tools"); tools.IsArray() && len(tools.Array()) > 0 {
+		return "", nil, false
+	}
+	schemaResult := rf.Get("json_schema.schema")
+	if !schemaResult.IsObject() || schemaResult.Get("type").String() != "object" {
+		return "", nil, false
+	}
+	if err := json.Unmarshal([]byte(schemaResult.Raw), &schema); err != nil {
+		return "", nil, false
+	}
+
+	name = rf.Get("json_schema.name").String()
+	if !claudeToolNamePattern.MatchString(name) {
+		name = structuredOutputDefaultToolName
+	}
+	return name, schema, true
+}
+
+// applyClaudeResponseFormat 将 OpenAI response_format 映射到 Claude 请求
+func applyClaudeResponseFormat(bodyBytes []byte, claudeReq map[string]interface{}) {
+	rf := gjson.GetBytes(bodyBytes, "response_format")
+	switch rf.Get("type").String() {
+	case "json_object":
+		appendClaudeSystem(claudeReq, jsonModeInstruction)
+	case "json_schema":
+		if name, schema, ok := structuredOutputTool(bodyBytes); ok {
+			description := rf.Get("json_schema.description").String()
+			if description == "" {
+				description = "Return the final answer as structured output matching this schema."
+			}
+			claudeReq["tools"] = []map[string]interface{}{
+				{"name": name, "description": description, "input_schema": schema},
+			}
+			claudeReq["tool_choice"] = map[string]interface{}{"type": "tool", "name": name}
+			return
+		}
+		instruction := jsonModeInstruction
+		if schema := rf.Get("json_schema.schema"); schema.Exists() {
+			instruction += " The JSON must conform to this JSON Schema: " + schema.Raw
+		}
+		appendClaudeSystem(claudeReq, instruction)
+	}
+}
+
+// appendClaudeSystem 在 Claude 请求的 system 末尾追加一段文本
+func appendClaudeSystem(claudeReq map[string]interface{}, text string) {
+	if existing, ok := claudeReq["system"].(string); ok && existing != "" {
+		claudeReq["system"] = existing + "\n\n" + text
+		return
+	}
+	claudeReq["system"] = text
+}
+
+// unwrapStructuredOutput 将 Claude 响应中结构化输出工具的调用还原为 JSON 文本块，stop_reason 还原为 end_turn
+func unwrapStructuredOutput(claudeResp map[string]interface{}, toolName string) {
+	content, ok := claudeResp["content"].([]interface{})
+	if !ok {
+		return
+	}
+	unwrapped := false
+	for i, block := range content {
+		b, ok := block.(map[string]interface{})
+		if !ok || b["type"] != "tool_use" || b["name"] != toolName {
+			continue
+		}
+		inputRaw, err := json.Marshal(b["input"])
+		if err != nil {
+			continue
+		}
+		content[i] = map[string]interface{}{"type": "text", "text": string(inputRaw)}
+		unwrapped = true
+	}
+	if unwrapped && claudeResp["stop_reason"] == "tool_use" {
+		claudeResp["stop_reason"] = "end_turn"
+	}
+}
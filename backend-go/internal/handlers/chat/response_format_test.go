@@ -0,0 +1,186 @@
+package chat
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestConvertChatToClaudeRequest_ResponseFormat response_format 映射为 Claude 的 system 指令或强制工具
+func TestConvertChatToClaudeRequest_ResponseFormat(t *testing.T) {
+	const schema = `{"type":"object","properties":{"answer":{"type":"string"}},"required":["answer"]}`
+
+	tests := []struct {
+		name           string
+		body           string
+		wantSystem     []string // system 需包含的片段
+		wantNoSystem   bool
+		wantToolChoice string // 期望强制调用的工具名
+		wantToolCount  int
+	}{
+		{
+			name:       "json_object 追加到已有 system",
+			body:       `{"model":"gpt-4o","response_format":{"type":"json_object"},"messages":[{"role":"system","content":"You are helpful."},{"role":"user","content":"hi"}]}`,
+			wantSystem: []string{"You are helpful.\n\n", jsonModeInstruction},
+		},
+		{
+			name:           "json_schema 转为强制工具",
+			body:           `{"model":"gpt-4o","response_format":{"type":"json_schema","json_schema":{"name":"final_answer","schema":` + schema + `}},"messages":[{"role":"user","content":"hi"}]}`,
+			wantNoSystem:   true,
+			wantToolChoice: "final_answer",
+			wantToolCount:  1,
+		},
+		{
+			name:           "json_schema 名称不合法时使用默认工具名",
+			body:           `{"model":"gpt-4o","response_format":{"type":"json_schema","json_schema":{"name":"final answer!","schema":` + schema + `}},"messages":[{"role":"user","content":"hi"}]}`,
+			wantNoSystem:   true,
+			wantToolChoice: structuredOutputDefaultToolName,
+			wantToolCount:  1,
+		},
+		{
+			name:          "请求已带 tools 时退化为 system 指令",
+			body:          `{"model":"gpt-4o","response_format":{"type":"json_schema","json_schema":{"name":"final_answer","schema":` + schema + `}},"tools":[{"type":"function","function":{"name":"get_weather"}}],"messages":[{"role":"user","content":"hi"}]}`,
+			wantSystem:    []string{jsonModeInstruction, `"required":["answer"]`},
+			wantToolCount: 1,
+		},
+		{
+			name:         "text 类型不做处理",
+			body:         `{"model":"gpt-4o","response_format":{"type":"text"},"messages":[{"role":"user","content":"hi"}]}`,
+			wantNoSystem: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claudeReq, err := convertChatToClaudeRequest([]byte(tt.body), "claude-test", false, "")
+			if err != nil {
+				t.Fatalf("convertChatToClaudeRequest() err = %v", err)
+			}
+
+			system, hasSystem := claudeReq["system"].(string)
+			if tt.wantNoSystem && hasSystem {
+				t.Fatalf("system = %q, want absent", system)
+			}
+			for _, want := range tt.wantSystem {
+				if !strings.Contains(system, want) {
+					t.Fatalf("system = %q, want contains %q", system, want)
+				}
+			}
+
+			tools, _ := claudeReq["tools"].([]map[string]interface{})
+			if len(tools) != tt.wantToolCount {
+				t.Fatalf("tools = %v, want %d tool(s)", claudeReq["tools"], tt.wantToolCount)
+			}
+
+			toolChoice, hasToolChoice := claudeReq["tool_choice"].(map[string]interface{})
+			if tt.wantToolChoice == "" {
+				if hasToolChoice {
+					t.Fatalf("tool_choice = %v, want absent", toolChoice)
+				}
+				return
+			}
+			if !hasToolChoice || toolChoice["type"] != "tool" || toolChoice["name"] != tt.wantToolChoice {
+				t.Fatalf("tool_choice = %v, want forced tool %q", claudeReq["tool_choice"], tt.wantToolChoice)
+			}
+			if tools[0]["name"] != tt.wantToolChoice {
+				t.Fatalf("tool name = %v, want %q", tools[0]["name"], tt.wantToolChoice)
+			}
+			inputSchema, _ := json.Marshal(tools[0]["input_schema"])
+			if !strings.Contains(string(inputSchema), `"required":["answer"]`) {
+				t.Fatalf("input_schema = %s, want the json_schema schema", inputSchema)
+			}
+		})
+	}
+}
+
+// TestHandleSuccess_UnwrapsStructuredOutput 结构化输出工具调用在响应中还原为 JSON 文本
+func TestHandleSuccess_UnwrapsStructuredOutput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	requestBody := []byte(`{"model":"gpt-4o","response_format":{"type":"json_schema","json_schema":{"name":"final_answer","schema":{"type":"object","properties":{"answer":{"type":"string"}}}}},"messages":[{"role":"user","content":"hi"}]}`)
+
+	t.Run("非流式", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+		upstreamBody := `{"id":"msg_1","content":[{"type":"tool_use","id":"toolu_01","name":"final_answer","input":{"answer":"42"}}],"stop_reason":"tool_use","usage":{"input_tokens":5,"output_tokens":3}}`
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(upstreamBody))}
+		if _, err := handleSuccess(c, resp, "claude", nil, requestBody, &config.EnvConfig{}, time.Now(), "gpt-4o", false); err != nil {
+			t.Fatalf("handleSuccess() err = %v", err)
+		}
+
+		var chatResp struct {
+			Choices []struct {
+				Message struct {
+					Content   string        `json:"content"`
+					ToolCalls []interface{} `json:"tool_calls"`
+				} `json:"message"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &chatResp); err != nil {
+			t.Fatalf("解析响应失败: %v", err)
+		}
+		choice := chatResp.Choices[0]
+		if choice.Message.Content != `{"answer":"42"}` || len(choice.Message.ToolCalls) != 0 || choice.FinishReason != "stop" {
+			t.Fatalf("choice = %+v, want JSON content with finish_reason=stop", choice)
+		}
+	})
+
+	t.Run("流式", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+		events := []string{
+			`{"type":"message_start","message":{"usage":{"input_tokens":5}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_01","name":"final_answer","input":{}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"answer\":"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"42\"}"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":3}}`,
+		}
+		var upstreamBody strings.Builder
+		for _, event := range events {
+			upstreamBody.WriteString("data: " + event + "\n\n")
+		}
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(upstreamBody.String()))}
+		handleStreamSuccess(c, resp, "claude", nil, requestBody, &config.EnvConfig{}, time.Now(), "gpt-4o")
+
+		var content, finishReason string
+		for _, line := range strings.Split(w.Body.String(), "\n") {
+			data, ok := strings.CutPrefix(line, "data: ")
+			if !ok || data == "[DONE]" {
+				continue
+			}
+			if strings.Contains(data, `"tool_calls"`) {
+				t.Fatalf("结构化输出不应以 tool_calls 返回: %s", data)
+			}
+			var chunk struct {
+				Choices []struct {
+					Delta struct {
+						Content string `json:"content"`
+					} `json:"delta"`
+					FinishReason *string `json:"finish_reason"`
+				} `json:"choices"`
+			}
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil || len(chunk.Choices) == 0 {
+				continue
+			}
+			content += chunk.Choices[0].Delta.Content
+			if chunk.Choices[0].FinishReason != nil {
+				finishReason = *chunk.Choices[0].FinishReason
+			}
+		}
+		if content != `{"answer":"42"}` || finishReason != "stop" {
+			t.Fatalf("content = %q, finish_reason = %q, want JSON content with stop", content, finishReason)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
+	"github.com/tidwall/gjson"
+)
+
+// claudeUnsupportedParams 返回 Claude 上游无法实现的 OpenAI Chat 参数（转换时会被丢弃）
+func claudeUnsupportedParams(bodyBytes []byte) []string {
+	var params []string
+	if n := gjson.GetBytes(bodyBytes, "n"); n.Exists() && n.Int() > 1 {
+		params = append(params, "n")
+	}
+	if gjson.GetBytes(bodyBytes, "logprobs").Bool() {
+		params = append(params, "logprobs")
+	}
+	if topLogprobs := gjson.GetBytes(bodyBytes, "top_logprobs"); topLogprobs.Exists() && topLogprobs.Type != gjson.Null {
+		params = append(params, "top_logprobs")
+	}
+	return params
+}
+
+// checkClaudeUnsupportedParams 检查发往 Claude 上游的请求是否包含无法支持的参数
+// drop 模式（默认）仅记录警告，参数在转换时被丢弃；error 严格模式返回 OpenAI 格式的 400 错误
+func checkClaudeUnsupportedParams(envCfg *config.EnvConfig, upstream *config.UpstreamConfig, bodyBytes []byte) *common.FailoverError {
+	if upstream == nil || upstream.ServiceType != "claude" {
+		return nil
+	}
+	params := claudeUnsupportedParams(bodyBytes)
+	if len(params) == 0 {
+		return nil
+	}
+
+	if envCfg == nil || envCfg.ChatUnsupportedParams != "error" {
+		log.Printf("[Chat-Convert] 警告: Claude 上游 %s 不支持参数 %s，已忽略", upstream.Name, strings.Join(params, ", "))
+		return nil
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": fmt.Sprintf("Unsupported parameter(s) for Claude upstream %q: %s. Claude returns a single completion without token log probabilities; remove these parameters (n must be 1).", upstream.Name, strings.Join(params, ", ")),
+			"type":    "invalid_request_error",
+			"param":   params[0],
+			"code":    "unsupported_parameter",
+		},
+	})
+	return &common.FailoverError{Status: 400, Body: body, Kind: common.FailoverKindInvalidRequest}
+}
@@ -0,0 +1,125 @@
+package chat
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/session"
+	"github.com/BenedictKing/ccx/internal/warmup"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_ClaudeUnsupportedParams Claude 上游不支持 n>1 / logprobs：严格模式返回 400，drop 模式忽略参数
+func TestHandler_ClaudeUnsupportedParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		mode         string
+		body         string
+		withOpenAI   bool
+		wantStatus   int
+		wantParam    string
+		wantUpstream string
+	}{
+		{name: "n=2 严格模式", mode: "error", body: `{"model":"claude-test","n":2,"messages":[{"role":"user","content":"hi"}]}`, wantStatus: http.StatusBadRequest, wantParam: "n"},
+		{name: "logprobs 严格模式", mode: "error", body: `{"model":"claude-test","logprobs":true,"messages":[{"role":"user","content":"hi"}]}`, wantStatus: http.StatusBadRequest, wantParam: "logprobs"},
+		{name: "n=1 不受影响", mode: "error", body: `{"model":"claude-test","n":1,"logprobs":false,"messages":[{"role":"user","content":"hi"}]}`, wantStatus: http.StatusOK, wantUpstream: "claude"},
+		{name: "未配置时默认忽略参数", mode: "", body: `{"model":"claude-test","n":2,"messages":[{"role":"user","content":"hi"}]}`, wantStatus: http.StatusOK, wantUpstream: "claude"},
+		{name: "drop 模式忽略参数", mode: "drop", body: `{"model":"claude-test","n":2,"logprobs":true,"messages":[{"role":"user","content":"hi"}]}`, wantStatus: http.StatusOK, wantUpstream: "claude"},
+		{name: "严格模式切换到 OpenAI 渠道", mode: "error", body: `{"model":"claude-test","n":2,"messages":[{"role":"user","content":"hi"}]}`, withOpenAI: true, wantStatus: http.StatusOK, wantUpstream: "openai"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var claudeCalls, openaiCalls atomic.Int32
+			claudeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				claudeCalls.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+			}))
+			defer claudeServer.Close()
+			openaiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				openaiCalls.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-test","choices":[{"index":0,"message":{"role":"assistant","content":"a"},"finish_reason":"stop"},{"index":1,"message":{"role":"assistant","content":"b"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3}}`))
+			}))
+			defer openaiServer.Close()
+
+			upstreams := []config.UpstreamConfig{
+				{Name: "claude", BaseURL: claudeServer.URL, APIKeys: []string{"sk-claude"}, ServiceType: "claude", Status: "active", Priority: 1},
+			}
+			if tt.withOpenAI {
+				upstreams = append(upstreams, config.UpstreamConfig{Name: "openai", BaseURL: openaiServer.URL, APIKeys: []string{"sk-openai"}, ServiceType: "openai", Status: "active", Priority: 2})
+			}
+			cm := setupChatConfigManager(t, upstreams)
+
+			messagesMetrics := metrics.NewMetricsManager()
+			responsesMetrics := metrics.NewMetricsManager()
+			geminiMetrics := metrics.NewMetricsManager()
+			chatMetrics := metrics.NewMetricsManager()
+			t.Cleanup(func() {
+				messagesMetrics.Stop()
+				responsesMetrics.Stop()
+				geminiMetrics.Stop()
+				chatMetrics.Stop()
+			})
+			sch := scheduler.NewChannelScheduler(cm, messagesMetrics, responsesMetrics, geminiMetrics, chatMetrics, session.NewTraceAffinityManager(), warmup.NewURLManager(30*time.Second, 3))
+
+			envCfg := &config.EnvConfig{
+				ProxyAccessKey:        "test-key",
+				LogLevel:              "error",
+				RequestTimeout:        5000,
+				MaxRequestBodySize:    1024 * 1024,
+				ChatUnsupportedParams: tt.mode,
+			}
+
+			r := gin.New()
+			r.POST("/v1/chat/completions", Handler(envCfg, cm, sch))
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer test-key")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status=%d, want=%d, body=%s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if got := claudeCalls.Load() > 0; got != (tt.wantUpstream == "claude") {
+				t.Fatalf("claude upstream called=%v, want upstream %q", got, tt.wantUpstream)
+			}
+			if got := openaiCalls.Load() > 0; got != (tt.wantUpstream == "openai") {
+				t.Fatalf("openai upstream called=%v, want upstream %q", got, tt.wantUpstream)
+			}
+
+			if tt.wantParam != "" {
+				var errResp struct {
+					Error struct {
+						Message string `json:"message"`
+						Type    string `json:"type"`
+						Param   string `json:"param"`
+						Code    string `json:"code"`
+					} `json:"error"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &errResp); err != nil {
+					t.Fatalf("解析错误响应失败: %v, body=%s", err, w.Body.String())
+				}
+				if errResp.Error.Type != "invalid_request_error" || errResp.Error.Code != "unsupported_parameter" || errResp.Error.Param != tt.wantParam {
+					t.Fatalf("error=%+v, want invalid_request_error/unsupported_parameter param=%s", errResp.Error, tt.wantParam)
+				}
+				if !strings.Contains(errResp.Error.Message, tt.wantParam) {
+					t.Fatalf("message=%q, want mention of %s", errResp.Error.Message, tt.wantParam)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// GetCircuitStates 列出每个 Key 当前的内存熔断状态（closed/open、熔断时间、连续失败数、剩余恢复时间）
+// GET /api/keys/circuit-states?kind=messages|responses|gemini|chat（未指定时返回全部类型）
+func GetCircuitStates(sch *scheduler.ChannelScheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kinds, ok := parseMetricsKinds(c)
+		if !ok {
+			return
+		}
+
+		results := make(map[string][]scheduler.KeyCircuitStatus, len(kinds))
+		open := 0
+		for _, kind := range kinds {
+			states := sch.GetCircuitStates(kind)
+			for _, state := range states {
+				if state.State == metrics.CircuitStateOpen {
+					open++
+				}
+			}
+			results[string(kind)] = states
+		}
+
+		c.JSON(200, gin.H{
+			"open":   open,
+			"states": results,
+		})
+	}
+}
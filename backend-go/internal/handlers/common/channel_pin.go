@@ -0,0 +1,35 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// ChannelPinHeader 客户端指定渠道索引的请求头（需开启 ENABLE_CHANNEL_PIN_HEADER）
+const ChannelPinHeader = "X-CCX-Channel"
+
+// ParseChannelPin 解析请求头中指定的渠道索引
+// 未开启或未携带请求头时返回 pinned=false；请求头不是非负整数时返回错误
+func ParseChannelPin(c *gin.Context, envCfg *config.EnvConfig) (index int, pinned bool, err error) {
+	if envCfg == nil || !envCfg.EnableChannelPinHeader {
+		return 0, false, nil
+	}
+	value := strings.TrimSpace(c.GetHeader(ChannelPinHeader))
+	if value == "" {
+		return 0, false, nil
+	}
+	index, err = strconv.Atoi(value)
+	if err != nil || index < 0 {
+		return 0, false, fmt.Errorf("无效的 %s 请求头: %q", ChannelPinHeader, value)
+	}
+	return index, true, nil
+}
+
+// HasChannelPin 请求是否携带了指定渠道的请求头（单渠道模式下用于切换到多渠道处理流程）
+func HasChannelPin(c *gin.Context, envCfg *config.EnvConfig) bool {
+	return envCfg != nil && envCfg.EnableChannelPinHeader && c.GetHeader(ChannelPinHeader) != ""
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/middleware"
+	"github.com/BenedictKing/ccx/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// clientStreamCounter 记录每个客户端正在进行的流式请求数
+type clientStreamCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+var clientStreams = &clientStreamCounter{counts: make(map[string]int)}
+
+// acquire 未达到上限时占用一个名额并返回 true
+func (s *clientStreamCounter) acquire(client string, limit int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts[client] >= limit {
+		return false
+	}
+	s.counts[client]++
+	return true
+}
+
+// release 释放一个名额，计数归零时删除条目避免 map 无限增长
+func (s *clientStreamCounter) release(client string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts[client] <= 1 {
+		delete(s.counts, client)
+		return
+	}
+	s.counts[client]--
+}
+
+// clientStreamIdentity 按 STREAM_CLIENT_IDENTITY 返回客户端标识（key 模式下未携带密钥时回退到 IP）
+func clientStreamIdentity(c *gin.Context, envCfg *config.EnvConfig) string {
+	if envCfg.StreamClientIdentity == "key" {
+		if key := middleware.ProvidedAPIKey(c); key != "" {
+			return "key:" + key
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// AcquireClientStream 在流式请求开始前占用客户端并发流名额（MAX_STREAMS_PER_CLIENT）
+// 超过上限时返回 429 且 ok 为 false，调用方应直接结束处理；成功时调用方需在流结束后调用 release
+func AcquireClientStream(c *gin.Context, envCfg *config.EnvConfig, apiType string) (release func(), ok bool) {
+	if envCfg == nil || envCfg.MaxStreamsPerClient <= 0 {
+		return func() {}, true
+	}
+
+	client := clientStreamIdentity(c, envCfg)
+	if !clientStreams.acquire(client, envCfg.MaxStreamsPerClient) {
+		logClient := c.ClientIP()
+		if envCfg.StreamClientIdentity == "key" {
+			logClient = utils.MaskAPIKey(middleware.ProvidedAPIKey(c))
+		}
+		log.Printf("[%s-StreamLimit] 客户端 %s 并发流式请求已达上限 %d，拒绝请求", apiType, logClient, envCfg.MaxStreamsPerClient)
+		c.JSON(429, gin.H{
+			"error": fmt.Sprintf("Too many concurrent streams for this client (limit %d)", envCfg.MaxStreamsPerClient),
+			"code":  "CLIENT_STREAM_LIMIT",
+		})
+		return nil, false
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { clientStreams.release(client) })
+	}, true
+}
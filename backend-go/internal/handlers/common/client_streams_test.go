@@ -0,0 +1,75 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestAcquireClientStream 超过单客户端并发流上限的请求返回 429，流结束释放后可再次建立
+func TestAcquireClientStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	envCfg := &config.EnvConfig{MaxStreamsPerClient: 2, StreamClientIdentity: "ip"}
+
+	newContext := func(remoteAddr string) (*gin.Context, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+		c.Request.RemoteAddr = remoteAddr
+		return c, w
+	}
+
+	var releases []func()
+	for i := 0; i < envCfg.MaxStreamsPerClient; i++ {
+		c, _ := newContext("203.0.113.10:40000")
+		release, ok := AcquireClientStream(c, envCfg, "Messages")
+		if !ok {
+			t.Fatalf("第 %d 个流应被放行", i+1)
+		}
+		releases = append(releases, release)
+	}
+
+	c, w := newContext("203.0.113.10:40001")
+	if _, ok := AcquireClientStream(c, envCfg, "Messages"); ok {
+		t.Fatal("超过上限的流应被拒绝")
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+
+	// 其他客户端不受影响
+	c, _ = newContext("203.0.113.11:40000")
+	otherRelease, ok := AcquireClientStream(c, envCfg, "Messages")
+	if !ok {
+		t.Fatal("其他客户端的流应被放行")
+	}
+	otherRelease()
+
+	// 重复调用 release 只释放一次名额
+	releases[0]()
+	releases[0]()
+	c, _ = newContext("203.0.113.10:40002")
+	release, ok := AcquireClientStream(c, envCfg, "Messages")
+	if !ok {
+		t.Fatal("释放后应可再次建立流")
+	}
+	c, _ = newContext("203.0.113.10:40003")
+	if _, ok := AcquireClientStream(c, envCfg, "Messages"); ok {
+		t.Fatal("重复 release 不应多释放名额")
+	}
+
+	release()
+	releases[1]()
+	if n := len(clientStreams.counts); n != 0 {
+		t.Fatalf("全部释放后计数条目应被清理，剩余 %d", n)
+	}
+
+	// 未配置上限时不限制
+	c, _ = newContext("203.0.113.10:40004")
+	if _, ok := AcquireClientStream(c, &config.EnvConfig{}, "Messages"); !ok {
+		t.Fatal("未配置上限时应放行")
+	}
+}
@@ -0,0 +1,104 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// coalescedHeader 共享其他请求响应时附加的响应头
+const coalescedHeader = "X-CCX-Coalesced"
+
+// RequestCoalescer 合并并发的相同非流式请求（single-flight）
+// 同一时刻同一调用方相同 (model, 请求体) 的请求只有第一个会真正发往上游，其余请求等待并共享其响应
+type RequestCoalescer struct {
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalescedCall 一次正在进行中的上游调用
+type coalescedCall struct {
+	done    chan struct{}
+	resp    *CachedResponse
+	waiters int
+}
+
+// NewRequestCoalescer 创建请求合并器（enabled 为 false 时返回 nil，表示禁用）
+func NewRequestCoalescer(enabled bool) *RequestCoalescer {
+	if !enabled {
+		return nil
+	}
+	return &RequestCoalescer{calls: make(map[string]*coalescedCall)}
+}
+
+// coalesceKey 按 (调用方, model, 请求体) 计算合并键
+// 合并键包含调用方身份（与幂等缓存一致），避免不同访问密钥的调用方共享彼此的响应
+func coalesceKey(apiType, caller, model string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(apiType))
+	h.Write([]byte{0})
+	h.Write([]byte(caller))
+	h.Write([]byte{0})
+	h.Write([]byte(model))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// BeginCoalescedRequest 处理非流式请求的合并
+// - 已有相同请求在进行中：等待其完成并回放响应，返回 handled=true
+// - 否则成为本次调用的发起者：包装 c.Writer 记录响应，调用方需在请求处理结束后调用 finish 唤醒等待者
+// 发起者未产生响应（如客户端断开）时，等待者各自独立处理请求
+// 合并器禁用时返回空操作的 finish
+func BeginCoalescedRequest(c *gin.Context, coalescer *RequestCoalescer, apiType, model string, body []byte) (handled bool, finish func()) {
+	if coalescer == nil {
+		return false, func() {}
+	}
+	key := coalesceKey(apiType, callerIdentity(c), model, body)
+
+	coalescer.mu.Lock()
+	if call, exists := coalescer.calls[key]; exists {
+		call.waiters++
+		coalescer.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-c.Request.Context().Done():
+			return true, nil
+		}
+		if call.resp == nil {
+			return false, func() {}
+		}
+		log.Printf("[%s-Coalesce] 共享进行中的相同请求的响应 (model: %s)", apiType, model)
+		c.Header(coalescedHeader, "true")
+		c.Data(call.resp.StatusCode, call.resp.ContentType, call.resp.Body)
+		return true, nil
+	}
+	call := &coalescedCall{done: make(chan struct{})}
+	coalescer.calls[key] = call
+	coalescer.mu.Unlock()
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	return false, func() {
+		coalescer.mu.Lock()
+		delete(coalescer.calls, key)
+		waiters := call.waiters
+		coalescer.mu.Unlock()
+
+		if recorder.Written() && recorder.body.Len() > 0 {
+			call.resp = &CachedResponse{
+				StatusCode:  recorder.Status(),
+				ContentType: recorder.Header().Get("Content-Type"),
+				Body:        append([]byte(nil), recorder.body.Bytes()...),
+			}
+		}
+		close(call.done)
+		if waiters > 0 {
+			log.Printf("[%s-Coalesce] 已合并 %d 个相同请求 (model: %s)", apiType, waiters, model)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestCoalesceKey_ScopedByCaller 不同访问密钥的相同请求不合并
+func TestCoalesceKey_ScopedByCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := []byte(`{"model":"claude-test"}`)
+
+	keyFor := func(apiKey string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+		c.Request.Header.Set("x-api-key", apiKey)
+		return coalesceKey("Messages", callerIdentity(c), "claude-test", body)
+	}
+
+	if keyFor("sk-a") != keyFor("sk-a") {
+		t.Fatal("同一调用方的相同请求应得到相同合并键")
+	}
+	if keyFor("sk-a") == keyFor("sk-b") {
+		t.Fatal("不同调用方的相同请求不应得到相同合并键")
+	}
+}
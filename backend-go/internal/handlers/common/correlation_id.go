@@ -0,0 +1,36 @@
+package common
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CorrelationIDHeader 代理请求的关联 ID 响应头（成功、failover 失败与错误响应均携带）
+// 与渠道日志、死信记录中的 ID 一致，用于将客户端反馈的问题对应到具体的上游尝试
+const CorrelationIDHeader = "X-CCX-Request-Id"
+
+// correlationIDContextKey 关联 ID 在 gin.Context 中的键
+const correlationIDContextKey = "ccx.correlationID"
+
+// EnsureCorrelationID 获取当前请求的关联 ID，首次调用时生成并写入响应头
+// 客户端携带 X-Request-ID 时沿用该值，便于与客户端自身日志对照
+func EnsureCorrelationID(c *gin.Context) string {
+	if id := CorrelationID(c); id != "" {
+		return id
+	}
+	id := c.GetHeader(RequestIDHeader)
+	if id == "" {
+		id = uuid.NewString()
+	}
+	c.Set(correlationIDContextKey, id)
+	c.Header(CorrelationIDHeader, id)
+	return id
+}
+
+// CorrelationID 读取当前请求已生成的关联 ID（未生成时返回空字符串，不修改响应头）
+func CorrelationID(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	return c.GetString(correlationIDContextKey)
+}
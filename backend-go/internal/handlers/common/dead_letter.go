@@ -0,0 +1,163 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader 客户端携带的请求 ID 请求头（作为关联 ID 沿用，死信记录时回写到响应头）
+const RequestIDHeader = "X-Request-ID"
+
+// failedAttemptsContextKey 单次请求失败的上游尝试在 gin.Context 中的键
+const failedAttemptsContextKey = "ccx.failedAttempts"
+
+// deadLetterRequestContextKey 发往渠道的请求体在 gin.Context 中的键（写入死信用于重放）
+const deadLetterRequestContextKey = "ccx.deadLetterRequest"
+
+// deadLetterTargetContextKey 写入死信所需的调度器、接口类型与模型在 gin.Context 中的键
+const deadLetterTargetContextKey = "ccx.deadLetterTarget"
+
+// deadLetterRecordedContextKey 本次请求已写入死信（或为死信重放请求，不再写入）的标记
+const deadLetterRecordedContextKey = "ccx.deadLetterRecorded"
+
+// deadLetterTarget 写入死信所需的请求上下文
+type deadLetterTarget struct {
+	channelScheduler *scheduler.ChannelScheduler
+	kind             scheduler.ChannelKind
+	model            string
+}
+
+// deadLetterRequest 死信重放所需的原始请求
+type deadLetterRequest struct {
+	body   []byte
+	stream bool
+}
+
+// maxDeadLetterErrorLen 死信中错误信息的最大长度
+const maxDeadLetterErrorLen = 500
+
+// maxDeadLetterBodySize 死信保存请求体的上限，超出时不保存（截断的请求体无法重放），避免环形缓冲区长期占用大量内存
+const maxDeadLetterBodySize = 256 * 1024
+
+// recordFailedAttempt 记录一次可 failover 的上游失败（所有渠道都失败时写入死信）
+func recordFailedAttempt(c *gin.Context, channelIndex int, upstream *config.UpstreamConfig, baseURL, apiKey string, statusCode int, errInfo string) {
+	if c == nil || upstream == nil {
+		return
+	}
+	attempts, _ := c.Get(failedAttemptsContextKey)
+	list, _ := attempts.([]metrics.DeadLetterAttempt)
+	c.Set(failedAttemptsContextKey, append(list, metrics.DeadLetterAttempt{
+		ChannelIndex: channelIndex,
+		ChannelName:  upstream.Name,
+		BaseURL:      baseURL,
+		KeyMask:      utils.MaskAPIKey(apiKey),
+		StatusCode:   statusCode,
+		ErrorInfo:    truncateDeadLetterError(errInfo),
+	}))
+}
+
+// rememberDeadLetterRequest 记录本次请求发往渠道的请求体（超过 maxDeadLetterBodySize 时不保存，死信标记为不可重放）
+func rememberDeadLetterRequest(c *gin.Context, requestBody []byte, isStream bool) {
+	if c == nil || len(requestBody) == 0 || len(requestBody) > maxDeadLetterBodySize {
+		return
+	}
+	c.Set(deadLetterRequestContextKey, deadLetterRequest{body: requestBody, stream: isStream})
+}
+
+// bindDeadLetterTarget 记录写入死信所需的调度器、接口类型与模型（多渠道调度入口与渠道内 Key 轮转入口调用）
+func bindDeadLetterTarget(c *gin.Context, channelScheduler *scheduler.ChannelScheduler, kind scheduler.ChannelKind, model string) {
+	if c == nil || channelScheduler == nil {
+		return
+	}
+	c.Set(deadLetterTargetContextKey, deadLetterTarget{channelScheduler: channelScheduler, kind: kind, model: model})
+}
+
+// RecordDeadLetter 所有渠道（或单渠道模式下所有 Key）都失败时写入死信，并将关联 ID 回写到响应头
+// 由各接口的"全部失败"处理函数调用；每个请求只写入一次，死信重放请求与未经过调度的请求不写入
+func RecordDeadLetter(c *gin.Context, apiType string, failoverErr *FailoverError, lastError error) {
+	if c == nil || c.GetBool(deadLetterRecordedContextKey) {
+		return
+	}
+	value, ok := c.Get(deadLetterTargetContextKey)
+	if !ok {
+		return
+	}
+	target, ok := value.(deadLetterTarget)
+	if !ok {
+		return
+	}
+	store := target.channelScheduler.GetDeadLetterStore()
+	if store == nil {
+		return
+	}
+	c.Set(deadLetterRecordedContextKey, true)
+	kind, model := target.kind, target.model
+
+	correlationID := EnsureCorrelationID(c)
+	c.Header(RequestIDHeader, correlationID)
+
+	entry := &metrics.DeadLetterEntry{
+		Timestamp:     time.Now(),
+		CorrelationID: correlationID,
+		Kind:          string(kind),
+		Model:         model,
+		StatusCode:    http.StatusServiceUnavailable,
+	}
+	if attempts, ok := c.Get(failedAttemptsContextKey); ok {
+		entry.Attempts, _ = attempts.([]metrics.DeadLetterAttempt)
+	}
+	if entry.Attempts == nil {
+		entry.Attempts = []metrics.DeadLetterAttempt{}
+	}
+	if value, ok := c.Get(deadLetterRequestContextKey); ok {
+		if request, ok := value.(deadLetterRequest); ok {
+			entry.RequestBody = request.body
+			entry.Stream = request.stream
+		}
+	}
+	switch {
+	case failoverErr != nil:
+		if failoverErr.Status != 0 {
+			entry.StatusCode = failoverErr.Status
+		}
+		entry.FinalError = truncateDeadLetterError(string(failoverErr.Body))
+	case lastError != nil:
+		entry.FinalError = truncateDeadLetterError(lastError.Error())
+	}
+
+	store.Record(entry)
+	log.Printf("[%s-DeadLetter] 请求 %s 所有渠道都失败，已记录死信 #%d (模型: %s, 尝试: %d)",
+		apiType, correlationID, entry.ID, model, len(entry.Attempts))
+}
+
+// truncateDeadLetterError 截断过长的错误信息
+func truncateDeadLetterError(errInfo string) string {
+	if len(errInfo) > maxDeadLetterErrorLen {
+		return fmt.Sprintf("%s...(truncated)", errInfo[:maxDeadLetterErrorLen])
+	}
+	return errInfo
+}
+
+// PrepareReplayRequest 将管理请求改写为重放用的客户端请求
+// 请求路径与请求体替换为死信中保存的原始请求，仅保留 Content-Type 头，避免管理密钥被带入上游请求；
+// 与 NewPreviewContext 不同，改写直接作用于 c，上游响应会写回管理请求
+func PrepareReplayRequest(c *gin.Context, path string, bodyBytes []byte) error {
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, path, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+	// 重放失败直接返回给管理请求，不再产生新的死信
+	c.Set(deadLetterRecordedContextKey, true)
+	return nil
+}
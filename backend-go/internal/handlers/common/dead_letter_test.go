@@ -0,0 +1,34 @@
+package common
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRememberDeadLetterRequest_SkipsOversizedBody 超过上限的请求体不保存，避免死信环形缓冲区占用大量内存
+func TestRememberDeadLetterRequest_SkipsOversizedBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		body     []byte
+		wantSave bool
+	}{
+		{name: "未超过上限", body: []byte(`{"model":"claude-test"}`), wantSave: true},
+		{name: "恰好等于上限", body: []byte(strings.Repeat("x", maxDeadLetterBodySize)), wantSave: true},
+		{name: "超过上限", body: []byte(strings.Repeat("x", maxDeadLetterBodySize+1)), wantSave: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			rememberDeadLetterRequest(c, tt.body, false)
+			if _, saved := c.Get(deadLetterRequestContextKey); saved != tt.wantSave {
+				t.Fatalf("saved = %v, want %v", saved, tt.wantSave)
+			}
+		})
+	}
+}
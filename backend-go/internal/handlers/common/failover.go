@@ -3,9 +3,14 @@
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
+	"net/http"
+	"slices"
 	"strings"
 
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,6 +18,108 @@
 type FailoverError struct {
 	Status int
 	Body   []byte
+	Header http.Header  // 上游响应头（用于向客户端转发限流相关头部）
+	Kind   FailoverKind // 失败类别（鉴权/限流/服务端等），调用方无需重新解析状态码与响应体
+
+	// ChannelKinds 多渠道 failover 全部失败时，各渠道最终失败的类别（按尝试顺序）
+	ChannelKinds []FailoverKind
+}
+
+// FailoverKind 故障转移错误类别
+type FailoverKind string
+
+const (
+	FailoverKindAuth           FailoverKind = "auth"
+	FailoverKindRateLimit      FailoverKind = "rate_limit"
+	FailoverKindServer         FailoverKind = "server"
+	FailoverKindTimeout        FailoverKind = "timeout"
+	FailoverKindOverloaded     FailoverKind = "overloaded"
+	FailoverKindInvalidRequest FailoverKind = "invalid_request"
+	FailoverKindOther          FailoverKind = "other"
+)
+
+// FailoverSummaryHeader 所有渠道失败时，按类别汇总各渠道失败情况的响应头
+const FailoverSummaryHeader = "X-CCX-Failover-Summary"
+
+// ClassifyFailoverKind 根据状态码与 ShouldRetryWithNextKey 返回的 isQuotaRelated 判定失败类别
+// 额度/配额相关的错误（含 fuzzy 模式下按响应体识别的额度错误）统一归为限流
+func ClassifyFailoverKind(statusCode int, isQuotaRelated bool) FailoverKind {
+	if isQuotaRelated {
+		return FailoverKindRateLimit
+	}
+	switch {
+	case statusCode == 401 || statusCode == 403:
+		return FailoverKindAuth
+	case statusCode == 402 || statusCode == 429:
+		return FailoverKindRateLimit
+	case statusCode == 408 || statusCode == 504:
+		return FailoverKindTimeout
+	case statusCode == 400 || statusCode == 422:
+		return FailoverKindInvalidRequest
+	case statusCode >= 500:
+		return FailoverKindServer
+	default:
+		return FailoverKindOther
+	}
+}
+
+// failoverKindLabels 汇总信息中各类别的展示顺序与文案（单数, 复数）
+var failoverKindLabels = []struct {
+	kind             FailoverKind
+	singular, plural string
+}{
+	{FailoverKindRateLimit, "channel rate-limited", "channels rate-limited"},
+	{FailoverKindAuth, "auth error", "auth errors"},
+	{FailoverKindServer, "server error", "server errors"},
+	{FailoverKindTimeout, "timeout", "timeouts"},
+	{FailoverKindOverloaded, "channel overloaded", "channels overloaded"},
+	{FailoverKindInvalidRequest, "invalid request", "invalid requests"},
+	{FailoverKindOther, "other error", "other errors"},
+}
+
+// SummarizeFailoverKinds 将各渠道的失败类别汇总为面向客户端的消息，如 "2 channels rate-limited, 1 auth error"
+// 未标注类别的错误计入 other；kinds 为空时返回空字符串
+func SummarizeFailoverKinds(kinds []FailoverKind) string {
+	counts := make(map[FailoverKind]int, len(kinds))
+	for _, kind := range kinds {
+		if kind == "" {
+			kind = FailoverKindOther
+		}
+		counts[kind]++
+	}
+
+	parts := make([]string, 0, len(counts))
+	for _, label := range failoverKindLabels {
+		n := counts[label.kind]
+		switch {
+		case n == 1:
+			parts = append(parts, "1 "+label.singular)
+		case n > 1:
+			parts = append(parts, fmt.Sprintf("%d %s", n, label.plural))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Summary 返回跨渠道的失败汇总消息（未经多渠道聚合时按自身类别汇总）
+func (e *FailoverError) Summary() string {
+	if e == nil {
+		return ""
+	}
+	if len(e.ChannelKinds) > 0 {
+		return SummarizeFailoverKinds(e.ChannelKinds)
+	}
+	if e.Kind == "" {
+		return ""
+	}
+	return SummarizeFailoverKinds([]FailoverKind{e.Kind})
+}
+
+// SetFailoverSummaryHeader 在响应中附加跨渠道失败汇总头（透传上游错误体时使用）
+func SetFailoverSummaryHeader(c *gin.Context, failoverErr *FailoverError) {
+	if summary := failoverErr.Summary(); summary != "" {
+		c.Header(FailoverSummaryHeader, summary)
+	}
 }
 
 // ShouldRetryWithNextKey 判断是否应该使用下一个密钥重试
@@ -29,13 +136,34 @@ type FailoverError struct {
 // isQuotaRelated 标记用于调度器优先级调整：
 //   - true: 额度/配额相关，降低密钥优先级
 //   - false: 临时错误，不影响优先级
-func ShouldRetryWithNextKey(statusCode int, bodyBytes []byte, fuzzyMode bool, apiType string) (bool, bool) {
+//
+// upstream 非空时优先使用渠道级状态码策略：
+//   - noFailoverStatusCodes 中的状态码直接返回客户端，不 failover
+//   - failoverStatusCodes 中的状态码强制 failover（配额标记沿用默认判断结果）
+func ShouldRetryWithNextKey(statusCode int, bodyBytes []byte, fuzzyMode bool, apiType string, upstream *config.UpstreamConfig) (bool, bool) {
 	log.Printf("[%s-Failover-Entry] ShouldRetryWithNextKey 入口: statusCode=%d, bodyLen=%d, fuzzyMode=%v",
 		apiType, statusCode, len(bodyBytes), fuzzyMode)
+
+	var forceFailover bool
+	if upstream != nil {
+		if slices.Contains(upstream.NoFailoverStatusCodes, statusCode) {
+			log.Printf("[%s-Failover-Policy] 状态码 %d 命中渠道 noFailoverStatusCodes，不进行 failover", apiType, statusCode)
+			return false, false
+		}
+		forceFailover = slices.Contains(upstream.FailoverStatusCodes, statusCode)
+	}
+
+	var shouldFailover, isQuotaRelated bool
 	if fuzzyMode {
-		return shouldRetryWithNextKeyFuzzy(statusCode, bodyBytes, apiType)
+		shouldFailover, isQuotaRelated = shouldRetryWithNextKeyFuzzy(statusCode, bodyBytes, apiType)
+	} else {
+		shouldFailover, isQuotaRelated = shouldRetryWithNextKeyNormal(statusCode, bodyBytes, apiType)
+	}
+	if forceFailover && !shouldFailover {
+		log.Printf("[%s-Failover-Policy] 状态码 %d 命中渠道 failoverStatusCodes，强制 failover", apiType, statusCode)
+		return true, false
 	}
-	return shouldRetryWithNextKeyNormal(statusCode, bodyBytes, apiType)
+	return shouldFailover, isQuotaRelated
 }
 
 // shouldRetryWithNextKeyFuzzy Fuzzy 模式：大多数非 2xx 错误都尝试 failover
@@ -385,24 +513,32 @@ func isSchemaValidationMessage(msgLower string) bool {
 // lastError: 最后一个错误
 // apiType: API 类型（用于错误消息）
 func HandleAllChannelsFailed(c *gin.Context, fuzzyMode bool, lastFailoverError *FailoverError, lastError error, apiType string) {
+	RecordDeadLetter(c, apiType, lastFailoverError, lastError)
+
 	// Fuzzy 模式下返回通用错误，不透传上游详情
 	if fuzzyMode {
+		message := "All upstream channels are currently unavailable"
+		if summary := lastFailoverError.Summary(); summary != "" {
+			message += " (" + summary + ")"
+		}
 		c.JSON(503, gin.H{
 			"type": "error",
 			"error": gin.H{
 				"type":    "service_unavailable",
-				"message": "All upstream channels are currently unavailable",
+				"message": message,
 			},
 		})
 		return
 	}
 
-	// 非 Fuzzy 模式：透传最后一个错误的详情
+	// 非 Fuzzy 模式：透传最后一个错误的详情，失败类别汇总通过响应头提供
 	if lastFailoverError != nil {
 		status := lastFailoverError.Status
 		if status == 0 {
 			status = 503
 		}
+		utils.ForwardAllowlistedResponseHeaders(lastFailoverError.Header, c.Writer)
+		SetFailoverSummaryHeader(c, lastFailoverError)
 		var errBody map[string]interface{}
 		if err := json.Unmarshal(lastFailoverError.Body, &errBody); err == nil {
 			c.JSON(status, errBody)
@@ -423,6 +559,8 @@ func HandleAllChannelsFailed(c *gin.Context, fuzzyMode bool, lastFailoverError *
 
 // HandleAllKeysFailed 处理所有密钥都失败的情况（单渠道模式）
 func HandleAllKeysFailed(c *gin.Context, fuzzyMode bool, lastFailoverError *FailoverError, lastError error, apiType string) {
+	RecordDeadLetter(c, apiType, lastFailoverError, lastError)
+
 	// Fuzzy 模式下返回通用错误
 	if fuzzyMode {
 		c.JSON(503, gin.H{
@@ -441,6 +579,7 @@ func HandleAllKeysFailed(c *gin.Context, fuzzyMode bool, lastFailoverError *Fail
 		if status == 0 {
 			status = 500
 		}
+		utils.ForwardAllowlistedResponseHeaders(lastFailoverError.Header, c.Writer)
 		var errBody map[string]interface{}
 		if err := json.Unmarshal(lastFailoverError.Body, &errBody); err == nil {
 			c.JSON(status, errBody)
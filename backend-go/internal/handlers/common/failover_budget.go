@@ -0,0 +1,49 @@
+package common
+
+import (
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// failoverAttemptsContextKey 单次请求的上游尝试计数在 gin.Context 中的键
+const failoverAttemptsContextKey = "ccx.failoverAttempts"
+
+// failoverAttemptCounter 单次请求跨渠道的上游尝试计数（同一请求内串行使用，无需加锁）
+type failoverAttemptCounter struct {
+	used  int
+	limit int
+}
+
+// getFailoverAttemptCounter 获取（必要时创建）当前请求的尝试计数，未配置上限时返回 nil
+func getFailoverAttemptCounter(c *gin.Context, envCfg *config.EnvConfig) *failoverAttemptCounter {
+	if c == nil || envCfg == nil || envCfg.MaxFailoverAttempts <= 0 {
+		return nil
+	}
+	if v, ok := c.Get(failoverAttemptsContextKey); ok {
+		if counter, ok := v.(*failoverAttemptCounter); ok {
+			return counter
+		}
+	}
+	counter := &failoverAttemptCounter{limit: envCfg.MaxFailoverAttempts}
+	c.Set(failoverAttemptsContextKey, counter)
+	return counter
+}
+
+// consumeFailoverAttempt 发起上游请求前占用一次尝试额度，额度耗尽时返回 false
+func consumeFailoverAttempt(c *gin.Context, envCfg *config.EnvConfig) bool {
+	counter := getFailoverAttemptCounter(c, envCfg)
+	if counter == nil {
+		return true
+	}
+	if counter.used >= counter.limit {
+		return false
+	}
+	counter.used++
+	return true
+}
+
+// failoverAttemptsExhausted 当前请求的上游尝试额度是否已耗尽（未配置上限时恒为 false）
+func failoverAttemptsExhausted(c *gin.Context, envCfg *config.EnvConfig) bool {
+	counter := getFailoverAttemptCounter(c, envCfg)
+	return counter != nil && counter.used >= counter.limit
+}
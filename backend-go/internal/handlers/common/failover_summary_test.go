@@ -0,0 +1,102 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestClassifyFailoverKind 状态码与配额标记映射到失败类别
+func TestClassifyFailoverKind(t *testing.T) {
+	tests := []struct {
+		status int
+		quota  bool
+		want   FailoverKind
+	}{
+		{401, false, FailoverKindAuth},
+		{403, false, FailoverKindAuth},
+		{403, true, FailoverKindRateLimit},
+		{429, true, FailoverKindRateLimit},
+		{402, false, FailoverKindRateLimit},
+		{408, false, FailoverKindTimeout},
+		{400, false, FailoverKindInvalidRequest},
+		{500, false, FailoverKindServer},
+		{503, false, FailoverKindServer},
+		{404, false, FailoverKindOther},
+	}
+	for _, tt := range tests {
+		if got := ClassifyFailoverKind(tt.status, tt.quota); got != tt.want {
+			t.Errorf("ClassifyFailoverKind(%d, %v) = %q, want %q", tt.status, tt.quota, got, tt.want)
+		}
+	}
+}
+
+// TestSummarizeFailoverKinds 汇总消息反映各渠道失败类别的组合
+func TestSummarizeFailoverKinds(t *testing.T) {
+	tests := []struct {
+		name  string
+		kinds []FailoverKind
+		want  string
+	}{
+		{"空", nil, ""},
+		{"限流与鉴权混合", []FailoverKind{FailoverKindRateLimit, FailoverKindAuth, FailoverKindRateLimit}, "2 channels rate-limited, 1 auth error"},
+		{"单一服务端错误", []FailoverKind{FailoverKindServer}, "1 server error"},
+		{"未标注类别计入 other", []FailoverKind{"", FailoverKindTimeout, FailoverKindOther}, "1 timeout, 2 other errors"},
+		{"多类别按固定顺序", []FailoverKind{FailoverKindOverloaded, FailoverKindServer, FailoverKindAuth, FailoverKindAuth}, "2 auth errors, 1 server error, 1 channel overloaded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SummarizeFailoverKinds(tt.kinds); got != tt.want {
+				t.Fatalf("SummarizeFailoverKinds() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHandleAllChannelsFailed_FailoverSummary 所有渠道失败时响应中携带跨渠道失败汇总
+func TestHandleAllChannelsFailed_FailoverSummary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	failoverErr := &FailoverError{
+		Status:       http.StatusTooManyRequests,
+		Body:         []byte(`{"error":{"type":"rate_limit_error","message":"rate limited"}}`),
+		Kind:         FailoverKindRateLimit,
+		ChannelKinds: []FailoverKind{FailoverKindRateLimit, FailoverKindAuth, FailoverKindRateLimit},
+	}
+	const wantSummary = "2 channels rate-limited, 1 auth error"
+
+	t.Run("透传模式通过响应头提供汇总", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		HandleAllChannelsFailed(c, false, failoverErr, nil, "Messages")
+
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("status = %d, want 429", w.Code)
+		}
+		if got := w.Header().Get(FailoverSummaryHeader); got != wantSummary {
+			t.Fatalf("%s = %q, want %q", FailoverSummaryHeader, got, wantSummary)
+		}
+	})
+
+	t.Run("fuzzy 模式在通用错误消息中附带汇总", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		HandleAllChannelsFailed(c, true, failoverErr, nil, "Messages")
+
+		var resp struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("解析响应失败: %v", err)
+		}
+		want := "All upstream channels are currently unavailable (" + wantSummary + ")"
+		if resp.Error.Message != want {
+			t.Fatalf("message = %q, want %q", resp.Error.Message, want)
+		}
+	})
+}
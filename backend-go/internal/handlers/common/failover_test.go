@@ -3,6 +3,8 @@
 import (
 	"encoding/json"
 	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
 )
 
 // TestClassifyByStatusCode 测试基于状态码的分类
@@ -329,7 +331,7 @@ func TestShouldRetryWithNextKey_403WithPredeductQuotaError(t *testing.T) {
 	// 使用生产环境的精确 JSON 格式
 	body := []byte(`{"error":{"type":"new_api_error","message":"预扣费额度失败, 用户剩余额度: ¥0.053950, 需要预扣费额度: ¥0.191160, 下次重置时间: 2025-01-01 00:00:00"},"type":"error"}`)
 
-	gotFailover, gotQuota := ShouldRetryWithNextKey(403, body, false, "Messages")
+	gotFailover, gotQuota := ShouldRetryWithNextKey(403, body, false, "Messages", nil)
 
 	if !gotFailover {
 		t.Errorf("ShouldRetryWithNextKey(403, prededuct_error, false) failover = %v, want true", gotFailover)
@@ -485,7 +487,7 @@ func TestShouldRetryWithNextKey(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			bodyBytes, _ := json.Marshal(tt.body)
 			// 测试非 Fuzzy 模式（精确错误分类）
-			gotFailover, gotQuota := ShouldRetryWithNextKey(tt.statusCode, bodyBytes, false, "Messages")
+			gotFailover, gotQuota := ShouldRetryWithNextKey(tt.statusCode, bodyBytes, false, "Messages", nil)
 			if gotFailover != tt.wantFailover {
 				t.Errorf("shouldRetryWithNextKey(%d, ..., false) failover = %v, want %v", tt.statusCode, gotFailover, tt.wantFailover)
 			}
@@ -598,7 +600,7 @@ func TestShouldRetryWithNextKeyFuzzyMode(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// 测试 Fuzzy 模式（所有非 2xx 都 failover）
-			gotFailover, gotQuota := ShouldRetryWithNextKey(tt.statusCode, nil, true, "Messages")
+			gotFailover, gotQuota := ShouldRetryWithNextKey(tt.statusCode, nil, true, "Messages", nil)
 			if gotFailover != tt.wantFailover {
 				t.Errorf("shouldRetryWithNextKey(%d, nil, true) failover = %v, want %v", tt.statusCode, gotFailover, tt.wantFailover)
 			}
@@ -658,7 +660,7 @@ func TestShouldRetryWithNextKey_FuzzyMode_403WithQuotaMessage(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotFailover, gotQuota := ShouldRetryWithNextKey(tt.statusCode, tt.body, true, "Messages")
+			gotFailover, gotQuota := ShouldRetryWithNextKey(tt.statusCode, tt.body, true, "Messages", nil)
 			if gotFailover != tt.wantFailover {
 				t.Errorf("ShouldRetryWithNextKey(%d, body, true) failover = %v, want %v", tt.statusCode, gotFailover, tt.wantFailover)
 			}
@@ -686,7 +688,7 @@ func TestShouldRetryWithNextKey_FuzzyMode_InvalidRequestShouldNotFailover(t *tes
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotFailover, gotQuota := ShouldRetryWithNextKey(400, tt.body, true, "Messages")
+			gotFailover, gotQuota := ShouldRetryWithNextKey(400, tt.body, true, "Messages", nil)
 			if gotFailover {
 				t.Errorf("ShouldRetryWithNextKey(400, invalid_request_body, true) failover = %v, want false", gotFailover)
 			}
@@ -776,7 +778,7 @@ func TestShouldRetryWithNextKey_SensitiveWordsDetected(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotFailover, gotQuota := ShouldRetryWithNextKey(tt.statusCode, body, tt.fuzzyMode, "Messages")
+			gotFailover, gotQuota := ShouldRetryWithNextKey(tt.statusCode, body, tt.fuzzyMode, "Messages", nil)
 			if gotFailover != tt.wantFailover {
 				t.Errorf("ShouldRetryWithNextKey(%d, sensitive_words_body, %v) failover = %v, want %v",
 					tt.statusCode, tt.fuzzyMode, gotFailover, tt.wantFailover)
@@ -788,3 +790,40 @@ func TestShouldRetryWithNextKey_SensitiveWordsDetected(t *testing.T) {
 		})
 	}
 }
+
+// TestShouldRetryWithNextKey_UpstreamStatusPolicy 测试渠道级 failover 状态码策略覆盖默认判断
+func TestShouldRetryWithNextKey_UpstreamStatusPolicy(t *testing.T) {
+	upstream := &config.UpstreamConfig{
+		FailoverStatusCodes:   []int{404},
+		NoFailoverStatusCodes: []int{429},
+	}
+	notFoundBody := []byte(`{"error":{"type":"not_found_error","message":"model not found"}}`)
+	rateLimitBody := []byte(`{"error":{"type":"rate_limit_error","message":"rate limited"}}`)
+
+	tests := []struct {
+		name         string
+		statusCode   int
+		body         []byte
+		fuzzyMode    bool
+		upstream     *config.UpstreamConfig
+		wantFailover bool
+		wantQuota    bool
+	}{
+		{name: "默认 404 不 failover", statusCode: 404, body: notFoundBody, wantFailover: false},
+		{name: "配置 404 触发 failover", statusCode: 404, body: notFoundBody, upstream: upstream, wantFailover: true},
+		{name: "默认 429 failover", statusCode: 429, body: rateLimitBody, wantFailover: true, wantQuota: true},
+		{name: "配置 429 不 failover", statusCode: 429, body: rateLimitBody, upstream: upstream, wantFailover: false},
+		{name: "配置 429 在 fuzzy 模式下同样不 failover", statusCode: 429, body: rateLimitBody, fuzzyMode: true, upstream: upstream, wantFailover: false},
+		{name: "未配置的状态码沿用默认逻辑", statusCode: 500, upstream: upstream, wantFailover: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotFailover, gotQuota := ShouldRetryWithNextKey(tt.statusCode, tt.body, tt.fuzzyMode, "Messages", tt.upstream)
+			if gotFailover != tt.wantFailover || gotQuota != tt.wantQuota {
+				t.Errorf("ShouldRetryWithNextKey(%d) = (%v, %v), want (%v, %v)",
+					tt.statusCode, gotFailover, gotQuota, tt.wantFailover, tt.wantQuota)
+			}
+		})
+	}
+}
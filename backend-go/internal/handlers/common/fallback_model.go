@@ -0,0 +1,40 @@
+package common
+
+import (
+	"log"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/sjson"
+)
+
+// FallbackModelHeader 请求模型被替换为全局回退模型时，在响应头中标注实际使用的模型
+const FallbackModelHeader = "X-CCX-Fallback-Model"
+
+// ApplyFallbackModel 请求模型没有任何渠道支持（supportedModels 过滤后为空）时替换为 FALLBACK_MODEL
+// 仅在配置了回退模型、回退模型通过该接口类型的模型访问控制且存在支持回退模型的渠道时生效；替换后改写请求体中的 model 字段
+// 返回（可能被改写的）请求体与实际使用的模型
+func ApplyFallbackModel(c *gin.Context, envCfg *config.EnvConfig, cfgManager *config.ConfigManager, channelScheduler *scheduler.ChannelScheduler, kind scheduler.ChannelKind, apiType string, bodyBytes []byte, model string) ([]byte, string) {
+	fallback := envCfg.FallbackModel
+	if fallback == "" || model == "" || model == fallback {
+		return bodyBytes, model
+	}
+	if channelScheduler.HasChannelForModel(kind, model) || !channelScheduler.HasChannelForModel(kind, fallback) {
+		return bodyBytes, model
+	}
+	// 回退模型被 allowedModels/deniedModels 拒绝时不替换，避免绕过访问控制
+	if allowed, reason := cfgManager.CheckModelAccess(string(kind), fallback); !allowed {
+		log.Printf("[%s-FallbackModel] 回退模型 %s 被访问控制拒绝（%s），保持原模型 %s", apiType, fallback, reason, model)
+		return bodyBytes, model
+	}
+
+	rewritten, err := sjson.SetBytes(bodyBytes, "model", fallback)
+	if err != nil {
+		log.Printf("[%s-FallbackModel] 警告: 改写请求模型失败，保持原模型 %s: %v", apiType, model, err)
+		return bodyBytes, model
+	}
+	log.Printf("[%s-FallbackModel] 没有渠道支持模型 %s，替换为回退模型 %s", apiType, model, fallback)
+	c.Header(FallbackModelHeader, fallback)
+	return rewritten, fallback
+}
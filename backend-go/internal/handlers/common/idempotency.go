@@ -0,0 +1,183 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader 客户端重试时携带的幂等键请求头
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyReplayedHeader 命中幂等缓存时附加的响应头
+const idempotencyReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotentBodySize 单条幂等缓存响应体上限，超出时不缓存，避免大响应长期占用内存
+const maxIdempotentBodySize = 1 << 20
+
+// CachedResponse 幂等缓存中保存的已完成响应
+type CachedResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+// IdempotencyCache 按 Idempotency-Key 缓存已完成的非流式响应
+// 客户端在 TTL 内使用相同 Key 重试时直接回放缓存，避免重复向上游计费
+type IdempotencyCache struct {
+	mu        sync.Mutex
+	entries   map[string]*CachedResponse
+	ttl       time.Duration
+	lastSweep time.Time
+}
+
+// NewIdempotencyCache 创建幂等缓存（ttl <= 0 时返回 nil，表示禁用）
+func NewIdempotencyCache(ttl time.Duration) *IdempotencyCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &IdempotencyCache{
+		entries:   make(map[string]*CachedResponse),
+		ttl:       ttl,
+		lastSweep: time.Now(),
+	}
+}
+
+// Get 获取未过期的缓存响应
+func (ic *IdempotencyCache) Get(key string) (*CachedResponse, bool) {
+	if ic == nil || key == "" {
+		return nil, false
+	}
+
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	entry, exists := ic.entries[key]
+	if !exists {
+		return nil, false
+	}
+	if time.Now().After(entry.ExpiresAt) {
+		delete(ic.entries, key)
+		return nil, false
+	}
+	return entry, true
+}
+
+// Set 写入缓存响应，并按 TTL 周期清理过期条目
+func (ic *IdempotencyCache) Set(key string, statusCode int, contentType string, body []byte) {
+	if ic == nil || key == "" {
+		return
+	}
+
+	now := time.Now()
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	if now.Sub(ic.lastSweep) >= ic.ttl {
+		for k, entry := range ic.entries {
+			if now.After(entry.ExpiresAt) {
+				delete(ic.entries, k)
+			}
+		}
+		ic.lastSweep = now
+	}
+
+	ic.entries[key] = &CachedResponse{
+		StatusCode:  statusCode,
+		ContentType: contentType,
+		Body:        append([]byte(nil), body...),
+		ExpiresAt:   now.Add(ic.ttl),
+	}
+}
+
+// BeginIdempotentRequest 处理非流式请求的 Idempotency-Key
+// - 命中缓存：直接回放缓存响应，返回 handled=true
+// - 未命中：包装 c.Writer 记录响应，调用方需在请求处理结束后调用 finish 写入缓存（仅缓存 200 且不超过上限的响应）
+// 缓存键包含调用方标识与请求体哈希：不同调用方或不同请求体复用同一 Key 时不会回放他人/其他请求的响应
+// 未携带请求头或缓存禁用时返回空操作的 finish
+func BeginIdempotentRequest(c *gin.Context, cache *IdempotencyCache, apiType string, body []byte) (handled bool, finish func()) {
+	idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+	if cache == nil || idempotencyKey == "" {
+		return false, func() {}
+	}
+	cacheKey := idempotencyCacheKey(apiType, callerIdentity(c), idempotencyKey, body)
+
+	if cached, ok := cache.Get(cacheKey); ok {
+		log.Printf("[%s-Idempotency] 命中幂等缓存，回放已完成的响应 (Key: %s)", apiType, idempotencyKey)
+		c.Header(idempotencyReplayedHeader, "true")
+		c.Data(cached.StatusCode, cached.ContentType, cached.Body)
+		return true, nil
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer, limit: maxIdempotentBodySize}
+	c.Writer = recorder
+	return false, func() {
+		if recorder.Status() != http.StatusOK || recorder.body.Len() == 0 || recorder.overflowed {
+			return
+		}
+		cache.Set(cacheKey, recorder.Status(), recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+	}
+}
+
+// idempotencyCacheKey 幂等缓存键：apiType + 调用方标识 + Idempotency-Key + 请求体的 SHA-256
+func idempotencyCacheKey(apiType, caller, idempotencyKey string, body []byte) string {
+	h := sha256.New()
+	for _, part := range []string{apiType, caller, idempotencyKey} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// callerIdentity 调用方标识：优先取客户端携带的访问密钥，未携带时回退到客户端 IP
+func callerIdentity(c *gin.Context) string {
+	if key := middleware.ProvidedAPIKey(c); key != "" {
+		return "key:" + key
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// responseRecorder 透传写入的同时记录响应体
+// limit > 0 时记录超出上限即停止记录并标记 overflowed（响应仍完整透传给客户端）
+type responseRecorder struct {
+	gin.ResponseWriter
+	body       bytes.Buffer
+	limit      int
+	overflowed bool
+}
+
+// Write 实现 io.Writer
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.record(data[:n])
+	return n, err
+}
+
+// WriteString 实现 io.StringWriter
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.record([]byte(s[:n]))
+	return n, err
+}
+
+// record 追加已写入的数据，超出上限时释放已记录内容
+func (w *responseRecorder) record(data []byte) {
+	if w.overflowed {
+		return
+	}
+	if w.limit > 0 && w.body.Len()+len(data) > w.limit {
+		w.overflowed = true
+		w.body = bytes.Buffer{}
+		return
+	}
+	w.body.Write(data)
+}
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotencyCache_ExpiresByTTL(t *testing.T) {
+	cache := NewIdempotencyCache(50 * time.Millisecond)
+	cache.Set("k", 200, "application/json", []byte(`{"ok":true}`))
+
+	cached, ok := cache.Get("k")
+	if !ok || string(cached.Body) != `{"ok":true}` {
+		t.Fatalf("Get() = %+v, %v, want cached body", cached, ok)
+	}
+
+	time.Sleep(80 * time.Millisecond)
+	if _, ok := cache.Get("k"); ok {
+		t.Fatal("TTL 过期后 Get() 应未命中")
+	}
+}
+
+func TestNewIdempotencyCache_DisabledWhenTTLNotPositive(t *testing.T) {
+	cache := NewIdempotencyCache(0)
+	cache.Set("k", 200, "application/json", []byte("x"))
+	if _, ok := cache.Get("k"); ok {
+		t.Fatal("禁用的缓存不应命中")
+	}
+}
+
+// TestBeginIdempotentRequest_ScopedByCallerAndSize 不同调用方不共享缓存；超过上限的响应不缓存
+func TestBeginIdempotentRequest_ScopedByCallerAndSize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := []byte(`{"model":"claude-test"}`)
+
+	tests := []struct {
+		name       string
+		respBody   string
+		replayKey  string
+		wantReplay bool
+	}{
+		{name: "同一调用方回放", respBody: `{"ok":true}`, replayKey: "sk-a", wantReplay: true},
+		{name: "不同调用方不回放", respBody: `{"ok":true}`, replayKey: "sk-b", wantReplay: false},
+		{name: "超过上限不缓存", respBody: strings.Repeat("x", maxIdempotentBodySize+1), replayKey: "sk-a", wantReplay: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewIdempotencyCache(time.Minute)
+			newContext := func(apiKey string) (*gin.Context, *httptest.ResponseRecorder) {
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+				c.Request.Header.Set(IdempotencyKeyHeader, "retry-1")
+				c.Request.Header.Set("x-api-key", apiKey)
+				return c, w
+			}
+
+			c, _ := newContext("sk-a")
+			handled, finish := BeginIdempotentRequest(c, cache, "Messages", body)
+			if handled {
+				t.Fatal("首次请求不应命中缓存")
+			}
+			c.Data(http.StatusOK, "application/json", []byte(tt.respBody))
+			finish()
+
+			c, w := newContext(tt.replayKey)
+			handled, _ = BeginIdempotentRequest(c, cache, "Messages", body)
+			if handled != tt.wantReplay {
+				t.Fatalf("handled = %v, want %v", handled, tt.wantReplay)
+			}
+			if tt.wantReplay && w.Body.String() != tt.respBody {
+				t.Fatalf("回放响应 = %s, want %s", w.Body.String(), tt.respBody)
+			}
+		})
+	}
+}
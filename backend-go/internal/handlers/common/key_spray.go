@@ -0,0 +1,153 @@
+package common
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/utils"
+)
+
+// keySprayCount 渠道实际的 Key 并行竞速数（keySprayCount 受 MAX_KEY_SPRAY_CONCURRENCY 限制），<=1 表示不启用
+func keySprayCount(upstream *config.UpstreamConfig, envCfg *config.EnvConfig) int {
+	if upstream == nil || envCfg == nil || upstream.KeySprayCount <= 1 || envCfg.MaxKeySprayConcurrency <= 1 {
+		return 0
+	}
+	return min(upstream.KeySprayCount, envCfg.MaxKeySprayConcurrency)
+}
+
+// keySprayAttempt 竞速中的单个 Key 请求
+type keySprayAttempt struct {
+	apiKey       string
+	upstreamCopy *config.UpstreamConfig
+	req          *http.Request
+	requestID    uint64
+	attemptStart time.Time
+	cancel       context.CancelFunc
+	resp         *http.Response
+	err          error
+}
+
+// keySpray 同一 BaseURL 下并行发出的一组 Key 请求
+// 结果按完成先后依次交给 failover 主循环处理：失败结果按正常失败记录，
+// 首个成功结果胜出后取消其余请求，未被处理的请求在后台回收并按客户端取消统计
+type keySpray struct {
+	apiType          string
+	baseURL          string
+	kind             scheduler.ChannelKind
+	metricsManager   *metrics.MetricsManager
+	channelScheduler *scheduler.ChannelScheduler
+
+	attempts []*keySprayAttempt
+	results  chan *keySprayAttempt
+	received int
+	settled  bool
+	once     sync.Once
+}
+
+func newKeySpray(apiType, baseURL string, kind scheduler.ChannelKind, metricsManager *metrics.MetricsManager, channelScheduler *scheduler.ChannelScheduler) *keySpray {
+	return &keySpray{
+		apiType:          apiType,
+		baseURL:          baseURL,
+		kind:             kind,
+		metricsManager:   metricsManager,
+		channelScheduler: channelScheduler,
+	}
+}
+
+// add 加入一个已构建好的请求（launch 前调用）
+func (s *keySpray) add(req *http.Request, upstreamCopy *config.UpstreamConfig, apiKey string) {
+	s.attempts = append(s.attempts, &keySprayAttempt{apiKey: apiKey, upstreamCopy: upstreamCopy, req: req})
+}
+
+// size 已加入的请求数
+func (s *keySpray) size() int {
+	return len(s.attempts)
+}
+
+// launch 记录请求开始并同时发出全部请求，每个请求使用独立的可取消 context
+func (s *keySpray) launch(parent context.Context, model string, send func(req *http.Request) (*http.Response, error)) {
+	if len(s.attempts) == 0 {
+		return
+	}
+	s.results = make(chan *keySprayAttempt, len(s.attempts))
+	for _, attempt := range s.attempts {
+		ctx, cancel := context.WithCancel(parent)
+		attempt.cancel = cancel
+		attempt.req = attempt.req.WithContext(ctx)
+
+		s.channelScheduler.RecordRequestStart(s.baseURL, attempt.apiKey, s.kind)
+		attempt.requestID = s.metricsManager.RecordRequestConnected(s.baseURL, attempt.apiKey, model)
+		attempt.attemptStart = time.Now()
+		go func(attempt *keySprayAttempt) {
+			attempt.resp, attempt.err = send(attempt.req)
+			s.results <- attempt
+		}(attempt)
+	}
+	log.Printf("[%s-KeySpray] 并行向 %d 个 Key 发起请求", s.apiType, len(s.attempts))
+}
+
+// pending 是否还有待处理的竞速结果（胜者确定后不再交出结果）
+func (s *keySpray) pending() bool {
+	return s != nil && !s.settled && s.received < len(s.attempts)
+}
+
+// next 阻塞等待下一个完成的请求
+func (s *keySpray) next() *keySprayAttempt {
+	attempt := <-s.results
+	s.received++
+	return attempt
+}
+
+// settle 确定胜者（winner 为 nil 表示放弃整组竞速），取消其余进行中的请求并在后台回收
+// 被取消的请求计入请求数但不计入失败，与客户端主动取消的统计口径一致
+func (s *keySpray) settle(winner *keySprayAttempt) {
+	if s == nil || s.settled {
+		return
+	}
+	s.settled = true
+
+	for _, attempt := range s.attempts {
+		if attempt != winner && attempt.cancel != nil {
+			attempt.cancel()
+		}
+	}
+	remaining := len(s.attempts) - s.received
+	if remaining == 0 || s.results == nil {
+		return
+	}
+	if winner != nil {
+		log.Printf("[%s-KeySpray] Key %s 最先成功，取消其余 %d 个请求", s.apiType, utils.MaskAPIKey(winner.apiKey), remaining)
+	}
+
+	go func() {
+		for i := 0; i < remaining; i++ {
+			loser := <-s.results
+			if loser.resp != nil {
+				loser.resp.Body.Close()
+			}
+			s.metricsManager.RecordRequestFinalizeClientCancel(s.baseURL, loser.apiKey, loser.requestID)
+			s.channelScheduler.RecordRequestEnd(s.baseURL, loser.apiKey, s.kind)
+		}
+	}()
+}
+
+// close 放弃未处理的请求并释放全部 context（含胜者，需在胜者响应处理完成后调用）
+func (s *keySpray) close() {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() {
+		s.settle(nil)
+		for _, attempt := range s.attempts {
+			if attempt.cancel != nil {
+				attempt.cancel()
+			}
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/session"
+	"github.com/BenedictKing/ccx/internal/types"
+	"github.com/BenedictKing/ccx/internal/warmup"
+	"github.com/gin-gonic/gin"
+)
+
+// TestTryUpstreamWithAllKeys_KeySpray 竞速模式下最先成功的 Key 胜出，先失败的 Key 计入失败，
+// 仍在进行的 Key 被取消并按客户端取消统计；超出并发上限的 Key 不会被请求
+func TestTryUpstreamWithAllKeys_KeySpray(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	brokenServed := make(chan struct{})
+	slowCanceled := make(chan struct{})
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("x-api-key")
+		mu.Lock()
+		hits[key]++
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch key {
+		case "sk-broken":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"type":"overloaded_error","message":"overloaded"}}`))
+			w.(http.Flusher).Flush()
+			close(brokenServed)
+		case "sk-fast":
+			// 保证失败的 Key 先于胜者返回
+			<-brokenServed
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte(`{"winner":"sk-fast"}`))
+		default:
+			// 读完请求体后服务端才能感知连接关闭
+			io.ReadAll(r.Body)
+			select {
+			case <-r.Context().Done():
+				close(slowCanceled)
+			case <-time.After(10 * time.Second):
+			}
+		}
+	}))
+	defer server.Close()
+
+	upstream := &config.UpstreamConfig{
+		Name:          "spray",
+		BaseURL:       server.URL,
+		APIKeys:       []string{"sk-slow", "sk-broken", "sk-fast", "sk-unused"},
+		ServiceType:   "claude",
+		KeySprayCount: 5,
+	}
+	data, _ := json.Marshal(config.Config{Upstream: []config.UpstreamConfig{*upstream}})
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	cfgManager, err := config.NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("创建配置管理器失败: %v", err)
+	}
+	t.Cleanup(func() { cfgManager.Close() })
+
+	metricsManager := metrics.NewMetricsManager()
+	defer metricsManager.Stop()
+	sch := scheduler.NewChannelScheduler(cfgManager, metricsManager, metrics.NewMetricsManager(), metrics.NewMetricsManager(), metrics.NewMetricsManager(), session.NewTraceAffinityManager(), warmup.NewURLManager(30*time.Second, 3))
+
+	envCfg := &config.EnvConfig{MaxKeySprayConcurrency: 3, RequestTimeout: 10000, MaxResponseBodySize: 1 << 20}
+	nextAPIKey := func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error) {
+		for _, key := range upstream.APIKeys {
+			if !failedKeys[key] {
+				return key, nil
+			}
+		}
+		return "", errors.New("no keys")
+	}
+	buildRequest := func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, upstreamCopy.BaseURL+"/v1/messages", c.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("x-api-key", apiKey)
+		return req, nil
+	}
+	handleSuccess := func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, apiKey string) (*types.Usage, error) {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		c.Data(resp.StatusCode, "application/json", body)
+		return nil, nil
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+
+	handled, successKey, _, _, _, lastErr := TryUpstreamWithAllKeys(
+		c, envCfg, cfgManager, sch, scheduler.ChannelKindMessages, "Messages", metricsManager,
+		upstream, BuildDefaultURLResults([]string{server.URL}), []byte(`{}`), false,
+		nextAPIKey, buildRequest, nil, nil, nil, handleSuccess, "claude-test", 0, nil,
+	)
+	if !handled || successKey != "sk-fast" || lastErr != nil {
+		t.Fatalf("TryUpstreamWithAllKeys() = handled %v, key %q, err %v, want sk-fast 胜出", handled, successKey, lastErr)
+	}
+	if w.Body.String() != `{"winner":"sk-fast"}` {
+		t.Fatalf("响应 = %s, want sk-fast 的响应", w.Body.String())
+	}
+
+	select {
+	case <-slowCanceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("落后的 Key 请求未被取消")
+	}
+
+	// 落后的请求在后台回收，等待指标落定
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		slow := metricsManager.GetKeyMetrics(server.URL, "sk-slow")
+		if slow != nil && slow.RequestCount == 1 {
+			if slow.FailureCount != 0 || slow.SuccessCount != 0 {
+				t.Fatalf("sk-slow 计数 = %d/%d, want 按客户端取消统计", slow.SuccessCount, slow.FailureCount)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sk-slow 指标未记录取消: %+v", slow)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if fast := metricsManager.GetKeyMetrics(server.URL, "sk-fast"); fast == nil || fast.SuccessCount != 1 {
+		t.Fatalf("sk-fast 指标 = %+v, want 1 次成功", fast)
+	}
+	if broken := metricsManager.GetKeyMetrics(server.URL, "sk-broken"); broken == nil || broken.FailureCount != 1 {
+		t.Fatalf("sk-broken 指标 = %+v, want 1 次失败", broken)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["sk-unused"] != 0 {
+		t.Fatalf("超出 MAX_KEY_SPRAY_CONCURRENCY 的 Key 被请求了 %d 次", hits["sk-unused"])
+	}
+}
+
+func TestKeySprayCount(t *testing.T) {
+	tests := []struct {
+		name       string
+		upstream   int
+		concurrent int
+		want       int
+	}{
+		{name: "未配置", upstream: 0, concurrent: 3, want: 0},
+		{name: "单 Key 不竞速", upstream: 1, concurrent: 3, want: 0},
+		{name: "全局禁用", upstream: 3, concurrent: 1, want: 0},
+		{name: "受并发上限限制", upstream: 5, concurrent: 3, want: 3},
+		{name: "低于上限", upstream: 2, concurrent: 3, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := keySprayCount(&config.UpstreamConfig{KeySprayCount: tt.upstream}, &config.EnvConfig{MaxKeySprayConcurrency: tt.concurrent})
+			if got != tt.want {
+				t.Fatalf("keySprayCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
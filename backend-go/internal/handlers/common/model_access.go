@@ -0,0 +1,28 @@
+package common
+
+import (
+	"log"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// CheckModelAccess 在调度前按接口类型的 allowedModels/deniedModels 检查请求模型
+// 不允许时返回 403 并返回 false，调用方应直接结束处理；未携带模型的请求交由后续流程处理
+func CheckModelAccess(c *gin.Context, cfgManager *config.ConfigManager, kind string, apiType string, model string) bool {
+	if cfgManager == nil || model == "" {
+		return true
+	}
+
+	allowed, reason := cfgManager.CheckModelAccess(kind, model)
+	if allowed {
+		return true
+	}
+
+	log.Printf("[%s-ModelAccess] 拒绝请求: %s", apiType, reason)
+	c.JSON(403, gin.H{
+		"error": "Forbidden: " + reason,
+		"code":  "MODEL_NOT_ALLOWED",
+	})
+	return false
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/session"
+	"github.com/BenedictKing/ccx/internal/types"
+	"github.com/BenedictKing/ccx/internal/warmup"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// TestTryUpstreamWithAllKeys_NormalizedModelMetrics 同一模型的不同写法在指标中归并为规范名称，
+// 未命中映射时上游收到的仍是客户端原始模型名
+func TestTryUpstreamWithAllKeys_NormalizedModelMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var mu sync.Mutex
+	var upstreamModels []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		upstreamModels = append(upstreamModels, gjson.GetBytes(body, "model").String())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	upstream := &config.UpstreamConfig{
+		Name:        "normalize",
+		BaseURL:     server.URL,
+		APIKeys:     []string{"sk-test"},
+		ServiceType: "claude",
+	}
+	data, _ := json.Marshal(config.Config{
+		Upstream: []config.UpstreamConfig{*upstream},
+		ModelAliases: map[string]string{
+			"claude-3.5-sonnet":            "claude-3-5-sonnet",
+			`re:(claude-3-5-sonnet)-\d{8}`: "$1",
+		},
+	})
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	cfgManager, err := config.NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("创建配置管理器失败: %v", err)
+	}
+	t.Cleanup(func() { cfgManager.Close() })
+
+	metricsManager := metrics.NewMetricsManager()
+	defer metricsManager.Stop()
+	sch := scheduler.NewChannelScheduler(cfgManager, metricsManager, metrics.NewMetricsManager(), metrics.NewMetricsManager(), metrics.NewMetricsManager(), session.NewTraceAffinityManager(), warmup.NewURLManager(30*time.Second, 3))
+
+	envCfg := &config.EnvConfig{RequestTimeout: 10000, MaxResponseBodySize: 1 << 20}
+	nextAPIKey := func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error) {
+		for _, key := range upstream.APIKeys {
+			if !failedKeys[key] {
+				return key, nil
+			}
+		}
+		return "", errors.New("no keys")
+	}
+	handleSuccess := func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, apiKey string) (*types.Usage, error) {
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		c.Status(resp.StatusCode)
+		return nil, nil
+	}
+
+	variants := []string{"claude-3.5-sonnet", "claude-3-5-sonnet", "claude-3-5-sonnet-20241022"}
+	for _, model := range variants {
+		buildRequest := func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
+			body, _ := json.Marshal(map[string]string{"model": config.RedirectModel(model, upstreamCopy)})
+			return http.NewRequestWithContext(c.Request.Context(), http.MethodPost, upstreamCopy.BaseURL+"/v1/messages", bytes.NewReader(body))
+		}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+
+		handled, _, _, _, _, lastErr := TryUpstreamWithAllKeys(
+			c, envCfg, cfgManager, sch, scheduler.ChannelKindMessages, "Messages", metricsManager,
+			upstream, BuildDefaultURLResults([]string{server.URL}), []byte(`{}`), false,
+			nextAPIKey, buildRequest, nil, nil, nil, handleSuccess, model, 0, nil,
+		)
+		if !handled || lastErr != nil {
+			t.Fatalf("TryUpstreamWithAllKeys(%s) = handled %v, err %v", model, handled, lastErr)
+		}
+	}
+
+	summary := metricsManager.GetModelUsageSummary(time.Hour)
+	if len(summary) != 1 || summary["claude-3-5-sonnet"] == nil || summary["claude-3-5-sonnet"].RequestCount != 3 {
+		t.Fatalf("模型指标 = %v, want 仅 claude-3-5-sonnet 一项且 3 次请求", summary)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, model := range variants {
+		if upstreamModels[i] != model {
+			t.Fatalf("上游收到模型 %q, want 原始模型名 %q", upstreamModels[i], model)
+		}
+	}
+}
@@ -52,10 +52,34 @@ func HandleMultiChannelFailover(
 			HandleAllChannelsFailed(c, false, failoverErr, lastError, apiType)
 		}
 	}
+	// 绑定死信上下文：快速失败等未进入渠道尝试的路径也能由"全部失败"处理函数写入死信
+	bindDeadLetterTarget(c, channelScheduler, kind, model)
+
+	// 客户端通过请求头固定渠道：跳过调度，仅在该渠道内的 Key/BaseURL 之间 failover
+	pinnedIndex, pinned, err := ParseChannelPin(c, envCfg)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if pinned {
+		handlePinnedChannel(c, envCfg, channelScheduler, kind, apiType, model, pinnedIndex, trySelectedChannel, onHandled, handleAllFailed)
+		return
+	}
+
+	// 快速失败：所有渠道均已熔断时直接返回 503，避免客户端等待逐个渠道的失败尝试
+	// 单渠道模式不经过此处（仍由强制探测模式恢复），存在促销渠道时也不生效
+	if envCfg.FastFail && channelScheduler.AllChannelsUnhealthy(kind, model) {
+		log.Printf("[%s-FastFail] 所有渠道均已熔断，快速失败", apiType)
+		lastError := fmt.Errorf("所有渠道均已熔断，请稍后重试")
+		RecordDeadLetter(c, apiType, nil, lastError)
+		handleAllFailed(c, nil, lastError)
+		return
+	}
 
 	failedChannels := make(map[int]bool)
 	var lastError error
 	var lastFailoverError *FailoverError
+	var failoverKinds []FailoverKind
 
 	maxChannelAttempts := channelScheduler.GetActiveChannelCount(kind)
 
@@ -71,6 +95,11 @@ func HandleMultiChannelFailover(
 			// 继续正常流程
 		}
 
+		if failoverAttemptsExhausted(c, envCfg) {
+			log.Printf("[%s-Failover] 已达到最大尝试次数 (%d)，停止切换渠道", apiType, envCfg.MaxFailoverAttempts)
+			break
+		}
+
 		selection, err := channelScheduler.SelectChannel(c.Request.Context(), userID, failedChannels, kind, model)
 		if err != nil {
 			lastError = err
@@ -100,7 +129,10 @@ func HandleMultiChannelFailover(
 		failedChannels[channelIndex] = true
 
 		if result.FailoverError != nil {
+			// sticky 亲和模式下，会话仅在亲和渠道实际返回 failover 错误后才重新选择渠道
+			channelScheduler.MarkTraceAffinityFailed(userID, channelIndex, kind)
 			lastFailoverError = result.FailoverError
+			failoverKinds = append(failoverKinds, result.FailoverError.Kind)
 			if upstream != nil {
 				lastError = fmt.Errorf("渠道 [%d] %s 失败", channelIndex, upstream.Name)
 			} else {
@@ -114,5 +146,54 @@ func HandleMultiChannelFailover(
 	}
 
 	log.Printf("[%s-Error] 所有渠道都失败了", apiType)
+	if lastFailoverError != nil {
+		// 复制一份再附加汇总，避免修改渠道内部返回的错误对象
+		aggregated := *lastFailoverError
+		aggregated.ChannelKinds = failoverKinds
+		lastFailoverError = &aggregated
+	}
+	RecordDeadLetter(c, apiType, lastFailoverError, lastError)
 	handleAllFailed(c, lastFailoverError, lastError)
 }
+
+// handlePinnedChannel 处理请求头固定渠道的请求（不参与 Trace 亲和，也不切换到其他渠道）
+func handlePinnedChannel(
+	c *gin.Context,
+	envCfg *config.EnvConfig,
+	channelScheduler *scheduler.ChannelScheduler,
+	kind scheduler.ChannelKind,
+	apiType string,
+	model string,
+	channelIndex int,
+	trySelectedChannel TrySelectedChannelFunc,
+	onHandled OnMultiChannelHandledFunc,
+	handleAllFailed HandleAllFailedFunc,
+) {
+	selection, err := channelScheduler.SelectPinnedChannel(channelIndex, kind)
+	if err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("无效的 %s 请求头: %v", ChannelPinHeader, err)})
+		return
+	}
+
+	if envCfg.ShouldLog("info") {
+		log.Printf("[%s-Select] 选择渠道: [%d] %s (原因: %s)", apiType, channelIndex, selection.Upstream.Name, selection.Reason)
+	}
+
+	result := trySelectedChannel(selection)
+	if result.Handled {
+		if onHandled != nil {
+			onHandled(selection, result)
+		}
+		return
+	}
+
+	var lastError error
+	if result.FailoverError != nil {
+		lastError = fmt.Errorf("渠道 [%d] %s 失败", channelIndex, selection.Upstream.Name)
+	} else {
+		lastError = result.LastError
+	}
+	log.Printf("[%s-Error] 固定渠道 [%d] %s 所有密钥都失败了", apiType, channelIndex, selection.Upstream.Name)
+	RecordDeadLetter(c, apiType, result.FailoverError, lastError)
+	handleAllFailed(c, result.FailoverError, lastError)
+}
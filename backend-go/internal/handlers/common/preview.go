@@ -0,0 +1,23 @@
+package common
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewPreviewContext 基于管理请求构造用于预览的 gin.Context
+// 请求路径与请求体替换为模拟的客户端请求，仅保留 Content-Type 头，避免管理密钥等头部被带入上游请求；
+// 返回的 Context 不绑定 ResponseWriter，只能用于构建上游请求，不能写响应
+func NewPreviewContext(c *gin.Context, path string, bodyBytes []byte) (*gin.Context, error) {
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, path, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	previewCtx := c.Copy()
+	previewCtx.Request = req
+	return previewCtx, nil
+}
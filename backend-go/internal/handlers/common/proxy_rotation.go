@@ -0,0 +1,46 @@
+package common
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// proxyRotationCounters 每组代理列表的轮询计数器（key 为代理列表拼接，value 为 *atomic.Uint64）
+// HTTP 客户端（含 Transport）已由 httpclient.ClientManager 按代理地址缓存，这里只负责选择顺序
+var proxyRotationCounters sync.Map
+
+// nextProxyStart 返回本次请求的起始代理下标（轮询）
+func nextProxyStart(proxies []string) int {
+	key := strings.Join(proxies, "\n")
+	v, _ := proxyRotationCounters.LoadOrStore(key, new(atomic.Uint64))
+	return int((v.(*atomic.Uint64).Add(1) - 1) % uint64(len(proxies)))
+}
+
+// isProxyConnectError 判断是否为连接阶段错误（请求尚未发往上游，可安全切换代理重发）
+func isProxyConnectError(err error) bool {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	return opErr.Op == "dial" || opErr.Op == "proxyconnect" || strings.HasPrefix(opErr.Op, "socks")
+}
+
+// rewindRequestBody 重置请求体以便换代理重发，无法重置时返回 false
+func rewindRequestBody(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody {
+		return true
+	}
+	if req.GetBody == nil {
+		return false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	req.Body = body
+	return true
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+)
+
+// newCountingProxy 启动一个计数的 HTTP 代理（直接应答，不转发）
+func newCountingProxy(t *testing.T, name string, hits *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"model":"test"}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(name))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSendRequest_ProxyRotation(t *testing.T) {
+	envCfg := &config.EnvConfig{RequestTimeout: 5000}
+
+	// 已关闭的监听地址：连接代理时被拒绝
+	dead := httptest.NewServer(http.NotFoundHandler())
+	deadURL := dead.URL
+	dead.Close()
+
+	send := func(t *testing.T, upstream *config.UpstreamConfig) string {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, "http://upstream.example.com/v1/messages", bytes.NewReader([]byte(`{"model":"test"}`)))
+		if err != nil {
+			t.Fatalf("创建请求失败: %v", err)
+		}
+		resp, err := SendRequest(req, upstream, envCfg, false, "Messages")
+		if err != nil {
+			t.Fatalf("SendRequest 失败: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status=%d, want 200", resp.StatusCode)
+		}
+		return string(body)
+	}
+
+	t.Run("多次请求轮询代理", func(t *testing.T) {
+		var hitsA, hitsB atomic.Int32
+		proxyA := newCountingProxy(t, "a", &hitsA)
+		proxyB := newCountingProxy(t, "b", &hitsB)
+		upstream := &config.UpstreamConfig{ProxyURLs: []string{proxyA.URL, proxyB.URL}}
+
+		var order []string
+		for i := 0; i < 4; i++ {
+			order = append(order, send(t, upstream))
+		}
+		if hitsA.Load() != 2 || hitsB.Load() != 2 {
+			t.Fatalf("hits a=%d b=%d, want 2/2", hitsA.Load(), hitsB.Load())
+		}
+		for i := 1; i < len(order); i++ {
+			if order[i] == order[i-1] {
+				t.Fatalf("相邻请求使用了同一代理: %v", order)
+			}
+		}
+	})
+
+	t.Run("连接失败的代理被跳过", func(t *testing.T) {
+		var hits atomic.Int32
+		proxy := newCountingProxy(t, "ok", &hits)
+		upstream := &config.UpstreamConfig{ProxyURL: deadURL, ProxyURLs: []string{proxy.URL}}
+
+		for i := 0; i < 3; i++ {
+			if got := send(t, upstream); got != "ok" {
+				t.Fatalf("response=%q, want ok", got)
+			}
+		}
+		if hits.Load() != 3 {
+			t.Fatalf("hits=%d, want 3", hits.Load())
+		}
+	})
+}
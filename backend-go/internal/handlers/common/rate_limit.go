@@ -0,0 +1,37 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// CheckGlobalRateLimit 在代理前检查全局 RPM/TPM 上限
+// 超限时返回 429 + Retry-After 并返回 false，调用方应直接结束处理
+func CheckGlobalRateLimit(c *gin.Context, channelScheduler *scheduler.ChannelScheduler, apiType string) bool {
+	if channelScheduler == nil {
+		return true
+	}
+
+	allowed, retryAfter := channelScheduler.CheckGlobalRateLimit()
+	if allowed {
+		return true
+	}
+
+	retryAfterSeconds := int(math.Ceil(retryAfter.Seconds()))
+	if retryAfterSeconds < 1 {
+		retryAfterSeconds = 1
+	}
+	log.Printf("[%s-RateLimit] 超过全局限流上限，拒绝请求 (Retry-After: %ds)", apiType, retryAfterSeconds)
+
+	c.Header("Retry-After", strconv.Itoa(retryAfterSeconds))
+	c.JSON(429, gin.H{
+		"error": fmt.Sprintf("Global rate limit exceeded, retry after %d seconds", retryAfterSeconds),
+		"code":  "GLOBAL_RATE_LIMITED",
+	})
+	return false
+}
@@ -3,6 +3,7 @@
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -49,17 +50,54 @@ func RestoreRequestBody(c *gin.Context, bodyBytes []byte) {
 }
 
 // SendRequest 发送 HTTP 请求到上游
-// isStream: 是否为流式请求（流式请求使用无超时客户端）
+// isStream: 是否为流式请求（流式请求不限总时长，仅在空闲超时时中断）
 // apiType: 接口类型（Messages/Responses/Gemini），用于日志标签前缀
+// 连接 + 响应头超时与流式空闲超时可由渠道配置覆盖，见 ResolveUpstreamTimeouts
+// 响应体按 MaxResponseBodySize / MaxStreamBytes 限制大小，超出时读取返回 ErrResponseTooLarge
 func SendRequest(req *http.Request, upstream *config.UpstreamConfig, envCfg *config.EnvConfig, isStream bool, apiType string) (*http.Response, error) {
 	clientManager := httpclient.GetManager()
+	headerTimeout, idleTimeout := ResolveUpstreamTimeouts(upstream, envCfg)
+	if !isStream {
+		idleTimeout = 0 // 非流式请求由客户端总超时控制
+	}
 
-	var client *http.Client
+	// 响应大小限制：防止异常上游返回超大响应
+	responseLimit := envCfg.MaxResponseBodySize
 	if isStream {
-		client = clientManager.GetStreamClient(upstream.InsecureSkipVerify, upstream.ProxyURL)
-	} else {
+		responseLimit = envCfg.MaxStreamBytes
+	}
+
+	// 请求级超时覆盖（X-CCX-Timeout-Ms）：替换等待响应头超时，非流式请求的总超时改由 context 控制
+	// 不按覆盖值创建 HTTP 客户端，避免任意超时值导致客户端缓存膨胀
+	var cancelOverride context.CancelFunc
+	if timeoutOverride := timeoutOverrideFromContext(req.Context()); timeoutOverride > 0 {
+		headerTimeout = timeoutOverride
+		if !isStream {
+			var ctx context.Context
+			ctx, cancelOverride = context.WithTimeout(req.Context(), timeoutOverride)
+			req = req.WithContext(ctx)
+		}
+	}
+
+	getClient := func(proxyURL string) *http.Client {
+		if isStream {
+			return clientManager.GetStreamClientForUpstream(headerTimeout, upstream, proxyURL)
+		}
 		timeout := time.Duration(envCfg.RequestTimeout) * time.Millisecond
-		client = clientManager.GetStandardClient(timeout, upstream.InsecureSkipVerify, upstream.ProxyURL)
+		if cancelOverride != nil {
+			timeout = 0
+		}
+		return clientManager.GetStandardClientForUpstream(timeout, headerTimeout, upstream, proxyURL)
+	}
+
+	// 多代理：每次请求轮询选择起始代理，连接失败时依次切换到下一个
+	proxies := upstream.GetAllProxyURLs()
+	if len(proxies) == 0 {
+		proxies = []string{""}
+	}
+	start := 0
+	if len(proxies) > 1 {
+		start = nextProxyStart(proxies)
 	}
 
 	if upstream.InsecureSkipVerify && envCfg.EnableRequestLogs {
@@ -69,17 +107,55 @@ func SendRequest(req *http.Request, upstream *config.UpstreamConfig, envCfg *con
 	if envCfg.EnableRequestLogs {
 		log.Printf("[%s-Request-URL] 实际请求URL: %s", apiType, req.URL.String())
 		log.Printf("[%s-Request-Method] 请求方法: %s", apiType, req.Method)
-		if upstream.ProxyURL != "" {
-			// 对代理 URL 进行脱敏处理，避免泄露凭证
-			redactedProxyURL := utils.RedactURLCredentials(upstream.ProxyURL)
-			log.Printf("[%s-Request-Proxy] 使用代理: %s", apiType, redactedProxyURL)
-		}
 		if envCfg.IsDevelopment() {
 			logRequestDetails(req, envCfg, apiType)
 		}
 	}
 
-	return client.Do(req)
+	// 请求体压缩放在日志之后，开发模式下记录的仍是原始 JSON
+	if upstream.CompressUpstreamRequests {
+		originalSize, compressedSize, err := compressRequestBody(req, envCfg.UpstreamGzipMinBytes)
+		if err != nil {
+			log.Printf("[%s-Request-Gzip] 警告: 请求体压缩失败，使用原始请求体: %v", apiType, err)
+		} else if compressedSize > 0 && envCfg.EnableRequestLogs {
+			log.Printf("[%s-Request-Gzip] 请求体已压缩: %d -> %d 字节", apiType, originalSize, compressedSize)
+		}
+	}
+
+	var lastErr error
+	for i := range proxies {
+		proxyURL := proxies[(start+i)%len(proxies)]
+		if i > 0 && !rewindRequestBody(req) {
+			break
+		}
+		if envCfg.EnableRequestLogs && proxyURL != "" {
+			// 对代理 URL 进行脱敏处理，避免泄露凭证
+			log.Printf("[%s-Request-Proxy] 使用代理: %s", apiType, utils.RedactURLCredentials(proxyURL))
+		}
+
+		resp, err := doRequestWithTimeouts(getClient(proxyURL), req, headerTimeout, idleTimeout)
+		if err == nil {
+			resp.Body = limitResponseBody(resp.Body, responseLimit)
+			if cancelOverride != nil {
+				resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancelOverride}
+			}
+			return resp, nil
+		}
+		if !isProxyConnectError(err) || req.Context().Err() != nil {
+			if cancelOverride != nil {
+				cancelOverride()
+			}
+			return nil, err
+		}
+		lastErr = err
+		if i < len(proxies)-1 {
+			log.Printf("[%s-Request-Proxy] 代理连接失败，切换到下一个代理: %s, 错误: %v", apiType, utils.RedactURLCredentials(proxyURL), err)
+		}
+	}
+	if cancelOverride != nil {
+		cancelOverride()
+	}
+	return nil, lastErr
 }
 
 // logRequestDetails 记录请求详情（仅开发模式）
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+)
+
+// compressRequestBody 对请求体进行 gzip 压缩并设置 Content-Encoding: gzip
+// 仅替换 req.Body/GetBody，调用方持有的原始字节（failover 时用于 RestoreRequestBody）不受影响
+// 请求体小于 minBytes 或已设置 Content-Encoding 时不压缩
+// 返回压缩前后的字节数，compressedSize 为 0 表示未压缩
+func compressRequestBody(req *http.Request, minBytes int) (originalSize, compressedSize int, err error) {
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+		return 0, 0, nil
+	}
+	if req.ContentLength > 0 && req.ContentLength < int64(minBytes) {
+		return int(req.ContentLength), 0, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(body) < minBytes {
+		setRequestBody(req, body)
+		return len(body), 0, nil
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		setRequestBody(req, body)
+		return len(body), 0, err
+	}
+	if err := gz.Close(); err != nil {
+		setRequestBody(req, body)
+		return len(body), 0, err
+	}
+
+	setRequestBody(req, buf.Bytes())
+	req.Header.Set("Content-Encoding", "gzip")
+	return len(body), buf.Len(), nil
+}
+
+// setRequestBody 替换请求体，同时更新 ContentLength 与 GetBody（支持换代理重发）
+func setRequestBody(req *http.Request, data []byte) {
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+}
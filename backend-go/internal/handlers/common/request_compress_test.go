@@ -0,0 +1,84 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSendRequest_CompressUpstreamRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotEncoding string
+	var gotBody []byte
+	upstreamSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding = r.Header.Get("Content-Encoding")
+		reader := io.Reader(r.Body)
+		if gotEncoding == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			defer gz.Close()
+			reader = gz
+		}
+		gotBody, _ = io.ReadAll(reader)
+		w.Write([]byte(`{}`))
+	}))
+	defer upstreamSrv.Close()
+
+	largeBody := []byte(`{"model":"test","messages":[{"role":"user","content":"` + strings.Repeat("hello world ", 2000) + `"}]}`)
+	smallBody := []byte(`{"model":"test"}`)
+	envCfg := &config.EnvConfig{RequestTimeout: 5000, UpstreamGzipMinBytes: 1024}
+
+	tests := []struct {
+		name         string
+		compress     bool
+		body         []byte
+		wantEncoding string
+	}{
+		{name: "开启压缩且超过阈值", compress: true, body: largeBody, wantEncoding: "gzip"},
+		{name: "开启压缩但低于阈值", compress: true, body: smallBody, wantEncoding: ""},
+		{name: "未开启压缩", compress: false, body: largeBody, wantEncoding: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := append([]byte(nil), tt.body...)
+			req, err := http.NewRequest(http.MethodPost, upstreamSrv.URL+"/v1/messages", bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("创建请求失败: %v", err)
+			}
+			upstream := &config.UpstreamConfig{CompressUpstreamRequests: tt.compress}
+			resp, err := SendRequest(req, upstream, envCfg, false, "Messages")
+			if err != nil {
+				t.Fatalf("SendRequest 失败: %v", err)
+			}
+			resp.Body.Close()
+
+			if gotEncoding != tt.wantEncoding {
+				t.Fatalf("Content-Encoding=%q, want %q", gotEncoding, tt.wantEncoding)
+			}
+			if !bytes.Equal(gotBody, original) {
+				t.Fatalf("上游收到的请求体与原始请求体不一致: len=%d, want %d", len(gotBody), len(original))
+			}
+
+			// failover 时用原始字节恢复请求体，不应受压缩影响
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+			RestoreRequestBody(c, tt.body)
+			restored, _ := io.ReadAll(c.Request.Body)
+			if !bytes.Equal(restored, original) {
+				t.Fatalf("RestoreRequestBody 恢复的请求体与原始字节不一致")
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+)
+
+// ReadNonStreamBody 读取非流式响应体
+// 上游中途断开导致响应体截断（如 unexpected EOF）时返回 ErrInvalidResponseBody，
+// 此时尚未向客户端写入任何内容，可安全 failover；客户端取消时原样返回 context.Canceled
+// 响应超出 MaxResponseBodySize 时同样返回 ErrInvalidResponseBody（同时可匹配 ErrResponseTooLarge）
+func ReadNonStreamBody(body io.Reader, apiType string) ([]byte, error) {
+	bodyBytes, err := io.ReadAll(body)
+	if err == nil {
+		return bodyBytes, nil
+	}
+	if errors.Is(err, context.Canceled) {
+		return nil, err
+	}
+	log.Printf("[%s-InvalidBody] 响应体读取中断: %v (已读取 %d 字节)", apiType, err, len(bodyBytes))
+	return nil, fmt.Errorf("%w: %w", ErrInvalidResponseBody, err)
+}
+
+// ValidateJSONBody 校验透传前的非流式响应体是否为完整 JSON
+// 截断或非 JSON 响应返回 ErrInvalidResponseBody，由调用方在写入客户端前触发 failover
+func ValidateJSONBody(bodyBytes []byte, apiType string) error {
+	if json.Valid(bodyBytes) {
+		return nil
+	}
+	preview := bodyBytes
+	if len(preview) > 100 {
+		preview = preview[:100]
+	}
+	log.Printf("[%s-InvalidBody] 响应体不是完整 JSON, body前100字节: %s", apiType, preview)
+	return fmt.Errorf("%w: truncated or malformed JSON (%d bytes)", ErrInvalidResponseBody, len(bodyBytes))
+}
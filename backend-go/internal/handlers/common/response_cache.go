@@ -0,0 +1,211 @@
+package common
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// responseCacheHeader 响应缓存命中状态（HIT / MISS）
+const responseCacheHeader = "X-CCX-Cache"
+
+// servedChannelContextKey 成功处理本次请求的渠道信息在 gin.Context 中的键
+const servedChannelContextKey = "ccx.servedChannel"
+
+// servedChannel 成功处理请求的渠道信息（用于缓存命中时记录渠道日志）
+type servedChannel struct {
+	ChannelIndex  int
+	Model         string
+	OriginalModel string
+	BaseURL       string
+	KeyMask       string
+}
+
+// setServedChannel 记录成功处理本次请求的渠道
+func setServedChannel(c *gin.Context, served servedChannel) {
+	if c != nil {
+		c.Set(servedChannelContextKey, served)
+	}
+}
+
+// responseCacheEntry 响应缓存条目
+type responseCacheEntry struct {
+	key       string
+	response  CachedResponse
+	served    servedChannel
+	expiresAt time.Time
+}
+
+// ResponseCache 确定性请求（temperature=0、n=1）的非流式响应 LRU 缓存
+// 按规范化请求体的哈希索引，超过 TTL 或容量上限时淘汰
+type ResponseCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	order      *list.List // 最近使用的在前
+	entries    map[string]*list.Element
+}
+
+// NewResponseCache 创建响应缓存（ttl <= 0 或 maxEntries <= 0 时返回 nil，表示禁用）
+func NewResponseCache(ttl time.Duration, maxEntries int) *ResponseCache {
+	if ttl <= 0 || maxEntries <= 0 {
+		return nil
+	}
+	return &ResponseCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// get 获取未过期的缓存条目并标记为最近使用
+func (rc *ResponseCache) get(key string) (*responseCacheEntry, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	elem, exists := rc.entries[key]
+	if !exists {
+		return nil, false
+	}
+	entry := elem.Value.(*responseCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		rc.order.Remove(elem)
+		delete(rc.entries, key)
+		return nil, false
+	}
+	rc.order.MoveToFront(elem)
+	return entry, true
+}
+
+// set 写入缓存条目，超出容量时淘汰最久未使用的条目
+func (rc *ResponseCache) set(key string, response CachedResponse, served servedChannel) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	entry := &responseCacheEntry{
+		key:       key,
+		response:  response,
+		served:    served,
+		expiresAt: time.Now().Add(rc.ttl),
+	}
+	if elem, exists := rc.entries[key]; exists {
+		elem.Value = entry
+		rc.order.MoveToFront(elem)
+		return
+	}
+	rc.entries[key] = rc.order.PushFront(entry)
+	for rc.order.Len() > rc.maxEntries {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*responseCacheEntry).key)
+	}
+}
+
+// Len 返回当前缓存条目数（含尚未清理的过期条目）
+func (rc *ResponseCache) Len() int {
+	if rc == nil {
+		return 0
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.order.Len()
+}
+
+// responseCacheKey 判断请求是否可缓存并计算缓存键
+// 仅缓存确定性的非流式请求：temperature 显式为 0，n 未设置或为 1
+// 缓存键为调用方标识与规范化请求体（对象键排序，忽略 metadata）的哈希，不同访问密钥/IP 之间不共享缓存
+func responseCacheKey(apiType, caller string, bodyBytes []byte) (string, bool) {
+	var req map[string]interface{}
+	if err := json.Unmarshal(bodyBytes, &req); err != nil {
+		return "", false
+	}
+	if stream, _ := req["stream"].(bool); stream {
+		return "", false
+	}
+	if temperature, ok := req["temperature"].(float64); !ok || temperature != 0 {
+		return "", false
+	}
+	if n, exists := req["n"]; exists {
+		if v, ok := n.(float64); !ok || v != 1 {
+			return "", false
+		}
+	}
+	delete(req, "metadata")
+
+	normalized, err := json.Marshal(req)
+	if err != nil {
+		return "", false
+	}
+	h := sha256.New()
+	h.Write([]byte(apiType))
+	h.Write([]byte{0})
+	h.Write([]byte(caller))
+	h.Write([]byte{0})
+	h.Write(normalized)
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// BeginResponseCache 处理确定性非流式请求的响应缓存
+// - 命中：直接返回缓存响应，记录缓存命中指标与渠道日志（cacheHit），返回 handled=true
+// - 未命中：包装 c.Writer 记录响应，调用方需在请求处理结束后调用 finish 写入缓存（仅缓存 200 响应）
+// 缓存禁用或请求不可缓存时返回空操作的 finish
+func BeginResponseCache(c *gin.Context, cache *ResponseCache, channelScheduler *scheduler.ChannelScheduler, kind scheduler.ChannelKind, apiType string, bodyBytes []byte) (handled bool, finish func()) {
+	if cache == nil {
+		return false, func() {}
+	}
+	key, ok := responseCacheKey(apiType, callerIdentity(c), bodyBytes)
+	if !ok {
+		return false, func() {}
+	}
+
+	if entry, hit := cache.get(key); hit {
+		log.Printf("[%s-Cache] 命中响应缓存 (渠道: [%d], 模型: %s)", apiType, entry.served.ChannelIndex, entry.served.Model)
+		channelScheduler.RecordResponseCacheHit(kind)
+		if channelLogStore := channelScheduler.GetChannelLogStore(kind); channelLogStore != nil {
+			channelLogStore.Record(entry.served.ChannelIndex, &metrics.ChannelLog{
+				Timestamp:     time.Now(),
+				Model:         entry.served.Model,
+				OriginalModel: entry.served.OriginalModel,
+				StatusCode:    entry.response.StatusCode,
+				Success:       true,
+				KeyMask:       entry.served.KeyMask,
+				BaseURL:       entry.served.BaseURL,
+				InterfaceType: apiType,
+				RequestID:     CorrelationID(c),
+				CacheHit:      true,
+			})
+		}
+		c.Header(responseCacheHeader, "HIT")
+		c.Data(entry.response.StatusCode, entry.response.ContentType, entry.response.Body)
+		return true, nil
+	}
+
+	c.Header(responseCacheHeader, "MISS")
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	return false, func() {
+		if recorder.Status() != http.StatusOK || recorder.body.Len() == 0 {
+			return
+		}
+		value, exists := c.Get(servedChannelContextKey)
+		served, ok := value.(servedChannel)
+		if !exists || !ok {
+			return
+		}
+		cache.set(key, CachedResponse{
+			StatusCode:  recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        append([]byte(nil), recorder.body.Bytes()...),
+		}, served)
+	}
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+func TestResponseCacheKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		cacheable bool
+	}{
+		{"temperature 0", `{"model":"m","temperature":0,"messages":[]}`, true},
+		{"n 为 1", `{"model":"m","temperature":0,"n":1,"messages":[]}`, true},
+		{"未设置 temperature", `{"model":"m","messages":[]}`, false},
+		{"非 0 temperature", `{"model":"m","temperature":0.7,"messages":[]}`, false},
+		{"n 大于 1", `{"model":"m","temperature":0,"n":2,"messages":[]}`, false},
+		{"流式请求", `{"model":"m","temperature":0,"stream":true,"messages":[]}`, false},
+		{"无效 JSON", `{`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := responseCacheKey("Messages", "key:sk-a", []byte(tt.body)); ok != tt.cacheable {
+				t.Fatalf("cacheable=%v, want %v", ok, tt.cacheable)
+			}
+		})
+	}
+
+	// 键顺序与 metadata 不影响缓存键
+	a, _ := responseCacheKey("Messages", "key:sk-a", []byte(`{"model":"m","temperature":0,"messages":[{"role":"user","content":"hi"}],"metadata":{"user_id":"a"}}`))
+	b, _ := responseCacheKey("Messages", "key:sk-a", []byte(`{"messages":[{"content":"hi","role":"user"}],"temperature":0,"model":"m","metadata":{"user_id":"b"}}`))
+	if a != b {
+		t.Fatalf("规范化后的请求应得到相同缓存键: %s != %s", a, b)
+	}
+	c, _ := responseCacheKey("Messages", "key:sk-a", []byte(`{"model":"m","temperature":0,"messages":[{"role":"user","content":"hello"}]}`))
+	if a == c {
+		t.Fatal("不同消息内容应得到不同缓存键")
+	}
+	d, _ := responseCacheKey("Messages", "key:sk-b", []byte(`{"model":"m","temperature":0,"messages":[{"role":"user","content":"hi"}]}`))
+	if a == d {
+		t.Fatal("不同调用方应得到不同缓存键")
+	}
+}
+
+// TestBeginResponseCache_ScopedByCaller 不同访问密钥发送相同的确定性请求各自未命中，同一调用方重复请求命中
+func TestBeginResponseCache_ScopedByCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := []byte(`{"model":"claude-test","temperature":0,"messages":[{"role":"user","content":"hi"}]}`)
+
+	tests := []struct {
+		name      string
+		replayKey string
+		wantCache string
+	}{
+		{name: "同一调用方命中", replayKey: "sk-a", wantCache: "HIT"},
+		{name: "不同调用方未命中", replayKey: "sk-b", wantCache: "MISS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewResponseCache(time.Minute, 10)
+			sch := &scheduler.ChannelScheduler{}
+			newContext := func(apiKey string) (*gin.Context, *httptest.ResponseRecorder) {
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+				c.Request.Header.Set("x-api-key", apiKey)
+				return c, w
+			}
+
+			c, w := newContext("sk-a")
+			handled, finish := BeginResponseCache(c, cache, sch, scheduler.ChannelKindMessages, "Messages", body)
+			if handled || w.Header().Get(responseCacheHeader) != "MISS" {
+				t.Fatalf("首次请求应未命中, handled=%v header=%q", handled, w.Header().Get(responseCacheHeader))
+			}
+			c.Set(servedChannelContextKey, servedChannel{Model: "claude-test"})
+			c.Data(http.StatusOK, "application/json", []byte(`{"ok":true}`))
+			finish()
+
+			c, w = newContext(tt.replayKey)
+			BeginResponseCache(c, cache, sch, scheduler.ChannelKindMessages, "Messages", body)
+			if got := w.Header().Get(responseCacheHeader); got != tt.wantCache {
+				t.Fatalf("%s=%q, want %q", responseCacheHeader, got, tt.wantCache)
+			}
+		})
+	}
+}
+
+func TestResponseCache_TTLAndCapacity(t *testing.T) {
+	if NewResponseCache(0, 10) != nil || NewResponseCache(time.Minute, 0) != nil {
+		t.Fatal("ttl 或容量为 0 时应禁用缓存")
+	}
+
+	t.Run("TTL 过期淘汰", func(t *testing.T) {
+		cache := NewResponseCache(50*time.Millisecond, 10)
+		cache.set("k", CachedResponse{StatusCode: 200, Body: []byte("v")}, servedChannel{ChannelIndex: 1})
+		if entry, ok := cache.get("k"); !ok || string(entry.response.Body) != "v" || entry.served.ChannelIndex != 1 {
+			t.Fatalf("写入后应命中, entry=%+v ok=%v", entry, ok)
+		}
+		time.Sleep(80 * time.Millisecond)
+		if _, ok := cache.get("k"); ok {
+			t.Fatal("超过 TTL 后不应命中")
+		}
+		if cache.Len() != 0 {
+			t.Fatalf("过期条目应被移除, len=%d", cache.Len())
+		}
+	})
+
+	t.Run("超出容量淘汰最久未使用", func(t *testing.T) {
+		cache := NewResponseCache(time.Minute, 2)
+		cache.set("a", CachedResponse{StatusCode: 200}, servedChannel{})
+		cache.set("b", CachedResponse{StatusCode: 200}, servedChannel{})
+		cache.get("a") // a 变为最近使用
+		cache.set("c", CachedResponse{StatusCode: 200}, servedChannel{})
+
+		if _, ok := cache.get("b"); ok {
+			t.Fatal("b 应被淘汰")
+		}
+		for _, key := range []string{"a", "c"} {
+			if _, ok := cache.get(key); !ok {
+				t.Fatalf("%s 应保留", key)
+			}
+		}
+	})
+}
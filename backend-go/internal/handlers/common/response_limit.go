@@ -0,0 +1,47 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrResponseTooLarge 上游响应超出配置的大小限制（MAX_RESPONSE_BODY_SIZE_MB / MAX_STREAM_BYTES_MB）
+var ErrResponseTooLarge = errors.New("upstream response too large")
+
+// limitResponseBody 为上游响应体加上累计字节数限制，limit <= 0 表示不限制
+// 超出限制后 Read 返回 ErrResponseTooLarge：
+// - 非流式响应由 ReadNonStreamBody 转换为 ErrInvalidResponseBody，尚未写入客户端，可安全 failover
+// - 流式响应按普通读取错误处理，已转发的数据保持不变，随后结束流
+func limitResponseBody(body io.ReadCloser, limit int64) io.ReadCloser {
+	if limit <= 0 {
+		return body
+	}
+	return &limitedBody{ReadCloser: body, remaining: limit, limit: limit}
+}
+
+// limitedBody 统计已读取字节数的响应体包装
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+// Read 读取不超过剩余额度的数据；额度耗尽后再探测 1 字节，确认确有超出才返回错误，
+// 避免响应体恰好等于限制时被误判
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		var probe [1]byte
+		n, err := b.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, fmt.Errorf("%w (limit %d bytes)", ErrResponseTooLarge, b.limit)
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRetryAfter Retry-After 暂停时长上限，避免异常的响应头长期锁死 Key
+const maxRetryAfter = time.Hour
+
+// ParseRetryAfter 解析 Retry-After 响应头（秒数或 HTTP 日期），返回需要等待的时长
+// 无法解析或已过期时返回 ok=false；超过上限时截断为 maxRetryAfter
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		wait = at.Sub(now)
+	} else {
+		return 0, false
+	}
+
+	if wait <= 0 {
+		return 0, false
+	}
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return wait, true
+}
@@ -0,0 +1,35 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		wantWait time.Duration
+		wantOK   bool
+	}{
+		{name: "秒数", value: "30", wantWait: 30 * time.Second, wantOK: true},
+		{name: "HTTP 日期", value: now.Add(2 * time.Minute).Format(http.TimeFormat), wantWait: 2 * time.Minute, wantOK: true},
+		{name: "超过上限截断", value: "86400", wantWait: maxRetryAfter, wantOK: true},
+		{name: "已过期的日期", value: now.Add(-time.Minute).Format(http.TimeFormat), wantOK: false},
+		{name: "零秒", value: "0", wantOK: false},
+		{name: "空值", value: "", wantOK: false},
+		{name: "无法解析", value: "soon", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, ok := ParseRetryAfter(tt.value, now)
+			if ok != tt.wantOK || wait != tt.wantWait {
+				t.Fatalf("ParseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, wait, ok, tt.wantWait, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ReplayToShadowChannel 将主渠道已成功处理的非流式请求异步镜像到影子渠道
+// 影子渠道的结果只计入其自身的 Key 指标和渠道日志，响应体直接丢弃，不影响客户端。
+// 调用方应在主渠道成功写回响应后调用；函数立即返回，重放由调度器的影子重放工作池在后台完成。
+func ReplayToShadowChannel(
+	c *gin.Context,
+	envCfg *config.EnvConfig,
+	cfgManager *config.ConfigManager,
+	channelScheduler *scheduler.ChannelScheduler,
+	kind scheduler.ChannelKind,
+	apiType string,
+	metricsManager *metrics.MetricsManager,
+	primaryChannelIndex int,
+	requestBody []byte,
+	model string,
+	nextAPIKey NextAPIKeyFunc,
+	buildRequest BuildRequestFunc,
+) {
+	if c == nil || c.Request == nil || cfgManager == nil || metricsManager == nil {
+		return
+	}
+	if nextAPIKey == nil || buildRequest == nil {
+		return
+	}
+
+	upstream, shadowIndex, ok := cfgManager.GetShadowUpstream(string(kind))
+	if !ok || shadowIndex == primaryChannelIndex || len(upstream.APIKeys) == 0 {
+		return
+	}
+
+	// 主请求返回后其 context 会被取消，因此影子请求脱离客户端取消信号，改用独立超时控制
+	baseCtx := context.WithoutCancel(c.Request.Context())
+	timeout := time.Duration(envCfg.RequestTimeout) * time.Millisecond
+
+	// gin.Context 会被复用，必须在提交任务前完成拷贝
+	shadowCtx := c.Copy()
+	shadowCtx.Request = c.Request.Clone(baseCtx)
+	RestoreRequestBody(shadowCtx, requestBody)
+
+	var channelLogStore *metrics.ChannelLogStore
+	if channelScheduler != nil {
+		channelLogStore = channelScheduler.GetChannelLogStore(kind)
+	}
+
+	task := func() {
+		// 超时从 worker 开始执行时计算，排队时间不占用请求超时
+		ctx, cancel := context.WithTimeout(baseCtx, timeout)
+		defer cancel()
+		replayShadowRequest(ctx, shadowCtx, envCfg, apiType, metricsManager, channelLogStore, upstream, shadowIndex, model, cfgManager.NormalizeModel, nextAPIKey, buildRequest)
+	}
+	if channelScheduler == nil {
+		go task()
+		return
+	}
+	// 由调度器的有界工作池执行（SHADOW_CONCURRENCY），队列满时丢弃最旧的重放
+	channelScheduler.SubmitShadowReplay(task)
+}
+
+// replayShadowRequest 执行一次影子请求并记录指标（仅尝试一个 Key，不做 failover）
+func replayShadowRequest(
+	ctx context.Context,
+	c *gin.Context,
+	envCfg *config.EnvConfig,
+	apiType string,
+	metricsManager *metrics.MetricsManager,
+	channelLogStore *metrics.ChannelLogStore,
+	upstream *config.UpstreamConfig,
+	shadowIndex int,
+	model string,
+	normalize func(string) string,
+	nextAPIKey NextAPIKeyFunc,
+	buildRequest BuildRequestFunc,
+) {
+	apiKey, err := nextAPIKey(upstream, map[string]bool{})
+	if err != nil {
+		log.Printf("[%s-Shadow] 警告: 影子渠道 %s 无可用密钥: %v", apiType, upstream.Name, err)
+		return
+	}
+
+	upstreamCopy := config.PinNormalizedModelMapping(upstream, model, normalize).Clone()
+	upstreamCopy.BaseURL = upstream.GetEffectiveBaseURL()
+	baseURL := upstreamCopy.BaseURL
+
+	req, err := buildRequest(c, upstreamCopy, apiKey)
+	if err != nil {
+		log.Printf("[%s-Shadow] 警告: 影子请求构建失败: %v", apiType, err)
+		return
+	}
+	req = req.WithContext(ctx)
+
+	redirectedModel := config.RedirectModel(model, upstreamCopy)
+	var originalModel string
+	metricsModel := redirectedModel
+	if redirectedModel != model {
+		originalModel = model
+	} else {
+		metricsModel = normalize(model)
+	}
+
+	requestID := metricsManager.RecordRequestConnected(baseURL, apiKey, metricsModel)
+	attemptStart := time.Now()
+
+	statusCode := 0
+	var replayErr error
+	resp, err := SendRequest(req, upstreamCopy, envCfg, false, apiType)
+	if err != nil {
+		replayErr = err
+	} else {
+		statusCode = resp.StatusCode
+		// 读取并丢弃响应体，保证连接可复用
+		_, readErr := io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if statusCode < 200 || statusCode >= 300 {
+			replayErr = fmt.Errorf("上游错误: %d", statusCode)
+		} else if readErr != nil {
+			replayErr = readErr
+		}
+	}
+
+	if replayErr != nil && errors.Is(replayErr, context.Canceled) {
+		metricsManager.RecordRequestFinalizeClientCancel(baseURL, apiKey, requestID)
+		return
+	}
+	if replayErr != nil {
+		metricsManager.RecordRequestFinalizeFailure(baseURL, apiKey, requestID)
+		log.Printf("[%s-Shadow] 警告: 影子渠道 [%d] %s 请求失败: %v", apiType, shadowIndex, upstream.Name, replayErr)
+	} else {
+		metricsManager.RecordRequestFinalizeSuccess(baseURL, apiKey, requestID, nil)
+		if envCfg.ShouldLog("info") {
+			log.Printf("[%s-Shadow] 影子渠道 [%d] %s 请求成功: %dms", apiType, shadowIndex, upstream.Name, time.Since(attemptStart).Milliseconds())
+		}
+	}
+
+	if channelLogStore != nil {
+		var errInfo string
+		if replayErr != nil {
+			errInfo = replayErr.Error()
+			if len(errInfo) > 200 {
+				errInfo = errInfo[:200]
+			}
+		}
+		channelLogStore.Record(shadowIndex, &metrics.ChannelLog{
+			Timestamp:     time.Now(),
+			Model:         redirectedModel,
+			OriginalModel: originalModel,
+			StatusCode:    statusCode,
+			DurationMs:    time.Since(attemptStart).Milliseconds(),
+			Success:       replayErr == nil,
+			KeyMask:       utils.MaskAPIKey(apiKey),
+			BaseURL:       baseURL,
+			ErrorInfo:     errInfo,
+			InterfaceType: apiType,
+			RequestID:     CorrelationID(c),
+		})
+	}
+}
@@ -186,6 +186,10 @@ type StreamContext struct {
 	LowQuality   bool   // 是否为低质量渠道
 	// 隐式缓存推断
 	MessageStartInputTokens int // message_start 事件中的 input_tokens（用于推断隐式缓存）
+	// 渠道级事件黑名单过滤（nil 表示不过滤）
+	EventFilter *SSEEventFilter
+	// 慢客户端背压监控（nil 表示禁用）
+	Backpressure *StreamBackpressureGuard
 }
 
 // CollectedUsageData 从流事件中收集的 usage 数据
@@ -198,6 +202,8 @@ type CollectedUsageData struct {
 	CacheCreation5mInputTokens int
 	CacheCreation1hInputTokens int
 	CacheTTL                   string // "5m" | "1h" | "mixed"
+	// 扩展思考 tokens（message_delta 中上游单独返回）
+	ThinkingTokens int
 }
 
 // NewStreamContext 创建流处理上下文
@@ -255,10 +261,11 @@ func seedSynthesizerFromRequest(ctx *StreamContext, requestBody []byte) {
 	}
 }
 
-// SetupStreamHeaders 设置流式响应头
+// SetupStreamHeaders 设置流式响应头（?format=ndjson 时切换为 NDJSON 输出）
 func SetupStreamHeaders(c *gin.Context, resp *http.Response) {
 	utils.ForwardResponseHeaders(resp.Header, c.Writer)
-	c.Header("Content-Type", "text/event-stream")
+	format := ApplyStreamFormat(c)
+	c.Header("Content-Type", StreamContentType(format))
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 	c.Header("X-Accel-Buffering", "no")
@@ -278,20 +285,53 @@ func ProcessStreamEvents(
 	startTime time.Time,
 	requestBody []byte,
 ) (*types.Usage, error) {
+	// 周期性用量事件（STREAM_USAGE_INTERVAL > 0 时启用）
+	partialUsageC, stopPartialUsage := newPartialUsageTicker(envCfg.StreamUsageInterval)
+	defer stopPartialUsage()
+
 	for {
+		// 客户端消费过慢导致缓冲区持续满载：中止请求，按客户端侧错误处理
+		if ctx.Backpressure.Aborted() {
+			ctx.ClientGone = true
+			log.Printf("[Messages-Stream] 警告: 客户端消费过慢，事件缓冲区持续满载，已中止请求以释放上游连接")
+			logPartialResponse(ctx, envCfg)
+			drainChannels(eventChan, errChan)
+			return nil, ErrSlowClient
+		}
+
+		// 客户端已断开（请求 context 取消）：停止读取上游，按客户端取消处理
+		if err := c.Request.Context().Err(); err != nil {
+			return nil, abortCanceledStream(ctx, envCfg, eventChan, errChan, err)
+		}
+
 		select {
+		case <-c.Request.Context().Done():
+			return nil, abortCanceledStream(ctx, envCfg, eventChan, errChan, c.Request.Context().Err())
+
 		case event, ok := <-eventChan:
 			if !ok {
+				if ctx.Backpressure.Aborted() {
+					continue
+				}
 				usage := logStreamCompletion(ctx, envCfg, startTime)
 				return usage, nil
 			}
 			ProcessStreamEvent(c, w, flusher, event, ctx, envCfg, requestBody)
 
+		case <-partialUsageC:
+			writePartialUsageEvent(w, flusher, ctx, requestBody)
+
 		case err, ok := <-errChan:
 			if !ok {
 				continue
 			}
 			if err != nil {
+				if ctx.Backpressure.Aborted() {
+					continue
+				}
+				if ctxErr := c.Request.Context().Err(); ctxErr != nil {
+					return nil, abortCanceledStream(ctx, envCfg, eventChan, errChan, ctxErr)
+				}
 				log.Printf("[Messages-Stream] 错误: 流式传输错误: %v", err)
 				logPartialResponse(ctx, envCfg)
 
@@ -308,6 +348,18 @@ func ProcessStreamEvents(
 	}
 }
 
+// abortCanceledStream 客户端取消后结束流处理：上游请求与客户端 context 绑定已随之中断，
+// 这里排空 channel 让 provider goroutine 退出，返回的错误包装 context.Canceled
+func abortCanceledStream(ctx *StreamContext, envCfg *config.EnvConfig, eventChan <-chan string, errChan <-chan error, err error) error {
+	ctx.ClientGone = true
+	if envCfg.ShouldLog("info") {
+		log.Printf("[Messages-Stream] 客户端已取消请求，停止读取上游流")
+	}
+	logPartialResponse(ctx, envCfg)
+	drainChannels(eventChan, errChan)
+	return err
+}
+
 // ProcessStreamEvent 处理单个流事件
 func ProcessStreamEvent(
 	c *gin.Context,
@@ -318,6 +370,11 @@ func ProcessStreamEvent(
 	envCfg *config.EnvConfig,
 	requestBody []byte,
 ) {
+	// 丢弃命中渠道事件黑名单的事件（如 ping、厂商私有事件）
+	if ctx.EventFilter.IsDenied(event) {
+		return
+	}
+
 	// SSE 事件调试日志
 	ctx.EventCount++
 	if envCfg.SSEDebugLevel == "full" || envCfg.SSEDebugLevel == "summary" {
@@ -494,6 +551,11 @@ func ProcessStreamEvent(
 		ctx.HasMessageDeltaUsage = true
 	}
 
+	// 保证事件带 event: 行并以单个空行分隔（依赖具名 SSE 事件的客户端使用；须在所有修补之后执行）
+	if envCfg.PreserveSSEEvents {
+		eventToSend = ensureSSEEventName(eventToSend)
+	}
+
 	// 转发给客户端
 	if !ctx.ClientGone {
 		if _, err := w.Write([]byte(eventToSend)); err != nil {
@@ -583,6 +645,9 @@ func updateCollectedUsage(collected *CollectedUsageData, usageData CollectedUsag
 	if usageData.CacheTTL != "" {
 		collected.CacheTTL = usageData.CacheTTL
 	}
+	if usageData.ThinkingTokens > collected.ThinkingTokens {
+		collected.ThinkingTokens = usageData.ThinkingTokens
+	}
 }
 
 // inferImplicitCacheRead 推断隐式缓存读取
@@ -650,7 +715,8 @@ func logStreamCompletion(ctx *StreamContext, envCfg *config.EnvConfig, startTime
 		ctx.CollectedUsage.CacheCreationInputTokens > 0 ||
 		ctx.CollectedUsage.CacheReadInputTokens > 0 ||
 		ctx.CollectedUsage.CacheCreation5mInputTokens > 0 ||
-		ctx.CollectedUsage.CacheCreation1hInputTokens > 0
+		ctx.CollectedUsage.CacheCreation1hInputTokens > 0 ||
+		ctx.CollectedUsage.ThinkingTokens > 0
 	if hasUsageData {
 		usage = &types.Usage{
 			InputTokens:                ctx.CollectedUsage.InputTokens,
@@ -660,6 +726,7 @@ func logStreamCompletion(ctx *StreamContext, envCfg *config.EnvConfig, startTime
 			CacheCreation5mInputTokens: ctx.CollectedUsage.CacheCreation5mInputTokens,
 			CacheCreation1hInputTokens: ctx.CollectedUsage.CacheCreation1hInputTokens,
 			CacheTTL:                   ctx.CollectedUsage.CacheTTL,
+			ThinkingTokens:             ctx.CollectedUsage.ThinkingTokens,
 		}
 	}
 	return usage
@@ -731,6 +798,10 @@ func HandleStreamResponse(
 	// 流错误：排空 channel 后返回错误
 	if preflight.HasError {
 		drainChannels(eventChan, errChan)
+		// 响应超限时 Header 尚未发送，按无效响应处理以便 failover
+		if errors.Is(preflight.Error, ErrResponseTooLarge) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidResponseBody, preflight.Error)
+		}
 		return nil, preflight.Error
 	}
 
@@ -756,8 +827,16 @@ func HandleStreamResponse(
 	ctx := NewStreamContext(envCfg)
 	ctx.RequestModel = requestModel
 	ctx.LowQuality = upstream.LowQuality
+	ctx.EventFilter = NewSSEEventFilter(upstream.StreamEventDenylist)
 	seedSynthesizerFromRequest(ctx, requestBody)
 
+	// 慢客户端背压保护：缓冲区持续满载时关闭上游连接并中断阻塞中的客户端写入
+	ctx.Backpressure = StartStreamBackpressureGuard(eventChan, time.Duration(envCfg.StreamBackpressureTimeout)*time.Second, func() {
+		resp.Body.Close()
+		abortSlowClientWrite(w)
+	})
+	defer ctx.Backpressure.Stop()
+
 	// 回放预检测期间缓冲的事件
 	for _, bufferedEvent := range preflight.BufferedEvents {
 		ProcessStreamEvent(c, w, flusher, bufferedEvent, ctx, envCfg, requestBody)
@@ -858,6 +937,9 @@ func extractUsageFromMap(usage map[string]interface{}) CollectedUsageData {
 	if v, ok := usage["cache_read_input_tokens"].(float64); ok {
 		data.CacheReadInputTokens = int(v)
 	}
+	if v, ok := usage["thinking_tokens"].(float64); ok {
+		data.ThinkingTokens = int(v)
+	}
 
 	var has5m, has1h bool
 	if v, ok := usage["cache_creation_5m_input_tokens"].(float64); ok {
@@ -1191,6 +1273,20 @@ func BuildUsageEvent(requestBody []byte, outputText string) string {
 	return fmt.Sprintf("event: message_delta\ndata: %s\n\n", eventJSON)
 }
 
+// EstimateStreamUsage 上游流式响应未返回 usage 时，按请求体与已输出文本估算用量
+// 请求体不是 Claude/OpenAI 消息格式（如 Gemini contents）时按整个请求体估算输入
+func EstimateStreamUsage(requestBody []byte, outputText string) *types.Usage {
+	inputTokens := utils.EstimateRequestTokens(requestBody)
+	if inputTokens == 0 && len(requestBody) > 0 {
+		inputTokens = utils.EstimateTokens(string(requestBody))
+	}
+	return &types.Usage{
+		InputTokens:  inputTokens,
+		OutputTokens: utils.EstimateResponseTokens(outputText),
+		Estimated:    true,
+	}
+}
+
 // IsMessageStartEvent 检测是否为 message_start 事件
 func IsMessageStartEvent(event string) bool {
 	return strings.Contains(event, "\"type\":\"message_start\"") ||
@@ -0,0 +1,176 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+)
+
+// ErrSlowClient 客户端消费过慢，上游事件缓冲区持续满载超过阈值时间，为释放上游连接主动中止
+// 包装 context.Canceled，按客户端侧错误处理（不计入 Key 失败）
+var ErrSlowClient = fmt.Errorf("client too slow, stream aborted: %w", context.Canceled)
+
+// backpressureFullRatio 缓冲区占用达到容量的该比例即视为满载
+const backpressureFullRatio = 0.8
+
+// 透传流预读缓冲：单块大小与缓冲块数
+const (
+	backpressureChunkSize    = 32 * 1024
+	backpressureBufferChunks = 64
+)
+
+// StreamBackpressureGuard 监控上游读取协程与客户端写入之间的事件缓冲区
+// 客户端写入阻塞时上游仍持续产出，缓冲区满载持续超过 timeout 即调用 abort 中止请求，避免慢客户端长期占用上游 Key
+type StreamBackpressureGuard struct {
+	aborted  atomic.Bool
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// StartStreamBackpressureGuard 启动缓冲区监控（timeout <= 0 或无缓冲 channel 时返回 nil，表示禁用）
+func StartStreamBackpressureGuard[T any](eventChan <-chan T, timeout time.Duration, abort func()) *StreamBackpressureGuard {
+	if timeout <= 0 || cap(eventChan) == 0 {
+		return nil
+	}
+	g := &StreamBackpressureGuard{stopCh: make(chan struct{})}
+	threshold := int(float64(cap(eventChan)) * backpressureFullRatio)
+	if threshold < 1 {
+		threshold = 1
+	}
+	interval := timeout / 10
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var fullSince time.Time
+		for {
+			select {
+			case <-g.stopCh:
+				return
+			case now := <-ticker.C:
+				if len(eventChan) < threshold {
+					fullSince = time.Time{}
+					continue
+				}
+				if fullSince.IsZero() {
+					fullSince = now
+					continue
+				}
+				if now.Sub(fullSince) >= timeout {
+					g.aborted.Store(true)
+					if abort != nil {
+						abort()
+					}
+					return
+				}
+			}
+		}
+	}()
+	return g
+}
+
+// Aborted 是否因客户端过慢而中止
+func (g *StreamBackpressureGuard) Aborted() bool {
+	return g != nil && g.aborted.Load()
+}
+
+// Stop 停止监控
+func (g *StreamBackpressureGuard) Stop() {
+	if g == nil {
+		return
+	}
+	g.stopOnce.Do(func() { close(g.stopCh) })
+}
+
+// abortSlowClientWrite 使阻塞中的客户端写入尽快返回（ResponseWriter 不支持写超时时忽略）
+func abortSlowClientWrite(w http.ResponseWriter) {
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now())
+}
+
+// backpressureReader 在独立协程中预读上游响应体，经有界缓冲区交给透传循环消费
+// 客户端写入阻塞时缓冲区逐渐堆满，由 StreamBackpressureGuard 判定慢客户端并中止
+type backpressureReader struct {
+	chunks    chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+	guard     *StreamBackpressureGuard
+	pending   []byte
+	readErr   error
+}
+
+// WrapStreamBackpressure 为直接读取上游响应体的透传流（Chat / Responses / Gemini）接入慢客户端背压保护
+// 未启用（STREAM_BACKPRESSURE_TIMEOUT <= 0）时原样返回 body；中止后 Read 返回 ErrSlowClient
+// 返回的 stop 须在流处理结束时调用，释放预读协程与监控协程
+func WrapStreamBackpressure(w http.ResponseWriter, body io.ReadCloser, envCfg *config.EnvConfig) (io.Reader, func()) {
+	timeout := time.Duration(envCfg.StreamBackpressureTimeout) * time.Second
+	if timeout <= 0 {
+		return body, func() {}
+	}
+
+	r := &backpressureReader{
+		chunks: make(chan []byte, backpressureBufferChunks),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(r.chunks)
+		for {
+			buf := make([]byte, backpressureChunkSize)
+			n, err := body.Read(buf)
+			if n > 0 {
+				select {
+				case r.chunks <- buf[:n]:
+				case <-r.done:
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					r.readErr = err
+				}
+				return
+			}
+		}
+	}()
+	r.guard = StartStreamBackpressureGuard(r.chunks, timeout, func() {
+		body.Close()
+		abortSlowClientWrite(w)
+	})
+
+	return r, func() {
+		r.guard.Stop()
+		r.closeOnce.Do(func() { close(r.done) })
+	}
+}
+
+// Read 实现 io.Reader：优先返回上次未读完的块
+func (r *backpressureReader) Read(p []byte) (int, error) {
+	if r.guard.Aborted() {
+		return 0, ErrSlowClient
+	}
+	if len(r.pending) == 0 {
+		chunk, ok := <-r.chunks
+		if !ok {
+			if r.guard.Aborted() {
+				return 0, ErrSlowClient
+			}
+			if r.readErr != nil {
+				return 0, r.readErr
+			}
+			return 0, io.EOF
+		}
+		r.pending = chunk
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
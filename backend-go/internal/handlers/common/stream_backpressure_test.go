@@ -0,0 +1,111 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/providers"
+	"github.com/gin-gonic/gin"
+)
+
+// slowResponseWriter 每次写入都阻塞一段时间，模拟消费过慢的客户端
+type slowResponseWriter struct {
+	*httptest.ResponseRecorder
+	delay time.Duration
+}
+
+func (w *slowResponseWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return w.ResponseRecorder.Write(p)
+}
+
+// TestHandleStreamResponse_AbortsSlowClient 客户端消费过慢导致缓冲区持续满载时中止请求，并按客户端侧错误处理
+func TestHandleStreamResponse_AbortsSlowClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// 上游持续快速产出事件，直到连接被关闭
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; ; i++ {
+			event := fmt.Sprintf("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"chunk-%d\"}}\n\n", i)
+			if _, err := pw.Write([]byte(event)); err != nil {
+				return
+			}
+		}
+	}()
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": []string{"text/event-stream"}}, Body: pr}
+
+	writer := &slowResponseWriter{ResponseRecorder: httptest.NewRecorder(), delay: 50 * time.Millisecond}
+	c, _ := gin.CreateTestContext(writer)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+
+	envCfg := &config.EnvConfig{LogLevel: "error", StreamBackpressureTimeout: 1}
+	upstream := &config.UpstreamConfig{Name: "slow-client-test"}
+
+	type result struct {
+		err     error
+		elapsed time.Duration
+	}
+	done := make(chan result, 1)
+	start := time.Now()
+	go func() {
+		_, err := HandleStreamResponse(c, resp, &providers.ClaudeProvider{}, envCfg, start, upstream, []byte(`{"model":"claude-test"}`), "claude-test")
+		done <- result{err: err, elapsed: time.Since(start)}
+	}()
+
+	select {
+	case r := <-done:
+		if !errors.Is(r.err, ErrSlowClient) {
+			t.Fatalf("err = %v, want ErrSlowClient", r.err)
+		}
+		if !isClientSideError(r.err) {
+			t.Fatalf("慢客户端中止应按客户端侧错误处理")
+		}
+		if r.elapsed < time.Second {
+			t.Fatalf("中止过早: %v，应在缓冲区满载持续超过阈值后才中止", r.elapsed)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("慢客户端请求未在阈值后中止")
+	}
+}
+
+// TestStreamBackpressureGuard_DisabledOrDraining 禁用或缓冲区未满载时不中止
+func TestStreamBackpressureGuard_DisabledOrDraining(t *testing.T) {
+	eventChan := make(chan string, 10)
+	if guard := StartStreamBackpressureGuard(eventChan, 0, nil); guard != nil || guard.Aborted() {
+		t.Fatal("timeout=0 时应禁用背压监控")
+	}
+
+	aborted := make(chan struct{})
+	guard := StartStreamBackpressureGuard(eventChan, 100*time.Millisecond, func() { close(aborted) })
+	defer guard.Stop()
+
+	// 缓冲区低于满载阈值：不中止
+	for range 5 {
+		eventChan <- "event"
+	}
+	select {
+	case <-aborted:
+		t.Fatal("缓冲区未满载时不应中止")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// 缓冲区满载超过阈值时间：中止
+	for range 5 {
+		eventChan <- "event"
+	}
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("缓冲区持续满载后应中止")
+	}
+	if !guard.Aborted() {
+		t.Fatal("Aborted() = false, want true")
+	}
+}
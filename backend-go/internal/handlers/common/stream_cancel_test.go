@@ -0,0 +1,86 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/providers"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandleStreamResponse_ClientCancelStopsUpstream 客户端中途取消时停止读取上游，上游连接随之断开，并按客户端取消处理
+func TestHandleStreamResponse_ClientCancelStopsUpstream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstreamGone := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for i := 0; ; i++ {
+			event := fmt.Sprintf("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"chunk-%d\"}}\n\n", i)
+			if _, err := w.Write([]byte(event)); err != nil {
+				close(upstreamGone)
+				return
+			}
+			flusher.Flush()
+			select {
+			case <-r.Context().Done():
+				close(upstreamGone)
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	clientCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil).WithContext(clientCtx)
+
+	// 与 provider 一致：上游请求使用客户端请求的 context
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, upstream.URL, nil)
+	if err != nil {
+		t.Fatalf("创建上游请求失败: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("上游请求失败: %v", err)
+	}
+
+	envCfg := &config.EnvConfig{LogLevel: "error"}
+	done := make(chan error, 1)
+	go func() {
+		_, err := HandleStreamResponse(c, resp, &providers.ClaudeProvider{}, envCfg, time.Now(), &config.UpstreamConfig{Name: "cancel-test"}, []byte(`{"model":"claude-test"}`), "claude-test")
+		done <- err
+	}()
+
+	time.Sleep(150 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+		if !isClientSideError(err) {
+			t.Fatalf("客户端取消应按客户端侧错误处理")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("客户端取消后流处理未及时结束")
+	}
+
+	select {
+	case <-upstreamGone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("客户端取消后上游连接未断开")
+	}
+}
@@ -0,0 +1,139 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// SSEEventFilter 按事件类型黑名单丢弃上游 SSE 事件（如 ping、厂商私有的 x-* 事件），其余事件原样转发
+// 类型优先取 event: 行，缺失时取 data: JSON 负载中的 type 字段
+type SSEEventFilter struct {
+	denylist []string
+	pending  []byte // 尚未遇到事件分隔空行的残留数据（透传模式下上游 chunk 可能截断行或事件）
+}
+
+// NewSSEEventFilter 创建事件过滤器（黑名单为空时返回 nil，表示不过滤）
+func NewSSEEventFilter(denylist []string) *SSEEventFilter {
+	if len(denylist) == 0 {
+		return nil
+	}
+	return &SSEEventFilter{denylist: denylist}
+}
+
+// IsDenied 判断完整事件是否命中黑名单
+func (f *SSEEventFilter) IsDenied(event string) bool {
+	if f == nil {
+		return false
+	}
+	eventType := sseEventType(event)
+	if eventType == "" {
+		return false
+	}
+	for _, pattern := range f.denylist {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(eventType, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if pattern == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter 处理一段原始 SSE 字节流，返回可转发的完整事件
+// 不完整的事件缓存到下次调用，流结束时需调用 Flush 输出残留数据
+func (f *SSEEventFilter) Filter(data []byte) []byte {
+	if f == nil {
+		return data
+	}
+	f.pending = append(f.pending, data...)
+
+	var out bytes.Buffer
+	for {
+		end := sseEventEnd(f.pending)
+		if end < 0 {
+			break
+		}
+		event := f.pending[:end]
+		if !f.IsDenied(string(event)) {
+			out.Write(event)
+		}
+		f.pending = f.pending[end:]
+	}
+	return out.Bytes()
+}
+
+// Flush 返回流结束时残留的不完整事件（命中黑名单时丢弃）
+func (f *SSEEventFilter) Flush() []byte {
+	if f == nil || len(f.pending) == 0 {
+		return nil
+	}
+	rest := f.pending
+	f.pending = nil
+	if f.IsDenied(string(rest)) {
+		return nil
+	}
+	return rest
+}
+
+// sseEventEnd 返回首个事件（含结尾空行）的结束位置，未找到分隔空行时返回 -1
+func sseEventEnd(data []byte) int {
+	lineStart := 0
+	for lineStart < len(data) {
+		idx := bytes.IndexByte(data[lineStart:], '\n')
+		if idx < 0 {
+			return -1
+		}
+		lineEnd := lineStart + idx + 1
+		if len(bytes.TrimRight(data[lineStart:lineEnd], "\r\n")) == 0 && lineStart > 0 {
+			return lineEnd
+		}
+		lineStart = lineEnd
+	}
+	return -1
+}
+
+// sseEventType 提取事件类型：优先 event: 行，否则解析 data: JSON 的 type 字段
+func sseEventType(event string) string {
+	var dataType string
+	for _, line := range strings.Split(event, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "event:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		}
+		if dataType == "" && strings.HasPrefix(line, "data:") {
+			payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			var parsed struct {
+				Type string `json:"type"`
+			}
+			if json.Unmarshal([]byte(payload), &parsed) == nil {
+				dataType = parsed.Type
+			}
+		}
+	}
+	return dataType
+}
+
+// ensureSSEEventName 保证透传事件带 event: 行并以空行结尾（PRESERVE_SSE_EVENTS）
+// 上游已有 event: 行时原样保留；缺失时按 data: JSON 的 type 字段补全；无法识别类型的事件（注释、空事件）不补全
+func ensureSSEEventName(event string) string {
+	body := strings.TrimRight(event, "\r\n")
+	if body == "" {
+		return event
+	}
+	hasEventLine := false
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "event:") {
+			hasEventLine = true
+			break
+		}
+	}
+	if !hasEventLine {
+		if eventType := sseEventType(body); eventType != "" {
+			body = "event: " + eventType + "\n" + body
+		}
+	}
+	return body + "\n\n"
+}
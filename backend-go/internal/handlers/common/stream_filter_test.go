@@ -0,0 +1,119 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSSEEventFilter_DropsDeniedEvents(t *testing.T) {
+	stream := "event: message_start\ndata: {\"type\":\"message_start\"}\n\n" +
+		"event: ping\ndata: {\"type\": \"ping\"}\n\n" +
+		"data: {\"type\":\"x-vendor-trace\",\"id\":\"t1\"}\r\n\r\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"hi\"}}\n\n" +
+		": keep-alive comment\n\n" +
+		"data: {\"choices\":[]}\n\n" +
+		"data: [DONE]\n\n"
+	want := "event: message_start\ndata: {\"type\":\"message_start\"}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"hi\"}}\n\n" +
+		": keep-alive comment\n\n" +
+		"data: {\"choices\":[]}\n\n" +
+		"data: [DONE]\n\n"
+
+	tests := []struct {
+		name      string
+		chunkSize int
+	}{
+		{name: "整段写入", chunkSize: len(stream)},
+		{name: "逐字节写入（行被截断）", chunkSize: 1},
+		{name: "7 字节分块", chunkSize: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewSSEEventFilter([]string{"ping", "x-*"})
+			var out strings.Builder
+			for i := 0; i < len(stream); i += tt.chunkSize {
+				end := i + tt.chunkSize
+				if end > len(stream) {
+					end = len(stream)
+				}
+				out.Write(filter.Filter([]byte(stream[i:end])))
+			}
+			out.Write(filter.Flush())
+
+			if out.String() != want {
+				t.Fatalf("filtered stream = %q, want %q", out.String(), want)
+			}
+		})
+	}
+}
+
+func TestSSEEventFilter_NilPassthrough(t *testing.T) {
+	filter := NewSSEEventFilter(nil)
+	if filter != nil {
+		t.Fatalf("空黑名单应返回 nil 过滤器")
+	}
+
+	chunk := []byte("event: ping\ndata: {\"type\":\"ping\"}\n")
+	if got := filter.Filter(chunk); string(got) != string(chunk) {
+		t.Fatalf("Filter() = %q, want unchanged", got)
+	}
+	if filter.IsDenied("event: ping\n\n") {
+		t.Fatalf("nil 过滤器不应丢弃事件")
+	}
+	if got := filter.Flush(); got != nil {
+		t.Fatalf("Flush() = %q, want nil", got)
+	}
+}
+
+func TestSSEEventFilter_FlushIncompleteEvent(t *testing.T) {
+	filter := NewSSEEventFilter([]string{"ping"})
+
+	if got := filter.Filter([]byte("data: {\"type\":\"ping\"}\n\ndata: {\"type\":\"done\"}")); len(got) != 0 {
+		t.Fatalf("Filter() = %q, want empty", got)
+	}
+	if got := filter.Flush(); string(got) != "data: {\"type\":\"done\"}" {
+		t.Fatalf("Flush() = %q, want trailing event", got)
+	}
+}
+
+func TestEnsureSSEEventName(t *testing.T) {
+	tests := []struct {
+		name  string
+		event string
+		want  string
+	}{
+		{
+			name:  "已有 event 行原样保留",
+			event: "event: content_block_delta\ndata: {\"type\":\"content_block_delta\"}\n\n",
+			want:  "event: content_block_delta\ndata: {\"type\":\"content_block_delta\"}\n\n",
+		},
+		{
+			name:  "缺失 event 行按 type 补全",
+			event: "data: {\"type\":\"content_block_delta\"}\n\n",
+			want:  "event: content_block_delta\ndata: {\"type\":\"content_block_delta\"}\n\n",
+		},
+		{
+			name:  "缺少结尾空行时补齐分隔",
+			event: "event: message_stop\ndata: {\"type\":\"message_stop\"}\n",
+			want:  "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+		},
+		{
+			name:  "注释不补全事件名",
+			event: ": keep-alive\n\n",
+			want:  ": keep-alive\n\n",
+		},
+		{
+			name:  "空事件原样返回",
+			event: "\n",
+			want:  "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ensureSSEEventName(tt.event); got != tt.want {
+				t.Fatalf("ensureSSEEventName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
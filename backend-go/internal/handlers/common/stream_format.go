@@ -0,0 +1,100 @@
+package common
+
+import (
+	"bytes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamFormat 流式响应输出帧格式
+type StreamFormat string
+
+const (
+	StreamFormatSSE    StreamFormat = "sse"    // 默认：data: ...\n\n
+	StreamFormatNDJSON StreamFormat = "ndjson" // 每个事件对象输出为一行 JSON
+)
+
+// ndjsonDoneLine SSE 的 [DONE] 在 NDJSON 中对应的终止对象
+const ndjsonDoneLine = `{"type":"done"}` + "\n"
+
+// GetStreamFormat 从 ?format= 查询参数读取流式输出格式（未指定或未知值均为 SSE）
+func GetStreamFormat(c *gin.Context) StreamFormat {
+	if c.Query("format") == string(StreamFormatNDJSON) {
+		return StreamFormatNDJSON
+	}
+	return StreamFormatSSE
+}
+
+// StreamContentType 返回输出格式对应的 Content-Type
+func StreamContentType(format StreamFormat) string {
+	if format == StreamFormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/event-stream"
+}
+
+// ApplyStreamFormat 按请求的输出格式包装 c.Writer
+// 上游解析与事件处理逻辑保持不变，仅在写出时转换帧格式
+func ApplyStreamFormat(c *gin.Context) StreamFormat {
+	format := GetStreamFormat(c)
+	if format == StreamFormatNDJSON {
+		if _, wrapped := c.Writer.(*ndjsonResponseWriter); !wrapped {
+			c.Writer = &ndjsonResponseWriter{ResponseWriter: c.Writer}
+		}
+	}
+	return format
+}
+
+// ndjsonResponseWriter 将写入的 SSE 帧转换为 NDJSON 行
+// - data: 行的 JSON 负载原样输出为一行
+// - data: [DONE] 转换为终止对象 {"type":"done"}
+// - event:/id:/注释/空行 丢弃（事件类型已包含在 JSON 负载的 type 字段中）
+type ndjsonResponseWriter struct {
+	gin.ResponseWriter
+	pending []byte // 未以换行结尾的残留数据（透传模式下上游 chunk 可能截断行）
+}
+
+// Write 实现 io.Writer，返回值按输入长度计算，保证调用方的写入计数语义不变
+func (w *ndjsonResponseWriter) Write(data []byte) (int, error) {
+	w.pending = append(w.pending, data...)
+
+	var out bytes.Buffer
+	for {
+		idx := bytes.IndexByte(w.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		line := bytes.TrimRight(w.pending[:idx], "\r")
+		w.pending = w.pending[idx+1:]
+		appendNDJSONLine(&out, line)
+	}
+
+	if out.Len() > 0 {
+		if _, err := w.ResponseWriter.Write(out.Bytes()); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// WriteString 实现 io.StringWriter
+func (w *ndjsonResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// appendNDJSONLine 将单行 SSE 转换为 NDJSON 写入 out
+func appendNDJSONLine(out *bytes.Buffer, line []byte) {
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return
+	}
+	payload := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+	if len(payload) == 0 {
+		return
+	}
+	if string(payload) == "[DONE]" {
+		out.WriteString(ndjsonDoneLine)
+		return
+	}
+	out.Write(payload)
+	out.WriteByte('\n')
+}
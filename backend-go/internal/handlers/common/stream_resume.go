@@ -0,0 +1,307 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// StreamResumeTokenHeader 流式响应的续传令牌（响应头下发，续传请求时由客户端回传）
+const StreamResumeTokenHeader = "X-CCX-Resume-Token"
+
+// lastEventIDHeader 客户端已收到的最后一个事件 id（SSE 标准请求头）
+const lastEventIDHeader = "Last-Event-ID"
+
+// resumableStream 单个流式响应的事件缓冲区
+// 事件 id 从 1 开始递增，events[0] 的 id 为 firstID；超过上限时丢弃最早的事件
+type resumableStream struct {
+	mu        sync.Mutex
+	apiType   string
+	events    [][]byte
+	firstID   int64
+	done      bool
+	expiresAt time.Time
+	updated   chan struct{} // 有新事件或流结束时关闭并替换，用于唤醒续传中的连接
+}
+
+// append 追加一个事件
+func (s *resumableStream) append(event []byte, maxEvents int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+	if len(s.events) > maxEvents {
+		drop := len(s.events) - maxEvents
+		s.events = append([][]byte(nil), s.events[drop:]...)
+		s.firstID += int64(drop)
+	}
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+// nextID 下一个追加事件将获得的 id（同一流只有一个写入方）
+func (s *resumableStream) nextID() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.firstID + int64(len(s.events))
+}
+
+// finish 标记流结束，令牌从此刻起保留 ttl
+func (s *resumableStream) finish(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return
+	}
+	s.done = true
+	s.expiresAt = time.Now().Add(ttl)
+	close(s.updated)
+}
+
+// since 返回 id 大于 lastID 的已缓冲事件；lastID 早于保留窗口时 ok 为 false
+func (s *resumableStream) since(lastID int64) (events [][]byte, lastBuffered int64, done bool, updated <-chan struct{}, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lastID+1 < s.firstID {
+		return nil, 0, s.done, s.updated, false
+	}
+	start := int(lastID + 1 - s.firstID)
+	if start < len(s.events) {
+		events = append([][]byte(nil), s.events[start:]...)
+	}
+	return events, s.firstID + int64(len(s.events)) - 1, s.done, s.updated, true
+}
+
+// expired 流已结束且超过保留时间
+func (s *resumableStream) expired(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done && now.After(s.expiresAt)
+}
+
+// resumableStreamStore 续传令牌到事件缓冲区的映射
+// 进行中的流不会过期；流结束后保留 TTL，每次注册新流时顺带清理过期条目
+type resumableStreamStore struct {
+	mu      sync.Mutex
+	streams map[string]*resumableStream
+}
+
+var resumableStreams = &resumableStreamStore{streams: make(map[string]*resumableStream)}
+
+func (rs *resumableStreamStore) register(token string, stream *resumableStream) {
+	now := time.Now()
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for t, s := range rs.streams {
+		if s.expired(now) {
+			delete(rs.streams, t)
+		}
+	}
+	rs.streams[token] = stream
+}
+
+func (rs *resumableStreamStore) get(token string) (*resumableStream, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	stream, exists := rs.streams[token]
+	if !exists {
+		return nil, false
+	}
+	if stream.expired(time.Now()) {
+		delete(rs.streams, token)
+		return nil, false
+	}
+	return stream, true
+}
+
+func (rs *resumableStreamStore) remove(token string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	delete(rs.streams, token)
+}
+
+// BeginResumableStream 为流式请求分配续传令牌（ENABLE_STREAM_RESUME）
+// 包装 c.Writer：按 SSE 事件边界切分写入，为每个事件附加递增的 id: 行并缓冲最近的事件；
+// 同时解除请求 context 与客户端连接的绑定，客户端断开后上游继续生成，事件留在缓冲区等待续传。
+// 调用方需在请求处理结束后调用 finish。未开启或 ?format=ndjson（无事件 id）时返回空操作的 finish
+func BeginResumableStream(c *gin.Context, envCfg *config.EnvConfig, apiType string) (finish func()) {
+	if envCfg == nil || !envCfg.EnableStreamResume || GetStreamFormat(c) != StreamFormatSSE {
+		return func() {}
+	}
+
+	token := uuid.NewString()
+	stream := &resumableStream{apiType: apiType, firstID: 1, updated: make(chan struct{})}
+	resumableStreams.register(token, stream)
+
+	writer := &resumeResponseWriter{ResponseWriter: c.Writer, stream: stream, maxEvents: envCfg.StreamResumeMaxEvents}
+	if writer.maxEvents <= 0 {
+		writer.maxEvents = 1
+	}
+	c.Writer = writer
+	c.Header(StreamResumeTokenHeader, token)
+	c.Request = c.Request.WithContext(context.WithoutCancel(c.Request.Context()))
+
+	ttl := time.Duration(envCfg.StreamResumeTTL) * time.Second
+	return func() {
+		writer.flushPending()
+		if writer.passthrough {
+			// 非 SSE 响应（错误 JSON 等）没有事件可续传
+			resumableStreams.remove(token)
+			return
+		}
+		stream.finish(ttl)
+	}
+}
+
+// resumeResponseWriter 将写入的 SSE 数据切分为事件，附加 id 后缓冲并转发给客户端
+// 客户端断开后写入错误被吞掉，保证上游流继续读取直到结束
+type resumeResponseWriter struct {
+	gin.ResponseWriter
+	stream      *resumableStream
+	maxEvents   int
+	pending     []byte // 未以空行结尾的残留数据（透传模式下上游 chunk 可能截断事件）
+	passthrough bool   // 响应不是 SSE，原样透传
+	clientGone  bool
+}
+
+// Write 实现 io.Writer，返回值按输入长度计算，客户端断开时不返回错误
+func (w *resumeResponseWriter) Write(data []byte) (int, error) {
+	if w.passthrough || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		w.passthrough = true
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.pending = append(w.pending, data...)
+	for {
+		end := bytes.Index(w.pending, []byte("\n\n"))
+		if end < 0 {
+			break
+		}
+		w.emit(w.pending[:end+2])
+		w.pending = w.pending[end+2:]
+	}
+	return len(data), nil
+}
+
+// WriteString 实现 io.StringWriter
+func (w *resumeResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// flushPending 流结束时将残留的不完整事件作为最后一个事件输出
+func (w *resumeResponseWriter) flushPending() {
+	if len(w.pending) == 0 || w.passthrough {
+		return
+	}
+	w.emit(w.pending)
+	w.pending = nil
+	w.Flush()
+}
+
+func (w *resumeResponseWriter) emit(event []byte) {
+	framed := formatResumableEvent(w.stream.nextID(), event)
+	w.stream.append(framed, w.maxEvents)
+
+	if w.clientGone {
+		return
+	}
+	if _, err := w.ResponseWriter.Write(framed); err != nil {
+		w.clientGone = true
+		log.Printf("[%s-StreamResume] 客户端连接已断开，继续缓冲上游事件等待续传: %v", w.stream.apiType, err)
+	}
+}
+
+// formatResumableEvent 在事件末尾附加 id: 行（放在最后，覆盖上游事件自带的 id）
+func formatResumableEvent(id int64, event []byte) []byte {
+	event = bytes.TrimRight(event, "\n")
+	framed := make([]byte, 0, len(event)+24)
+	framed = append(framed, event...)
+	framed = append(framed, "\nid: "...)
+	framed = strconv.AppendInt(framed, id, 10)
+	return append(framed, "\n\n"...)
+}
+
+// ResumeStream 处理携带 X-CCX-Resume-Token 的续传请求
+// 回放 id 大于 Last-Event-ID 的缓冲事件，原流仍在进行时继续跟随新事件直到结束，返回 handled=true。
+// 未开启或未携带令牌时返回 false，调用方按普通请求处理
+func ResumeStream(c *gin.Context, envCfg *config.EnvConfig, apiType string) (handled bool) {
+	token := c.GetHeader(StreamResumeTokenHeader)
+	if envCfg == nil || !envCfg.EnableStreamResume || token == "" {
+		return false
+	}
+
+	stream, ok := resumableStreams.get(token)
+	if !ok || stream.apiType != apiType {
+		c.JSON(410, gin.H{
+			"error": "Stream resume token is unknown or expired",
+			"code":  "STREAM_RESUME_EXPIRED",
+		})
+		return true
+	}
+
+	var lastID int64
+	if raw := strings.TrimSpace(c.GetHeader(lastEventIDHeader)); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid %s header: %q", lastEventIDHeader, raw)})
+			return true
+		}
+		lastID = parsed
+	}
+
+	events, lastBuffered, done, updated, ok := stream.since(lastID)
+	if !ok {
+		c.JSON(410, gin.H{
+			"error": fmt.Sprintf("Event %d is no longer buffered for this stream", lastID+1),
+			"code":  "STREAM_RESUME_WINDOW_EXCEEDED",
+		})
+		return true
+	}
+
+	log.Printf("[%s-StreamResume] 续传流式响应，从事件 %d 开始", apiType, lastID+1)
+	c.Header(StreamResumeTokenHeader, token)
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(200)
+
+	for {
+		for _, event := range events {
+			if _, err := c.Writer.Write(event); err != nil {
+				return true
+			}
+		}
+		c.Writer.Flush()
+		lastID = lastBuffered
+
+		if done {
+			return true
+		}
+		select {
+		case <-c.Request.Context().Done():
+			return true
+		case <-updated:
+		}
+
+		events, lastBuffered, done, updated, ok = stream.since(lastID)
+		if !ok {
+			// 续传连接消费过慢，事件已被挤出保留窗口
+			log.Printf("[%s-StreamResume] 警告: 续传连接落后超过保留窗口，结束续传", apiType)
+			return true
+		}
+	}
+}
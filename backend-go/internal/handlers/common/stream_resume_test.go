@@ -0,0 +1,196 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// writeTestEvents 按 SSE 帧写出 data: {"n":from}..{"n":to}
+func writeTestEvents(c *gin.Context, from, to int) {
+	for i := from; i <= to; i++ {
+		c.Writer.WriteString(fmt.Sprintf("event: delta\ndata: {\"n\":%d}\n\n", i))
+		c.Writer.Flush()
+	}
+}
+
+// readSSEEvent 读取一个完整的 SSE 事件（以空行结尾）
+func readSSEEvent(t *testing.T, reader *bufio.Reader) string {
+	t.Helper()
+	var event strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("读取事件失败: %v (已读取 %q)", err, event.String())
+		}
+		if line == "\n" {
+			return event.String()
+		}
+		event.WriteString(line)
+	}
+}
+
+// TestResumeStream_DisconnectAndResume 客户端断开后上游继续写入缓冲区，携带令牌与 Last-Event-ID 重连后从断点继续接收
+func TestResumeStream_DisconnectAndResume(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	envCfg := &config.EnvConfig{EnableStreamResume: true, StreamResumeMaxEvents: 100, StreamResumeTTL: 60}
+
+	resumedCh := make(chan struct{})
+	upstreamCtxErr := make(chan error, 1)
+
+	router := gin.New()
+	router.POST("/v1/messages", func(c *gin.Context) {
+		if ResumeStream(c, envCfg, "Messages") {
+			return
+		}
+		clientCtx := c.Request.Context()
+		finish := BeginResumableStream(c, envCfg, "Messages")
+		defer finish()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Status(http.StatusOK)
+		writeTestEvents(c, 1, 2)
+
+		// 等待客户端断开，断开后继续产出事件
+		<-clientCtx.Done()
+		upstreamCtxErr <- c.Request.Context().Err()
+		writeTestEvents(c, 3, 3)
+
+		// 续传连接收到事件 3 后再产出剩余事件，验证续传会跟随进行中的流
+		<-resumedCh
+		writeTestEvents(c, 4, 5)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// 第一次连接：读取两个事件后断开
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/messages", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	token := resp.Header.Get(StreamResumeTokenHeader)
+	if token == "" {
+		t.Fatal("响应缺少续传令牌")
+	}
+	reader := bufio.NewReader(resp.Body)
+	for i := 1; i <= 2; i++ {
+		event := readSSEEvent(t, reader)
+		if want := fmt.Sprintf("data: {\"n\":%d}\nid: %d\n", i, i); !strings.HasSuffix(event, want) {
+			t.Fatalf("事件 %d = %q, want 以 %q 结尾", i, event, want)
+		}
+	}
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case err := <-upstreamCtxErr:
+		if err != nil {
+			t.Fatalf("客户端断开后上游 context 被取消: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("服务端未感知客户端断开")
+	}
+
+	// 续传：从事件 3 开始
+	req, _ = http.NewRequest(http.MethodPost, server.URL+"/v1/messages", nil)
+	req.Header.Set(StreamResumeTokenHeader, token)
+	req.Header.Set("Last-Event-ID", "2")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("续传请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("续传 status = %d, want 200", resp.StatusCode)
+	}
+
+	reader = bufio.NewReader(resp.Body)
+	if event := readSSEEvent(t, reader); !strings.Contains(event, `{"n":3}`) || !strings.HasSuffix(event, "id: 3\n") {
+		t.Fatalf("续传首个事件 = %q, want 事件 3", event)
+	}
+	close(resumedCh)
+
+	rest, _ := io.ReadAll(reader)
+	want := "event: delta\ndata: {\"n\":4}\nid: 4\n\nevent: delta\ndata: {\"n\":5}\nid: 5\n\n"
+	if string(rest) != want {
+		t.Fatalf("续传剩余事件 = %q, want %q", rest, want)
+	}
+}
+
+// TestResumeStream_Rejects 令牌未知、断点早于保留窗口、Last-Event-ID 非法时拒绝续传
+func TestResumeStream_Rejects(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	envCfg := &config.EnvConfig{EnableStreamResume: true, StreamResumeMaxEvents: 2, StreamResumeTTL: 60}
+
+	// 完成一个 5 个事件的流，缓冲区只保留最后 2 个
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	finish := BeginResumableStream(c, envCfg, "Chat")
+	c.Header("Content-Type", "text/event-stream")
+	c.Status(http.StatusOK)
+	writeTestEvents(c, 1, 5)
+	finish()
+	token := w.Header().Get(StreamResumeTokenHeader)
+
+	tests := []struct {
+		name        string
+		apiType     string
+		token       string
+		lastEventID string
+		wantStatus  int
+		wantCode    string
+		wantBody    string
+	}{
+		{name: "未知令牌", apiType: "Chat", token: "unknown", wantStatus: http.StatusGone, wantCode: "STREAM_RESUME_EXPIRED"},
+		{name: "令牌属于其他接口类型", apiType: "Messages", token: token, lastEventID: "4", wantStatus: http.StatusGone, wantCode: "STREAM_RESUME_EXPIRED"},
+		{name: "断点早于保留窗口", apiType: "Chat", token: token, lastEventID: "2", wantStatus: http.StatusGone, wantCode: "STREAM_RESUME_WINDOW_EXCEEDED"},
+		{name: "非法 Last-Event-ID", apiType: "Chat", token: token, lastEventID: "abc", wantStatus: http.StatusBadRequest},
+		{name: "窗口内续传", apiType: "Chat", token: token, lastEventID: "4", wantStatus: http.StatusOK, wantBody: "event: delta\ndata: {\"n\":5}\nid: 5\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			c.Request.Header.Set(StreamResumeTokenHeader, tt.token)
+			if tt.lastEventID != "" {
+				c.Request.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+
+			if !ResumeStream(c, envCfg, tt.apiType) {
+				t.Fatal("携带令牌的请求应由 ResumeStream 处理")
+			}
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body: %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantCode != "" && !strings.Contains(w.Body.String(), tt.wantCode) {
+				t.Fatalf("body = %s, want code %s", w.Body.String(), tt.wantCode)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Fatalf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	// 未开启时不处理续传请求头
+	disabled := &config.EnvConfig{}
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Request.Header.Set(StreamResumeTokenHeader, token)
+	if ResumeStream(c, disabled, "Chat") {
+		t.Fatal("未开启 ENABLE_STREAM_RESUME 时不应处理续传请求")
+	}
+}
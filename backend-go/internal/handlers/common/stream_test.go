@@ -6,6 +6,7 @@
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/BenedictKing/ccx/internal/config"
 	"github.com/BenedictKing/ccx/internal/utils"
@@ -533,3 +534,36 @@ func TestProcessStreamEvent_MessageStopInjectsUsageWhenMessageDeltaMissing(t *te
 		t.Fatalf("expected message_stop event to be forwarded, body=%s", body)
 	}
 }
+
+func TestProcessStreamEvent_CollectsThinkingTokensFromMessageDelta(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{}`))
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		t.Fatalf("response writer does not implement http.Flusher")
+	}
+
+	ctx := &StreamContext{ContentBlockTypes: make(map[int]string)}
+	envCfg := &config.EnvConfig{LogLevel: "info"}
+	requestBody := []byte(`{"messages":[{"role":"user","content":"hello"}]}`)
+
+	events := []string{
+		"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"usage\":{\"input_tokens\":120,\"output_tokens\":1}}}\n\n",
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":300,\"thinking_tokens\":250}}\n\n",
+	}
+	for _, event := range events {
+		ProcessStreamEvent(c, c.Writer, flusher, event, ctx, envCfg, requestBody)
+	}
+
+	if ctx.CollectedUsage.ThinkingTokens != 250 {
+		t.Fatalf("expected collected thinking tokens=250, got %d", ctx.CollectedUsage.ThinkingTokens)
+	}
+
+	usage := logStreamCompletion(ctx, envCfg, time.Now())
+	if usage == nil || usage.ThinkingTokens != 250 || usage.OutputTokens != 300 {
+		t.Fatalf("expected usage with thinking_tokens=250, output_tokens=300, got %+v", usage)
+	}
+}
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// partialUsageEventType 周期性用量事件的类型（Claude 流式协议约定客户端忽略未知事件类型）
+const partialUsageEventType = "usage"
+
+// newPartialUsageTicker 创建周期性用量事件的触发 channel
+// interval <= 0 时返回 nil channel（在 select 中永不触发），即默认不输出
+func newPartialUsageTicker(intervalSeconds int) (<-chan time.Time, func()) {
+	if intervalSeconds <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(time.Duration(intervalSeconds) * time.Second)
+	return ticker.C, ticker.Stop
+}
+
+// BuildPartialUsageEvent 构建周期性用量 SSE 事件（partial 标记为中间估算值，最终计费以 message_delta 为准）
+// ?format=ndjson 时由 ndjsonResponseWriter 转换为单行 JSON
+func BuildPartialUsageEvent(inputTokens, outputTokens int) string {
+	event := map[string]interface{}{
+		"type":    partialUsageEventType,
+		"partial": true,
+		"usage": map[string]int{
+			"input_tokens":  inputTokens,
+			"output_tokens": outputTokens,
+		},
+	}
+	eventJSON, _ := json.Marshal(event)
+	return fmt.Sprintf("event: %s\ndata: %s\n\n", partialUsageEventType, eventJSON)
+}
+
+// runningUsage 当前累计用量：输入优先取 message_start 上报值，输出取上游已上报值与按已输出文本估算值中的较大者
+func runningUsage(ctx *StreamContext, requestBody []byte) (inputTokens, outputTokens int) {
+	inputTokens = ctx.MessageStartInputTokens
+	if inputTokens <= 0 {
+		inputTokens = utils.EstimateRequestTokens(requestBody)
+	}
+	outputTokens = ctx.CollectedUsage.OutputTokens
+	if estimated := utils.EstimateTokens(ctx.OutputTextBuffer.String()); estimated > outputTokens {
+		outputTokens = estimated
+	}
+	return inputTokens, outputTokens
+}
+
+// writePartialUsageEvent 向客户端输出一次周期性用量事件（客户端已断开或事件类型被渠道黑名单过滤时跳过）
+func writePartialUsageEvent(w gin.ResponseWriter, flusher http.Flusher, ctx *StreamContext, requestBody []byte) {
+	if ctx.ClientGone {
+		return
+	}
+	event := BuildPartialUsageEvent(runningUsage(ctx, requestBody))
+	if ctx.EventFilter.IsDenied(event) {
+		return
+	}
+	if _, err := w.Write([]byte(event)); err != nil {
+		ctx.ClientGone = true
+		return
+	}
+	flusher.Flush()
+}
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/providers"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// TestHandleStreamResponse_PartialUsageEvents 启用后流式期间周期性输出用量事件，最终真实用量仍由 message_delta 输出
+func TestHandleStreamResponse_PartialUsageEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		write := func(event string) {
+			w.Write([]byte(event))
+			flusher.Flush()
+		}
+		write("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"model\":\"claude-test\",\"usage\":{\"input_tokens\":120,\"output_tokens\":1}}}\n\n")
+		// 持续输出约 2.4 秒，覆盖两个 1 秒的用量周期
+		for range 12 {
+			write("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hello world \"}}\n\n")
+			time.Sleep(200 * time.Millisecond)
+		}
+		write("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"input_tokens\":120,\"output_tokens\":42}}\n\n")
+		write("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}))
+	t.Cleanup(upstream.Close)
+
+	tests := []struct {
+		name     string
+		query    string
+		interval int
+		// 按输出格式拆分出事件负载
+		payloads func(body string) []string
+		wantMin  int
+	}{
+		{name: "SSE", query: "", interval: 1, payloads: ssePayloads, wantMin: 2},
+		{name: "NDJSON", query: "?format=ndjson", interval: 1, payloads: ndjsonPayloads, wantMin: 2},
+		{name: "默认关闭", query: "", interval: 0, payloads: ssePayloads, wantMin: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages"+tt.query, nil)
+
+			resp, err := http.Post(upstream.URL, "application/json", nil)
+			if err != nil {
+				t.Fatalf("上游请求失败: %v", err)
+			}
+
+			envCfg := &config.EnvConfig{LogLevel: "error", StreamUsageInterval: tt.interval}
+			usage, err := HandleStreamResponse(c, resp, &providers.ClaudeProvider{}, envCfg, time.Now(), &config.UpstreamConfig{Name: "usage-test"}, []byte(`{"model":"claude-test"}`), "claude-test")
+			if err != nil {
+				t.Fatalf("HandleStreamResponse() err = %v", err)
+			}
+			if usage == nil || usage.OutputTokens != 42 {
+				t.Fatalf("最终 usage = %+v, want output_tokens 42", usage)
+			}
+
+			var partial, finalOutput int
+			var lastPartialOutput int64
+			for _, payload := range tt.payloads(w.Body.String()) {
+				switch gjson.Get(payload, "type").String() {
+				case partialUsageEventType:
+					partial++
+					if !gjson.Get(payload, "partial").Bool() || gjson.Get(payload, "usage.input_tokens").Int() != 120 {
+						t.Fatalf("用量事件 = %s, want partial 且 input_tokens 120", payload)
+					}
+					output := gjson.Get(payload, "usage.output_tokens").Int()
+					if output <= lastPartialOutput {
+						t.Fatalf("用量事件 output_tokens 未随输出增长: %d -> %d", lastPartialOutput, output)
+					}
+					lastPartialOutput = output
+				case "message_delta":
+					if partial < tt.wantMin {
+						t.Fatalf("message_delta 之前的用量事件数 = %d, want >= %d", partial, tt.wantMin)
+					}
+					finalOutput = int(gjson.Get(payload, "usage.output_tokens").Int())
+				}
+			}
+			if tt.wantMin == 0 && partial != 0 {
+				t.Fatalf("未启用时输出了 %d 个用量事件", partial)
+			}
+			if finalOutput != 42 {
+				t.Fatalf("最终 message_delta output_tokens = %d, want 42", finalOutput)
+			}
+		})
+	}
+}
+
+func ssePayloads(body string) []string {
+	var payloads []string
+	for _, line := range strings.Split(body, "\n") {
+		if payload, ok := strings.CutPrefix(line, "data: "); ok {
+			payloads = append(payloads, payload)
+		}
+	}
+	return payloads
+}
+
+func ndjsonPayloads(body string) []string {
+	var payloads []string
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		if strings.HasPrefix(line, "event:") || strings.HasPrefix(line, "data:") {
+			return nil // NDJSON 输出中不应出现 SSE 帧
+		}
+		payloads = append(payloads, line)
+	}
+	return payloads
+}
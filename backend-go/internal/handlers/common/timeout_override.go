@@ -0,0 +1,68 @@
+package common
+
+import (
+	"context"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TimeoutOverrideHeader 客户端按请求覆盖上游超时（毫秒），需开启 ENABLE_TIMEOUT_OVERRIDE_HEADER
+const TimeoutOverrideHeader = "X-CCX-Timeout-Ms"
+
+type timeoutOverrideKey struct{}
+
+// ApplyTimeoutOverride 解析 X-CCX-Timeout-Ms 并写入请求 context
+// 上游请求沿用客户端请求的 context，由 SendRequest 读取；未开启、非法或非正数时忽略，超过上限时截断为 MAX_TIMEOUT_OVERRIDE_MS
+func ApplyTimeoutOverride(c *gin.Context, envCfg *config.EnvConfig) {
+	if !envCfg.EnableTimeoutOverrideHeader {
+		return
+	}
+	value := c.GetHeader(TimeoutOverrideHeader)
+	if value == "" {
+		return
+	}
+	timeout := parseTimeoutOverride(value, envCfg.MaxTimeoutOverrideMs)
+	if timeout <= 0 {
+		if envCfg.ShouldLog("warn") {
+			log.Printf("[Request-Timeout] 警告: 忽略无效的 %s: %q", TimeoutOverrideHeader, value)
+		}
+		return
+	}
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), timeoutOverrideKey{}, timeout))
+}
+
+// parseTimeoutOverride 解析毫秒数，非法或非正数返回 0，超过 maxMs（> 0 时）截断为 maxMs
+func parseTimeoutOverride(value string, maxMs int) time.Duration {
+	ms, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || ms <= 0 {
+		return 0
+	}
+	if maxMs > 0 && ms > maxMs {
+		ms = maxMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// timeoutOverrideFromContext 读取请求级超时覆盖（未设置时返回 0）
+func timeoutOverrideFromContext(ctx context.Context) time.Duration {
+	timeout, _ := ctx.Value(timeoutOverrideKey{}).(time.Duration)
+	return timeout
+}
+
+// cancelOnCloseBody 响应体关闭时释放请求级超时 context
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
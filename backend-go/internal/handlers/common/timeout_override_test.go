@@ -0,0 +1,103 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseTimeoutOverride(t *testing.T) {
+	tests := []struct {
+		value string
+		maxMs int
+		want  time.Duration
+	}{
+		{value: "1500", maxMs: 10000, want: 1500 * time.Millisecond},
+		{value: " 200 ", maxMs: 10000, want: 200 * time.Millisecond},
+		{value: "999999", maxMs: 10000, want: 10 * time.Second},
+		{value: "0", maxMs: 10000, want: 0},
+		{value: "-5", maxMs: 10000, want: 0},
+		{value: "abc", maxMs: 10000, want: 0},
+		{value: "1.5", maxMs: 10000, want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseTimeoutOverride(tt.value, tt.maxMs); got != tt.want {
+			t.Errorf("parseTimeoutOverride(%q, %d) = %v, want %v", tt.value, tt.maxMs, got, tt.want)
+		}
+	}
+}
+
+// TestSendRequest_TimeoutOverride 请求头覆盖本次请求的上游超时，且不超过配置的上限
+func TestSendRequest_TimeoutOverride(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// 上游 300ms 后才返回（非流式请求的响应头在处理完成后才到达）
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(300 * time.Millisecond):
+			w.Write([]byte(`{"ok":true}`))
+		case <-r.Context().Done():
+		}
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name        string
+		enabled     bool
+		maxMs       int
+		header      string
+		wantTimeout time.Duration // 0 表示未覆盖
+		wantErr     bool
+	}{
+		{name: "未携带请求头使用默认超时", enabled: true, maxMs: 5000, wantErr: true},
+		{name: "覆盖为更长超时", enabled: true, maxMs: 5000, header: "2000", wantTimeout: 2 * time.Second},
+		{name: "超过上限时截断", enabled: true, maxMs: 150, header: "2000", wantTimeout: 150 * time.Millisecond, wantErr: true},
+		{name: "无效值被忽略", enabled: true, maxMs: 5000, header: "soon", wantErr: true},
+		{name: "未开启时忽略请求头", enabled: false, maxMs: 5000, header: "2000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envCfg := &config.EnvConfig{
+				LogLevel:                    "error",
+				RequestTimeout:              100,
+				EnableTimeoutOverrideHeader: tt.enabled,
+				MaxTimeoutOverrideMs:        tt.maxMs,
+			}
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+			if tt.header != "" {
+				c.Request.Header.Set(TimeoutOverrideHeader, tt.header)
+			}
+			ApplyTimeoutOverride(c, envCfg)
+			if got := timeoutOverrideFromContext(c.Request.Context()); got != tt.wantTimeout {
+				t.Fatalf("override=%v, want %v", got, tt.wantTimeout)
+			}
+
+			req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, upstream.URL, nil)
+			if err != nil {
+				t.Fatalf("创建请求失败: %v", err)
+			}
+			resp, err := SendRequest(req, &config.UpstreamConfig{}, envCfg, false, "Messages")
+			if tt.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("期望超时错误，实际成功")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendRequest 失败: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status=%d, want 200", resp.StatusCode)
+			}
+		})
+	}
+}
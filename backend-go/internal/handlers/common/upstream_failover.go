@@ -7,6 +7,7 @@
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"time"
 
@@ -84,14 +85,39 @@ func TryUpstreamWithAllKeys(
 		return false, "", 0, nil, nil, nil
 	}
 
+	// 保存请求体，所有渠道都失败时写入死信以便重放
+	rememberDeadLetterRequest(c, requestBody, isStream)
+	bindDeadLetterTarget(c, channelScheduler, kind, model)
+
+	// 渠道并发排队：达到 maxConcurrent 时等待空闲槽位，超时视为可 failover 的渠道故障
+	release, err := channelScheduler.AcquireChannelSlot(c.Request.Context(), kind, channelIndex, upstream)
+	if err != nil {
+		if isClientSideError(err) {
+			log.Printf("[%s-Cancel] 请求已取消（渠道排队阶段）", apiType)
+			return true, "", 0, nil, nil, err
+		}
+		log.Printf("[%s-Queue] 警告: 渠道 [%d] %s 并发已满 (上限: %d)，排队 %dms 超时，尝试下一个渠道",
+			apiType, channelIndex, upstream.Name, upstream.MaxConcurrent, upstream.QueueTimeoutMs)
+		return false, "", 0, &FailoverError{
+			Status: http.StatusServiceUnavailable,
+			Body:   []byte(`{"error":{"type":"overloaded_error","message":"channel concurrency queue timeout"}}`),
+			Kind:   FailoverKindOverloaded,
+		}, nil, err
+	}
+	defer release()
+
 	var lastFailoverError *FailoverError
 	deprioritizeCandidates := make(map[string]bool)
 
-	// 计算重定向后的模型（用于日志记录）
-	redirectedModel := config.RedirectModel(model, upstream)
+	// 按归一化模型名命中的映射固定到请求使用的渠道副本上，计算重定向后的模型（用于日志记录）
+	requestUpstream := config.PinNormalizedModelMapping(upstream, model, cfgManager.NormalizeModel)
+	redirectedModel := config.RedirectModel(model, requestUpstream)
 	var originalModel string
+	metricsModel := redirectedModel
 	if redirectedModel != model {
 		originalModel = model // 仅当发生重定向时记录原始模型
+	} else {
+		metricsModel = cfgManager.NormalizeModel(model) // 未命中映射时按归一化名称聚合指标
 	}
 
 	// 强制探测模式：基于本次优先尝试的 BaseURL 判断（避免 BaseURL/BaseURLs 不一致导致误判）
@@ -100,6 +126,11 @@ func TryUpstreamWithAllKeys(
 		log.Printf("[%s-ForceProbe] 渠道 %s 所有 Key 都被熔断，启用强制探测模式", apiType, upstream.Name)
 	}
 
+	// Key 并行竞速（keySprayCount）：每个 BaseURL 的首轮同时向前 N 个 Key 发起请求
+	sprayCount := keySprayCount(upstream, envCfg)
+	var spray *keySpray
+	defer func() { spray.close() }()
+
 	for urlIdx, urlResult := range urlResults {
 		currentBaseURL := urlResult.URL
 		originalIdx := urlResult.OriginalIdx // 原始索引用于指标记录
@@ -109,44 +140,104 @@ func TryUpstreamWithAllKeys(
 		for attempt := 0; attempt < maxRetries; attempt++ {
 			RestoreRequestBody(c, requestBody)
 
-			apiKey, err := nextAPIKey(upstream, failedKeys)
-			if err != nil {
-				lastError = err
-				break // 当前 BaseURL 没有可用 Key，尝试下一个 BaseURL
+			if attempt == 0 && sprayCount > 1 {
+				spray.close()
+				spray = newKeySpray(apiType, currentBaseURL, kind, metricsManager, channelScheduler)
+				for spray.size() < sprayCount {
+					RestoreRequestBody(c, requestBody)
+					sprayKey, err := nextAPIKey(upstream, failedKeys)
+					if err != nil || failedKeys[sprayKey] {
+						break // 可用 Key 不足竞速数时按实际数量发起
+					}
+					if metricsManager.IsKeyRateLimited(currentBaseURL, sprayKey) ||
+						(!forceProbeMode && metricsManager.ShouldSuspendKey(currentBaseURL, sprayKey)) {
+						failedKeys[sprayKey] = true
+						continue
+					}
+					if !consumeFailoverAttempt(c, envCfg) {
+						break
+					}
+					sprayCopy := requestUpstream.Clone()
+					sprayCopy.BaseURL = currentBaseURL
+					req, err := buildRequest(c, sprayCopy, sprayKey)
+					if err != nil {
+						log.Printf("[%s-BuildRequest] 请求构建失败: %v", apiType, err)
+						return false, "", 0, nil, nil, fmt.Errorf("request build failed: %w", err)
+					}
+					failedKeys[sprayKey] = true // 已参与竞速的 Key 不再进入顺序 failover
+					spray.add(req, sprayCopy, sprayKey)
+				}
+				spray.launch(c.Request.Context(), metricsModel, func(req *http.Request) (*http.Response, error) {
+					return SendRequest(req, upstream, envCfg, isStream, apiType)
+				})
 			}
 
-			// 检查熔断状态
-			if !forceProbeMode && metricsManager.ShouldSuspendKey(currentBaseURL, apiKey) {
-				failedKeys[apiKey] = true
-				log.Printf("[%s-Circuit] 跳过熔断中的 Key: %s", apiType, utils.MaskAPIKey(apiKey))
-				continue
-			}
+			var (
+				apiKey       string
+				upstreamCopy *config.UpstreamConfig
+				requestID    uint64
+				attemptStart time.Time
+				resp         *http.Response
+				sprayAttempt *keySprayAttempt
+			)
+			if spray.pending() {
+				sprayAttempt = spray.next()
+				apiKey, upstreamCopy, requestID = sprayAttempt.apiKey, sprayAttempt.upstreamCopy, sprayAttempt.requestID
+				attemptStart, resp, err = sprayAttempt.attemptStart, sprayAttempt.resp, sprayAttempt.err
+			} else {
+				apiKey, err = nextAPIKey(upstream, failedKeys)
+				if err != nil {
+					lastError = err
+					break // 当前 BaseURL 没有可用 Key，尝试下一个 BaseURL
+				}
 
-			if envCfg.ShouldLog("info") {
-				log.Printf("[%s-Key] 使用API密钥: %s (BaseURL %d/%d, 尝试 %d/%d)",
-					apiType, utils.MaskAPIKey(apiKey), urlIdx+1, len(urlResults), attempt+1, maxRetries)
-			}
+				// 上游 Retry-After 暂停期内的 Key 即使在强制探测模式下也跳过
+				if metricsManager.IsKeyRateLimited(currentBaseURL, apiKey) {
+					failedKeys[apiKey] = true
+					log.Printf("[%s-RateLimit] 跳过上游限流暂停中的 Key: %s", apiType, utils.MaskAPIKey(apiKey))
+					continue
+				}
 
-			// 使用深拷贝避免并发修改问题
-			upstreamCopy := upstream.Clone()
-			upstreamCopy.BaseURL = currentBaseURL
+				// 检查熔断状态
+				if !forceProbeMode && metricsManager.ShouldSuspendKey(currentBaseURL, apiKey) {
+					failedKeys[apiKey] = true
+					log.Printf("[%s-Circuit] 跳过熔断中的 Key: %s", apiType, utils.MaskAPIKey(apiKey))
+					continue
+				}
 
-			req, err := buildRequest(c, upstreamCopy, apiKey)
-			if err != nil {
-				// buildRequest 失败通常是客户端参数问题或本地构建错误
-				// 不应污染熔断统计，直接返回错误
-				log.Printf("[%s-BuildRequest] 请求构建失败: %v", apiType, err)
-				return false, "", 0, nil, nil, fmt.Errorf("request build failed: %w", err)
-			}
+				// 跨渠道尝试总次数上限：额度耗尽时停止 failover，返回最后一次上游错误
+				if !consumeFailoverAttempt(c, envCfg) {
+					log.Printf("[%s-Failover] 已达到最大尝试次数 (%d)，停止 failover", apiType, envCfg.MaxFailoverAttempts)
+					return false, "", 0, lastFailoverError, nil, lastError
+				}
+
+				if envCfg.ShouldLog("info") {
+					log.Printf("[%s-Key] 使用API密钥: %s (BaseURL %d/%d, 尝试 %d/%d)",
+						apiType, utils.MaskAPIKey(apiKey), urlIdx+1, len(urlResults), attempt+1, maxRetries)
+				}
+
+				// 使用深拷贝避免并发修改问题
+				upstreamCopy = requestUpstream.Clone()
+				upstreamCopy.BaseURL = currentBaseURL
+
+				var req *http.Request
+				req, err = buildRequest(c, upstreamCopy, apiKey)
+				if err != nil {
+					// buildRequest 失败通常是客户端参数问题或本地构建错误
+					// 不应污染熔断统计，直接返回错误
+					log.Printf("[%s-BuildRequest] 请求构建失败: %v", apiType, err)
+					return false, "", 0, nil, nil, fmt.Errorf("request build failed: %w", err)
+				}
 
-			// 记录请求开始
-			channelScheduler.RecordRequestStart(currentBaseURL, apiKey, kind)
+				// 记录请求开始
+				channelScheduler.RecordRequestStart(currentBaseURL, apiKey, kind)
 
-			// TCP 建连开始即计数：将活跃度统计提前到发起上游请求之前
-			requestID := metricsManager.RecordRequestConnected(currentBaseURL, apiKey, redirectedModel)
+				// TCP 建连开始即计数：将活跃度统计提前到发起上游请求之前
+				requestID = metricsManager.RecordRequestConnected(currentBaseURL, apiKey, metricsModel)
 
-			attemptStart := time.Now()
-			resp, err := SendRequest(req, upstream, envCfg, isStream, apiType)
+				attemptStart = time.Now()
+				resp, err = SendRequest(req, upstream, envCfg, isStream, apiType)
+			}
 			if err != nil {
 				lastError = err
 				// 区分客户端取消和真实渠道故障（统一口径）
@@ -159,6 +250,8 @@ func TryUpstreamWithAllKeys(
 				}
 				// 真实渠道故障：计入失败，继续 failover
 				failedKeys[apiKey] = true
+				recordFailedAttempt(c, channelIndex, upstream, currentBaseURL, apiKey, 0, err.Error())
+				metricsManager.RecordKeyError(currentBaseURL, apiKey, classifyKeyError(0, err))
 				cfgManager.MarkKeyAsFailed(apiKey, apiType)
 				metricsManager.RecordRequestFinalizeFailure(currentBaseURL, apiKey, requestID)
 				channelScheduler.RecordRequestEnd(currentBaseURL, apiKey, kind)
@@ -183,6 +276,7 @@ func TryUpstreamWithAllKeys(
 						ErrorInfo:     errInfo,
 						IsRetry:       attempt > 0 || urlIdx > 0,
 						InterfaceType: apiType,
+						RequestID:     CorrelationID(c),
 					})
 				}
 				log.Printf("[%s-Key] 警告: API密钥失败: %v", apiType, err)
@@ -194,10 +288,19 @@ func TryUpstreamWithAllKeys(
 				resp.Body.Close()
 				respBodyBytes = utils.DecompressGzipIfNeeded(resp, respBodyBytes)
 
-				shouldFailover, isQuotaRelated := ShouldRetryWithNextKey(resp.StatusCode, respBodyBytes, cfgManager.GetFuzzyModeEnabled(), apiType)
+				// 429 携带 Retry-After 时按上游要求暂停该 Key，避免后续请求立即重试
+				if resp.StatusCode == http.StatusTooManyRequests {
+					if wait, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+						metricsManager.SuspendKeyUntil(currentBaseURL, apiKey, time.Now().Add(wait))
+					}
+				}
+
+				shouldFailover, isQuotaRelated := ShouldRetryWithNextKey(resp.StatusCode, respBodyBytes, cfgManager.GetFuzzyModeEnabled(), apiType, upstream)
 				if shouldFailover {
 					lastError = fmt.Errorf("上游错误: %d", resp.StatusCode)
 					failedKeys[apiKey] = true
+					recordFailedAttempt(c, channelIndex, upstream, currentBaseURL, apiKey, resp.StatusCode, string(respBodyBytes))
+					metricsManager.RecordKeyError(currentBaseURL, apiKey, classifyKeyError(resp.StatusCode, nil))
 					cfgManager.MarkKeyAsFailed(apiKey, apiType)
 					metricsManager.RecordRequestFinalizeFailure(currentBaseURL, apiKey, requestID)
 					channelScheduler.RecordRequestEnd(currentBaseURL, apiKey, kind)
@@ -209,6 +312,8 @@ func TryUpstreamWithAllKeys(
 					lastFailoverError = &FailoverError{
 						Status: resp.StatusCode,
 						Body:   respBodyBytes,
+						Header: resp.Header.Clone(),
+						Kind:   ClassifyFailoverKind(resp.StatusCode, isQuotaRelated),
 					}
 
 					// 记录渠道日志
@@ -229,6 +334,7 @@ func TryUpstreamWithAllKeys(
 							ErrorInfo:     errInfo,
 							IsRetry:       attempt > 0 || urlIdx > 0,
 							InterfaceType: apiType,
+							RequestID:     CorrelationID(c),
 						})
 					}
 
@@ -240,6 +346,7 @@ func TryUpstreamWithAllKeys(
 
 				// 非 failover 错误，记录失败指标后返回（请求已处理）
 				metricsManager.RecordRequestFinalizeFailure(currentBaseURL, apiKey, requestID)
+				metricsManager.RecordKeyError(currentBaseURL, apiKey, classifyKeyError(resp.StatusCode, nil))
 				channelScheduler.RecordRequestEnd(currentBaseURL, apiKey, kind)
 				// 记录渠道日志
 				if channelLogStore != nil {
@@ -259,12 +366,19 @@ func TryUpstreamWithAllKeys(
 						ErrorInfo:     errInfo,
 						IsRetry:       attempt > 0 || urlIdx > 0,
 						InterfaceType: apiType,
+						RequestID:     CorrelationID(c),
 					})
 				}
+				utils.ForwardAllowlistedResponseHeaders(resp.Header, c.Writer)
 				c.Data(resp.StatusCode, "application/json", respBodyBytes)
 				return true, "", 0, nil, nil, nil
 			}
 
+			// 竞速胜出：取消其余 Key 的请求（胜者 context 在函数返回时释放）
+			if sprayAttempt != nil {
+				spray.settle(sprayAttempt)
+			}
+
 			// 成功响应：处理 quota key 降级
 			if deprioritizeKey != nil && len(deprioritizeCandidates) > 0 {
 				for key := range deprioritizeCandidates {
@@ -276,6 +390,8 @@ func TryUpstreamWithAllKeys(
 				markURLSuccess(currentBaseURL)
 			}
 
+			// 协议转换的响应不会透传上游头部，这里先转发白名单中的限流头部
+			utils.ForwardAllowlistedResponseHeaders(resp.Header, c.Writer)
 			usage, err = handleSuccess(c, resp, upstreamCopy, apiKey)
 			if err != nil {
 				lastError = err
@@ -288,6 +404,8 @@ func TryUpstreamWithAllKeys(
 				} else if errors.Is(err, ErrEmptyStreamResponse) || errors.Is(err, ErrInvalidResponseBody) {
 					// 空响应或无效响应体（如 HTML）：Header 未发送，可安全 failover
 					failedKeys[apiKey] = true
+					recordFailedAttempt(c, channelIndex, upstream, currentBaseURL, apiKey, resp.StatusCode, err.Error())
+					metricsManager.RecordKeyError(currentBaseURL, apiKey, classifyKeyError(0, err))
 					cfgManager.MarkKeyAsFailed(apiKey, apiType)
 					metricsManager.RecordRequestFinalizeFailure(currentBaseURL, apiKey, requestID)
 					channelScheduler.RecordRequestEnd(currentBaseURL, apiKey, kind)
@@ -312,6 +430,7 @@ func TryUpstreamWithAllKeys(
 							ErrorInfo:     errInfo,
 							IsRetry:       attempt > 0 || urlIdx > 0,
 							InterfaceType: apiType,
+							RequestID:     CorrelationID(c),
 						})
 					}
 					log.Printf("[%s-InvalidResponse] 上游返回无效响应 (Key: %s): %v，尝试下一个密钥", apiType, utils.MaskAPIKey(apiKey), err)
@@ -320,6 +439,7 @@ func TryUpstreamWithAllKeys(
 					// 真实渠道故障：计入失败指标
 					cfgManager.MarkKeyAsFailed(apiKey, apiType)
 					metricsManager.RecordRequestFinalizeFailure(currentBaseURL, apiKey, requestID)
+					metricsManager.RecordKeyError(currentBaseURL, apiKey, classifyKeyError(0, err))
 					channelScheduler.RecordRequestEnd(currentBaseURL, apiKey, kind)
 					// 记录渠道日志
 					if channelLogStore != nil {
@@ -339,6 +459,7 @@ func TryUpstreamWithAllKeys(
 							ErrorInfo:     errInfo,
 							IsRetry:       attempt > 0 || urlIdx > 0,
 							InterfaceType: apiType,
+							RequestID:     CorrelationID(c),
 						})
 					}
 					log.Printf("[%s-Key] 警告: 响应处理失败: %v", apiType, err)
@@ -348,9 +469,17 @@ func TryUpstreamWithAllKeys(
 
 			metricsManager.RecordRequestFinalizeSuccess(currentBaseURL, apiKey, requestID, usage)
 			channelScheduler.RecordRequestEnd(currentBaseURL, apiKey, kind)
-			// 记录渠道日志
+			channelScheduler.RecordGlobalUsage(usage)
+			setServedChannel(c, servedChannel{
+				ChannelIndex:  channelIndex,
+				Model:         redirectedModel,
+				OriginalModel: originalModel,
+				BaseURL:       currentBaseURL,
+				KeyMask:       utils.MaskAPIKey(apiKey),
+			})
+			// 记录渠道日志（usage 由 handleSuccess 返回，流式请求为流结束时的最终值）
 			if channelLogStore != nil {
-				channelLogStore.Record(channelIndex, &metrics.ChannelLog{
+				channelLog := &metrics.ChannelLog{
 					Timestamp:     time.Now(),
 					Model:         redirectedModel,
 					OriginalModel: originalModel,
@@ -361,7 +490,13 @@ func TryUpstreamWithAllKeys(
 					BaseURL:       currentBaseURL,
 					IsRetry:       attempt > 0 || urlIdx > 0,
 					InterfaceType: apiType,
-				})
+					RequestID:     CorrelationID(c),
+				}
+				if usage != nil {
+					channelLog.InputTokens = usage.InputTokens
+					channelLog.OutputTokens = usage.OutputTokens
+				}
+				channelLogStore.Record(channelIndex, channelLog)
 			}
 			return true, apiKey, originalIdx, nil, usage, nil
 		}
@@ -387,3 +522,22 @@ func BuildDefaultURLResults(urls []string) []warmup.URLLatencyResult {
 	}
 	return results
 }
+
+// classifyKeyError 将上游失败归类为 Key 错误类型（用于 errorBreakdown 统计）
+// err 非空时按错误归类（超时/无效响应），否则按 HTTP 状态码归类；不在统计范围内的返回空字符串
+func classifyKeyError(status int, err error) string {
+	if err == nil {
+		return metrics.KeyErrorTypeForStatus(status)
+	}
+	if errors.Is(err, ErrEmptyStreamResponse) || errors.Is(err, ErrInvalidResponseBody) {
+		return metrics.KeyErrorInvalidResponse
+	}
+	if errors.Is(err, ErrUpstreamHeaderTimeout) || errors.Is(err, ErrStreamIdleTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return metrics.KeyErrorTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return metrics.KeyErrorTimeout
+	}
+	return ""
+}
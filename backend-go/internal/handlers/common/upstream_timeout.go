@@ -0,0 +1,108 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+)
+
+// ErrUpstreamHeaderTimeout 在连接 + 响应头超时时间内未收到上游响应头
+var ErrUpstreamHeaderTimeout = errors.New("upstream response header timeout")
+
+// ErrStreamIdleTimeout 流式响应在空闲超时时间内未收到任何数据
+var ErrStreamIdleTimeout = errors.New("upstream stream idle timeout")
+
+// ResolveUpstreamTimeouts 计算渠道生效的超时配置（渠道级配置优先，否则使用环境变量）
+// headerTimeout: 连接 + 等待响应头超时；idleTimeout: 流式响应两次读取之间的最大间隔（0 表示不限制）
+func ResolveUpstreamTimeouts(upstream *config.UpstreamConfig, envCfg *config.EnvConfig) (headerTimeout, idleTimeout time.Duration) {
+	headerTimeout = time.Duration(envCfg.ResponseHeaderTimeout) * time.Second
+	if upstream.ResponseHeaderTimeout > 0 {
+		headerTimeout = time.Duration(upstream.ResponseHeaderTimeout) * time.Second
+	}
+
+	idleTimeout = time.Duration(envCfg.StreamIdleTimeout) * time.Second
+	if upstream.StreamIdleTimeout > 0 {
+		idleTimeout = time.Duration(upstream.StreamIdleTimeout) * time.Second
+	}
+	return headerTimeout, idleTimeout
+}
+
+// doRequestWithTimeouts 发送请求并分别控制连接/响应头超时与流式空闲超时
+// - headerTimeout 覆盖建连、发送请求与等待响应头，收到响应头后即停止计时
+// - idleTimeout 仅在 > 0 时生效，每次读到数据重置计时，只在空闲超时时中断，不限制总时长
+func doRequestWithTimeouts(client *http.Client, req *http.Request, headerTimeout, idleTimeout time.Duration) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+
+	var headerTimedOut atomic.Bool
+	var headerTimer *time.Timer
+	if headerTimeout > 0 {
+		headerTimer = time.AfterFunc(headerTimeout, func() {
+			headerTimedOut.Store(true)
+			cancel()
+		})
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if headerTimer != nil {
+		headerTimer.Stop()
+	}
+	if headerTimedOut.Load() {
+		// 计时器可能在 Do 返回后、Stop 之前触发，此时 ctx 已取消，响应体不可用
+		if resp != nil {
+			resp.Body.Close()
+		}
+		cancel()
+		return nil, fmt.Errorf("%w (%v)", ErrUpstreamHeaderTimeout, headerTimeout)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	body := &timeoutBody{ReadCloser: resp.Body, cancel: cancel, idleTimeout: idleTimeout}
+	if idleTimeout > 0 {
+		body.idleTimer = time.AfterFunc(idleTimeout, func() {
+			body.idleTimedOut.Store(true)
+			cancel()
+		})
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// timeoutBody 包装上游响应体：空闲计时 + 关闭时释放请求 context
+type timeoutBody struct {
+	io.ReadCloser
+	cancel       context.CancelFunc
+	idleTimeout  time.Duration
+	idleTimer    *time.Timer
+	idleTimedOut atomic.Bool
+}
+
+// Read 读到数据时重置空闲计时；空闲超时导致的读取错误转换为 ErrStreamIdleTimeout
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.idleTimer != nil && !b.idleTimedOut.Load() {
+		b.idleTimer.Reset(b.idleTimeout)
+	}
+	if err != nil && err != io.EOF && b.idleTimedOut.Load() {
+		err = fmt.Errorf("%w (%v)", ErrStreamIdleTimeout, b.idleTimeout)
+	}
+	return n, err
+}
+
+// Close 停止空闲计时并释放请求 context
+func (b *timeoutBody) Close() error {
+	if b.idleTimer != nil {
+		b.idleTimer.Stop()
+	}
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+)
+
+func TestResolveUpstreamTimeouts(t *testing.T) {
+	envCfg := &config.EnvConfig{ResponseHeaderTimeout: 60, StreamIdleTimeout: 300}
+
+	header, idle := ResolveUpstreamTimeouts(&config.UpstreamConfig{}, envCfg)
+	if header != 60*time.Second || idle != 300*time.Second {
+		t.Fatalf("默认值 header=%v idle=%v, want 60s/300s", header, idle)
+	}
+
+	header, idle = ResolveUpstreamTimeouts(&config.UpstreamConfig{ResponseHeaderTimeout: 10, StreamIdleTimeout: 900}, envCfg)
+	if header != 10*time.Second || idle != 900*time.Second {
+		t.Fatalf("渠道覆盖 header=%v idle=%v, want 10s/900s", header, idle)
+	}
+}
+
+func TestDoRequestWithTimeouts(t *testing.T) {
+	tests := []struct {
+		name          string
+		handler       http.HandlerFunc
+		headerTimeout time.Duration
+		idleTimeout   time.Duration
+		wantDoErr     error
+		wantReadErr   error
+		wantBody      string
+	}{
+		{
+			name: "响应头延迟超过 headerTimeout",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(2 * time.Second):
+				case <-r.Context().Done():
+				}
+			},
+			headerTimeout: 100 * time.Millisecond,
+			idleTimeout:   time.Second,
+			wantDoErr:     ErrUpstreamHeaderTimeout,
+		},
+		{
+			name: "慢速流间隔小于 idleTimeout，总时长超过 idleTimeout 仍完成",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				flusher := w.(http.Flusher)
+				for i := 0; i < 6; i++ {
+					io.WriteString(w, "data: x\n\n")
+					flusher.Flush()
+					time.Sleep(60 * time.Millisecond)
+				}
+			},
+			headerTimeout: time.Second,
+			idleTimeout:   200 * time.Millisecond,
+			wantBody:      strings.Repeat("data: x\n\n", 6),
+		},
+		{
+			name: "流中途停顿超过 idleTimeout",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				io.WriteString(w, "data: x\n\n")
+				w.(http.Flusher).Flush()
+				select {
+				case <-time.After(2 * time.Second):
+				case <-r.Context().Done():
+				}
+			},
+			headerTimeout: time.Second,
+			idleTimeout:   150 * time.Millisecond,
+			wantReadErr:   ErrStreamIdleTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			resp, err := doRequestWithTimeouts(server.Client(), req, tt.headerTimeout, tt.idleTimeout)
+			if tt.wantDoErr != nil {
+				if !errors.Is(err, tt.wantDoErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantDoErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("doRequestWithTimeouts() err = %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if tt.wantReadErr != nil {
+				if !errors.Is(err, tt.wantReadErr) {
+					t.Fatalf("read err = %v, want %v", err, tt.wantReadErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read err = %v", err)
+			}
+			if string(body) != tt.wantBody {
+				t.Fatalf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
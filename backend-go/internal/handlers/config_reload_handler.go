@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// ReloadConfig 从磁盘热重载配置文件（外部编辑配置后无需重启服务）
+// POST /api/config/reload
+func ReloadConfig(cfgManager *config.ConfigManager, sch *scheduler.ChannelScheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		summary, err := cfgManager.ReloadConfig()
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Failed to reload config: " + err.Error()})
+			return
+		}
+
+		// 渠道增删后索引可能平移，清除按索引缓存的 URL 排序状态
+		if summary.HasChanges() {
+			sch.InvalidateAllURLCaches()
+		}
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"summary": summary,
+		})
+	}
+}
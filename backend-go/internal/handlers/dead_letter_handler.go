@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/chat"
+	"github.com/BenedictKing/ccx/internal/handlers/gemini"
+	"github.com/BenedictKing/ccx/internal/handlers/messages"
+	"github.com/BenedictKing/ccx/internal/handlers/responses"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/session"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultDeadLetterLimit = 50
+
+// GetDeadLetters 获取最近所有渠道都失败的请求记录（用于事后排查）
+// GET /api/dead-letters?kind=messages|responses|gemini|chat&limit=50
+func GetDeadLetters(store *metrics.DeadLetterStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kind := strings.ToLower(c.Query("kind"))
+		switch kind {
+		case "", "messages", "responses", "gemini", "chat":
+		default:
+			c.JSON(400, gin.H{"error": "Invalid kind. Use: messages, responses, gemini, or chat"})
+			return
+		}
+
+		limit := defaultDeadLetterLimit
+		if limitStr := c.Query("limit"); limitStr != "" {
+			parsed, err := strconv.Atoi(limitStr)
+			if err != nil || parsed <= 0 {
+				c.JSON(400, gin.H{"error": "Invalid limit"})
+				return
+			}
+			limit = parsed
+		}
+
+		entries := store.GetRecent(kind, limit)
+		c.JSON(200, gin.H{
+			"entries": entries,
+			"total":   len(entries),
+		})
+	}
+}
+
+// ReplayDeadLetterRequest 死信重放参数
+type ReplayDeadLetterRequest struct {
+	ChannelIndex int `json:"channelIndex"`
+}
+
+// ReplayDeadLetter 将死信中保存的原始请求重放到指定渠道（仅在该渠道内的 Key/BaseURL 之间 failover）
+// POST /api/dead-letters/:id/replay
+// 上游响应（含流式响应）直接作为本接口的响应返回，失败时返回与原接口一致的错误格式
+func ReplayDeadLetter(envCfg *config.EnvConfig, cfgManager *config.ConfigManager, sessionManager *session.SessionManager, sch *scheduler.ChannelScheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id <= 0 {
+			c.JSON(400, gin.H{"error": "Invalid dead letter ID"})
+			return
+		}
+		var req ReplayDeadLetterRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		entry, ok := sch.GetDeadLetterStore().Get(id)
+		if !ok {
+			c.JSON(404, gin.H{"error": "Dead letter not found"})
+			return
+		}
+		if !entry.Replayable {
+			c.JSON(409, gin.H{"error": "Dead letter has no stored request body and cannot be replayed"})
+			return
+		}
+
+		kind := scheduler.ChannelKind(entry.Kind)
+		upstreams := sch.GetUpstreams(kind)
+		if req.ChannelIndex < 0 || req.ChannelIndex >= len(upstreams) {
+			c.JSON(404, gin.H{"error": "Channel not found"})
+			return
+		}
+		upstream := upstreams[req.ChannelIndex].Clone()
+
+		log.Printf("[DeadLetter-Replay] 重放死信 #%d (%s, 模型: %s) 到渠道 [%d] %s",
+			entry.ID, entry.Kind, entry.Model, req.ChannelIndex, upstream.Name)
+
+		var succeeded bool
+		switch kind {
+		case scheduler.ChannelKindMessages:
+			succeeded, err = messages.ReplayToChannel(c, envCfg, cfgManager, sch, upstream, req.ChannelIndex, entry.RequestBody)
+		case scheduler.ChannelKindResponses:
+			succeeded, err = responses.ReplayToChannel(c, envCfg, cfgManager, sessionManager, sch, upstream, req.ChannelIndex, entry.RequestBody)
+		case scheduler.ChannelKindGemini:
+			succeeded, err = gemini.ReplayToChannel(c, envCfg, cfgManager, sch, upstream, req.ChannelIndex, entry.RequestBody, entry.Model, entry.Stream)
+		case scheduler.ChannelKindChat:
+			succeeded, err = chat.ReplayToChannel(c, envCfg, cfgManager, sch, upstream, req.ChannelIndex, entry.RequestBody)
+		default:
+			c.JSON(400, gin.H{"error": "Unsupported dead letter kind: " + entry.Kind})
+			return
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if succeeded {
+			log.Printf("[DeadLetter-Replay] 死信 #%d 重放成功", entry.ID)
+		} else {
+			log.Printf("[DeadLetter-Replay] 警告: 死信 #%d 重放失败", entry.ID)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/messages"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/session"
+	"github.com/BenedictKing/ccx/internal/warmup"
+	"github.com/gin-gonic/gin"
+)
+
+func TestReplayDeadLetter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// 两个渠道起初都不可用，请求写入死信；随后 backup 恢复，重放到 backup
+	var backupHealthy atomic.Bool
+	var backupBody atomic.Value
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"overloaded"}}`))
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !backupHealthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"overloaded"}}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		backupBody.Store(string(body))
+		_, _ = w.Write([]byte(`{"id":"msg_replay","type":"message","role":"assistant","content":[{"type":"text","text":"replayed"}],"model":"claude-test","stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`))
+	}))
+	defer backup.Close()
+
+	cfg := config.Config{Upstream: []config.UpstreamConfig{
+		{Name: "primary", BaseURL: primary.URL, APIKeys: []string{"sk-primary"}, ServiceType: "claude", Status: "active", Priority: 1},
+		{Name: "backup", BaseURL: backup.URL, APIKeys: []string{"sk-backup"}, ServiceType: "claude", Status: "active", Priority: 2},
+	}}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		t.Fatalf("序列化配置失败: %v", err)
+	}
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	cfgManager, err := config.NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("创建配置管理器失败: %v", err)
+	}
+	t.Cleanup(func() { cfgManager.Close() })
+
+	messagesMetrics := metrics.NewMetricsManager()
+	responsesMetrics := metrics.NewMetricsManager()
+	geminiMetrics := metrics.NewMetricsManager()
+	chatMetrics := metrics.NewMetricsManager()
+	t.Cleanup(func() {
+		messagesMetrics.Stop()
+		responsesMetrics.Stop()
+		geminiMetrics.Stop()
+		chatMetrics.Stop()
+	})
+	sch := scheduler.NewChannelScheduler(cfgManager, messagesMetrics, responsesMetrics, geminiMetrics, chatMetrics, session.NewTraceAffinityManager(), warmup.NewURLManager(30*time.Second, 3))
+
+	envCfg := &config.EnvConfig{
+		ProxyAccessKey:     "test-key",
+		LogLevel:           "error",
+		RequestTimeout:     5000,
+		MaxRequestBodySize: 1024 * 1024,
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", messages.Handler(envCfg, cfgManager, sch))
+	r.POST("/api/dead-letters/:id/replay", ReplayDeadLetter(envCfg, cfgManager, nil, sch))
+
+	reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"replay me"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "test-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("原始请求 status=%d, want 503, body=%s", w.Code, w.Body.String())
+	}
+
+	entries := sch.GetDeadLetterStore().GetRecent("messages", 0)
+	if len(entries) != 1 || !entries[0].Replayable {
+		t.Fatalf("死信 = %+v, want 1 条可重放记录", entries)
+	}
+	entryID := strconv.FormatInt(entries[0].ID, 10)
+
+	// 请求体无法解析的死信：重放返回 400 而不是带着零值请求发往上游
+	invalid := &metrics.DeadLetterEntry{Kind: "messages", Model: "claude-test", RequestBody: []byte(`{"model":`)}
+	sch.GetDeadLetterStore().Record(invalid)
+	invalidID := strconv.FormatInt(invalid.ID, 10)
+
+	replay := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/dead-letters/"+id+"/replay", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name     string
+		id       string
+		body     string
+		wantCode int
+	}{
+		{name: "非法 ID", id: "abc", body: `{"channelIndex":1}`, wantCode: http.StatusBadRequest},
+		{name: "死信不存在", id: "999", body: `{"channelIndex":1}`, wantCode: http.StatusNotFound},
+		{name: "渠道不存在", id: entryID, body: `{"channelIndex":5}`, wantCode: http.StatusNotFound},
+		{name: "请求体无法解析", id: invalidID, body: `{"channelIndex":1}`, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := replay(tt.id, tt.body); w.Code != tt.wantCode {
+				t.Fatalf("status=%d, want=%d, body=%s", w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+
+	backupHealthy.Store(true)
+	w = replay(entryID, `{"channelIndex":1}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("重放 status=%d, want 200, body=%s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "replayed") {
+		t.Fatalf("重放响应应为上游结果, got %s", w.Body.String())
+	}
+	sent, _ := backupBody.Load().(string)
+	if !strings.Contains(sent, "replay me") || !strings.Contains(sent, "claude-test") {
+		t.Fatalf("上游收到的请求体应为死信中保存的原始请求, got %s", sent)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// GetEffectiveChannelConfig 返回各渠道解析默认值后的生效配置（调度器实际看到的状态、优先级、地址与熔断参数）
+// GET /api/channels/effective?kind=messages|responses|gemini|chat
+func GetEffectiveChannelConfig(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kind := strings.ToLower(c.DefaultQuery("kind", "messages"))
+
+		cfg := cfgManager.GetConfig()
+		var upstreams []config.UpstreamConfig
+		switch kind {
+		case "messages":
+			upstreams = cfg.Upstream
+		case "responses":
+			upstreams = cfg.ResponsesUpstream
+		case "gemini":
+			upstreams = cfg.GeminiUpstream
+		case "chat":
+			upstreams = cfg.ChatUpstream
+		default:
+			c.JSON(400, gin.H{"error": "Invalid kind. Use: messages, responses, gemini, or chat"})
+			return
+		}
+
+		channels := make([]gin.H, len(upstreams))
+		for i := range upstreams {
+			channels[i] = effectiveChannelConfig(&upstreams[i], i)
+		}
+
+		c.JSON(200, gin.H{
+			"kind":     kind,
+			"channels": channels,
+		})
+	}
+}
+
+// effectiveChannelConfig 将渠道的隐式默认值展开为显式值（不返回 API Key 明文）
+func effectiveChannelConfig(up *config.UpstreamConfig, index int) gin.H {
+	// 熔断参数为 0 时使用全局默认；低质量渠道未显式配置时使用更宽松的覆盖值
+	failureThreshold, recoveryTime := up.CircuitOverrides()
+
+	supportedModels := up.SupportedModels
+	if len(supportedModels) == 0 {
+		supportedModels = []string{"*"}
+	}
+
+	channel := gin.H{
+		"index":                   index,
+		"name":                    up.Name,
+		"serviceType":             up.ServiceType,
+		"status":                  config.GetChannelStatus(up),
+		"priority":                config.GetChannelPriority(up, index),
+		"inPromotion":             config.IsChannelInPromotion(up),
+		"baseUrls":                up.GetAllBaseURLs(),
+		"proxyUrls":               up.GetAllProxyURLs(),
+		"apiKeyCount":             len(up.APIKeys),
+		"supportedModels":         supportedModels,
+		"circuitFailureThreshold": failureThreshold,
+		"circuitRecoverySeconds":  int(recoveryTime.Seconds()),
+		"canaryPercent":           up.CanaryPercent,
+		"maxConcurrent":           up.MaxConcurrent,
+		"keySprayCount":           up.KeySprayCount,
+	}
+	if up.ServiceType == "azure" {
+		apiVersion := up.APIVersion
+		if apiVersion == "" {
+			apiVersion = config.DefaultAzureAPIVersion
+		}
+		channel["apiVersion"] = apiVersion
+	}
+	return channel
+}
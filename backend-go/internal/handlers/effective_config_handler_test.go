@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetEffectiveChannelConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Config{
+		ChatUpstream: []config.UpstreamConfig{
+			{Name: "explicit", ServiceType: "openai", BaseURL: "https://a.example.com", APIKeys: []string{"sk-a"}, Status: "suspended", Priority: 5},
+			{Name: "implicit", ServiceType: "openai", BaseURLs: []string{"https://b.example.com", "https://b2.example.com"}, APIKeys: []string{"sk-b", "sk-b2"}, LowQuality: true},
+			{Name: "azure", ServiceType: "azure", BaseURL: "https://c.example.com", APIKeys: []string{"sk-c"}, SupportedModels: []string{"gpt-4*"}},
+		},
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("序列化配置失败: %v", err)
+	}
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	cfgManager, err := config.NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("创建配置管理器失败: %v", err)
+	}
+	t.Cleanup(func() { cfgManager.Close() })
+
+	r := gin.New()
+	r.GET("/channels/effective", GetEffectiveChannelConfig(cfgManager))
+
+	req := httptest.NewRequest(http.MethodGet, "/channels/effective?kind=chat", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("status=%d, body=%s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Channels []struct {
+			Name                    string   `json:"name"`
+			Status                  string   `json:"status"`
+			Priority                int      `json:"priority"`
+			BaseURLs                []string `json:"baseUrls"`
+			APIKeyCount             int      `json:"apiKeyCount"`
+			SupportedModels         []string `json:"supportedModels"`
+			CircuitFailureThreshold float64  `json:"circuitFailureThreshold"`
+			APIVersion              string   `json:"apiVersion"`
+		} `json:"channels"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if len(resp.Channels) != 3 {
+		t.Fatalf("channels 数量 = %d, want 3", len(resp.Channels))
+	}
+
+	explicit, implicit, azure := resp.Channels[0], resp.Channels[1], resp.Channels[2]
+	if explicit.Status != "suspended" || explicit.Priority != 5 {
+		t.Errorf("显式配置 = %s/%d, want suspended/5", explicit.Status, explicit.Priority)
+	}
+	if implicit.Status != "active" || implicit.Priority != 1 {
+		t.Errorf("未设置 status/priority 解析为 %s/%d, want active/1（按索引）", implicit.Status, implicit.Priority)
+	}
+	if len(implicit.BaseURLs) != 2 || implicit.APIKeyCount != 2 || len(implicit.SupportedModels) != 1 || implicit.SupportedModels[0] != "*" {
+		t.Errorf("implicit 渠道生效配置 = %+v", implicit)
+	}
+	if implicit.CircuitFailureThreshold != config.LowQualityCircuitFailureThreshold {
+		t.Errorf("低质量渠道熔断阈值 = %v, want %v", implicit.CircuitFailureThreshold, config.LowQualityCircuitFailureThreshold)
+	}
+	if azure.Priority != 2 || azure.APIVersion != config.DefaultAzureAPIVersion {
+		t.Errorf("azure 渠道 = priority %d, apiVersion %q", azure.Priority, azure.APIVersion)
+	}
+	if strings.Contains(w.Body.String(), "sk-") {
+		t.Errorf("响应不应包含 API Key 明文: %s", w.Body.String())
+	}
+
+	// 无效 kind
+	req = httptest.NewRequest(http.MethodGet, "/channels/effective?kind=unknown", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 400 {
+		t.Fatalf("无效 kind status=%d, want 400", w.Code)
+	}
+}
@@ -52,8 +52,32 @@ func GetUpstreams(cfgManager *config.ConfigManager) gin.HandlerFunc {
 				"injectDummyThoughtSignature": up.InjectDummyThoughtSignature,
 				"stripThoughtSignature":       up.StripThoughtSignature,
 				"customHeaders":               up.CustomHeaders,
+				"forwardHeaderAllowlist":      up.ForwardHeaderAllowlist,
+				"stripHeaders":                up.StripHeaders,
 				"proxyUrl":                    up.ProxyURL,
+				"proxyUrls":                   up.ProxyURLs,
+				"compressUpstreamRequests":    up.CompressUpstreamRequests,
+				"failoverStatusCodes":         up.FailoverStatusCodes,
+				"noFailoverStatusCodes":       up.NoFailoverStatusCodes,
+				"deployment":                  up.Deployment,
+				"apiVersion":                  up.APIVersion,
+				"caCertPath":                  up.CACertPath,
+				"clientCertPath":              up.ClientCertPath,
+				"clientKeyPath":               up.ClientKeyPath,
+				"serverName":                  up.ServerName,
 				"supportedModels":             up.SupportedModels,
+				"autoReorderKeys":             up.AutoReorderKeys,
+				"responseHeaderTimeout":       up.ResponseHeaderTimeout,
+				"streamIdleTimeout":           up.StreamIdleTimeout,
+				"maxConcurrent":               up.MaxConcurrent,
+				"queueTimeoutMs":              up.QueueTimeoutMs,
+				"dailyTokenBudget":            up.DailyTokenBudget,
+				"streamEventDenylist":         up.StreamEventDenylist,
+				"bodyTransforms":              up.BodyTransforms,
+				"circuitFailureThreshold":     up.CircuitFailureThreshold,
+				"circuitRecoverySeconds":      up.CircuitRecoverySeconds,
+				"canaryPercent":               up.CanaryPercent,
+				"keySprayCount":               up.KeySprayCount,
 			}
 		}
 
@@ -97,6 +121,7 @@ func UpdateUpstream(cfgManager *config.ConfigManager, sch *scheduler.ChannelSche
 			return
 		}
 
+		previousKeys := sch.ChannelAPIKeys(id, scheduler.ChannelKindGemini)
 		shouldResetMetrics, err := cfgManager.UpdateGeminiUpstream(id, updates)
 		if err != nil {
 			c.JSON(500, gin.H{"error": err.Error()})
@@ -106,6 +131,9 @@ func UpdateUpstream(cfgManager *config.ConfigManager, sch *scheduler.ChannelSche
 		// 单 key 更换时重置熔断状态
 		if shouldResetMetrics {
 			sch.ResetChannelMetrics(id, scheduler.ChannelKindGemini)
+		} else if updates.APIKeys != nil {
+			// 新增 key 时重置其熔断状态（可能从历史列表恢复）
+			sch.ResetAddedKeysFailureState(id, scheduler.ChannelKindGemini, previousKeys)
 		}
 
 		c.JSON(200, gin.H{"message": "Gemini upstream updated successfully"})
@@ -133,13 +161,14 @@ func DeleteUpstream(cfgManager *config.ConfigManager, channelScheduler *schedule
 		}
 
 		channelScheduler.GetChannelLogStore(scheduler.ChannelKindGemini).ClearAll()
+		channelScheduler.ResetChannelSelectionCounts(scheduler.ChannelKindGemini)
 
 		c.JSON(200, gin.H{"message": "Gemini upstream deleted successfully"})
 	}
 }
 
 // AddApiKey 添加 Gemini 渠道 API 密钥
-func AddApiKey(cfgManager *config.ConfigManager) gin.HandlerFunc {
+func AddApiKey(cfgManager *config.ConfigManager, sch *scheduler.ChannelScheduler) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		idStr := c.Param("id")
 		id, err := strconv.Atoi(idStr)
@@ -156,6 +185,7 @@ func AddApiKey(cfgManager *config.ConfigManager) gin.HandlerFunc {
 			return
 		}
 
+		previousKeys := sch.ChannelAPIKeys(id, scheduler.ChannelKindGemini)
 		if err := cfgManager.AddGeminiAPIKey(id, req.APIKey); err != nil {
 			if strings.Contains(err.Error(), "无效的上游索引") {
 				c.JSON(404, gin.H{"error": "Upstream not found"})
@@ -167,6 +197,9 @@ func AddApiKey(cfgManager *config.ConfigManager) gin.HandlerFunc {
 			return
 		}
 
+		// 新增 key 可能从历史列表恢复，重置其熔断状态（与 PUT 更新渠道一致）
+		sch.ResetAddedKeysFailureState(id, scheduler.ChannelKindGemini, previousKeys)
+
 		c.JSON(200, gin.H{
 			"message": "API密钥已添加",
 			"success": true,
@@ -29,12 +29,22 @@ func Handler(
 	channelScheduler *scheduler.ChannelScheduler,
 ) gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
+		common.EnsureCorrelationID(c)
+
 		// Gemini 代理端点统一使用代理访问密钥鉴权（x-api-key / Authorization: Bearer）
 		middleware.ProxyAuthMiddleware(envCfg)(c)
 		if c.IsAborted() {
 			return
 		}
 
+		// 请求级超时覆盖（X-CCX-Timeout-Ms，需开启 ENABLE_TIMEOUT_OVERRIDE_HEADER）
+		common.ApplyTimeoutOverride(c, envCfg)
+
+		// 全局限流（未配置 globalMaxRpm/globalMaxTpm 时不生效）
+		if !common.CheckGlobalRateLimit(c, channelScheduler, "Gemini") {
+			return
+		}
+
 		startTime := time.Now()
 
 		// 读取原始请求体
@@ -77,8 +87,27 @@ func Handler(
 			return
 		}
 
+		// 模型访问控制（allowedModels/deniedModels）
+		if !common.CheckModelAccess(c, cfgManager, string(scheduler.ChannelKindGemini), "Gemini", model) {
+			return
+		}
+
 		// 判断是否流式
 		isStream := strings.Contains(c.Request.URL.Path, "streamGenerateContent")
+		if isStream {
+			release, ok := common.AcquireClientStream(c, envCfg, "Gemini")
+			if !ok {
+				return
+			}
+			defer release()
+
+			// 断线续传：携带续传令牌时回放缓冲事件，否则为本次流分配令牌（ENABLE_STREAM_RESUME）
+			if common.ResumeStream(c, envCfg, "Gemini") {
+				return
+			}
+			finishResume := common.BeginResumableStream(c, envCfg, "Gemini")
+			defer finishResume()
+		}
 
 		// 提取对话标识用于 Trace 亲和性
 		userID := common.ExtractConversationID(c, bodyBytes)
@@ -86,8 +115,8 @@ func Handler(
 		// 记录原始请求信息
 		common.LogOriginalRequest(c, bodyBytes, envCfg, "Gemini")
 
-		// 检查是否为多渠道模式
-		isMultiChannel := channelScheduler.IsMultiChannelMode(scheduler.ChannelKindGemini)
+		// 检查是否为多渠道模式（携带固定渠道请求头时统一走多渠道流程）
+		isMultiChannel := channelScheduler.IsMultiChannelMode(scheduler.ChannelKindGemini) || common.HasChannelPin(c, envCfg)
 
 		if isMultiChannel {
 			handleMultiChannel(c, envCfg, cfgManager, channelScheduler, bodyBytes, &geminiReq, model, isStream, userID, startTime)
@@ -124,7 +153,6 @@ func handleMultiChannel(
 	userID string,
 	startTime time.Time,
 ) {
-	metricsManager := channelScheduler.GetGeminiMetricsManager()
 	common.HandleMultiChannelFailover(
 		c,
 		envCfg,
@@ -134,68 +162,87 @@ func handleMultiChannel(
 		userID,
 		model,
 		func(selection *scheduler.SelectionResult) common.MultiChannelAttemptResult {
-			upstream := selection.Upstream
-			channelIndex := selection.ChannelIndex
-
-			if upstream == nil {
-				return common.MultiChannelAttemptResult{}
-			}
-
-			baseURLs := upstream.GetAllBaseURLs()
-			sortedURLResults := channelScheduler.GetSortedURLsForChannel(scheduler.ChannelKindGemini, channelIndex, baseURLs)
-
-			handled, successKey, successBaseURLIdx, failoverErr, usage, lastErr := common.TryUpstreamWithAllKeys(
-				c,
-				envCfg,
-				cfgManager,
-				channelScheduler,
-				scheduler.ChannelKindGemini,
-				"Gemini",
-				metricsManager,
-				upstream,
-				sortedURLResults,
-				bodyBytes,
-				isStream,
-				func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error) {
-					return cfgManager.GetNextGeminiAPIKey(upstream, failedKeys)
-				},
-				func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
-					return buildProviderRequest(c, upstreamCopy, upstreamCopy.BaseURL, apiKey, geminiReq, model, isStream)
-				},
-				func(apiKey string) {
-					_ = cfgManager.DeprioritizeAPIKey(apiKey)
-				},
-				func(url string) {
-					channelScheduler.MarkURLFailure(scheduler.ChannelKindGemini, channelIndex, url)
-				},
-				func(url string) {
-					channelScheduler.MarkURLSuccess(scheduler.ChannelKindGemini, channelIndex, url)
-				},
-				func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, apiKey string) (*types.Usage, error) {
-					return handleSuccess(c, resp, upstreamCopy.ServiceType, envCfg, startTime, geminiReq, model, isStream)
-				},
-				model,
-				selection.ChannelIndex,
-				channelScheduler.GetChannelLogStore(scheduler.ChannelKindGemini),
-			)
-
-			return common.MultiChannelAttemptResult{
-				Handled:           handled,
-				Attempted:         true,
-				SuccessKey:        successKey,
-				SuccessBaseURLIdx: successBaseURLIdx,
-				FailoverError:     failoverErr,
-				Usage:             usage,
-				LastError:         lastErr,
+			return tryChannel(c, envCfg, cfgManager, channelScheduler, selection.Upstream, selection.ChannelIndex, bodyBytes, geminiReq, model, isStream, startTime)
+		},
+		func(selection *scheduler.SelectionResult, result common.MultiChannelAttemptResult) {
+			if result.SuccessKey != "" {
+				replayToShadowChannel(c, envCfg, cfgManager, channelScheduler, selection.ChannelIndex, bodyBytes, model, isStream)
 			}
 		},
-		nil,
 		func(ctx *gin.Context, failoverErr *common.FailoverError, lastError error) {
 			handleAllChannelsFailed(ctx, failoverErr, lastError)
 		},
 	)
 }
 
+// tryChannel 在指定渠道内按 Key/BaseURL 轮转尝试请求（多渠道调度与死信重放共用）
+func tryChannel(
+	c *gin.Context,
+	envCfg *config.EnvConfig,
+	cfgManager *config.ConfigManager,
+	channelScheduler *scheduler.ChannelScheduler,
+	upstream *config.UpstreamConfig,
+	channelIndex int,
+	bodyBytes []byte,
+	geminiReq *types.GeminiRequest,
+	model string,
+	isStream bool,
+	startTime time.Time,
+) common.MultiChannelAttemptResult {
+	if upstream == nil {
+		return common.MultiChannelAttemptResult{}
+	}
+
+	metricsManager := channelScheduler.GetGeminiMetricsManager()
+	baseURLs := upstream.GetAllBaseURLs()
+	sortedURLResults := channelScheduler.GetSortedURLsForChannel(scheduler.ChannelKindGemini, channelIndex, baseURLs)
+
+	handled, successKey, successBaseURLIdx, failoverErr, usage, lastErr := common.TryUpstreamWithAllKeys(
+		c,
+		envCfg,
+		cfgManager,
+		channelScheduler,
+		scheduler.ChannelKindGemini,
+		"Gemini",
+		metricsManager,
+		upstream,
+		sortedURLResults,
+		bodyBytes,
+		isStream,
+		func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error) {
+			return cfgManager.GetNextGeminiAPIKey(upstream, failedKeys)
+		},
+		func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
+			return buildProviderRequest(c, upstreamCopy, upstreamCopy.BaseURL, apiKey, geminiReq, model, isStream)
+		},
+		func(apiKey string) {
+			_ = cfgManager.DeprioritizeAPIKey(apiKey)
+		},
+		func(url string) {
+			channelScheduler.MarkURLFailure(scheduler.ChannelKindGemini, channelIndex, url)
+		},
+		func(url string) {
+			channelScheduler.MarkURLSuccess(scheduler.ChannelKindGemini, channelIndex, url)
+		},
+		func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, apiKey string) (*types.Usage, error) {
+			return handleSuccess(c, resp, upstreamCopy.ServiceType, bodyBytes, envCfg, startTime, geminiReq, model, isStream)
+		},
+		model,
+		channelIndex,
+		channelScheduler.GetChannelLogStore(scheduler.ChannelKindGemini),
+	)
+
+	return common.MultiChannelAttemptResult{
+		Handled:           handled,
+		Attempted:         true,
+		SuccessKey:        successKey,
+		SuccessBaseURLIdx: successBaseURLIdx,
+		FailoverError:     failoverErr,
+		Usage:             usage,
+		LastError:         lastErr,
+	}
+}
+
 // handleSingleChannel 处理单渠道 Gemini 请求
 func handleSingleChannel(
 	c *gin.Context,
@@ -235,7 +282,7 @@ func handleSingleChannel(
 	baseURLs := upstream.GetAllBaseURLs()
 	urlResults := common.BuildDefaultURLResults(baseURLs)
 
-	handled, _, _, lastFailoverError, _, lastError := common.TryUpstreamWithAllKeys(
+	handled, successKey, _, lastFailoverError, _, lastError := common.TryUpstreamWithAllKeys(
 		c,
 		envCfg,
 		cfgManager,
@@ -259,13 +306,16 @@ func(apiKey string) {
 		nil,
 		nil,
 		func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, apiKey string) (*types.Usage, error) {
-			return handleSuccess(c, resp, upstreamCopy.ServiceType, envCfg, startTime, geminiReq, model, isStream)
+			return handleSuccess(c, resp, upstreamCopy.ServiceType, bodyBytes, envCfg, startTime, geminiReq, model, isStream)
 		},
 		model,
 		channelIndex,
 		channelScheduler.GetChannelLogStore(scheduler.ChannelKindGemini),
 	)
 	if handled {
+		if successKey != "" {
+			replayToShadowChannel(c, envCfg, cfgManager, channelScheduler, channelIndex, bodyBytes, model, isStream)
+		}
 		return
 	}
 
@@ -273,6 +323,46 @@ func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, a
 	handleAllKeysFailed(c, lastFailoverError, lastError)
 }
 
+// replayToShadowChannel 主渠道成功后将非流式请求异步镜像到影子渠道（流式请求不镜像）
+func replayToShadowChannel(
+	c *gin.Context,
+	envCfg *config.EnvConfig,
+	cfgManager *config.ConfigManager,
+	channelScheduler *scheduler.ChannelScheduler,
+	channelIndex int,
+	bodyBytes []byte,
+	model string,
+	isStream bool,
+) {
+	if isStream {
+		return
+	}
+
+	common.ReplayToShadowChannel(
+		c,
+		envCfg,
+		cfgManager,
+		channelScheduler,
+		scheduler.ChannelKindGemini,
+		"Gemini",
+		channelScheduler.GetGeminiMetricsManager(),
+		channelIndex,
+		bodyBytes,
+		model,
+		func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error) {
+			return cfgManager.GetNextGeminiAPIKey(upstream, failedKeys)
+		},
+		func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
+			// 重新解析请求体，避免与主请求共享（可能被就地修改的）GeminiRequest
+			var geminiReq types.GeminiRequest
+			if err := json.Unmarshal(bodyBytes, &geminiReq); err != nil {
+				return nil, err
+			}
+			return buildProviderRequest(c, upstreamCopy, upstreamCopy.BaseURL, apiKey, &geminiReq, model, false)
+		},
+	)
+}
+
 // ensureThoughtSignatures 确保所有 functionCall 都有 thought_signature 字段
 // 用于兼容 x666.me 等要求必须有该字段的第三方 API
 // 参考: https://ai.google.dev/gemini-api/docs/thought-signatures
@@ -437,6 +527,12 @@ func buildProviderRequest(
 		}
 	}
 
+	// 渠道级请求体改写
+	requestBody, err = config.ApplyBodyTransforms(requestBody, upstream.BodyTransforms)
+	if err != nil {
+		return nil, err
+	}
+
 	req, err := http.NewRequestWithContext(c.Request.Context(), "POST", url, bytes.NewReader(requestBody))
 	if err != nil {
 		return nil, err
@@ -445,6 +541,7 @@ func buildProviderRequest(
 	// 使用统一的头部处理逻辑（透明代理）
 	// 保留客户端的大部分 headers，只移除/替换必要的认证和代理相关 headers
 	req.Header = utils.PrepareUpstreamHeaders(c, req.URL.Host)
+	utils.FilterForwardedHeaders(req.Header, upstream.ForwardHeaderAllowlist, upstream.StripHeaders)
 
 	// 设置 Content-Type（覆盖可能来自客户端的值）
 	req.Header.Set("Content-Type", "application/json")
@@ -465,7 +562,7 @@ func buildProviderRequest(
 	}
 
 	// 应用自定义请求头
-	utils.ApplyCustomHeaders(req.Header, upstream.CustomHeaders)
+	utils.ApplyCustomHeaders(req.Header, upstream.CustomHeaders, utils.HeaderTemplateVars{Model: mappedModel, APIKey: apiKey})
 
 	return req, nil
 }
@@ -475,6 +572,7 @@ func handleSuccess(
 	c *gin.Context,
 	resp *http.Response,
 	upstreamType string,
+	requestBody []byte,
 	envCfg *config.EnvConfig,
 	startTime time.Time,
 	geminiReq *types.GeminiRequest,
@@ -484,7 +582,7 @@ func handleSuccess(
 	defer resp.Body.Close()
 
 	if isStream {
-		return handleStreamSuccess(c, resp, upstreamType, envCfg, startTime, model), nil
+		return handleStreamSuccess(c, resp, upstreamType, requestBody, envCfg, startTime, model)
 	}
 
 	// 非流式响应处理
@@ -600,7 +698,10 @@ func handleSuccess(
 
 // handleAllChannelsFailed 处理所有渠道失败的情况
 func handleAllChannelsFailed(c *gin.Context, failoverErr *common.FailoverError, lastError error) {
+	common.RecordDeadLetter(c, "Gemini", failoverErr, lastError)
+
 	if failoverErr != nil {
+		common.SetFailoverSummaryHeader(c, failoverErr)
 		c.Data(failoverErr.Status, "application/json", failoverErr.Body)
 		return
 	}
@@ -621,6 +722,8 @@ func handleAllChannelsFailed(c *gin.Context, failoverErr *common.FailoverError,
 
 // handleAllKeysFailed 处理所有 Key 失败的情况
 func handleAllKeysFailed(c *gin.Context, failoverErr *common.FailoverError, lastError error) {
+	common.RecordDeadLetter(c, "Gemini", failoverErr, lastError)
+
 	if failoverErr != nil {
 		c.Data(failoverErr.Status, "application/json", failoverErr.Body)
 		return
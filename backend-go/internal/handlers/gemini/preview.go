@@ -0,0 +1,36 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
+	"github.com/BenedictKing/ccx/internal/types"
+	"github.com/gin-gonic/gin"
+)
+
+// BuildPreviewRequest 按真实请求路径的转换逻辑构建上游请求（不发送），用于请求预览
+// model 对应 URL 路径中的模型名（/v1beta/models/{model}:generateContent）
+func BuildPreviewRequest(c *gin.Context, upstream *config.UpstreamConfig, apiKey string, bodyBytes []byte, model string, isStream bool) (*http.Request, error) {
+	if model == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	var geminiReq types.GeminiRequest
+	if len(bodyBytes) > 0 {
+		if err := json.Unmarshal(bodyBytes, &geminiReq); err != nil {
+			return nil, fmt.Errorf("invalid request body: %w", err)
+		}
+	}
+
+	action := "generateContent"
+	if isStream {
+		action = "streamGenerateContent"
+	}
+	previewCtx, err := common.NewPreviewContext(c, "/v1beta/models/"+model+":"+action, bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return buildProviderRequest(previewCtx, upstream, upstream.BaseURL, apiKey, &geminiReq, model, isStream)
+}
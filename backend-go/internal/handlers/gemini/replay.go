@@ -0,0 +1,38 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/types"
+	"github.com/gin-gonic/gin"
+)
+
+// ReplayToChannel 将死信中保存的请求重放到指定渠道，仅在该渠道内的 Key/BaseURL 之间 failover
+// Gemini 的模型与流式标记来自 URL 路径，因此由调用方按死信记录传入
+func ReplayToChannel(c *gin.Context, envCfg *config.EnvConfig, cfgManager *config.ConfigManager, channelScheduler *scheduler.ChannelScheduler, upstream *config.UpstreamConfig, channelIndex int, bodyBytes []byte, model string, isStream bool) (succeeded bool, err error) {
+	if model == "" {
+		return false, fmt.Errorf("model is required")
+	}
+	var geminiReq types.GeminiRequest
+	if err := json.Unmarshal(bodyBytes, &geminiReq); err != nil {
+		return false, fmt.Errorf("invalid request body: %w", err)
+	}
+	action := "generateContent"
+	if isStream {
+		action = "streamGenerateContent"
+	}
+	if err := common.PrepareReplayRequest(c, "/v1beta/models/"+model+":"+action, bodyBytes); err != nil {
+		return false, err
+	}
+
+	result := tryChannel(c, envCfg, cfgManager, channelScheduler, upstream, channelIndex, bodyBytes, &geminiReq, model, isStream, time.Now())
+	if !result.Handled {
+		handleAllChannelsFailed(c, result.FailoverError, result.LastError)
+	}
+	return result.SuccessKey != "", nil
+}
@@ -3,7 +3,9 @@
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strings"
@@ -11,19 +13,22 @@
 
 	"github.com/BenedictKing/ccx/internal/config"
 	"github.com/BenedictKing/ccx/internal/converters"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
 	"github.com/BenedictKing/ccx/internal/types"
 	"github.com/gin-gonic/gin"
 )
 
 // handleStreamSuccess 处理流式响应
+// requestBody: 上游未返回 usage 时用于估算输入 token
 func handleStreamSuccess(
 	c *gin.Context,
 	resp *http.Response,
 	upstreamType string,
+	requestBody []byte,
 	envCfg *config.EnvConfig,
 	startTime time.Time,
 	model string,
-) *types.Usage {
+) (*types.Usage, error) {
 	// 设置 SSE 响应头
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
@@ -35,20 +40,46 @@ func handleStreamSuccess(
 		log.Printf("[Gemini-Stream] 警告: ResponseWriter 不支持 Flusher")
 	}
 
+	// 慢客户端背压保护（STREAM_BACKPRESSURE_TIMEOUT > 0 时启用）
+	body, stopBackpressure := common.WrapStreamBackpressure(c.Writer, resp.Body, envCfg)
+	defer stopBackpressure()
+
 	var totalUsage *types.Usage
+	var streamErr error
+	var outputText strings.Builder
 
 	switch upstreamType {
 	case "gemini":
-		totalUsage = streamGeminiToGemini(c, resp, flusher, envCfg)
+		totalUsage, streamErr = streamGeminiToGemini(c, body, flusher, envCfg, &outputText)
 	case "claude":
-		totalUsage = streamClaudeToGemini(c, resp, flusher, envCfg, model)
+		totalUsage, streamErr = streamClaudeToGemini(c, body, flusher, envCfg, model, &outputText)
 	case "openai":
-		totalUsage = streamOpenAIToGemini(c, resp, flusher, envCfg, model)
+		totalUsage, streamErr = streamOpenAIToGemini(c, body, flusher, envCfg, model, &outputText)
 	case "responses":
-		totalUsage = streamResponsesToGemini(c, resp, flusher, envCfg, model)
+		totalUsage, streamErr = streamResponsesToGemini(c, body, flusher, envCfg, model, &outputText)
 	default:
 		// 默认透传
-		totalUsage = streamGeminiToGemini(c, resp, flusher, envCfg)
+		totalUsage, streamErr = streamGeminiToGemini(c, body, flusher, envCfg, &outputText)
+	}
+
+	// 慢客户端中止或客户端取消：按客户端侧错误返回，由调用方记录为客户端取消而非成功
+	switch {
+	case errors.Is(streamErr, common.ErrSlowClient):
+		log.Printf("[Gemini-Stream] 警告: 客户端消费过慢，读取缓冲区持续满载，已中止请求以释放上游连接")
+		return nil, streamErr
+	case c.Request.Context().Err() != nil:
+		if envCfg.ShouldLog("info") {
+			log.Printf("[Gemini-Stream] 客户端已取消请求，停止读取上游流")
+		}
+		return nil, c.Request.Context().Err()
+	}
+
+	// 上游未返回 usage 时按请求体与已输出文本估算，避免 token 漏记
+	if totalUsage == nil {
+		totalUsage = common.EstimateStreamUsage(requestBody, outputText.String())
+		if envCfg.EnableResponseLogs {
+			log.Printf("[Gemini-Stream-Usage] 上游未返回 usage，使用估算值: input=%d, output=%d", totalUsage.InputTokens, totalUsage.OutputTokens)
+		}
 	}
 
 	if envCfg.EnableResponseLogs {
@@ -56,22 +87,27 @@ func handleStreamSuccess(
 		log.Printf("[Gemini-Stream-Timing] 流式响应完成: %dms", responseTime)
 	}
 
-	return totalUsage
+	return totalUsage, nil
 }
 
 // streamGeminiToGemini Gemini 上游直接透传
 func streamGeminiToGemini(
 	c *gin.Context,
-	resp *http.Response,
+	body io.Reader,
 	flusher http.Flusher,
 	envCfg *config.EnvConfig,
-) *types.Usage {
-	scanner := bufio.NewScanner(resp.Body)
+	outputText *strings.Builder,
+) (*types.Usage, error) {
+	scanner := bufio.NewScanner(body)
 	scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // 1MB buffer
 
 	var totalUsage *types.Usage
 
 	for scanner.Scan() {
+		// 客户端已断开：停止读取上游（上游请求与客户端 context 绑定，读取也会随之中断）
+		if err := c.Request.Context().Err(); err != nil {
+			return nil, err
+		}
 		line := scanner.Text()
 
 		// 直接转发 SSE 数据
@@ -81,6 +117,7 @@ func streamGeminiToGemini(
 			// 尝试解析 usage
 			var chunk types.GeminiStreamChunk
 			if err := json.Unmarshal([]byte(jsonData), &chunk); err == nil {
+				appendGeminiChunkText(outputText, &chunk)
 				if chunk.UsageMetadata != nil {
 					totalUsage = &types.Usage{
 						InputTokens:  chunk.UsageMetadata.PromptTokenCount - chunk.UsageMetadata.CachedContentTokenCount,
@@ -101,24 +138,28 @@ func streamGeminiToGemini(
 		}
 	}
 
-	return totalUsage
+	return totalUsage, scanner.Err()
 }
 
 // streamClaudeToGemini Claude 流式响应转换为 Gemini 格式
 func streamClaudeToGemini(
 	c *gin.Context,
-	resp *http.Response,
+	body io.Reader,
 	flusher http.Flusher,
 	envCfg *config.EnvConfig,
 	model string,
-) *types.Usage {
-	scanner := bufio.NewScanner(resp.Body)
+	outputText *strings.Builder,
+) (*types.Usage, error) {
+	scanner := bufio.NewScanner(body)
 	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
 
 	var totalUsage *types.Usage
-	var currentText strings.Builder
 
 	for scanner.Scan() {
+		// 客户端已断开：停止读取上游（上游请求与客户端 context 绑定，读取也会随之中断）
+		if err := c.Request.Context().Err(); err != nil {
+			return nil, err
+		}
 		line := scanner.Text()
 
 		if !strings.HasPrefix(line, "data: ") {
@@ -147,7 +188,7 @@ func streamClaudeToGemini(
 			deltaType, _ := delta["type"].(string)
 			if deltaType == "text_delta" {
 				text, _ := delta["text"].(string)
-				currentText.WriteString(text)
+				outputText.WriteString(text)
 
 				// 转换为 Gemini 格式
 				geminiChunk := types.GeminiStreamChunk{
@@ -208,24 +249,28 @@ func streamClaudeToGemini(
 		}
 	}
 
-	return totalUsage
+	return totalUsage, scanner.Err()
 }
 
 // streamOpenAIToGemini OpenAI 流式响应转换为 Gemini 格式
 func streamOpenAIToGemini(
 	c *gin.Context,
-	resp *http.Response,
+	body io.Reader,
 	flusher http.Flusher,
 	envCfg *config.EnvConfig,
 	model string,
-) *types.Usage {
-	scanner := bufio.NewScanner(resp.Body)
+	outputText *strings.Builder,
+) (*types.Usage, error) {
+	scanner := bufio.NewScanner(body)
 	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
 
 	var totalUsage *types.Usage
-	var currentText strings.Builder
 
 	for scanner.Scan() {
+		// 客户端已断开：停止读取上游（上游请求与客户端 context 绑定，读取也会随之中断）
+		if err := c.Request.Context().Err(); err != nil {
+			return nil, err
+		}
 		line := scanner.Text()
 
 		if !strings.HasPrefix(line, "data: ") {
@@ -309,7 +354,7 @@ func streamOpenAIToGemini(
 		// 提取文本内容
 		content, _ := delta["content"].(string)
 		if content != "" {
-			currentText.WriteString(content)
+			outputText.WriteString(content)
 
 			geminiChunk := types.GeminiStreamChunk{
 				Candidates: []types.GeminiCandidate{
@@ -349,7 +394,19 @@ func streamOpenAIToGemini(
 		}
 	}
 
-	return totalUsage
+	return totalUsage, scanner.Err()
+}
+
+// appendGeminiChunkText 累积 Gemini 流式块中的文本（用于估算输出 token）
+func appendGeminiChunkText(outputText *strings.Builder, chunk *types.GeminiStreamChunk) {
+	for _, candidate := range chunk.Candidates {
+		if candidate.Content == nil {
+			continue
+		}
+		for _, part := range candidate.Content.Parts {
+			outputText.WriteString(part.Text)
+		}
+	}
 }
 
 // openaiFinishReasonToGemini 将 OpenAI 停止原因转换为 Gemini 格式
@@ -371,18 +428,23 @@ func openaiFinishReasonToGemini(finishReason string) string {
 // streamResponsesToGemini Responses 流式响应转换为 Gemini 格式
 func streamResponsesToGemini(
 	c *gin.Context,
-	resp *http.Response,
+	body io.Reader,
 	flusher http.Flusher,
 	envCfg *config.EnvConfig,
 	model string,
-) *types.Usage {
-	scanner := bufio.NewScanner(resp.Body)
+	outputText *strings.Builder,
+) (*types.Usage, error) {
+	scanner := bufio.NewScanner(body)
 	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
 
 	var totalUsage *types.Usage
 	var converterState any
 
 	for scanner.Scan() {
+		// 客户端已断开：停止读取上游（上游请求与客户端 context 绑定，读取也会随之中断）
+		if err := c.Request.Context().Err(); err != nil {
+			return nil, err
+		}
 		line := scanner.Text()
 		if line == "" {
 			continue
@@ -403,6 +465,7 @@ func streamResponsesToGemini(
 				jsonData = strings.TrimSuffix(jsonData, "\n\n")
 				var chunk types.GeminiStreamChunk
 				if err := json.Unmarshal([]byte(jsonData), &chunk); err == nil {
+					appendGeminiChunkText(outputText, &chunk)
 					if chunk.UsageMetadata != nil {
 						totalUsage = &types.Usage{
 							InputTokens:  chunk.UsageMetadata.PromptTokenCount - chunk.UsageMetadata.CachedContentTokenCount,
@@ -423,5 +486,5 @@ func streamResponsesToGemini(
 		log.Printf("[Gemini-Stream] Responses流式转换读取错误: %v", err)
 	}
 
-	return totalUsage
+	return totalUsage, scanner.Err()
 }
@@ -0,0 +1,119 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandleStreamSuccess_EstimatesMissingUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	requestBody := []byte(`{"contents":[{"role":"user","parts":[{"text":"please say hello to the world"}]}]}`)
+
+	tests := []struct {
+		name          string
+		upstreamType  string
+		upstreamBody  string
+		wantEstimated bool
+	}{
+		{
+			name:         "gemini 返回 usageMetadata 时不估算",
+			upstreamType: "gemini",
+			upstreamBody: "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"hello world\"}]}}]," +
+				"\"usageMetadata\":{\"promptTokenCount\":3,\"candidatesTokenCount\":2,\"totalTokenCount\":5}}\n\n",
+			wantEstimated: false,
+		},
+		{
+			name:          "gemini 缺失 usageMetadata 时估算",
+			upstreamType:  "gemini",
+			upstreamBody:  "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"hello world, this is a streamed answer\"}]}}]}\n\n",
+			wantEstimated: true,
+		},
+		{
+			name:         "openai 缺失 usage 时估算",
+			upstreamType: "openai",
+			upstreamBody: "data: {\"choices\":[{\"delta\":{\"content\":\"hello world, this is a streamed answer\"}}]}\n\n" +
+				"data: [DONE]\n\n",
+			wantEstimated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-test:streamGenerateContent", nil)
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(tt.upstreamBody)),
+			}
+
+			usage, _ := handleStreamSuccess(c, resp, tt.upstreamType, requestBody, &config.EnvConfig{}, time.Now(), "gemini-test")
+			if usage == nil {
+				t.Fatalf("usage = nil, want non-nil")
+			}
+			if usage.Estimated != tt.wantEstimated {
+				t.Fatalf("Estimated = %v, want %v", usage.Estimated, tt.wantEstimated)
+			}
+			if !tt.wantEstimated {
+				if usage.InputTokens != 3 || usage.OutputTokens != 2 {
+					t.Fatalf("usage = %+v, want upstream values 3/2", usage)
+				}
+				return
+			}
+			if usage.InputTokens <= 0 || usage.OutputTokens <= 0 {
+				t.Fatalf("usage = %+v, want positive estimated tokens", usage)
+			}
+		})
+	}
+}
+
+// TestHandleStreamSuccess_ClientCanceled 客户端取消后停止读取上游，返回 context.Canceled 而非估算用量
+func TestHandleStreamSuccess_ClientCanceled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		upstreamType string
+		upstreamBody string
+	}{
+		{upstreamType: "gemini", upstreamBody: "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"hello\"}]}}]}\n\n"},
+		{upstreamType: "claude", upstreamBody: "data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hello\"}}\n\n"},
+		{upstreamType: "openai", upstreamBody: "data: {\"choices\":[{\"delta\":{\"content\":\"hello\"}}]}\n\n"},
+		{upstreamType: "responses", upstreamBody: "data: {\"type\":\"response.output_text.delta\",\"delta\":\"hello\"}\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.upstreamType, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-test:streamGenerateContent", nil).WithContext(ctx)
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(tt.upstreamBody)),
+			}
+
+			usage, err := handleStreamSuccess(c, resp, tt.upstreamType, nil, &config.EnvConfig{}, time.Now(), "gemini-test")
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("err = %v, want context.Canceled", err)
+			}
+			if usage != nil {
+				t.Fatalf("usage = %+v, want nil", usage)
+			}
+			if strings.Contains(w.Body.String(), "hello") {
+				t.Fatalf("取消后仍转发了上游内容: %q", w.Body.String())
+			}
+		})
+	}
+}
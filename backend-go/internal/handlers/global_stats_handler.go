@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"strings"
 	"time"
 
 	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
 	"github.com/gin-gonic/gin"
 )
 
@@ -38,32 +40,9 @@ func GetGlobalStatsHistory(metricsManager *metrics.MetricsManager) gin.HandlerFu
 		}
 
 		// 解析或自动选择 interval
-		intervalStr := c.Query("interval")
-		var interval time.Duration
-		if intervalStr != "" {
-			interval, err = time.ParseDuration(intervalStr)
-			if err != nil {
-				c.JSON(400, gin.H{"error": "Invalid interval parameter"})
-				return
-			}
-			// 限制 interval 最小值为 1 分钟，防止生成过多 bucket
-			if interval < time.Minute {
-				interval = time.Minute
-			}
-		} else {
-			// 根据 duration 自动选择合适的聚合粒度
-			// 目标：每个时间段约 60-100 个数据点，保持图表清晰
-			// 1h = 60 points (1m interval)
-			// 6h = 72 points (5m interval)
-			// 24h = 96 points (15m interval)
-			switch {
-			case duration <= time.Hour:
-				interval = time.Minute
-			case duration <= 6*time.Hour:
-				interval = 5 * time.Minute
-			default:
-				interval = 15 * time.Minute
-			}
+		interval, ok := resolveHistoryInterval(c, duration)
+		if !ok {
+			return
 		}
 
 		// 获取全局统计数据
@@ -77,3 +56,50 @@ func GetGlobalStatsHistory(metricsManager *metrics.MetricsManager) gin.HandlerFu
 		c.JSON(200, result)
 	}
 }
+
+// GetGlobalStats 获取跨渠道的全局用量汇总与模型分布
+// GET /api/stats/global?kind=all|messages|responses|gemini|chat&range=today|24h|7d
+// 7d 依赖指标持久化的保留天数，超出可用保留范围时截断并返回 clamped=true
+func GetGlobalStats(sch *scheduler.ChannelScheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kind := strings.ToLower(c.DefaultQuery("kind", "all"))
+		kinds, ok := parseStatsKinds(kind)
+		if !ok {
+			c.JSON(400, gin.H{"error": "Invalid kind. Use: all, messages, responses, gemini, or chat"})
+			return
+		}
+
+		rangeStr := strings.ToLower(c.DefaultQuery("range", "24h"))
+		var duration time.Duration
+		switch rangeStr {
+		case "today":
+			duration = metrics.CalculateTodayDuration()
+			if duration < time.Minute {
+				duration = time.Minute
+			}
+		case "24h":
+			duration = 24 * time.Hour
+		case "7d":
+			duration = 7 * 24 * time.Hour
+		default:
+			c.JSON(400, gin.H{"error": "Invalid range. Use: today, 24h, or 7d"})
+			return
+		}
+
+		clamped := false
+		if retention := sch.GetStatsRetention(kinds); duration > retention {
+			duration = retention
+			clamped = true
+		}
+
+		summary, models := sch.GetGlobalUsageSummary(kinds, duration)
+		c.JSON(200, gin.H{
+			"kind":     kind,
+			"range":    rangeStr,
+			"duration": duration.String(),
+			"clamped":  clamped,
+			"summary":  summary,
+			"models":   models,
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/session"
+	"github.com/BenedictKing/ccx/internal/types"
+	"github.com/BenedictKing/ccx/internal/warmup"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetGlobalStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	data, err := json.MarshalIndent(config.Config{}, "", "  ")
+	if err != nil {
+		t.Fatalf("序列化配置失败: %v", err)
+	}
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	cfgManager, err := config.NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("创建配置管理器失败: %v", err)
+	}
+	t.Cleanup(func() { cfgManager.Close() })
+
+	messagesMetrics := metrics.NewMetricsManager()
+	responsesMetrics := metrics.NewMetricsManager()
+	geminiMetrics := metrics.NewMetricsManager()
+	chatMetrics := metrics.NewMetricsManager()
+	t.Cleanup(func() {
+		messagesMetrics.Stop()
+		responsesMetrics.Stop()
+		geminiMetrics.Stop()
+		chatMetrics.Stop()
+	})
+	sch := scheduler.NewChannelScheduler(cfgManager, messagesMetrics, responsesMetrics, geminiMetrics, chatMetrics, session.NewTraceAffinityManager(), warmup.NewURLManager(30*time.Second, 3))
+
+	record := func(m *metrics.MetricsManager, model string, at time.Time, usage *types.Usage) {
+		id := m.RecordRequestConnectedAt("https://a.example.com", "sk-test", model, at)
+		if usage == nil {
+			m.RecordRequestFinalizeFailure("https://a.example.com", "sk-test", id)
+			return
+		}
+		m.RecordRequestFinalizeSuccess("https://a.example.com", "sk-test", id, usage)
+	}
+
+	// 今天的请求：Messages 一次成功 + Chat 一次失败
+	now := time.Now()
+	record(messagesMetrics, "claude-sonnet", now.Add(-10*time.Second), &types.Usage{InputTokens: 100, OutputTokens: 10})
+	record(chatMetrics, "gpt-4o", now.Add(-5*time.Second), nil)
+
+	// 昨天的请求（今日 0 点前 2 分钟），仅计入 24h 窗口
+	todayDuration := metrics.CalculateTodayDuration()
+	hasYesterday := todayDuration >= 2*time.Minute && todayDuration < 23*time.Hour
+	if hasYesterday {
+		record(messagesMetrics, "claude-sonnet", now.Add(-todayDuration-2*time.Minute), &types.Usage{InputTokens: 1000, OutputTokens: 100})
+	}
+
+	r := gin.New()
+	r.GET("/stats/global", GetGlobalStats(sch))
+
+	type response struct {
+		Kind     string                      `json:"kind"`
+		Range    string                      `json:"range"`
+		Duration string                      `json:"duration"`
+		Clamped  bool                        `json:"clamped"`
+		Summary  metrics.GlobalStatsSummary  `json:"summary"`
+		Models   []metrics.ModelUsageSummary `json:"models"`
+	}
+	get := func(t *testing.T, query string) (int, response) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stats/global"+query, nil))
+		var resp response
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("解析响应失败: %v", err)
+			}
+		}
+		return w.Code, resp
+	}
+
+	t.Run("today 仅统计今日 0 点之后", func(t *testing.T) {
+		if !hasYesterday {
+			t.Skip("当前时间过于接近 0 点，无法构造跨日记录")
+		}
+		code, resp := get(t, "?range=today")
+		if code != http.StatusOK {
+			t.Fatalf("status=%d", code)
+		}
+		if resp.Clamped {
+			t.Fatalf("today 不应被截断")
+		}
+		if resp.Summary.TotalRequests != 2 || resp.Summary.TotalSuccess != 1 || resp.Summary.TotalInputTokens != 100 {
+			t.Fatalf("summary=%+v, want 2 requests / 1 success / 100 input", resp.Summary)
+		}
+	})
+
+	t.Run("24h 包含昨天的记录", func(t *testing.T) {
+		code, resp := get(t, "")
+		if code != http.StatusOK {
+			t.Fatalf("status=%d", code)
+		}
+		wantRequests, wantInput := int64(2), int64(100)
+		if hasYesterday {
+			wantRequests, wantInput = 3, 1100
+		}
+		if resp.Range != "24h" || resp.Kind != "all" {
+			t.Fatalf("range=%q kind=%q, want 24h/all", resp.Range, resp.Kind)
+		}
+		if resp.Summary.TotalRequests != wantRequests || resp.Summary.TotalInputTokens != wantInput {
+			t.Fatalf("summary=%+v, want %d requests / %d input", resp.Summary, wantRequests, wantInput)
+		}
+		if len(resp.Models) != 2 || resp.Models[0].Model != "claude-sonnet" {
+			t.Fatalf("models=%+v, want claude-sonnet first", resp.Models)
+		}
+	})
+
+	t.Run("7d 无持久化时截断到 24h", func(t *testing.T) {
+		code, resp := get(t, "?range=7d&kind=chat")
+		if code != http.StatusOK {
+			t.Fatalf("status=%d", code)
+		}
+		if !resp.Clamped || resp.Duration != (24*time.Hour).String() {
+			t.Fatalf("clamped=%v duration=%q, want true/24h0m0s", resp.Clamped, resp.Duration)
+		}
+		if resp.Summary.TotalRequests != 1 || resp.Summary.TotalFailure != 1 || len(resp.Models) != 1 || resp.Models[0].Model != "gpt-4o" {
+			t.Fatalf("chat 汇总异常: summary=%+v models=%+v", resp.Summary, resp.Models)
+		}
+	})
+
+	t.Run("无效参数", func(t *testing.T) {
+		for _, query := range []string{"?range=30d", "?kind=unknown"} {
+			if code, _ := get(t, query); code != http.StatusBadRequest {
+				t.Fatalf("%s status=%d, want 400", query, code)
+			}
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// RotateChannelKey 原地轮换渠道内的 API Key，并将旧 Key 的指标迁移到新 Key
+// POST /api/{kind}/channels/:id/keys/rotate
+// 与"删除旧 Key + 添加新 Key"不同，新 Key 保留旧 Key 的位置与累计统计，仪表盘不会出现统计归零
+func RotateChannelKey(cfgManager *config.ConfigManager, metricsManager *metrics.MetricsManager, channelKind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+			return
+		}
+
+		var req struct {
+			OldKey string `json:"oldKey"`
+			NewKey string `json:"newKey"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		req.OldKey = strings.TrimSpace(req.OldKey)
+		req.NewKey = strings.TrimSpace(req.NewKey)
+
+		upstream, err := cfgManager.RotateAPIKey(channelKind, id, req.OldKey, req.NewKey)
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), "无效的上游索引"):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Upstream not found"})
+			case strings.Contains(err.Error(), "API密钥不存在"):
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			case strings.Contains(err.Error(), "API密钥"), strings.Contains(err.Error(), "无效的接口类型"):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config"})
+			}
+			return
+		}
+
+		carried := 0
+		if metricsManager != nil {
+			carried = metricsManager.CarryOverKey(upstream.GetAllBaseURLs(), req.OldKey, req.NewKey)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "API密钥已轮换",
+			"success":        true,
+			"metricsCarried": carried > 0,
+		})
+	}
+}
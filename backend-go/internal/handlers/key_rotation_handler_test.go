@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// TestRotateChannelKey 轮换 Key 后新 Key 占据原位置，旧 Key 的请求统计延续到新 Key
+func TestRotateChannelKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const baseURL = "https://api.example.com"
+	cfg := config.Config{
+		Upstream: []config.UpstreamConfig{
+			{Name: "primary", BaseURL: baseURL, APIKeys: []string{"sk-a", "sk-old", "sk-c"}, ServiceType: "claude", Status: "active"},
+		},
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		t.Fatalf("序列化配置失败: %v", err)
+	}
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	cfgManager, err := config.NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("创建配置管理器失败: %v", err)
+	}
+	t.Cleanup(func() { cfgManager.Close() })
+
+	metricsManager := metrics.NewMetricsManager()
+	t.Cleanup(metricsManager.Stop)
+	for range 3 {
+		metricsManager.RecordSuccess(baseURL, "sk-old")
+	}
+	metricsManager.RecordFailure(baseURL, "sk-old")
+
+	r := gin.New()
+	r.POST("/messages/channels/:id/keys/rotate", RotateChannelKey(cfgManager, metricsManager, "messages"))
+
+	rotate := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/messages/channels/0/keys/rotate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := rotate(`{"oldKey":"sk-old","newKey":"sk-new"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", w.Code, w.Body.String())
+	}
+
+	upstream := cfgManager.GetConfig().Upstream[0]
+	if got := strings.Join(upstream.APIKeys, ","); got != "sk-a,sk-new,sk-c" {
+		t.Fatalf("APIKeys=%s, want sk-a,sk-new,sk-c", got)
+	}
+	if len(upstream.HistoricalAPIKeys) != 0 {
+		t.Fatalf("轮换的旧 Key 不应进入历史列表: %v", upstream.HistoricalAPIKeys)
+	}
+
+	newMetrics := metricsManager.GetKeyMetrics(baseURL, "sk-new")
+	if newMetrics == nil || newMetrics.RequestCount != 4 || newMetrics.SuccessCount != 3 || newMetrics.FailureCount != 1 {
+		t.Fatalf("新 Key 应延续旧 Key 的请求统计, got %+v", newMetrics)
+	}
+	if stats := metricsManager.GetTimeWindowStatsForKey(baseURL, "sk-new", time.Hour); stats.RequestCount != 4 {
+		t.Fatalf("新 Key 请求历史应包含旧 Key 的 4 条记录, got %d", stats.RequestCount)
+	}
+	if newMetrics.ConsecutiveFailures != 0 {
+		t.Fatalf("连续失败数不应迁移, got %d", newMetrics.ConsecutiveFailures)
+	}
+	if metricsManager.GetKeyMetrics(baseURL, "sk-old") != nil {
+		t.Fatal("旧 Key 的指标应已迁移并删除")
+	}
+
+	// 轮换后继续计数
+	metricsManager.RecordSuccess(baseURL, "sk-new")
+	if got := metricsManager.GetKeyMetrics(baseURL, "sk-new").RequestCount; got != 5 {
+		t.Fatalf("RequestCount=%d, want 5", got)
+	}
+
+	// 错误输入
+	if w := rotate(`{"oldKey":"sk-missing","newKey":"sk-x"}`); w.Code != http.StatusNotFound {
+		t.Fatalf("旧 Key 不存在时 status=%d, want 404", w.Code)
+	}
+	if w := rotate(`{"oldKey":"sk-a","newKey":"sk-c"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("新 Key 已存在时 status=%d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/httpclient"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	upstreamKeyTestTimeout      = 10 * time.Second // 单 Key 测试超时（快速失败，避免阻塞管理界面）
+	upstreamKeyTestMaxErrorBody = 4096             // 返回的上游错误响应体最大字节数
+)
+
+// keyTestProtocols 渠道 serviceType 到测试请求协议的映射
+var keyTestProtocols = map[string]string{
+	"claude":    "messages",
+	"openai":    "chat",
+	"gemini":    "gemini",
+	"responses": "responses",
+}
+
+// UpstreamKeyTestRequest 单 Key 测试请求
+type UpstreamKeyTestRequest struct {
+	BaseURL            string `json:"baseUrl"`
+	APIKey             string `json:"apiKey"`
+	ServiceType        string `json:"serviceType"` // claude, openai, gemini, responses
+	Model              string `json:"model"`       // 为空时使用能力测试的首选探测模型
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	ProxyURL           string `json:"proxyUrl"`
+}
+
+// TestUpstreamKey 在添加渠道前测试单个 BaseURL + Key 是否可用
+// POST /api/upstream/test-key
+// 结果只记录到临时指标管理器，不影响真实渠道的指标与熔断状态
+func TestUpstreamKey(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpstreamKeyTestRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+		req.BaseURL = strings.TrimSpace(req.BaseURL)
+		req.APIKey = strings.TrimSpace(req.APIKey)
+		if req.BaseURL == "" || req.APIKey == "" {
+			c.JSON(400, gin.H{"error": "baseUrl and apiKey are required"})
+			return
+		}
+		protocol, ok := keyTestProtocols[req.ServiceType]
+		if !ok {
+			c.JSON(400, gin.H{"error": "Invalid serviceType. Use: claude, openai, gemini, or responses"})
+			return
+		}
+		model := strings.TrimSpace(req.Model)
+		if model == "" {
+			var err error
+			if model, err = getCapabilityProbeModel(protocol); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		upstream := &config.UpstreamConfig{
+			BaseURL:            req.BaseURL,
+			APIKeys:            []string{req.APIKey},
+			ServiceType:        req.ServiceType,
+			InsecureSkipVerify: req.InsecureSkipVerify,
+			ProxyURL:           req.ProxyURL,
+		}
+		httpReq, err := buildTestRequestWithModel(protocol, upstream, model)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), upstreamKeyTestTimeout)
+		defer cancel()
+		httpReq = httpReq.WithContext(ctx)
+
+		// 临时指标管理器：复用正常请求的记录流程，但不写入真实指标
+		probeMetrics := metrics.NewMetricsManager()
+		defer probeMetrics.Stop()
+
+		client := httpclient.GetManager().GetStandardClient(upstreamKeyTestTimeout, req.InsecureSkipVerify, req.ProxyURL)
+		startTime := time.Now()
+		requestID := probeMetrics.RecordRequestConnectedAt(req.BaseURL, req.APIKey, model, startTime)
+
+		result := gin.H{
+			"model":        model,
+			"configuredIn": findChannelsUsingKey(cfgManager, req.APIKey),
+		}
+
+		resp, err := client.Do(httpReq)
+		latency := time.Since(startTime).Milliseconds()
+		result["latency"] = latency
+		if err != nil {
+			probeMetrics.RecordRequestFinalizeFailure(req.BaseURL, req.APIKey, requestID)
+			result["success"] = false
+			result["statusCode"] = 0
+			result["error"] = classifyError(err, 0, ctx)
+			log.Printf("[KeyTest] %s (%s) 测试失败: %v", req.BaseURL, utils.MaskAPIKey(req.APIKey), err)
+			c.JSON(200, result)
+			return
+		}
+		defer resp.Body.Close()
+
+		result["statusCode"] = resp.StatusCode
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			probeMetrics.RecordRequestFinalizeSuccess(req.BaseURL, req.APIKey, requestID, nil)
+			result["success"] = true
+			log.Printf("[KeyTest] %s (%s) 测试成功 (模型: %s, 耗时: %dms)", req.BaseURL, utils.MaskAPIKey(req.APIKey), model, latency)
+			c.JSON(200, result)
+			return
+		}
+
+		probeMetrics.RecordRequestFinalizeFailure(req.BaseURL, req.APIKey, requestID)
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, upstreamKeyTestMaxErrorBody))
+		result["success"] = false
+		result["error"] = classifyError(nil, resp.StatusCode, ctx)
+		result["errorBody"] = string(errorBody)
+		log.Printf("[KeyTest] %s (%s) 测试失败: HTTP %d", req.BaseURL, utils.MaskAPIKey(req.APIKey), resp.StatusCode)
+		c.JSON(200, result)
+	}
+}
+
+// findChannelsUsingKey 查找已配置该 Key 的渠道（提示重复添加）
+func findChannelsUsingKey(cfgManager *config.ConfigManager, apiKey string) []gin.H {
+	cfg := cfgManager.GetConfig()
+	result := []gin.H{}
+	for _, group := range []struct {
+		kind      string
+		upstreams []config.UpstreamConfig
+	}{
+		{"messages", cfg.Upstream},
+		{"responses", cfg.ResponsesUpstream},
+		{"gemini", cfg.GeminiUpstream},
+		{"chat", cfg.ChatUpstream},
+	} {
+		for i, up := range group.upstreams {
+			for _, key := range up.APIKeys {
+				if key == apiKey {
+					result = append(result, gin.H{"kind": group.kind, "index": i, "name": up.Name})
+					break
+				}
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestTestUpstreamKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer sk-good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer upstream.Close()
+
+	cfg := config.Config{
+		ChatUpstream: []config.UpstreamConfig{
+			{Name: "existing", BaseURL: upstream.URL, APIKeys: []string{"sk-good"}, ServiceType: "openai"},
+		},
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		t.Fatalf("序列化配置失败: %v", err)
+	}
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	cfgManager, err := config.NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("创建配置管理器失败: %v", err)
+	}
+	t.Cleanup(func() { cfgManager.Close() })
+
+	r := gin.New()
+	r.POST("/upstream/test-key", TestUpstreamKey(cfgManager))
+
+	tests := []struct {
+		name           string
+		body           UpstreamKeyTestRequest
+		wantCode       int
+		wantSuccess    bool
+		wantStatusCode int
+		wantConfigured int
+	}{
+		{
+			name:           "有效 Key 返回 200",
+			body:           UpstreamKeyTestRequest{BaseURL: upstream.URL, APIKey: "sk-good", ServiceType: "openai", Model: "gpt-test"},
+			wantCode:       http.StatusOK,
+			wantSuccess:    true,
+			wantStatusCode: http.StatusOK,
+			wantConfigured: 1,
+		},
+		{
+			name:           "无效 Key 返回 401",
+			body:           UpstreamKeyTestRequest{BaseURL: upstream.URL, APIKey: "sk-bad", ServiceType: "openai", Model: "gpt-test"},
+			wantCode:       http.StatusOK,
+			wantSuccess:    false,
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "不支持的 serviceType",
+			body:     UpstreamKeyTestRequest{BaseURL: upstream.URL, APIKey: "sk-good", ServiceType: "unknown"},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "缺少 apiKey",
+			body:     UpstreamKeyTestRequest{BaseURL: upstream.URL, ServiceType: "openai"},
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, _ := json.Marshal(tt.body)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upstream/test-key", bytes.NewReader(payload)))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status=%d, want %d, body=%s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Success      bool             `json:"success"`
+				StatusCode   int              `json:"statusCode"`
+				Latency      int64            `json:"latency"`
+				Error        string           `json:"error"`
+				ErrorBody    string           `json:"errorBody"`
+				ConfiguredIn []map[string]any `json:"configuredIn"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("解析响应失败: %v", err)
+			}
+			if resp.Success != tt.wantSuccess || resp.StatusCode != tt.wantStatusCode {
+				t.Fatalf("success=%v statusCode=%d, want %v/%d", resp.Success, resp.StatusCode, tt.wantSuccess, tt.wantStatusCode)
+			}
+			if len(resp.ConfiguredIn) != tt.wantConfigured {
+				t.Fatalf("configuredIn=%v, want %d entries", resp.ConfiguredIn, tt.wantConfigured)
+			}
+			if !tt.wantSuccess {
+				if resp.Error != "http_error_401" || !bytes.Contains([]byte(resp.ErrorBody), []byte("invalid api key")) {
+					t.Fatalf("error=%q errorBody=%q, want http_error_401 with upstream body", resp.Error, resp.ErrorBody)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_StreamChannelLogCarriesUsage 流式请求成功后渠道日志记录流中的最终 token 用量
+func TestHandler_StreamChannelLogCarriesUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`event: message_start` + "\n" + `data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[],"usage":{"input_tokens":42,"output_tokens":1}}}`,
+			`event: content_block_start` + "\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hello world"}}`,
+			`event: content_block_stop` + "\n" + `data: {"type":"content_block_stop","index":0}`,
+			`event: message_delta` + "\n" + `data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"input_tokens":42,"output_tokens":17}}`,
+			`event: message_stop` + "\n" + `data: {"type":"message_stop"}`,
+		}
+		for _, event := range events {
+			_, _ = w.Write([]byte(event + "\n\n"))
+		}
+	}))
+	defer upstream.Close()
+
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{
+		{Name: "primary", BaseURL: upstream.URL, APIKeys: []string{"sk-stream"}, ServiceType: "claude", Status: "active", Priority: 1},
+	})
+
+	sch := newTestScheduler(t, cm)
+
+	envCfg := &config.EnvConfig{
+		ProxyAccessKey:     "test-key",
+		LogLevel:           "error",
+		RequestTimeout:     5000,
+		MaxRequestBodySize: 1024 * 1024,
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+	reqBody := `{"model":"claude-test","max_tokens":16,"stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "test-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want 200, body=%s", w.Code, w.Body.String())
+	}
+
+	logs := sch.GetChannelLogStore(scheduler.ChannelKindMessages).Get(0)
+	if len(logs) != 1 {
+		t.Fatalf("渠道日志数量=%d, want 1", len(logs))
+	}
+	if !logs[0].Success || logs[0].InputTokens != 42 || logs[0].OutputTokens != 17 {
+		t.Fatalf("渠道日志 success=%v input=%d output=%d, want true/42/17", logs[0].Success, logs[0].InputTokens, logs[0].OutputTokens)
+	}
+}
@@ -0,0 +1,87 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_ChannelPinHeader X-CCX-Channel 请求头跳过渠道调度，直接使用指定渠道
+func TestHandler_ChannelPinHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		enabled       bool
+		pinHeader     string
+		wantStatus    int
+		wantPrimary   int32
+		wantSecondary int32
+	}{
+		{name: "未携带请求头按优先级调度", enabled: true, pinHeader: "", wantStatus: http.StatusOK, wantPrimary: 1, wantSecondary: 0},
+		{name: "固定到低优先级渠道", enabled: true, pinHeader: "1", wantStatus: http.StatusOK, wantPrimary: 0, wantSecondary: 1},
+		{name: "功能关闭时忽略请求头", enabled: false, pinHeader: "1", wantStatus: http.StatusOK, wantPrimary: 1, wantSecondary: 0},
+		{name: "索引越界返回 400", enabled: true, pinHeader: "5", wantStatus: http.StatusBadRequest},
+		{name: "索引非整数返回 400", enabled: true, pinHeader: "abc", wantStatus: http.StatusBadRequest},
+		{name: "指定已禁用渠道返回 400", enabled: true, pinHeader: "2", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var primaryHits, secondaryHits atomic.Int32
+			newUpstream := func(hits *atomic.Int32) *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					hits.Add(1)
+					w.Header().Set("Content-Type", "application/json")
+					_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":5}}`))
+				}))
+			}
+			primary := newUpstream(&primaryHits)
+			defer primary.Close()
+			secondary := newUpstream(&secondaryHits)
+			defer secondary.Close()
+
+			cm := setupTestConfigManager(t, []config.UpstreamConfig{
+				{Name: "primary", BaseURL: primary.URL, APIKeys: []string{"sk-primary"}, ServiceType: "claude", Status: "active", Priority: 1},
+				{Name: "secondary", BaseURL: secondary.URL, APIKeys: []string{"sk-secondary"}, ServiceType: "claude", Status: "active", Priority: 2},
+				{Name: "disabled", BaseURL: secondary.URL, APIKeys: []string{"sk-disabled"}, ServiceType: "claude", Status: "disabled", Priority: 3},
+			})
+
+			sch := newTestScheduler(t, cm)
+
+			envCfg := &config.EnvConfig{
+				ProxyAccessKey:         "test-key",
+				LogLevel:               "error",
+				RequestTimeout:         5000,
+				MaxRequestBodySize:     1024 * 1024,
+				EnableChannelPinHeader: tt.enabled,
+			}
+
+			r := gin.New()
+			r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+			reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("x-api-key", "test-key")
+			if tt.pinHeader != "" {
+				req.Header.Set("X-CCX-Channel", tt.pinHeader)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status=%d, want=%d, body=%s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if primaryHits.Load() != tt.wantPrimary || secondaryHits.Load() != tt.wantSecondary {
+				t.Fatalf("上游请求次数 primary=%d secondary=%d, want primary=%d secondary=%d",
+					primaryHits.Load(), secondaryHits.Load(), tt.wantPrimary, tt.wantSecondary)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package messages
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_ChannelConcurrencyQueue 渠道并发已满时排队等待，排队超时后 failover 到下一个渠道
+func TestHandler_ChannelConcurrencyQueue(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		queueTimeoutMs int
+		releaseAfter   time.Duration
+		wantPrimary    int32
+		wantSecondary  int32
+		wantMaxElapsed time.Duration
+	}{
+		{
+			name:           "排队期间槽位释放，仍由首选渠道处理",
+			queueTimeoutMs: 2000,
+			releaseAfter:   100 * time.Millisecond,
+			wantPrimary:    1,
+			wantSecondary:  0,
+			wantMaxElapsed: 2 * time.Second,
+		},
+		{
+			name:           "排队超时后 failover 到下一个渠道",
+			queueTimeoutMs: 100,
+			releaseAfter:   0, // 不释放
+			wantPrimary:    0,
+			wantSecondary:  1,
+			wantMaxElapsed: time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var primaryHits, secondaryHits atomic.Int32
+			newUpstream := func(hits *atomic.Int32, text string) *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					hits.Add(1)
+					w.Header().Set("Content-Type", "application/json")
+					_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"` + text + `"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":5}}`))
+				}))
+			}
+			primary := newUpstream(&primaryHits, "from-primary")
+			defer primary.Close()
+			secondary := newUpstream(&secondaryHits, "from-secondary")
+			defer secondary.Close()
+
+			cm := setupTestConfigManager(t, []config.UpstreamConfig{
+				{Name: "primary", BaseURL: primary.URL, APIKeys: []string{"sk-primary"}, ServiceType: "claude", Status: "active", Priority: 1, MaxConcurrent: 1, QueueTimeoutMs: tt.queueTimeoutMs},
+				{Name: "secondary", BaseURL: secondary.URL, APIKeys: []string{"sk-secondary"}, ServiceType: "claude", Status: "active", Priority: 2},
+			})
+
+			sch := newTestScheduler(t, cm)
+
+			// 占满首选渠道的唯一并发槽位
+			cfg := cm.GetConfig()
+			release, err := sch.AcquireChannelSlot(context.Background(), scheduler.ChannelKindMessages, 0, &cfg.Upstream[0])
+			if err != nil {
+				t.Fatalf("占用槽位失败: %v", err)
+			}
+			defer release()
+			if tt.releaseAfter > 0 {
+				time.AfterFunc(tt.releaseAfter, release)
+			}
+
+			envCfg := &config.EnvConfig{
+				ProxyAccessKey:     "test-key",
+				LogLevel:           "error",
+				RequestTimeout:     5000,
+				MaxRequestBodySize: 1024 * 1024,
+			}
+
+			r := gin.New()
+			r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+			reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("x-api-key", "test-key")
+			w := httptest.NewRecorder()
+
+			start := time.Now()
+			r.ServeHTTP(w, req)
+			elapsed := time.Since(start)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status=%d, want=200, body=%s", w.Code, w.Body.String())
+			}
+			if primaryHits.Load() != tt.wantPrimary || secondaryHits.Load() != tt.wantSecondary {
+				t.Fatalf("上游请求次数 primary=%d secondary=%d, want primary=%d secondary=%d",
+					primaryHits.Load(), secondaryHits.Load(), tt.wantPrimary, tt.wantSecondary)
+			}
+			if elapsed > tt.wantMaxElapsed {
+				t.Fatalf("请求耗时 %v 超过 %v，排队不应无限阻塞", elapsed, tt.wantMaxElapsed)
+			}
+		})
+	}
+}
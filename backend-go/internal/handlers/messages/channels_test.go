@@ -8,8 +8,13 @@
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/session"
+	"github.com/BenedictKing/ccx/internal/warmup"
 	"github.com/gin-gonic/gin"
 )
 
@@ -32,6 +37,22 @@ func setupTestConfigManager(t *testing.T, upstream []config.UpstreamConfig) *con
 	return cm
 }
 
+// newTestScheduler 创建测试用调度器，各类型的指标管理器在测试结束时停止
+func newTestScheduler(t *testing.T, cm *config.ConfigManager) *scheduler.ChannelScheduler {
+	t.Helper()
+	messagesMetrics := metrics.NewMetricsManager()
+	responsesMetrics := metrics.NewMetricsManager()
+	geminiMetrics := metrics.NewMetricsManager()
+	chatMetrics := metrics.NewMetricsManager()
+	t.Cleanup(func() {
+		messagesMetrics.Stop()
+		responsesMetrics.Stop()
+		geminiMetrics.Stop()
+		chatMetrics.Stop()
+	})
+	return scheduler.NewChannelScheduler(cm, messagesMetrics, responsesMetrics, geminiMetrics, chatMetrics, session.NewTraceAffinityManager(), warmup.NewURLManager(30*time.Second, 3))
+}
+
 func newModelsRouter(cfgManager *config.ConfigManager) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
@@ -0,0 +1,99 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_RequestCoalescing 并发的相同非流式请求只调用一次上游，并共享同一响应
+func TestHandler_RequestCoalescing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const concurrency = 8
+	okBody := `{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`
+
+	var upstreamCalls atomic.Int32
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(okBody))
+	}))
+	defer upstream.Close()
+
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{
+		{Name: "primary", BaseURL: upstream.URL, APIKeys: []string{"sk-test"}, ServiceType: "claude", Status: "active", Priority: 1},
+	})
+
+	sch := newTestScheduler(t, cm)
+
+	envCfg := &config.EnvConfig{
+		ProxyAccessKey:          "test-key",
+		LogLevel:                "error",
+		RequestTimeout:          5000,
+		MaxRequestBodySize:      1024 * 1024,
+		EnableRequestCoalescing: true,
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+	body := `{"model":"claude-test","max_tokens":16,"temperature":0,"messages":[{"role":"user","content":"hi"}]}`
+	recorders := make([]*httptest.ResponseRecorder, concurrency)
+	var wg sync.WaitGroup
+	for i := range concurrency {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("x-api-key", "test-key")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			recorders[i] = w
+		}(i)
+	}
+
+	// 等待所有请求到达后再放行上游响应
+	time.Sleep(200 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := upstreamCalls.Load(); got != 1 {
+		t.Fatalf("上游调用次数=%d, want 1", got)
+	}
+	coalesced := 0
+	for i, w := range recorders {
+		if w.Code != http.StatusOK {
+			t.Fatalf("请求 %d status=%d, want 200, body=%s", i, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `"msg_1"`) {
+			t.Fatalf("请求 %d 未收到共享响应, body=%s", i, w.Body.String())
+		}
+		if w.Header().Get("X-CCX-Coalesced") == "true" {
+			coalesced++
+		}
+	}
+	if coalesced != concurrency-1 {
+		t.Fatalf("共享响应的请求数=%d, want %d", coalesced, concurrency-1)
+	}
+
+	// 上一次调用完成后，相同请求应重新发往上游
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "test-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || upstreamCalls.Load() != 2 {
+		t.Fatalf("status=%d upstreamCalls=%d, want 200/2", w.Code, upstreamCalls.Load())
+	}
+}
@@ -0,0 +1,115 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_CorrelationIDHeader 成功与所有渠道失败的响应都携带关联 ID，且与渠道日志中的 requestId 一致
+func TestHandler_CorrelationIDHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "成功响应",
+			status:     http.StatusOK,
+			body:       `{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "所有渠道失败",
+			status:     http.StatusServiceUnavailable,
+			body:       `{"type":"error","error":{"type":"overloaded_error","message":"overloaded"}}`,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer upstream.Close()
+
+			cm := setupTestConfigManager(t, []config.UpstreamConfig{
+				{Name: "primary", BaseURL: upstream.URL, APIKeys: []string{"sk-test"}, ServiceType: "claude", Status: "active", Priority: 1},
+			})
+
+			sch := newTestScheduler(t, cm)
+
+			envCfg := &config.EnvConfig{
+				ProxyAccessKey:     "test-key",
+				LogLevel:           "error",
+				RequestTimeout:     5000,
+				MaxRequestBodySize: 1024 * 1024,
+			}
+
+			r := gin.New()
+			r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+			reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("x-api-key", "test-key")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status=%d, want=%d, body=%s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			correlationID := w.Header().Get(common.CorrelationIDHeader)
+			if correlationID == "" {
+				t.Fatalf("响应缺少 %s 响应头", common.CorrelationIDHeader)
+			}
+
+			logs := sch.GetChannelLogStore(scheduler.ChannelKindMessages).Get(0)
+			if len(logs) == 0 {
+				t.Fatal("应记录渠道日志")
+			}
+			for _, l := range logs {
+				if l.RequestID != correlationID {
+					t.Fatalf("渠道日志 requestId=%q, want %q", l.RequestID, correlationID)
+				}
+			}
+		})
+	}
+}
+
+// TestHandler_CorrelationIDReusesClientRequestID 客户端携带 X-Request-ID 时沿用为关联 ID，认证失败的响应同样携带
+func TestHandler_CorrelationIDReusesClientRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{
+		{Name: "primary", BaseURL: "http://127.0.0.1:0", APIKeys: []string{"sk-test"}, ServiceType: "claude", Status: "active"},
+	})
+	sch := newTestScheduler(t, cm)
+
+	r := gin.New()
+	r.POST("/v1/messages", Handler(&config.EnvConfig{ProxyAccessKey: "test-key", LogLevel: "error", MaxRequestBodySize: 1024}, cm, sch))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{}`))
+	req.Header.Set(common.RequestIDHeader, "client-req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status=%d, want 401", w.Code)
+	}
+	if got := w.Header().Get(common.CorrelationIDHeader); got != "client-req-1" {
+		t.Fatalf("%s=%q, want client-req-1", common.CorrelationIDHeader, got)
+	}
+}
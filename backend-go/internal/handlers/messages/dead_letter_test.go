@@ -0,0 +1,139 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_AllChannelsFailedRecordsDeadLetter 所有渠道都失败时写入死信，包含尝试过的渠道与 Key
+func TestHandler_AllChannelsFailedRecordsDeadLetter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"overloaded"}}`))
+	}))
+	defer upstream.Close()
+
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{
+		{Name: "primary", BaseURL: upstream.URL, APIKeys: []string{"sk-primary-aaaaaaaa", "sk-primary-bbbbbbbb"}, ServiceType: "claude", Status: "active", Priority: 1},
+		{Name: "secondary", BaseURL: upstream.URL, APIKeys: []string{"sk-secondary-cccccccc"}, ServiceType: "claude", Status: "active", Priority: 2},
+	})
+
+	sch := newTestScheduler(t, cm)
+
+	envCfg := &config.EnvConfig{
+		ProxyAccessKey:     "test-key",
+		LogLevel:           "error",
+		RequestTimeout:     5000,
+		MaxRequestBodySize: 1024 * 1024,
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+	reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "test-key")
+	req.Header.Set(common.RequestIDHeader, "req-dead-letter-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status=%d, want=%d, body=%s", w.Code, http.StatusServiceUnavailable, w.Body.String())
+	}
+	if got := w.Header().Get(common.RequestIDHeader); got != "req-dead-letter-1" {
+		t.Fatalf("响应头 %s=%q, want req-dead-letter-1", common.RequestIDHeader, got)
+	}
+
+	entries := sch.GetDeadLetterStore().GetRecent("", 0)
+	if len(entries) != 1 {
+		t.Fatalf("死信条数=%d, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry.CorrelationID != "req-dead-letter-1" || entry.Kind != "messages" || entry.Model != "claude-test" {
+		t.Fatalf("死信元信息异常: %+v", entry)
+	}
+	if entry.StatusCode != http.StatusServiceUnavailable || !strings.Contains(entry.FinalError, "overloaded") {
+		t.Fatalf("死信最终错误异常: status=%d error=%q", entry.StatusCode, entry.FinalError)
+	}
+
+	wantKeys := map[string]string{
+		utils.MaskAPIKey("sk-primary-aaaaaaaa"):   "primary",
+		utils.MaskAPIKey("sk-primary-bbbbbbbb"):   "primary",
+		utils.MaskAPIKey("sk-secondary-cccccccc"): "secondary",
+	}
+	if len(entry.Attempts) != len(wantKeys) {
+		t.Fatalf("尝试次数=%d, want %d: %+v", len(entry.Attempts), len(wantKeys), entry.Attempts)
+	}
+	for _, attempt := range entry.Attempts {
+		channel, ok := wantKeys[attempt.KeyMask]
+		if !ok || channel != attempt.ChannelName {
+			t.Fatalf("未预期的尝试记录: %+v", attempt)
+		}
+		if attempt.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("尝试状态码=%d, want 503", attempt.StatusCode)
+		}
+		delete(wantKeys, attempt.KeyMask)
+	}
+}
+
+// TestHandler_SingleChannelAllKeysFailedRecordsDeadLetter 单渠道模式下所有 Key 都失败时同样写入死信
+func TestHandler_SingleChannelAllKeysFailedRecordsDeadLetter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"overloaded"}}`))
+	}))
+	defer upstream.Close()
+
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{
+		{Name: "only", BaseURL: upstream.URL, APIKeys: []string{"sk-only-aaaaaaaa", "sk-only-bbbbbbbb"}, ServiceType: "claude", Status: "active", Priority: 1},
+	})
+
+	sch := newTestScheduler(t, cm)
+	if sch.IsMultiChannelMode(scheduler.ChannelKindMessages) {
+		t.Fatal("单个渠道时不应处于多渠道模式")
+	}
+
+	envCfg := &config.EnvConfig{
+		ProxyAccessKey:     "test-key",
+		LogLevel:           "error",
+		RequestTimeout:     5000,
+		MaxRequestBodySize: 1024 * 1024,
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+	reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "test-key")
+	req.Header.Set(common.RequestIDHeader, "req-single-channel")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status=%d, want=%d, body=%s", w.Code, http.StatusServiceUnavailable, w.Body.String())
+	}
+	entries := sch.GetDeadLetterStore().GetRecent("", 0)
+	if len(entries) != 1 {
+		t.Fatalf("死信条数=%d, want 1", len(entries))
+	}
+	if entry := entries[0]; entry.CorrelationID != "req-single-channel" || entry.Model != "claude-test" || len(entry.Attempts) != 2 {
+		t.Fatalf("死信内容异常: %+v", entry)
+	}
+}
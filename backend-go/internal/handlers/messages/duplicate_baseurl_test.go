@@ -0,0 +1,66 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_DuplicateBaseURLsTriedOnce 配置中重复的 BaseURL 在一轮重试中只尝试一次
+func TestHandler_DuplicateBaseURLsTriedOnce(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newUpstream := func(hits *atomic.Int32) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"api_error","message":"internal error"}}`))
+		}))
+	}
+	var hitsA, hitsB atomic.Int32
+	upstreamA := newUpstream(&hitsA)
+	defer upstreamA.Close()
+	upstreamB := newUpstream(&hitsB)
+	defer upstreamB.Close()
+
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{{
+		Name:        "dup",
+		BaseURL:     upstreamA.URL,
+		BaseURLs:    []string{upstreamA.URL, upstreamB.URL, upstreamA.URL + "/"},
+		APIKeys:     []string{"sk-dup"},
+		ServiceType: "claude",
+		Status:      "active",
+	}})
+
+	sch := newTestScheduler(t, cm)
+
+	envCfg := &config.EnvConfig{
+		ProxyAccessKey:     "test-key",
+		LogLevel:           "error",
+		RequestTimeout:     5000,
+		MaxRequestBodySize: 1024 * 1024,
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+	reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "test-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status=%d, want=%d, body=%s", w.Code, http.StatusInternalServerError, w.Body.String())
+	}
+	if hitsA.Load() != 1 || hitsB.Load() != 1 {
+		t.Fatalf("上游请求次数 A=%d B=%d, want 各 1 次", hitsA.Load(), hitsB.Load())
+	}
+}
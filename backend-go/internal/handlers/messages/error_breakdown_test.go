@@ -0,0 +1,96 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_KeyErrorBreakdown 各类失败按 Key 计入 errorBreakdown
+func TestHandler_KeyErrorBreakdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		case "sk-unauthorized":
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+		case "sk-ratelimited":
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"rate limited"}}`))
+		case "sk-server":
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"api_error","message":"bad gateway"}}`))
+		case "sk-invalid":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><body>maintenance</body></html>`))
+		case "sk-timeout":
+			time.Sleep(1500 * time.Millisecond)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+		}
+	}))
+	defer upstream.Close()
+
+	keys := []string{"sk-unauthorized", "sk-ratelimited", "sk-server", "sk-invalid", "sk-timeout", "sk-ok"}
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{
+		{Name: "primary", BaseURL: upstream.URL, APIKeys: keys, ServiceType: "claude", Status: "active", Priority: 1, ResponseHeaderTimeout: 1},
+	})
+
+	sch := newTestScheduler(t, cm)
+	messagesMetrics := sch.GetMessagesMetricsManager()
+
+	envCfg := &config.EnvConfig{
+		ProxyAccessKey:     "test-key",
+		LogLevel:           "error",
+		RequestTimeout:     5000,
+		MaxRequestBodySize: 1024 * 1024,
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+	reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "test-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want 200, body=%s", w.Code, w.Body.String())
+	}
+
+	resp := messagesMetrics.ToResponseMultiURL(0, []string{upstream.URL}, keys, 0)
+	if len(resp.KeyMetrics) != len(keys) {
+		t.Fatalf("keyMetrics=%d, want %d", len(resp.KeyMetrics), len(keys))
+	}
+
+	want := map[string]map[string]int64{
+		"sk-unauthorized": {metrics.KeyErrorUnauthorized: 1},
+		"sk-ratelimited":  {metrics.KeyErrorRateLimited: 1},
+		"sk-server":       {metrics.KeyErrorServer: 1},
+		"sk-invalid":      {metrics.KeyErrorInvalidResponse: 1},
+		"sk-timeout":      {metrics.KeyErrorTimeout: 1},
+		"sk-ok":           nil,
+	}
+	for i, key := range keys {
+		got := resp.KeyMetrics[i].ErrorBreakdown
+		if len(got) != len(want[key]) {
+			t.Fatalf("%s errorBreakdown=%v, want %v", key, got, want[key])
+		}
+		for errorType, count := range want[key] {
+			if got[errorType] != count {
+				t.Fatalf("%s errorBreakdown[%s]=%d, want %d", key, errorType, got[errorType], count)
+			}
+		}
+	}
+}
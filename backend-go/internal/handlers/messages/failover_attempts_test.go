@@ -0,0 +1,83 @@
+package messages
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_MaxFailoverAttempts 跨渠道上游尝试总次数达到上限后停止 failover
+func TestHandler_MaxFailoverAttempts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const channelCount = 6 // 每个渠道 2 个 Key，共 12 次可尝试
+
+	tests := []struct {
+		name        string
+		maxAttempts int
+		wantHits    int32
+	}{
+		{name: "默认不限制", maxAttempts: 0, wantHits: channelCount * 2},
+		{name: "上限落在渠道内", maxAttempts: 3, wantHits: 3},
+		{name: "上限大于可尝试次数", maxAttempts: 100, wantHits: channelCount * 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(`{"type":"error","error":{"type":"api_error","message":"internal error"}}`))
+			}))
+			defer upstream.Close()
+
+			upstreams := make([]config.UpstreamConfig, 0, channelCount)
+			for i := 0; i < channelCount; i++ {
+				upstreams = append(upstreams, config.UpstreamConfig{
+					Name:        fmt.Sprintf("ch-%d", i),
+					BaseURL:     upstream.URL,
+					APIKeys:     []string{fmt.Sprintf("sk-%d-a", i), fmt.Sprintf("sk-%d-b", i)},
+					ServiceType: "claude",
+					Status:      "active",
+					Priority:    i + 1,
+				})
+			}
+			cm := setupTestConfigManager(t, upstreams)
+
+			sch := newTestScheduler(t, cm)
+
+			envCfg := &config.EnvConfig{
+				ProxyAccessKey:      "test-key",
+				LogLevel:            "error",
+				RequestTimeout:      5000,
+				MaxRequestBodySize:  1024 * 1024,
+				MaxFailoverAttempts: tt.maxAttempts,
+			}
+
+			r := gin.New()
+			r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+			reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("x-api-key", "test-key")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("status=%d, want=%d (最后一次上游错误), body=%s", w.Code, http.StatusInternalServerError, w.Body.String())
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Fatalf("上游请求次数=%d, want=%d", got, tt.wantHits)
+			}
+		})
+	}
+}
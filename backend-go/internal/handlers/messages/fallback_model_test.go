@@ -0,0 +1,89 @@
+package messages
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_FallbackModel 请求模型没有渠道支持时替换为 FALLBACK_MODEL，并在支持回退模型的渠道上成功
+func TestHandler_FallbackModel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		fallbackModel string
+		deniedModels  []string
+		wantStatus    int
+		wantUpstream  string // 上游收到的 model，空表示不应请求上游
+		wantHeader    string
+	}{
+		{name: "启用回退", fallbackModel: "claude-fallback", wantStatus: http.StatusOK, wantUpstream: "claude-fallback", wantHeader: "claude-fallback"},
+		{name: "未启用回退", fallbackModel: "", wantStatus: http.StatusServiceUnavailable},
+		{name: "回退模型被拒绝", fallbackModel: "claude-fallback", deniedModels: []string{"claude-fallback"}, wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotModel string
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				var req struct {
+					Model string `json:"model"`
+				}
+				_ = json.Unmarshal(body, &req)
+				gotModel = req.Model
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-fallback","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+			}))
+			defer upstream.Close()
+
+			cm := setupTestConfigManager(t, []config.UpstreamConfig{
+				{Name: "other", BaseURL: upstream.URL, APIKeys: []string{"sk-other"}, ServiceType: "claude", Status: "active", Priority: 1, SupportedModels: []string{"gpt-*"}},
+				{Name: "fallback", BaseURL: upstream.URL, APIKeys: []string{"sk-fallback"}, ServiceType: "claude", Status: "active", Priority: 2, SupportedModels: []string{"claude-fallback"}},
+			})
+			if len(tt.deniedModels) > 0 {
+				if err := cm.SetModelAccess("messages", config.ModelAccessList{DeniedModels: tt.deniedModels}); err != nil {
+					t.Fatalf("SetModelAccess() err = %v", err)
+				}
+			}
+
+			sch := newTestScheduler(t, cm)
+
+			envCfg := &config.EnvConfig{
+				ProxyAccessKey:     "test-key",
+				LogLevel:           "error",
+				RequestTimeout:     5000,
+				MaxRequestBodySize: 1024 * 1024,
+				FallbackModel:      tt.fallbackModel,
+			}
+
+			r := gin.New()
+			r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+			reqBody := `{"model":"unknown-model","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("x-api-key", "test-key")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status=%d, want=%d, body=%s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if gotModel != tt.wantUpstream {
+				t.Fatalf("上游收到 model=%q, want %q", gotModel, tt.wantUpstream)
+			}
+			if got := w.Header().Get(common.FallbackModelHeader); got != tt.wantHeader {
+				t.Fatalf("%s=%q, want %q", common.FallbackModelHeader, got, tt.wantHeader)
+			}
+		})
+	}
+}
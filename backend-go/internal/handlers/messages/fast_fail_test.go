@@ -0,0 +1,88 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_FastFailWhenAllChannelsUnhealthy 快速失败模式下所有渠道均已熔断时直接返回 503，不请求上游
+func TestHandler_FastFailWhenAllChannelsUnhealthy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		fastFail       bool
+		promotePrimary bool
+		wantStatus     int
+		wantUpstream   bool
+	}{
+		{name: "快速失败", fastFail: true, wantStatus: http.StatusServiceUnavailable, wantUpstream: false},
+		{name: "未启用时仍降级尝试", fastFail: false, wantStatus: http.StatusOK, wantUpstream: true},
+		{name: "促销渠道仍尝试", fastFail: true, promotePrimary: true, wantStatus: http.StatusOK, wantUpstream: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var upstreamCalls atomic.Int32
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstreamCalls.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+			}))
+			defer upstream.Close()
+
+			primary := config.UpstreamConfig{Name: "primary", BaseURL: upstream.URL, APIKeys: []string{"sk-primary"}, ServiceType: "claude", Status: "active", Priority: 1}
+			if tt.promotePrimary {
+				until := time.Now().Add(time.Hour)
+				primary.PromotionUntil = &until
+			}
+			cm := setupTestConfigManager(t, []config.UpstreamConfig{
+				primary,
+				{Name: "backup", BaseURL: upstream.URL + "/backup", APIKeys: []string{"sk-backup"}, ServiceType: "claude", Status: "active", Priority: 2},
+			})
+
+			sch := newTestScheduler(t, cm)
+			messagesMetrics := sch.GetMessagesMetricsManager()
+			// 两个渠道都达到熔断条件
+			for range messagesMetrics.GetWindowSize() {
+				messagesMetrics.RecordFailure(upstream.URL, "sk-primary")
+				messagesMetrics.RecordFailure(upstream.URL+"/backup", "sk-backup")
+			}
+
+			envCfg := &config.EnvConfig{
+				ProxyAccessKey:     "test-key",
+				LogLevel:           "error",
+				RequestTimeout:     5000,
+				MaxRequestBodySize: 1024 * 1024,
+				FastFail:           tt.fastFail,
+			}
+
+			r := gin.New()
+			r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+			reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("x-api-key", "test-key")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status=%d, want=%d, body=%s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if got := upstreamCalls.Load() > 0; got != tt.wantUpstream {
+				t.Fatalf("upstream called=%v, want=%v", got, tt.wantUpstream)
+			}
+			if !tt.wantUpstream && !strings.Contains(w.Body.String(), "熔断") {
+				t.Fatalf("body=%s, want fast-fail reason", w.Body.String())
+			}
+		})
+	}
+}
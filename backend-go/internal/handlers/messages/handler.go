@@ -4,7 +4,6 @@
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"time"
@@ -22,13 +21,31 @@
 // Handler Messages API 代理处理器
 // 支持多渠道调度：当配置多个渠道时自动启用
 func Handler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager, channelScheduler *scheduler.ChannelScheduler) gin.HandlerFunc {
+	// 幂等缓存：相同 Idempotency-Key 的非流式重试直接回放已完成的响应
+	idempotencyCache := common.NewIdempotencyCache(time.Duration(envCfg.IdempotencyTTL) * time.Second)
+	// 请求合并：并发的相同非流式请求共享同一次上游调用（ENABLE_REQUEST_COALESCING）
+	requestCoalescer := common.NewRequestCoalescer(envCfg.EnableRequestCoalescing)
+	// 响应缓存：确定性非流式请求在 TTL 内直接返回缓存（RESPONSE_CACHE_TTL）
+	responseCache := common.NewResponseCache(time.Duration(envCfg.ResponseCacheTTL)*time.Second, envCfg.ResponseCacheMaxEntries)
+
 	return gin.HandlerFunc(func(c *gin.Context) {
+		// 生成请求关联 ID 并写入响应头（认证失败等错误响应同样携带）
+		common.EnsureCorrelationID(c)
+
 		// 先进行认证
 		middleware.ProxyAuthMiddleware(envCfg)(c)
 		if c.IsAborted() {
 			return
 		}
 
+		// 请求级超时覆盖（X-CCX-Timeout-Ms，需开启 ENABLE_TIMEOUT_OVERRIDE_HEADER）
+		common.ApplyTimeoutOverride(c, envCfg)
+
+		// 全局限流（未配置 globalMaxRpm/globalMaxTpm 时不生效）
+		if !common.CheckGlobalRateLimit(c, channelScheduler, "Messages") {
+			return
+		}
+
 		startTime := time.Now()
 
 		// 读取请求体
@@ -56,14 +73,56 @@ func Handler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager, channel
 			_ = json.Unmarshal(bodyBytes, &claudeReq)
 		}
 
+		// 模型访问控制（allowedModels/deniedModels）
+		if !common.CheckModelAccess(c, cfgManager, string(scheduler.ChannelKindMessages), "Messages", claudeReq.Model) {
+			return
+		}
+
+		// 请求模型无渠道支持时替换为回退模型（FALLBACK_MODEL）
+		bodyBytes, claudeReq.Model = common.ApplyFallbackModel(c, envCfg, cfgManager, channelScheduler, scheduler.ChannelKindMessages, "Messages", bodyBytes, claudeReq.Model)
+
 		// 提取 user_id 用于 Trace 亲和性
 		userID := common.ExtractUserID(bodyBytes)
 
 		// 记录原始请求信息（仅在入口处记录一次）
 		common.LogOriginalRequest(c, bodyBytes, envCfg, "Messages")
 
-		// 检查是否为多渠道模式
-		isMultiChannel := channelScheduler.IsMultiChannelMode(scheduler.ChannelKindMessages)
+		if claudeReq.Stream {
+			// 单客户端并发流限制（未配置 MAX_STREAMS_PER_CLIENT 时不生效）
+			release, ok := common.AcquireClientStream(c, envCfg, "Messages")
+			if !ok {
+				return
+			}
+			defer release()
+
+			// 断线续传：携带续传令牌时回放缓冲事件，否则为本次流分配令牌（ENABLE_STREAM_RESUME）
+			if common.ResumeStream(c, envCfg, "Messages") {
+				return
+			}
+			finishResume := common.BeginResumableStream(c, envCfg, "Messages")
+			defer finishResume()
+		} else {
+			handled, finish := common.BeginIdempotentRequest(c, idempotencyCache, "Messages", bodyBytes)
+			if handled {
+				return
+			}
+			defer finish()
+
+			cached, finishCache := common.BeginResponseCache(c, responseCache, channelScheduler, scheduler.ChannelKindMessages, "Messages", bodyBytes)
+			if cached {
+				return
+			}
+			defer finishCache()
+
+			coalesced, finishCoalesce := common.BeginCoalescedRequest(c, requestCoalescer, "Messages", claudeReq.Model, bodyBytes)
+			if coalesced {
+				return
+			}
+			defer finishCoalesce()
+		}
+
+		// 检查是否为多渠道模式（携带固定渠道请求头时统一走多渠道流程）
+		isMultiChannel := channelScheduler.IsMultiChannelMode(scheduler.ChannelKindMessages) || common.HasChannelPin(c, envCfg)
 
 		if isMultiChannel {
 			handleMultiChannel(c, envCfg, cfgManager, channelScheduler, bodyBytes, claudeReq, userID, startTime)
@@ -93,78 +152,94 @@ func handleMultiChannel(
 		userID,
 		claudeReq.Model,
 		func(selection *scheduler.SelectionResult) common.MultiChannelAttemptResult {
-			upstream := selection.Upstream
-			channelIndex := selection.ChannelIndex
-
-			if upstream == nil {
-				return common.MultiChannelAttemptResult{}
+			return tryChannel(c, envCfg, cfgManager, channelScheduler, selection.Upstream, selection.ChannelIndex, bodyBytes, claudeReq, startTime)
+		},
+		func(selection *scheduler.SelectionResult, result common.MultiChannelAttemptResult) {
+			if result.SuccessKey != "" {
+				replayToShadowChannel(c, envCfg, cfgManager, channelScheduler, selection.ChannelIndex, bodyBytes, claudeReq)
 			}
+		},
+		func(ctx *gin.Context, failoverErr *common.FailoverError, lastError error) {
+			common.HandleAllChannelsFailed(ctx, cfgManager.GetFuzzyModeEnabled(), failoverErr, lastError, "Messages")
+		},
+	)
+}
 
-			provider := providers.GetProvider(upstream.ServiceType)
-			if provider == nil {
-				return common.MultiChannelAttemptResult{}
-			}
+// tryChannel 在指定渠道内按 Key/BaseURL 轮转尝试请求（多渠道调度与死信重放共用）
+func tryChannel(
+	c *gin.Context,
+	envCfg *config.EnvConfig,
+	cfgManager *config.ConfigManager,
+	channelScheduler *scheduler.ChannelScheduler,
+	upstream *config.UpstreamConfig,
+	channelIndex int,
+	bodyBytes []byte,
+	claudeReq types.ClaudeRequest,
+	startTime time.Time,
+) common.MultiChannelAttemptResult {
+	if upstream == nil {
+		return common.MultiChannelAttemptResult{}
+	}
+
+	provider := providers.GetProvider(upstream.ServiceType)
+	if provider == nil {
+		return common.MultiChannelAttemptResult{}
+	}
 
-			metricsManager := channelScheduler.GetMessagesMetricsManager()
-			baseURLs := upstream.GetAllBaseURLs()
-			sortedURLResults := channelScheduler.GetSortedURLsForChannel(scheduler.ChannelKindMessages, channelIndex, baseURLs)
-
-			handled, successKey, successBaseURLIdx, failoverErr, usage, lastErr := common.TryUpstreamWithAllKeys(
-				c,
-				envCfg,
-				cfgManager,
-				channelScheduler,
-				scheduler.ChannelKindMessages,
-				"Messages",
-				metricsManager,
-				upstream,
-				sortedURLResults,
-				bodyBytes,
-				claudeReq.Stream,
-				func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error) {
-					return cfgManager.GetNextAPIKey(upstream, failedKeys, "Messages")
-				},
-				func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
-					req, _, err := provider.ConvertToProviderRequest(c, upstreamCopy, apiKey)
-					return req, err
-				},
-				func(apiKey string) {
-					if err := cfgManager.DeprioritizeAPIKey(apiKey); err != nil {
-						log.Printf("[Messages-Key] 警告: 密钥降级失败: %v", err)
-					}
-				},
-				func(url string) {
-					channelScheduler.MarkURLFailure(scheduler.ChannelKindMessages, channelIndex, url)
-				},
-				func(url string) {
-					channelScheduler.MarkURLSuccess(scheduler.ChannelKindMessages, channelIndex, url)
-				},
-				func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, apiKey string) (*types.Usage, error) {
-					if claudeReq.Stream {
-						return common.HandleStreamResponse(c, resp, provider, envCfg, startTime, upstreamCopy, bodyBytes, claudeReq.Model)
-					}
-					return handleNormalResponse(c, resp, provider, envCfg, startTime, bodyBytes, upstreamCopy, apiKey)
-				},
-				claudeReq.Model,
-				selection.ChannelIndex,
-				channelScheduler.GetChannelLogStore(scheduler.ChannelKindMessages),
-			)
-
-			return common.MultiChannelAttemptResult{
-				Handled:           handled,
-				Attempted:         true,
-				SuccessKey:        successKey,
-				SuccessBaseURLIdx: successBaseURLIdx,
-				FailoverError:     failoverErr,
-				Usage:             usage,
-				LastError:         lastErr,
+	metricsManager := channelScheduler.GetMessagesMetricsManager()
+	baseURLs := upstream.GetAllBaseURLs()
+	sortedURLResults := channelScheduler.GetSortedURLsForChannel(scheduler.ChannelKindMessages, channelIndex, baseURLs)
+
+	handled, successKey, successBaseURLIdx, failoverErr, usage, lastErr := common.TryUpstreamWithAllKeys(
+		c,
+		envCfg,
+		cfgManager,
+		channelScheduler,
+		scheduler.ChannelKindMessages,
+		"Messages",
+		metricsManager,
+		upstream,
+		sortedURLResults,
+		bodyBytes,
+		claudeReq.Stream,
+		func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error) {
+			return cfgManager.GetNextAPIKey(upstream, failedKeys, "Messages")
+		},
+		func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
+			req, _, err := provider.ConvertToProviderRequest(c, upstreamCopy, apiKey)
+			return req, err
+		},
+		func(apiKey string) {
+			if err := cfgManager.DeprioritizeAPIKey(apiKey); err != nil {
+				log.Printf("[Messages-Key] 警告: 密钥降级失败: %v", err)
 			}
 		},
-		nil,
-		func(ctx *gin.Context, failoverErr *common.FailoverError, lastError error) {
-			common.HandleAllChannelsFailed(ctx, cfgManager.GetFuzzyModeEnabled(), failoverErr, lastError, "Messages")
+		func(url string) {
+			channelScheduler.MarkURLFailure(scheduler.ChannelKindMessages, channelIndex, url)
 		},
+		func(url string) {
+			channelScheduler.MarkURLSuccess(scheduler.ChannelKindMessages, channelIndex, url)
+		},
+		func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, apiKey string) (*types.Usage, error) {
+			if claudeReq.Stream {
+				return common.HandleStreamResponse(c, resp, provider, envCfg, startTime, upstreamCopy, bodyBytes, claudeReq.Model)
+			}
+			return handleNormalResponse(c, resp, provider, envCfg, startTime, bodyBytes, upstreamCopy, apiKey)
+		},
+		claudeReq.Model,
+		channelIndex,
+		channelScheduler.GetChannelLogStore(scheduler.ChannelKindMessages),
 	)
+
+	return common.MultiChannelAttemptResult{
+		Handled:           handled,
+		Attempted:         true,
+		SuccessKey:        successKey,
+		SuccessBaseURLIdx: successBaseURLIdx,
+		FailoverError:     failoverErr,
+		Usage:             usage,
+		LastError:         lastErr,
+	}
 }
 
 // handleSingleChannel 处理单渠道代理请求
@@ -205,7 +280,7 @@ func handleSingleChannel(
 
 	urlResults := common.BuildDefaultURLResults(baseURLs)
 
-	handled, _, _, lastFailoverError, _, lastError := common.TryUpstreamWithAllKeys(
+	handled, successKey, _, lastFailoverError, _, lastError := common.TryUpstreamWithAllKeys(
 		c,
 		envCfg,
 		cfgManager,
@@ -242,6 +317,9 @@ func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, a
 		channelScheduler.GetChannelLogStore(scheduler.ChannelKindMessages),
 	)
 	if handled {
+		if successKey != "" {
+			replayToShadowChannel(c, envCfg, cfgManager, channelScheduler, channelIndex, bodyBytes, claudeReq)
+		}
 		return
 	}
 
@@ -249,6 +327,45 @@ func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, a
 	common.HandleAllKeysFailed(c, cfgManager.GetFuzzyModeEnabled(), lastFailoverError, lastError, "Messages")
 }
 
+// replayToShadowChannel 主渠道成功后将非流式请求异步镜像到影子渠道（流式请求不镜像）
+func replayToShadowChannel(
+	c *gin.Context,
+	envCfg *config.EnvConfig,
+	cfgManager *config.ConfigManager,
+	channelScheduler *scheduler.ChannelScheduler,
+	channelIndex int,
+	bodyBytes []byte,
+	claudeReq types.ClaudeRequest,
+) {
+	if claudeReq.Stream {
+		return
+	}
+
+	common.ReplayToShadowChannel(
+		c,
+		envCfg,
+		cfgManager,
+		channelScheduler,
+		scheduler.ChannelKindMessages,
+		"Messages",
+		channelScheduler.GetMessagesMetricsManager(),
+		channelIndex,
+		bodyBytes,
+		claudeReq.Model,
+		func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error) {
+			return cfgManager.GetNextAPIKey(upstream, failedKeys, "Messages")
+		},
+		func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
+			provider := providers.GetProvider(upstreamCopy.ServiceType)
+			if provider == nil {
+				return nil, fmt.Errorf("unsupported service type: %s", upstreamCopy.ServiceType)
+			}
+			req, _, err := provider.ConvertToProviderRequest(c, upstreamCopy, apiKey)
+			return req, err
+		},
+	)
+}
+
 // handleNormalResponse 处理非流式响应
 func handleNormalResponse(
 	c *gin.Context,
@@ -262,9 +379,8 @@ func handleNormalResponse(
 ) (*types.Usage, error) {
 	defer resp.Body.Close()
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+	bodyBytes, err := common.ReadNonStreamBody(resp.Body, "Messages")
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to read response"})
 		return nil, err
 	}
 
@@ -381,6 +497,8 @@ func handleNormalResponse(
 // CountTokensHandler 处理 /v1/messages/count_tokens 请求
 func CountTokensHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager, channelScheduler *scheduler.ChannelScheduler) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		common.EnsureCorrelationID(c)
+
 		middleware.ProxyAuthMiddleware(envCfg)(c)
 		if c.IsAborted() {
 			return
@@ -0,0 +1,92 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_IdempotencyKeyReplaysCachedResponse 相同 Idempotency-Key 的重试直接回放缓存，不再请求上游
+func TestHandler_IdempotencyKeyReplaysCachedResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var upstreamHits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"hello"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":5}}`))
+	}))
+	defer upstream.Close()
+
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{
+		{Name: "primary", BaseURL: upstream.URL, APIKeys: []string{"sk-primary"}, ServiceType: "claude", Status: "active"},
+	})
+
+	sch := newTestScheduler(t, cm)
+
+	envCfg := &config.EnvConfig{
+		ProxyAccessKey:     "test-key",
+		LogLevel:           "error",
+		RequestTimeout:     5000,
+		MaxRequestBodySize: 1024 * 1024,
+		IdempotencyTTL:     60,
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+	send := func(idempotencyKey, content string) *httptest.ResponseRecorder {
+		reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"` + content + `"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", "test-key")
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("retry-1", "hi")
+	if first.Code != http.StatusOK {
+		t.Fatalf("首次请求 status=%d, body=%s", first.Code, first.Body.String())
+	}
+
+	second := send("retry-1", "hi")
+	if second.Code != http.StatusOK {
+		t.Fatalf("重试请求 status=%d, body=%s", second.Code, second.Body.String())
+	}
+	if got := upstreamHits.Load(); got != 1 {
+		t.Fatalf("相同 Idempotency-Key 上游请求次数=%d, want=1", got)
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Fatalf("回放响应与首次响应不一致:\nfirst=%s\nsecond=%s", first.Body.String(), second.Body.String())
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("回放响应应带 Idempotent-Replayed 头")
+	}
+
+	if w := send("retry-2", "hi"); w.Code != http.StatusOK {
+		t.Fatalf("不同 Key 请求 status=%d", w.Code)
+	}
+	if w := send("", "hi"); w.Code != http.StatusOK {
+		t.Fatalf("无 Key 请求 status=%d", w.Code)
+	}
+	if got := upstreamHits.Load(); got != 3 {
+		t.Fatalf("不同 Key/无 Key 应各请求上游一次, 上游请求次数=%d, want=3", got)
+	}
+
+	// 相同 Key 但请求体不同：不回放首次响应
+	if w := send("retry-1", "something else"); w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") == "true" {
+		t.Fatalf("相同 Key 不同请求体不应回放缓存: status=%d", w.Code)
+	}
+	if got := upstreamHits.Load(); got != 4 {
+		t.Fatalf("相同 Key 不同请求体应请求上游, 上游请求次数=%d, want=4", got)
+	}
+}
@@ -0,0 +1,99 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_TruncatedJSONFailsOver 上游返回 200 但 JSON 被截断时 failover 到下一个渠道，而不是直接返回 500
+func TestHandler_TruncatedJSONFailsOver(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const fullBody = `{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"hello"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":5}}`
+	truncated := fullBody[:len(fullBody)/2]
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "响应体为不完整 JSON",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(truncated))
+			},
+		},
+		{
+			name: "连接在 Content-Length 之前中断",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Length", strconv.Itoa(len(fullBody)))
+				_, _ = w.Write([]byte(truncated))
+				w.(http.Flusher).Flush()
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					conn.Close()
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var brokenHits, healthyHits atomic.Int32
+			broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				brokenHits.Add(1)
+				tt.handler(w, r)
+			}))
+			defer broken.Close()
+
+			healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				healthyHits.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(fullBody))
+			}))
+			defer healthy.Close()
+
+			cm := setupTestConfigManager(t, []config.UpstreamConfig{
+				{Name: "broken", BaseURL: broken.URL, APIKeys: []string{"sk-broken"}, ServiceType: "claude", Status: "active", Priority: 1},
+				{Name: "healthy", BaseURL: healthy.URL, APIKeys: []string{"sk-healthy"}, ServiceType: "claude", Status: "active", Priority: 2},
+			})
+
+			sch := newTestScheduler(t, cm)
+
+			envCfg := &config.EnvConfig{
+				ProxyAccessKey:     "test-key",
+				LogLevel:           "error",
+				RequestTimeout:     5000,
+				MaxRequestBodySize: 1024 * 1024,
+			}
+
+			r := gin.New()
+			r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+			reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("x-api-key", "test-key")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status=%d, want=200, body=%s", w.Code, w.Body.String())
+			}
+			if brokenHits.Load() == 0 || healthyHits.Load() != 1 {
+				t.Fatalf("上游请求次数 broken=%d healthy=%d, 期望 broken 失败后 failover 到 healthy", brokenHits.Load(), healthyHits.Load())
+			}
+			if !strings.Contains(w.Body.String(), `"hello"`) {
+				t.Fatalf("响应应来自 healthy 渠道, body=%s", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_ModelAccess 被拒绝的模型在调度前返回 403，不会请求上游
+func TestHandler_ModelAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"model":"claude-test","stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	defer upstream.Close()
+
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{{
+		Name:        "primary",
+		BaseURL:     upstream.URL,
+		APIKeys:     []string{"sk-primary"},
+		ServiceType: "claude",
+		Status:      "active",
+	}})
+	if err := cm.SetModelAccess("messages", config.ModelAccessList{
+		AllowedModels: []string{"claude-*"},
+		DeniedModels:  []string{"claude-opus-*"},
+	}); err != nil {
+		t.Fatalf("SetModelAccess() err = %v", err)
+	}
+
+	sch := newTestScheduler(t, cm)
+
+	envCfg := &config.EnvConfig{
+		ProxyAccessKey:     "test-key",
+		LogLevel:           "error",
+		RequestTimeout:     5000,
+		MaxRequestBodySize: 1024 * 1024,
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+	tests := []struct {
+		model    string
+		wantCode int
+	}{
+		{model: "claude-sonnet-4-5", wantCode: http.StatusOK},
+		{model: "claude-opus-4-1", wantCode: http.StatusForbidden},
+		{model: "gpt-4o", wantCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			before := hits.Load()
+			reqBody := `{"model":"` + tt.model + `","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("x-api-key", "test-key")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status=%d, want=%d, body=%s", w.Code, tt.wantCode, w.Body.String())
+			}
+			upstreamHit := hits.Load() > before
+			if tt.wantCode == http.StatusForbidden {
+				if upstreamHit {
+					t.Fatalf("被拒绝的模型不应请求上游")
+				}
+				if !strings.Contains(w.Body.String(), "MODEL_NOT_ALLOWED") || !strings.Contains(w.Body.String(), tt.model) {
+					t.Fatalf("403 响应应说明被拒绝的模型: %s", w.Body.String())
+				}
+			} else if !upstreamHit {
+				t.Fatalf("允许的模型应请求上游")
+			}
+		})
+	}
+}
@@ -8,9 +8,11 @@
 	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
 	"github.com/BenedictKing/ccx/internal/httpclient"
 	"github.com/BenedictKing/ccx/internal/middleware"
 	"github.com/BenedictKing/ccx/internal/scheduler"
@@ -34,15 +36,50 @@ type ModelEntry struct {
 	OwnedBy string `json:"owned_by"`
 }
 
-// ModelsHandler 处理 /v1/models 请求，从 Messages 和 Responses 渠道获取并合并模型列表
+// modelsCacheTTL 合并后的模型列表缓存时间
+const modelsCacheTTL = 30 * time.Second
+
+// modelsListCache 合并后的模型列表缓存（避免客户端频繁发现模型时逐个请求所有渠道）
+type modelsListCache struct {
+	mu        sync.Mutex
+	models    []ModelEntry
+	expiresAt time.Time
+}
+
+func (mc *modelsListCache) get() ([]ModelEntry, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.models == nil || time.Now().After(mc.expiresAt) {
+		return nil, false
+	}
+	return mc.models, true
+}
+
+func (mc *modelsListCache) set(models []ModelEntry) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.models = models
+	mc.expiresAt = time.Now().Add(modelsCacheTTL)
+}
+
+// ModelsHandler 处理 /v1/models 请求，查询 Messages 和 Responses 的每个活跃渠道并合并模型列表
+// 上游不支持 models 端点时回退到渠道配置的 supportedModels，合并结果短暂缓存
 func ModelsHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager, channelScheduler *scheduler.ChannelScheduler) gin.HandlerFunc {
+	cache := &modelsListCache{}
+
 	return func(c *gin.Context) {
+		common.EnsureCorrelationID(c)
+
 		middleware.ProxyAuthMiddleware(envCfg)(c)
 		if c.IsAborted() {
 			return
 		}
 
-		// 并行从两种渠道获取模型列表
+		if cached, ok := cache.get(); ok {
+			c.JSON(http.StatusOK, ModelsResponse{Object: "list", Data: cached})
+			return
+		}
+
 		messagesModels := fetchModelsFromChannels(c, cfgManager, channelScheduler, false)
 		responsesModels := fetchModelsFromChannels(c, cfgManager, channelScheduler, true)
 
@@ -59,6 +96,8 @@ func ModelsHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager, c
 			return
 		}
 
+		cache.set(mergedModels)
+
 		response := ModelsResponse{
 			Object: "list",
 			Data:   mergedModels,
@@ -74,6 +113,8 @@ func ModelsHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager, c
 // ModelsDetailHandler 处理 /v1/models/:model 请求，转发到上游
 func ModelsDetailHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager, channelScheduler *scheduler.ChannelScheduler) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		common.EnsureCorrelationID(c)
+
 		middleware.ProxyAuthMiddleware(envCfg)(c)
 		if c.IsAborted() {
 			return
@@ -111,42 +152,110 @@ func ModelsDetailHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigMana
 	}
 }
 
-// fetchModelsFromChannels 从指定类型的渠道获取模型列表
+// fetchModelsFromChannels 并行查询指定类型的每个活跃渠道，按渠道顺序合并模型列表
 func fetchModelsFromChannels(c *gin.Context, cfgManager *config.ConfigManager, channelScheduler *scheduler.ChannelScheduler, isResponses bool) []ModelEntry {
-	body, ok := tryModelsRequest(c, cfgManager, channelScheduler, "GET", "", isResponses)
-	if !ok {
-		return nil
+	kind := scheduler.ChannelKindMessages
+	channelType := "Messages"
+	if isResponses {
+		kind = scheduler.ChannelKindResponses
+		channelType = "Responses"
 	}
 
-	var resp ModelsResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
-		channelType := "Messages"
-		if isResponses {
-			channelType = "Responses"
+	upstreams := channelScheduler.GetUpstreams(kind)
+	results := make([][]ModelEntry, len(upstreams))
+	var wg sync.WaitGroup
+	for i := range upstreams {
+		upstream := upstreams[i]
+		if status := config.GetChannelStatus(&upstream); status != "active" || len(upstream.APIKeys) == 0 {
+			continue
 		}
-		log.Printf("[%s-Models] 解析渠道响应失败: %v", channelType, err)
-		return nil
+		wg.Add(1)
+		go func(i int, upstream *config.UpstreamConfig) {
+			defer wg.Done()
+			results[i] = fetchChannelModels(c, cfgManager, upstream, channelType)
+		}(i, &upstream)
 	}
+	wg.Wait()
 
-	return resp.Data
+	return mergeModels(results...)
 }
 
-// mergeModels 合并两个模型列表并去重（按 ID）
-func mergeModels(models1, models2 []ModelEntry) []ModelEntry {
-	seen := make(map[string]bool)
-	var result []ModelEntry
+// fetchChannelModels 请求单个渠道的 models 端点，失败时回退到 supportedModels 配置
+func fetchChannelModels(c *gin.Context, cfgManager *config.ConfigManager, upstream *config.UpstreamConfig, channelType string) []ModelEntry {
+	apiKey, err := cfgManager.GetNextAPIKey(upstream, nil, channelType)
+	if err != nil {
+		log.Printf("[%s-Models] 获取 API Key 失败: channel=%s, error=%v", channelType, upstream.Name, err)
+		return supportedModelEntries(upstream)
+	}
 
-	// 先添加第一个列表的模型
-	for _, m := range models1 {
-		if !seen[m.ID] {
-			seen[m.ID] = true
-			result = append(result, m)
+	url := buildModelsURL(upstream.BaseURL)
+	req, err := http.NewRequestWithContext(c.Request.Context(), "GET", url, nil)
+	if err != nil {
+		log.Printf("[%s-Models] 创建请求失败: channel=%s, url=%s, error=%v", channelType, upstream.Name, url, err)
+		return supportedModelEntries(upstream)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	if upstream.ServiceType == "claude" {
+		req.Header.Set("x-api-key", apiKey)
+		req.Header.Set("anthropic-version", "2023-06-01")
+	}
+
+	client := httpclient.GetManager().GetStandardClientForUpstream(modelsRequestTimeout, 0, upstream, upstream.ProxyURL)
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("[%s-Models] 请求失败，回退到 supportedModels: channel=%s, key=%s, url=%s, error=%v",
+			channelType, upstream.Name, utils.MaskAPIKey(apiKey), url, err)
+		return supportedModelEntries(upstream)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[%s-Models] 上游返回非 200，回退到 supportedModels: channel=%s, status=%d, url=%s",
+			channelType, upstream.Name, resp.StatusCode, url)
+		return supportedModelEntries(upstream)
+	}
+
+	var modelsResp ModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+		log.Printf("[%s-Models] 解析渠道响应失败，回退到 supportedModels: channel=%s, error=%v", channelType, upstream.Name, err)
+		return supportedModelEntries(upstream)
+	}
+
+	for i := range modelsResp.Data {
+		if modelsResp.Data[i].Object == "" {
+			modelsResp.Data[i].Object = "model"
 		}
 	}
+	return modelsResp.Data
+}
 
-	// 再添加第二个列表中不重复的模型
-	for _, m := range models2 {
-		if !seen[m.ID] {
+// supportedModelEntries 将渠道 supportedModels 中的具体模型名转换为模型条目（通配符无法枚举，跳过）
+func supportedModelEntries(upstream *config.UpstreamConfig) []ModelEntry {
+	var entries []ModelEntry
+	for _, model := range upstream.SupportedModels {
+		if model == "" || strings.HasSuffix(model, "*") {
+			continue
+		}
+		entries = append(entries, ModelEntry{
+			ID:      model,
+			Object:  "model",
+			OwnedBy: upstream.ServiceType,
+		})
+	}
+	return entries
+}
+
+// mergeModels 按顺序合并多个模型列表并去重（按 ID，保留首次出现的条目）
+func mergeModels(lists ...[]ModelEntry) []ModelEntry {
+	seen := make(map[string]bool)
+	var result []ModelEntry
+
+	for _, models := range lists {
+		for _, m := range models {
+			if m.ID == "" || seen[m.ID] {
+				continue
+			}
 			seen[m.ID] = true
 			result = append(result, m)
 		}
@@ -0,0 +1,91 @@
+package messages
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestModelsHandler_MergesAllChannels 合并每个活跃渠道的模型列表并去重，上游不支持 models 端点时回退到 supportedModels
+func TestModelsHandler_MergesAllChannels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var upstreamCalls atomic.Int32
+	newModelsServer := func(status int, ids ...string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			upstreamCalls.Add(1)
+			if r.URL.Path != "/v1/models" {
+				t.Errorf("path=%s, want /v1/models", r.URL.Path)
+			}
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+			resp := ModelsResponse{Object: "list"}
+			for _, id := range ids {
+				resp.Data = append(resp.Data, ModelEntry{ID: id, Object: "model", OwnedBy: "anthropic"})
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+		}))
+	}
+	first := newModelsServer(http.StatusOK, "claude-a", "claude-shared")
+	defer first.Close()
+	second := newModelsServer(http.StatusOK, "claude-shared", "claude-b")
+	defer second.Close()
+	unsupported := newModelsServer(http.StatusNotFound)
+	defer unsupported.Close()
+
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{
+		{Name: "first", BaseURL: first.URL, APIKeys: []string{"sk-1"}, ServiceType: "claude", Status: "active"},
+		{Name: "second", BaseURL: second.URL, APIKeys: []string{"sk-2"}, ServiceType: "claude", Status: "active"},
+		{Name: "unsupported", BaseURL: unsupported.URL, APIKeys: []string{"sk-3"}, ServiceType: "claude", Status: "active", SupportedModels: []string{"claude-c", "claude-shared", "claude-*"}},
+		{Name: "disabled", BaseURL: first.URL, APIKeys: []string{"sk-4"}, ServiceType: "claude", Status: "disabled", SupportedModels: []string{"claude-disabled"}},
+	})
+
+	sch := newTestScheduler(t, cm)
+
+	envCfg := &config.EnvConfig{ProxyAccessKey: "test-key", LogLevel: "error"}
+	r := gin.New()
+	r.GET("/v1/models", ModelsHandler(envCfg, cm, sch))
+
+	listModels := func() []string {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.Header.Set("x-api-key", "test-key")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status=%d, body=%s", w.Code, w.Body.String())
+		}
+		var resp ModelsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("解析响应失败: %v", err)
+		}
+		ids := make([]string, 0, len(resp.Data))
+		for _, m := range resp.Data {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+
+	want := []string{"claude-a", "claude-shared", "claude-b", "claude-c"}
+	if got := listModels(); !slices.Equal(got, want) {
+		t.Fatalf("models=%v, want %v", got, want)
+	}
+	if got := upstreamCalls.Load(); got != 3 {
+		t.Fatalf("upstream calls=%d, want 3", got)
+	}
+
+	// 缓存有效期内不再请求上游
+	if got := listModels(); !slices.Equal(got, want) {
+		t.Fatalf("cached models=%v, want %v", got, want)
+	}
+	if got := upstreamCalls.Load(); got != 3 {
+		t.Fatalf("upstream calls after cache hit=%d, want 3", got)
+	}
+}
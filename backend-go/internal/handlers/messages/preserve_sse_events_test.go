@@ -0,0 +1,102 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_PreserveSSEEvents Claude 透传流式响应中 event: 行保留并以空行分隔；开启 PRESERVE_SSE_EVENTS 时补全上游缺失的事件名
+func TestHandler_PreserveSSEEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_s\",\"type\":\"message\",\"role\":\"assistant\",\"model\":\"claude-test\",\"content\":[],\"usage\":{\"input_tokens\":1,\"output_tokens\":0}}}\n\n"))
+		_, _ = w.Write([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hello\"}}\n\n"))
+		// 部分兼容上游只发送 data: 行
+		_, _ = w.Write([]byte("data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\" world\"}}\n\n"))
+		_, _ = w.Write([]byte("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"input_tokens\":1,\"output_tokens\":2}}\n\n"))
+		_, _ = w.Write([]byte("data: {\"type\":\"message_stop\"}"))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name          string
+		preserve      bool
+		wantDeltas    int // 带 event: content_block_delta 的事件数
+		wantUnnamed   int // 缺少 event: 行的事件数
+		wantStopEvent bool
+	}{
+		{name: "默认原样透传上游 event 行", preserve: false, wantDeltas: 1, wantUnnamed: 2},
+		{name: "开启后补全缺失的事件名", preserve: true, wantDeltas: 2, wantUnnamed: 0, wantStopEvent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := setupTestConfigManager(t, []config.UpstreamConfig{
+				{Name: "primary", BaseURL: upstream.URL, APIKeys: []string{"sk-test"}, ServiceType: "claude", Status: "active", Priority: 1},
+			})
+
+			sch := newTestScheduler(t, cm)
+
+			envCfg := &config.EnvConfig{
+				ProxyAccessKey:     "test-key",
+				LogLevel:           "error",
+				RequestTimeout:     5000,
+				MaxRequestBodySize: 1024 * 1024,
+				PreserveSSEEvents:  tt.preserve,
+			}
+
+			r := gin.New()
+			r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":16,"stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("x-api-key", "test-key")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status=%d, body=%s", w.Code, w.Body.String())
+			}
+			body := w.Body.String()
+			if !strings.HasPrefix(body, "event: message_start\n") {
+				t.Fatalf("首个事件应为 message_start, body=%s", body)
+			}
+
+			deltas, unnamed, stopEvent := 0, 0, false
+			for _, frame := range strings.Split(strings.TrimSuffix(body, "\n\n"), "\n\n") {
+				if strings.TrimSpace(frame) == "" {
+					continue
+				}
+				lines := strings.Split(frame, "\n")
+				name, ok := strings.CutPrefix(lines[0], "event: ")
+				if !ok {
+					unnamed++
+					continue
+				}
+				if len(lines) != 2 || !strings.HasPrefix(lines[1], "data: ") {
+					t.Fatalf("event 行后应紧跟单个 data 行并以空行结束: %q", frame)
+				}
+				switch name {
+				case "content_block_delta":
+					deltas++
+				case "message_stop":
+					stopEvent = true
+				}
+			}
+			if deltas != tt.wantDeltas || unnamed != tt.wantUnnamed || stopEvent != tt.wantStopEvent {
+				t.Fatalf("content_block_delta=%d unnamed=%d message_stop=%v, want %d/%d/%v\nbody=%s",
+					deltas, unnamed, stopEvent, tt.wantDeltas, tt.wantUnnamed, tt.wantStopEvent, body)
+			}
+			if tt.preserve && (!strings.HasSuffix(body, "\n\n") || strings.Contains(body, "\n\n\n")) {
+				t.Fatalf("每个事件应以单个空行结束, body=%q", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package messages
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
+	"github.com/BenedictKing/ccx/internal/providers"
+	"github.com/gin-gonic/gin"
+)
+
+// BuildPreviewRequest 按真实请求路径的预处理与 provider 转换构建上游请求（不发送），用于请求预览
+func BuildPreviewRequest(c *gin.Context, cfgManager *config.ConfigManager, upstream *config.UpstreamConfig, apiKey string, bodyBytes []byte) (*http.Request, error) {
+	// 与 Handler 入口保持一致的请求体预处理
+	bodyBytes, _ = common.RemoveEmptySignatures(bodyBytes, false, "Messages")
+	if cfgManager.GetStripBillingHeader() {
+		bodyBytes, _ = common.RemoveBillingHeaders(bodyBytes, false, "Messages")
+	}
+	bodyBytes = common.NormalizeMetadataUserID(bodyBytes)
+
+	provider := providers.GetProvider(upstream.ServiceType)
+	if provider == nil {
+		return nil, fmt.Errorf("unsupported service type: %s", upstream.ServiceType)
+	}
+
+	previewCtx, err := common.NewPreviewContext(c, "/v1/messages", bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	req, _, err := provider.ConvertToProviderRequest(previewCtx, upstream, apiKey)
+	return req, err
+}
@@ -0,0 +1,84 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_GlobalRateLimit 超过全局 RPM 上限后返回 429 + Retry-After，放宽上限后恢复
+func TestHandler_GlobalRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var upstreamHits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"hello"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":5}}`))
+	}))
+	defer upstream.Close()
+
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{
+		{Name: "primary", BaseURL: upstream.URL, APIKeys: []string{"sk-primary"}, ServiceType: "claude", Status: "active"},
+	})
+	if err := cm.SetGlobalRateLimit(2, 0); err != nil {
+		t.Fatalf("设置全局限流失败: %v", err)
+	}
+
+	sch := newTestScheduler(t, cm)
+
+	envCfg := &config.EnvConfig{
+		ProxyAccessKey:     "test-key",
+		LogLevel:           "error",
+		RequestTimeout:     5000,
+		MaxRequestBodySize: 1024 * 1024,
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+	send := func() *httptest.ResponseRecorder {
+		reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", "test-key")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := send(); w.Code != http.StatusOK {
+			t.Fatalf("第 %d 个请求 status=%d, want=200, body=%s", i+1, w.Code, w.Body.String())
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		w := send()
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("超限请求 status=%d, want=429, body=%s", w.Code, w.Body.String())
+		}
+		if w.Header().Get("Retry-After") == "" {
+			t.Fatal("429 响应应带 Retry-After 头")
+		}
+	}
+	if got := upstreamHits.Load(); got != 2 {
+		t.Fatalf("被限流的请求不应转发到上游, 上游请求次数=%d, want=2", got)
+	}
+
+	// 放宽上限后恢复
+	if err := cm.SetGlobalRateLimit(3, 0); err != nil {
+		t.Fatalf("更新全局限流失败: %v", err)
+	}
+	if w := send(); w.Code != http.StatusOK {
+		t.Fatalf("放宽上限后 status=%d, want=200", w.Code)
+	}
+	if w := send(); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("再次达到上限后 status=%d, want=429", w.Code)
+	}
+}
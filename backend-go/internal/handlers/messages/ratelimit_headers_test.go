@@ -0,0 +1,91 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_ForwardsRateLimitHeaders 上游限流相关响应头在成功响应与 failover 最终的 429 响应中都转发给客户端
+func TestHandler_ForwardsRateLimitHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantStatus int
+		wantHeader map[string]string
+	}{
+		{
+			name:       "成功响应",
+			status:     http.StatusOK,
+			body:       `{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`,
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{
+				"anthropic-ratelimit-requests-remaining": "99",
+				"anthropic-ratelimit-tokens-reset":       "2026-01-01T00:00:00Z",
+			},
+		},
+		{
+			name:       "透传的 429",
+			status:     http.StatusTooManyRequests,
+			body:       `{"type":"error","error":{"type":"rate_limit_error","message":"rate limited"}}`,
+			wantStatus: http.StatusTooManyRequests,
+			wantHeader: map[string]string{
+				"anthropic-ratelimit-requests-remaining": "0",
+				"retry-after":                            "7",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				for k, v := range tt.wantHeader {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer upstream.Close()
+
+			cm := setupTestConfigManager(t, []config.UpstreamConfig{
+				{Name: "primary", BaseURL: upstream.URL, APIKeys: []string{"sk-test"}, ServiceType: "claude", Status: "active", Priority: 1},
+			})
+
+			sch := newTestScheduler(t, cm)
+
+			envCfg := &config.EnvConfig{
+				ProxyAccessKey:     "test-key",
+				LogLevel:           "error",
+				RequestTimeout:     5000,
+				MaxRequestBodySize: 1024 * 1024,
+			}
+
+			r := gin.New()
+			r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+			reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("x-api-key", "test-key")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status=%d, want=%d, body=%s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			for k, want := range tt.wantHeader {
+				if got := w.Header().Values(k); len(got) != 1 || got[0] != want {
+					t.Fatalf("header %s=%v, want [%s]", k, got, want)
+				}
+			}
+		})
+	}
+}
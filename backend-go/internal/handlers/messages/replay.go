@@ -0,0 +1,31 @@
+package messages
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/types"
+	"github.com/gin-gonic/gin"
+)
+
+// ReplayToChannel 将死信中保存的请求重放到指定渠道，仅在该渠道内的 Key/BaseURL 之间 failover
+// 上游响应直接写回 c，全部失败时按 Messages 错误格式返回；err 非空表示请求无法构建，此时未写响应
+func ReplayToChannel(c *gin.Context, envCfg *config.EnvConfig, cfgManager *config.ConfigManager, channelScheduler *scheduler.ChannelScheduler, upstream *config.UpstreamConfig, channelIndex int, bodyBytes []byte) (succeeded bool, err error) {
+	var claudeReq types.ClaudeRequest
+	if err := json.Unmarshal(bodyBytes, &claudeReq); err != nil {
+		return false, fmt.Errorf("invalid request body: %w", err)
+	}
+	if err := common.PrepareReplayRequest(c, "/v1/messages", bodyBytes); err != nil {
+		return false, err
+	}
+
+	result := tryChannel(c, envCfg, cfgManager, channelScheduler, upstream, channelIndex, bodyBytes, claudeReq, time.Now())
+	if !result.Handled {
+		common.HandleAllKeysFailed(c, cfgManager.GetFuzzyModeEnabled(), result.FailoverError, result.LastError, "Messages")
+	}
+	return result.SuccessKey != "", nil
+}
@@ -0,0 +1,98 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_ResponseCache 确定性请求命中缓存时不调用上游，并在渠道日志中标记 cacheHit
+func TestHandler_ResponseCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var upstreamCalls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":2}}`))
+	}))
+	defer upstream.Close()
+
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{
+		{Name: "primary", BaseURL: upstream.URL, APIKeys: []string{"sk-test"}, ServiceType: "claude", Status: "active", Priority: 1},
+	})
+
+	sch := newTestScheduler(t, cm)
+
+	envCfg := &config.EnvConfig{
+		ProxyAccessKey:          "test-key",
+		LogLevel:                "error",
+		RequestTimeout:          5000,
+		MaxRequestBodySize:      1024 * 1024,
+		ResponseCacheTTL:        60,
+		ResponseCacheMaxEntries: 10,
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", "test-key")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	deterministic := `{"model":"claude-test","max_tokens":16,"temperature":0,"messages":[{"role":"user","content":"hi"}]}`
+
+	tests := []struct {
+		name      string
+		body      string
+		wantCache string
+		wantCalls int32
+	}{
+		{"首次请求未命中", deterministic, "MISS", 1},
+		{"相同请求命中缓存", deterministic, "HIT", 1},
+		{"不同内容未命中", `{"model":"claude-test","max_tokens":16,"temperature":0,"messages":[{"role":"user","content":"hello"}]}`, "MISS", 2},
+		{"非确定性请求不缓存", `{"model":"claude-test","max_tokens":16,"temperature":0.7,"messages":[{"role":"user","content":"hi"}]}`, "", 3},
+		{"非确定性请求重复也调用上游", `{"model":"claude-test","max_tokens":16,"temperature":0.7,"messages":[{"role":"user","content":"hi"}]}`, "", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(tt.body)
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"msg_1"`) {
+				t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("X-CCX-Cache"); got != tt.wantCache {
+				t.Fatalf("X-CCX-Cache=%q, want %q", got, tt.wantCache)
+			}
+			if got := upstreamCalls.Load(); got != tt.wantCalls {
+				t.Fatalf("上游调用次数=%d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+
+	if got := sch.GetResponseCacheHits(scheduler.ChannelKindMessages); got != 1 {
+		t.Fatalf("缓存命中次数=%d, want 1", got)
+	}
+	cacheHits := 0
+	for _, entry := range sch.GetChannelLogStore(scheduler.ChannelKindMessages).Get(0) {
+		if entry.CacheHit {
+			cacheHits++
+			if !entry.Success || entry.Model != "claude-test" {
+				t.Fatalf("缓存命中日志=%+v", entry)
+			}
+		}
+	}
+	if cacheHits != 1 {
+		t.Fatalf("渠道日志中缓存命中条数=%d, want 1", cacheHits)
+	}
+}
@@ -0,0 +1,110 @@
+package messages
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_ResponseSizeLimit 上游响应超出大小限制：非流式 failover 到下一个 Key，流式转发已有数据后终止
+func TestHandler_ResponseSizeLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const limit = 4096
+	okBody := `{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if key == "sk-ok" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(okBody))
+			return
+		}
+		if key == "sk-huge" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"msg_huge","type":"message","content":[{"type":"text","text":"` + strings.Repeat("a", limit*4) + `"}]}`))
+			return
+		}
+
+		// sk-stream: 先发送 message_start 与首个文本片段（通过预检测），稍后持续发送 delta 直到远超限制
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		_, _ = w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_s\",\"type\":\"message\",\"role\":\"assistant\",\"model\":\"claude-test\",\"content\":[],\"usage\":{\"input_tokens\":1,\"output_tokens\":0}}}\n\n"))
+		_, _ = w.Write([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hello\"}}\n\n"))
+		flusher.Flush()
+		time.Sleep(50 * time.Millisecond)
+		for i := 0; i < 200; i++ {
+			_, _ = fmt.Fprintf(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"%s\"}}\n\n", strings.Repeat("b", 64))
+			flusher.Flush()
+		}
+	}))
+	defer upstream.Close()
+
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{
+		{Name: "primary", BaseURL: upstream.URL, APIKeys: []string{"sk-huge", "sk-ok"}, ServiceType: "claude", Status: "active", Priority: 1},
+	})
+
+	sch := newTestScheduler(t, cm)
+	messagesMetrics := sch.GetMessagesMetricsManager()
+
+	envCfg := &config.EnvConfig{
+		ProxyAccessKey:      "test-key",
+		LogLevel:            "error",
+		RequestTimeout:      5000,
+		MaxRequestBodySize:  1024 * 1024,
+		MaxResponseBodySize: limit,
+		MaxStreamBytes:      limit,
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", "test-key")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("非流式超限后 failover", func(t *testing.T) {
+		w := send(`{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status=%d, want 200, body=%s", w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `"msg_1"`) {
+			t.Fatalf("应返回下一个 Key 的响应, body=%s", w.Body.String())
+		}
+		got := messagesMetrics.GetKeyMetrics(upstream.URL, "sk-huge")
+		if got == nil || got.FailureCount != 1 {
+			t.Fatalf("超限 Key 应记录一次失败, metrics=%+v", got)
+		}
+	})
+
+	t.Run("流式超限后终止", func(t *testing.T) {
+		if _, err := cm.UpdateUpstream(0, config.UpstreamUpdate{APIKeys: []string{"sk-stream"}}); err != nil {
+			t.Fatalf("更新渠道失败: %v", err)
+		}
+		w := send(`{"model":"claude-test","max_tokens":16,"stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status=%d, want 200", w.Code)
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "message_start") {
+			t.Fatalf("超限前的数据应已转发, body=%s", body)
+		}
+		if !strings.Contains(body, "response too large") {
+			t.Fatalf("超限后应发送错误事件, body 末尾=%s", body[max(0, len(body)-300):])
+		}
+		if len(body) > limit*2 {
+			t.Fatalf("转发数据 %d 字节，应在限制 %d 附近终止", len(body), limit)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package messages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_RetryAfterSuspendsKey 上游 429 + Retry-After 后暂停该 Key，暂停期结束前不再使用
+func TestHandler_RetryAfterSuspendsKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	successBody := `{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":5}}`
+
+	var primaryHits, secondaryHits atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if primaryHits.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"rate limited"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(successBody))
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondaryHits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(successBody))
+	}))
+	defer secondary.Close()
+
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{
+		{Name: "primary", BaseURL: primary.URL, APIKeys: []string{"sk-primary"}, ServiceType: "claude", Status: "active", Priority: 1},
+		{Name: "secondary", BaseURL: secondary.URL, APIKeys: []string{"sk-secondary"}, ServiceType: "claude", Status: "active", Priority: 2},
+	})
+
+	sch := newTestScheduler(t, cm)
+	messagesMetrics := sch.GetMessagesMetricsManager()
+
+	envCfg := &config.EnvConfig{
+		ProxyAccessKey:     "test-key",
+		LogLevel:           "error",
+		RequestTimeout:     5000,
+		MaxRequestBodySize: 1024 * 1024,
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+	send := func() {
+		t.Helper()
+		reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", "test-key")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status=%d, want=200, body=%s", w.Code, w.Body.String())
+		}
+	}
+	assertHits := func(wantPrimary, wantSecondary int32) {
+		t.Helper()
+		if primaryHits.Load() != wantPrimary || secondaryHits.Load() != wantSecondary {
+			t.Fatalf("上游请求次数 primary=%d secondary=%d, want primary=%d secondary=%d",
+				primaryHits.Load(), secondaryHits.Load(), wantPrimary, wantSecondary)
+		}
+	}
+
+	// 首个请求：primary 返回 429 + Retry-After，failover 到 secondary
+	send()
+	assertHits(1, 1)
+	if !messagesMetrics.IsKeyRateLimited(primary.URL, "sk-primary") {
+		t.Fatalf("429 + Retry-After 后 Key 应处于暂停期")
+	}
+
+	// 暂停期内：primary 的 Key 被跳过
+	send()
+	assertHits(1, 2)
+
+	// 暂停期结束：primary 恢复使用
+	time.Sleep(1100 * time.Millisecond)
+	send()
+	assertHits(2, 2)
+}
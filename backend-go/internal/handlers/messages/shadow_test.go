@@ -0,0 +1,100 @@
+package messages
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// TestHandler_ShadowChannelReplay 主渠道成功后异步镜像到影子渠道：客户端响应不变，影子渠道指标递增
+func TestHandler_ShadowChannelReplay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	primaryResp := `{"id":"msg_primary","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"from primary"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":5}}`
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(primaryResp))
+	}))
+	defer primary.Close()
+
+	var shadowHits atomic.Int32
+	var shadowBody atomic.Value
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := new(bytes.Buffer)
+		_, _ = buf.ReadFrom(r.Body)
+		shadowBody.Store(buf.String())
+		shadowHits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_shadow","type":"message","role":"assistant","content":[{"type":"text","text":"from shadow"}],"usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	defer shadow.Close()
+
+	cm := setupTestConfigManager(t, []config.UpstreamConfig{
+		{Name: "primary", BaseURL: primary.URL, APIKeys: []string{"sk-primary"}, ServiceType: "claude", Status: "active"},
+		{Name: "shadow", BaseURL: shadow.URL, APIKeys: []string{"sk-shadow"}, ServiceType: "claude", Status: "disabled"},
+	})
+	if err := cm.SetShadowChannel("messages", 1); err != nil {
+		t.Fatalf("设置影子渠道失败: %v", err)
+	}
+
+	sch := newTestScheduler(t, cm)
+	messagesMetrics := sch.GetMessagesMetricsManager()
+
+	envCfg := &config.EnvConfig{
+		ProxyAccessKey:     "test-key",
+		LogLevel:           "error",
+		RequestTimeout:     5000,
+		MaxRequestBodySize: 1024 * 1024,
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", Handler(envCfg, cm, sch))
+
+	reqBody := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "test-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want=200, body=%s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "from primary") || strings.Contains(w.Body.String(), "from shadow") {
+		t.Fatalf("客户端响应应来自主渠道，实际: %s", w.Body.String())
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		km := messagesMetrics.GetKeyMetrics(shadow.URL, "sk-shadow")
+		if km != nil && km.SuccessCount == 1 {
+			if km.RequestCount != 1 {
+				t.Fatalf("影子渠道 RequestCount=%d, want=1", km.RequestCount)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待影子渠道指标超时, hits=%d", shadowHits.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := shadowHits.Load(); got != 1 {
+		t.Fatalf("影子渠道请求次数=%d, want=1", got)
+	}
+	if body, _ := shadowBody.Load().(string); !strings.Contains(body, `"content":"hi"`) {
+		t.Fatalf("影子渠道应收到相同请求体，实际: %s", body)
+	}
+
+	primaryKM := messagesMetrics.GetKeyMetrics(primary.URL, "sk-primary")
+	if primaryKM == nil || primaryKM.SuccessCount != 1 {
+		t.Fatalf("主渠道指标异常: %+v", primaryKM)
+	}
+}
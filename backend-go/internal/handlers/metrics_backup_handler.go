@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// metricsRestoreMaxBytes 恢复请求体上限（归档流式解码，不整体缓冲；gzip 压缩的二进制格式，百万级记录也远小于该值）
+const metricsRestoreMaxBytes = 256 << 20
+
+// BackupMetrics 导出请求历史归档（gzip 压缩的长度前缀二进制格式，可直接拼接追加）
+// GET /api/metrics/backup?kind=messages|responses|gemini|chat（未指定时导出全部类型，每个类型一段）
+func BackupMetrics(sch *scheduler.ChannelScheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kinds, ok := parseMetricsKinds(c)
+		if !ok {
+			return
+		}
+
+		scope := "all"
+		if len(kinds) == 1 {
+			scope = string(kinds[0])
+		}
+		filename := fmt.Sprintf("ccx-metrics-%s-%s.bin.gz", scope, time.Now().Format("20060102-150405"))
+		c.Header("Content-Type", "application/gzip")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Status(http.StatusOK)
+
+		for _, kind := range kinds {
+			if err := sch.GetMetricsManagerByKind(kind).ExportAllMetrics(c.Writer); err != nil {
+				// 响应头已发送，只能中断输出；不完整的归档在导入时会被识别
+				log.Printf("[Metrics-Backup] 警告: 导出 %s 指标归档失败: %v", kind, err)
+				return
+			}
+		}
+	}
+}
+
+// RestoreMetrics 从请求历史归档恢复指标（与内存中已有记录合并，重复记录跳过）
+// POST /api/metrics/restore?kind=messages|responses|gemini|chat（未指定时按归档中的段恢复全部类型）
+func RestoreMetrics(sch *scheduler.ChannelScheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kinds, ok := parseMetricsKinds(c)
+		if !ok {
+			return
+		}
+
+		// 归档边读边解码，不整体缓冲；上限仅用于拒绝异常大的上传
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, metricsRestoreMaxBytes)
+		managers := make([]*metrics.MetricsManager, 0, len(kinds))
+		for _, kind := range kinds {
+			managers = append(managers, sch.GetMetricsManagerByKind(kind))
+		}
+		results, err := metrics.ImportMetricsArchive(body, managers...)
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Archive exceeds %d bytes", int64(metricsRestoreMaxBytes)), "partial": results})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   fmt.Sprintf("Failed to restore metrics: %v", err),
+				"partial": results,
+			})
+			return
+		}
+
+		imported, sections := 0, 0
+		for _, result := range results {
+			imported += result.Imported
+			sections += result.Sections
+		}
+		if sections == 0 {
+			names := make([]string, 0, len(kinds))
+			for _, kind := range kinds {
+				names = append(names, string(kind))
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Archive contains no sections for: " + strings.Join(names, ", ")})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"imported": imported,
+			"byKind":   results,
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// GetOrphanMetrics 列出未映射到任何当前配置的指标（Key 轮换后遗留的数据）
+// GET /api/metrics/orphans?kind=messages|responses|gemini|chat（未指定时返回全部类型）
+func GetOrphanMetrics(sch *scheduler.ChannelScheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kinds, ok := parseMetricsKinds(c)
+		if !ok {
+			return
+		}
+
+		results := make(map[string][]scheduler.OrphanMetrics, len(kinds))
+		total := 0
+		for _, kind := range kinds {
+			orphans := sch.FindOrphanMetrics(kind)
+			results[string(kind)] = orphans
+			total += len(orphans)
+		}
+
+		c.JSON(200, gin.H{
+			"total":   total,
+			"orphans": results,
+		})
+	}
+}
+
+// PurgeOrphanMetrics 删除未映射到任何当前配置的指标（内存 + 持久化）
+// DELETE /api/metrics/orphans?kind=messages|responses|gemini|chat（未指定时处理全部类型）
+func PurgeOrphanMetrics(sch *scheduler.ChannelScheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kinds, ok := parseMetricsKinds(c)
+		if !ok {
+			return
+		}
+
+		purged := make(map[string]int, len(kinds))
+		total := 0
+		var deletedRecords int64
+		for _, kind := range kinds {
+			count, records := sch.PurgeOrphanMetrics(kind)
+			purged[string(kind)] = count
+			total += count
+			deletedRecords += records
+		}
+
+		c.JSON(200, gin.H{
+			"purged":         total,
+			"byKind":         purged,
+			"deletedRecords": deletedRecords,
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// parseMetricsKinds 解析 kind 查询参数（未指定时返回全部类型），无效时返回 ok=false
+func parseMetricsKinds(c *gin.Context) (kinds []scheduler.ChannelKind, ok bool) {
+	switch kind := strings.ToLower(c.Query("kind")); kind {
+	case "":
+		return []scheduler.ChannelKind{scheduler.ChannelKindMessages, scheduler.ChannelKindResponses, scheduler.ChannelKindGemini, scheduler.ChannelKindChat}, true
+	case "messages", "responses", "gemini", "chat":
+		return []scheduler.ChannelKind{scheduler.ChannelKind(kind)}, true
+	default:
+		c.JSON(400, gin.H{"error": "Invalid kind. Use: messages, responses, gemini, or chat"})
+		return nil, false
+	}
+}
+
+// ReconcileMetrics 按请求历史重新计算 Key 的聚合计数（修正计数漂移）
+// POST /api/metrics/reconcile?kind=messages|responses|gemini|chat（未指定时处理全部类型）
+func ReconcileMetrics(sch *scheduler.ChannelScheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kinds, ok := parseMetricsKinds(c)
+		if !ok {
+			return
+		}
+
+		results := make(map[string][]metrics.CounterCorrection, len(kinds))
+		total := 0
+		for _, kind := range kinds {
+			corrections := sch.GetMetricsManagerByKind(kind).ReconcileCounters()
+			if corrections == nil {
+				corrections = make([]metrics.CounterCorrection, 0)
+			}
+			results[string(kind)] = corrections
+			total += len(corrections)
+		}
+
+		c.JSON(200, gin.H{
+			"corrected":   total,
+			"corrections": results,
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveModel 解析指定渠道对某个模型的实际重定向结果（用于发送流量前验证模型别名）
+// GET /api/model-mapping/resolve?kind=messages|responses|gemini|chat&channelIndex=0&model=xxx
+func ResolveModel(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kind := strings.ToLower(c.DefaultQuery("kind", "messages"))
+		model := c.Query("model")
+		if model == "" {
+			c.JSON(400, gin.H{"error": "model is required"})
+			return
+		}
+
+		channelIndex, err := strconv.Atoi(c.Query("channelIndex"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid channelIndex"})
+			return
+		}
+
+		cfg := cfgManager.GetConfig()
+		var upstreams []config.UpstreamConfig
+		switch kind {
+		case "messages":
+			upstreams = cfg.Upstream
+		case "responses":
+			upstreams = cfg.ResponsesUpstream
+		case "gemini":
+			upstreams = cfg.GeminiUpstream
+		case "chat":
+			upstreams = cfg.ChatUpstream
+		default:
+			c.JSON(400, gin.H{"error": "Invalid kind. Use: messages, responses, gemini, or chat"})
+			return
+		}
+
+		if channelIndex < 0 || channelIndex >= len(upstreams) {
+			c.JSON(404, gin.H{"error": "Channel not found"})
+			return
+		}
+
+		upstream := &upstreams[channelIndex]
+		redirected, match := config.RedirectModelWithMatch(model, upstream, cfgManager.NormalizeModel)
+
+		c.JSON(200, gin.H{
+			"kind":            kind,
+			"channelIndex":    channelIndex,
+			"channelName":     upstream.Name,
+			"model":           model,
+			"redirectedModel": redirected,
+			"match":           match,
+			"supported":       upstream.SupportsModel(model),
+		})
+	}
+}
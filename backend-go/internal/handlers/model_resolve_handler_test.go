@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestResolveModel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Config{
+		ChatUpstream: []config.UpstreamConfig{
+			{
+				Name:         "chat-test",
+				ServiceType:  "openai",
+				BaseURL:      "https://example.com",
+				APIKeys:      []string{"test-key"},
+				ModelMapping: map[string]string{"gpt-4o": "gpt-4o-2024-11-20", "mini": "gpt-4o-mini"},
+			},
+		},
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		t.Fatalf("序列化配置失败: %v", err)
+	}
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	cfgManager, err := config.NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("创建配置管理器失败: %v", err)
+	}
+	t.Cleanup(func() { cfgManager.Close() })
+
+	r := gin.New()
+	r.GET("/model-mapping/resolve", ResolveModel(cfgManager))
+
+	tests := []struct {
+		name          string
+		query         string
+		wantStatus    int
+		wantModel     string
+		wantMatchType string
+	}{
+		{"精确匹配", "kind=chat&channelIndex=0&model=gpt-4o", 200, "gpt-4o-2024-11-20", config.ModelMatchExact},
+		{"模糊匹配", "kind=chat&channelIndex=0&model=gpt-4o-mini-tts", 200, "gpt-4o-2024-11-20", config.ModelMatchWildcard},
+		{"未命中", "kind=chat&channelIndex=0&model=o3", 200, "o3", config.ModelMatchNone},
+		{"缺少 model", "kind=chat&channelIndex=0", 400, "", ""},
+		{"无效 kind", "kind=unknown&channelIndex=0&model=o3", 400, "", ""},
+		{"渠道不存在", "kind=messages&channelIndex=0&model=o3", 404, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/model-mapping/resolve?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status=%d, want=%d, body=%s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != 200 {
+				return
+			}
+
+			var resp struct {
+				RedirectedModel string            `json:"redirectedModel"`
+				Match           config.ModelMatch `json:"match"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("解析响应失败: %v", err)
+			}
+			if resp.RedirectedModel != tt.wantModel {
+				t.Errorf("redirectedModel=%q, want=%q", resp.RedirectedModel, tt.wantModel)
+			}
+			if resp.Match.Type != tt.wantMatchType {
+				t.Errorf("match.type=%q, want=%q", resp.Match.Type, tt.wantMatchType)
+			}
+		})
+	}
+}
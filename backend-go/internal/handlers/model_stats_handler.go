@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"strings"
 	"time"
 
 	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
 	"github.com/gin-gonic/gin"
 )
 
@@ -33,15 +35,7 @@ func GetModelStatsHistory(metricsManager *metrics.MetricsManager) gin.HandlerFun
 		}
 
 		// 根据 duration 自动选择聚合粒度
-		var interval time.Duration
-		switch {
-		case duration <= time.Hour:
-			interval = time.Minute
-		case duration <= 6*time.Hour:
-			interval = 5 * time.Minute
-		default:
-			interval = 15 * time.Minute
-		}
+		interval := autoHistoryInterval(duration)
 
 		models := metricsManager.GetModelStatsHistory(duration, interval)
 
@@ -52,3 +46,61 @@ func GetModelStatsHistory(metricsManager *metrics.MetricsManager) gin.HandlerFun
 		})
 	}
 }
+
+// GetModelUsageSummary 获取按模型汇总的用量（请求数、成功率、token、估算费用）
+// GET /api/models/usage/summary?kind=all|messages|responses|gemini|chat&duration=1h|6h|24h|today
+func GetModelUsageSummary(sch *scheduler.ChannelScheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kind := strings.ToLower(c.DefaultQuery("kind", "all"))
+		kinds, ok := parseStatsKinds(kind)
+		if !ok {
+			c.JSON(400, gin.H{"error": "Invalid kind. Use: all, messages, responses, gemini, or chat"})
+			return
+		}
+
+		durationStr := c.DefaultQuery("duration", "24h")
+
+		var duration time.Duration
+		var err error
+
+		if durationStr == "today" {
+			duration = metrics.CalculateTodayDuration()
+			if duration < time.Minute {
+				duration = time.Minute
+			}
+		} else {
+			duration, err = time.ParseDuration(durationStr)
+			if err != nil || duration <= 0 {
+				c.JSON(400, gin.H{"error": "Invalid duration parameter. Use: 1h, 6h, 24h, or today"})
+				return
+			}
+		}
+
+		if duration > 24*time.Hour {
+			duration = 24 * time.Hour
+		}
+
+		c.JSON(200, gin.H{
+			"kind":     kind,
+			"duration": durationStr,
+			"models":   sch.GetModelUsageSummary(kinds, duration),
+		})
+	}
+}
+
+// parseStatsKinds 解析统计接口的 kind 参数（all 表示全部接口类型）
+func parseStatsKinds(kind string) ([]scheduler.ChannelKind, bool) {
+	switch kind {
+	case "all":
+		return []scheduler.ChannelKind{
+			scheduler.ChannelKindMessages,
+			scheduler.ChannelKindResponses,
+			scheduler.ChannelKindGemini,
+			scheduler.ChannelKindChat,
+		}, true
+	case "messages", "responses", "gemini", "chat":
+		return []scheduler.ChannelKind{scheduler.ChannelKind(kind)}, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/session"
+	"github.com/BenedictKing/ccx/internal/types"
+	"github.com/BenedictKing/ccx/internal/warmup"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetModelUsageSummary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Config{
+		ModelPricing: map[string]config.ModelPrice{
+			"claude-*": {Input: 3, Output: 15, CacheRead: 0.3},
+		},
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		t.Fatalf("序列化配置失败: %v", err)
+	}
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	cfgManager, err := config.NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("创建配置管理器失败: %v", err)
+	}
+	t.Cleanup(func() { cfgManager.Close() })
+
+	messagesMetrics := metrics.NewMetricsManager()
+	responsesMetrics := metrics.NewMetricsManager()
+	geminiMetrics := metrics.NewMetricsManager()
+	chatMetrics := metrics.NewMetricsManager()
+	t.Cleanup(func() {
+		messagesMetrics.Stop()
+		responsesMetrics.Stop()
+		geminiMetrics.Stop()
+		chatMetrics.Stop()
+	})
+	sch := scheduler.NewChannelScheduler(cfgManager, messagesMetrics, responsesMetrics, geminiMetrics, chatMetrics, session.NewTraceAffinityManager(), warmup.NewURLManager(30*time.Second, 3))
+
+	now := time.Now()
+	record := func(m *metrics.MetricsManager, baseURL, model string, at time.Time, usage *types.Usage) {
+		id := m.RecordRequestConnectedAt(baseURL, "sk-test", model, at)
+		if usage == nil {
+			m.RecordRequestFinalizeFailure(baseURL, "sk-test", id)
+			return
+		}
+		m.RecordRequestFinalizeSuccess(baseURL, "sk-test", id, usage)
+	}
+
+	// claude-sonnet：Messages 两个渠道各一次成功 + 一次失败
+	record(messagesMetrics, "https://a.example.com", "claude-sonnet", now.Add(-10*time.Minute), &types.Usage{InputTokens: 1000, OutputTokens: 200, CacheReadInputTokens: 500})
+	record(messagesMetrics, "https://b.example.com", "claude-sonnet", now.Add(-20*time.Minute), &types.Usage{InputTokens: 2000, OutputTokens: 300})
+	record(messagesMetrics, "https://b.example.com", "claude-sonnet", now.Add(-30*time.Minute), nil)
+	// gpt-4o：Chat 一次成功（无定价）
+	record(chatMetrics, "https://c.example.com", "gpt-4o", now.Add(-5*time.Minute), &types.Usage{InputTokens: 100, OutputTokens: 50})
+	// 窗口外的记录不计入
+	record(chatMetrics, "https://c.example.com", "gpt-4o", now.Add(-2*time.Hour), &types.Usage{InputTokens: 9999, OutputTokens: 9999})
+
+	r := gin.New()
+	r.GET("/models/usage/summary", GetModelUsageSummary(sch))
+
+	t.Run("全部接口 1h 窗口", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/models/usage/summary?duration=1h", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status=%d, body=%s", w.Code, w.Body.String())
+		}
+
+		var resp struct {
+			Models []metrics.ModelUsageSummary `json:"models"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("解析响应失败: %v", err)
+		}
+		if len(resp.Models) != 2 {
+			t.Fatalf("len(models)=%d, want 2: %s", len(resp.Models), w.Body.String())
+		}
+
+		sonnet := resp.Models[0]
+		if sonnet.Model != "claude-sonnet" || sonnet.RequestCount != 3 || sonnet.SuccessCount != 2 || sonnet.FailureCount != 1 {
+			t.Fatalf("claude-sonnet 汇总异常: %+v", sonnet)
+		}
+		if sonnet.InputTokens != 3000 || sonnet.OutputTokens != 500 || sonnet.CacheReadInputTokens != 500 {
+			t.Fatalf("claude-sonnet token 汇总异常: %+v", sonnet)
+		}
+		if math.Abs(sonnet.SuccessRate-200.0/3) > 0.01 {
+			t.Fatalf("claude-sonnet successRate=%v, want 66.67", sonnet.SuccessRate)
+		}
+		wantCost := (3000*3 + 500*15 + 500*0.3) / 1_000_000
+		if sonnet.EstimatedCost == nil || math.Abs(*sonnet.EstimatedCost-wantCost) > 1e-9 {
+			t.Fatalf("claude-sonnet estimatedCost=%v, want %v", sonnet.EstimatedCost, wantCost)
+		}
+
+		gpt := resp.Models[1]
+		if gpt.Model != "gpt-4o" || gpt.RequestCount != 1 || gpt.InputTokens != 100 || gpt.OutputTokens != 50 {
+			t.Fatalf("gpt-4o 汇总异常: %+v", gpt)
+		}
+		if gpt.EstimatedCost != nil {
+			t.Fatalf("未配置定价的模型不应返回 estimatedCost: %v", *gpt.EstimatedCost)
+		}
+	})
+
+	t.Run("按接口类型筛选", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/models/usage/summary?kind=chat&duration=1h", nil))
+		var resp struct {
+			Models []metrics.ModelUsageSummary `json:"models"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		if len(resp.Models) != 1 || resp.Models[0].Model != "gpt-4o" {
+			t.Fatalf("kind=chat 应只返回 gpt-4o: %s", w.Body.String())
+		}
+	})
+
+	t.Run("参数校验", func(t *testing.T) {
+		for _, query := range []string{"kind=unknown", "duration=abc"} {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/models/usage/summary?"+query, nil))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("%s: status=%d, want 400", query, w.Code)
+			}
+		}
+	})
+}
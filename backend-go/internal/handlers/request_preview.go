@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/chat"
+	"github.com/BenedictKing/ccx/internal/handlers/gemini"
+	"github.com/BenedictKing/ccx/internal/handlers/messages"
+	"github.com/BenedictKing/ccx/internal/handlers/responses"
+	"github.com/BenedictKing/ccx/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// UpstreamRequestPreviewRequest 上游请求预览参数
+type UpstreamRequestPreviewRequest struct {
+	Kind         string          `json:"kind"` // messages, responses, gemini, chat
+	ChannelIndex int             `json:"channelIndex"`
+	Model        string          `json:"model"`  // 仅 gemini 使用（对应 URL 路径中的模型名），其余类型从请求体读取
+	Stream       bool            `json:"stream"` // 仅 gemini 使用，其余类型从请求体读取
+	Body         json.RawMessage `json:"body"`   // 客户端原始请求体
+}
+
+// PreviewUpstreamRequest 预览客户端请求经模型映射与协议转换后实际发往上游的请求（不发送）
+// POST /api/upstream/preview-request
+// 使用渠道的第一个 BaseURL 与第一个 API Key 构建，返回的请求头中密钥已脱敏
+func PreviewUpstreamRequest(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpstreamRequestPreviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if len(req.Body) == 0 {
+			c.JSON(400, gin.H{"error": "body is required"})
+			return
+		}
+
+		cfg := cfgManager.GetConfig()
+		kind := strings.ToLower(req.Kind)
+		var upstreams []config.UpstreamConfig
+		switch kind {
+		case "messages":
+			upstreams = cfg.Upstream
+		case "responses":
+			upstreams = cfg.ResponsesUpstream
+		case "gemini":
+			upstreams = cfg.GeminiUpstream
+		case "chat":
+			upstreams = cfg.ChatUpstream
+		default:
+			c.JSON(400, gin.H{"error": "Invalid kind. Use: messages, responses, gemini, or chat"})
+			return
+		}
+		if req.ChannelIndex < 0 || req.ChannelIndex >= len(upstreams) {
+			c.JSON(404, gin.H{"error": "Channel not found"})
+			return
+		}
+
+		// 与真实请求一致：使用渠道副本并写入当前尝试的 BaseURL
+		upstream := upstreams[req.ChannelIndex].Clone()
+		upstream.BaseURL = upstream.GetEffectiveBaseURL()
+		apiKey := ""
+		if len(upstream.APIKeys) > 0 {
+			apiKey = upstream.APIKeys[0]
+		}
+
+		var httpReq *http.Request
+		var err error
+		switch kind {
+		case "messages":
+			httpReq, err = messages.BuildPreviewRequest(c, cfgManager, upstream, apiKey, req.Body)
+		case "responses":
+			httpReq, err = responses.BuildPreviewRequest(c, upstream, apiKey, req.Body)
+		case "gemini":
+			httpReq, err = gemini.BuildPreviewRequest(c, upstream, apiKey, req.Body, req.Model, req.Stream)
+		case "chat":
+			httpReq, err = chat.BuildPreviewRequest(c, upstream, apiKey, req.Body)
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		var body []byte
+		if httpReq.Body != nil {
+			body, _ = io.ReadAll(httpReq.Body)
+			httpReq.Body.Close()
+		}
+
+		headers := make(map[string]string, len(httpReq.Header))
+		for key, values := range httpReq.Header {
+			if len(values) > 0 {
+				headers[key] = values[0]
+			}
+		}
+
+		result := gin.H{
+			"kind":         kind,
+			"channelIndex": req.ChannelIndex,
+			"channelName":  upstream.Name,
+			"serviceType":  upstream.ServiceType,
+			"method":       httpReq.Method,
+			"url":          httpReq.URL.String(),
+			"headers":      utils.MaskSensitiveHeaders(headers),
+		}
+		if json.Valid(body) {
+			result["body"] = json.RawMessage(body)
+		} else {
+			result["body"] = string(body)
+		}
+		c.JSON(200, result)
+	}
+}
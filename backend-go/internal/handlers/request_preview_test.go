@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestPreviewUpstreamRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Config{
+		GeminiUpstream: []config.UpstreamConfig{
+			{
+				Name:         "claude-backend",
+				BaseURL:      "https://claude.example.com",
+				APIKeys:      []string{"sk-ant-REDACTED"},
+				ServiceType:  "claude",
+				ModelMapping: map[string]string{"gemini-2.5-pro": "claude-sonnet-4"},
+			},
+		},
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		t.Fatalf("序列化配置失败: %v", err)
+	}
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	cfgManager, err := config.NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("创建配置管理器失败: %v", err)
+	}
+	t.Cleanup(func() { cfgManager.Close() })
+
+	r := gin.New()
+	r.POST("/upstream/preview-request", PreviewUpstreamRequest(cfgManager))
+
+	post := func(t *testing.T, payload string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/upstream/preview-request", bytes.NewReader([]byte(payload)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", "admin-secret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Gemini 请求转换为 Claude", func(t *testing.T) {
+		w := post(t, `{"kind":"gemini","channelIndex":0,"model":"gemini-2.5-pro","body":{"contents":[{"role":"user","parts":[{"text":"hello"}]}],"generationConfig":{"maxOutputTokens":64}}}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status=%d, body=%s", w.Code, w.Body.String())
+		}
+
+		var resp struct {
+			URL     string            `json:"url"`
+			Method  string            `json:"method"`
+			Headers map[string]string `json:"headers"`
+			Body    struct {
+				Model     string `json:"model"`
+				MaxTokens int    `json:"max_tokens"`
+				Stream    bool   `json:"stream"`
+				Messages  []struct {
+					Role string `json:"role"`
+				} `json:"messages"`
+			} `json:"body"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("解析响应失败: %v", err)
+		}
+		if resp.URL != "https://claude.example.com/v1/messages" || resp.Method != http.MethodPost {
+			t.Fatalf("url=%s method=%s", resp.URL, resp.Method)
+		}
+		if resp.Body.Model != "claude-sonnet-4" {
+			t.Fatalf("model=%q, want 模型映射后的 claude-sonnet-4", resp.Body.Model)
+		}
+		if resp.Body.MaxTokens != 64 || resp.Body.Stream || len(resp.Body.Messages) != 1 || resp.Body.Messages[0].Role != "user" {
+			t.Fatalf("转换后的请求体不符合预期: %s", w.Body.String())
+		}
+		if resp.Headers["Anthropic-Version"] == "" {
+			t.Fatalf("缺少 anthropic-version 头: %v", resp.Headers)
+		}
+		if strings.Contains(w.Body.String(), "sk-ant-REDACTED") || strings.Contains(w.Body.String(), "admin-secret") {
+			t.Fatalf("响应中不应包含明文密钥: %s", w.Body.String())
+		}
+	})
+
+	t.Run("无效参数", func(t *testing.T) {
+		tests := []struct {
+			payload  string
+			wantCode int
+		}{
+			{`{"kind":"unknown","channelIndex":0,"body":{}}`, http.StatusBadRequest},
+			{`{"kind":"gemini","channelIndex":3,"model":"m","body":{}}`, http.StatusNotFound},
+			{`{"kind":"gemini","channelIndex":0,"body":{}}`, http.StatusBadRequest},
+			{`{"kind":"gemini","channelIndex":0,"model":"m"}`, http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			if w := post(t, tt.payload); w.Code != tt.wantCode {
+				t.Fatalf("%s status=%d, want %d", tt.payload, w.Code, tt.wantCode)
+			}
+		}
+	})
+}
@@ -29,28 +29,52 @@ func GetUpstreams(cfgManager *config.ConfigManager) gin.HandlerFunc {
 			priority := config.GetChannelPriority(&up, i)
 
 			upstreams[i] = gin.H{
-				"index":              i,
-				"name":               up.Name,
-				"serviceType":        up.ServiceType,
-				"baseUrl":            up.BaseURL,
-				"baseUrls":           up.BaseURLs,
-				"apiKeys":            up.APIKeys,
-				"description":        up.Description,
-				"website":            up.Website,
-				"insecureSkipVerify": up.InsecureSkipVerify,
-				"modelMapping":       up.ModelMapping,
-				"reasoningMapping":   up.ReasoningMapping,
-				"textVerbosity":      up.TextVerbosity,
-				"fastMode":           up.FastMode,
-				"latency":            nil,
-				"status":             status,
-				"priority":           priority,
-				"promotionUntil":     up.PromotionUntil,
-				"lowQuality":         up.LowQuality,
-				"rpm":                up.RPM,
-				"customHeaders":      up.CustomHeaders,
-				"proxyUrl":           up.ProxyURL,
-				"supportedModels":    up.SupportedModels,
+				"index":                    i,
+				"name":                     up.Name,
+				"serviceType":              up.ServiceType,
+				"baseUrl":                  up.BaseURL,
+				"baseUrls":                 up.BaseURLs,
+				"apiKeys":                  up.APIKeys,
+				"description":              up.Description,
+				"website":                  up.Website,
+				"insecureSkipVerify":       up.InsecureSkipVerify,
+				"modelMapping":             up.ModelMapping,
+				"reasoningMapping":         up.ReasoningMapping,
+				"textVerbosity":            up.TextVerbosity,
+				"fastMode":                 up.FastMode,
+				"latency":                  nil,
+				"status":                   status,
+				"priority":                 priority,
+				"promotionUntil":           up.PromotionUntil,
+				"lowQuality":               up.LowQuality,
+				"rpm":                      up.RPM,
+				"customHeaders":            up.CustomHeaders,
+				"forwardHeaderAllowlist":   up.ForwardHeaderAllowlist,
+				"stripHeaders":             up.StripHeaders,
+				"proxyUrl":                 up.ProxyURL,
+				"proxyUrls":                up.ProxyURLs,
+				"compressUpstreamRequests": up.CompressUpstreamRequests,
+				"failoverStatusCodes":      up.FailoverStatusCodes,
+				"noFailoverStatusCodes":    up.NoFailoverStatusCodes,
+				"deployment":               up.Deployment,
+				"apiVersion":               up.APIVersion,
+				"caCertPath":               up.CACertPath,
+				"clientCertPath":           up.ClientCertPath,
+				"clientKeyPath":            up.ClientKeyPath,
+				"serverName":               up.ServerName,
+				"supportedModels":          up.SupportedModels,
+				"autoReorderKeys":          up.AutoReorderKeys,
+				"responseHeaderTimeout":    up.ResponseHeaderTimeout,
+				"streamIdleTimeout":        up.StreamIdleTimeout,
+				"maxConcurrent":            up.MaxConcurrent,
+				"queueTimeoutMs":           up.QueueTimeoutMs,
+				"dailyTokenBudget":         up.DailyTokenBudget,
+				"streamEventDenylist":      up.StreamEventDenylist,
+				"bodyTransforms":           up.BodyTransforms,
+				"circuitFailureThreshold":  up.CircuitFailureThreshold,
+				"circuitRecoverySeconds":   up.CircuitRecoverySeconds,
+				"canaryPercent":            up.CanaryPercent,
+				"keySprayCount":            up.KeySprayCount,
 			}
 		}
 
@@ -94,6 +118,7 @@ func UpdateUpstream(cfgManager *config.ConfigManager, sch *scheduler.ChannelSche
 			return
 		}
 
+		previousKeys := sch.ChannelAPIKeys(id, scheduler.ChannelKindResponses)
 		shouldResetMetrics, err := cfgManager.UpdateResponsesUpstream(id, updates)
 		if err != nil {
 			c.JSON(500, gin.H{"error": err.Error()})
@@ -103,6 +128,9 @@ func UpdateUpstream(cfgManager *config.ConfigManager, sch *scheduler.ChannelSche
 		// 单 key 更换时重置熔断状态
 		if shouldResetMetrics {
 			sch.ResetChannelMetrics(id, scheduler.ChannelKindResponses)
+		} else if updates.APIKeys != nil {
+			// 新增 key 时重置其熔断状态（可能从历史列表恢复）
+			sch.ResetAddedKeysFailureState(id, scheduler.ChannelKindResponses, previousKeys)
 		}
 
 		c.JSON(200, gin.H{"message": "Responses upstream updated successfully"})
@@ -130,13 +158,14 @@ func DeleteUpstream(cfgManager *config.ConfigManager, channelScheduler *schedule
 		}
 
 		channelScheduler.GetChannelLogStore(scheduler.ChannelKindResponses).ClearAll()
+		channelScheduler.ResetChannelSelectionCounts(scheduler.ChannelKindResponses)
 
 		c.JSON(200, gin.H{"message": "Responses upstream deleted successfully"})
 	}
 }
 
 // AddApiKey 添加 Responses 渠道 API 密钥
-func AddApiKey(cfgManager *config.ConfigManager) gin.HandlerFunc {
+func AddApiKey(cfgManager *config.ConfigManager, sch *scheduler.ChannelScheduler) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		idStr := c.Param("id")
 		id, err := strconv.Atoi(idStr)
@@ -153,6 +182,7 @@ func AddApiKey(cfgManager *config.ConfigManager) gin.HandlerFunc {
 			return
 		}
 
+		previousKeys := sch.ChannelAPIKeys(id, scheduler.ChannelKindResponses)
 		if err := cfgManager.AddResponsesAPIKey(id, req.APIKey); err != nil {
 			if strings.Contains(err.Error(), "无效的上游索引") {
 				c.JSON(404, gin.H{"error": "Upstream not found"})
@@ -164,6 +194,9 @@ func AddApiKey(cfgManager *config.ConfigManager) gin.HandlerFunc {
 			return
 		}
 
+		// 新增 key 可能从历史列表恢复，重置其熔断状态（与 PUT 更新渠道一致）
+		sch.ResetAddedKeysFailureState(id, scheduler.ChannelKindResponses, previousKeys)
+
 		c.JSON(200, gin.H{
 			"message": "API密钥已添加",
 			"success": true,
@@ -16,6 +16,7 @@
 	"github.com/BenedictKing/ccx/internal/session"
 	"github.com/BenedictKing/ccx/internal/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
 )
 
 // compactError 封装 compact 请求错误
@@ -34,12 +35,17 @@ func CompactHandler(
 	channelScheduler *scheduler.ChannelScheduler,
 ) gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
+		common.EnsureCorrelationID(c)
+
 		// 认证
 		middleware.ProxyAuthMiddleware(envCfg)(c)
 		if c.IsAborted() {
 			return
 		}
 
+		// 请求级超时覆盖（X-CCX-Timeout-Ms，需开启 ENABLE_TIMEOUT_OVERRIDE_HEADER）
+		common.ApplyTimeoutOverride(c, envCfg)
+
 		// 读取请求体
 		maxBodySize := envCfg.MaxRequestBodySize
 		bodyBytes, err := common.ReadRequestBody(c, maxBodySize)
@@ -255,17 +261,25 @@ func tryCompactWithKey(
 	cfgManager *config.ConfigManager,
 ) (bool, *compactError) {
 	targetURL := buildCompactURL(upstream)
+	bodyBytes, err := config.ApplyBodyTransforms(bodyBytes, upstream.BodyTransforms)
+	if err != nil {
+		return false, &compactError{status: 500, body: []byte(`{"error":"请求体改写失败"}`), shouldFailover: true}
+	}
 	req, err := http.NewRequestWithContext(c.Request.Context(), "POST", targetURL, bytes.NewReader(bodyBytes))
 	if err != nil {
 		return false, &compactError{status: 500, body: []byte(`{"error":"创建请求失败"}`), shouldFailover: true}
 	}
 
 	req.Header = utils.PrepareUpstreamHeaders(c, req.URL.Host)
+	utils.FilterForwardedHeaders(req.Header, upstream.ForwardHeaderAllowlist, upstream.StripHeaders)
 	req.Header.Del("authorization")
 	req.Header.Del("x-api-key")
 	utils.SetAuthenticationHeader(req.Header, apiKey)
 	req.Header.Set("Content-Type", "application/json")
-	utils.ApplyCustomHeaders(req.Header, upstream.CustomHeaders)
+	utils.ApplyCustomHeaders(req.Header, upstream.CustomHeaders, utils.HeaderTemplateVars{
+		Model:  gjson.GetBytes(bodyBytes, "model").String(),
+		APIKey: apiKey,
+	})
 
 	resp, err := common.SendRequest(req, upstream, envCfg, false, "Responses")
 	if err != nil {
@@ -278,7 +292,7 @@ func tryCompactWithKey(
 
 	// 判断是否需要故障转移
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		shouldFailover, _ := common.ShouldRetryWithNextKey(resp.StatusCode, respBody, cfgManager.GetFuzzyModeEnabled(), "Responses")
+		shouldFailover, _ := common.ShouldRetryWithNextKey(resp.StatusCode, respBody, cfgManager.GetFuzzyModeEnabled(), "Responses", upstream)
 		return false, &compactError{status: resp.StatusCode, body: respBody, shouldFailover: shouldFailover}
 	}
 
@@ -5,6 +5,7 @@
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -22,6 +23,7 @@
 	"github.com/BenedictKing/ccx/internal/types"
 	"github.com/BenedictKing/ccx/internal/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
 )
 
 // Handler Responses API 代理处理器
@@ -33,12 +35,22 @@ func Handler(
 	channelScheduler *scheduler.ChannelScheduler,
 ) gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
+		common.EnsureCorrelationID(c)
+
 		// 先进行认证
 		middleware.ProxyAuthMiddleware(envCfg)(c)
 		if c.IsAborted() {
 			return
 		}
 
+		// 请求级超时覆盖（X-CCX-Timeout-Ms，需开启 ENABLE_TIMEOUT_OVERRIDE_HEADER）
+		common.ApplyTimeoutOverride(c, envCfg)
+
+		// 全局限流（未配置 globalMaxRpm/globalMaxTpm 时不生效）
+		if !common.CheckGlobalRateLimit(c, channelScheduler, "Responses") {
+			return
+		}
+
 		startTime := time.Now()
 
 		// 读取原始请求体
@@ -57,14 +69,38 @@ func Handler(
 			_ = json.Unmarshal(bodyBytes, &responsesReq)
 		}
 
+		// 模型访问控制（allowedModels/deniedModels）
+		if !common.CheckModelAccess(c, cfgManager, string(scheduler.ChannelKindResponses), "Responses", responsesReq.Model) {
+			return
+		}
+
+		// 请求模型无渠道支持时替换为回退模型（FALLBACK_MODEL）
+		bodyBytes, responsesReq.Model = common.ApplyFallbackModel(c, envCfg, cfgManager, channelScheduler, scheduler.ChannelKindResponses, "Responses", bodyBytes, responsesReq.Model)
+
 		// 提取对话标识用于 Trace 亲和性
 		userID := common.ExtractConversationID(c, bodyBytes)
 
 		// 记录原始请求信息（仅在入口处记录一次）
 		common.LogOriginalRequest(c, bodyBytes, envCfg, "Responses")
 
-		// 检查是否为多渠道模式
-		isMultiChannel := channelScheduler.IsMultiChannelMode(scheduler.ChannelKindResponses)
+		// 单客户端并发流限制（未配置 MAX_STREAMS_PER_CLIENT 时不生效）
+		if responsesReq.Stream {
+			release, ok := common.AcquireClientStream(c, envCfg, "Responses")
+			if !ok {
+				return
+			}
+			defer release()
+
+			// 断线续传：携带续传令牌时回放缓冲事件，否则为本次流分配令牌（ENABLE_STREAM_RESUME）
+			if common.ResumeStream(c, envCfg, "Responses") {
+				return
+			}
+			finishResume := common.BeginResumableStream(c, envCfg, "Responses")
+			defer finishResume()
+		}
+
+		// 检查是否为多渠道模式（携带固定渠道请求头时统一走多渠道流程）
+		isMultiChannel := channelScheduler.IsMultiChannelMode(scheduler.ChannelKindResponses) || common.HasChannelPin(c, envCfg)
 
 		if isMultiChannel {
 			handleMultiChannel(c, envCfg, cfgManager, channelScheduler, sessionManager, bodyBytes, responsesReq, userID, startTime)
@@ -87,7 +123,6 @@ func handleMultiChannel(
 	startTime time.Time,
 ) {
 	provider := &providers.ResponsesProvider{SessionManager: sessionManager}
-	metricsManager := channelScheduler.GetResponsesMetricsManager()
 
 	common.HandleMultiChannelFailover(
 		c,
@@ -98,69 +133,88 @@ func handleMultiChannel(
 		userID,
 		responsesReq.Model,
 		func(selection *scheduler.SelectionResult) common.MultiChannelAttemptResult {
-			upstream := selection.Upstream
-			channelIndex := selection.ChannelIndex
-
-			if upstream == nil {
-				return common.MultiChannelAttemptResult{}
-			}
-
-			baseURLs := upstream.GetAllBaseURLs()
-			sortedURLResults := channelScheduler.GetSortedURLsForChannel(scheduler.ChannelKindResponses, channelIndex, baseURLs)
-
-			handled, successKey, successBaseURLIdx, failoverErr, usage, lastErr := common.TryUpstreamWithAllKeys(
-				c,
-				envCfg,
-				cfgManager,
-				channelScheduler,
-				scheduler.ChannelKindResponses,
-				"Responses",
-				metricsManager,
-				upstream,
-				sortedURLResults,
-				bodyBytes,
-				responsesReq.Stream,
-				func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error) {
-					return cfgManager.GetNextResponsesAPIKey(upstream, failedKeys)
-				},
-				func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
-					req, _, err := provider.ConvertToProviderRequest(c, upstreamCopy, apiKey)
-					return req, err
-				},
-				func(apiKey string) {
-					_ = cfgManager.DeprioritizeAPIKey(apiKey)
-				},
-				func(url string) {
-					channelScheduler.MarkURLFailure(scheduler.ChannelKindResponses, channelIndex, url)
-				},
-				func(url string) {
-					channelScheduler.MarkURLSuccess(scheduler.ChannelKindResponses, channelIndex, url)
-				},
-				func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, apiKey string) (*types.Usage, error) {
-					return handleSuccess(c, resp, provider, upstream.ServiceType, envCfg, sessionManager, startTime, &responsesReq, bodyBytes)
-				},
-				responsesReq.Model,
-				selection.ChannelIndex,
-				channelScheduler.GetChannelLogStore(scheduler.ChannelKindResponses),
-			)
-
-			return common.MultiChannelAttemptResult{
-				Handled:           handled,
-				Attempted:         true,
-				SuccessKey:        successKey,
-				SuccessBaseURLIdx: successBaseURLIdx,
-				FailoverError:     failoverErr,
-				Usage:             usage,
-				LastError:         lastErr,
+			return tryChannel(c, envCfg, cfgManager, channelScheduler, selection.Upstream, selection.ChannelIndex, sessionManager, provider, bodyBytes, responsesReq, startTime)
+		},
+		func(selection *scheduler.SelectionResult, result common.MultiChannelAttemptResult) {
+			if result.SuccessKey != "" {
+				replayToShadowChannel(c, envCfg, cfgManager, channelScheduler, provider, selection.ChannelIndex, bodyBytes, responsesReq)
 			}
 		},
-		nil,
 		func(ctx *gin.Context, failoverErr *common.FailoverError, lastError error) {
 			common.HandleAllChannelsFailed(ctx, cfgManager.GetFuzzyModeEnabled(), failoverErr, lastError, "Responses")
 		},
 	)
 }
 
+// tryChannel 在指定渠道内按 Key/BaseURL 轮转尝试请求（多渠道调度与死信重放共用）
+func tryChannel(
+	c *gin.Context,
+	envCfg *config.EnvConfig,
+	cfgManager *config.ConfigManager,
+	channelScheduler *scheduler.ChannelScheduler,
+	upstream *config.UpstreamConfig,
+	channelIndex int,
+	sessionManager *session.SessionManager,
+	provider *providers.ResponsesProvider,
+	bodyBytes []byte,
+	responsesReq types.ResponsesRequest,
+	startTime time.Time,
+) common.MultiChannelAttemptResult {
+	if upstream == nil {
+		return common.MultiChannelAttemptResult{}
+	}
+
+	metricsManager := channelScheduler.GetResponsesMetricsManager()
+	baseURLs := upstream.GetAllBaseURLs()
+	sortedURLResults := channelScheduler.GetSortedURLsForChannel(scheduler.ChannelKindResponses, channelIndex, baseURLs)
+
+	handled, successKey, successBaseURLIdx, failoverErr, usage, lastErr := common.TryUpstreamWithAllKeys(
+		c,
+		envCfg,
+		cfgManager,
+		channelScheduler,
+		scheduler.ChannelKindResponses,
+		"Responses",
+		metricsManager,
+		upstream,
+		sortedURLResults,
+		bodyBytes,
+		responsesReq.Stream,
+		func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error) {
+			return cfgManager.GetNextResponsesAPIKey(upstream, failedKeys)
+		},
+		func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
+			req, _, err := provider.ConvertToProviderRequest(c, upstreamCopy, apiKey)
+			return req, err
+		},
+		func(apiKey string) {
+			_ = cfgManager.DeprioritizeAPIKey(apiKey)
+		},
+		func(url string) {
+			channelScheduler.MarkURLFailure(scheduler.ChannelKindResponses, channelIndex, url)
+		},
+		func(url string) {
+			channelScheduler.MarkURLSuccess(scheduler.ChannelKindResponses, channelIndex, url)
+		},
+		func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, apiKey string) (*types.Usage, error) {
+			return handleSuccess(c, resp, provider, upstream.ServiceType, envCfg, sessionManager, startTime, &responsesReq, bodyBytes)
+		},
+		responsesReq.Model,
+		channelIndex,
+		channelScheduler.GetChannelLogStore(scheduler.ChannelKindResponses),
+	)
+
+	return common.MultiChannelAttemptResult{
+		Handled:           handled,
+		Attempted:         true,
+		SuccessKey:        successKey,
+		SuccessBaseURLIdx: successBaseURLIdx,
+		FailoverError:     failoverErr,
+		Usage:             usage,
+		LastError:         lastErr,
+	}
+}
+
 // handleSingleChannel 处理单渠道 Responses 请求
 func handleSingleChannel(
 	c *gin.Context,
@@ -196,7 +250,7 @@ func handleSingleChannel(
 
 	urlResults := common.BuildDefaultURLResults(baseURLs)
 
-	handled, _, _, lastFailoverError, _, lastError := common.TryUpstreamWithAllKeys(
+	handled, successKey, _, lastFailoverError, _, lastError := common.TryUpstreamWithAllKeys(
 		c,
 		envCfg,
 		cfgManager,
@@ -230,6 +284,9 @@ func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, a
 		channelScheduler.GetChannelLogStore(scheduler.ChannelKindResponses),
 	)
 	if handled {
+		if successKey != "" {
+			replayToShadowChannel(c, envCfg, cfgManager, channelScheduler, provider, channelIndex, bodyBytes, responsesReq)
+		}
 		return
 	}
 
@@ -237,6 +294,42 @@ func(c *gin.Context, resp *http.Response, upstreamCopy *config.UpstreamConfig, a
 	common.HandleAllKeysFailed(c, cfgManager.GetFuzzyModeEnabled(), lastFailoverError, lastError, "Responses")
 }
 
+// replayToShadowChannel 主渠道成功后将非流式请求异步镜像到影子渠道（流式请求不镜像）
+func replayToShadowChannel(
+	c *gin.Context,
+	envCfg *config.EnvConfig,
+	cfgManager *config.ConfigManager,
+	channelScheduler *scheduler.ChannelScheduler,
+	provider *providers.ResponsesProvider,
+	channelIndex int,
+	bodyBytes []byte,
+	responsesReq types.ResponsesRequest,
+) {
+	if responsesReq.Stream {
+		return
+	}
+
+	common.ReplayToShadowChannel(
+		c,
+		envCfg,
+		cfgManager,
+		channelScheduler,
+		scheduler.ChannelKindResponses,
+		"Responses",
+		channelScheduler.GetResponsesMetricsManager(),
+		channelIndex,
+		bodyBytes,
+		responsesReq.Model,
+		func(upstream *config.UpstreamConfig, failedKeys map[string]bool) (string, error) {
+			return cfgManager.GetNextResponsesAPIKey(upstream, failedKeys)
+		},
+		func(c *gin.Context, upstreamCopy *config.UpstreamConfig, apiKey string) (*http.Request, error) {
+			req, _, err := provider.ConvertToProviderRequest(c, upstreamCopy, apiKey)
+			return req, err
+		},
+	)
+}
+
 // handleSuccess 处理成功的 Responses 响应
 func handleSuccess(
 	c *gin.Context,
@@ -498,7 +591,11 @@ func handleStreamSuccess(
 	needConvert := upstreamType != "responses"
 	var converterState any
 
-	scanner := bufio.NewScanner(resp.Body)
+	// 慢客户端背压保护（STREAM_BACKPRESSURE_TIMEOUT > 0 时启用）
+	body, stopBackpressure := common.WrapStreamBackpressure(c.Writer, resp.Body, envCfg)
+	defer stopBackpressure()
+
+	scanner := bufio.NewScanner(body)
 	const maxCapacity = 1024 * 1024
 	buf := make([]byte, 0, 64*1024)
 	scanner.Buffer(buf, maxCapacity)
@@ -545,6 +642,14 @@ type scanLine struct {
 			line := sl.text
 			bufferedLines = append(bufferedLines, line)
 
+			// 上游已是 Responses 事件格式（如 openai 类型渠道实际指向 /v1/responses）：改为透传
+			if needConvert && converterState == nil && isResponsesFramedLine(line) {
+				needConvert = false
+				if envCfg.ShouldLog("info") {
+					log.Printf("[Responses-Stream] 上游 (%s) 返回 Responses 事件格式，跳过 Chat → Responses 转换", upstreamType)
+				}
+			}
+
 			// 处理转换后的事件用于文本提取
 			var eventsToCheck []string
 			if needConvert {
@@ -629,6 +734,7 @@ type scanLine struct {
 	hasUsage := false
 	needTokenPatch := false
 	clientGone := false
+	completedSent := false
 
 	// processLine 处理单行数据（复用于缓冲行回放和后续读取）
 	processLine := func(line string) {
@@ -692,6 +798,7 @@ type scanLine struct {
 			// 在 response.completed 事件前注入/修补 usage
 			eventToSend := event
 			if isResponsesCompletedEvent(event) {
+				completedSent = true
 				if !hasUsage {
 					// 上游完全没有 usage，注入本地估算
 					var injectedInput, injectedOutput int
@@ -732,14 +839,33 @@ type scanLine struct {
 	}
 
 	// 继续从 lineChan 读取剩余的流数据
-	for sl := range lineChan {
+	// 客户端取消时立即停止：上游请求使用客户端 context，取消后读取随之中断，不再消耗上游额度
+	for {
+		var sl scanLine
+		select {
+		case <-c.Request.Context().Done():
+			close(scanDone)
+			if envCfg.ShouldLog("info") {
+				log.Printf("[Responses-Stream] 客户端已取消请求，停止读取上游流")
+			}
+			return nil, c.Request.Context().Err()
+		case sl = <-lineChan:
+		}
 		if !sl.ok {
 			break
 		}
 		processLine(sl.text)
 	}
 
-	if err := scanner.Err(); err != nil {
+	// Chat 上游未发送 [DONE] 就结束流时补发，确保客户端收到携带最终 usage 的 response.completed
+	if needConvert && upstreamType != "gemini" && converterState != nil && !completedSent {
+		processLine("data: [DONE]")
+	}
+
+	if err := scanner.Err(); errors.Is(err, common.ErrSlowClient) {
+		log.Printf("[Responses-Stream] 警告: 客户端消费过慢，读取缓冲区持续满载，已中止请求以释放上游连接")
+		return nil, err
+	} else if err != nil {
 		log.Printf("[Responses-Stream] 警告: 流式响应读取错误: %v", err)
 	}
 
@@ -1010,6 +1136,17 @@ func isResponsesCompletedEvent(event string) bool {
 		strings.Contains(event, `"type": "response.completed"`)
 }
 
+// isResponsesFramedLine 判断 SSE 行是否为 Responses 事件格式（event: response.* 或 data 中 type 为 response.*）
+func isResponsesFramedLine(line string) bool {
+	if name, ok := strings.CutPrefix(line, "event:"); ok {
+		return strings.HasPrefix(strings.TrimSpace(name), "response.")
+	}
+	if data, ok := strings.CutPrefix(line, "data:"); ok {
+		return strings.HasPrefix(gjson.Get(data, "type").String(), "response.")
+	}
+	return false
+}
+
 // isClientDisconnectError 判断是否为客户端断开连接错误
 func isClientDisconnectError(err error) bool {
 	msg := err.Error()
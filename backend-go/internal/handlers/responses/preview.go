@@ -0,0 +1,32 @@
+package responses
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
+	"github.com/BenedictKing/ccx/internal/providers"
+	"github.com/BenedictKing/ccx/internal/session"
+	"github.com/gin-gonic/gin"
+)
+
+// previewSessionManager 请求预览专用的会话管理器，与真实会话隔离，避免预览写入对话历史
+// 因此预览时 previous_response_id 不会展开为历史消息
+var previewSessionManager = sync.OnceValue(func() *session.SessionManager {
+	return session.NewSessionManager(10*time.Minute, 100, 100000)
+})
+
+// BuildPreviewRequest 按真实请求路径的预处理与 provider 转换构建上游请求（不发送），用于请求预览
+func BuildPreviewRequest(c *gin.Context, upstream *config.UpstreamConfig, apiKey string, bodyBytes []byte) (*http.Request, error) {
+	bodyBytes = common.NormalizeMetadataUserID(bodyBytes)
+
+	previewCtx, err := common.NewPreviewContext(c, "/v1/responses", bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	provider := &providers.ResponsesProvider{SessionManager: previewSessionManager()}
+	req, _, err := provider.ConvertToProviderRequest(previewCtx, upstream, apiKey)
+	return req, err
+}
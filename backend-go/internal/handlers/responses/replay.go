@@ -0,0 +1,34 @@
+package responses
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/handlers/common"
+	"github.com/BenedictKing/ccx/internal/providers"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/BenedictKing/ccx/internal/session"
+	"github.com/BenedictKing/ccx/internal/types"
+	"github.com/gin-gonic/gin"
+)
+
+// ReplayToChannel 将死信中保存的请求重放到指定渠道，仅在该渠道内的 Key/BaseURL 之间 failover
+// 使用真实会话管理器，previous_response_id 与重放产生的响应都会进入对话历史
+func ReplayToChannel(c *gin.Context, envCfg *config.EnvConfig, cfgManager *config.ConfigManager, sessionManager *session.SessionManager, channelScheduler *scheduler.ChannelScheduler, upstream *config.UpstreamConfig, channelIndex int, bodyBytes []byte) (succeeded bool, err error) {
+	var responsesReq types.ResponsesRequest
+	if err := json.Unmarshal(bodyBytes, &responsesReq); err != nil {
+		return false, fmt.Errorf("invalid request body: %w", err)
+	}
+	if err := common.PrepareReplayRequest(c, "/v1/responses", bodyBytes); err != nil {
+		return false, err
+	}
+
+	provider := &providers.ResponsesProvider{SessionManager: sessionManager}
+	result := tryChannel(c, envCfg, cfgManager, channelScheduler, upstream, channelIndex, sessionManager, provider, bodyBytes, responsesReq, time.Now())
+	if !result.Handled {
+		common.HandleAllKeysFailed(c, cfgManager.GetFuzzyModeEnabled(), result.FailoverError, result.LastError, "Responses")
+	}
+	return result.SuccessKey != "", nil
+}
@@ -0,0 +1,111 @@
+package responses
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/types"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// runStreamSuccess 以给定上游类型和 SSE 内容调用 handleStreamSuccess，返回客户端收到的事件
+func runStreamSuccess(t *testing.T, upstreamType, upstreamBody string) (events []gjson.Result, eventNames []string, usage *types.Usage) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+
+	originalJSON := []byte(`{"model":"gpt-4o","input":"Hi","stream":true}`)
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(upstreamBody))}
+	usage, err := handleStreamSuccess(c, resp, upstreamType, &config.EnvConfig{}, time.Now(), &types.ResponsesRequest{Model: "gpt-4o", Stream: true}, originalJSON)
+	if err != nil {
+		t.Fatalf("handleStreamSuccess() err = %v", err)
+	}
+
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			eventNames = append(eventNames, name)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			events = append(events, gjson.Parse(data))
+		}
+	}
+	return events, eventNames, usage
+}
+
+// TestHandleStreamSuccess_TranslatesChatStream Chat 上游的流式 chunk 转换为 Responses 命名事件，response.completed 携带最终 usage
+func TestHandleStreamSuccess_TranslatesChatStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// usage 在流末尾单独发送（不带 choices），且上游未发送 [DONE]
+	chunks := []string{
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"},"finish_reason":null}],"usage":null}`,
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":" world"},"finish_reason":null}],"usage":null}`,
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":null}`,
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","usage":{"prompt_tokens":12,"completion_tokens":4,"total_tokens":16}}`,
+	}
+	var upstreamBody strings.Builder
+	for _, chunk := range chunks {
+		upstreamBody.WriteString("data: " + chunk + "\n\n")
+	}
+
+	events, eventNames, usage := runStreamSuccess(t, "openai", upstreamBody.String())
+
+	if len(eventNames) == 0 || eventNames[0] != "response.created" || eventNames[len(eventNames)-1] != "response.completed" {
+		t.Fatalf("event names = %v, want response.created ... response.completed", eventNames)
+	}
+	var text string
+	for _, event := range events {
+		if event.Get("type").String() == "response.output_text.delta" {
+			text += event.Get("delta").String()
+		}
+	}
+	if text != "Hello world" {
+		t.Fatalf("output_text = %q, want %q", text, "Hello world")
+	}
+
+	completed := events[len(events)-1]
+	if completed.Get("type").String() != "response.completed" {
+		t.Fatalf("last event = %s, want response.completed", completed.Raw)
+	}
+	if completed.Get("response.usage.input_tokens").Int() != 12 || completed.Get("response.usage.output_tokens").Int() != 4 {
+		t.Fatalf("completed usage = %s, want input=12 output=4", completed.Get("response.usage").Raw)
+	}
+	if completed.Get("response.output.0.content.0.text").String() != "Hello world" {
+		t.Fatalf("completed output = %s", completed.Get("response.output").Raw)
+	}
+	if usage == nil || usage.InputTokens != 12 || usage.OutputTokens != 4 {
+		t.Fatalf("usage = %+v, want input=12 output=4", usage)
+	}
+}
+
+// TestHandleStreamSuccess_PassesThroughResponsesFraming 非 responses 类型渠道返回 Responses 事件时原样透传
+func TestHandleStreamSuccess_PassesThroughResponsesFraming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstreamBody := "event: response.created\n" +
+		`data: {"type":"response.created","response":{"id":"resp_1","status":"in_progress"}}` + "\n\n" +
+		"event: response.output_text.delta\n" +
+		`data: {"type":"response.output_text.delta","delta":"Hi"}` + "\n\n" +
+		"event: response.completed\n" +
+		`data: {"type":"response.completed","response":{"id":"resp_1","status":"completed","usage":{"input_tokens":3,"output_tokens":1,"total_tokens":4}}}` + "\n\n"
+
+	events, eventNames, usage := runStreamSuccess(t, "openai", upstreamBody)
+
+	wantNames := []string{"response.created", "response.output_text.delta", "response.completed"}
+	if strings.Join(eventNames, ",") != strings.Join(wantNames, ",") {
+		t.Fatalf("event names = %v, want %v", eventNames, wantNames)
+	}
+	if events[0].Get("response.id").String() != "resp_1" {
+		t.Fatalf("response.created = %s, want upstream id passed through", events[0].Raw)
+	}
+	if usage == nil || usage.InputTokens != 3 || usage.OutputTokens != 1 {
+		t.Fatalf("usage = %+v, want input=3 output=1", usage)
+	}
+}
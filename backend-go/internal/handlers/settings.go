@@ -69,3 +69,181 @@ func SetStripBillingHeader(cfgManager *config.ConfigManager) gin.HandlerFunc {
 		})
 	}
 }
+
+// GetShadowChannels 获取各接口类型的影子渠道配置
+func GetShadowChannels(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"shadowChannels": cfgManager.GetShadowChannels(),
+		})
+	}
+}
+
+// SetShadowChannel 设置指定接口类型的影子渠道（index < 0 表示清除）
+func SetShadowChannel(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Kind  string `json:"kind"`  // messages, responses, gemini, chat
+			Index int    `json:"index"` // 影子渠道索引，-1 表示清除
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		if err := cfgManager.SetShadowChannel(req.Kind, req.Index); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success":        true,
+			"shadowChannels": cfgManager.GetShadowChannels(),
+		})
+	}
+}
+
+// GetGlobalRateLimit 获取全局 RPM/TPM 限流配置
+func GetGlobalRateLimit(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxRPM, maxTPM := cfgManager.GetGlobalRateLimit()
+		c.JSON(200, gin.H{
+			"globalMaxRpm": maxRPM,
+			"globalMaxTpm": maxTPM,
+		})
+	}
+}
+
+// SetGlobalRateLimit 设置全局 RPM/TPM 限流配置（0 表示不限制）
+func SetGlobalRateLimit(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			GlobalMaxRPM int `json:"globalMaxRpm"`
+			GlobalMaxTPM int `json:"globalMaxTpm"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if req.GlobalMaxRPM < 0 || req.GlobalMaxTPM < 0 {
+			c.JSON(400, gin.H{"error": "globalMaxRpm and globalMaxTpm must be non-negative"})
+			return
+		}
+
+		if err := cfgManager.SetGlobalRateLimit(req.GlobalMaxRPM, req.GlobalMaxTPM); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to save config"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success":      true,
+			"globalMaxRpm": req.GlobalMaxRPM,
+			"globalMaxTpm": req.GlobalMaxTPM,
+		})
+	}
+}
+
+// GetAffinityMode 获取 Trace 亲和模式
+func GetAffinityMode(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"affinityMode": cfgManager.GetAffinityMode(),
+		})
+	}
+}
+
+// SetAffinityMode 设置 Trace 亲和模式（default / sticky）
+func SetAffinityMode(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			AffinityMode string `json:"affinityMode"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if req.AffinityMode != config.AffinityModeDefault && req.AffinityMode != config.AffinityModeSticky {
+			c.JSON(400, gin.H{"error": "affinityMode must be 'default' or 'sticky'"})
+			return
+		}
+
+		if err := cfgManager.SetAffinityMode(req.AffinityMode); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to save config"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success":      true,
+			"affinityMode": req.AffinityMode,
+		})
+	}
+}
+
+// GetSchedulingMode 获取渠道调度模式
+func GetSchedulingMode(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"schedulingMode": cfgManager.GetSchedulingMode(),
+		})
+	}
+}
+
+// SetSchedulingMode 设置渠道调度模式（priority / least_loaded）
+func SetSchedulingMode(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			SchedulingMode string `json:"schedulingMode"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if req.SchedulingMode != config.SchedulingModePriority && req.SchedulingMode != config.SchedulingModeLeastLoaded {
+			c.JSON(400, gin.H{"error": "schedulingMode must be 'priority' or 'least_loaded'"})
+			return
+		}
+
+		if err := cfgManager.SetSchedulingMode(req.SchedulingMode); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to save config"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success":        true,
+			"schedulingMode": req.SchedulingMode,
+		})
+	}
+}
+
+// GetModelAccess 获取各接口类型的模型允许/拒绝列表
+func GetModelAccess(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"modelAccess": cfgManager.GetModelAccess(),
+		})
+	}
+}
+
+// SetModelAccess 设置指定接口类型的模型允许/拒绝列表（空列表表示清除）
+func SetModelAccess(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Kind string `json:"kind"` // messages, responses, gemini, chat
+			config.ModelAccessList
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		if err := cfgManager.SetModelAccess(req.Kind, req.ModelAccessList); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success":     true,
+			"modelAccess": cfgManager.GetModelAccess(),
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// GetTopKeysByTokens 按 token 用量列出消耗最高的 Key（定位成本来源）
+// GET /api/keys/top-tokens?kind=messages|responses|gemini|chat&duration=1h|6h|24h|today&limit=10（kind 未指定时返回全部类型）
+func GetTopKeysByTokens(sch *scheduler.ChannelScheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kinds, ok := parseMetricsKinds(c)
+		if !ok {
+			return
+		}
+
+		durationStr := c.DefaultQuery("duration", "24h")
+		var duration time.Duration
+		if durationStr == "today" {
+			duration = metrics.CalculateTodayDuration()
+		} else {
+			var err error
+			duration, err = time.ParseDuration(durationStr)
+			if err != nil || duration <= 0 {
+				c.JSON(400, gin.H{"error": "Invalid duration parameter. Use: 1h, 6h, 24h, or today"})
+				return
+			}
+		}
+		if duration > 24*time.Hour {
+			duration = 24 * time.Hour
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil || limit <= 0 {
+			c.JSON(400, gin.H{"error": "Invalid limit parameter"})
+			return
+		}
+
+		results := make(map[string][]scheduler.TopKeyUsage, len(kinds))
+		for _, kind := range kinds {
+			results[string(kind)] = sch.GetTopKeysByTokens(kind, duration, limit)
+		}
+
+		c.JSON(200, gin.H{
+			"duration": durationStr,
+			"limit":    limit,
+			"keys":     results,
+		})
+	}
+}
@@ -21,10 +21,20 @@
 type ClientManager struct {
 	mu      sync.RWMutex
 	clients map[string]*http.Client
+
+	tlsMu      sync.RWMutex
+	tlsConfigs map[string]*tls.Config // 按 TLSCacheKey 缓存已构建的渠道 TLS 配置
+	tlsFailed  map[string]bool        // 加载失败的 TLSCacheKey（仅首次失败记录日志）
 }
 
-var globalManager = &ClientManager{
-	clients: make(map[string]*http.Client),
+var globalManager = newClientManager()
+
+func newClientManager() *ClientManager {
+	return &ClientManager{
+		clients:    make(map[string]*http.Client),
+		tlsConfigs: make(map[string]*tls.Config),
+		tlsFailed:  make(map[string]bool),
+	}
 }
 
 // GetManager 获取全局客户端管理器
@@ -39,14 +49,21 @@ func (cm *ClientManager) GetStandardClient(timeout time.Duration, insecure bool,
 	// 从配置获取响应头超时时间
 	envConfig := config.NewEnvConfig()
 	responseHeaderTimeout := time.Duration(envConfig.ResponseHeaderTimeout) * time.Second
+	return cm.GetStandardClientWithHeaderTimeout(timeout, responseHeaderTimeout, insecure, proxyURL...)
+}
 
-	// 提取代理 URL
-	proxyAddr := ""
-	if len(proxyURL) > 0 {
-		proxyAddr = proxyURL[0]
-	}
+// GetStandardClientWithHeaderTimeout 获取指定响应头超时的标准客户端（用于渠道级超时覆盖）
+func (cm *ClientManager) GetStandardClientWithHeaderTimeout(timeout, responseHeaderTimeout time.Duration, insecure bool, proxyURL ...string) *http.Client {
+	return cm.getStandardClient(timeout, responseHeaderTimeout, insecureTLSProfile(insecure), firstProxy(proxyURL))
+}
 
-	key := fmt.Sprintf("standard-%d-%t-%d-%s", timeout, insecure, envConfig.ResponseHeaderTimeout, proxyAddr)
+// GetStandardClientForUpstream 获取应用渠道 TLS 配置（自定义 CA / 客户端证书 / SNI）的标准客户端
+func (cm *ClientManager) GetStandardClientForUpstream(timeout, responseHeaderTimeout time.Duration, upstream *config.UpstreamConfig, proxyURL ...string) *http.Client {
+	return cm.getStandardClient(timeout, responseHeaderTimeout, cm.upstreamTLSProfile(upstream), firstProxy(proxyURL))
+}
+
+func (cm *ClientManager) getStandardClient(timeout, responseHeaderTimeout time.Duration, profile tlsProfile, proxyAddr string) *http.Client {
+	key := fmt.Sprintf("standard-%d-%s-%d-%s", timeout, profile.key, responseHeaderTimeout, proxyAddr)
 
 	cm.mu.RLock()
 	if client, ok := cm.clients[key]; ok {
@@ -74,11 +91,13 @@ func (cm *ClientManager) GetStandardClient(timeout time.Duration, insecure bool,
 		ForceAttemptHTTP2:     true,
 	}
 
-	if insecure {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-		envCfg := config.NewEnvConfig()
-		if envCfg.IsProduction() {
-			log.Printf("[HttpClient-Warn] 生产环境启用了 insecureSkipVerify，存在中间人攻击风险")
+	if profile.config != nil {
+		transport.TLSClientConfig = profile.config
+		if profile.config.InsecureSkipVerify {
+			envCfg := config.NewEnvConfig()
+			if envCfg.IsProduction() {
+				log.Printf("[HttpClient-Warn] 生产环境启用了 insecureSkipVerify，存在中间人攻击风险")
+			}
 		}
 	}
 
@@ -99,14 +118,22 @@ func (cm *ClientManager) GetStreamClient(insecure bool, proxyURL ...string) *htt
 	// 从配置获取响应头超时时间
 	envConfig := config.NewEnvConfig()
 	responseHeaderTimeout := time.Duration(envConfig.ResponseHeaderTimeout) * time.Second
+	return cm.GetStreamClientWithHeaderTimeout(responseHeaderTimeout, insecure, proxyURL...)
+}
 
-	// 提取代理 URL
-	proxyAddr := ""
-	if len(proxyURL) > 0 {
-		proxyAddr = proxyURL[0]
-	}
+// GetStreamClientWithHeaderTimeout 获取指定响应头超时的流式客户端（用于渠道级超时覆盖）
+// 流式客户端本身无总超时，空闲超时由调用方按读取间隔控制
+func (cm *ClientManager) GetStreamClientWithHeaderTimeout(responseHeaderTimeout time.Duration, insecure bool, proxyURL ...string) *http.Client {
+	return cm.getStreamClient(responseHeaderTimeout, insecureTLSProfile(insecure), firstProxy(proxyURL))
+}
+
+// GetStreamClientForUpstream 获取应用渠道 TLS 配置（自定义 CA / 客户端证书 / SNI）的流式客户端
+func (cm *ClientManager) GetStreamClientForUpstream(responseHeaderTimeout time.Duration, upstream *config.UpstreamConfig, proxyURL ...string) *http.Client {
+	return cm.getStreamClient(responseHeaderTimeout, cm.upstreamTLSProfile(upstream), firstProxy(proxyURL))
+}
 
-	key := fmt.Sprintf("stream-%t-%d-%s", insecure, envConfig.ResponseHeaderTimeout, proxyAddr)
+func (cm *ClientManager) getStreamClient(responseHeaderTimeout time.Duration, profile tlsProfile, proxyAddr string) *http.Client {
+	key := fmt.Sprintf("stream-%s-%d-%s", profile.key, responseHeaderTimeout, proxyAddr)
 
 	cm.mu.RLock()
 	if client, ok := cm.clients[key]; ok {
@@ -134,8 +161,8 @@ func (cm *ClientManager) GetStreamClient(insecure bool, proxyURL ...string) *htt
 		ForceAttemptHTTP2:     true,
 	}
 
-	if insecure {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	if profile.config != nil {
+		transport.TLSClientConfig = profile.config
 	}
 
 	applyProxy(transport, proxyAddr)
@@ -149,6 +176,75 @@ func (cm *ClientManager) GetStreamClient(insecure bool, proxyURL ...string) *htt
 	return client
 }
 
+// tlsProfile 客户端 TLS 配置及其缓存键（相同 TLS 配置的渠道共享 transport）
+type tlsProfile struct {
+	key    string
+	config *tls.Config
+}
+
+// insecureTLSProfile 仅包含 insecureSkipVerify 的 TLS 配置
+func insecureTLSProfile(insecure bool) tlsProfile {
+	if !insecure {
+		return tlsProfile{key: "false"}
+	}
+	return tlsProfile{key: "true", config: &tls.Config{InsecureSkipVerify: true}}
+}
+
+// upstreamTLSProfile 按渠道配置获取 TLS 配置
+// 构建结果按 TLSCacheKey 缓存，避免每个请求重复读取证书文件；
+// 证书加载失败时（配置加载时已校验，通常是文件被移除）不缓存，记录警告并回退到保留 serverName 与 insecureSkipVerify 的配置
+func (cm *ClientManager) upstreamTLSProfile(upstream *config.UpstreamConfig) tlsProfile {
+	if upstream == nil {
+		return insecureTLSProfile(false)
+	}
+	if !upstream.HasCustomTLS() {
+		return insecureTLSProfile(upstream.InsecureSkipVerify)
+	}
+
+	key := upstream.TLSCacheKey()
+	cm.tlsMu.RLock()
+	tlsConfig, ok := cm.tlsConfigs[key]
+	cm.tlsMu.RUnlock()
+	if ok {
+		return tlsProfile{key: key, config: tlsConfig}
+	}
+
+	tlsConfig, err := upstream.BuildTLSConfig()
+
+	cm.tlsMu.Lock()
+	defer cm.tlsMu.Unlock()
+	if err != nil {
+		if !cm.tlsFailed[key] {
+			cm.tlsFailed[key] = true
+			log.Printf("[HttpClient-TLS] 警告: 渠道 %s 的 TLS 证书加载失败，回退到仅 serverName/insecureSkipVerify 的配置: %v", upstream.Name, err)
+		}
+		return tlsProfile{
+			key: "fallback|" + key,
+			config: &tls.Config{
+				InsecureSkipVerify: upstream.InsecureSkipVerify,
+				ServerName:         upstream.ServerName,
+			},
+		}
+	}
+	if cached, ok := cm.tlsConfigs[key]; ok {
+		return tlsProfile{key: key, config: cached}
+	}
+	if cm.tlsFailed[key] {
+		delete(cm.tlsFailed, key)
+		log.Printf("[HttpClient-TLS] 渠道 %s 的 TLS 证书已恢复加载", upstream.Name)
+	}
+	cm.tlsConfigs[key] = tlsConfig
+	return tlsProfile{key: key, config: tlsConfig}
+}
+
+// firstProxy 提取可选的代理 URL
+func firstProxy(proxyURL []string) string {
+	if len(proxyURL) > 0 {
+		return proxyURL[0]
+	}
+	return ""
+}
+
 // applyProxy 为 transport 配置代理
 // 支持 http://, https://, socks5:// 协议
 func applyProxy(transport *http.Transport, proxyAddr string) {
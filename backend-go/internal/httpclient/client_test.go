@@ -0,0 +1,96 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+)
+
+// TestGetStandardClientForUpstream_CustomCA 渠道配置的自定义 CA 与 SNI 被应用到 transport
+func TestGetStandardClientForUpstream_CustomCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caPath, caPEM, 0600); err != nil {
+		t.Fatalf("写入 CA 文件失败: %v", err)
+	}
+
+	manager := newClientManager()
+	upstream := &config.UpstreamConfig{Name: "self-hosted", CACertPath: caPath, ServerName: "example.com"}
+
+	client := manager.GetStandardClientForUpstream(5*time.Second, 5*time.Second, upstream)
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok || transport.TLSClientConfig == nil {
+		t.Fatal("transport 未应用渠道 TLS 配置")
+	}
+	if transport.TLSClientConfig.RootCAs == nil || transport.TLSClientConfig.ServerName != "example.com" {
+		t.Fatalf("TLS 配置不正确: RootCAs=%v ServerName=%q", transport.TLSClientConfig.RootCAs, transport.TLSClientConfig.ServerName)
+	}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("使用自定义 CA 请求失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("status=%d, want 204", resp.StatusCode)
+	}
+
+	// 未配置 CA 的客户端无法校验自签名证书
+	if resp, err := manager.GetStandardClientWithHeaderTimeout(5*time.Second, 5*time.Second, false).Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("未配置自定义 CA 时请求应失败")
+	}
+
+	// 相同 TLS 配置复用同一客户端
+	sameConfig := &config.UpstreamConfig{Name: "another", CACertPath: caPath, ServerName: "example.com"}
+	if manager.GetStandardClientForUpstream(5*time.Second, 5*time.Second, sameConfig) != client {
+		t.Fatal("相同 TLS 配置应复用缓存的客户端")
+	}
+}
+
+// TestUpstreamTLSProfile_CachesAndFallback TLS 配置按缓存键只构建一次；证书加载失败时保留 serverName 且不缓存
+func TestUpstreamTLSProfile_CachesAndFallback(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caPath, caPEM, 0600); err != nil {
+		t.Fatalf("写入 CA 文件失败: %v", err)
+	}
+
+	manager := newClientManager()
+	upstream := &config.UpstreamConfig{Name: "self-hosted", CACertPath: caPath, ServerName: "example.com"}
+
+	first := manager.upstreamTLSProfile(upstream)
+	if err := os.Remove(caPath); err != nil {
+		t.Fatalf("删除 CA 文件失败: %v", err)
+	}
+	// 已缓存：证书文件被移除后仍复用已构建的配置
+	if second := manager.upstreamTLSProfile(upstream); second.config != first.config {
+		t.Fatal("相同 TLS 缓存键应复用已构建的 tls.Config")
+	}
+
+	missing := &config.UpstreamConfig{Name: "missing", CACertPath: caPath + ".missing", ServerName: "sni.example.com", InsecureSkipVerify: true}
+	fallback := manager.upstreamTLSProfile(missing)
+	if fallback.config == nil || fallback.config.ServerName != "sni.example.com" || !fallback.config.InsecureSkipVerify {
+		t.Fatalf("回退配置应保留 serverName 与 insecureSkipVerify: %+v", fallback.config)
+	}
+	if fallback.key == missing.TLSCacheKey() {
+		t.Fatal("回退配置不应与正常配置共享缓存键")
+	}
+	if _, ok := manager.tlsConfigs[missing.TLSCacheKey()]; ok {
+		t.Fatal("加载失败的 TLS 配置不应被缓存")
+	}
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+)
+
+// AdaptiveThresholdConfig 自适应熔断阈值曲线：按 Key 最近 1 分钟的请求速率（RPM）在宽松与严格阈值之间线性插值
+//   - RPM <= LowRPM：使用 LenientThreshold（低流量时避免因个别失败过度反应）
+//   - RPM >= HighRPM：使用 StrictThreshold（高流量时更快熔断，减少失败扩散）
+type AdaptiveThresholdConfig struct {
+	LowRPM           float64
+	HighRPM          float64
+	LenientThreshold float64
+	StrictThreshold  float64
+}
+
+// Validate 校验曲线参数
+func (c AdaptiveThresholdConfig) Validate() error {
+	if c.LowRPM < 0 || c.HighRPM <= c.LowRPM {
+		return fmt.Errorf("RPM 区间无效: low=%v high=%v（要求 0 <= low < high）", c.LowRPM, c.HighRPM)
+	}
+	if c.LenientThreshold <= 0 || c.LenientThreshold > 1 || c.StrictThreshold <= 0 || c.StrictThreshold > 1 {
+		return fmt.Errorf("阈值必须在 (0, 1] 之间: lenient=%v strict=%v", c.LenientThreshold, c.StrictThreshold)
+	}
+	if c.StrictThreshold > c.LenientThreshold {
+		return fmt.Errorf("严格阈值不能大于宽松阈值: lenient=%v strict=%v", c.LenientThreshold, c.StrictThreshold)
+	}
+	return nil
+}
+
+// ThresholdAt 返回指定 RPM 下的有效失败率阈值
+func (c AdaptiveThresholdConfig) ThresholdAt(rpm float64) float64 {
+	switch {
+	case rpm <= c.LowRPM:
+		return c.LenientThreshold
+	case rpm >= c.HighRPM:
+		return c.StrictThreshold
+	default:
+		ratio := (rpm - c.LowRPM) / (c.HighRPM - c.LowRPM)
+		return c.LenientThreshold - ratio*(c.LenientThreshold-c.StrictThreshold)
+	}
+}
+
+// SetAdaptiveThreshold 启用自适应熔断阈值（nil 表示关闭，使用固定 failureThreshold）
+// 渠道级熔断覆盖（circuitFailureThreshold）优先于自适应阈值
+func (m *MetricsManager) SetAdaptiveThreshold(cfg *AdaptiveThresholdConfig) error {
+	if cfg != nil {
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.adaptiveThreshold = cfg
+	return nil
+}
+
+// keyFailureThreshold 返回 Key 当前的有效熔断失败率阈值（调用方需持有锁）
+func (m *MetricsManager) keyFailureThreshold(metrics *KeyMetrics) float64 {
+	if threshold := m.circuitOverrides[metrics.BaseURL].FailureThreshold; threshold > 0 {
+		return threshold
+	}
+	if m.adaptiveThreshold != nil {
+		return m.adaptiveThreshold.ThresholdAt(keyRecentRPM(metrics, time.Now()))
+	}
+	return m.failureThreshold
+}
+
+// keyRecentRPM 统计 Key 最近 1 分钟的请求数（requestHistory 按时间追加，从尾部向前扫描）
+func keyRecentRPM(metrics *KeyMetrics, now time.Time) float64 {
+	cutoff := now.Add(-time.Minute)
+	count := 0
+	for i := len(metrics.requestHistory) - 1; i >= 0; i-- {
+		if metrics.requestHistory[i].Timestamp.Before(cutoff) {
+			break
+		}
+		count++
+	}
+	return float64(count)
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveThresholdConfig_ThresholdAt(t *testing.T) {
+	cfg := AdaptiveThresholdConfig{LowRPM: 10, HighRPM: 110, LenientThreshold: 0.8, StrictThreshold: 0.3}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() err = %v", err)
+	}
+
+	tests := []struct {
+		rpm  float64
+		want float64
+	}{
+		{0, 0.8},
+		{10, 0.8},
+		{60, 0.55},
+		{110, 0.3},
+		{500, 0.3},
+	}
+	for _, tt := range tests {
+		if got := cfg.ThresholdAt(tt.rpm); got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("ThresholdAt(%v) = %v, want %v", tt.rpm, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []AdaptiveThresholdConfig{
+		{LowRPM: 100, HighRPM: 10, LenientThreshold: 0.8, StrictThreshold: 0.3},
+		{LowRPM: 10, HighRPM: 100, LenientThreshold: 0.3, StrictThreshold: 0.8},
+		{LowRPM: 10, HighRPM: 100, LenientThreshold: 1.5, StrictThreshold: 0.3},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Validate(%+v) err = nil, want error", invalid)
+		}
+	}
+}
+
+func TestAdaptiveThreshold_LowVsHighRPM(t *testing.T) {
+	const (
+		baseURL = "https://api.example.com"
+		key     = "sk-test"
+	)
+	adaptive := &AdaptiveThresholdConfig{LowRPM: 5, HighRPM: 60, LenientThreshold: 0.8, StrictThreshold: 0.3}
+
+	// 相同的滑动窗口：10 次请求中最后 4 次失败（40% 失败率）
+	recordWindow := func(m *MetricsManager, start time.Time, spacing time.Duration) {
+		for i := 0; i < 10; i++ {
+			id := m.RecordRequestConnectedAt(baseURL, key, "claude-test", start.Add(time.Duration(i)*spacing))
+			if i < 6 {
+				m.RecordRequestFinalizeSuccess(baseURL, key, id, nil)
+			} else {
+				m.RecordRequestFinalizeFailure(baseURL, key, id)
+			}
+		}
+	}
+
+	tests := []struct {
+		name       string
+		adaptive   *AdaptiveThresholdConfig
+		background int // 窗口前最近 1 分钟内额外的成功请求数（模拟高流量）
+		wantBroken bool
+	}{
+		{name: "固定阈值 50% 低流量不熔断", adaptive: nil, background: 0, wantBroken: false},
+		{name: "固定阈值 50% 高流量不熔断", adaptive: nil, background: 80, wantBroken: false},
+		{name: "自适应低流量使用宽松阈值", adaptive: adaptive, background: 0, wantBroken: false},
+		{name: "自适应高流量使用严格阈值", adaptive: adaptive, background: 80, wantBroken: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMetricsManagerWithConfig(10, 0.5)
+			defer m.Stop()
+			if err := m.SetAdaptiveThreshold(tt.adaptive); err != nil {
+				t.Fatalf("SetAdaptiveThreshold() err = %v", err)
+			}
+
+			now := time.Now()
+			if tt.background > 0 {
+				for i := 0; i < tt.background; i++ {
+					id := m.RecordRequestConnectedAt(baseURL, key, "claude-test", now.Add(-30*time.Second))
+					m.RecordRequestFinalizeSuccess(baseURL, key, id, nil)
+				}
+				recordWindow(m, now.Add(-10*time.Second), time.Second)
+			} else {
+				// 低流量：窗口内请求间隔 2 分钟，最近 1 分钟几乎没有请求
+				recordWindow(m, now.Add(-20*time.Minute), 2*time.Minute)
+			}
+
+			broken := m.GetKeyMetrics(baseURL, key).CircuitBrokenAt != nil
+			if broken != tt.wantBroken {
+				t.Fatalf("熔断=%v, want %v (失败率 %.0f%%)", broken, tt.wantBroken, m.CalculateKeyFailureRate(baseURL, key)*100)
+			}
+			if m.ShouldSuspendKey(baseURL, key) != tt.wantBroken {
+				t.Fatalf("ShouldSuspendKey 与熔断状态不一致")
+			}
+		})
+	}
+}
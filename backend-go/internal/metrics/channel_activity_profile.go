@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+// ChannelActivityProfile 渠道流量分布画像（用于容量规划）
+// 可区分"持续少量请求"与"偶发集中突发"的渠道，最大空闲间隔持续增长说明渠道可能已被弃用
+type ChannelActivityProfile struct {
+	RequestCount      int64   `json:"requestCount"`
+	MaxIdleGapSeconds float64 `json:"maxIdleGapSeconds"` // 窗口内最长空闲时间（含窗口起点到首个请求、末个请求到现在）
+	MeanGapSeconds    float64 `json:"meanGapSeconds"`    // 相邻请求的平均间隔（请求数少于 2 时为 0）
+	ActiveMinutes     int     `json:"activeMinutes"`     // 至少有一个请求的自然分钟数
+}
+
+// GetChannelActivityProfile 根据请求历史计算渠道在时间窗口内的流量分布画像
+// 请求历史最多保留 24 小时，更长的窗口按保留范围内的数据计算
+func (m *MetricsManager) GetChannelActivityProfile(baseURLs []string, activeKeys []string, duration time.Duration) ChannelActivityProfile {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.channelActivityProfileLocked(baseURLs, activeKeys, duration, time.Now())
+}
+
+// channelActivityProfileLocked 以 now 为窗口终点计算流量分布画像（调用方需持有读锁）
+func (m *MetricsManager) channelActivityProfileLocked(baseURLs []string, activeKeys []string, duration time.Duration, now time.Time) ChannelActivityProfile {
+	if duration <= 0 {
+		return ChannelActivityProfile{}
+	}
+	windowStart := now.Add(-duration)
+
+	var timestamps []time.Time
+	for _, baseURL := range baseURLs {
+		for _, apiKey := range activeKeys {
+			metrics, exists := m.keyMetrics[generateMetricsKey(baseURL, apiKey)]
+			if !exists {
+				continue
+			}
+			for _, record := range metrics.requestHistory {
+				if record.Timestamp.Before(windowStart) || record.Timestamp.After(now) {
+					continue
+				}
+				timestamps = append(timestamps, record.Timestamp)
+			}
+		}
+	}
+
+	profile := ChannelActivityProfile{RequestCount: int64(len(timestamps))}
+	if len(timestamps) == 0 {
+		profile.MaxIdleGapSeconds = duration.Seconds()
+		return profile
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i].Before(timestamps[j]) })
+
+	maxGap := timestamps[0].Sub(windowStart)
+	if tail := now.Sub(timestamps[len(timestamps)-1]); tail > maxGap {
+		maxGap = tail
+	}
+	activeMinutes := map[int64]struct{}{timestamps[0].Unix() / 60: {}}
+	for i := 1; i < len(timestamps); i++ {
+		if gap := timestamps[i].Sub(timestamps[i-1]); gap > maxGap {
+			maxGap = gap
+		}
+		activeMinutes[timestamps[i].Unix()/60] = struct{}{}
+	}
+
+	profile.MaxIdleGapSeconds = maxGap.Seconds()
+	if len(timestamps) > 1 {
+		profile.MeanGapSeconds = timestamps[len(timestamps)-1].Sub(timestamps[0]).Seconds() / float64(len(timestamps)-1)
+	}
+	profile.ActiveMinutes = len(activeMinutes)
+	return profile
+}
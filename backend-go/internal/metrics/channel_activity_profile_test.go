@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChannelActivityProfile(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+	at := func(ago time.Duration) time.Time { return now.Add(-ago) }
+	baseURL := "https://example.com"
+
+	m := NewMetricsManagerWithConfig(10, 0.5)
+	defer m.Stop()
+
+	// k1: 一次突发（11:10:00、11:10:30、11:11:00）后间隔 29 分钟再来两个请求；窗口外的记录不计入
+	// k2: 5 分钟前一个请求
+	history := map[string][]time.Time{
+		"k1": {at(2 * time.Hour), at(50 * time.Minute), at(49*time.Minute + 30*time.Second), at(49 * time.Minute), at(20 * time.Minute), at(19 * time.Minute)},
+		"k2": {at(5 * time.Minute)},
+	}
+	for key, timestamps := range history {
+		metrics := m.getOrCreateKey(baseURL, key)
+		for _, ts := range timestamps {
+			metrics.requestHistory = append(metrics.requestHistory, RequestRecord{Timestamp: ts, Success: true})
+		}
+	}
+
+	got := m.channelActivityProfileLocked([]string{baseURL}, []string{"k1", "k2"}, time.Hour, now)
+	want := ChannelActivityProfile{
+		RequestCount:      6,
+		MaxIdleGapSeconds: (29 * time.Minute).Seconds(), // 11:11 → 11:40
+		MeanGapSeconds:    (9 * time.Minute).Seconds(),  // 11:10 → 11:55 共 45 分钟，5 个间隔
+		ActiveMinutes:     5,                            // 11:10、11:11、11:40、11:41、11:55
+	}
+	if got != want {
+		t.Fatalf("profile = %+v, want %+v", got, want)
+	}
+
+	// 窗口起点到首个请求的空闲计入最大间隔
+	got = m.channelActivityProfileLocked([]string{baseURL}, []string{"k2"}, time.Hour, now)
+	if got.RequestCount != 1 || got.MaxIdleGapSeconds != (55*time.Minute).Seconds() || got.MeanGapSeconds != 0 || got.ActiveMinutes != 1 {
+		t.Fatalf("单请求 profile = %+v", got)
+	}
+
+	// 无请求：整个窗口都是空闲
+	got = m.channelActivityProfileLocked([]string{baseURL}, []string{"unknown"}, time.Hour, now)
+	if got.RequestCount != 0 || got.MaxIdleGapSeconds != time.Hour.Seconds() || got.ActiveMinutes != 0 {
+		t.Fatalf("无请求 profile = %+v", got)
+	}
+}
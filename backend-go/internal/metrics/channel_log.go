@@ -18,18 +18,41 @@ type ChannelLog struct {
 	ErrorInfo     string    `json:"errorInfo"`
 	IsRetry       bool      `json:"isRetry"`
 	InterfaceType string    `json:"interfaceType"` // 接口类型（Messages/Responses/Gemini）
+	// 成功请求的最终 token 用量（流式请求取自流结束时收集的 usage）
+	InputTokens  int `json:"inputTokens,omitempty"`
+	OutputTokens int `json:"outputTokens,omitempty"`
+	// 命中响应缓存（未调用上游，渠道与模型为缓存写入时的值）
+	CacheHit bool `json:"cacheHit,omitempty"`
+	// 请求关联 ID（与响应头 X-CCX-Request-Id 一致）
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// KeyErrorSample 单个 Key 的一次失败请求样本
+type KeyErrorSample struct {
+	Timestamp  time.Time `json:"timestamp"`
+	StatusCode int       `json:"statusCode"`
+	Body       string    `json:"body"` // 截断后的上游错误响应（或请求错误信息）
+	BaseURL    string    `json:"baseUrl"`
+	Model      string    `json:"model"`
 }
 
 const maxChannelLogs = 50
 
+// maxKeyErrorSamples 每个 Key 保留的最近失败样本数
+const maxKeyErrorSamples = 10
+
 // ChannelLogStore 渠道日志存储（内存环形缓冲区）
 type ChannelLogStore struct {
-	mu   sync.RWMutex
-	logs map[int][]*ChannelLog // key: channelIndex
+	mu        sync.RWMutex
+	logs      map[int][]*ChannelLog                // key: channelIndex
+	keyErrors map[int]map[string][]*KeyErrorSample // key: channelIndex -> keyMask
 }
 
 func NewChannelLogStore() *ChannelLogStore {
-	return &ChannelLogStore{logs: make(map[int][]*ChannelLog)}
+	return &ChannelLogStore{
+		logs:      make(map[int][]*ChannelLog),
+		keyErrors: make(map[int]map[string][]*KeyErrorSample),
+	}
 }
 
 func (s *ChannelLogStore) Record(channelIndex int, log *ChannelLog) {
@@ -39,6 +62,27 @@ func (s *ChannelLogStore) Record(channelIndex int, log *ChannelLog) {
 	if len(s.logs[channelIndex]) > maxChannelLogs {
 		s.logs[channelIndex] = s.logs[channelIndex][len(s.logs[channelIndex])-maxChannelLogs:]
 	}
+
+	// 失败请求额外按 Key 保留最近样本，避免被同渠道其他 Key 的日志挤出
+	if log.Success || log.KeyMask == "" {
+		return
+	}
+	byKey := s.keyErrors[channelIndex]
+	if byKey == nil {
+		byKey = make(map[string][]*KeyErrorSample)
+		s.keyErrors[channelIndex] = byKey
+	}
+	samples := append(byKey[log.KeyMask], &KeyErrorSample{
+		Timestamp:  log.Timestamp,
+		StatusCode: log.StatusCode,
+		Body:       log.ErrorInfo,
+		BaseURL:    log.BaseURL,
+		Model:      log.Model,
+	})
+	if len(samples) > maxKeyErrorSamples {
+		samples = samples[len(samples)-maxKeyErrorSamples:]
+	}
+	byKey[log.KeyMask] = samples
 }
 
 // ClearAll 清除所有渠道日志（渠道删除导致索引变化时调用）
@@ -46,6 +90,22 @@ func (s *ChannelLogStore) ClearAll() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.logs = make(map[int][]*ChannelLog)
+	s.keyErrors = make(map[int]map[string][]*KeyErrorSample)
+}
+
+// GetKeyRecentErrors 获取指定渠道中某个 Key（脱敏后）最近的失败样本，按时间倒序（最新在前）
+func (s *ChannelLogStore) GetKeyRecentErrors(channelIndex int, keyMask string) []*KeyErrorSample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	src := s.keyErrors[channelIndex][keyMask]
+	if len(src) == 0 {
+		return nil
+	}
+	result := make([]*KeyErrorSample, len(src))
+	for i, j := 0, len(src)-1; j >= 0; i, j = i+1, j-1 {
+		result[i] = src[j]
+	}
+	return result
 }
 
 func (s *ChannelLogStore) Get(channelIndex int) []*ChannelLog {
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestChannelLogStore_GetKeyRecentErrors(t *testing.T) {
+	store := NewChannelLogStore()
+	base := time.Now()
+
+	total := maxKeyErrorSamples + 5
+	for i := 0; i < total; i++ {
+		store.Record(0, &ChannelLog{
+			Timestamp:  base.Add(time.Duration(i) * time.Second),
+			StatusCode: 500,
+			KeyMask:    "sk-a***",
+			ErrorInfo:  fmt.Sprintf("error %d", i),
+			Success:    false,
+		})
+	}
+	// 成功请求与其他 Key、其他渠道的失败不应出现在结果中
+	store.Record(0, &ChannelLog{Timestamp: base, StatusCode: 200, KeyMask: "sk-a***", Success: true})
+	store.Record(0, &ChannelLog{Timestamp: base, StatusCode: 429, KeyMask: "sk-b***", ErrorInfo: "rate limited"})
+	store.Record(1, &ChannelLog{Timestamp: base, StatusCode: 401, KeyMask: "sk-a***", ErrorInfo: "unauthorized"})
+
+	got := store.GetKeyRecentErrors(0, "sk-a***")
+	if len(got) != maxKeyErrorSamples {
+		t.Fatalf("样本数=%d, want %d", len(got), maxKeyErrorSamples)
+	}
+	for i, sample := range got {
+		want := fmt.Sprintf("error %d", total-1-i)
+		if sample.Body != want || sample.StatusCode != 500 {
+			t.Fatalf("got[%d]=%+v, want body %q status 500", i, sample, want)
+		}
+	}
+
+	if got := store.GetKeyRecentErrors(0, "sk-b***"); len(got) != 1 || got[0].StatusCode != 429 {
+		t.Fatalf("sk-b 样本=%+v, want 1 条 429", got)
+	}
+	if got := store.GetKeyRecentErrors(2, "sk-a***"); got != nil {
+		t.Fatalf("未知渠道应返回 nil, got %+v", got)
+	}
+
+	store.ClearAll()
+	if got := store.GetKeyRecentErrors(0, "sk-a***"); got != nil {
+		t.Fatalf("ClearAll 后应返回 nil, got %+v", got)
+	}
+}
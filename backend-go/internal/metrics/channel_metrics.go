@@ -1,11 +1,13 @@
 package metrics
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"log"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/BenedictKing/ccx/internal/types"
@@ -21,27 +23,40 @@ type RequestRecord struct {
 	OutputTokens             int64
 	CacheCreationInputTokens int64
 	CacheReadInputTokens     int64
+	ThinkingTokens           int64 // 扩展思考 tokens（上游单独返回时记录）
+	Estimated                bool  // Token 数为估算值（上游流式响应未返回 usage）
 }
 
 // KeyMetrics 单个 Key 的指标（绑定到 BaseURL + Key 组合）
 type KeyMetrics struct {
-	MetricsKey          string     `json:"metricsKey"`          // hash(baseURL + apiKey)
-	BaseURL             string     `json:"baseUrl"`             // 用于显示
-	KeyMask             string     `json:"keyMask"`             // 脱敏的 key（用于显示）
-	RequestCount        int64      `json:"requestCount"`        // 总请求数
-	SuccessCount        int64      `json:"successCount"`        // 成功数
-	FailureCount        int64      `json:"failureCount"`        // 失败数
-	ConsecutiveFailures int64      `json:"consecutiveFailures"` // 连续失败数
-	ActiveRequests      int64      `json:"activeRequests"`      // 进行中的请求数
-	LastSuccessAt       *time.Time `json:"lastSuccessAt,omitempty"`
-	LastFailureAt       *time.Time `json:"lastFailureAt,omitempty"`
-	CircuitBrokenAt     *time.Time `json:"circuitBrokenAt,omitempty"` // 熔断开始时间
+	MetricsKey          string       `json:"metricsKey"`          // hash(baseURL + apiKey)
+	BaseURL             string       `json:"baseUrl"`             // 用于显示
+	KeyMask             string       `json:"keyMask"`             // 脱敏的 key（用于显示）
+	RequestCount        int64        `json:"requestCount"`        // 总请求数
+	SuccessCount        int64        `json:"successCount"`        // 成功数
+	FailureCount        int64        `json:"failureCount"`        // 失败数
+	ConsecutiveFailures int64        `json:"consecutiveFailures"` // 连续失败数
+	ActiveRequests      atomic.Int64 `json:"-"`                   // 进行中的请求数（原子计数，开始/结束只需读锁定位 Key）
+	LastSuccessAt       *time.Time   `json:"lastSuccessAt,omitempty"`
+	LastFailureAt       *time.Time   `json:"lastFailureAt,omitempty"`
+	CircuitBrokenAt     *time.Time   `json:"circuitBrokenAt,omitempty"` // 熔断开始时间
+	SuspendedUntil      *time.Time   `json:"suspendedUntil,omitempty"`  // 上游 429 Retry-After 指定的暂停截止时间
+	// 按错误类型的失败计数（401/429/5xx/timeout/invalid_response）
+	ErrorBreakdown map[string]int64 `json:"errorBreakdown,omitempty"`
 	// 滑动窗口记录（最近 N 次请求的结果）
 	recentResults []bool // true=success, false=failure
 	// 带时间戳的请求记录（用于分时段统计，保留24小时）
 	requestHistory []RequestRecord
 	// 进行中请求在 requestHistory 中的索引（用于“连接即计数”，结束后回写成功/失败与 token）
 	pendingHistoryIdx map[uint64]int
+	// 已过期移出 requestHistory 的成功/失败请求数（计数对账时与历史记录合并，与累计计数同口径比较）
+	expiredSuccessCount int64
+	expiredFailureCount int64
+	// 今日（本地零点起）输入 + 输出 token 消耗，随请求记录增量累加（每日预算检查使用，跨天后懒重置）
+	todayTokens    int64
+	todayTokensDay time.Time
+	// 熔断状态变化记录（用于计算可用率）
+	circuitEvents []CircuitEvent
 }
 
 // ChannelMetrics 渠道聚合指标（用于 API 返回，兼容旧结构）
@@ -73,6 +88,7 @@ type TimeWindowStats struct {
 	OutputTokens        int64 `json:"outputTokens,omitempty"`
 	CacheCreationTokens int64 `json:"cacheCreationTokens,omitempty"`
 	CacheReadTokens     int64 `json:"cacheReadTokens,omitempty"`
+	ThinkingTokens      int64 `json:"thinkingTokens,omitempty"`
 	// CacheHitRate 缓存命中率（Token口径），范围 0-100
 	// 定义：cacheReadTokens / (cacheReadTokens + inputTokens) * 100
 	CacheHitRate float64 `json:"cacheHitRate,omitempty"`
@@ -88,9 +104,33 @@ type MetricsManager struct {
 	stopCh              chan struct{}          // 用于停止清理 goroutine
 	nextRequestID       uint64                 // 单进程递增请求ID（用于 pendingHistoryIdx）
 
+	// 后台任务生命周期（Stop 可重复调用，Shutdown 等待后台 goroutine 退出）
+	stopOnce sync.Once
+	bgWg     sync.WaitGroup
+
 	// 持久化存储（可选）
 	store   PersistenceStore
 	apiType string // "messages"、"responses" 或 "gemini"
+
+	// TPM 是否额外计入上游单独返回的思考 tokens
+	tpmIncludeThinking bool
+	tpmDefinition      TPMDefinition
+
+	// 渠道级熔断覆盖（按 BaseURL，由配置变更时推送）
+	circuitOverrides map[string]CircuitOverride
+
+	// 自适应熔断阈值（可选，按 Key 最近 RPM 调整失败率阈值）
+	adaptiveThreshold *AdaptiveThresholdConfig
+
+	// 渠道健康度聚合策略（空值等同 pooled）
+	healthAggregation HealthAggregation
+
+	// 仪表盘成功率的最小样本数（低于该值标记为数据不足），0 表示不限制
+	dashboardMinSamples int
+
+	// 过期 Key 清理：分块大小与调度抖动
+	cleanupChunkSize int
+	cleanupJitter    time.Duration
 }
 
 // NewMetricsManager 创建指标管理器
@@ -101,8 +141,11 @@ func NewMetricsManager() *MetricsManager {
 		failureThreshold:    0.5,              // 默认 50% 失败率阈值
 		circuitRecoveryTime: 15 * time.Minute, // 默认 15 分钟自动恢复
 		stopCh:              make(chan struct{}),
+		cleanupChunkSize:    DefaultStaleKeyCleanupChunkSize,
+		cleanupJitter:       DefaultStaleKeyCleanupJitter,
 	}
 	// 启动后台熔断恢复任务
+	m.bgWg.Add(1)
 	go m.cleanupCircuitBreakers()
 	return m
 }
@@ -121,8 +164,11 @@ func NewMetricsManagerWithConfig(windowSize int, failureThreshold float64) *Metr
 		failureThreshold:    failureThreshold,
 		circuitRecoveryTime: 15 * time.Minute,
 		stopCh:              make(chan struct{}),
+		cleanupChunkSize:    DefaultStaleKeyCleanupChunkSize,
+		cleanupJitter:       DefaultStaleKeyCleanupJitter,
 	}
 	// 启动后台熔断恢复任务
+	m.bgWg.Add(1)
 	go m.cleanupCircuitBreakers()
 	return m
 }
@@ -141,6 +187,8 @@ func NewMetricsManagerWithPersistence(windowSize int, failureThreshold float64,
 		failureThreshold:    failureThreshold,
 		circuitRecoveryTime: 15 * time.Minute,
 		stopCh:              make(chan struct{}),
+		cleanupChunkSize:    DefaultStaleKeyCleanupChunkSize,
+		cleanupJitter:       DefaultStaleKeyCleanupJitter,
 		store:               store,
 		apiType:             apiType,
 	}
@@ -153,6 +201,7 @@ func NewMetricsManagerWithPersistence(windowSize int, failureThreshold float64,
 	}
 
 	// 启动后台熔断恢复任务
+	m.bgWg.Add(1)
 	go m.cleanupCircuitBreakers()
 	return m
 }
@@ -195,7 +244,9 @@ func (m *MetricsManager) loadFromStore() error {
 			OutputTokens:             r.OutputTokens,
 			CacheCreationInputTokens: r.CacheCreationTokens,
 			CacheReadInputTokens:     r.CacheReadTokens,
+			ThinkingTokens:           r.ThinkingTokens,
 		})
+		addTodayTokensLocked(metrics, r.Timestamp, r.InputTokens+r.OutputTokens)
 
 		// 更新聚合计数
 		metrics.RequestCount++
@@ -338,6 +389,7 @@ func (m *MetricsManager) recordSuccessWithUsageLocked(baseURL, apiKey string, us
 	// 成功后清除熔断标记
 	if metrics.CircuitBrokenAt != nil {
 		metrics.CircuitBrokenAt = nil
+		recordCircuitEventLocked(metrics, false, now)
 		log.Printf("[Metrics-Circuit] Key [%s] (%s) 因请求成功退出熔断状态", metrics.KeyMask, metrics.BaseURL)
 	}
 
@@ -345,7 +397,7 @@ func (m *MetricsManager) recordSuccessWithUsageLocked(baseURL, apiKey string, us
 	m.appendToWindowKey(metrics, true)
 
 	// 提取 Token 数据（如果有）
-	var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int64
+	var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens, thinkingTokens int64
 	if usage != nil {
 		inputTokens = int64(usage.InputTokens)
 		outputTokens = int64(usage.OutputTokens)
@@ -355,10 +407,14 @@ func (m *MetricsManager) recordSuccessWithUsageLocked(baseURL, apiKey string, us
 			cacheCreationTokens = int64(usage.CacheCreation5mInputTokens + usage.CacheCreation1hInputTokens)
 		}
 		cacheReadTokens = int64(usage.CacheReadInputTokens)
+		thinkingTokens = int64(usage.ThinkingTokens)
 	}
 
 	// 记录带时间戳的请求
-	m.appendToHistoryKeyWithUsage(metrics, now, true, inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens)
+	m.appendToHistoryKeyWithUsage(metrics, now, true, inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens, thinkingTokens)
+	if usage != nil && usage.Estimated {
+		metrics.requestHistory[len(metrics.requestHistory)-1].Estimated = true
+	}
 
 	// 写入持久化存储（异步，不阻塞）
 	if m.store != nil {
@@ -372,6 +428,7 @@ func (m *MetricsManager) recordSuccessWithUsageLocked(baseURL, apiKey string, us
 			OutputTokens:        outputTokens,
 			CacheCreationTokens: cacheCreationTokens,
 			CacheReadTokens:     cacheReadTokens,
+			ThinkingTokens:      thinkingTokens,
 			APIType:             m.apiType,
 		})
 	}
@@ -399,6 +456,7 @@ func (m *MetricsManager) recordFailureLocked(baseURL, apiKey string, now time.Ti
 	// 检查是否刚进入熔断状态
 	if metrics.CircuitBrokenAt == nil && m.isKeyCircuitBroken(metrics) {
 		metrics.CircuitBrokenAt = &now
+		recordCircuitEventLocked(metrics, true, now)
 		log.Printf("[Metrics-Circuit] Key [%s] (%s) 进入熔断状态（失败率: %.1f%%）", metrics.KeyMask, metrics.BaseURL, m.calculateKeyFailureRateInternal(metrics)*100)
 	}
 
@@ -486,6 +544,7 @@ func (m *MetricsManager) RecordRequestFinalizeSuccess(baseURL, apiKey string, re
 	// 成功后清除熔断标记
 	if metrics.CircuitBrokenAt != nil {
 		metrics.CircuitBrokenAt = nil
+		recordCircuitEventLocked(metrics, false, now)
 		log.Printf("[Metrics-Circuit] Key [%s] (%s) 因请求成功退出熔断状态", metrics.KeyMask, metrics.BaseURL)
 	}
 
@@ -493,7 +552,7 @@ func (m *MetricsManager) RecordRequestFinalizeSuccess(baseURL, apiKey string, re
 	m.appendToWindowKey(metrics, true)
 
 	// 提取 Token 数据（如果有）
-	var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int64
+	var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens, thinkingTokens int64
 	if usage != nil {
 		inputTokens = int64(usage.InputTokens)
 		outputTokens = int64(usage.OutputTokens)
@@ -503,6 +562,7 @@ func (m *MetricsManager) RecordRequestFinalizeSuccess(baseURL, apiKey string, re
 			cacheCreationTokens = int64(usage.CacheCreation5mInputTokens + usage.CacheCreation1hInputTokens)
 		}
 		cacheReadTokens = int64(usage.CacheReadInputTokens)
+		thinkingTokens = int64(usage.ThinkingTokens)
 	}
 
 	// 回写历史记录（时间戳保持为“请求开始（TCP 建连阶段）”时刻）
@@ -512,6 +572,9 @@ func (m *MetricsManager) RecordRequestFinalizeSuccess(baseURL, apiKey string, re
 	record.OutputTokens = outputTokens
 	record.CacheCreationInputTokens = cacheCreationTokens
 	record.CacheReadInputTokens = cacheReadTokens
+	record.ThinkingTokens = thinkingTokens
+	record.Estimated = usage != nil && usage.Estimated
+	addTodayTokensLocked(metrics, record.Timestamp, inputTokens+outputTokens)
 
 	// 写入持久化存储（异步，不阻塞）
 	if m.store != nil {
@@ -525,6 +588,7 @@ func (m *MetricsManager) RecordRequestFinalizeSuccess(baseURL, apiKey string, re
 			OutputTokens:        outputTokens,
 			CacheCreationTokens: cacheCreationTokens,
 			CacheReadTokens:     cacheReadTokens,
+			ThinkingTokens:      thinkingTokens,
 			APIType:             m.apiType,
 			Model:               record.Model,
 		})
@@ -564,6 +628,7 @@ func (m *MetricsManager) RecordRequestFinalizeFailure(baseURL, apiKey string, re
 	// 检查是否刚进入熔断状态
 	if metrics.CircuitBrokenAt == nil && m.isKeyCircuitBroken(metrics) {
 		metrics.CircuitBrokenAt = &now
+		recordCircuitEventLocked(metrics, true, now)
 		log.Printf("[Metrics-Circuit] Key [%s] (%s) 进入熔断状态（失败率: %.1f%%）", metrics.KeyMask, metrics.BaseURL, m.calculateKeyFailureRateInternal(metrics)*100)
 	}
 
@@ -574,6 +639,7 @@ func (m *MetricsManager) RecordRequestFinalizeFailure(baseURL, apiKey string, re
 	record.OutputTokens = 0
 	record.CacheCreationInputTokens = 0
 	record.CacheReadInputTokens = 0
+	record.ThinkingTokens = 0
 
 	// 写入持久化存储（异步，不阻塞）
 	if m.store != nil {
@@ -628,27 +694,55 @@ func (m *MetricsManager) RecordRequestFinalizeClientCancel(baseURL, apiKey strin
 }
 
 // RecordRequestStart 记录请求开始（增加进行中计数）
+// Key 已存在时只需读锁，首次出现时才升级为写锁创建
 func (m *MetricsManager) RecordRequestStart(baseURL, apiKey string) {
+	metricsKey := generateMetricsKey(baseURL, apiKey)
+
+	m.mu.RLock()
+	if metrics, exists := m.keyMetrics[metricsKey]; exists {
+		metrics.ActiveRequests.Add(1)
+		m.mu.RUnlock()
+		return
+	}
+	m.mu.RUnlock()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
-
-	metrics := m.getOrCreateKey(baseURL, apiKey)
-	metrics.ActiveRequests++
+	m.getOrCreateKey(baseURL, apiKey).ActiveRequests.Add(1)
 }
 
-// RecordRequestEnd 记录请求结束（减少进行中计数）
+// RecordRequestEnd 记录请求结束（减少进行中计数，不会低于 0）
 func (m *MetricsManager) RecordRequestEnd(baseURL, apiKey string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
 	metricsKey := generateMetricsKey(baseURL, apiKey)
 	if metrics, exists := m.keyMetrics[metricsKey]; exists {
-		if metrics.ActiveRequests > 0 {
-			metrics.ActiveRequests--
+		for {
+			active := metrics.ActiveRequests.Load()
+			if active <= 0 || metrics.ActiveRequests.CompareAndSwap(active, active-1) {
+				return
+			}
 		}
 	}
 }
 
+// GetActiveRequests 获取多个 BaseURL × Key 组合的进行中请求总数
+func (m *MetricsManager) GetActiveRequests(baseURLs []string, apiKeys []string) int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var total int64
+	for _, baseURL := range baseURLs {
+		for _, apiKey := range apiKeys {
+			if metrics, exists := m.keyMetrics[generateMetricsKey(baseURL, apiKey)]; exists {
+				total += metrics.ActiveRequests.Load()
+			}
+		}
+	}
+	return total
+}
+
 // isKeyCircuitBroken 判断 Key 是否达到熔断条件（内部方法，调用前需持有锁）
 func (m *MetricsManager) isKeyCircuitBroken(metrics *KeyMetrics) bool {
 	// 最小请求数保护：至少 max(3, windowSize/2) 次请求才判断熔断
@@ -656,7 +750,7 @@ func (m *MetricsManager) isKeyCircuitBroken(metrics *KeyMetrics) bool {
 	if len(metrics.recentResults) < minRequests {
 		return false
 	}
-	return m.calculateKeyFailureRateInternal(metrics) >= m.failureThreshold
+	return m.calculateKeyFailureRateInternal(metrics) >= m.keyFailureThreshold(metrics)
 }
 
 // calculateKeyFailureRateInternal 计算 Key 失败率（内部方法，调用前需持有锁）
@@ -684,7 +778,7 @@ func (m *MetricsManager) appendToWindowKey(metrics *KeyMetrics, success bool) {
 
 // appendToHistoryKey 向 Key 历史记录添加请求（保留24小时）
 func (m *MetricsManager) appendToHistoryKey(metrics *KeyMetrics, timestamp time.Time, success bool) {
-	m.appendToHistoryKeyWithUsage(metrics, timestamp, success, 0, 0, 0, 0)
+	m.appendToHistoryKeyWithUsage(metrics, timestamp, success, 0, 0, 0, 0, 0)
 }
 
 // cleanupHistoryLocked 清理超过 24 小时的历史记录，并同步修正 pendingHistoryIdx 索引。
@@ -704,6 +798,12 @@ func (m *MetricsManager) cleanupHistoryLocked(metrics *KeyMetrics) {
 		}
 	}
 
+	expired := newStart
+	if newStart == -1 {
+		expired = len(metrics.requestHistory)
+	}
+	m.countExpiredHistoryLocked(metrics, expired)
+
 	if newStart > 0 {
 		metrics.requestHistory = metrics.requestHistory[newStart:]
 		// 索引平移：老数据被切走后，pending 索引需要整体减去 newStart
@@ -730,8 +830,30 @@ func (m *MetricsManager) cleanupHistoryLocked(metrics *KeyMetrics) {
 	}
 }
 
+// countExpiredHistoryLocked 累计即将移出历史的前 n 条已完成请求（进行中的请求尚未计入累计计数，不参与累计）
+// 注意：调用方需要持有写锁。
+func (m *MetricsManager) countExpiredHistoryLocked(metrics *KeyMetrics, n int) {
+	if n <= 0 {
+		return
+	}
+	pending := make(map[int]bool, len(metrics.pendingHistoryIdx))
+	for _, idx := range metrics.pendingHistoryIdx {
+		pending[idx] = true
+	}
+	for i, record := range metrics.requestHistory[:n] {
+		if pending[i] {
+			continue
+		}
+		if record.Success {
+			metrics.expiredSuccessCount++
+		} else {
+			metrics.expiredFailureCount++
+		}
+	}
+}
+
 // appendToHistoryKeyWithUsage 向 Key 历史记录添加请求（带 Usage 数据）
-func (m *MetricsManager) appendToHistoryKeyWithUsage(metrics *KeyMetrics, timestamp time.Time, success bool, inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int64) {
+func (m *MetricsManager) appendToHistoryKeyWithUsage(metrics *KeyMetrics, timestamp time.Time, success bool, inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens, thinkingTokens int64) {
 	metrics.requestHistory = append(metrics.requestHistory, RequestRecord{
 		Timestamp:                timestamp,
 		Success:                  success,
@@ -739,8 +861,11 @@ func (m *MetricsManager) appendToHistoryKeyWithUsage(metrics *KeyMetrics, timest
 		OutputTokens:             outputTokens,
 		CacheCreationInputTokens: cacheCreationTokens,
 		CacheReadInputTokens:     cacheReadTokens,
+		ThinkingTokens:           thinkingTokens,
 	})
 
+	addTodayTokensLocked(metrics, timestamp, inputTokens+outputTokens)
+
 	// 清理超过 24 小时的记录
 	m.cleanupHistoryLocked(metrics)
 }
@@ -756,7 +881,7 @@ func (m *MetricsManager) IsKeyHealthy(baseURL, apiKey string) bool {
 		return true // 没有记录，默认健康
 	}
 
-	return m.calculateKeyFailureRateInternal(metrics) < m.failureThreshold
+	return m.calculateKeyFailureRateInternal(metrics) < m.keyFailureThreshold(metrics)
 }
 
 // IsChannelHealthy 判断渠道是否健康（基于当前活跃 Keys 聚合计算）
@@ -769,36 +894,21 @@ func (m *MetricsManager) IsChannelHealthyWithKeys(baseURL string, activeKeys []s
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	// 聚合所有活跃 Key 的指标
-	var totalResults []bool
-	for _, apiKey := range activeKeys {
-		metricsKey := generateMetricsKey(baseURL, apiKey)
-		if metrics, exists := m.keyMetrics[metricsKey]; exists {
-			totalResults = append(totalResults, metrics.recentResults...)
-		}
-	}
+	// 按聚合策略计算所有活跃 Key 的失败率
+	failureRate, samples := m.channelFailureRateLocked(baseURL, activeKeys)
 
 	// 没有任何记录，默认健康
-	if len(totalResults) == 0 {
+	if samples == 0 {
 		return true
 	}
 
 	// 最小请求数保护：至少 max(3, windowSize/2) 次请求才判断健康状态
 	minRequests := max(3, m.windowSize/2)
-	if len(totalResults) < minRequests {
+	if samples < minRequests {
 		return true // 请求数不足，默认健康
 	}
 
-	// 计算聚合失败率
-	failures := 0
-	for _, success := range totalResults {
-		if !success {
-			failures++
-		}
-	}
-	failureRate := float64(failures) / float64(len(totalResults))
-
-	return failureRate < m.failureThreshold
+	return failureRate < m.failureThresholdFor(baseURL)
 }
 
 // CalculateKeyFailureRate 计算单个 Key 的失败率
@@ -815,7 +925,7 @@ func (m *MetricsManager) CalculateKeyFailureRate(baseURL, apiKey string) float64
 	return m.calculateKeyFailureRateInternal(metrics)
 }
 
-// CalculateChannelFailureRate 计算渠道聚合失败率
+// CalculateChannelFailureRate 计算渠道聚合失败率（按健康度聚合策略）
 func (m *MetricsManager) CalculateChannelFailureRate(baseURL string, activeKeys []string) float64 {
 	if len(activeKeys) == 0 {
 		return 0
@@ -824,26 +934,8 @@ func (m *MetricsManager) CalculateChannelFailureRate(baseURL string, activeKeys
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	var totalResults []bool
-	for _, apiKey := range activeKeys {
-		metricsKey := generateMetricsKey(baseURL, apiKey)
-		if metrics, exists := m.keyMetrics[metricsKey]; exists {
-			totalResults = append(totalResults, metrics.recentResults...)
-		}
-	}
-
-	if len(totalResults) == 0 {
-		return 0
-	}
-
-	failures := 0
-	for _, success := range totalResults {
-		if !success {
-			failures++
-		}
-	}
-
-	return float64(failures) / float64(len(totalResults))
+	failureRate, _ := m.channelFailureRateLocked(baseURL, activeKeys)
+	return failureRate
 }
 
 // GetKeyMetrics 获取单个 Key 的指标
@@ -854,7 +946,7 @@ func (m *MetricsManager) GetKeyMetrics(baseURL, apiKey string) *KeyMetrics {
 	metricsKey := generateMetricsKey(baseURL, apiKey)
 	if metrics, exists := m.keyMetrics[metricsKey]; exists {
 		// 返回副本
-		return &KeyMetrics{
+		copied := &KeyMetrics{
 			MetricsKey:          metrics.MetricsKey,
 			BaseURL:             metrics.BaseURL,
 			KeyMask:             metrics.KeyMask,
@@ -865,7 +957,11 @@ func (m *MetricsManager) GetKeyMetrics(baseURL, apiKey string) *KeyMetrics {
 			LastSuccessAt:       metrics.LastSuccessAt,
 			LastFailureAt:       metrics.LastFailureAt,
 			CircuitBrokenAt:     metrics.CircuitBrokenAt,
+			SuspendedUntil:      metrics.SuspendedUntil,
+			ErrorBreakdown:      copyErrorBreakdown(metrics.ErrorBreakdown),
 		}
+		copied.ActiveRequests.Store(metrics.ActiveRequests.Load())
+		return copied
 	}
 	return nil
 }
@@ -1095,7 +1191,7 @@ func (m *MetricsManager) GetAllKeyMetrics() []*KeyMetrics {
 
 	result := make([]*KeyMetrics, 0, len(m.keyMetrics))
 	for _, metrics := range m.keyMetrics {
-		result = append(result, &KeyMetrics{
+		copied := &KeyMetrics{
 			MetricsKey:          metrics.MetricsKey,
 			BaseURL:             metrics.BaseURL,
 			KeyMask:             metrics.KeyMask,
@@ -1106,7 +1202,9 @@ func (m *MetricsManager) GetAllKeyMetrics() []*KeyMetrics {
 			LastSuccessAt:       metrics.LastSuccessAt,
 			LastFailureAt:       metrics.LastFailureAt,
 			CircuitBrokenAt:     metrics.CircuitBrokenAt,
-		})
+		}
+		copied.ActiveRequests.Store(metrics.ActiveRequests.Load())
+		result = append(result, copied)
 	}
 	return result
 }
@@ -1159,6 +1257,84 @@ func (m *MetricsManager) GetAllTimeWindowStatsForKey(baseURL, apiKey string) map
 	}
 }
 
+// GetKeyTodayTokens 统计 Key 今日（本地零点起）在所有 BaseURL 上消耗的 token 数（输入 + 输出）
+// 读取随请求记录增量维护的今日用量，不遍历请求历史
+func (m *MetricsManager) GetKeyTodayTokens(baseURLs []string, apiKey string) int64 {
+	day := startOfDay(time.Now())
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var total int64
+	for _, baseURL := range baseURLs {
+		if metrics, exists := m.keyMetrics[generateMetricsKey(baseURL, apiKey)]; exists && metrics.todayTokensDay.Equal(day) {
+			total += metrics.todayTokens
+		}
+	}
+	return total
+}
+
+// IsKeyOverBudget 检查 Key 今日用量是否已达到每日 token 预算（budget <= 0 表示不限制）
+// 仅统计本地零点之后的请求记录，跨天后自动恢复
+func (m *MetricsManager) IsKeyOverBudget(baseURLs []string, apiKey string, budget int64) bool {
+	if budget <= 0 {
+		return false
+	}
+	return m.GetKeyTodayTokens(baseURLs, apiKey) >= budget
+}
+
+// startOfDay 返回 t 所在本地日期的零点
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// addTodayTokensLocked 将一条请求记录的 token 计入 Key 的今日用量（早于今日零点的记录不计入）
+// 注意：调用方需要持有写锁。
+func addTodayTokensLocked(metrics *KeyMetrics, timestamp time.Time, tokens int64) {
+	if tokens == 0 {
+		return
+	}
+	day := startOfDay(time.Now())
+	if timestamp.Before(day) {
+		return
+	}
+	if !metrics.todayTokensDay.Equal(day) {
+		metrics.todayTokens = 0
+		metrics.todayTokensDay = day
+	}
+	metrics.todayTokens += tokens
+}
+
+// SuspendKeyUntil 按上游 429 响应的 Retry-After 暂停 Key 到指定时间（仅延长，不缩短已有的暂停期）
+func (m *MetricsManager) SuspendKeyUntil(baseURL, apiKey string, until time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics := m.getOrCreateKey(baseURL, apiKey)
+	if metrics.SuspendedUntil != nil && metrics.SuspendedUntil.After(until) {
+		return
+	}
+	metrics.SuspendedUntil = &until
+	log.Printf("[Metrics-RateLimit] Key [%s] (%s) 被上游限流，暂停至 %s", metrics.KeyMask, metrics.BaseURL, until.Format(time.RFC3339))
+}
+
+// IsKeyRateLimited 检查 Key 是否处于上游 Retry-After 指定的暂停期内
+func (m *MetricsManager) IsKeyRateLimited(baseURL, apiKey string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	metrics, exists := m.keyMetrics[generateMetricsKey(baseURL, apiKey)]
+	if !exists {
+		return false
+	}
+	return isKeyRateLimitedLocked(metrics, time.Now())
+}
+
+// isKeyRateLimitedLocked 判断暂停期是否未结束（调用方需持有锁）
+func isKeyRateLimitedLocked(metrics *KeyMetrics, now time.Time) bool {
+	return metrics.SuspendedUntil != nil && now.Before(*metrics.SuspendedUntil)
+}
+
 // ResetKeyFailureState 重置单个 Key 的熔断/失败状态（保留历史统计与总量计数）。
 // 用于“恢复熔断”场景：清零连续失败、清空滑动窗口、解除熔断标记。
 func (m *MetricsManager) ResetKeyFailureState(baseURL, apiKey string) {
@@ -1169,7 +1345,11 @@ func (m *MetricsManager) ResetKeyFailureState(baseURL, apiKey string) {
 	if metrics, exists := m.keyMetrics[metricsKey]; exists {
 		metrics.ConsecutiveFailures = 0
 		metrics.recentResults = make([]bool, 0, m.windowSize)
-		metrics.CircuitBrokenAt = nil
+		if metrics.CircuitBrokenAt != nil {
+			metrics.CircuitBrokenAt = nil
+			recordCircuitEventLocked(metrics, false, time.Now())
+		}
+		metrics.SuspendedUntil = nil
 		log.Printf("[Metrics-Reset] Key [%s] (%s) 熔断状态已重置（保留历史统计）", metrics.KeyMask, metrics.BaseURL)
 	}
 }
@@ -1186,12 +1366,19 @@ func (m *MetricsManager) ResetKey(baseURL, apiKey string) {
 		metrics.SuccessCount = 0
 		metrics.FailureCount = 0
 		metrics.ConsecutiveFailures = 0
-		metrics.ActiveRequests = 0
+		metrics.ActiveRequests.Store(0)
 		metrics.LastSuccessAt = nil
 		metrics.LastFailureAt = nil
 		metrics.CircuitBrokenAt = nil
+		metrics.SuspendedUntil = nil
+		metrics.ErrorBreakdown = nil
+		metrics.circuitEvents = nil
 		metrics.recentResults = make([]bool, 0, m.windowSize)
 		metrics.requestHistory = nil
+		metrics.expiredSuccessCount = 0
+		metrics.expiredFailureCount = 0
+		metrics.todayTokens = 0
+		metrics.todayTokensDay = time.Time{}
 		if metrics.pendingHistoryIdx != nil {
 			for id := range metrics.pendingHistoryIdx {
 				delete(metrics.pendingHistoryIdx, id)
@@ -1211,7 +1398,29 @@ func (m *MetricsManager) ResetAll() {
 
 // Stop 停止后台清理任务
 func (m *MetricsManager) Stop() {
-	close(m.stopCh)
+	m.stopOnce.Do(func() { close(m.stopCh) })
+}
+
+// Shutdown 停止后台任务并等待其退出，随后将持久化存储中缓冲的记录刷入存储
+// 在 ctx 截止前未完成时返回 ctx.Err()（刷新仍会在后台继续进行）
+func (m *MetricsManager) Shutdown(ctx context.Context) error {
+	m.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		m.bgWg.Wait()
+		if m.store != nil {
+			m.store.Flush()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // DeleteKeysForChannel 删除指定渠道的所有内存指标
@@ -1312,19 +1521,21 @@ func (m *MetricsManager) DeleteByMetricsKeys(metricsKeys []string) int64 {
 
 // cleanupCircuitBreakers 后台任务：定期检查并恢复超时的熔断 Key，清理过期指标
 func (m *MetricsManager) cleanupCircuitBreakers() {
+	defer m.bgWg.Done()
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
-	// 每小时清理一次过期 Key
-	cleanupTicker := time.NewTicker(1 * time.Hour)
-	defer cleanupTicker.Stop()
+	// 约每小时清理一次过期 Key（带随机抖动，避免多个实例同时清理）
+	cleanupTimer := time.NewTimer(m.nextStaleKeyCleanupDelay())
+	defer cleanupTimer.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
 			m.recoverExpiredCircuitBreakers()
-		case <-cleanupTicker.C:
+		case <-cleanupTimer.C:
 			m.cleanupStaleKeys()
+			cleanupTimer.Reset(m.nextStaleKeyCleanupDelay())
 		case <-m.stopCh:
 			return
 		}
@@ -1340,50 +1551,23 @@ func (m *MetricsManager) recoverExpiredCircuitBreakers() {
 	for _, metrics := range m.keyMetrics {
 		if metrics.CircuitBrokenAt != nil {
 			elapsed := now.Sub(*metrics.CircuitBrokenAt)
-			if elapsed > m.circuitRecoveryTime {
+			recoveryTime := m.recoveryTimeFor(metrics.BaseURL)
+			if elapsed > recoveryTime {
 				// 重置熔断状态
 				metrics.ConsecutiveFailures = 0
 				metrics.recentResults = make([]bool, 0, m.windowSize)
 				metrics.CircuitBrokenAt = nil
-				log.Printf("[Metrics-Circuit] Key [%s] (%s) 熔断自动恢复（已超过 %v）", metrics.KeyMask, metrics.BaseURL, m.circuitRecoveryTime)
+				recordCircuitEventLocked(metrics, false, now)
+				log.Printf("[Metrics-Circuit] Key [%s] (%s) 熔断自动恢复（已超过 %v）", metrics.KeyMask, metrics.BaseURL, recoveryTime)
 			}
 		}
 	}
 }
 
-// cleanupStaleKeys 清理过期的 Key 指标（超过 48 小时无活动）
-func (m *MetricsManager) cleanupStaleKeys() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	now := time.Now()
-	staleThreshold := 48 * time.Hour
-	var removed []string
-
-	for key, metrics := range m.keyMetrics {
-		// 判断最后活动时间
-		var lastActivity time.Time
-		if metrics.LastSuccessAt != nil {
-			lastActivity = *metrics.LastSuccessAt
-		}
-		if metrics.LastFailureAt != nil && metrics.LastFailureAt.After(lastActivity) {
-			lastActivity = *metrics.LastFailureAt
-		}
-
-		// 如果从未有活动或超过阈值，删除
-		if lastActivity.IsZero() || now.Sub(lastActivity) > staleThreshold {
-			delete(m.keyMetrics, key)
-			removed = append(removed, metrics.KeyMask)
-		}
-	}
-
-	if len(removed) > 0 {
-		log.Printf("[Metrics-Cleanup] 清理了 %d 个过期 Key 指标: %v", len(removed), removed)
-	}
-}
-
 // GetCircuitRecoveryTime 获取熔断恢复时间
 func (m *MetricsManager) GetCircuitRecoveryTime() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.circuitRecoveryTime
 }
 
@@ -1401,14 +1585,15 @@ func (m *MetricsManager) GetWindowSize() int {
 
 // MetricsResponse API 响应结构
 // 使用 omitempty 减少 JSON 体积，0 值字段不输出
-// 注意：successRate/errorRate 不使用 omitempty，因为 0% 是有意义的值
+// 注意：successRate/errorRate 不使用 omitempty，因为 0% 是有意义的值；样本数低于最小样本数时为 null
 type MetricsResponse struct {
 	ChannelIndex        int                        `json:"channelIndex"`
 	RequestCount        int64                      `json:"requestCount,omitempty"`
 	SuccessCount        int64                      `json:"successCount,omitempty"`
 	FailureCount        int64                      `json:"failureCount,omitempty"`
-	SuccessRate         float64                    `json:"successRate"`
-	ErrorRate           float64                    `json:"errorRate"`
+	SuccessRate         *float64                   `json:"successRate"`
+	ErrorRate           *float64                   `json:"errorRate"`
+	InsufficientData    bool                       `json:"insufficientData,omitempty"` // 最近样本数低于最小样本数，成功率/失败率为 null
 	ConsecutiveFailures int64                      `json:"consecutiveFailures,omitempty"`
 	ActiveRequests      int64                      `json:"activeRequests,omitempty"` // 进行中请求数
 	Latency             int64                      `json:"latency,omitempty"`
@@ -1416,36 +1601,70 @@ type MetricsResponse struct {
 	LastFailureAt       *string                    `json:"lastFailureAt,omitempty"`
 	CircuitBrokenAt     *string                    `json:"circuitBrokenAt,omitempty"`
 	TimeWindows         map[string]TimeWindowStats `json:"timeWindows,omitempty"`
-	KeyMetrics          []*KeyMetricsResponse      `json:"keyMetrics,omitempty"` // 各 Key 的详细指标
+	Uptime              map[string]float64         `json:"uptime,omitempty"`          // 分时段可用率 0-1（至少一个 Key 未熔断的时间占比）
+	ActivityProfile     *ChannelActivityProfile    `json:"activityProfile,omitempty"` // 近 24 小时流量分布画像
+	KeyMetrics          []*KeyMetricsResponse      `json:"keyMetrics,omitempty"`      // 各 Key 的详细指标
 }
 
 // KeyMetricsResponse 单个 Key 的 API 响应
 // 使用 omitempty 减少 JSON 体积，0 值字段不输出
-// 注意：successRate 不使用 omitempty，因为 0% 是有意义的值
+// 注意：successRate 不使用 omitempty，因为 0% 是有意义的值；请求数低于最小样本数时为 null
 type KeyMetricsResponse struct {
-	KeyMask             string  `json:"keyMask"`
-	RequestCount        int64   `json:"requestCount,omitempty"`
-	SuccessCount        int64   `json:"successCount,omitempty"`
-	FailureCount        int64   `json:"failureCount,omitempty"`
-	SuccessRate         float64 `json:"successRate"`
-	ConsecutiveFailures int64   `json:"consecutiveFailures,omitempty"`
-	CircuitBroken       bool    `json:"circuitBroken,omitempty"`
+	KeyMask             string   `json:"keyMask"`
+	RequestCount        int64    `json:"requestCount,omitempty"`
+	SuccessCount        int64    `json:"successCount,omitempty"`
+	FailureCount        int64    `json:"failureCount,omitempty"`
+	SuccessRate         *float64 `json:"successRate"`
+	InsufficientData    bool     `json:"insufficientData,omitempty"` // 请求数低于最小样本数，成功率为 null
+	ConsecutiveFailures int64    `json:"consecutiveFailures,omitempty"`
+	CircuitBroken       bool     `json:"circuitBroken,omitempty"`
+	// 按错误类型的失败计数（401/429/5xx/timeout/invalid_response）
+	ErrorBreakdown map[string]int64 `json:"errorBreakdown,omitempty"`
+	// 近 24 小时成功请求的平均 token 数（用于发现请求规模突然异常的 Key）
+	AvgInputTokens  float64 `json:"avgInputTokens,omitempty"`
+	AvgOutputTokens float64 `json:"avgOutputTokens,omitempty"`
+}
+
+// keyTokenSums 单个 Key 在历史窗口内成功请求的 token 合计（失败与进行中的请求没有 usage，不参与平均）
+type keyTokenSums struct {
+	requests     int64
+	inputTokens  int64
+	outputTokens int64
+}
+
+func (s *keyTokenSums) add(other keyTokenSums) {
+	s.requests += other.requests
+	s.inputTokens += other.inputTokens
+	s.outputTokens += other.outputTokens
+}
+
+// averages 返回每请求平均输入/输出 token 数
+func (s keyTokenSums) averages() (avgInput, avgOutput float64) {
+	if s.requests == 0 {
+		return 0, 0
+	}
+	return float64(s.inputTokens) / float64(s.requests), float64(s.outputTokens) / float64(s.requests)
+}
+
+// historyTokenSumsLocked 统计 Key 近 24 小时的成功请求 token 合计（调用方需持有读锁）
+func historyTokenSumsLocked(metrics *KeyMetrics) keyTokenSums {
+	cutoff := time.Now().Add(-24 * time.Hour)
+	var sums keyTokenSums
+	for _, record := range metrics.requestHistory {
+		if !record.Success || record.Timestamp.Before(cutoff) {
+			continue
+		}
+		sums.requests++
+		sums.inputTokens += record.InputTokens
+		sums.outputTokens += record.OutputTokens
+	}
+	return sums
 }
 
 // ToResponseMultiURL 转换为 API 响应格式（支持多 BaseURL 聚合）
 // baseURLs: 渠道配置的所有 BaseURL（用于多端点 failover 场景）
 // historicalKeys: 历史 API Key（用于统计聚合，只计入总数不显示在 KeyMetrics 中）
 func (m *MetricsManager) ToResponseMultiURL(channelIndex int, baseURLs []string, activeKeys []string, latency int64, historicalKeys ...[]string) *MetricsResponse {
-	// 如果没有配置 BaseURL，返回空响应
-	if len(baseURLs) == 0 {
-		return &MetricsResponse{
-			ChannelIndex: channelIndex,
-			Latency:      latency,
-			SuccessRate:  100,
-			ErrorRate:    0,
-		}
-	}
-
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -1454,9 +1673,9 @@ func (m *MetricsManager) ToResponseMultiURL(channelIndex int, baseURLs []string,
 		Latency:      latency,
 	}
 
-	if len(activeKeys) == 0 {
-		resp.SuccessRate = 100
-		resp.ErrorRate = 0
+	// 没有配置 BaseURL 或 Key 时返回空响应
+	if len(baseURLs) == 0 || len(activeKeys) == 0 {
+		m.setRatesLocked(resp, nil)
 		return resp
 	}
 
@@ -1468,6 +1687,8 @@ type keyAggregation struct {
 		failureCount        int64
 		consecutiveFailures int64
 		circuitBroken       bool
+		errorBreakdown      map[string]int64
+		tokenSums           keyTokenSums
 	}
 	keyAggMap := make(map[string]*keyAggregation) // key: apiKey
 
@@ -1483,7 +1704,7 @@ type keyAggregation struct {
 				resp.RequestCount += metrics.RequestCount
 				resp.SuccessCount += metrics.SuccessCount
 				resp.FailureCount += metrics.FailureCount
-				resp.ActiveRequests += metrics.ActiveRequests
+				resp.ActiveRequests += metrics.ActiveRequests.Load()
 				if metrics.ConsecutiveFailures > maxConsecutiveFailures {
 					maxConsecutiveFailures = metrics.ConsecutiveFailures
 				}
@@ -1511,6 +1732,13 @@ type keyAggregation struct {
 					if metrics.CircuitBrokenAt != nil {
 						agg.circuitBroken = true
 					}
+					for errorType, count := range metrics.ErrorBreakdown {
+						if agg.errorBreakdown == nil {
+							agg.errorBreakdown = make(map[string]int64)
+						}
+						agg.errorBreakdown[errorType] += count
+					}
+					agg.tokenSums.add(historyTokenSumsLocked(metrics))
 				} else {
 					keyAggMap[apiKey] = &keyAggregation{
 						keyMask:             metrics.KeyMask,
@@ -1519,6 +1747,8 @@ type keyAggregation struct {
 						failureCount:        metrics.FailureCount,
 						consecutiveFailures: metrics.ConsecutiveFailures,
 						circuitBroken:       metrics.CircuitBrokenAt != nil,
+						errorBreakdown:      copyErrorBreakdown(metrics.ErrorBreakdown),
+						tokenSums:           historyTokenSumsLocked(metrics),
 					}
 				}
 			}
@@ -1545,39 +1775,27 @@ type keyAggregation struct {
 	var keyResponses []*KeyMetricsResponse
 	for _, apiKey := range activeKeys {
 		if agg, ok := keyAggMap[apiKey]; ok {
-			keySuccessRate := float64(100)
-			if agg.requestCount > 0 {
-				keySuccessRate = float64(agg.successCount) / float64(agg.requestCount) * 100
-			}
+			keySuccessRate, keyInsufficient := m.keySuccessRateLocked(agg.successCount, agg.requestCount)
+			avgInput, avgOutput := agg.tokenSums.averages()
 			keyResponses = append(keyResponses, &KeyMetricsResponse{
 				KeyMask:             agg.keyMask,
 				RequestCount:        agg.requestCount,
 				SuccessCount:        agg.successCount,
 				FailureCount:        agg.failureCount,
 				SuccessRate:         keySuccessRate,
+				InsufficientData:    keyInsufficient,
 				ConsecutiveFailures: agg.consecutiveFailures,
 				CircuitBroken:       agg.circuitBroken,
+				ErrorBreakdown:      agg.errorBreakdown,
+				AvgInputTokens:      avgInput,
+				AvgOutputTokens:     avgOutput,
 			})
 		}
 	}
 
 	// 计算聚合失败率
 	resp.ConsecutiveFailures = maxConsecutiveFailures
-
-	if len(totalResults) > 0 {
-		failures := 0
-		for _, success := range totalResults {
-			if !success {
-				failures++
-			}
-		}
-		failureRate := float64(failures) / float64(len(totalResults))
-		resp.SuccessRate = (1 - failureRate) * 100
-		resp.ErrorRate = failureRate * 100
-	} else {
-		resp.SuccessRate = 100
-		resp.ErrorRate = 0
-	}
+	m.setRatesLocked(resp, totalResults)
 
 	if latestSuccess != nil {
 		t := latestSuccess.Format(time.RFC3339)
@@ -1596,6 +1814,9 @@ type keyAggregation struct {
 
 	// 计算聚合的时间窗口统计（多 URL 版本）
 	resp.TimeWindows = m.calculateAggregatedTimeWindowsMultiURL(baseURLs, activeKeys)
+	resp.Uptime = m.channelUptimeWindowsLocked(baseURLs, activeKeys)
+	profile := m.channelActivityProfileLocked(baseURLs, activeKeys, 24*time.Hour, time.Now())
+	resp.ActivityProfile = &profile
 
 	return resp
 }
@@ -1611,8 +1832,7 @@ func (m *MetricsManager) ToResponse(channelIndex int, baseURL string, activeKeys
 	}
 
 	if len(activeKeys) == 0 {
-		resp.SuccessRate = 100
-		resp.ErrorRate = 0
+		m.setRatesLocked(resp, nil)
 		return resp
 	}
 
@@ -1627,7 +1847,7 @@ func (m *MetricsManager) ToResponse(channelIndex int, baseURL string, activeKeys
 			resp.RequestCount += metrics.RequestCount
 			resp.SuccessCount += metrics.SuccessCount
 			resp.FailureCount += metrics.FailureCount
-			resp.ActiveRequests += metrics.ActiveRequests
+			resp.ActiveRequests += metrics.ActiveRequests.Load()
 			if metrics.ConsecutiveFailures > maxConsecutiveFailures {
 				maxConsecutiveFailures = metrics.ConsecutiveFailures
 			}
@@ -1645,39 +1865,27 @@ func (m *MetricsManager) ToResponse(channelIndex int, baseURL string, activeKeys
 			}
 
 			// 单个 Key 的指标
-			keySuccessRate := float64(100)
-			if metrics.RequestCount > 0 {
-				keySuccessRate = float64(metrics.SuccessCount) / float64(metrics.RequestCount) * 100
-			}
+			keySuccessRate, keyInsufficient := m.keySuccessRateLocked(metrics.SuccessCount, metrics.RequestCount)
+			avgInput, avgOutput := historyTokenSumsLocked(metrics).averages()
 			keyResponses = append(keyResponses, &KeyMetricsResponse{
 				KeyMask:             metrics.KeyMask,
 				RequestCount:        metrics.RequestCount,
 				SuccessCount:        metrics.SuccessCount,
 				FailureCount:        metrics.FailureCount,
 				SuccessRate:         keySuccessRate,
+				InsufficientData:    keyInsufficient,
 				ConsecutiveFailures: metrics.ConsecutiveFailures,
 				CircuitBroken:       metrics.CircuitBrokenAt != nil,
+				ErrorBreakdown:      copyErrorBreakdown(metrics.ErrorBreakdown),
+				AvgInputTokens:      avgInput,
+				AvgOutputTokens:     avgOutput,
 			})
 		}
 	}
 
 	// 计算聚合失败率
 	resp.ConsecutiveFailures = maxConsecutiveFailures
-
-	if len(totalResults) > 0 {
-		failures := 0
-		for _, success := range totalResults {
-			if !success {
-				failures++
-			}
-		}
-		failureRate := float64(failures) / float64(len(totalResults))
-		resp.SuccessRate = (1 - failureRate) * 100
-		resp.ErrorRate = failureRate * 100
-	} else {
-		resp.SuccessRate = 100
-		resp.ErrorRate = 0
-	}
+	m.setRatesLocked(resp, totalResults)
 
 	if latestSuccess != nil {
 		t := latestSuccess.Format(time.RFC3339)
@@ -1696,6 +1904,9 @@ func (m *MetricsManager) ToResponse(channelIndex int, baseURL string, activeKeys
 
 	// 计算聚合的时间窗口统计
 	resp.TimeWindows = m.calculateAggregatedTimeWindowsInternal(baseURL, activeKeys)
+	resp.Uptime = m.channelUptimeWindowsLocked([]string{baseURL}, activeKeys)
+	profile := m.channelActivityProfileLocked([]string{baseURL}, activeKeys, 24*time.Hour, time.Now())
+	resp.ActivityProfile = &profile
 
 	return resp
 }
@@ -1715,7 +1926,7 @@ func (m *MetricsManager) calculateAggregatedTimeWindowsInternal(baseURL string,
 	for label, duration := range windows {
 		cutoff := now.Add(-duration)
 		var requestCount, successCount, failureCount int64
-		var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int64
+		var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens, thinkingTokens int64
 
 		for _, apiKey := range activeKeys {
 			metricsKey := generateMetricsKey(baseURL, apiKey)
@@ -1732,6 +1943,7 @@ func (m *MetricsManager) calculateAggregatedTimeWindowsInternal(baseURL string,
 						outputTokens += record.OutputTokens
 						cacheCreationTokens += record.CacheCreationInputTokens
 						cacheReadTokens += record.CacheReadInputTokens
+						thinkingTokens += record.ThinkingTokens
 					}
 				}
 			}
@@ -1757,6 +1969,7 @@ func (m *MetricsManager) calculateAggregatedTimeWindowsInternal(baseURL string,
 			OutputTokens:        outputTokens,
 			CacheCreationTokens: cacheCreationTokens,
 			CacheReadTokens:     cacheReadTokens,
+			ThinkingTokens:      thinkingTokens,
 			CacheHitRate:        cacheHitRate,
 		}
 	}
@@ -1779,7 +1992,7 @@ func (m *MetricsManager) calculateAggregatedTimeWindowsMultiURL(baseURLs []strin
 	for label, duration := range windows {
 		cutoff := now.Add(-duration)
 		var requestCount, successCount, failureCount int64
-		var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int64
+		var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens, thinkingTokens int64
 
 		// 遍历所有 BaseURL 和 Key 的组合
 		for _, baseURL := range baseURLs {
@@ -1798,6 +2011,7 @@ func (m *MetricsManager) calculateAggregatedTimeWindowsMultiURL(baseURLs []strin
 							outputTokens += record.OutputTokens
 							cacheCreationTokens += record.CacheCreationInputTokens
 							cacheReadTokens += record.CacheReadInputTokens
+							thinkingTokens += record.ThinkingTokens
 						}
 					}
 				}
@@ -1824,6 +2038,7 @@ func (m *MetricsManager) calculateAggregatedTimeWindowsMultiURL(baseURLs []strin
 			OutputTokens:        outputTokens,
 			CacheCreationTokens: cacheCreationTokens,
 			CacheReadTokens:     cacheReadTokens,
+			ThinkingTokens:      thinkingTokens,
 			CacheHitRate:        cacheHitRate,
 		}
 	}
@@ -1897,13 +2112,18 @@ func (m *MetricsManager) ShouldSuspendKey(baseURL, apiKey string) bool {
 		return false
 	}
 
+	// 上游 Retry-After 指定的暂停期内直接跳过
+	if isKeyRateLimitedLocked(metrics, time.Now()) {
+		return true
+	}
+
 	// 最小请求数保护：至少 max(3, windowSize/2) 次请求才判断
 	minRequests := max(3, m.windowSize/2)
 	if len(metrics.recentResults) < minRequests {
 		return false
 	}
 
-	return m.calculateKeyFailureRateInternal(metrics) >= m.failureThreshold
+	return m.calculateKeyFailureRateInternal(metrics) >= m.keyFailureThreshold(metrics)
 }
 
 // ============ 历史数据查询方法（用于图表可视化）============
@@ -1928,6 +2148,7 @@ type KeyHistoryDataPoint struct {
 	OutputTokens             int64     `json:"outputTokens"`
 	CacheCreationInputTokens int64     `json:"cacheCreationTokens"`
 	CacheReadInputTokens     int64     `json:"cacheReadTokens"`
+	ThinkingTokens           int64     `json:"thinkingTokens"`
 }
 
 // GetHistoricalStats 获取历史统计数据（按时间间隔聚合）
@@ -2093,9 +2314,6 @@ func (m *MetricsManager) GetAllKeysHistoricalStats(duration, interval time.Durat
 		return []HistoryDataPoint{}
 	}
 
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
 	now := time.Now()
 	// 时间对齐到 interval 边界
 	startTime := now.Add(-duration).Truncate(interval)
@@ -2114,20 +2332,18 @@ func (m *MetricsManager) GetAllKeysHistoricalStats(duration, interval time.Durat
 		buckets[int64(i)] = &bucketData{}
 	}
 
-	// 收集所有 Key 的请求历史并放入对应桶
-	for _, metrics := range m.keyMetrics {
-		for _, record := range metrics.requestHistory {
-			// 使用 [startTime, endTime) 的区间，避免 endTime 处 offset 越界
-			if !record.Timestamp.Before(startTime) && record.Timestamp.Before(endTime) {
-				offset := int64(record.Timestamp.Sub(startTime) / interval)
-				if offset >= 0 && offset < int64(numPoints) {
-					b := buckets[offset]
-					b.requestCount++
-					if record.Success {
-						b.successCount++
-					} else {
-						b.failureCount++
-					}
+	// 收集所有 Key 的请求历史快照并放入对应桶（解锁后聚合）
+	for _, record := range m.snapshotHistory(startTime) {
+		// 使用 [startTime, endTime) 的区间，避免 endTime 处 offset 越界
+		if !record.Timestamp.Before(startTime) && record.Timestamp.Before(endTime) {
+			offset := int64(record.Timestamp.Sub(startTime) / interval)
+			if offset >= 0 && offset < int64(numPoints) {
+				b := buckets[offset]
+				b.requestCount++
+				if record.Success {
+					b.successCount++
+				} else {
+					b.failureCount++
 				}
 			}
 		}
@@ -2214,6 +2430,7 @@ func (m *MetricsManager) GetKeyHistoricalStats(baseURL, apiKey string, duration,
 				b.outputTokens += record.OutputTokens
 				b.cacheCreationTokens += record.CacheCreationInputTokens
 				b.cacheReadTokens += record.CacheReadInputTokens
+				b.thinkingTokens += record.ThinkingTokens
 			}
 		}
 	}
@@ -2237,6 +2454,7 @@ func (m *MetricsManager) GetKeyHistoricalStats(baseURL, apiKey string, duration,
 			OutputTokens:             b.outputTokens,
 			CacheCreationInputTokens: b.cacheCreationTokens,
 			CacheReadInputTokens:     b.cacheReadTokens,
+			ThinkingTokens:           b.thinkingTokens,
 		}
 	}
 
@@ -2299,6 +2517,7 @@ func (m *MetricsManager) GetKeyHistoricalStatsMultiURL(baseURLs []string, apiKey
 					b.outputTokens += record.OutputTokens
 					b.cacheCreationTokens += record.CacheCreationInputTokens
 					b.cacheReadTokens += record.CacheReadInputTokens
+					b.thinkingTokens += record.ThinkingTokens
 				}
 			}
 		}
@@ -2334,6 +2553,7 @@ func (m *MetricsManager) GetKeyHistoricalStatsMultiURL(baseURLs []string, apiKey
 			OutputTokens:             b.outputTokens,
 			CacheCreationInputTokens: b.cacheCreationTokens,
 			CacheReadInputTokens:     b.cacheReadTokens,
+			ThinkingTokens:           b.thinkingTokens,
 		}
 	}
 
@@ -2438,6 +2658,7 @@ type keyBucketData struct {
 	outputTokens        int64
 	cacheCreationTokens int64
 	cacheReadTokens     int64
+	thinkingTokens      int64
 }
 
 // ============ 全局统计数据结构和方法（用于全局流量统计图表）============
@@ -2453,6 +2674,7 @@ type GlobalHistoryDataPoint struct {
 	OutputTokens        int64     `json:"outputTokens"`
 	CacheCreationTokens int64     `json:"cacheCreationTokens"`
 	CacheReadTokens     int64     `json:"cacheReadTokens"`
+	ThinkingTokens      int64     `json:"thinkingTokens"`
 }
 
 // GlobalStatsSummary 全局统计汇总
@@ -2464,6 +2686,7 @@ type GlobalStatsSummary struct {
 	TotalOutputTokens        int64   `json:"totalOutputTokens"`
 	TotalCacheCreationTokens int64   `json:"totalCacheCreationTokens"`
 	TotalCacheReadTokens     int64   `json:"totalCacheReadTokens"`
+	TotalThinkingTokens      int64   `json:"totalThinkingTokens"`
 	AvgSuccessRate           float64 `json:"avgSuccessRate"`
 	Duration                 string  `json:"duration"`
 }
@@ -2486,9 +2709,6 @@ func (m *MetricsManager) GetGlobalHistoricalStatsWithTokens(duration, interval t
 		}
 	}
 
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
 	now := time.Now()
 	// 时间对齐到 interval 边界
 	startTime := now.Add(-duration).Truncate(interval)
@@ -2509,7 +2729,7 @@ func (m *MetricsManager) GetGlobalHistoricalStatsWithTokens(duration, interval t
 
 	// 汇总统计
 	var totalRequests, totalSuccess, totalFailure int64
-	var totalInputTokens, totalOutputTokens, totalCacheCreation, totalCacheRead int64
+	var totalInputTokens, totalOutputTokens, totalCacheCreation, totalCacheRead, totalThinking int64
 
 	// 按模型分桶（复用 modelBucket 结构）
 	type modelBucket struct {
@@ -2521,52 +2741,52 @@ type modelBucket struct {
 	}
 	modelBuckets := make(map[string][]modelBucket)
 
-	// 遍历所有 Key 的请求历史
-	for _, metrics := range m.keyMetrics {
-		for _, record := range metrics.requestHistory {
-			// 使用 Before(endTime) 排除恰好落在 endTime 的记录，避免 offset 越界
-			if record.Timestamp.After(startTime) && record.Timestamp.Before(endTime) {
-				offset := int64(record.Timestamp.Sub(startTime) / interval)
-				if offset >= 0 && offset < int64(numPoints) {
-					b := buckets[offset]
-					b.requestCount++
-					if record.Success {
-						b.successCount++
-					} else {
-						b.failureCount++
-					}
-					b.inputTokens += record.InputTokens
-					b.outputTokens += record.OutputTokens
-					b.cacheCreationTokens += record.CacheCreationInputTokens
-					b.cacheReadTokens += record.CacheReadInputTokens
+	// 遍历所有 Key 的请求历史快照（解锁后聚合）
+	for _, record := range m.snapshotHistory(startTime) {
+		// 使用 Before(endTime) 排除恰好落在 endTime 的记录，避免 offset 越界
+		if record.Timestamp.After(startTime) && record.Timestamp.Before(endTime) {
+			offset := int64(record.Timestamp.Sub(startTime) / interval)
+			if offset >= 0 && offset < int64(numPoints) {
+				b := buckets[offset]
+				b.requestCount++
+				if record.Success {
+					b.successCount++
+				} else {
+					b.failureCount++
+				}
+				b.inputTokens += record.InputTokens
+				b.outputTokens += record.OutputTokens
+				b.cacheCreationTokens += record.CacheCreationInputTokens
+				b.cacheReadTokens += record.CacheReadInputTokens
+				b.thinkingTokens += record.ThinkingTokens
 
-					// 累加汇总
-					totalRequests++
+				// 累加汇总
+				totalRequests++
+				if record.Success {
+					totalSuccess++
+				} else {
+					totalFailure++
+				}
+				totalInputTokens += record.InputTokens
+				totalOutputTokens += record.OutputTokens
+				totalCacheCreation += record.CacheCreationInputTokens
+				totalCacheRead += record.CacheReadInputTokens
+				totalThinking += record.ThinkingTokens
+
+				// 同时按模型分桶（跳过无模型信息的记录）
+				if model := record.Model; model != "" {
+					if _, ok := modelBuckets[model]; !ok {
+						modelBuckets[model] = make([]modelBucket, numPoints)
+					}
+					mb := &modelBuckets[model][offset]
+					mb.requestCount++
 					if record.Success {
-						totalSuccess++
+						mb.successCount++
 					} else {
-						totalFailure++
-					}
-					totalInputTokens += record.InputTokens
-					totalOutputTokens += record.OutputTokens
-					totalCacheCreation += record.CacheCreationInputTokens
-					totalCacheRead += record.CacheReadInputTokens
-
-					// 同时按模型分桶（跳过无模型信息的记录）
-					if model := record.Model; model != "" {
-						if _, ok := modelBuckets[model]; !ok {
-							modelBuckets[model] = make([]modelBucket, numPoints)
-						}
-						mb := &modelBuckets[model][offset]
-						mb.requestCount++
-						if record.Success {
-							mb.successCount++
-						} else {
-							mb.failureCount++
-						}
-						mb.inputTokens += record.InputTokens
-						mb.outputTokens += record.OutputTokens
+						mb.failureCount++
 					}
+					mb.inputTokens += record.InputTokens
+					mb.outputTokens += record.OutputTokens
 				}
 			}
 		}
@@ -2590,6 +2810,7 @@ type modelBucket struct {
 			OutputTokens:        b.outputTokens,
 			CacheCreationTokens: b.cacheCreationTokens,
 			CacheReadTokens:     b.cacheReadTokens,
+			ThinkingTokens:      b.thinkingTokens,
 		}
 	}
 
@@ -2607,6 +2828,7 @@ type modelBucket struct {
 		TotalOutputTokens:        totalOutputTokens,
 		TotalCacheCreationTokens: totalCacheCreation,
 		TotalCacheReadTokens:     totalCacheRead,
+		TotalThinkingTokens:      totalThinking,
 		AvgSuccessRate:           avgSuccessRate,
 		Duration:                 duration.String(),
 	}
@@ -2647,6 +2869,7 @@ type globalBucketData struct {
 	outputTokens        int64
 	cacheCreationTokens int64
 	cacheReadTokens     int64
+	thinkingTokens      int64
 }
 
 // CalculateTodayDuration 计算"今日"时间范围（从今天 0 点到现在）
@@ -2666,6 +2889,7 @@ type ActivitySegment struct {
 	FailureCount int64 `json:"failureCount,omitempty"`
 	InputTokens  int64 `json:"inputTokens,omitempty"`
 	OutputTokens int64 `json:"outputTokens,omitempty"`
+	TPMTokens    int64 `json:"tpmTokens,omitempty"` // 按 TPM 统计口径计入的 tokens
 }
 
 // ChannelRecentActivity 渠道最近活跃度数据
@@ -2678,6 +2902,20 @@ type ChannelRecentActivity struct {
 	TPM          float64                  `json:"tpm,omitempty"`      // 15分钟平均 TPM
 }
 
+// SetTPMIncludeThinking 设置 TPM 是否计入上游单独返回的思考 tokens
+func (m *MetricsManager) SetTPMIncludeThinking(include bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tpmIncludeThinking = include
+}
+
+// SetAPIType 设置接口类型（未启用持久化时也需要，用于快照与历史归档区分类型）
+func (m *MetricsManager) SetAPIType(apiType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.apiType = apiType
+}
+
 // GetRecentActivityMultiURL 获取渠道最近活跃度数据（支持多 URL 和多 Key 聚合）
 // 参数：
 //   - channelIndex: 渠道索引
@@ -2718,7 +2956,7 @@ func (m *MetricsManager) GetRecentActivityMultiURL(channelIndex int, baseURLs []
 	sparseSegments := make(map[int]*ActivitySegment)
 
 	// 汇总统计
-	var totalRequests, totalInputTokens, totalOutputTokens int64
+	var totalRequests, totalTPMTokens int64
 
 	// 遍历所有 BaseURL 和 Key 的组合
 	for _, baseURL := range baseURLs {
@@ -2757,20 +2995,22 @@ func (m *MetricsManager) GetRecentActivityMultiURL(channelIndex int, baseURLs []
 				}
 				seg.InputTokens += record.InputTokens
 				seg.OutputTokens += record.OutputTokens
+				tpmTokens := m.tpmTokensLocked(record)
+				seg.TPMTokens += tpmTokens
 
 				// 累加汇总
 				totalRequests++
-				totalInputTokens += record.InputTokens
-				totalOutputTokens += record.OutputTokens
+				totalTPMTokens += tpmTokens
 			}
 		}
 	}
 
 	// 计算 RPM 和 TPM（基于实际窗口时长）
-	// TPM 只计算输出 tokens（包含思考），不包含输入 tokens 和缓存 tokens
+	// TPM 默认只计算输出 tokens，可通过 SetTPMDefinition 计入输入 tokens 和缓存 tokens
+	// 上游单独返回思考 tokens 时，可通过 SetTPMIncludeThinking 将其计入 TPM
 	windowMinutes := float64(numSegments) * segmentDuration.Minutes()
 	rpm := float64(totalRequests) / windowMinutes
-	tpm := float64(totalOutputTokens) / windowMinutes
+	tpm := float64(totalTPMTokens) / windowMinutes
 
 	return &ChannelRecentActivity{
 		ChannelIndex: channelIndex,
@@ -2802,20 +3042,9 @@ func (m *MetricsManager) GetModelStatsHistory(duration, interval time.Duration)
 	endTime := now.Truncate(interval).Add(interval)
 	numPoints := int(duration/interval) + 1
 
-	// 快速拷贝 requestHistory 引用，缩短持锁时间
-	type historyRef struct {
-		history []RequestRecord
-	}
-	var historyRefs []historyRef
-
-	m.mu.RLock()
-	for _, metrics := range m.keyMetrics {
-		// 拷贝 slice 引用（底层数组共享，但遍历时不会修改）
-		historyRefs = append(historyRefs, historyRef{history: metrics.requestHistory})
-	}
-	m.mu.RUnlock()
+	// 持锁拷贝窗口内的记录，解锁后进行聚合计算
+	records := m.snapshotHistory(startTime)
 
-	// 解锁后进行聚合计算
 	// 按模型分组收集记录
 	type modelBucket struct {
 		requestCount int64
@@ -2827,32 +3056,30 @@ type modelBucket struct {
 	// model -> bucketIndex -> data
 	modelBuckets := make(map[string][]modelBucket)
 
-	for _, ref := range historyRefs {
-		for _, record := range ref.history {
-			if record.Timestamp.Before(startTime) || !record.Timestamp.Before(endTime) {
-				continue
-			}
-			model := record.Model
-			if model == "" {
-				continue // 跳过没有模型信息的记录
-			}
-			offset := int(record.Timestamp.Sub(startTime) / interval)
-			if offset < 0 || offset >= numPoints {
-				continue
-			}
-			if _, ok := modelBuckets[model]; !ok {
-				modelBuckets[model] = make([]modelBucket, numPoints)
-			}
-			b := &modelBuckets[model][offset]
-			b.requestCount++
-			if record.Success {
-				b.successCount++
-			} else {
-				b.failureCount++
-			}
-			b.inputTokens += record.InputTokens
-			b.outputTokens += record.OutputTokens
+	for _, record := range records {
+		if record.Timestamp.Before(startTime) || !record.Timestamp.Before(endTime) {
+			continue
+		}
+		model := record.Model
+		if model == "" {
+			continue // 跳过没有模型信息的记录
+		}
+		offset := int(record.Timestamp.Sub(startTime) / interval)
+		if offset < 0 || offset >= numPoints {
+			continue
+		}
+		if _, ok := modelBuckets[model]; !ok {
+			modelBuckets[model] = make([]modelBucket, numPoints)
+		}
+		b := &modelBuckets[model][offset]
+		b.requestCount++
+		if record.Success {
+			b.successCount++
+		} else {
+			b.failureCount++
 		}
+		b.inputTokens += record.InputTokens
+		b.outputTokens += record.OutputTokens
 	}
 
 	// 构建结果
@@ -2874,3 +3101,56 @@ type modelBucket struct {
 
 	return result
 }
+
+// ModelUsageSummary 模型级别的用量汇总（不分桶）
+type ModelUsageSummary struct {
+	Model                    string   `json:"model"`
+	RequestCount             int64    `json:"requestCount"`
+	SuccessCount             int64    `json:"successCount"`
+	FailureCount             int64    `json:"failureCount"`
+	SuccessRate              float64  `json:"successRate"`
+	InputTokens              int64    `json:"inputTokens"`
+	OutputTokens             int64    `json:"outputTokens"`
+	CacheCreationInputTokens int64    `json:"cacheCreationInputTokens"`
+	CacheReadInputTokens     int64    `json:"cacheReadInputTokens"`
+	ThinkingTokens           int64    `json:"thinkingTokens"`
+	EstimatedCost            *float64 `json:"estimatedCost,omitempty"` // 估算费用（USD，仅在配置了模型定价时返回）
+}
+
+// GetModelUsageSummary 获取指定时间窗口内按模型汇总的用量
+func (m *MetricsManager) GetModelUsageSummary(duration time.Duration) map[string]*ModelUsageSummary {
+	result := make(map[string]*ModelUsageSummary)
+	if duration <= 0 {
+		return result
+	}
+
+	cutoff := time.Now().Add(-duration)
+
+	for _, record := range m.snapshotHistory(cutoff) {
+		if !record.Timestamp.After(cutoff) || record.Model == "" {
+			continue
+		}
+		summary, ok := result[record.Model]
+		if !ok {
+			summary = &ModelUsageSummary{Model: record.Model}
+			result[record.Model] = summary
+		}
+		summary.RequestCount++
+		if record.Success {
+			summary.SuccessCount++
+		} else {
+			summary.FailureCount++
+		}
+		summary.InputTokens += record.InputTokens
+		summary.OutputTokens += record.OutputTokens
+		summary.CacheCreationInputTokens += record.CacheCreationInputTokens
+		summary.CacheReadInputTokens += record.CacheReadInputTokens
+		summary.ThinkingTokens += record.ThinkingTokens
+	}
+
+	for _, summary := range result {
+		summary.SuccessRate = float64(summary.SuccessCount) / float64(summary.RequestCount) * 100
+	}
+
+	return result
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestRecordRequestStartEnd_Concurrent(t *testing.T) {
+	m := NewMetricsManager()
+	defer m.Stop()
+
+	const (
+		goroutines = 64
+		iterations = 500
+	)
+	baseURLs := []string{"https://a.example.com", "https://b.example.com"}
+	keys := []string{"k1", "k2", "k3"}
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			baseURL := baseURLs[g%len(baseURLs)]
+			key := keys[g%len(keys)]
+			for i := 0; i < iterations; i++ {
+				m.RecordRequestStart(baseURL, key)
+				// 穿插读取，验证复制路径与计数更新并发安全
+				if i%50 == 0 {
+					m.GetKeyMetrics(baseURL, key)
+					m.GetAllKeyMetrics()
+				}
+				m.RecordRequestEnd(baseURL, key)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for _, baseURL := range baseURLs {
+		for _, key := range keys {
+			metrics := m.GetKeyMetrics(baseURL, key)
+			if metrics == nil {
+				t.Fatalf("GetKeyMetrics(%s, %s) = nil", baseURL, key)
+			}
+			if active := metrics.ActiveRequests.Load(); active != 0 {
+				t.Fatalf("GetKeyMetrics(%s, %s).ActiveRequests = %d, want 0", baseURL, key, active)
+			}
+		}
+	}
+
+	// 多余的结束调用不会使计数变为负数
+	m.RecordRequestEnd(baseURLs[0], keys[0])
+	if active := m.GetKeyMetrics(baseURLs[0], keys[0]).ActiveRequests.Load(); active != 0 {
+		t.Fatalf("ActiveRequests = %d after extra end, want 0", active)
+	}
+}
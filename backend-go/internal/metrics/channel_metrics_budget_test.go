@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/types"
+)
+
+func TestIsKeyOverBudget(t *testing.T) {
+	m := NewMetricsManagerWithConfig(10, 0.5)
+	defer m.Stop()
+
+	baseURLs := []string{"https://a.example.com", "https://b.example.com"}
+	key := "k1"
+
+	if m.IsKeyOverBudget(baseURLs, key, 1000) {
+		t.Fatalf("无请求记录时不应超出预算")
+	}
+
+	// 今日用量分布在两个 BaseURL 上，合计 1100 tokens
+	m.RecordSuccessWithUsage(baseURLs[0], key, &types.Usage{InputTokens: 300, OutputTokens: 300})
+	m.RecordSuccessWithUsage(baseURLs[1], key, &types.Usage{InputTokens: 200, OutputTokens: 300})
+
+	if got := m.GetKeyTodayTokens(baseURLs, key); got != 1100 {
+		t.Fatalf("GetKeyTodayTokens() = %d, want 1100", got)
+	}
+	if !m.IsKeyOverBudget(baseURLs, key, 1000) {
+		t.Fatalf("今日用量 1100 应超出预算 1000")
+	}
+	if m.IsKeyOverBudget(baseURLs, key, 2000) {
+		t.Fatalf("今日用量 1100 不应超出预算 2000")
+	}
+	if m.IsKeyOverBudget(baseURLs, key, 0) {
+		t.Fatalf("预算为 0 表示不限制")
+	}
+}
+
+func TestIsKeyOverBudget_ResetsOnNewDay(t *testing.T) {
+	m := NewMetricsManagerWithConfig(10, 0.5)
+	defer m.Stop()
+
+	baseURL := "https://example.com"
+	key := "k1"
+
+	// 昨天的用量不计入今日预算
+	yesterday := time.Now().Add(-24 * time.Hour)
+	m.mu.Lock()
+	m.recordSuccessWithUsageLocked(baseURL, key, &types.Usage{InputTokens: 5000, OutputTokens: 5000}, yesterday)
+	m.mu.Unlock()
+
+	if m.IsKeyOverBudget([]string{baseURL}, key, 1000) {
+		t.Fatalf("跨天后 Key 应恢复可用")
+	}
+
+	m.RecordSuccessWithUsage(baseURL, key, &types.Usage{InputTokens: 600, OutputTokens: 600})
+	if !m.IsKeyOverBudget([]string{baseURL}, key, 1000) {
+		t.Fatalf("今日用量 1200 应超出预算 1000")
+	}
+}
+
+// TestGetKeyTodayTokens_IncrementalPaths 连接即计数的 finalize 路径与 Key 轮换同样计入今日用量
+func TestGetKeyTodayTokens_IncrementalPaths(t *testing.T) {
+	m := NewMetricsManagerWithConfig(10, 0.5)
+	defer m.Stop()
+
+	baseURL := "https://example.com"
+	id := m.RecordRequestConnected(baseURL, "k1", "claude-test")
+	if got := m.GetKeyTodayTokens([]string{baseURL}, "k1"); got != 0 {
+		t.Fatalf("进行中请求不应计入今日用量, got %d", got)
+	}
+	m.RecordRequestFinalizeSuccess(baseURL, "k1", id, &types.Usage{InputTokens: 100, OutputTokens: 50})
+	if got := m.GetKeyTodayTokens([]string{baseURL}, "k1"); got != 150 {
+		t.Fatalf("GetKeyTodayTokens() = %d, want 150", got)
+	}
+
+	m.RecordSuccessWithUsage(baseURL, "k2", &types.Usage{InputTokens: 10, OutputTokens: 10})
+	m.CarryOverKey([]string{baseURL}, "k1", "k2")
+	if got := m.GetKeyTodayTokens([]string{baseURL}, "k2"); got != 170 {
+		t.Fatalf("轮换后 GetKeyTodayTokens() = %d, want 170", got)
+	}
+}
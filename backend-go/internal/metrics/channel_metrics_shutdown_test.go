@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestMetricsManager_ShutdownFlushesPersistence 关闭时缓冲区中尚未写入的记录应刷入存储
+func TestMetricsManager_ShutdownFlushesPersistence(t *testing.T) {
+	store, err := NewSQLiteStore(&SQLiteStoreConfig{
+		DBPath:        filepath.Join(t.TempDir(), "metrics.db"),
+		RetentionDays: 7,
+	})
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	defer store.Close()
+
+	m := NewMetricsManagerWithPersistence(10, 0.5, store, "messages")
+
+	// 记录数低于批量写入阈值，且远早于定时刷新周期，此时只存在于写入缓冲区
+	const records = 5
+	for i := 0; i < records; i++ {
+		if i%2 == 0 {
+			m.RecordSuccess("https://a.example.com", "sk-a")
+		} else {
+			m.RecordFailure("https://a.example.com", "sk-a")
+		}
+	}
+	if count, err := store.GetRecordCount(); err != nil || count != 0 {
+		t.Fatalf("关闭前记录数=%d err=%v, want 0（应仍在缓冲区）", count, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() err = %v", err)
+	}
+
+	count, err := store.GetRecordCount()
+	if err != nil {
+		t.Fatalf("查询记录数失败: %v", err)
+	}
+	if count != records {
+		t.Fatalf("关闭后记录数=%d, want %d", count, records)
+	}
+
+	// 重复关闭不应 panic
+	m.Stop()
+	if err := m.Shutdown(ctx); err != nil {
+		t.Fatalf("重复 Shutdown() err = %v", err)
+	}
+}
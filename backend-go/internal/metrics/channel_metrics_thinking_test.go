@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/types"
+)
+
+func TestRecordSuccessWithUsage_ThinkingTokensInStats(t *testing.T) {
+	m := NewMetricsManagerWithConfig(10, 0.5)
+	defer m.Stop()
+
+	baseURL := "https://example.com"
+	key := "k1"
+
+	m.RecordSuccessWithUsage(baseURL, key, &types.Usage{
+		InputTokens:    100,
+		OutputTokens:   400,
+		ThinkingTokens: 300,
+	})
+	m.RecordSuccessWithUsage(baseURL, key, &types.Usage{
+		InputTokens:  50,
+		OutputTokens: 20,
+	})
+
+	resp := m.ToResponse(0, baseURL, []string{key}, 0)
+	stats, ok := resp.TimeWindows["15m"]
+	if !ok {
+		t.Fatalf("expected timeWindows[15m] to exist")
+	}
+	if stats.ThinkingTokens != 300 {
+		t.Fatalf("expected time window thinkingTokens=300, got %d", stats.ThinkingTokens)
+	}
+
+	history := m.GetKeyHistoricalStatsMultiURL([]string{baseURL}, key, time.Hour, 5*time.Minute)
+	var historyThinking int64
+	for _, point := range history {
+		historyThinking += point.ThinkingTokens
+	}
+	if historyThinking != 300 {
+		t.Fatalf("expected key history thinkingTokens=300, got %d", historyThinking)
+	}
+
+	global := m.GetGlobalHistoricalStatsWithTokens(time.Hour, 5*time.Minute)
+	if global.Summary.TotalThinkingTokens != 300 {
+		t.Fatalf("expected global totalThinkingTokens=300, got %d", global.Summary.TotalThinkingTokens)
+	}
+}
+
+func TestGetRecentActivityMultiURL_TPMIncludeThinking(t *testing.T) {
+	tests := []struct {
+		name            string
+		includeThinking bool
+		wantTPM         float64
+	}{
+		{name: "默认仅计算输出 tokens", includeThinking: false, wantTPM: 450.0 / 15},
+		{name: "计入思考 tokens", includeThinking: true, wantTPM: (450.0 + 300.0) / 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMetricsManagerWithConfig(10, 0.5)
+			defer m.Stop()
+			m.SetTPMIncludeThinking(tt.includeThinking)
+
+			baseURL := "https://example.com"
+			key := "k1"
+			m.RecordSuccessWithUsage(baseURL, key, &types.Usage{InputTokens: 100, OutputTokens: 450, ThinkingTokens: 300})
+
+			activity := m.GetRecentActivityMultiURL(0, []string{baseURL}, []string{key})
+			if diff := activity.TPM - tt.wantTPM; diff > 0.001 || diff < -0.001 {
+				t.Fatalf("expected TPM=%.3f, got %.3f", tt.wantTPM, activity.TPM)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+// maxCircuitEvents 每个 Key 保留的熔断状态变化记录上限（环形覆盖最早的记录）
+const maxCircuitEvents = 64
+
+// CircuitEvent 熔断状态变化记录
+type CircuitEvent struct {
+	Timestamp time.Time
+	Open      bool // true=进入熔断，false=退出熔断
+}
+
+// uptimeInterval 熔断区间 [start, end)
+type uptimeInterval struct {
+	start time.Time
+	end   time.Time
+}
+
+// recordCircuitEventLocked 记录 Key 的熔断状态变化（调用方需持有写锁）
+func recordCircuitEventLocked(metrics *KeyMetrics, open bool, at time.Time) {
+	metrics.circuitEvents = append(metrics.circuitEvents, CircuitEvent{Timestamp: at, Open: open})
+	if len(metrics.circuitEvents) > maxCircuitEvents {
+		metrics.circuitEvents = metrics.circuitEvents[len(metrics.circuitEvents)-maxCircuitEvents:]
+	}
+}
+
+// GetChannelUptime 计算渠道在时间窗口内的可用率（0-1）
+// 可用定义为：至少有一个 Key 未处于熔断状态。仅统计已产生指标的 Key，没有指标时视为全程可用
+func (m *MetricsManager) GetChannelUptime(baseURLs []string, activeKeys []string, duration time.Duration) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.channelUptimeLocked(baseURLs, activeKeys, duration, time.Now())
+}
+
+// channelUptimeWindowsLocked 获取渠道各时间窗口的可用率（与 TimeWindows 使用相同的窗口，调用方需持有读锁）
+func (m *MetricsManager) channelUptimeWindowsLocked(baseURLs []string, activeKeys []string) map[string]float64 {
+	now := time.Now()
+	return map[string]float64{
+		"15m": m.channelUptimeLocked(baseURLs, activeKeys, 15*time.Minute, now),
+		"1h":  m.channelUptimeLocked(baseURLs, activeKeys, time.Hour, now),
+		"6h":  m.channelUptimeLocked(baseURLs, activeKeys, 6*time.Hour, now),
+		"24h": m.channelUptimeLocked(baseURLs, activeKeys, 24*time.Hour, now),
+	}
+}
+
+// channelUptimeLocked 以 now 为窗口终点计算渠道可用率（调用方需持有读锁）
+func (m *MetricsManager) channelUptimeLocked(baseURLs []string, activeKeys []string, duration time.Duration, now time.Time) float64 {
+	if duration <= 0 {
+		return 1
+	}
+	windowStart := now.Add(-duration)
+
+	var down []uptimeInterval
+	found := false
+	for _, baseURL := range baseURLs {
+		for _, apiKey := range activeKeys {
+			metrics, exists := m.keyMetrics[generateMetricsKey(baseURL, apiKey)]
+			if !exists {
+				continue
+			}
+			broken := keyBrokenIntervals(metrics.circuitEvents, windowStart, now)
+			if !found {
+				down = broken
+				found = true
+			} else {
+				down = intersectIntervals(down, broken)
+			}
+		}
+	}
+
+	var downtime time.Duration
+	for _, iv := range down {
+		downtime += iv.end.Sub(iv.start)
+	}
+	uptime := 1 - float64(downtime)/float64(duration)
+	if uptime < 0 {
+		return 0
+	}
+	return uptime
+}
+
+// keyBrokenIntervals 根据熔断状态变化记录还原 Key 在 [windowStart, now) 内的熔断区间
+func keyBrokenIntervals(events []CircuitEvent, windowStart, now time.Time) []uptimeInterval {
+	if len(events) == 0 {
+		return nil
+	}
+
+	// 最早记录为退出熔断时，说明之前的进入记录已被环形覆盖，视为窗口开始时处于熔断
+	open := !events[0].Open
+	var openedAt time.Time
+	if open {
+		openedAt = windowStart
+	}
+
+	var intervals []uptimeInterval
+	for _, event := range events {
+		if event.Open == open {
+			continue
+		}
+		if event.Open {
+			openedAt = event.Timestamp
+		} else {
+			intervals = appendClipped(intervals, openedAt, event.Timestamp, windowStart, now)
+		}
+		open = event.Open
+	}
+	if open {
+		intervals = appendClipped(intervals, openedAt, now, windowStart, now)
+	}
+	return intervals
+}
+
+// appendClipped 将区间裁剪到窗口内后追加
+func appendClipped(intervals []uptimeInterval, start, end, windowStart, windowEnd time.Time) []uptimeInterval {
+	if start.Before(windowStart) {
+		start = windowStart
+	}
+	if end.After(windowEnd) {
+		end = windowEnd
+	}
+	if !end.After(start) {
+		return intervals
+	}
+	return append(intervals, uptimeInterval{start: start, end: end})
+}
+
+// intersectIntervals 计算两组有序区间的交集（所有 Key 同时熔断的时间段）
+func intersectIntervals(a, b []uptimeInterval) []uptimeInterval {
+	sort.Slice(a, func(i, j int) bool { return a[i].start.Before(a[j].start) })
+	sort.Slice(b, func(i, j int) bool { return b[i].start.Before(b[j].start) })
+
+	var result []uptimeInterval
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		start := a[i].start
+		if b[j].start.After(start) {
+			start = b[j].start
+		}
+		end := a[i].end
+		if b[j].end.Before(end) {
+			end = b[j].end
+		}
+		if end.After(start) {
+			result = append(result, uptimeInterval{start: start, end: end})
+		}
+		if a[i].end.Before(b[j].end) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return result
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestChannelUptime(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+	at := func(minutesAgo int) time.Time { return now.Add(-time.Duration(minutesAgo) * time.Minute) }
+	baseURL := "https://example.com"
+
+	tests := []struct {
+		name   string
+		events map[string][]CircuitEvent // key -> 熔断状态变化
+		window time.Duration
+		want   float64
+	}{
+		{
+			name:   "无熔断记录",
+			events: map[string][]CircuitEvent{"k1": nil},
+			window: time.Hour,
+			want:   1,
+		},
+		{
+			name: "单 Key 熔断 15 分钟后恢复",
+			events: map[string][]CircuitEvent{
+				"k1": {{Timestamp: at(40), Open: true}, {Timestamp: at(25), Open: false}},
+			},
+			window: time.Hour,
+			want:   0.75,
+		},
+		{
+			name: "单 Key 熔断至今",
+			events: map[string][]CircuitEvent{
+				"k1": {{Timestamp: at(6), Open: true}},
+			},
+			window: time.Hour,
+			want:   0.9,
+		},
+		{
+			name: "熔断开始于窗口之前，按窗口裁剪",
+			events: map[string][]CircuitEvent{
+				"k1": {{Timestamp: at(90), Open: true}, {Timestamp: at(30), Open: false}},
+			},
+			window: time.Hour,
+			want:   0.5,
+		},
+		{
+			name: "两个 Key 交替熔断，渠道始终可用",
+			events: map[string][]CircuitEvent{
+				"k1": {{Timestamp: at(50), Open: true}, {Timestamp: at(40), Open: false}},
+				"k2": {{Timestamp: at(30), Open: true}, {Timestamp: at(20), Open: false}},
+			},
+			window: time.Hour,
+			want:   1,
+		},
+		{
+			name: "两个 Key 熔断重叠 12 分钟",
+			events: map[string][]CircuitEvent{
+				"k1": {{Timestamp: at(50), Open: true}, {Timestamp: at(20), Open: false}},
+				"k2": {{Timestamp: at(32), Open: true}, {Timestamp: at(10), Open: false}},
+			},
+			window: time.Hour,
+			want:   0.8,
+		},
+		{
+			name: "最早记录为恢复事件（进入记录已被覆盖）",
+			events: map[string][]CircuitEvent{
+				"k1": {{Timestamp: at(45), Open: false}},
+			},
+			window: time.Hour,
+			want:   0.75,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMetricsManagerWithConfig(10, 0.5)
+			defer m.Stop()
+
+			var keys []string
+			for key, events := range tt.events {
+				keys = append(keys, key)
+				metrics := m.getOrCreateKey(baseURL, key)
+				for _, event := range events {
+					recordCircuitEventLocked(metrics, event.Open, event.Timestamp)
+				}
+			}
+
+			got := m.channelUptimeLocked([]string{baseURL}, keys, tt.window, now)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("uptime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelUptime_TracksCircuitTransitions(t *testing.T) {
+	m := NewMetricsManagerWithConfig(3, 0.5)
+	defer m.Stop()
+
+	baseURL := "https://example.com"
+	for i := 0; i < 3; i++ {
+		m.RecordFailure(baseURL, "k1")
+	}
+	if m.GetKeyMetrics(baseURL, "k1").CircuitBrokenAt == nil {
+		t.Fatalf("连续失败后 Key 应进入熔断")
+	}
+	if got := m.GetChannelUptime([]string{baseURL}, []string{"k1"}, time.Hour); got >= 1 {
+		t.Fatalf("熔断期间 uptime = %v, want < 1", got)
+	}
+
+	m.RecordSuccess(baseURL, "k1")
+	events := m.keyMetrics[generateMetricsKey(baseURL, "k1")].circuitEvents
+	if len(events) != 2 || !events[0].Open || events[1].Open {
+		t.Fatalf("circuitEvents = %+v, want open then close", events)
+	}
+}
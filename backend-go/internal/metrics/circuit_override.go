@@ -0,0 +1,48 @@
+package metrics
+
+import "time"
+
+// CircuitOverride 渠道级熔断失败率阈值与恢复时间（0 表示使用全局默认）
+type CircuitOverride struct {
+	FailureThreshold float64
+	RecoveryTime     time.Duration
+}
+
+// SetCircuitOverrides 按 BaseURL 设置渠道级熔断覆盖（用于放宽低质量渠道的熔断灵敏度）
+// 由调用方在配置变更时推送（metrics 不直接依赖 config），熔断判断时只读本地副本，不回调配置
+func (m *MetricsManager) SetCircuitOverrides(overrides map[string]CircuitOverride) {
+	copied := make(map[string]CircuitOverride, len(overrides))
+	for baseURL, override := range overrides {
+		copied[baseURL] = override
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.circuitOverrides = copied
+}
+
+// failureThresholdFor 返回 BaseURL 对应的熔断失败率阈值（调用方需持有锁）
+func (m *MetricsManager) failureThresholdFor(baseURL string) float64 {
+	if threshold := m.circuitOverrides[baseURL].FailureThreshold; threshold > 0 {
+		return threshold
+	}
+	return m.failureThreshold
+}
+
+// recoveryTimeFor 返回 BaseURL 对应的熔断恢复时间（调用方需持有锁）
+func (m *MetricsManager) recoveryTimeFor(baseURL string) time.Duration {
+	if recovery := m.circuitOverrides[baseURL].RecoveryTime; recovery > 0 {
+		return recovery
+	}
+	return m.circuitRecoveryTime
+}
+
+// SetCircuitRecoveryTime 设置全局熔断恢复时间（<= 0 时保持默认值）
+func (m *MetricsManager) SetCircuitRecoveryTime(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.circuitRecoveryTime = d
+}
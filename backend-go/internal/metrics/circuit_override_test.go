@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitOverride_LowQualityVsNormal(t *testing.T) {
+	const (
+		normalURL     = "https://normal.example.com"
+		lowQualityURL = "https://low.example.com"
+		key           = "sk-test"
+	)
+
+	m := NewMetricsManagerWithConfig(10, 0.5)
+	defer m.Stop()
+	m.SetCircuitOverrides(map[string]CircuitOverride{
+		lowQualityURL: {FailureThreshold: 0.8, RecoveryTime: 5 * time.Minute},
+	})
+
+	// 相同的请求序列：10 次中 6 次失败（60% 失败率）
+	for _, baseURL := range []string{normalURL, lowQualityURL} {
+		for i := 0; i < 10; i++ {
+			if i%5 < 3 {
+				m.RecordFailure(baseURL, key)
+			} else {
+				m.RecordSuccess(baseURL, key)
+			}
+		}
+	}
+
+	if !m.ShouldSuspendKey(normalURL, key) {
+		t.Fatalf("普通渠道 60%% 失败率应达到默认阈值 50%% 而熔断")
+	}
+	if m.ShouldSuspendKey(lowQualityURL, key) {
+		t.Fatalf("低质量渠道 60%% 失败率不应达到覆盖阈值 80%%")
+	}
+
+	// 继续失败直到低质量渠道也进入熔断
+	for i := 0; i < 10; i++ {
+		m.RecordFailure(lowQualityURL, key)
+	}
+	if m.GetKeyMetrics(lowQualityURL, key).CircuitBrokenAt == nil {
+		t.Fatalf("低质量渠道连续失败后应进入熔断")
+	}
+
+	// 两者都熔断 6 分钟后：低质量渠道（5 分钟恢复）已恢复，普通渠道（15 分钟恢复）仍熔断
+	brokenAt := time.Now().Add(-6 * time.Minute)
+	m.mu.Lock()
+	for _, baseURL := range []string{normalURL, lowQualityURL} {
+		metrics := m.keyMetrics[generateMetricsKey(baseURL, key)]
+		metrics.CircuitBrokenAt = &brokenAt
+	}
+	m.mu.Unlock()
+
+	m.recoverExpiredCircuitBreakers()
+
+	if m.GetKeyMetrics(normalURL, key).CircuitBrokenAt == nil {
+		t.Fatalf("普通渠道熔断 6 分钟后不应恢复（默认 15 分钟）")
+	}
+	if m.GetKeyMetrics(lowQualityURL, key).CircuitBrokenAt != nil {
+		t.Fatalf("低质量渠道熔断 6 分钟后应已恢复（覆盖 5 分钟）")
+	}
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import "time"
+
+// 熔断状态（当前实现没有半开状态：恢复时间到达后直接回到 closed）
+const (
+	CircuitStateClosed = "closed"
+	CircuitStateOpen   = "open"
+)
+
+// KeyCircuitState 单个 Key 的实时熔断状态（运维排查用，比仪表盘聚合数据更直接）
+type KeyCircuitState struct {
+	MetricsKey          string     `json:"metricsKey"`
+	BaseURL             string     `json:"baseUrl"`
+	KeyMask             string     `json:"keyMask"`
+	State               string     `json:"state"` // closed / open
+	BrokenAt            *time.Time `json:"brokenAt,omitempty"`
+	ConsecutiveFailures int64      `json:"consecutiveFailures"`
+	// 距离自动恢复的剩余秒数（仅 open 状态；后台每分钟检查一次，已到期但尚未恢复时为 0）
+	RecoversInSeconds float64    `json:"recoversInSeconds,omitempty"`
+	SuspendedUntil    *time.Time `json:"suspendedUntil,omitempty"` // 上游 Retry-After 暂停截止时间（仅未过期时返回）
+}
+
+// GetKeyCircuitStates 返回所有 Key 的当前熔断状态
+func (m *MetricsManager) GetKeyCircuitStates() []KeyCircuitState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.keyCircuitStatesLocked(time.Now())
+}
+
+// keyCircuitStatesLocked 以 now 计算剩余恢复时间（调用方需持有读锁）
+func (m *MetricsManager) keyCircuitStatesLocked(now time.Time) []KeyCircuitState {
+	result := make([]KeyCircuitState, 0, len(m.keyMetrics))
+	for metricsKey, km := range m.keyMetrics {
+		state := KeyCircuitState{
+			MetricsKey:          metricsKey,
+			BaseURL:             km.BaseURL,
+			KeyMask:             km.KeyMask,
+			State:               CircuitStateClosed,
+			ConsecutiveFailures: km.ConsecutiveFailures,
+		}
+		if km.CircuitBrokenAt != nil {
+			brokenAt := *km.CircuitBrokenAt
+			state.State = CircuitStateOpen
+			state.BrokenAt = &brokenAt
+			if remaining := m.recoveryTimeFor(km.BaseURL) - now.Sub(brokenAt); remaining > 0 {
+				state.RecoversInSeconds = remaining.Seconds()
+			}
+		}
+		if km.SuspendedUntil != nil && km.SuspendedUntil.After(now) {
+			suspendedUntil := *km.SuspendedUntil
+			state.SuspendedUntil = &suspendedUntil
+		}
+		result = append(result, state)
+	}
+	return result
+}
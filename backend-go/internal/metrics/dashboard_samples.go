@@ -0,0 +1,48 @@
+package metrics
+
+// SetDashboardMinSamples 设置仪表盘成功率的最小样本数（<= 0 表示不限制）
+// 样本数不足时成功率/失败率返回 null 并标记 insufficientData，避免 1 次失败显示为 0% 之类的误导
+func (m *MetricsManager) SetDashboardMinSamples(minSamples int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dashboardMinSamples = max(minSamples, 0)
+}
+
+// insufficientSamplesLocked 样本数是否低于仪表盘最小样本数（调用方需持有锁）
+func (m *MetricsManager) insufficientSamplesLocked(samples int64) bool {
+	return m.dashboardMinSamples > 0 && samples < int64(m.dashboardMinSamples)
+}
+
+// setRatesLocked 根据最近请求结果设置渠道成功率与失败率（调用方需持有锁）
+// 样本数不足时两者为 nil；未设置最小样本数且没有样本时按 100% 成功
+func (m *MetricsManager) setRatesLocked(resp *MetricsResponse, results []bool) {
+	resp.InsufficientData = m.insufficientSamplesLocked(int64(len(results)))
+	if resp.InsufficientData {
+		resp.SuccessRate, resp.ErrorRate = nil, nil
+		return
+	}
+	failureRate := float64(0)
+	if len(results) > 0 {
+		failures := 0
+		for _, success := range results {
+			if !success {
+				failures++
+			}
+		}
+		failureRate = float64(failures) / float64(len(results))
+	}
+	successRate, errorRate := (1-failureRate)*100, failureRate*100
+	resp.SuccessRate, resp.ErrorRate = &successRate, &errorRate
+}
+
+// keySuccessRateLocked 计算单个 Key 的成功率（调用方需持有锁），样本数不足时返回 nil
+func (m *MetricsManager) keySuccessRateLocked(successCount, requestCount int64) (*float64, bool) {
+	if m.insufficientSamplesLocked(requestCount) {
+		return nil, true
+	}
+	rate := float64(100)
+	if requestCount > 0 {
+		rate = float64(successCount) / float64(requestCount) * 100
+	}
+	return &rate, false
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestToResponseMultiURL_InsufficientData 样本数低于最小样本数时成功率为 null 并标记 insufficientData，达到阈值后返回真实成功率
+func TestToResponseMultiURL_InsufficientData(t *testing.T) {
+	const baseURL = "https://api.example.com"
+	keys := []string{"sk-test"}
+	rate := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name             string
+		minSamples       int
+		failures         int
+		successes        int
+		keys             []string
+		wantInsufficient bool
+		wantSuccessRate  *float64
+	}{
+		{name: "无样本", minSamples: 5, keys: keys, wantInsufficient: true},
+		{name: "未配置 Key", minSamples: 5, wantInsufficient: true},
+		{name: "单次失败", minSamples: 5, failures: 1, keys: keys, wantInsufficient: true},
+		{name: "达到阈值", minSamples: 5, failures: 2, successes: 6, keys: keys, wantSuccessRate: rate(75)},
+		{name: "未设置阈值", minSamples: 0, failures: 1, keys: keys, wantSuccessRate: rate(0)},
+		{name: "未设置阈值且无样本", minSamples: 0, keys: keys, wantSuccessRate: rate(100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMetricsManagerWithConfig(10, 0.5)
+			defer m.Stop()
+			m.SetDashboardMinSamples(tt.minSamples)
+
+			for range tt.failures {
+				m.RecordFailure(baseURL, "sk-test")
+			}
+			for range tt.successes {
+				m.RecordSuccess(baseURL, "sk-test")
+			}
+
+			resp := m.ToResponseMultiURL(0, []string{baseURL}, tt.keys, 0)
+			if resp.InsufficientData != tt.wantInsufficient {
+				t.Fatalf("InsufficientData = %v, want %v", resp.InsufficientData, tt.wantInsufficient)
+			}
+			if tt.wantSuccessRate == nil {
+				if resp.SuccessRate != nil || resp.ErrorRate != nil {
+					t.Fatalf("样本不足时成功率/失败率应为 nil, got %v/%v", resp.SuccessRate, resp.ErrorRate)
+				}
+				data, _ := json.Marshal(resp)
+				if !strings.Contains(string(data), `"successRate":null`) {
+					t.Fatalf("样本不足时 successRate 应序列化为 null: %s", data)
+				}
+			} else if resp.SuccessRate == nil || *resp.SuccessRate < *tt.wantSuccessRate-1e-9 || *resp.SuccessRate > *tt.wantSuccessRate+1e-9 {
+				t.Fatalf("SuccessRate = %v, want %v", resp.SuccessRate, *tt.wantSuccessRate)
+			}
+			for _, km := range resp.KeyMetrics {
+				if km.InsufficientData != tt.wantInsufficient || (km.SuccessRate == nil) != tt.wantInsufficient {
+					t.Fatalf("key InsufficientData = %v, SuccessRate = %v, want insufficient %v", km.InsufficientData, km.SuccessRate, tt.wantInsufficient)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// DeadLetterAttempt 死信请求中的单次上游尝试
+type DeadLetterAttempt struct {
+	ChannelIndex int    `json:"channelIndex"`
+	ChannelName  string `json:"channelName"`
+	BaseURL      string `json:"baseUrl"`
+	KeyMask      string `json:"keyMask"`
+	StatusCode   int    `json:"statusCode"` // 0 表示网络错误等未拿到响应
+	ErrorInfo    string `json:"errorInfo,omitempty"`
+}
+
+// DeadLetterEntry 所有渠道都失败的请求记录（用于事后排查）
+type DeadLetterEntry struct {
+	ID            int64               `json:"id"` // 由存储分配，用于重放
+	Timestamp     time.Time           `json:"timestamp"`
+	CorrelationID string              `json:"correlationId"`
+	Kind          string              `json:"kind"`
+	Model         string              `json:"model"`
+	Attempts      []DeadLetterAttempt `json:"attempts"`
+	StatusCode    int                 `json:"statusCode"` // 最终返回给客户端的状态码
+	FinalError    string              `json:"finalError"`
+
+	// 原始请求（经入口预处理后实际发往渠道的请求体），用于重放；请求体不在列表接口中返回
+	Stream      bool   `json:"stream"`
+	Replayable  bool   `json:"replayable"`
+	RequestBody []byte `json:"-"`
+}
+
+const maxDeadLetters = 200
+
+// DeadLetterStore 死信存储（内存环形缓冲区，跨接口类型共享）
+type DeadLetterStore struct {
+	mu      sync.RWMutex
+	entries []*DeadLetterEntry
+	nextID  int64
+}
+
+func NewDeadLetterStore() *DeadLetterStore {
+	return &DeadLetterStore{}
+}
+
+func (s *DeadLetterStore) Record(entry *DeadLetterEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	entry.ID = s.nextID
+	entry.Replayable = len(entry.RequestBody) > 0
+	s.entries = append(s.entries, entry)
+	if len(s.entries) > maxDeadLetters {
+		s.entries = s.entries[len(s.entries)-maxDeadLetters:]
+	}
+}
+
+// GetRecent 获取最近的死信记录，按时间倒序（最新在前）
+// kind 为空时不过滤接口类型，limit <= 0 时返回全部
+func (s *DeadLetterStore) GetRecent(kind string, limit int) []*DeadLetterEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]*DeadLetterEntry, 0)
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if kind != "" && s.entries[i].Kind != kind {
+			continue
+		}
+		result = append(result, s.entries[i])
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+	return result
+}
+
+// Get 按 ID 获取死信记录（已被环形缓冲区淘汰时返回 false）
+func (s *DeadLetterStore) Get(id int64) (*DeadLetterEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, entry := range s.entries {
+		if entry.ID == id {
+			return entry, true
+		}
+	}
+	return nil, false
+}
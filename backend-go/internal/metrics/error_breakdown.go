@@ -0,0 +1,51 @@
+package metrics
+
+// Key 错误类型（用于 errorBreakdown 统计，区分无效 Key 与上游过载等情况）
+const (
+	KeyErrorUnauthorized    = "401"
+	KeyErrorRateLimited     = "429"
+	KeyErrorServer          = "5xx"
+	KeyErrorTimeout         = "timeout"
+	KeyErrorInvalidResponse = "invalid_response"
+)
+
+// KeyErrorTypeForStatus 将上游 HTTP 状态码归类为 Key 错误类型，不在统计范围内的返回空字符串
+func KeyErrorTypeForStatus(status int) string {
+	switch {
+	case status == 401:
+		return KeyErrorUnauthorized
+	case status == 429:
+		return KeyErrorRateLimited
+	case status >= 500 && status < 600:
+		return KeyErrorServer
+	default:
+		return ""
+	}
+}
+
+// RecordKeyError 累加 Key 的错误类型计数（errorType 为空时忽略）
+func (m *MetricsManager) RecordKeyError(baseURL, apiKey, errorType string) {
+	if errorType == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics := m.getOrCreateKey(baseURL, apiKey)
+	if metrics.ErrorBreakdown == nil {
+		metrics.ErrorBreakdown = make(map[string]int64)
+	}
+	metrics.ErrorBreakdown[errorType]++
+}
+
+// copyErrorBreakdown 复制错误类型计数（调用方需持有锁）
+func copyErrorBreakdown(src map[string]int64) map[string]int64 {
+	if len(src) == 0 {
+		return nil
+	}
+	dst := make(map[string]int64, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"log"
+	"time"
+)
+
+// memoryHistoryRetention 内存中请求历史的保留时长（更早的数据需从持久化存储读取）
+const memoryHistoryRetention = 24 * time.Hour
+
+// GetStatsRetention 返回可查询统计的最长时间范围
+// 启用持久化时为存储保留天数，否则仅为内存中的 24 小时
+func (m *MetricsManager) GetStatsRetention() time.Duration {
+	if m.store == nil {
+		return memoryHistoryRetention
+	}
+	retention := time.Duration(m.store.RetentionDays()) * 24 * time.Hour
+	if retention < memoryHistoryRetention {
+		return memoryHistoryRetention
+	}
+	return retention
+}
+
+// GetUsageSummary 汇总时间窗口内所有渠道的请求与 Token 用量，并按模型分组
+// 24 小时内的数据取自内存，更早的部分从持久化存储补充（调用方需按 GetStatsRetention 截断 duration）
+func (m *MetricsManager) GetUsageSummary(duration time.Duration) (GlobalStatsSummary, map[string]*ModelUsageSummary) {
+	acc := &usageAccumulator{models: make(map[string]*ModelUsageSummary)}
+	if duration <= 0 {
+		return acc.result(duration)
+	}
+
+	now := time.Now()
+	cutoff := now.Add(-duration)
+	memoryCutoff := now.Add(-memoryHistoryRetention)
+	if cutoff.After(memoryCutoff) {
+		memoryCutoff = cutoff
+	}
+
+	for _, record := range m.snapshotHistory(memoryCutoff) {
+		if record.Timestamp.After(memoryCutoff) {
+			acc.add(record)
+		}
+	}
+	m.mu.RLock()
+	store := m.store
+	m.mu.RUnlock()
+
+	// 超出内存保留范围的部分从持久化存储读取，只取内存窗口之前的记录避免重复统计
+	if store != nil && cutoff.Before(memoryCutoff) {
+		records, err := store.LoadRecords(cutoff, m.apiType)
+		if err != nil {
+			log.Printf("[Metrics-Stats] 警告: 加载 %s 历史记录失败: %v", m.apiType, err)
+		}
+		for _, r := range records {
+			if r.Timestamp.After(memoryCutoff) {
+				continue
+			}
+			acc.add(RequestRecord{
+				Model:                    r.Model,
+				Timestamp:                r.Timestamp,
+				Success:                  r.Success,
+				InputTokens:              r.InputTokens,
+				OutputTokens:             r.OutputTokens,
+				CacheCreationInputTokens: r.CacheCreationTokens,
+				CacheReadInputTokens:     r.CacheReadTokens,
+				ThinkingTokens:           r.ThinkingTokens,
+			})
+		}
+	}
+
+	return acc.result(duration)
+}
+
+// usageAccumulator 累加请求记录的汇总与模型用量
+type usageAccumulator struct {
+	summary GlobalStatsSummary
+	models  map[string]*ModelUsageSummary
+}
+
+func (a *usageAccumulator) add(record RequestRecord) {
+	a.summary.TotalRequests++
+	if record.Success {
+		a.summary.TotalSuccess++
+	} else {
+		a.summary.TotalFailure++
+	}
+	a.summary.TotalInputTokens += record.InputTokens
+	a.summary.TotalOutputTokens += record.OutputTokens
+	a.summary.TotalCacheCreationTokens += record.CacheCreationInputTokens
+	a.summary.TotalCacheReadTokens += record.CacheReadInputTokens
+	a.summary.TotalThinkingTokens += record.ThinkingTokens
+
+	if record.Model == "" {
+		return
+	}
+	model, ok := a.models[record.Model]
+	if !ok {
+		model = &ModelUsageSummary{Model: record.Model}
+		a.models[record.Model] = model
+	}
+	model.RequestCount++
+	if record.Success {
+		model.SuccessCount++
+	} else {
+		model.FailureCount++
+	}
+	model.InputTokens += record.InputTokens
+	model.OutputTokens += record.OutputTokens
+	model.CacheCreationInputTokens += record.CacheCreationInputTokens
+	model.CacheReadInputTokens += record.CacheReadInputTokens
+	model.ThinkingTokens += record.ThinkingTokens
+}
+
+func (a *usageAccumulator) result(duration time.Duration) (GlobalStatsSummary, map[string]*ModelUsageSummary) {
+	a.summary.Duration = duration.String()
+	if a.summary.TotalRequests > 0 {
+		a.summary.AvgSuccessRate = float64(a.summary.TotalSuccess) / float64(a.summary.TotalRequests) * 100
+	}
+	for _, model := range a.models {
+		model.SuccessRate = float64(model.SuccessCount) / float64(model.RequestCount) * 100
+	}
+	return a.summary, a.models
+}
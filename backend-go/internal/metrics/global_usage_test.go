@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/types"
+)
+
+func TestGetUsageSummary_MergesPersistedHistory(t *testing.T) {
+	store, err := NewSQLiteStore(&SQLiteStoreConfig{
+		DBPath:        filepath.Join(t.TempDir(), "metrics.db"),
+		RetentionDays: 7,
+	})
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	defer store.Close()
+
+	// 3 天前的记录只存在于持久化存储中
+	store.AddRecord(PersistentRecord{
+		MetricsKey:   generateMetricsKey("https://a.example.com", "k1"),
+		BaseURL:      "https://a.example.com",
+		KeyMask:      "k1",
+		Timestamp:    time.Now().Add(-72 * time.Hour),
+		Success:      true,
+		InputTokens:  1000,
+		OutputTokens: 100,
+		Model:        "claude-sonnet",
+		APIType:      "messages",
+	})
+	store.flush()
+
+	m := NewMetricsManagerWithPersistence(10, 0.5, store, "messages")
+	defer m.Stop()
+
+	if got := m.GetStatsRetention(); got != 7*24*time.Hour {
+		t.Fatalf("GetStatsRetention()=%v, want 168h", got)
+	}
+
+	// 最近的请求同时写入内存与存储，不应被重复统计
+	id := m.RecordRequestConnectedAt("https://a.example.com", "k1", "claude-sonnet", time.Now().Add(-time.Minute))
+	m.RecordRequestFinalizeSuccess("https://a.example.com", "k1", id, &types.Usage{InputTokens: 200, OutputTokens: 20})
+	store.flush()
+
+	tests := []struct {
+		name         string
+		duration     time.Duration
+		wantRequests int64
+		wantInput    int64
+	}{
+		{name: "24h 仅内存", duration: 24 * time.Hour, wantRequests: 1, wantInput: 200},
+		{name: "7d 合并持久化记录", duration: 7 * 24 * time.Hour, wantRequests: 2, wantInput: 1200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, models := m.GetUsageSummary(tt.duration)
+			if summary.TotalRequests != tt.wantRequests || summary.TotalInputTokens != tt.wantInput {
+				t.Fatalf("summary=%+v, want requests=%d input=%d", summary, tt.wantRequests, tt.wantInput)
+			}
+			model := models["claude-sonnet"]
+			if model == nil || model.RequestCount != tt.wantRequests || model.InputTokens != tt.wantInput {
+				t.Fatalf("claude-sonnet=%+v, want requests=%d input=%d", model, tt.wantRequests, tt.wantInput)
+			}
+		})
+	}
+}
+
+func TestGetStatsRetention_WithoutStore(t *testing.T) {
+	m := NewMetricsManagerWithConfig(10, 0.5)
+	defer m.Stop()
+
+	if got := m.GetStatsRetention(); got != 24*time.Hour {
+		t.Fatalf("GetStatsRetention()=%v, want 24h", got)
+	}
+}
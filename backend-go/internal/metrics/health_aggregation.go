@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HealthAggregation 渠道健康度的聚合策略
+type HealthAggregation string
+
+const (
+	// HealthAggregationPooled 合并所有 Key 的滑动窗口结果计算失败率（默认，请求量大的 Key 权重更高）
+	HealthAggregationPooled HealthAggregation = "pooled"
+	// HealthAggregationPerKeyMean 各 Key 失败率的算术平均（每个 Key 权重相同，避免高流量 Key 掩盖失效 Key）
+	HealthAggregationPerKeyMean HealthAggregation = "per_key_mean"
+)
+
+// ParseHealthAggregation 解析聚合策略（空字符串视为 pooled）
+func ParseHealthAggregation(value string) (HealthAggregation, error) {
+	switch HealthAggregation(strings.ToLower(strings.TrimSpace(value))) {
+	case "", HealthAggregationPooled:
+		return HealthAggregationPooled, nil
+	case HealthAggregationPerKeyMean:
+		return HealthAggregationPerKeyMean, nil
+	default:
+		return "", fmt.Errorf("未知的健康度聚合策略 %q（可选 pooled / per_key_mean）", value)
+	}
+}
+
+// SetHealthAggregation 设置渠道健康度聚合策略
+func (m *MetricsManager) SetHealthAggregation(mode HealthAggregation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.healthAggregation = mode
+}
+
+// channelFailureRateLocked 按聚合策略计算渠道失败率（调用方需持有锁）
+// 返回参与计算的样本总数，供调用方做最小请求数保护
+func (m *MetricsManager) channelFailureRateLocked(baseURL string, activeKeys []string) (float64, int) {
+	var failures, samples, keysWithResults int
+	var keyRateSum float64
+	for _, apiKey := range activeKeys {
+		metrics, exists := m.keyMetrics[generateMetricsKey(baseURL, apiKey)]
+		if !exists || len(metrics.recentResults) == 0 {
+			continue
+		}
+		keyFailures := 0
+		for _, success := range metrics.recentResults {
+			if !success {
+				keyFailures++
+			}
+		}
+		failures += keyFailures
+		samples += len(metrics.recentResults)
+		keyRateSum += float64(keyFailures) / float64(len(metrics.recentResults))
+		keysWithResults++
+	}
+
+	if samples == 0 {
+		return 0, 0
+	}
+	if m.healthAggregation == HealthAggregationPerKeyMean {
+		return keyRateSum / float64(keysWithResults), samples
+	}
+	return float64(failures) / float64(samples), samples
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import "testing"
+
+// TestHealthAggregation_DeadKeyMaskedByBusyKey 高流量的健康 Key 在 pooled 策略下掩盖失效 Key，per_key_mean 策略下不会
+func TestHealthAggregation_DeadKeyMaskedByBusyKey(t *testing.T) {
+	const baseURL = "https://api.example.com"
+	keys := []string{"sk-busy", "sk-dead"}
+
+	tests := []struct {
+		mode        HealthAggregation
+		wantRate    float64
+		wantHealthy bool
+	}{
+		{HealthAggregationPooled, 0.2, true},
+		{HealthAggregationPerKeyMean, 0.5, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			m := NewMetricsManagerWithConfig(20, 0.5)
+			defer m.Stop()
+			m.SetHealthAggregation(tt.mode)
+
+			for range 20 {
+				m.RecordSuccess(baseURL, "sk-busy")
+			}
+			for range 5 {
+				m.RecordFailure(baseURL, "sk-dead")
+			}
+
+			if got := m.CalculateChannelFailureRate(baseURL, keys); got < tt.wantRate-1e-9 || got > tt.wantRate+1e-9 {
+				t.Fatalf("CalculateChannelFailureRate() = %v, want %v", got, tt.wantRate)
+			}
+			if got := m.IsChannelHealthyWithKeys(baseURL, keys); got != tt.wantHealthy {
+				t.Fatalf("IsChannelHealthyWithKeys() = %v, want %v", got, tt.wantHealthy)
+			}
+		})
+	}
+}
+
+func TestParseHealthAggregation(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    HealthAggregation
+		wantErr bool
+	}{
+		{"", HealthAggregationPooled, false},
+		{"pooled", HealthAggregationPooled, false},
+		{" PER_KEY_MEAN ", HealthAggregationPerKeyMean, false},
+		{"weighted", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHealthAggregation(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseHealthAggregation(%q) = %q, %v; want %q, err=%v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
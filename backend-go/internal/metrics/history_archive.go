@@ -0,0 +1,650 @@
+package metrics
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sort"
+	"time"
+)
+
+// 请求历史归档格式（ExportAllMetrics / ImportAllMetrics）
+//
+// 整体为 gzip 流，解压后是一串长度前缀帧：uvarint(payload 长度) + payload，payload 首字节为帧类型：
+//   - 'H' 段头：魔数、格式版本、apiType、导出时间
+//   - 'K' Key 记录块：metricsKey、baseURL、keyMask 与最多 archiveChunkSize 条按时间排序的记录
+//
+// 每次导出写出一个完整的 gzip member（段头 + 若干记录块），多次导出的文件可直接拼接追加，
+// 导入时 gzip 按多 member 连续读取，遇到新的段头即切换 apiType。
+// 与 Snapshot 不同，归档只包含请求历史（不含滑动窗口、熔断等实时状态），适合周期性备份。
+const (
+	archiveMagic          = "ccx-metrics-archive"
+	archiveFormatVersion  = 1
+	archiveChunkSize      = 1024
+	archiveMaxFrameLength = 64 << 20 // 单帧上限，防止损坏的长度前缀导致超大分配
+
+	archiveFrameHeader = 'H'
+	archiveFrameKey    = 'K'
+
+	archiveFlagSuccess   = 1 << 0
+	archiveFlagEstimated = 1 << 1
+)
+
+// ErrInvalidArchive 归档内容无法解析（魔数、帧类型或长度不合法）
+var ErrInvalidArchive = errors.New("invalid metrics archive")
+
+// MetricsImportResult 归档导入结果
+type MetricsImportResult struct {
+	Sections   int `json:"sections"`   // 匹配当前 apiType 的段数
+	Keys       int `json:"keys"`       // 涉及的 Key 数
+	Imported   int `json:"imported"`   // 新增的记录数（含仅写入持久化存储的记录）
+	Archived   int `json:"archived"`   // 超出 24 小时历史窗口、仅写入持久化存储的新增记录数
+	Duplicates int `json:"duplicates"` // 已存在而跳过的记录数
+	Expired    int `json:"expired"`    // 超出持久化保留期（未启用持久化时为 24 小时窗口）而跳过的记录数
+}
+
+// ExportAllMetrics 将所有 Key 的请求历史以归档格式流式写入 w
+// 启用持久化时，24 小时窗口之前的记录从持久化存储逐条读取导出，窗口内的记录取自内存（保留纳秒时间戳与估算标记）；
+// 两部分按整秒分界（存储中的时间戳精度为秒），同一请求不会重复导出
+// 内存记录按 Key 逐个加读锁拷贝（与 snapshotHistory 相同），写出过程不持锁；进行中的请求结果未定，不导出
+func (m *MetricsManager) ExportAllMetrics(w io.Writer) error {
+	boundary := time.Unix(time.Now().Add(-24*time.Hour).Unix(), 0)
+
+	m.mu.RLock()
+	store := m.store
+	keys := make([]*KeyMetrics, 0, len(m.keyMetrics))
+	for _, metrics := range m.keyMetrics {
+		keys = append(keys, metrics)
+	}
+	m.mu.RUnlock()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].MetricsKey < keys[j].MetricsKey })
+
+	gz := gzip.NewWriter(w)
+	enc := &archiveEncoder{w: bufio.NewWriter(gz)}
+
+	enc.begin(archiveFrameHeader)
+	enc.putString(archiveMagic)
+	enc.putUvarint(archiveFormatVersion)
+	enc.putString(m.apiType)
+	enc.putVarint(time.Now().UnixNano())
+	if err := enc.end(); err != nil {
+		return err
+	}
+
+	if store != nil {
+		store.Flush()
+		if err := exportStoredHistory(enc, store, m.apiType, boundary); err != nil {
+			return err
+		}
+	}
+
+	var records []RequestRecord
+	for _, metrics := range keys {
+		records = m.appendCompletedHistory(records[:0], metrics)
+		if store != nil {
+			records = slices.DeleteFunc(records, func(record RequestRecord) bool { return record.Timestamp.Before(boundary) })
+		}
+		if len(records) == 0 {
+			continue
+		}
+		sort.SliceStable(records, func(i, j int) bool { return records[i].Timestamp.Before(records[j].Timestamp) })
+
+		for start := 0; start < len(records); start += archiveChunkSize {
+			end := min(start+archiveChunkSize, len(records))
+			if err := enc.writeKeyFrame(metrics.MetricsKey, metrics.BaseURL, metrics.KeyMask, records[start:end]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := enc.w.Flush(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// exportStoredHistory 将持久化存储中 before 之前的记录按 Key 分块写出（逐条遍历，不整体加载）
+func exportStoredHistory(enc *archiveEncoder, store PersistenceStore, apiType string, before time.Time) error {
+	var metricsKey, baseURL, keyMask string
+	chunk := make([]RequestRecord, 0, archiveChunkSize)
+	writeChunk := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		err := enc.writeKeyFrame(metricsKey, baseURL, keyMask, chunk)
+		chunk = chunk[:0]
+		return err
+	}
+
+	err := store.IterateRecords(before, apiType, func(record PersistentRecord) error {
+		if record.MetricsKey != metricsKey || len(chunk) >= archiveChunkSize {
+			if err := writeChunk(); err != nil {
+				return err
+			}
+			metricsKey, baseURL, keyMask = record.MetricsKey, record.BaseURL, record.KeyMask
+		}
+		chunk = append(chunk, RequestRecord{
+			Timestamp:                record.Timestamp,
+			Success:                  record.Success,
+			InputTokens:              record.InputTokens,
+			OutputTokens:             record.OutputTokens,
+			CacheCreationInputTokens: record.CacheCreationTokens,
+			CacheReadInputTokens:     record.CacheReadTokens,
+			ThinkingTokens:           record.ThinkingTokens,
+			Model:                    record.Model,
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("读取持久化记录失败: %w", err)
+	}
+	return writeChunk()
+}
+
+// ImportAllMetrics 从归档恢复请求历史，与已有记录合并
+// - 只导入 apiType 与当前实例一致的段（同一文件可包含多个类型的段）
+// - 24 小时窗口内的记录合并进内存历史，计入 Key 的聚合计数，并写入持久化存储
+// - 更早的记录在保留期内时直接写入持久化存储（不进入内存历史）；未启用持久化或超出保留期时跳过
+// - 已存在的相同记录跳过
+// 归档损坏时返回错误，已解析的记录块仍会导入
+func (m *MetricsManager) ImportAllMetrics(r io.Reader) (MetricsImportResult, error) {
+	results, err := ImportMetricsArchive(r, m)
+	return results[m.apiType], err
+}
+
+// ImportMetricsArchive 单次流式解析归档，按段的 apiType 分发到对应的指标管理器，返回按 apiType 的导入结果
+// 归档逐帧解码，内存占用与单个记录块相当，不随归档大小增长
+func ImportMetricsArchive(r io.Reader, managers ...*MetricsManager) (map[string]MetricsImportResult, error) {
+	byType := make(map[string]*MetricsManager, len(managers))
+	results := make(map[string]MetricsImportResult, len(managers))
+	touched := make(map[string]map[string]bool, len(managers))
+	for _, m := range managers {
+		if _, exists := byType[m.apiType]; !exists {
+			byType[m.apiType] = m
+			results[m.apiType] = MetricsImportResult{}
+			touched[m.apiType] = make(map[string]bool)
+		}
+	}
+	finish := func() {
+		for apiType, result := range results {
+			result.Keys = len(touched[apiType])
+			results[apiType] = result
+			if result.Sections > 0 {
+				log.Printf("[Metrics-Import] [%s] 已从归档导入 %d 条记录（%d 个 Key，其中 %d 条仅写入持久化存储；跳过重复 %d 条、过期 %d 条）",
+					apiType, result.Imported, result.Keys, result.Archived, result.Duplicates, result.Expired)
+			}
+		}
+	}
+	defer finish()
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return results, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+	defer gz.Close()
+	reader := bufio.NewReader(gz)
+
+	sawHeader := false
+	var active *MetricsManager
+	for {
+		frame, err := readArchiveFrame(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return results, err
+		}
+
+		dec := &archiveDecoder{buf: frame[1:]}
+		switch frame[0] {
+		case archiveFrameHeader:
+			magic := dec.str()
+			version := dec.uvarint()
+			apiType := dec.str()
+			if dec.err != nil || magic != archiveMagic {
+				return results, fmt.Errorf("%w: 段头无效", ErrInvalidArchive)
+			}
+			if version != archiveFormatVersion {
+				return results, fmt.Errorf("%w: 不支持的格式版本 %d (当前版本 %d)", ErrInvalidArchive, version, archiveFormatVersion)
+			}
+			sawHeader = true
+			active = byType[apiType]
+			if active != nil {
+				result := results[apiType]
+				result.Sections++
+				results[apiType] = result
+			}
+		case archiveFrameKey:
+			if !sawHeader {
+				return results, fmt.Errorf("%w: 缺少段头", ErrInvalidArchive)
+			}
+			metricsKey, baseURL, keyMask := dec.str(), dec.str(), dec.str()
+			records := dec.records()
+			if dec.err != nil || metricsKey == "" {
+				return results, fmt.Errorf("%w: 记录块无效", ErrInvalidArchive)
+			}
+			if active == nil {
+				continue
+			}
+
+			result := results[active.apiType]
+			err := active.importKeyRecords(metricsKey, baseURL, keyMask, records, &result)
+			results[active.apiType] = result
+			if err != nil {
+				return results, err
+			}
+			touched[active.apiType][metricsKey] = true
+		default:
+			return results, fmt.Errorf("%w: 未知帧类型 %q", ErrInvalidArchive, frame[0])
+		}
+	}
+	if !sawHeader {
+		return results, fmt.Errorf("%w: 缺少段头", ErrInvalidArchive)
+	}
+	return results, nil
+}
+
+// importKeyRecords 导入单个记录块：窗口内记录合并进内存历史，更早的记录写入持久化存储
+func (m *MetricsManager) importKeyRecords(metricsKey, baseURL, keyMask string, records []RequestRecord, result *MetricsImportResult) error {
+	now := time.Now()
+	cutoff := now.Add(-24 * time.Hour)
+	m.mu.RLock()
+	store := m.store
+	m.mu.RUnlock()
+
+	var fresh, older []RequestRecord
+	for _, record := range records {
+		switch {
+		case record.Timestamp.After(cutoff):
+			fresh = append(fresh, record)
+		case store != nil && record.Timestamp.After(now.AddDate(0, 0, -store.RetentionDays())):
+			older = append(older, record)
+		default:
+			result.Expired++
+		}
+	}
+
+	imported, duplicates := m.mergeKeyHistory(metricsKey, baseURL, keyMask, fresh)
+	result.Imported += imported
+	result.Duplicates += duplicates
+
+	if len(older) > 0 {
+		archived, duplicates, err := m.archiveKeyHistory(store, metricsKey, baseURL, keyMask, older)
+		if err != nil {
+			return fmt.Errorf("写入持久化存储失败: %w", err)
+		}
+		result.Imported += archived
+		result.Archived += archived
+		result.Duplicates += duplicates
+	}
+	return nil
+}
+
+// storedRecordKey 持久化记录去重键（存储中的时间戳精度为秒，估算标记不落库）
+type storedRecordKey struct {
+	timestamp                                         int64
+	success                                           bool
+	input, output, cacheCreation, cacheRead, thinking int64
+	model                                             string
+}
+
+func newStoredRecordKey(record PersistentRecord) storedRecordKey {
+	return storedRecordKey{
+		timestamp:     record.Timestamp.Unix(),
+		success:       record.Success,
+		input:         record.InputTokens,
+		output:        record.OutputTokens,
+		cacheCreation: record.CacheCreationTokens,
+		cacheRead:     record.CacheReadTokens,
+		thinking:      record.ThinkingTokens,
+		model:         record.Model,
+	}
+}
+
+// archiveKeyHistory 将超出内存历史窗口的记录直接写入持久化存储（与存储中同一 Key、同一时间范围的记录去重）
+// 这些记录不进入内存历史，也不计入 Key 的聚合计数
+func (m *MetricsManager) archiveKeyHistory(store PersistenceStore, metricsKey, baseURL, keyMask string, records []RequestRecord) (imported, duplicates int, err error) {
+	from, to := records[0].Timestamp, records[0].Timestamp
+	for _, record := range records[1:] {
+		if record.Timestamp.Before(from) {
+			from = record.Timestamp
+		}
+		if record.Timestamp.After(to) {
+			to = record.Timestamp
+		}
+	}
+
+	// 先刷新缓冲区，确保此前导入的记录参与去重
+	store.Flush()
+	stored, err := store.LoadKeyRecords(metricsKey, m.apiType, from, to)
+	if err != nil {
+		return 0, 0, err
+	}
+	existing := make(map[storedRecordKey]bool, len(stored))
+	for _, record := range stored {
+		existing[newStoredRecordKey(record)] = true
+	}
+
+	for _, record := range records {
+		persistent := PersistentRecord{
+			MetricsKey:          metricsKey,
+			BaseURL:             baseURL,
+			KeyMask:             keyMask,
+			Timestamp:           record.Timestamp,
+			Success:             record.Success,
+			InputTokens:         record.InputTokens,
+			OutputTokens:        record.OutputTokens,
+			CacheCreationTokens: record.CacheCreationInputTokens,
+			CacheReadTokens:     record.CacheReadInputTokens,
+			ThinkingTokens:      record.ThinkingTokens,
+			APIType:             m.apiType,
+			Model:               record.Model,
+		}
+		key := newStoredRecordKey(persistent)
+		if existing[key] {
+			duplicates++
+			continue
+		}
+		existing[key] = true
+		store.AddRecord(persistent)
+		imported++
+	}
+	return imported, duplicates, nil
+}
+
+// appendCompletedHistory 持读锁将单个 Key 中已完成的请求记录追加到 dst（跳过 pendingHistoryIdx 中的进行中请求）
+func (m *MetricsManager) appendCompletedHistory(dst []RequestRecord, metrics *KeyMetrics) []RequestRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	pending := make(map[int]bool, len(metrics.pendingHistoryIdx))
+	for _, idx := range metrics.pendingHistoryIdx {
+		pending[idx] = true
+	}
+	for i, record := range metrics.requestHistory {
+		if !pending[i] {
+			dst = append(dst, record)
+		}
+	}
+	return dst
+}
+
+// archiveRecordKey 记录去重键（时间戳按纳秒比较，避免时区与单调时钟差异）
+type archiveRecordKey struct {
+	timestamp int64
+	record    RequestRecord
+}
+
+func newArchiveRecordKey(record RequestRecord) archiveRecordKey {
+	ts := record.Timestamp.UnixNano()
+	record.Timestamp = time.Time{}
+	return archiveRecordKey{timestamp: ts, record: record}
+}
+
+// mergeKeyHistory 将按时间排序的记录合并进 Key 的请求历史，返回新增与重复的记录数
+// 已有记录保持相对顺序，进行中请求的 pendingHistoryIdx 随合并后的位置同步调整
+func (m *MetricsManager) mergeKeyHistory(metricsKey, baseURL, keyMask string, records []RequestRecord) (imported, duplicates int) {
+	if len(records) == 0 {
+		return 0, 0
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics := m.getOrCreateKeyLocked(baseURL, metricsKey, keyMask)
+	existing := make(map[archiveRecordKey]bool, len(metrics.requestHistory))
+	for _, record := range metrics.requestHistory {
+		existing[newArchiveRecordKey(record)] = true
+	}
+
+	added := make([]RequestRecord, 0, len(records))
+	for _, record := range records {
+		key := newArchiveRecordKey(record)
+		if existing[key] {
+			duplicates++
+			continue
+		}
+		existing[key] = true
+		added = append(added, record)
+	}
+	if len(added) == 0 {
+		return 0, duplicates
+	}
+
+	history := metrics.requestHistory
+	merged := make([]RequestRecord, 0, len(history)+len(added))
+	newIndex := make([]int, len(history))
+	i, j := 0, 0
+	for i < len(history) || j < len(added) {
+		if j >= len(added) || (i < len(history) && !history[i].Timestamp.After(added[j].Timestamp)) {
+			newIndex[i] = len(merged)
+			merged = append(merged, history[i])
+			i++
+			continue
+		}
+		merged = append(merged, added[j])
+		j++
+	}
+	metrics.requestHistory = merged
+	for id, idx := range metrics.pendingHistoryIdx {
+		if idx < len(newIndex) {
+			metrics.pendingHistoryIdx[id] = newIndex[idx]
+		}
+	}
+
+	for _, record := range added {
+		addTodayTokensLocked(metrics, record.Timestamp, record.InputTokens+record.OutputTokens)
+		metrics.RequestCount++
+		if record.Success {
+			metrics.SuccessCount++
+			if metrics.LastSuccessAt == nil || record.Timestamp.After(*metrics.LastSuccessAt) {
+				t := record.Timestamp
+				metrics.LastSuccessAt = &t
+			}
+		} else {
+			metrics.FailureCount++
+			if metrics.LastFailureAt == nil || record.Timestamp.After(*metrics.LastFailureAt) {
+				t := record.Timestamp
+				metrics.LastFailureAt = &t
+			}
+		}
+
+		if m.store != nil {
+			m.store.AddRecord(PersistentRecord{
+				MetricsKey:          metricsKey,
+				BaseURL:             metrics.BaseURL,
+				KeyMask:             metrics.KeyMask,
+				Timestamp:           record.Timestamp,
+				Success:             record.Success,
+				InputTokens:         record.InputTokens,
+				OutputTokens:        record.OutputTokens,
+				CacheCreationTokens: record.CacheCreationInputTokens,
+				CacheReadTokens:     record.CacheReadInputTokens,
+				ThinkingTokens:      record.ThinkingTokens,
+				APIType:             m.apiType,
+				Model:               record.Model,
+			})
+		}
+	}
+	return len(added), duplicates
+}
+
+// readArchiveFrame 读取一个长度前缀帧，流正常结束时返回 io.EOF
+func readArchiveFrame(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+	if length == 0 || length > archiveMaxFrameLength {
+		return nil, fmt.Errorf("%w: 帧长度 %d 超出范围", ErrInvalidArchive, length)
+	}
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, fmt.Errorf("%w: 帧不完整: %w", ErrInvalidArchive, err)
+	}
+	return frame, nil
+}
+
+// archiveEncoder 在内存中组装单个帧，end 时写出长度前缀与 payload
+type archiveEncoder struct {
+	w       *bufio.Writer
+	payload []byte
+}
+
+func (e *archiveEncoder) begin(frameType byte) {
+	e.payload = append(e.payload[:0], frameType)
+}
+
+func (e *archiveEncoder) end() error {
+	var prefix [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(prefix[:], uint64(len(e.payload)))
+	if _, err := e.w.Write(prefix[:n]); err != nil {
+		return err
+	}
+	_, err := e.w.Write(e.payload)
+	return err
+}
+
+// writeKeyFrame 写出一个 Key 记录块
+func (e *archiveEncoder) writeKeyFrame(metricsKey, baseURL, keyMask string, records []RequestRecord) error {
+	e.begin(archiveFrameKey)
+	e.putString(metricsKey)
+	e.putString(baseURL)
+	e.putString(keyMask)
+	e.putRecords(records)
+	return e.end()
+}
+
+func (e *archiveEncoder) putUvarint(v uint64) {
+	e.payload = binary.AppendUvarint(e.payload, v)
+}
+
+func (e *archiveEncoder) putVarint(v int64) {
+	e.payload = binary.AppendVarint(e.payload, v)
+}
+
+func (e *archiveEncoder) putString(s string) {
+	e.putUvarint(uint64(len(s)))
+	e.payload = append(e.payload, s...)
+}
+
+// putRecords 写入记录数与记录列表，时间戳按与前一条的差值编码
+func (e *archiveEncoder) putRecords(records []RequestRecord) {
+	e.putUvarint(uint64(len(records)))
+	var prev int64
+	for _, record := range records {
+		ts := record.Timestamp.UnixNano()
+		e.putVarint(ts - prev)
+		prev = ts
+
+		var flags byte
+		if record.Success {
+			flags |= archiveFlagSuccess
+		}
+		if record.Estimated {
+			flags |= archiveFlagEstimated
+		}
+		e.payload = append(e.payload, flags)
+		e.putVarint(record.InputTokens)
+		e.putVarint(record.OutputTokens)
+		e.putVarint(record.CacheCreationInputTokens)
+		e.putVarint(record.CacheReadInputTokens)
+		e.putVarint(record.ThinkingTokens)
+		e.putString(record.Model)
+	}
+}
+
+// archiveDecoder 顺序解析帧 payload，首次出错后后续读取均返回零值
+type archiveDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *archiveDecoder) fail() {
+	if d.err == nil {
+		d.err = ErrInvalidArchive
+	}
+}
+
+func (d *archiveDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *archiveDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *archiveDecoder) readByte() byte {
+	if d.err != nil || len(d.buf) == 0 {
+		d.fail()
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *archiveDecoder) str() string {
+	length := d.uvarint()
+	if d.err != nil || length > uint64(len(d.buf)) {
+		d.fail()
+		return ""
+	}
+	s := string(d.buf[:length])
+	d.buf = d.buf[length:]
+	return s
+}
+
+func (d *archiveDecoder) records() []RequestRecord {
+	count := d.uvarint()
+	// 每条记录至少 8 字节，据此拒绝与剩余长度不符的记录数
+	if d.err != nil || count > uint64(len(d.buf))/8 {
+		d.fail()
+		return nil
+	}
+	records := make([]RequestRecord, 0, count)
+	var ts int64
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		ts += d.varint()
+		flags := d.readByte()
+		record := RequestRecord{
+			Timestamp: time.Unix(0, ts),
+			Success:   flags&archiveFlagSuccess != 0,
+			Estimated: flags&archiveFlagEstimated != 0,
+		}
+		record.InputTokens = d.varint()
+		record.OutputTokens = d.varint()
+		record.CacheCreationInputTokens = d.varint()
+		record.CacheReadInputTokens = d.varint()
+		record.ThinkingTokens = d.varint()
+		record.Model = d.str()
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,253 @@
+package metrics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestHistoryArchive_RoundTrip 多个 Key、数千条记录导出后导入到新实例，记录与聚合计数一致；重复导入不产生重复记录
+func TestHistoryArchive_RoundTrip(t *testing.T) {
+	const keys, perKey = 5, 2000
+
+	src := NewMetricsManager()
+	defer src.Stop()
+	seedHistory(src, keys, perKey)
+	src.mu.Lock()
+	for _, metrics := range src.keyMetrics {
+		for i := range metrics.requestHistory {
+			metrics.requestHistory[i].CacheReadInputTokens = int64(i)
+			metrics.requestHistory[i].ThinkingTokens = int64(i % 7)
+			metrics.requestHistory[i].Estimated = i%3 == 0
+		}
+	}
+	src.mu.Unlock()
+	// 进行中的请求不导出
+	src.RecordRequestConnected("https://api0.example.com", "sk-0", "model-pending")
+
+	var archive bytes.Buffer
+	if err := src.ExportAllMetrics(&archive); err != nil {
+		t.Fatalf("ExportAllMetrics() err = %v", err)
+	}
+	snapshot, _ := src.Snapshot()
+	t.Logf("归档大小 %d 字节（JSON 快照 %d 字节）", archive.Len(), len(snapshot))
+
+	dst := NewMetricsManager()
+	defer dst.Stop()
+	result, err := dst.ImportAllMetrics(bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatalf("ImportAllMetrics() err = %v", err)
+	}
+	if result.Sections != 1 || result.Keys != keys || result.Imported != keys*perKey || result.Duplicates != 0 {
+		t.Fatalf("导入结果 = %+v, want 1 段 %d 个 Key %d 条记录", result, keys, keys*perKey)
+	}
+
+	for metricsKey, want := range src.keyMetrics {
+		got, ok := dst.keyMetrics[metricsKey]
+		if !ok {
+			t.Fatalf("缺少 Key %s", metricsKey)
+		}
+		if got.BaseURL != want.BaseURL || got.KeyMask != want.KeyMask {
+			t.Fatalf("Key %s 元数据 = %s/%s, want %s/%s", metricsKey, got.BaseURL, got.KeyMask, want.BaseURL, want.KeyMask)
+		}
+		if len(got.requestHistory) != perKey {
+			t.Fatalf("Key %s 记录数 = %d, want %d", metricsKey, len(got.requestHistory), perKey)
+		}
+		for i, record := range got.requestHistory {
+			if newArchiveRecordKey(record) != newArchiveRecordKey(want.requestHistory[i]) {
+				t.Fatalf("Key %s 第 %d 条记录 = %+v, want %+v", metricsKey, i, record, want.requestHistory[i])
+			}
+		}
+		if got.RequestCount != perKey || got.SuccessCount != perKey*9/10 || got.FailureCount != perKey/10 {
+			t.Fatalf("Key %s 计数 = %d/%d/%d, want %d/%d/%d", metricsKey, got.RequestCount, got.SuccessCount, got.FailureCount, perKey, perKey*9/10, perKey/10)
+		}
+	}
+
+	result, err = dst.ImportAllMetrics(bytes.NewReader(archive.Bytes()))
+	if err != nil || result.Imported != 0 || result.Duplicates != keys*perKey {
+		t.Fatalf("重复导入结果 = %+v, %v, want 全部跳过", result, err)
+	}
+	if got := dst.keyMetrics[generateMetricsKey("https://api0.example.com", "sk-0")].RequestCount; got != perKey {
+		t.Fatalf("重复导入后计数 = %d, want %d", got, perKey)
+	}
+}
+
+// TestHistoryArchive_AppendedSections 拼接的多个归档按 apiType 分段导入，与已有进行中请求合并时保持 pending 索引
+func TestHistoryArchive_AppendedSections(t *testing.T) {
+	now := time.Now()
+	newSource := func(apiType, model string, ages ...time.Duration) *MetricsManager {
+		m := NewMetricsManager()
+		m.SetAPIType(apiType)
+		for _, age := range ages {
+			id := m.RecordRequestConnectedAt("https://a.example.com", "sk-a", model, now.Add(-age))
+			m.RecordRequestFinalizeSuccess("https://a.example.com", "sk-a", id, nil)
+		}
+		return m
+	}
+	messages := newSource("messages", "claude", 3*time.Hour, time.Hour, 30*time.Hour)
+	defer messages.Stop()
+	chat := newSource("chat", "gpt", 2*time.Hour)
+	defer chat.Stop()
+
+	var archive bytes.Buffer
+	for _, m := range []*MetricsManager{messages, chat} {
+		if err := m.ExportAllMetrics(&archive); err != nil {
+			t.Fatalf("ExportAllMetrics() err = %v", err)
+		}
+	}
+
+	dst := NewMetricsManager()
+	defer dst.Stop()
+	dst.SetAPIType("messages")
+	pendingID := dst.RecordRequestConnectedAt("https://a.example.com", "sk-a", "claude-live", now.Add(-2*time.Hour))
+
+	result, err := dst.ImportAllMetrics(bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatalf("ImportAllMetrics() err = %v", err)
+	}
+	// 超出 24 小时的记录导入时跳过，chat 段不导入
+	if result.Sections != 1 || result.Imported+result.Expired != len(messages.snapshotHistory(time.Time{})) || result.Imported != 2 {
+		t.Fatalf("导入结果 = %+v, want 仅 messages 段的 2 条窗口内记录", result)
+	}
+
+	metrics := dst.keyMetrics[generateMetricsKey("https://a.example.com", "sk-a")]
+	var models []string
+	for _, record := range metrics.requestHistory {
+		models = append(models, record.Model)
+	}
+	if len(models) != 3 || models[0] != "claude" || models[1] != "claude-live" || models[2] != "claude" {
+		t.Fatalf("合并后记录顺序 = %v, want 按时间排序", models)
+	}
+
+	// 进行中请求在合并后仍能正确回写
+	dst.RecordRequestFinalizeSuccess("https://a.example.com", "sk-a", pendingID, nil)
+	if !metrics.requestHistory[1].Success || metrics.requestHistory[1].Model != "claude-live" {
+		t.Fatalf("进行中请求回写位置错误: %+v", metrics.requestHistory)
+	}
+}
+
+// TestHistoryArchive_RejectsInvalidArchive 非 gzip、缺少段头或帧长度越界时返回 ErrInvalidArchive
+func TestHistoryArchive_RejectsInvalidArchive(t *testing.T) {
+	gzipped := func(data []byte) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(data)
+		gz.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "非 gzip", data: []byte("not an archive")},
+		{name: "空归档", data: gzipped(nil)},
+		{name: "缺少段头", data: gzipped([]byte{2, archiveFrameKey, 0})},
+		{name: "帧长度越界", data: gzipped([]byte{0xff, 0xff, 0xff, 0xff, 0x7f})},
+		{name: "帧不完整", data: gzipped([]byte{10, archiveFrameHeader})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMetricsManager()
+			defer m.Stop()
+			if _, err := m.ImportAllMetrics(bytes.NewReader(tt.data)); !errors.Is(err, ErrInvalidArchive) {
+				t.Fatalf("ImportAllMetrics() err = %v, want ErrInvalidArchive", err)
+			}
+		})
+	}
+}
+
+// TestHistoryArchive_PersistentStore 启用持久化时导出包含存储中超出 24 小时的记录，导入时更早的记录写回存储且可重复导入
+func TestHistoryArchive_PersistentStore(t *testing.T) {
+	now := time.Now()
+	const baseURL, apiKey = "https://a.example.com", "sk-a"
+	metricsKey := generateMetricsKey(baseURL, apiKey)
+
+	srcStore := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "src.db"))
+	defer srcStore.Close()
+	for _, age := range []time.Duration{72 * time.Hour, 30 * time.Hour, 30 * 24 * time.Hour} {
+		srcStore.AddRecord(PersistentRecord{MetricsKey: metricsKey, BaseURL: baseURL, KeyMask: "sk-a***", Timestamp: now.Add(-age), Success: true, InputTokens: 10, Model: "claude-old", APIType: "messages"})
+	}
+	srcStore.Flush()
+	src := NewMetricsManagerWithPersistence(10, 0.5, srcStore, "messages")
+	defer src.Stop()
+	id := src.RecordRequestConnectedAt(baseURL, apiKey, "claude-new", now.Add(-time.Hour))
+	src.RecordRequestFinalizeSuccess(baseURL, apiKey, id, nil)
+
+	var archive bytes.Buffer
+	if err := src.ExportAllMetrics(&archive); err != nil {
+		t.Fatalf("ExportAllMetrics() err = %v", err)
+	}
+
+	dstStore := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "dst.db"))
+	defer dstStore.Close()
+	dst := NewMetricsManagerWithPersistence(10, 0.5, dstStore, "messages")
+	defer dst.Stop()
+
+	tests := []struct {
+		name string
+		want MetricsImportResult
+	}{
+		// 30 天前的记录超出目标存储 7 天保留期
+		{name: "首次导入", want: MetricsImportResult{Sections: 1, Keys: 1, Imported: 3, Archived: 2, Expired: 1}},
+		{name: "重复导入", want: MetricsImportResult{Sections: 1, Keys: 1, Duplicates: 3, Expired: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := dst.ImportAllMetrics(bytes.NewReader(archive.Bytes()))
+			if err != nil {
+				t.Fatalf("ImportAllMetrics() err = %v", err)
+			}
+			if result != tt.want {
+				t.Fatalf("导入结果 = %+v, want %+v", result, tt.want)
+			}
+			dstStore.Flush()
+			stored, err := dstStore.LoadRecords(now.Add(-7*24*time.Hour), "messages")
+			if err != nil || len(stored) != 3 {
+				t.Fatalf("目标存储记录 = %d 条, %v, want 3", len(stored), err)
+			}
+			if got := len(dst.keyMetrics[metricsKey].requestHistory); got != 1 {
+				t.Fatalf("内存历史记录数 = %d, want 1（仅 24 小时窗口内）", got)
+			}
+		})
+	}
+}
+
+// TestImportMetricsArchive_DispatchesByAPIType 单次解析拼接归档，按段类型分发到各指标管理器
+func TestImportMetricsArchive_DispatchesByAPIType(t *testing.T) {
+	var archive bytes.Buffer
+	for _, apiType := range []string{"messages", "chat"} {
+		m := NewMetricsManager()
+		m.SetAPIType(apiType)
+		id := m.RecordRequestConnected("https://a.example.com", "sk-a", apiType+"-model")
+		m.RecordRequestFinalizeSuccess("https://a.example.com", "sk-a", id, nil)
+		if err := m.ExportAllMetrics(&archive); err != nil {
+			t.Fatalf("ExportAllMetrics() err = %v", err)
+		}
+		m.Stop()
+	}
+
+	messages, chat := NewMetricsManager(), NewMetricsManager()
+	defer messages.Stop()
+	defer chat.Stop()
+	messages.SetAPIType("messages")
+	chat.SetAPIType("chat")
+
+	results, err := ImportMetricsArchive(bytes.NewReader(archive.Bytes()), messages, chat)
+	if err != nil {
+		t.Fatalf("ImportMetricsArchive() err = %v", err)
+	}
+	for _, m := range []*MetricsManager{messages, chat} {
+		if got := results[m.apiType]; got.Sections != 1 || got.Imported != 1 {
+			t.Fatalf("[%s] 导入结果 = %+v, want 1 段 1 条", m.apiType, got)
+		}
+		history := m.snapshotHistory(time.Time{})
+		if len(history) != 1 || history[0].Model != m.apiType+"-model" {
+			t.Fatalf("[%s] 历史 = %+v", m.apiType, history)
+		}
+	}
+}
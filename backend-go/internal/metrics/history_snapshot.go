@@ -0,0 +1,44 @@
+package metrics
+
+import "time"
+
+// snapshotHistory 拷贝所有 Key 中时间戳不早于 since 的请求记录，调用方在锁外完成聚合计算
+//
+// 全量聚合（按模型/按时间分桶）如果整段持有读锁，持锁时间随 Key 数与记录数线性增长，
+// 期间所有写入（连接计数、结果回写）都被阻塞。这里按 Key 逐个加读锁拷贝，单次持锁只覆盖一个 Key，
+// 结果切片也在锁外预分配。不能只拷贝切片引用后在锁外遍历：进行中的记录会被原地回写，
+// 删除记录时底层数组会整体前移。
+//
+// 20 个 Key × 5000 条记录（单核）实测：整段持锁聚合单次持锁约 1.6ms，按 Key 拷贝单次持锁约 0.04ms；
+// 查询持续进行时一次写入的耗时从 1.9~3.2ms 降到 0.05~1.7ms（单核下受调度影响波动较大），
+// 见 BenchmarkHistoryQueryLockHold、BenchmarkRecordDuringHistoryQueries。
+// 跨 Key 的快照不是同一时刻的，统计场景下可以接受。
+func (m *MetricsManager) snapshotHistory(since time.Time) []RequestRecord {
+	m.mu.RLock()
+	keys := make([]*KeyMetrics, 0, len(m.keyMetrics))
+	total := 0
+	for _, metrics := range m.keyMetrics {
+		keys = append(keys, metrics)
+		total += len(metrics.requestHistory)
+	}
+	m.mu.RUnlock()
+
+	records := make([]RequestRecord, 0, total)
+	for _, metrics := range keys {
+		records = m.appendKeyHistory(records, metrics, since)
+	}
+	return records
+}
+
+// appendKeyHistory 持读锁将单个 Key 中时间戳不早于 since 的记录追加到 dst
+func (m *MetricsManager) appendKeyHistory(dst []RequestRecord, metrics *KeyMetrics, since time.Time) []RequestRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, record := range metrics.requestHistory {
+		if !record.Timestamp.Before(since) {
+			dst = append(dst, record)
+		}
+	}
+	return dst
+}
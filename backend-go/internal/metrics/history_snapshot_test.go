@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/types"
+)
+
+// seedHistory 为 keys 个 Key 各写入 perKey 条均匀分布在最近 23 小时内的已完成请求记录
+func seedHistory(m *MetricsManager, keys, perKey int) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k := 0; k < keys; k++ {
+		metrics := m.getOrCreateKey(fmt.Sprintf("https://api%d.example.com", k), fmt.Sprintf("sk-%d", k))
+		for i := 0; i < perKey; i++ {
+			metrics.requestHistory = append(metrics.requestHistory, RequestRecord{
+				Model:        fmt.Sprintf("model-%d", i%4),
+				Timestamp:    now.Add(-23 * time.Hour).Add(time.Duration(i) * 23 * time.Hour / time.Duration(perKey)),
+				Success:      i%10 != 0,
+				InputTokens:  100,
+				OutputTokens: 20,
+			})
+		}
+	}
+}
+
+func TestSnapshotHistory_CopiesRecordsInWindow(t *testing.T) {
+	m := NewMetricsManager()
+	defer m.Stop()
+
+	now := time.Now()
+	m.RecordRequestConnectedAt("https://a.example.com", "sk-a", "claude-old", now.Add(-2*time.Hour))
+	pendingID := m.RecordRequestConnectedAt("https://a.example.com", "sk-a", "claude-new", now.Add(-time.Minute))
+
+	records := m.snapshotHistory(now.Add(-time.Hour))
+	if len(records) != 1 || records[0].Model != "claude-new" {
+		t.Fatalf("snapshot = %+v, want 仅窗口内的 claude-new", records)
+	}
+
+	// 快照是值拷贝：之后回写进行中的记录不影响已取得的快照
+	m.RecordRequestFinalizeFailure("https://a.example.com", "sk-a", pendingID)
+	if !records[0].Success {
+		t.Fatalf("快照记录被后续回写修改")
+	}
+}
+
+// TestHistoryQueries_ConcurrentWithRecording 聚合查询与写入并发执行（配合 -race 检查数据竞争）
+func TestHistoryQueries_ConcurrentWithRecording(t *testing.T) {
+	m := NewMetricsManager()
+	defer m.Stop()
+	seedHistory(m, 4, 200)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			id := m.RecordRequestConnected("https://api0.example.com", "sk-0", "model-0")
+			if i%2 == 0 {
+				m.RecordRequestFinalizeSuccess("https://api0.example.com", "sk-0", id, &types.Usage{InputTokens: 10, OutputTokens: 5})
+			} else {
+				m.RecordRequestFinalizeFailure("https://api0.example.com", "sk-0", id)
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		m.GetModelStatsHistory(24*time.Hour, time.Hour)
+		m.GetAllKeysHistoricalStats(24*time.Hour, time.Hour)
+		m.GetGlobalHistoricalStatsWithTokens(24*time.Hour, time.Hour)
+		m.GetModelUsageSummary(24 * time.Hour)
+		m.GetUsageSummary(24 * time.Hour)
+	}
+	wg.Wait()
+
+	summary := m.GetModelUsageSummary(24 * time.Hour)
+	if got := summary["model-0"].RequestCount; got != 200+200 {
+		t.Fatalf("model-0 请求数 = %d, want 400", got)
+	}
+}
+
+// lockedModelUsageSummary 重构前的实现：整个聚合过程持有读锁（仅用于基准对比）
+func (m *MetricsManager) lockedModelUsageSummary(duration time.Duration) map[string]*ModelUsageSummary {
+	result := make(map[string]*ModelUsageSummary)
+	cutoff := time.Now().Add(-duration)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, metrics := range m.keyMetrics {
+		for _, record := range metrics.requestHistory {
+			if !record.Timestamp.After(cutoff) || record.Model == "" {
+				continue
+			}
+			summary, ok := result[record.Model]
+			if !ok {
+				summary = &ModelUsageSummary{Model: record.Model}
+				result[record.Model] = summary
+			}
+			summary.RequestCount++
+			if record.Success {
+				summary.SuccessCount++
+			} else {
+				summary.FailureCount++
+			}
+			summary.InputTokens += record.InputTokens
+			summary.OutputTokens += record.OutputTokens
+		}
+	}
+	return result
+}
+
+// BenchmarkHistoryQueryLockHold 单次持锁时间：重构前整段聚合都在读锁内，重构后每次只持锁拷贝一个 Key
+func BenchmarkHistoryQueryLockHold(b *testing.B) {
+	m := NewMetricsManager()
+	defer m.Stop()
+	seedHistory(m, 20, 5000)
+
+	b.Run("locked", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			m.lockedModelUsageSummary(24 * time.Hour)
+		}
+	})
+	b.Run("snapshot", func(b *testing.B) {
+		var key *KeyMetrics
+		for _, metrics := range m.keyMetrics {
+			key = metrics
+			break
+		}
+		dst := make([]RequestRecord, 0, len(key.requestHistory))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			dst = m.appendKeyHistory(dst[:0], key, time.Now().Add(-24*time.Hour))
+		}
+	})
+}
+
+// BenchmarkRecordDuringHistoryQueries 聚合查询持续进行时，一次写入（连接 + 回写）的耗时
+func BenchmarkRecordDuringHistoryQueries(b *testing.B) {
+	queries := map[string]func(m *MetricsManager){
+		"locked":   func(m *MetricsManager) { m.lockedModelUsageSummary(24 * time.Hour) },
+		"snapshot": func(m *MetricsManager) { m.GetModelUsageSummary(24 * time.Hour) },
+	}
+	for _, name := range []string{"locked", "snapshot"} {
+		query := queries[name]
+		b.Run(name, func(b *testing.B) {
+			m := NewMetricsManager()
+			defer m.Stop()
+			seedHistory(m, 20, 5000)
+
+			var wg sync.WaitGroup
+			stop := make(chan struct{})
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						query(m)
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				id := m.RecordRequestConnected("https://writer.example.com", "sk-writer", "model-0")
+				m.RecordRequestFinalizeSuccess("https://writer.example.com", "sk-writer", id, nil)
+			}
+			b.StopTimer()
+			close(stop)
+			wg.Wait()
+		})
+	}
+}
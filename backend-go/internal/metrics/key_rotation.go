@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"log"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/utils"
+)
+
+// CarryOverKey 轮换 Key 后将旧 Key 的指标迁移到新 Key，保持同一 Key 位置的统计连续
+// 迁移语义：
+//   - 累计计数（请求/成功/失败）、错误类型计数、最后成功/失败时间、24 小时请求历史合并到新 Key
+//   - 滑动窗口、连续失败数、熔断与 429 暂停状态不迁移：新 Key 是新凭证，从健康状态开始
+//   - 持久化记录的 metrics_key 同步改写，重启后历史仍归属新 Key
+//
+// 迁移后旧 Key 的内存指标被删除；仍在进行中的旧 Key 请求结束时会重新记录到旧 Key，
+// 因此其占位历史记录不迁移（否则会在新 Key 下残留一条永远不会回写结果的记录）
+// 返回迁移的 BaseURL 数量（旧 Key 在该 BaseURL 下无指标时跳过）
+func (m *MetricsManager) CarryOverKey(baseURLs []string, oldKey, newKey string) int {
+	type rename struct{ oldMetricsKey, newMetricsKey, keyMask string }
+	var renames []rename
+	carried := 0
+
+	m.mu.Lock()
+	store := m.store
+	for _, baseURL := range baseURLs {
+		oldMetricsKey := generateMetricsKey(baseURL, oldKey)
+		newMetricsKey := generateMetricsKey(baseURL, newKey)
+		renames = append(renames, rename{oldMetricsKey, newMetricsKey, utils.MaskAPIKey(newKey)})
+
+		old, exists := m.keyMetrics[oldMetricsKey]
+		if !exists {
+			continue
+		}
+		target := m.getOrCreateKey(baseURL, newKey)
+
+		target.RequestCount += old.RequestCount
+		target.SuccessCount += old.SuccessCount
+		target.FailureCount += old.FailureCount
+		target.expiredSuccessCount += old.expiredSuccessCount
+		target.expiredFailureCount += old.expiredFailureCount
+		if day := startOfDay(time.Now()); old.todayTokensDay.Equal(day) {
+			if !target.todayTokensDay.Equal(day) {
+				target.todayTokens = 0
+				target.todayTokensDay = day
+			}
+			target.todayTokens += old.todayTokens
+		}
+		if old.LastSuccessAt != nil && (target.LastSuccessAt == nil || old.LastSuccessAt.After(*target.LastSuccessAt)) {
+			target.LastSuccessAt = old.LastSuccessAt
+		}
+		if old.LastFailureAt != nil && (target.LastFailureAt == nil || old.LastFailureAt.After(*target.LastFailureAt)) {
+			target.LastFailureAt = old.LastFailureAt
+		}
+		for errType, count := range old.ErrorBreakdown {
+			if target.ErrorBreakdown == nil {
+				target.ErrorBreakdown = make(map[string]int64)
+			}
+			target.ErrorBreakdown[errType] += count
+		}
+
+		// 旧 Key 已完成的请求历史早于新 Key，拼接在前面；新 Key 进行中请求的索引同步后移
+		pending := make(map[int]bool, len(old.pendingHistoryIdx))
+		for _, idx := range old.pendingHistoryIdx {
+			pending[idx] = true
+		}
+		completed := make([]RequestRecord, 0, len(old.requestHistory)+len(target.requestHistory))
+		for i, record := range old.requestHistory {
+			if !pending[i] {
+				completed = append(completed, record)
+			}
+		}
+		if offset := len(completed); offset > 0 {
+			target.requestHistory = append(completed, target.requestHistory...)
+			for id, idx := range target.pendingHistoryIdx {
+				target.pendingHistoryIdx[id] = idx + offset
+			}
+		}
+
+		delete(m.keyMetrics, oldMetricsKey)
+		carried++
+	}
+	m.mu.Unlock()
+
+	if store != nil {
+		for _, r := range renames {
+			if _, err := store.RenameMetricsKey(r.oldMetricsKey, r.newMetricsKey, r.keyMask, m.apiType); err != nil {
+				log.Printf("[Metrics-Rotate] 警告: 迁移持久化指标记录失败: %v", err)
+			}
+		}
+	}
+
+	if carried > 0 {
+		log.Printf("[Metrics-Rotate] Key %s 的指标已迁移到 %s (%d 个 BaseURL)",
+			utils.MaskAPIKey(oldKey), utils.MaskAPIKey(newKey), carried)
+	}
+	return carried
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/types"
+)
+
+// TestCarryOverKey_SkipsPendingRecords 旧 Key 进行中请求的占位记录不迁移，结束时仍记录到旧 Key
+func TestCarryOverKey_SkipsPendingRecords(t *testing.T) {
+	m := NewMetricsManagerWithConfig(10, 0.5)
+	defer m.Stop()
+
+	baseURL := "https://example.com"
+	m.RecordSuccess(baseURL, "old")
+	id := m.RecordRequestConnected(baseURL, "old", "claude-test")
+	newID := m.RecordRequestConnected(baseURL, "new", "claude-test")
+
+	if carried := m.CarryOverKey([]string{baseURL}, "old", "new"); carried != 1 {
+		t.Fatalf("CarryOverKey() = %d, want 1", carried)
+	}
+
+	m.mu.RLock()
+	target := m.keyMetrics[generateMetricsKey(baseURL, "new")]
+	historyLen := len(target.requestHistory)
+	idx, pending := target.pendingHistoryIdx[newID]
+	m.mu.RUnlock()
+	if historyLen != 2 {
+		t.Fatalf("新 Key 历史条数 = %d, want 2（旧 Key 已完成 1 条 + 新 Key 进行中 1 条）", historyLen)
+	}
+	if !pending || idx != 1 {
+		t.Fatalf("新 Key 进行中请求索引 = %d (%v), want 1", idx, pending)
+	}
+
+	// 旧 Key 的进行中请求结束时重新记录到旧 Key，不影响新 Key
+	m.RecordRequestFinalizeSuccess(baseURL, "old", id, &types.Usage{InputTokens: 1, OutputTokens: 1})
+	m.RecordRequestFinalizeSuccess(baseURL, "new", newID, &types.Usage{InputTokens: 1, OutputTokens: 1})
+	if got := m.GetKeyMetrics(baseURL, "new"); got == nil || got.SuccessCount != 2 || got.RequestCount != 2 {
+		t.Fatalf("新 Key 指标异常: %+v", got)
+	}
+	if got := m.GetKeyMetrics(baseURL, "old"); got == nil || got.SuccessCount != 1 {
+		t.Fatalf("旧 Key 进行中请求应重新记录到旧 Key: %+v", got)
+	}
+}
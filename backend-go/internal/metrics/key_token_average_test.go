@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+// TestKeyMetricsResponse_AvgTokens 每 Key 平均 token 数按 24 小时窗口内的成功请求计算，多 BaseURL 时合并计算
+func TestKeyMetricsResponse_AvgTokens(t *testing.T) {
+	m := NewMetricsManager()
+	defer m.Stop()
+
+	now := time.Now()
+	seed := func(baseURL, apiKey string, records ...RequestRecord) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		metrics := m.getOrCreateKey(baseURL, apiKey)
+		metrics.requestHistory = append(metrics.requestHistory, records...)
+	}
+	seed("https://a.example.com", "sk-normal",
+		RequestRecord{Timestamp: now.Add(-time.Hour), Success: true, InputTokens: 100, OutputTokens: 10},
+		RequestRecord{Timestamp: now.Add(-time.Minute), Success: true, InputTokens: 300, OutputTokens: 30},
+		// 失败请求与窗口外的记录不参与平均
+		RequestRecord{Timestamp: now.Add(-time.Minute), Success: false},
+		RequestRecord{Timestamp: now.Add(-25 * time.Hour), Success: true, InputTokens: 99999, OutputTokens: 99999},
+	)
+	seed("https://a.example.com", "sk-large",
+		RequestRecord{Timestamp: now.Add(-time.Minute), Success: true, InputTokens: 50000, OutputTokens: 500},
+	)
+	seed("https://b.example.com", "sk-large",
+		RequestRecord{Timestamp: now.Add(-time.Minute), Success: true, InputTokens: 70000, OutputTokens: 700},
+	)
+
+	tests := []struct {
+		name       string
+		resp       *MetricsResponse
+		wantInput  map[string]float64
+		wantOutput map[string]float64
+	}{
+		{
+			name:       "单 BaseURL",
+			resp:       m.ToResponse(0, "https://a.example.com", []string{"sk-normal", "sk-large"}, 0),
+			wantInput:  map[string]float64{"sk-normal": 200, "sk-large": 50000},
+			wantOutput: map[string]float64{"sk-normal": 20, "sk-large": 500},
+		},
+		{
+			name:       "多 BaseURL 合并",
+			resp:       m.ToResponseMultiURL(0, []string{"https://a.example.com", "https://b.example.com"}, []string{"sk-normal", "sk-large"}, 0),
+			wantInput:  map[string]float64{"sk-normal": 200, "sk-large": 60000},
+			wantOutput: map[string]float64{"sk-normal": 20, "sk-large": 600},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.resp.KeyMetrics) != 2 {
+				t.Fatalf("KeyMetrics 数量 = %d, want 2", len(tt.resp.KeyMetrics))
+			}
+			for i, apiKey := range []string{"sk-normal", "sk-large"} {
+				km := tt.resp.KeyMetrics[i]
+				if km.AvgInputTokens != tt.wantInput[apiKey] || km.AvgOutputTokens != tt.wantOutput[apiKey] {
+					t.Fatalf("%s 平均 token = %v/%v, want %v/%v", apiKey, km.AvgInputTokens, km.AvgOutputTokens, tt.wantInput[apiKey], tt.wantOutput[apiKey])
+				}
+			}
+		})
+	}
+}
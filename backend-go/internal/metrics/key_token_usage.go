@@ -0,0 +1,49 @@
+package metrics
+
+import "time"
+
+// KeyTokenUsage 单个 Key（BaseURL + Key 组合）在时间窗口内的 token 用量
+type KeyTokenUsage struct {
+	MetricsKey               string `json:"metricsKey"`
+	BaseURL                  string `json:"baseUrl"`
+	KeyMask                  string `json:"keyMask"`
+	RequestCount             int64  `json:"requestCount"`
+	InputTokens              int64  `json:"inputTokens"`
+	OutputTokens             int64  `json:"outputTokens"`
+	CacheCreationInputTokens int64  `json:"cacheCreationInputTokens"`
+	CacheReadInputTokens     int64  `json:"cacheReadInputTokens"`
+	TotalTokens              int64  `json:"totalTokens"` // input + output + 缓存创建 + 缓存读取
+}
+
+// GetKeyTokenUsage 按 Key 汇总时间窗口内的 token 用量（基于内存中的请求历史，最多 24 小时）
+// 窗口内没有请求的 Key 不返回
+func (m *MetricsManager) GetKeyTokenUsage(duration time.Duration) []KeyTokenUsage {
+	if duration <= 0 {
+		return nil
+	}
+	cutoff := time.Now().Add(-duration)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]KeyTokenUsage, 0, len(m.keyMetrics))
+	for metricsKey, km := range m.keyMetrics {
+		usage := KeyTokenUsage{MetricsKey: metricsKey, BaseURL: km.BaseURL, KeyMask: km.KeyMask}
+		for _, record := range km.requestHistory {
+			if !record.Timestamp.After(cutoff) {
+				continue
+			}
+			usage.RequestCount++
+			usage.InputTokens += record.InputTokens
+			usage.OutputTokens += record.OutputTokens
+			usage.CacheCreationInputTokens += record.CacheCreationInputTokens
+			usage.CacheReadInputTokens += record.CacheReadInputTokens
+		}
+		if usage.RequestCount == 0 {
+			continue
+		}
+		usage.TotalTokens = usage.InputTokens + usage.OutputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens
+		result = append(result, usage)
+	}
+	return result
+}
@@ -12,10 +12,19 @@ type PersistenceStore interface {
 	// LoadRecords 加载指定时间范围内的记录
 	LoadRecords(since time.Time, apiType string) ([]PersistentRecord, error)
 
+	// IterateRecords 按 metrics_key、时间顺序逐条遍历 before 之前的记录（用于导出归档，不整体加载到内存）
+	IterateRecords(before time.Time, apiType string, fn func(PersistentRecord) error) error
+
+	// LoadKeyRecords 加载单个 Key 在 [from, to] 时间范围内的记录（用于导入归档时去重）
+	LoadKeyRecords(metricsKey, apiType string, from, to time.Time) ([]PersistentRecord, error)
+
 	// LoadLatestTimestamps 从全量历史记录中查询每个 key 的最后成功/失败时间
 	// 用于启动时补全超出 24h 窗口的时间戳
 	LoadLatestTimestamps(apiType string) (map[string]*KeyLatestTimestamps, error)
 
+	// RetentionDays 数据保留天数（决定可查询的最长时间范围）
+	RetentionDays() int
+
 	// CleanupOldRecords 清理过期数据
 	CleanupOldRecords(before time.Time) (int64, error)
 
@@ -23,6 +32,12 @@ type PersistenceStore interface {
 	// apiType: 接口类型（messages/responses/gemini），避免误删其他接口的数据
 	DeleteRecordsByMetricsKeys(metricsKeys []string, apiType string) (int64, error)
 
+	// RenameMetricsKey 将记录从旧 metrics_key 改写到新 metrics_key（用于轮换 Key 时保持统计连续）
+	RenameMetricsKey(oldMetricsKey, newMetricsKey, keyMask, apiType string) (int64, error)
+
+	// Flush 立即将写入缓冲区刷入存储，并等待进行中的异步刷新完成
+	Flush()
+
 	// Close 关闭存储（会先刷新缓冲区）
 	Close() error
 }
@@ -46,6 +61,7 @@ type PersistentRecord struct {
 	OutputTokens        int64     // 输出 Token 数
 	CacheCreationTokens int64     // 缓存创建 Token
 	CacheReadTokens     int64     // 缓存读取 Token
+	ThinkingTokens      int64     // 扩展思考 Token
 	Model               string    // 请求模型
 	APIType             string    // "messages"、"responses" 或 "gemini"
 }
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"log"
+)
+
+// CounterCorrection 单个 Key 聚合计数的修正记录
+type CounterCorrection struct {
+	MetricsKey      string `json:"metricsKey"`
+	BaseURL         string `json:"baseUrl"`
+	KeyMask         string `json:"keyMask"`
+	OldRequestCount int64  `json:"oldRequestCount"`
+	OldSuccessCount int64  `json:"oldSuccessCount"`
+	OldFailureCount int64  `json:"oldFailureCount"`
+	RequestCount    int64  `json:"requestCount"`
+	SuccessCount    int64  `json:"successCount"`
+	FailureCount    int64  `json:"failureCount"`
+}
+
+// ReconcileCounters 按 requestHistory 与已过期移出历史的请求数重新计算每个 Key 的 RequestCount/SuccessCount/FailureCount，
+// 修正 finalize 路径遗漏或重复计数造成的偏差，返回发生修正的 Key 列表。
+// 累计计数覆盖 Key 的整个生命周期（启动时从最近 24 小时的持久化记录重建），历史记录只保留 24 小时，
+// 因此过期记录在清理时计入 expired 计数，对账时与历史记录合并后再与累计计数比较，避免把累计计数截断为 24 小时窗口。
+// 说明：进行中的请求尚未确定结果，不参与计数；客户端取消的请求不保留在历史中，修正后不再计入 RequestCount。
+func (m *MetricsManager) ReconcileCounters() []CounterCorrection {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var corrections []CounterCorrection
+
+	for _, metrics := range m.keyMetrics {
+		pending := make(map[int]bool, len(metrics.pendingHistoryIdx))
+		for _, idx := range metrics.pendingHistoryIdx {
+			pending[idx] = true
+		}
+
+		successCount, failureCount := metrics.expiredSuccessCount, metrics.expiredFailureCount
+		for i, record := range metrics.requestHistory {
+			if pending[i] {
+				continue
+			}
+			if record.Success {
+				successCount++
+			} else {
+				failureCount++
+			}
+		}
+		requestCount := successCount + failureCount
+
+		if metrics.RequestCount == requestCount && metrics.SuccessCount == successCount && metrics.FailureCount == failureCount {
+			continue
+		}
+
+		correction := CounterCorrection{
+			MetricsKey:      metrics.MetricsKey,
+			BaseURL:         metrics.BaseURL,
+			KeyMask:         metrics.KeyMask,
+			OldRequestCount: metrics.RequestCount,
+			OldSuccessCount: metrics.SuccessCount,
+			OldFailureCount: metrics.FailureCount,
+			RequestCount:    requestCount,
+			SuccessCount:    successCount,
+			FailureCount:    failureCount,
+		}
+		corrections = append(corrections, correction)
+		log.Printf("[Metrics-Reconcile] [%s] Key [%s] (%s) 计数已修正: 请求 %d→%d, 成功 %d→%d, 失败 %d→%d",
+			m.apiType, metrics.KeyMask, metrics.BaseURL,
+			correction.OldRequestCount, requestCount,
+			correction.OldSuccessCount, successCount,
+			correction.OldFailureCount, failureCount)
+
+		metrics.RequestCount = requestCount
+		metrics.SuccessCount = successCount
+		metrics.FailureCount = failureCount
+	}
+
+	return corrections
+}
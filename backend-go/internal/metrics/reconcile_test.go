@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricsManager_ReconcileCounters(t *testing.T) {
+	m := NewMetricsManager()
+	defer m.Stop()
+
+	const baseURL, apiKey = "https://a.example.com", "sk-a"
+	for i := 0; i < 3; i++ {
+		id := m.RecordRequestConnected(baseURL, apiKey, "claude-test")
+		m.RecordRequestFinalizeSuccess(baseURL, apiKey, id, nil)
+	}
+	id := m.RecordRequestConnected(baseURL, apiKey, "claude-test")
+	m.RecordRequestFinalizeFailure(baseURL, apiKey, id)
+	// 进行中的请求不参与计数
+	m.RecordRequestConnected(baseURL, apiKey, "claude-test")
+
+	// 另一个 Key 计数一致，不应被修正
+	id = m.RecordRequestConnected("https://b.example.com", "sk-b", "claude-test")
+	m.RecordRequestFinalizeSuccess("https://b.example.com", "sk-b", id, nil)
+
+	if corrections := m.ReconcileCounters(); len(corrections) != 0 {
+		t.Fatalf("计数一致时不应修正, got %+v", corrections)
+	}
+
+	// 人为制造计数偏差
+	m.mu.Lock()
+	metrics := m.keyMetrics[generateMetricsKey(baseURL, apiKey)]
+	metrics.RequestCount = 10
+	metrics.SuccessCount = 8
+	metrics.FailureCount = 0
+	m.mu.Unlock()
+
+	corrections := m.ReconcileCounters()
+	if len(corrections) != 1 {
+		t.Fatalf("修正数=%d, want 1: %+v", len(corrections), corrections)
+	}
+	got := corrections[0]
+	if got.OldRequestCount != 10 || got.OldSuccessCount != 8 || got.OldFailureCount != 0 {
+		t.Fatalf("修正前计数=%+v", got)
+	}
+	if got.RequestCount != 4 || got.SuccessCount != 3 || got.FailureCount != 1 {
+		t.Fatalf("修正后计数=%+v, want 4/3/1", got)
+	}
+
+	km := m.GetKeyMetrics(baseURL, apiKey)
+	if km.RequestCount != 4 || km.SuccessCount != 3 || km.FailureCount != 1 {
+		t.Fatalf("Key 指标未更新: request=%d success=%d failure=%d", km.RequestCount, km.SuccessCount, km.FailureCount)
+	}
+
+	// 过期移出历史的记录仍计入累计计数，不应被截断为 24 小时窗口
+	m.mu.Lock()
+	metrics.requestHistory[0].Timestamp = time.Now().Add(-25 * time.Hour)
+	m.cleanupHistoryLocked(metrics)
+	m.mu.Unlock()
+	if corrections := m.ReconcileCounters(); len(corrections) != 0 {
+		t.Fatalf("过期记录清理后累计计数不应被修正, got %+v", corrections)
+	}
+
+	// 过期计数参与对账：累计计数偏差仍能被修正
+	m.mu.Lock()
+	metrics.SuccessCount = 1
+	m.mu.Unlock()
+	if corrections := m.ReconcileCounters(); len(corrections) != 1 || corrections[0].SuccessCount != 3 {
+		t.Fatalf("修正后成功数应包含过期记录, got %+v", corrections)
+	}
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// MetricsSnapshotVersion 当前快照格式版本（结构不兼容变更时递增）
+const MetricsSnapshotVersion = 1
+
+// ErrSnapshotVersion 快照版本与当前实例不兼容
+var ErrSnapshotVersion = errors.New("unsupported metrics snapshot version")
+
+// metricsSnapshot MetricsManager 内存状态的可序列化快照（用于蓝绿部署时迁移实时指标）
+type metricsSnapshot struct {
+	Version   int                  `json:"version"`
+	APIType   string               `json:"apiType,omitempty"`
+	CreatedAt time.Time            `json:"createdAt"`
+	Keys      []keyMetricsSnapshot `json:"keys"`
+}
+
+// keyMetricsSnapshot 单个 Key 的完整指标（含滑动窗口、请求历史与熔断事件）
+// 进行中的请求（ActiveRequests / pendingHistoryIdx）属于进程内状态，不参与迁移
+type keyMetricsSnapshot struct {
+	MetricsKey          string           `json:"metricsKey"`
+	BaseURL             string           `json:"baseUrl"`
+	KeyMask             string           `json:"keyMask"`
+	RequestCount        int64            `json:"requestCount"`
+	SuccessCount        int64            `json:"successCount"`
+	FailureCount        int64            `json:"failureCount"`
+	ConsecutiveFailures int64            `json:"consecutiveFailures"`
+	LastSuccessAt       *time.Time       `json:"lastSuccessAt,omitempty"`
+	LastFailureAt       *time.Time       `json:"lastFailureAt,omitempty"`
+	CircuitBrokenAt     *time.Time       `json:"circuitBrokenAt,omitempty"`
+	SuspendedUntil      *time.Time       `json:"suspendedUntil,omitempty"`
+	ErrorBreakdown      map[string]int64 `json:"errorBreakdown,omitempty"`
+	RecentResults       []bool           `json:"recentResults,omitempty"`
+	RequestHistory      []RequestRecord  `json:"requestHistory,omitempty"`
+	CircuitEvents       []CircuitEvent   `json:"circuitEvents,omitempty"`
+}
+
+// Snapshot 序列化全部内存指标为带版本号的 JSON（不经过持久化存储）
+func (m *MetricsManager) Snapshot() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshot := metricsSnapshot{
+		Version:   MetricsSnapshotVersion,
+		APIType:   m.apiType,
+		CreatedAt: time.Now(),
+		Keys:      make([]keyMetricsSnapshot, 0, len(m.keyMetrics)),
+	}
+	for _, metrics := range m.keyMetrics {
+		snapshot.Keys = append(snapshot.Keys, keyMetricsSnapshot{
+			MetricsKey:          metrics.MetricsKey,
+			BaseURL:             metrics.BaseURL,
+			KeyMask:             metrics.KeyMask,
+			RequestCount:        metrics.RequestCount,
+			SuccessCount:        metrics.SuccessCount,
+			FailureCount:        metrics.FailureCount,
+			ConsecutiveFailures: metrics.ConsecutiveFailures,
+			LastSuccessAt:       metrics.LastSuccessAt,
+			LastFailureAt:       metrics.LastFailureAt,
+			CircuitBrokenAt:     metrics.CircuitBrokenAt,
+			SuspendedUntil:      metrics.SuspendedUntil,
+			ErrorBreakdown:      metrics.ErrorBreakdown,
+			RecentResults:       metrics.recentResults,
+			RequestHistory:      metrics.requestHistory,
+			CircuitEvents:       metrics.circuitEvents,
+		})
+	}
+	// 持锁序列化，避免与后续写入共享底层切片
+	return json.Marshal(snapshot)
+}
+
+// Restore 用快照替换当前内存指标
+// 快照无法解析或版本不兼容时返回错误，且不修改现有状态
+func (m *MetricsManager) Restore(data []byte) error {
+	var snapshot metricsSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("解析指标快照失败: %w", err)
+	}
+	if snapshot.Version != MetricsSnapshotVersion {
+		return fmt.Errorf("%w: %d (当前版本 %d)", ErrSnapshotVersion, snapshot.Version, MetricsSnapshotVersion)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if snapshot.APIType != "" && m.apiType != "" && snapshot.APIType != m.apiType {
+		return fmt.Errorf("指标快照类型不匹配: %s (当前 %s)", snapshot.APIType, m.apiType)
+	}
+
+	keyMetrics := make(map[string]*KeyMetrics, len(snapshot.Keys))
+	for _, k := range snapshot.Keys {
+		if k.MetricsKey == "" {
+			continue
+		}
+		// 滑动窗口按当前实例的 windowSize 截断
+		recentResults := k.RecentResults
+		if len(recentResults) > m.windowSize {
+			recentResults = recentResults[len(recentResults)-m.windowSize:]
+		}
+		keyMetrics[k.MetricsKey] = &KeyMetrics{
+			MetricsKey:          k.MetricsKey,
+			BaseURL:             k.BaseURL,
+			KeyMask:             k.KeyMask,
+			RequestCount:        k.RequestCount,
+			SuccessCount:        k.SuccessCount,
+			FailureCount:        k.FailureCount,
+			ConsecutiveFailures: k.ConsecutiveFailures,
+			LastSuccessAt:       k.LastSuccessAt,
+			LastFailureAt:       k.LastFailureAt,
+			CircuitBrokenAt:     k.CircuitBrokenAt,
+			SuspendedUntil:      k.SuspendedUntil,
+			ErrorBreakdown:      k.ErrorBreakdown,
+			recentResults:       append(make([]bool, 0, m.windowSize), recentResults...),
+			requestHistory:      k.RequestHistory,
+			pendingHistoryIdx:   make(map[uint64]int),
+			circuitEvents:       k.CircuitEvents,
+		}
+	}
+	m.keyMetrics = keyMetrics
+
+	log.Printf("[Metrics-Restore] [%s] 已从快照恢复 %d 个 Key 的指标 (快照时间: %s)",
+		m.apiType, len(keyMetrics), snapshot.CreatedAt.Format(time.RFC3339))
+	return nil
+}
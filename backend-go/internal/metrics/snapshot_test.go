@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/types"
+)
+
+func TestSnapshotRestore_RoundTrip(t *testing.T) {
+	const (
+		urlA = "https://a.example.com"
+		urlB = "https://b.example.com"
+	)
+
+	src := NewMetricsManagerWithConfig(5, 0.5)
+	defer src.Stop()
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		id := src.RecordRequestConnectedAt(urlA, "sk-a", "claude-sonnet", now.Add(-time.Duration(i)*time.Minute))
+		src.RecordRequestFinalizeSuccess(urlA, "sk-a", id, &types.Usage{InputTokens: 100, OutputTokens: 20, CacheReadInputTokens: 50})
+	}
+	// Key B 连续失败进入熔断，并被上游限流暂停
+	for i := 0; i < 6; i++ {
+		id := src.RecordRequestConnected(urlB, "sk-b", "gpt-4o")
+		src.RecordRequestFinalizeFailure(urlB, "sk-b", id)
+	}
+	src.SuspendKeyUntil(urlB, "sk-b", now.Add(time.Minute))
+
+	data, err := src.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot 失败: %v", err)
+	}
+
+	dst := NewMetricsManagerWithConfig(5, 0.5)
+	defer dst.Stop()
+	if err := dst.Restore(data); err != nil {
+		t.Fatalf("Restore 失败: %v", err)
+	}
+
+	for _, k := range []struct{ baseURL, apiKey string }{{urlA, "sk-a"}, {urlB, "sk-b"}} {
+		want := src.GetKeyMetrics(k.baseURL, k.apiKey)
+		got := dst.GetKeyMetrics(k.baseURL, k.apiKey)
+		if got == nil {
+			t.Fatalf("%s 恢复后缺失", k.apiKey)
+		}
+		wantJSON, _ := json.Marshal(want)
+		gotJSON, _ := json.Marshal(got)
+		if string(wantJSON) != string(gotJSON) {
+			t.Fatalf("%s 指标不一致:\n got=%s\nwant=%s", k.apiKey, gotJSON, wantJSON)
+		}
+		if !reflect.DeepEqual(dst.GetAllTimeWindowStatsForKey(k.baseURL, k.apiKey), src.GetAllTimeWindowStatsForKey(k.baseURL, k.apiKey)) {
+			t.Fatalf("%s 分时段统计不一致", k.apiKey)
+		}
+		if dst.CalculateKeyFailureRate(k.baseURL, k.apiKey) != src.CalculateKeyFailureRate(k.baseURL, k.apiKey) {
+			t.Fatalf("%s 滑动窗口失败率不一致", k.apiKey)
+		}
+		if dst.ShouldSuspendKey(k.baseURL, k.apiKey) != src.ShouldSuspendKey(k.baseURL, k.apiKey) {
+			t.Fatalf("%s 熔断状态不一致", k.apiKey)
+		}
+	}
+	if !dst.IsKeyRateLimited(urlB, "sk-b") {
+		t.Fatalf("限流暂停状态应随快照迁移")
+	}
+	if !reflect.DeepEqual(dst.GetModelUsageSummary(24*time.Hour), src.GetModelUsageSummary(24*time.Hour)) {
+		t.Fatalf("模型用量汇总不一致")
+	}
+}
+
+func TestSnapshotRestore_InvalidSnapshotKeepsState(t *testing.T) {
+	m := NewMetricsManager()
+	defer m.Stop()
+	m.RecordSuccess("https://a.example.com", "sk-a")
+
+	tests := []struct {
+		name        string
+		data        []byte
+		wantVersion bool
+	}{
+		{name: "未来版本", data: []byte(`{"version":99,"keys":[]}`), wantVersion: true},
+		{name: "缺少版本号", data: []byte(`{"keys":[]}`), wantVersion: true},
+		{name: "非法 JSON", data: []byte(`not json`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.Restore(tt.data)
+			if err == nil {
+				t.Fatalf("Restore 应返回错误")
+			}
+			if errors.Is(err, ErrSnapshotVersion) != tt.wantVersion {
+				t.Fatalf("err=%v, want ErrSnapshotVersion=%v", err, tt.wantVersion)
+			}
+			if got := m.GetKeyMetrics("https://a.example.com", "sk-a"); got == nil || got.RequestCount != 1 {
+				t.Fatalf("恢复失败后不应修改现有指标: %+v", got)
+			}
+		})
+	}
+}
@@ -30,6 +30,7 @@ type SQLiteStore struct {
 
 	// 控制
 	stopCh       chan struct{}
+	cleanedUp    chan struct{} // 启动时的首次清理完成后关闭
 	wg           sync.WaitGroup
 	closed       bool           // 是否已关闭
 	flushMu      sync.Mutex     // 串行化 flush 与 delete 操作，避免并发竞态
@@ -98,6 +99,7 @@ func NewSQLiteStore(cfg *SQLiteStoreConfig) (*SQLiteStore, error) {
 		flushInterval: defaultFlushInterval,
 		retentionDays: cfg.RetentionDays,
 		stopCh:        make(chan struct{}),
+		cleanedUp:     make(chan struct{}),
 	}
 
 	// 启动后台任务
@@ -127,9 +129,9 @@ func initSchema(db *sql.DB) error {
 			api_type TEXT NOT NULL DEFAULT 'messages'
 		);
 
-		-- 索引：按 api_type 和时间查询
-		CREATE INDEX IF NOT EXISTS idx_records_api_type_timestamp
-			ON request_records(api_type, timestamp);
+		-- 索引：按 api_type 和时间查询（附带 metrics_key，按 Key 聚合时无需回表）
+		CREATE INDEX IF NOT EXISTS idx_records_api_type_timestamp_key
+			ON request_records(api_type, timestamp, metrics_key);
 
 		-- 索引：按 metrics_key 查询
 		CREATE INDEX IF NOT EXISTS idx_records_metrics_key
@@ -161,6 +163,34 @@ func initSchema(db *sql.DB) error {
 		log.Printf("[SQLite-Migration] schema 升级: v0 -> v1 (添加 model 列)")
 	}
 
+	if version < 2 {
+		// v1 -> v2: 添加 thinking_tokens 列
+		migrations := []string{
+			"ALTER TABLE request_records ADD COLUMN thinking_tokens INTEGER DEFAULT 0",
+			"PRAGMA user_version = 2",
+		}
+		for _, sql := range migrations {
+			if _, err := db.Exec(sql); err != nil {
+				return fmt.Errorf("migration v1->v2 failed: %w", err)
+			}
+		}
+		log.Printf("[SQLite-Migration] schema 升级: v1 -> v2 (添加 thinking_tokens 列)")
+	}
+
+	if version < 3 {
+		// v2 -> v3: (api_type, timestamp) 索引被 (api_type, timestamp, metrics_key) 覆盖，删除旧索引
+		migrations := []string{
+			"DROP INDEX IF EXISTS idx_records_api_type_timestamp",
+			"PRAGMA user_version = 3",
+		}
+		for _, sql := range migrations {
+			if _, err := db.Exec(sql); err != nil {
+				return fmt.Errorf("migration v2->v3 failed: %w", err)
+			}
+		}
+		log.Printf("[SQLite-Migration] schema 升级: v2 -> v3 (索引扩展为 api_type, timestamp, metrics_key)")
+	}
+
 	return nil
 }
 
@@ -217,6 +247,14 @@ func (s *SQLiteStore) flush() {
 	}
 }
 
+// Flush 立即刷新写入缓冲区（等待 AddRecord 触发的异步 flush 完成后再同步刷新剩余数据）
+func (s *SQLiteStore) Flush() {
+	s.asyncFlushWg.Wait()
+	s.flushMu.Lock()
+	s.flush()
+	s.flushMu.Unlock()
+}
+
 // batchInsertRecords 批量插入记录
 func (s *SQLiteStore) batchInsertRecords(records []PersistentRecord) error {
 	if len(records) == 0 {
@@ -232,8 +270,8 @@ func (s *SQLiteStore) batchInsertRecords(records []PersistentRecord) error {
 	stmt, err := tx.Prepare(`
 		INSERT INTO request_records
 		(metrics_key, base_url, key_mask, timestamp, success,
-		 input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens, api_type, model)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens, api_type, model, thinking_tokens)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return err
@@ -247,7 +285,7 @@ func (s *SQLiteStore) batchInsertRecords(records []PersistentRecord) error {
 		}
 		_, err := stmt.Exec(
 			r.MetricsKey, r.BaseURL, r.KeyMask, r.Timestamp.Unix(), success,
-			r.InputTokens, r.OutputTokens, r.CacheCreationTokens, r.CacheReadTokens, r.APIType, r.Model,
+			r.InputTokens, r.OutputTokens, r.CacheCreationTokens, r.CacheReadTokens, r.APIType, r.Model, r.ThinkingTokens,
 		)
 		if err != nil {
 			return err
@@ -261,7 +299,7 @@ func (s *SQLiteStore) batchInsertRecords(records []PersistentRecord) error {
 func (s *SQLiteStore) LoadRecords(since time.Time, apiType string) ([]PersistentRecord, error) {
 	rows, err := s.db.Query(`
 		SELECT metrics_key, base_url, key_mask, timestamp, success,
-		       input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens, model
+		       input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens, model, thinking_tokens
 		FROM request_records
 		WHERE timestamp >= ? AND api_type = ?
 		ORDER BY timestamp ASC
@@ -279,7 +317,7 @@ func (s *SQLiteStore) LoadRecords(since time.Time, apiType string) ([]Persistent
 
 		err := rows.Scan(
 			&r.MetricsKey, &r.BaseURL, &r.KeyMask, &ts, &success,
-			&r.InputTokens, &r.OutputTokens, &r.CacheCreationTokens, &r.CacheReadTokens, &r.Model,
+			&r.InputTokens, &r.OutputTokens, &r.CacheCreationTokens, &r.CacheReadTokens, &r.Model, &r.ThinkingTokens,
 		)
 		if err != nil {
 			return nil, err
@@ -294,6 +332,74 @@ func (s *SQLiteStore) LoadRecords(since time.Time, apiType string) ([]Persistent
 	return records, rows.Err()
 }
 
+// IterateRecords 按 metrics_key、时间顺序逐条遍历 before 之前的记录
+func (s *SQLiteStore) IterateRecords(before time.Time, apiType string, fn func(PersistentRecord) error) error {
+	rows, err := s.db.Query(`
+		SELECT metrics_key, base_url, key_mask, timestamp, success,
+		       input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens, model, thinking_tokens
+		FROM request_records
+		WHERE timestamp < ? AND api_type = ?
+		ORDER BY metrics_key ASC, timestamp ASC, id ASC
+	`, before.Unix(), apiType)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := scanPersistentRecord(rows, apiType)
+		if err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// LoadKeyRecords 加载单个 Key 在 [from, to] 时间范围内的记录
+func (s *SQLiteStore) LoadKeyRecords(metricsKey, apiType string, from, to time.Time) ([]PersistentRecord, error) {
+	rows, err := s.db.Query(`
+		SELECT metrics_key, base_url, key_mask, timestamp, success,
+		       input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens, model, thinking_tokens
+		FROM request_records
+		WHERE metrics_key = ? AND api_type = ? AND timestamp >= ? AND timestamp <= ?
+		ORDER BY timestamp ASC
+	`, metricsKey, apiType, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []PersistentRecord
+	for rows.Next() {
+		r, err := scanPersistentRecord(rows, apiType)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// scanPersistentRecord 解析一行 request_records（列顺序与 LoadRecords 一致）
+func scanPersistentRecord(rows *sql.Rows, apiType string) (PersistentRecord, error) {
+	var r PersistentRecord
+	var ts int64
+	var success int
+	if err := rows.Scan(
+		&r.MetricsKey, &r.BaseURL, &r.KeyMask, &ts, &success,
+		&r.InputTokens, &r.OutputTokens, &r.CacheCreationTokens, &r.CacheReadTokens, &r.Model, &r.ThinkingTokens,
+	); err != nil {
+		return r, err
+	}
+	r.Timestamp = time.Unix(ts, 0)
+	r.Success = success == 1
+	r.APIType = apiType
+	return r, nil
+}
+
 // LoadLatestTimestamps 从全量历史记录中查询每个 key 的最后成功/失败时间
 func (s *SQLiteStore) LoadLatestTimestamps(apiType string) (map[string]*KeyLatestTimestamps, error) {
 	rows, err := s.db.Query(`
@@ -401,6 +507,23 @@ func (s *SQLiteStore) DeleteRecordsByMetricsKeys(metricsKeys []string, apiType s
 	return totalDeleted, nil
 }
 
+// RenameMetricsKey 将记录从旧 metrics_key 改写到新 metrics_key，同时更新脱敏 Key
+func (s *SQLiteStore) RenameMetricsKey(oldMetricsKey, newMetricsKey, keyMask, apiType string) (int64, error) {
+	// 获取 flush 锁并先刷新缓冲区，确保缓冲中的旧 Key 记录一并改写
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	s.flush()
+
+	result, err := s.db.Exec(
+		"UPDATE request_records SET metrics_key = ?, key_mask = ? WHERE api_type = ? AND metrics_key = ?",
+		newMetricsKey, keyMask, apiType, oldMetricsKey,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // flushLoop 定时刷新循环
 func (s *SQLiteStore) flushLoop() {
 	defer s.wg.Done()
@@ -430,6 +553,7 @@ func (s *SQLiteStore) cleanupLoop() {
 
 	// 启动时先清理一次
 	s.doCleanup()
+	close(s.cleanedUp)
 
 	ticker := time.NewTicker(1 * time.Hour)
 	defer ticker.Stop()
@@ -455,6 +579,11 @@ func (s *SQLiteStore) doCleanup() {
 	}
 }
 
+// RetentionDays 返回数据保留天数
+func (s *SQLiteStore) RetentionDays() int {
+	return s.retentionDays
+}
+
 // Close 关闭存储
 func (s *SQLiteStore) Close() error {
 	// 标记为已关闭，阻止新记录
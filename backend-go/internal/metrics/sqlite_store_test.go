@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestSQLiteStore(t *testing.T, dbPath string) *SQLiteStore {
+	t.Helper()
+	store, err := NewSQLiteStore(&SQLiteStoreConfig{DBPath: dbPath, RetentionDays: 7})
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	// 等待后台启动清理完成，避免与测试写入的过期记录竞争
+	<-store.cleanedUp
+	return store
+}
+
+// TestSQLiteStore_AddLoadDeleteRoundTrip 写入的记录可按类型加载，按 metrics_key 删除时不影响其他类型
+func TestSQLiteStore_AddLoadDeleteRoundTrip(t *testing.T) {
+	store := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "nested", "metrics.db"))
+	defer store.Close()
+
+	now := time.Now().Truncate(time.Second)
+	records := []PersistentRecord{
+		{MetricsKey: "mk-a", BaseURL: "https://a.example.com", KeyMask: "sk-a***", Timestamp: now.Add(-2 * time.Minute), Success: true, InputTokens: 10, OutputTokens: 5, CacheReadTokens: 3, ThinkingTokens: 2, Model: "claude-sonnet", APIType: "messages"},
+		{MetricsKey: "mk-b", BaseURL: "https://b.example.com", KeyMask: "sk-b***", Timestamp: now.Add(-time.Minute), Success: false, Model: "claude-haiku", APIType: "messages"},
+		{MetricsKey: "mk-a", BaseURL: "https://a.example.com", KeyMask: "sk-a***", Timestamp: now, Success: true, InputTokens: 7, APIType: "chat"},
+	}
+	for _, record := range records {
+		store.AddRecord(record)
+	}
+	store.Flush()
+
+	loaded, err := store.LoadRecords(now.Add(-time.Hour), "messages")
+	if err != nil {
+		t.Fatalf("LoadRecords() err = %v", err)
+	}
+	if len(loaded) != 2 {
+		t.Fatalf("messages 记录数 = %d, want 2", len(loaded))
+	}
+	if loaded[0] != records[0] || loaded[1] != records[1] {
+		t.Fatalf("加载结果与写入不一致:\n got  %+v\n want %+v", loaded, records[:2])
+	}
+
+	deleted, err := store.DeleteRecordsByMetricsKeys([]string{"mk-a"}, "messages")
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteRecordsByMetricsKeys() = %d, %v, want 1", deleted, err)
+	}
+	if loaded, _ := store.LoadRecords(now.Add(-time.Hour), "messages"); len(loaded) != 1 || loaded[0].MetricsKey != "mk-b" {
+		t.Fatalf("删除后 messages 记录 = %+v, want 仅 mk-b", loaded)
+	}
+	if loaded, _ := store.LoadRecords(now.Add(-time.Hour), "chat"); len(loaded) != 1 {
+		t.Fatalf("chat 记录数 = %d, want 1（删除不应跨类型）", len(loaded))
+	}
+}
+
+// TestSQLiteStore_RetentionWindow 按时间窗口加载记录，过期记录由清理删除
+func TestSQLiteStore_RetentionWindow(t *testing.T) {
+	store := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "metrics.db"))
+	defer store.Close()
+
+	now := time.Now()
+	for _, age := range []time.Duration{10 * 24 * time.Hour, 3 * 24 * time.Hour, time.Hour} {
+		store.AddRecord(PersistentRecord{MetricsKey: "mk", BaseURL: "https://a.example.com", KeyMask: "sk", Timestamp: now.Add(-age), Success: true, APIType: "messages"})
+	}
+	store.Flush()
+
+	tests := []struct {
+		since time.Duration
+		want  int
+	}{
+		{since: 2 * time.Hour, want: 1},
+		{since: 4 * 24 * time.Hour, want: 2},
+		{since: 30 * 24 * time.Hour, want: 3},
+	}
+	for _, tt := range tests {
+		loaded, err := store.LoadRecords(now.Add(-tt.since), "messages")
+		if err != nil || len(loaded) != tt.want {
+			t.Fatalf("LoadRecords(-%v) = %d 条, %v, want %d", tt.since, len(loaded), err, tt.want)
+		}
+	}
+
+	deleted, err := store.CleanupOldRecords(now.Add(-time.Duration(store.RetentionDays()) * 24 * time.Hour))
+	if err != nil || deleted != 1 {
+		t.Fatalf("CleanupOldRecords() = %d, %v, want 1", deleted, err)
+	}
+	if count, _ := store.GetRecordCount(); count != 2 {
+		t.Fatalf("清理后记录数 = %d, want 2", count)
+	}
+}
+
+// TestSQLiteStore_BatchedAsyncWrite 缓冲区达到批量阈值时异步写入，无需显式 Flush
+func TestSQLiteStore_BatchedAsyncWrite(t *testing.T) {
+	store := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "metrics.db"))
+	defer store.Close()
+
+	for i := 0; i < store.batchSize-1; i++ {
+		store.AddRecord(PersistentRecord{MetricsKey: "mk", BaseURL: "https://a.example.com", KeyMask: "sk", Timestamp: time.Now(), APIType: "messages"})
+	}
+	if count, _ := store.GetRecordCount(); count != 0 {
+		t.Fatalf("未达到批量阈值时记录数 = %d, want 0（仍在缓冲区）", count)
+	}
+
+	store.AddRecord(PersistentRecord{MetricsKey: "mk", BaseURL: "https://a.example.com", KeyMask: "sk", Timestamp: time.Now(), APIType: "messages"})
+	store.asyncFlushWg.Wait()
+	if count, _ := store.GetRecordCount(); count != int64(store.batchSize) {
+		t.Fatalf("达到批量阈值后记录数 = %d, want %d", count, store.batchSize)
+	}
+}
+
+// TestSQLiteStore_MigratesLegacyIndex 旧版数据库的 (api_type, timestamp) 索引迁移为包含 metrics_key 的组合索引
+func TestSQLiteStore_MigratesLegacyIndex(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "metrics.db")
+
+	legacy, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE request_records (
+			id INTEGER PRIMARY KEY AUTOINCREMENT, metrics_key TEXT NOT NULL, base_url TEXT NOT NULL, key_mask TEXT NOT NULL,
+			timestamp INTEGER NOT NULL, success INTEGER NOT NULL, input_tokens INTEGER DEFAULT 0, output_tokens INTEGER DEFAULT 0,
+			cache_creation_tokens INTEGER DEFAULT 0, cache_read_tokens INTEGER DEFAULT 0, api_type TEXT NOT NULL DEFAULT 'messages',
+			model TEXT DEFAULT '', thinking_tokens INTEGER DEFAULT 0)`,
+		"CREATE INDEX idx_records_api_type_timestamp ON request_records(api_type, timestamp)",
+		"PRAGMA user_version = 2",
+	} {
+		if _, err := legacy.Exec(stmt); err != nil {
+			t.Fatalf("构造旧版数据库失败: %v", err)
+		}
+	}
+	legacy.Close()
+
+	store := newTestSQLiteStore(t, dbPath)
+	defer store.Close()
+
+	indexes := map[string]bool{}
+	rows, err := store.db.Query("SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'request_records'")
+	if err != nil {
+		t.Fatalf("查询索引失败: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		_ = rows.Scan(&name)
+		indexes[name] = true
+	}
+	if !indexes["idx_records_api_type_timestamp_key"] || indexes["idx_records_api_type_timestamp"] {
+		t.Fatalf("索引 = %v, want 组合索引且不含旧索引", indexes)
+	}
+
+	var version int
+	if err := store.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil || version != 3 {
+		t.Fatalf("user_version = %d, %v, want 3", version, err)
+	}
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"log"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	staleKeyThreshold       = 48 * time.Hour // 超过该时长无活动的 Key 视为过期
+	staleKeyCleanupInterval = 1 * time.Hour  // 过期 Key 清理的基础周期
+
+	// DefaultStaleKeyCleanupChunkSize 每次持有写锁处理的 Key 数量
+	DefaultStaleKeyCleanupChunkSize = 500
+	// DefaultStaleKeyCleanupJitter 清理周期的最大随机抖动
+	DefaultStaleKeyCleanupJitter = 5 * time.Minute
+)
+
+// SetStaleKeyCleanup 设置过期 Key 清理的分块大小与调度抖动
+// chunkSize <= 0 时使用默认值；jitter <= 0 时不加抖动
+// 新的抖动从下一次调度开始生效
+func (m *MetricsManager) SetStaleKeyCleanup(chunkSize int, jitter time.Duration) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultStaleKeyCleanupChunkSize
+	}
+	if jitter < 0 {
+		jitter = 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cleanupChunkSize = chunkSize
+	m.cleanupJitter = jitter
+}
+
+// nextStaleKeyCleanupDelay 计算下一次清理的等待时间（基础周期 + [0, jitter) 随机抖动）
+func (m *MetricsManager) nextStaleKeyCleanupDelay() time.Duration {
+	m.mu.RLock()
+	jitter := m.cleanupJitter
+	m.mu.RUnlock()
+
+	if jitter <= 0 {
+		return staleKeyCleanupInterval
+	}
+	return staleKeyCleanupInterval + rand.N(jitter)
+}
+
+// cleanupStaleKeys 清理过期的 Key 指标（超过 48 小时无活动）
+// 先在读锁下取 Key 快照，再按块获取写锁逐块处理，避免长时间持有写锁造成请求延迟尖刺：
+// - 块之间被删除的 Key 直接跳过
+// - 块之间新增的 Key 不在快照中，留待下一轮
+// - 是否过期在写锁内按最新状态重新判断，块之间恢复活动的 Key 不会被误删
+func (m *MetricsManager) cleanupStaleKeys() {
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.keyMetrics))
+	for key := range m.keyMetrics {
+		keys = append(keys, key)
+	}
+	chunkSize := m.cleanupChunkSize
+	m.mu.RUnlock()
+
+	if chunkSize <= 0 {
+		chunkSize = DefaultStaleKeyCleanupChunkSize
+	}
+
+	var removed []string
+	for start := 0; start < len(keys); start += chunkSize {
+		end := min(start+chunkSize, len(keys))
+
+		m.mu.Lock()
+		now := time.Now()
+		for _, key := range keys[start:end] {
+			metrics, exists := m.keyMetrics[key]
+			if !exists {
+				continue
+			}
+			if isStaleKeyLocked(metrics, now) {
+				delete(m.keyMetrics, key)
+				removed = append(removed, metrics.KeyMask)
+			}
+		}
+		m.mu.Unlock()
+	}
+
+	if len(removed) > 0 {
+		log.Printf("[Metrics-Cleanup] 清理了 %d 个过期 Key 指标: %v", len(removed), removed)
+	}
+}
+
+// isStaleKeyLocked 判断 Key 是否过期：从未有活动或最后活动超过阈值（调用方需持有锁）
+func isStaleKeyLocked(metrics *KeyMetrics, now time.Time) bool {
+	var lastActivity time.Time
+	if metrics.LastSuccessAt != nil {
+		lastActivity = *metrics.LastSuccessAt
+	}
+	if metrics.LastFailureAt != nil && metrics.LastFailureAt.After(lastActivity) {
+		lastActivity = *metrics.LastFailureAt
+	}
+	return lastActivity.IsZero() || now.Sub(lastActivity) > staleKeyThreshold
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCleanupStaleKeys_Chunked(t *testing.T) {
+	m := NewMetricsManager()
+	defer m.Stop()
+	m.SetStaleKeyCleanup(7, 0)
+
+	const total = 1000
+	baseURL := "https://api.example.com"
+	stale := time.Now().Add(-50 * time.Hour)
+	for i := 0; i < total; i++ {
+		apiKey := fmt.Sprintf("sk-%04d", i)
+		switch i % 3 {
+		case 0: // 近期有活动
+			m.RecordSuccess(baseURL, apiKey)
+		case 1: // 超过 48 小时无活动
+			m.RecordFailure(baseURL, apiKey)
+			m.mu.Lock()
+			metrics := m.keyMetrics[generateMetricsKey(baseURL, apiKey)]
+			metrics.LastFailureAt = &stale
+			m.mu.Unlock()
+		case 2: // 从未完成过请求
+			m.mu.Lock()
+			m.getOrCreateKey(baseURL, apiKey)
+			m.mu.Unlock()
+		}
+	}
+
+	// 清理过程中并发写入新 Key，应保留
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			m.RecordSuccess(baseURL, fmt.Sprintf("sk-new-%04d", i))
+		}
+	}()
+	m.cleanupStaleKeys()
+	wg.Wait()
+
+	for i := 0; i < total; i++ {
+		apiKey := fmt.Sprintf("sk-%04d", i)
+		exists := m.GetKeyMetrics(baseURL, apiKey) != nil
+		if wantExists := i%3 == 0; exists != wantExists {
+			t.Fatalf("%s exists=%v, want %v", apiKey, exists, wantExists)
+		}
+	}
+	for i := 0; i < 200; i++ {
+		if m.GetKeyMetrics(baseURL, fmt.Sprintf("sk-new-%04d", i)) == nil {
+			t.Fatalf("清理期间新增的 Key sk-new-%04d 不应被删除", i)
+		}
+	}
+}
+
+func TestNextStaleKeyCleanupDelay_Jitter(t *testing.T) {
+	m := NewMetricsManager()
+	defer m.Stop()
+
+	m.SetStaleKeyCleanup(0, 0)
+	if got := m.nextStaleKeyCleanupDelay(); got != staleKeyCleanupInterval {
+		t.Fatalf("无抖动 delay=%v, want %v", got, staleKeyCleanupInterval)
+	}
+
+	jitter := 10 * time.Minute
+	m.SetStaleKeyCleanup(0, jitter)
+	for i := 0; i < 100; i++ {
+		got := m.nextStaleKeyCleanupDelay()
+		if got < staleKeyCleanupInterval || got >= staleKeyCleanupInterval+jitter {
+			t.Fatalf("delay=%v, want [%v, %v)", got, staleKeyCleanupInterval, staleKeyCleanupInterval+jitter)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TPMDefinition TPM 统计口径
+type TPMDefinition string
+
+const (
+	// TPMDefinitionOutput 仅计算输出 tokens（默认）
+	TPMDefinitionOutput TPMDefinition = "output"
+	// TPMDefinitionOutputInput 输出 + 输入 tokens（不含缓存 tokens）
+	TPMDefinitionOutputInput TPMDefinition = "output+input"
+	// TPMDefinitionTotalInclCache 输出 + 输入 + 缓存创建 + 缓存读取 tokens，与按总量计费的上游账单口径一致
+	TPMDefinitionTotalInclCache TPMDefinition = "total_incl_cache"
+)
+
+// ParseTPMDefinition 解析 TPM 统计口径（空字符串视为 output）
+func ParseTPMDefinition(value string) (TPMDefinition, error) {
+	switch TPMDefinition(strings.ToLower(strings.TrimSpace(value))) {
+	case "", TPMDefinitionOutput:
+		return TPMDefinitionOutput, nil
+	case TPMDefinitionOutputInput:
+		return TPMDefinitionOutputInput, nil
+	case TPMDefinitionTotalInclCache:
+		return TPMDefinitionTotalInclCache, nil
+	default:
+		return "", fmt.Errorf("未知的 TPM 统计口径 %q（可选 output / output+input / total_incl_cache）", value)
+	}
+}
+
+// SetTPMDefinition 设置 TPM 统计口径
+func (m *MetricsManager) SetTPMDefinition(def TPMDefinition) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tpmDefinition = def
+}
+
+// tpmTokensLocked 按 TPM 统计口径计算单条请求记录计入 TPM 的 tokens（调用方需持有锁）
+// 思考 tokens 是否计入仍由 SetTPMIncludeThinking 单独控制
+func (m *MetricsManager) tpmTokensLocked(record RequestRecord) int64 {
+	tokens := record.OutputTokens
+	switch m.tpmDefinition {
+	case TPMDefinitionOutputInput:
+		tokens += record.InputTokens
+	case TPMDefinitionTotalInclCache:
+		tokens += record.InputTokens + record.CacheCreationInputTokens + record.CacheReadInputTokens
+	}
+	if m.tpmIncludeThinking {
+		tokens += record.ThinkingTokens
+	}
+	return tokens
+}
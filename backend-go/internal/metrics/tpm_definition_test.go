@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/types"
+)
+
+func TestParseTPMDefinition(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    TPMDefinition
+		wantErr bool
+	}{
+		{value: "", want: TPMDefinitionOutput},
+		{value: "output", want: TPMDefinitionOutput},
+		{value: " Output+Input ", want: TPMDefinitionOutputInput},
+		{value: "total_incl_cache", want: TPMDefinitionTotalInclCache},
+		{value: "total", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTPMDefinition(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseTPMDefinition(%q) err=%v, wantErr=%v", tt.value, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Fatalf("ParseTPMDefinition(%q)=%q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestGetRecentActivityMultiURL_TPMDefinition 同一份请求历史在不同 TPM 口径下得到不同的 TPM 与分段 tokens
+func TestGetRecentActivityMultiURL_TPMDefinition(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition TPMDefinition
+		wantTokens int64
+	}{
+		{name: "默认仅输出", definition: "", wantTokens: 300},
+		{name: "输出", definition: TPMDefinitionOutput, wantTokens: 300},
+		{name: "输出加输入", definition: TPMDefinitionOutputInput, wantTokens: 300 + 1500},
+		{name: "含缓存总量", definition: TPMDefinitionTotalInclCache, wantTokens: 300 + 1500 + 600 + 3000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMetricsManagerWithConfig(10, 0.5)
+			defer m.Stop()
+			if tt.definition != "" {
+				m.SetTPMDefinition(tt.definition)
+			}
+
+			baseURL := "https://example.com"
+			key := "k1"
+			for range 3 {
+				m.RecordSuccessWithUsage(baseURL, key, &types.Usage{
+					InputTokens:              500,
+					OutputTokens:             100,
+					CacheCreationInputTokens: 200,
+					CacheReadInputTokens:     1000,
+				})
+			}
+
+			activity := m.GetRecentActivityMultiURL(0, []string{baseURL}, []string{key})
+			wantTPM := float64(tt.wantTokens) / 15
+			if !floatEquals(activity.TPM, wantTPM, 0.001) {
+				t.Fatalf("expected TPM=%.3f, got %.3f", wantTPM, activity.TPM)
+			}
+
+			var segTokens int64
+			for _, seg := range activity.Segments {
+				segTokens += seg.TPMTokens
+			}
+			if segTokens != tt.wantTokens {
+				t.Fatalf("expected segment tpmTokens sum=%d, got %d", tt.wantTokens, segTokens)
+			}
+		})
+	}
+}
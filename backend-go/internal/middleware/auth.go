@@ -140,6 +140,11 @@ func getAPIKey(c *gin.Context) string {
 	return ""
 }
 
+// ProvidedAPIKey 返回客户端请求携带的代理访问密钥（未携带时为空字符串）
+func ProvidedAPIKey(c *gin.Context) string {
+	return getAPIKey(c)
+}
+
 // ProxyAuthMiddleware 代理访问控制中间件
 func ProxyAuthMiddleware(envCfg *config.EnvConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -101,3 +101,39 @@ func TestResponsesProvider_PassthroughInjectsModelLevelReasoningAndChannelLevelO
 		t.Fatalf("service_tier = %v, want priority", got)
 	}
 }
+
+func TestResponsesProvider_AzureDeploymentURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := newGinContext(http.MethodPost, "/v1/responses", []byte(`{"model":"gpt-4o","input":"hi"}`), context.Background())
+	upstream := &config.UpstreamConfig{
+		BaseURL:      "https://my-resource.openai.azure.com",
+		ServiceType:  "azure",
+		ModelMapping: map[string]string{"gpt-4o": "prod-gpt4o"},
+		APIVersion:   "2024-06-01",
+	}
+
+	p := &ResponsesProvider{}
+	req, _, err := p.ConvertToProviderRequest(c, upstream, "azure-key-123")
+	if err != nil {
+		t.Fatalf("ConvertToProviderRequest() err = %v", err)
+	}
+
+	want := "https://my-resource.openai.azure.com/openai/deployments/prod-gpt4o/chat/completions?api-version=2024-06-01"
+	if got := req.URL.String(); got != want {
+		t.Fatalf("url = %q, want %q", got, want)
+	}
+	if got := req.Header.Get("api-key"); got != "azure-key-123" {
+		t.Fatalf("api-key = %q, want azure-key-123", got)
+	}
+	if got := req.Header.Get("Authorization"); got != "" {
+		t.Fatalf("Authorization = %q, want empty", got)
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		t.Fatalf("decode request body: %v", err)
+	}
+	if _, ok := body["messages"]; !ok {
+		t.Fatalf("Azure 请求体应转换为 Chat Completions 格式, got %#v", body)
+	}
+}
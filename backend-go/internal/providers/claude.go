@@ -6,6 +6,7 @@
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 
@@ -13,6 +14,7 @@
 	"github.com/BenedictKing/ccx/internal/types"
 	"github.com/BenedictKing/ccx/internal/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
 )
 
 // ClaudeProvider Claude 提供商（直接透传）
@@ -63,6 +65,12 @@ func (p *ClaudeProvider) ConvertToProviderRequest(c *gin.Context, upstream *conf
 		bodyBytes = redirectModelInBody(bodyBytes, upstream)
 	}
 
+	// 渠道级请求体改写
+	bodyBytes, err = config.ApplyBodyTransforms(bodyBytes, upstream.BodyTransforms)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// 构建目标URL
 	// 智能拼接逻辑：
 	// 1. 如果 baseURL 以 # 结尾，跳过自动添加 /v1
@@ -88,8 +96,8 @@ func (p *ClaudeProvider) ConvertToProviderRequest(c *gin.Context, upstream *conf
 		targetURL = baseURL + "/v1" + endpoint
 	}
 
-	if c.Request.URL.RawQuery != "" {
-		targetURL += "?" + c.Request.URL.RawQuery
+	if rawQuery := stripProxyQueryParams(c.Request.URL); rawQuery != "" {
+		targetURL += "?" + rawQuery
 	}
 
 	// 创建请求
@@ -106,13 +114,30 @@ func (p *ClaudeProvider) ConvertToProviderRequest(c *gin.Context, upstream *conf
 
 	// 使用统一的头部处理逻辑
 	req.Header = utils.PrepareUpstreamHeaders(c, req.URL.Host)
+	utils.FilterForwardedHeaders(req.Header, upstream.ForwardHeaderAllowlist, upstream.StripHeaders)
 	utils.SetAuthenticationHeader(req.Header, apiKey)
 	utils.EnsureCompatibleUserAgent(req.Header, "claude")
-	utils.ApplyCustomHeaders(req.Header, upstream.CustomHeaders)
+	utils.ApplyCustomHeaders(req.Header, upstream.CustomHeaders, utils.HeaderTemplateVars{
+		Model:  gjson.GetBytes(bodyBytes, "model").String(),
+		APIKey: apiKey,
+	})
 
 	return req, bodyBytes, nil
 }
 
+// stripProxyQueryParams 移除仅由代理自身使用的查询参数（如 format=ndjson），返回转发给上游的查询串
+func stripProxyQueryParams(u *url.URL) string {
+	if u.RawQuery == "" {
+		return ""
+	}
+	query := u.Query()
+	if query.Get("format") != "ndjson" {
+		return u.RawQuery
+	}
+	query.Del("format")
+	return query.Encode()
+}
+
 // ConvertToClaudeResponse 转换为 Claude 响应（直接透传）
 func (p *ClaudeProvider) ConvertToClaudeResponse(providerResp *types.ProviderResponse) (*types.ClaudeResponse, error) {
 	var claudeResp types.ClaudeResponse
@@ -61,6 +61,11 @@ func (p *GeminiProvider) ConvertToProviderRequest(c *gin.Context, upstream *conf
 
 	url := fmt.Sprintf("%s/models/%s:%s", baseURL, model, action)
 
+	reqBodyBytes, err = config.ApplyBodyTransforms(reqBodyBytes, upstream.BodyTransforms)
+	if err != nil {
+		return nil, originalBodyBytes, err
+	}
+
 	req, err := http.NewRequestWithContext(c.Request.Context(), "POST", url, bytes.NewReader(reqBodyBytes))
 	if err != nil {
 		return nil, originalBodyBytes, fmt.Errorf("创建Gemini请求失败: %w", err)
@@ -69,8 +74,9 @@ func (p *GeminiProvider) ConvertToProviderRequest(c *gin.Context, upstream *conf
 	// 使用统一的头部处理逻辑（透明代理）
 	// 保留客户端的大部分 headers，只移除/替换必要的认证和代理相关 headers
 	req.Header = utils.PrepareUpstreamHeaders(c, req.URL.Host)
+	utils.FilterForwardedHeaders(req.Header, upstream.ForwardHeaderAllowlist, upstream.StripHeaders)
 	utils.SetGeminiAuthenticationHeader(req.Header, apiKey)
-	utils.ApplyCustomHeaders(req.Header, upstream.CustomHeaders)
+	utils.ApplyCustomHeaders(req.Header, upstream.CustomHeaders, utils.HeaderTemplateVars{Model: model, APIKey: apiKey})
 
 	return req, originalBodyBytes, nil
 }
@@ -104,6 +104,11 @@ func (p *OpenAIProvider) ConvertToProviderRequest(c *gin.Context, upstream *conf
 	}
 	url := baseURL + endpoint
 
+	reqBodyBytes, err = config.ApplyBodyTransforms(reqBodyBytes, upstream.BodyTransforms)
+	if err != nil {
+		return nil, originalBodyBytes, err
+	}
+
 	req, err := http.NewRequestWithContext(c.Request.Context(), "POST", url, bytes.NewReader(reqBodyBytes))
 	if err != nil {
 		return nil, originalBodyBytes, fmt.Errorf("创建OpenAI请求失败: %w", err)
@@ -112,8 +117,9 @@ func (p *OpenAIProvider) ConvertToProviderRequest(c *gin.Context, upstream *conf
 	// 使用统一的头部处理逻辑（透明代理）
 	// 保留客户端的大部分 headers，只移除/替换必要的认证和代理相关 headers
 	req.Header = utils.PrepareUpstreamHeaders(c, req.URL.Host)
+	utils.FilterForwardedHeaders(req.Header, upstream.ForwardHeaderAllowlist, upstream.StripHeaders)
 	utils.SetAuthenticationHeader(req.Header, apiKey)
-	utils.ApplyCustomHeaders(req.Header, upstream.CustomHeaders)
+	utils.ApplyCustomHeaders(req.Header, upstream.CustomHeaders, utils.HeaderTemplateVars{Model: openaiReq.Model, APIKey: apiKey})
 
 	return req, originalBodyBytes, nil
 }
@@ -16,6 +16,7 @@
 	"github.com/BenedictKing/ccx/internal/types"
 	"github.com/BenedictKing/ccx/internal/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
 )
 
 // ResponsesProvider Responses API 提供商
@@ -54,12 +55,18 @@ func (p *ResponsesProvider) ConvertToProviderRequest(
 		return nil, bodyBytes, err
 	}
 
+	reqBody, err = config.ApplyBodyTransforms(reqBody, upstream.BodyTransforms)
+	if err != nil {
+		return nil, bodyBytes, err
+	}
+
 	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, targetURL, bytes.NewReader(reqBody))
 	if err != nil {
 		return nil, bodyBytes, err
 	}
 
 	req.Header = utils.PrepareUpstreamHeaders(c, req.URL.Host)
+	utils.FilterForwardedHeaders(req.Header, upstream.ForwardHeaderAllowlist, upstream.StripHeaders)
 	req.Header.Del("authorization")
 	req.Header.Del("x-api-key")
 	req.Header.Del("x-goog-api-key")
@@ -67,12 +74,17 @@ func (p *ResponsesProvider) ConvertToProviderRequest(
 	switch upstream.ServiceType {
 	case "gemini":
 		utils.SetGeminiAuthenticationHeader(req.Header, apiKey)
+	case "azure":
+		utils.SetAzureAuthenticationHeader(req.Header, apiKey)
 	default:
 		utils.SetAuthenticationHeader(req.Header, apiKey)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	utils.ApplyCustomHeaders(req.Header, upstream.CustomHeaders)
+	utils.ApplyCustomHeaders(req.Header, upstream.CustomHeaders, utils.HeaderTemplateVars{
+		Model:  config.RedirectModel(gjson.GetBytes(bodyBytes, "model").String(), upstream),
+		APIKey: apiKey,
+	})
 
 	return req, bodyBytes, nil
 }
@@ -295,6 +307,11 @@ func (p *ResponsesProvider) buildRequestURL(upstream *config.UpstreamConfig, bod
 		}
 		return fmt.Sprintf("%s/models/%s:%s", baseURL, model, action), nil
 	}
+	if upstream.ServiceType == "azure" {
+		// Azure OpenAI：请求已转换为 Chat Completions 格式，按部署式路径发送
+		model := config.RedirectModel(gjson.GetBytes(bodyBytes, "model").String(), upstream)
+		return upstream.AzureChatCompletionsURL(upstream.BaseURL, model), nil
+	}
 	return p.buildTargetURL(upstream), nil
 }
 
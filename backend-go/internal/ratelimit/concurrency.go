@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQueueTimeout 在排队超时时间内未获取到并发槽位
+var ErrQueueTimeout = errors.New("concurrency queue wait timeout")
+
+// ConcurrencyLimiter 有界并发信号量：达到上限时请求排队等待空闲槽位
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+}
+
+// NewConcurrencyLimiter 创建并发信号量（limit 最小为 1）
+func NewConcurrencyLimiter(limit int) *ConcurrencyLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+	return &ConcurrencyLimiter{slots: make(chan struct{}, limit)}
+}
+
+// Limit 返回并发上限
+func (l *ConcurrencyLimiter) Limit() int {
+	return cap(l.slots)
+}
+
+// InUse 返回当前占用的槽位数
+func (l *ConcurrencyLimiter) InUse() int {
+	return len(l.slots)
+}
+
+// Acquire 获取一个并发槽位，最多等待 timeout（<= 0 表示不等待）
+// 成功时返回 release（可重复调用，仅首次生效）；超时返回 ErrQueueTimeout，ctx 取消时返回 ctx.Err()
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, timeout time.Duration) (func(), error) {
+	select {
+	case l.slots <- struct{}{}:
+		return l.releaseFunc(), nil
+	default:
+	}
+
+	if timeout <= 0 {
+		return nil, ErrQueueTimeout
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.releaseFunc(), nil
+	case <-timer.C:
+		return nil, ErrQueueTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// releaseFunc 生成只释放一次的回调，避免重复释放占用他人槽位
+func (l *ConcurrencyLimiter) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-l.slots })
+	}
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiter_Acquire(t *testing.T) {
+	tests := []struct {
+		name        string
+		timeout     time.Duration
+		releaseIn   time.Duration // > 0 时在该时间后释放已占用的槽位
+		cancelIn    time.Duration // > 0 时在该时间后取消 ctx
+		wantErr     error
+		wantWaitMin time.Duration
+	}{
+		{name: "槽位已满且不等待", timeout: 0, wantErr: ErrQueueTimeout},
+		{name: "排队超时", timeout: 50 * time.Millisecond, wantErr: ErrQueueTimeout, wantWaitMin: 50 * time.Millisecond},
+		{name: "排队期间槽位释放", timeout: time.Second, releaseIn: 50 * time.Millisecond, wantWaitMin: 50 * time.Millisecond},
+		{name: "排队期间 ctx 取消", timeout: time.Second, cancelIn: 50 * time.Millisecond, wantErr: context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewConcurrencyLimiter(1)
+			holder, err := l.Acquire(context.Background(), 0)
+			if err != nil {
+				t.Fatalf("首次获取槽位失败: %v", err)
+			}
+			if tt.releaseIn > 0 {
+				time.AfterFunc(tt.releaseIn, holder)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelIn > 0 {
+				time.AfterFunc(tt.cancelIn, cancel)
+			}
+
+			start := time.Now()
+			release, err := l.Acquire(ctx, tt.timeout)
+			waited := time.Since(start)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if waited < tt.wantWaitMin {
+				t.Fatalf("等待 %v, 期望至少 %v", waited, tt.wantWaitMin)
+			}
+			if err == nil {
+				if l.InUse() != 1 {
+					t.Fatalf("InUse = %d, want 1", l.InUse())
+				}
+				release()
+				release() // 重复释放不应占用他人槽位
+				if l.InUse() != 0 {
+					t.Fatalf("释放后 InUse = %d, want 0", l.InUse())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Package ratelimit 提供限流所需的滑动窗口计数器与并发信号量
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// event 窗口内的一次成功请求记录
+type event struct {
+	at     time.Time
+	tokens int
+}
+
+// SlidingWindow 滑动窗口计数器，统计窗口内的请求数与 token 数
+// 由成功请求驱动（Record），在请求入口处检查（Allow）
+type SlidingWindow struct {
+	mu     sync.Mutex
+	window time.Duration
+	events []event // 按时间升序
+	now    func() time.Time
+}
+
+// NewSlidingWindow 创建滑动窗口计数器
+func NewSlidingWindow(window time.Duration) *SlidingWindow {
+	if window <= 0 {
+		window = time.Minute
+	}
+	return &SlidingWindow{
+		window: window,
+		now:    time.Now,
+	}
+}
+
+// Record 记录一次成功请求及其消耗的 token 数
+func (w *SlidingWindow) Record(tokens int) {
+	if tokens < 0 {
+		tokens = 0
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	w.pruneLocked(now)
+	w.events = append(w.events, event{at: now, tokens: tokens})
+}
+
+// Allow 检查窗口内的请求数/token 数是否已达上限（上限 <= 0 表示不限制）
+// 超限时返回需要等待的时间（窗口内足够多的旧记录过期后即可恢复）
+func (w *SlidingWindow) Allow(maxRequests, maxTokens int) (bool, time.Duration) {
+	if maxRequests <= 0 && maxTokens <= 0 {
+		return true, 0
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	w.pruneLocked(now)
+
+	var retryAfter time.Duration
+
+	if maxRequests > 0 && len(w.events) >= maxRequests {
+		// 需要过期 len-maxRequests+1 条记录才能放行
+		oldest := w.events[len(w.events)-maxRequests]
+		retryAfter = oldest.at.Add(w.window).Sub(now)
+	}
+
+	if maxTokens > 0 {
+		total := 0
+		for _, e := range w.events {
+			total += e.tokens
+		}
+		for i := 0; i < len(w.events) && total >= maxTokens; i++ {
+			total -= w.events[i].tokens
+			if wait := w.events[i].at.Add(w.window).Sub(now); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+
+	if retryAfter > 0 {
+		return false, retryAfter
+	}
+	return true, 0
+}
+
+// Stats 返回当前窗口内的请求数与 token 总数
+func (w *SlidingWindow) Stats() (requests, tokens int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pruneLocked(w.now())
+	for _, e := range w.events {
+		tokens += e.tokens
+	}
+	return len(w.events), tokens
+}
+
+// pruneLocked 移除窗口外的记录（调用方需持有锁）
+func (w *SlidingWindow) pruneLocked(now time.Time) {
+	cutoff := now.Add(-w.window)
+	i := 0
+	for i < len(w.events) && !w.events[i].at.After(cutoff) {
+		i++
+	}
+	if i > 0 {
+		w.events = append(w.events[:0], w.events[i:]...)
+	}
+}
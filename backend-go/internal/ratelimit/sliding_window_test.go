@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestWindow 创建使用可控时钟的滑动窗口
+func newTestWindow(window time.Duration) (*SlidingWindow, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewSlidingWindow(window)
+	w.now = func() time.Time { return now }
+	return w, &now
+}
+
+func TestSlidingWindow_RPM(t *testing.T) {
+	w, now := newTestWindow(time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := w.Allow(3, 0); !ok {
+			t.Fatalf("第 %d 个请求不应被限流", i+1)
+		}
+		w.Record(10)
+		*now = now.Add(10 * time.Second)
+	}
+
+	// 窗口内已有 3 个请求：第 4 个被拒绝，最早的记录在 30s 后过期
+	ok, retryAfter := w.Allow(3, 0)
+	if ok {
+		t.Fatal("超过 RPM 上限后应被限流")
+	}
+	if retryAfter != 30*time.Second {
+		t.Fatalf("retryAfter = %v, want 30s", retryAfter)
+	}
+
+	// 最早的记录过期后恢复
+	*now = now.Add(retryAfter)
+	if ok, _ := w.Allow(3, 0); !ok {
+		t.Fatal("最早的记录过期后应恢复")
+	}
+	if requests, _ := w.Stats(); requests != 2 {
+		t.Fatalf("窗口内请求数 = %d, want 2", requests)
+	}
+}
+
+func TestSlidingWindow_TPM(t *testing.T) {
+	w, now := newTestWindow(time.Minute)
+
+	w.Record(600)
+	*now = now.Add(20 * time.Second)
+	w.Record(500)
+
+	// 1100 >= 1000：需等待第一条记录过期（40s 后）才能回到上限以下
+	ok, retryAfter := w.Allow(0, 1000)
+	if ok {
+		t.Fatal("超过 TPM 上限后应被限流")
+	}
+	if retryAfter != 40*time.Second {
+		t.Fatalf("retryAfter = %v, want 40s", retryAfter)
+	}
+
+	*now = now.Add(retryAfter)
+	if ok, _ := w.Allow(0, 1000); !ok {
+		t.Fatal("旧记录过期后应恢复")
+	}
+}
+
+func TestSlidingWindow_NoLimit(t *testing.T) {
+	w, _ := newTestWindow(time.Minute)
+	for i := 0; i < 100; i++ {
+		w.Record(1000)
+	}
+	if ok, _ := w.Allow(0, 0); !ok {
+		t.Fatal("未配置上限时不应限流")
+	}
+}
@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"hash/fnv"
+	"log"
+	"math/rand/v2"
+)
+
+// canaryBucket 返回请求落入的分流桶（0-99）
+// 带会话标识时按 hash 确定性分桶，同一会话始终落在金丝雀的同一侧；否则随机抽样
+func canaryBucket(kind ChannelKind, userID, channelName string) int {
+	if userID == "" {
+		return rand.IntN(100)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(string(kind) + ":" + userID + ":" + channelName))
+	return int(h.Sum32() % 100)
+}
+
+// selectCanaryChannel 按 canaryPercent 将部分普通请求分流到金丝雀渠道（调用方需持有读锁）
+// 命中分流但金丝雀不健康时返回 nil，请求按正常优先级调度
+func (s *ChannelScheduler) selectCanaryChannel(
+	activeChannels []ChannelInfo,
+	userID string,
+	failedChannels map[int]bool,
+	kind ChannelKind,
+) *SelectionResult {
+	metricsManager := s.getMetricsManager(kind)
+	for _, ch := range activeChannels {
+		if failedChannels[ch.Index] || ch.Status != "active" {
+			continue
+		}
+		upstream := s.getUpstreamByIndex(ch.Index, kind)
+		if upstream == nil || upstream.CanaryPercent <= 0 || len(upstream.APIKeys) == 0 {
+			continue
+		}
+		if canaryBucket(kind, userID, upstream.Name) >= upstream.CanaryPercent {
+			continue
+		}
+
+		prefix := kindSchedulerLogPrefix(kind)
+		if !metricsManager.IsChannelHealthyWithKeys(upstream.BaseURL, upstream.APIKeys) {
+			log.Printf("[%s-Canary] 警告: 金丝雀渠道 [%d] %s 不健康，本次请求按正常优先级调度", prefix, ch.Index, upstream.Name)
+			continue
+		}
+		log.Printf("[%s-Canary] 金丝雀分流选择渠道: [%d] %s (分流比例: %d%%)", prefix, ch.Index, upstream.Name, upstream.CanaryPercent)
+		return &SelectionResult{
+			Upstream:     upstream,
+			ChannelIndex: ch.Index,
+			Reason:       selectionReasonCanary,
+		}
+	}
+	return nil
+}
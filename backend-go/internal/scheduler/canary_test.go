@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+)
+
+// TestSelectChannel_CanaryPercent 金丝雀渠道获得约 canaryPercent 的普通请求，不健康时不再分流
+func TestSelectChannel_CanaryPercent(t *testing.T) {
+	cfg := config.Config{
+		Upstream: []config.UpstreamConfig{
+			{Name: "stable", BaseURL: "https://stable.example.com", APIKeys: []string{"sk-stable"}, Status: "active", Priority: 2},
+			{Name: "canary", BaseURL: "https://canary.example.com", APIKeys: []string{"sk-canary"}, Status: "active", Priority: 1, CanaryPercent: 20},
+		},
+	}
+	s, cleanup := createTestScheduler(t, cfg)
+	defer cleanup()
+
+	const total = 4000
+	share := func(userID func(i int) string) float64 {
+		canary := 0
+		for i := 0; i < total; i++ {
+			result, err := s.SelectChannel(context.Background(), userID(i), nil, ChannelKindMessages, "")
+			if err != nil {
+				t.Fatalf("SelectChannel() err = %v", err)
+			}
+			if result.ChannelIndex == 1 {
+				if result.Reason != selectionReasonCanary {
+					t.Fatalf("金丝雀选择原因 = %q, want %q", result.Reason, selectionReasonCanary)
+				}
+				canary++
+			}
+		}
+		return float64(canary) / total
+	}
+
+	// 随机抽样（无会话标识）与按会话 hash 分流都应接近 20%（金丝雀优先级更高也不会拿到全部流量）
+	if got := share(func(int) string { return "" }); got < 0.15 || got > 0.25 {
+		t.Fatalf("随机分流比例 = %.3f, want ≈0.20", got)
+	}
+	if got := share(func(i int) string { return fmt.Sprintf("conv-%d", i) }); got < 0.15 || got > 0.25 {
+		t.Fatalf("按会话分流比例 = %.3f, want ≈0.20", got)
+	}
+
+	// 同一会话的分流结果稳定
+	first, _ := s.SelectChannel(context.Background(), "conv-sticky", nil, ChannelKindMessages, "")
+	for range 20 {
+		if result, _ := s.SelectChannel(context.Background(), "conv-sticky", nil, ChannelKindMessages, ""); result.ChannelIndex != first.ChannelIndex {
+			t.Fatalf("同一会话分流结果不稳定: %d vs %d", result.ChannelIndex, first.ChannelIndex)
+		}
+	}
+
+	// 金丝雀熔断后全部流量回到正常渠道
+	for range 10 {
+		s.messagesMetricsManager.RecordFailure("https://canary.example.com", "sk-canary")
+	}
+	if got := share(func(int) string { return "" }); got != 0 {
+		t.Fatalf("金丝雀不健康时分流比例 = %.3f, want 0", got)
+	}
+}
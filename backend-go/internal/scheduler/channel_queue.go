@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/ratelimit"
+)
+
+// AcquireChannelSlot 获取渠道并发槽位（仅在渠道配置了 maxConcurrent 时生效）
+// 并发已满时最多排队 queueTimeoutMs，超时返回 ratelimit.ErrQueueTimeout，由调用方 failover 到下一个渠道
+// 返回的 release 必须在请求结束（含流式响应结束）后调用
+func (s *ChannelScheduler) AcquireChannelSlot(ctx context.Context, kind ChannelKind, channelIndex int, upstream *config.UpstreamConfig) (func(), error) {
+	if upstream == nil || upstream.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+
+	limiter := s.getChannelLimiter(kind, channelIndex, upstream.MaxConcurrent)
+	return limiter.Acquire(ctx, time.Duration(upstream.QueueTimeoutMs)*time.Millisecond)
+}
+
+// getChannelLimiter 获取渠道的并发信号量，上限变更时重建（旧信号量上的请求结束后自然释放）
+func (s *ChannelScheduler) getChannelLimiter(kind ChannelKind, channelIndex int, limit int) *ratelimit.ConcurrencyLimiter {
+	key := fmt.Sprintf("%s:%d", kind, channelIndex)
+
+	s.channelSlotsMu.Lock()
+	defer s.channelSlotsMu.Unlock()
+
+	limiter, exists := s.channelSlots[key]
+	if !exists || limiter.Limit() != limit {
+		limiter = ratelimit.NewConcurrencyLimiter(limit)
+		s.channelSlots[key] = limiter
+	}
+	return limiter
+}
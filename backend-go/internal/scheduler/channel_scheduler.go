@@ -4,11 +4,15 @@
 	"context"
 	"fmt"
 	"log"
+	"slices"
 	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/BenedictKing/ccx/internal/config"
 	"github.com/BenedictKing/ccx/internal/metrics"
+	"github.com/BenedictKing/ccx/internal/ratelimit"
 	"github.com/BenedictKing/ccx/internal/session"
 	"github.com/BenedictKing/ccx/internal/types"
 	"github.com/BenedictKing/ccx/internal/warmup"
@@ -28,6 +32,19 @@ type ChannelScheduler struct {
 	responsesChannelLogStore *metrics.ChannelLogStore // Responses 渠道请求日志
 	geminiChannelLogStore    *metrics.ChannelLogStore // Gemini 渠道请求日志
 	chatChannelLogStore      *metrics.ChannelLogStore // Chat 渠道请求日志
+	deadLetterStore          *metrics.DeadLetterStore // 所有渠道都失败的请求记录
+	stopCh                   chan struct{}            // 后台任务停止信号
+	stopOnce                 sync.Once
+	globalRateLimiter        *ratelimit.SlidingWindow // 全局 RPM/TPM 滑动窗口（跨接口、跨渠道）
+	channelSlotsMu           sync.Mutex
+	channelSlots             map[string]*ratelimit.ConcurrencyLimiter // 渠道并发信号量，key: kind:channelIndex
+	responseCacheMu          sync.Mutex
+	responseCacheHits        map[ChannelKind]int64 // 响应缓存命中次数（未调用上游）
+	shadowTasks              chan func()           // 影子重放任务队列（StartShadowReplayWorkers 启动后非空）
+	shadowDropped            atomic.Int64          // 队列满时丢弃的影子重放任务数
+	selectionMu              sync.Mutex
+	selectionCounts          map[ChannelKind]map[int]int64    // 渠道被调度选中的次数（按类型 + 渠道索引）
+	selectionReasons         map[ChannelKind]map[string]int64 // 各选择原因的累计次数（按类型 + SelectionResult.Reason）
 }
 
 // ChannelKind 标识调度器所处理的渠道类型
@@ -64,6 +81,10 @@ func NewChannelScheduler(
 		responsesChannelLogStore: metrics.NewChannelLogStore(),
 		geminiChannelLogStore:    metrics.NewChannelLogStore(),
 		chatChannelLogStore:      metrics.NewChannelLogStore(),
+		deadLetterStore:          metrics.NewDeadLetterStore(),
+		stopCh:                   make(chan struct{}),
+		globalRateLimiter:        ratelimit.NewSlidingWindow(time.Minute),
+		channelSlots:             make(map[string]*ratelimit.ConcurrencyLimiter),
 	}
 }
 
@@ -81,6 +102,11 @@ func (s *ChannelScheduler) getMetricsManager(kind ChannelKind) *metrics.MetricsM
 	}
 }
 
+// GetMetricsManagerByKind 根据类型获取对应的指标管理器
+func (s *ChannelScheduler) GetMetricsManagerByKind(kind ChannelKind) *metrics.MetricsManager {
+	return s.getMetricsManager(kind)
+}
+
 // SelectionResult 渠道选择结果
 type SelectionResult struct {
 	Upstream     *config.UpstreamConfig
@@ -89,13 +115,28 @@ type SelectionResult struct {
 }
 
 // SelectChannel 选择最佳渠道
-// 优先级: 促销期渠道 > Trace亲和（促销渠道失败时回退） > 渠道优先级顺序
+// 优先级: 促销期渠道 > Trace亲和（促销渠道失败时回退；sticky 模式下不检查健康度） > 渠道优先级顺序
 func (s *ChannelScheduler) SelectChannel(
 	ctx context.Context,
 	userID string,
 	failedChannels map[int]bool,
 	kind ChannelKind,
 	model string,
+) (*SelectionResult, error) {
+	result, err := s.selectChannel(userID, failedChannels, kind, model)
+	if result != nil {
+		s.recordChannelSelection(kind, result.ChannelIndex)
+		s.recordSelectionReason(kind, result.Reason)
+	}
+	return result, err
+}
+
+// selectChannel 按促销期、Trace 亲和、金丝雀分流、优先级与降级顺序选择渠道
+func (s *ChannelScheduler) selectChannel(
+	userID string,
+	failedChannels map[int]bool,
+	kind ChannelKind,
+	model string,
 ) (*SelectionResult, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -134,7 +175,7 @@ func (s *ChannelScheduler) SelectChannel(
 			return &SelectionResult{
 				Upstream:     upstream,
 				ChannelIndex: promotedChannel.Index,
-				Reason:       "promotion_priority",
+				Reason:       selectionReasonPromotion,
 			}, nil
 		} else if upstream != nil {
 			prefix := kindSchedulerLogPrefix(kind)
@@ -146,7 +187,12 @@ func (s *ChannelScheduler) SelectChannel(
 	}
 
 	// 1. 检查 Trace 亲和性（促销渠道失败时或无促销渠道时）
-	if userID != "" {
+	if userID != "" && s.configManager.GetAffinityMode() == config.AffinityModeSticky {
+		// sticky 模式：固定使用亲和渠道，不检查健康度，直到该会话在此渠道上实际失败
+		if result := s.selectStickyChannel(activeChannels, userID, failedChannels, kind); result != nil {
+			return result, nil
+		}
+	} else if userID != "" {
 		compositeKey := string(kind) + ":" + userID
 		if preferredIdx, ok := s.traceAffinity.GetPreferredChannel(compositeKey); ok {
 			for _, ch := range activeChannels {
@@ -165,7 +211,7 @@ func (s *ChannelScheduler) SelectChannel(
 						return &SelectionResult{
 							Upstream:     upstream,
 							ChannelIndex: preferredIdx,
-							Reason:       "trace_affinity",
+							Reason:       selectionReasonTraceAffinity,
 						}, nil
 					}
 				}
@@ -173,7 +219,14 @@ func (s *ChannelScheduler) SelectChannel(
 		}
 	}
 
-	// 2. 按优先级遍历活跃渠道
+	// 2. 金丝雀灰度分流（亲和与促销请求不参与）
+	if result := s.selectCanaryChannel(activeChannels, userID, failedChannels, kind); result != nil {
+		return result, nil
+	}
+
+	// 3. 按优先级遍历活跃渠道（least_loaded 模式下收集全部健康渠道后按负载选择）
+	leastLoaded := s.configManager.GetSchedulingMode() == config.SchedulingModeLeastLoaded
+	var healthyChannels []ChannelInfo
 	for _, ch := range activeChannels {
 		// 跳过本次请求已经失败的渠道
 		if failedChannels[ch.Index] {
@@ -192,6 +245,11 @@ func (s *ChannelScheduler) SelectChannel(
 			continue
 		}
 
+		// 金丝雀渠道只接收分流命中的请求，未命中时交给其余渠道
+		if upstream.CanaryPercent > 0 {
+			continue
+		}
+
 		// 跳过失败率过高的渠道（已熔断或即将熔断）
 		if !metricsManager.IsChannelHealthyWithKeys(upstream.BaseURL, upstream.APIKeys) {
 			failureRate := metricsManager.CalculateChannelFailureRate(upstream.BaseURL, upstream.APIKeys)
@@ -200,6 +258,11 @@ func (s *ChannelScheduler) SelectChannel(
 			continue
 		}
 
+		if leastLoaded {
+			healthyChannels = append(healthyChannels, ch)
+			continue
+		}
+
 		prefix := kindSchedulerLogPrefix(kind)
 		log.Printf("[%s-Channel] 选择渠道: [%d] %s (优先级: %d)", prefix, ch.Index, upstream.Name, ch.Priority)
 		return &SelectionResult{
@@ -209,7 +272,11 @@ func (s *ChannelScheduler) SelectChannel(
 		}, nil
 	}
 
-	// 3. 所有健康渠道都失败，选择失败率最低的作为降级
+	if result := s.selectLeastLoadedChannel(healthyChannels, kind); result != nil {
+		return result, nil
+	}
+
+	// 4. 所有健康渠道都失败，选择失败率最低的作为降级
 	return s.selectFallbackChannel(activeChannels, failedChannels, kind)
 }
 
@@ -273,7 +340,7 @@ func (s *ChannelScheduler) selectFallbackChannel(
 		return &SelectionResult{
 			Upstream:     bestUpstream,
 			ChannelIndex: bestChannel.Index,
-			Reason:       "fallback",
+			Reason:       selectionReasonFallback,
 		}, nil
 	}
 
@@ -341,23 +408,32 @@ func (s *ChannelScheduler) getActiveChannels(kind ChannelKind, model string) []C
 	return activeChannels
 }
 
-// getUpstreamByIndex 根据索引获取上游配置
-// 注意：返回的是副本，避免指向 slice 元素的指针在 slice 重分配后失效
-func (s *ChannelScheduler) getUpstreamByIndex(index int, kind ChannelKind) *config.UpstreamConfig {
-	cfg := s.configManager.GetConfig()
+// HasChannelForModel 是否存在未禁用且支持指定模型的渠道（不检查健康度）
+func (s *ChannelScheduler) HasChannelForModel(kind ChannelKind, model string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.getActiveChannels(kind, model)) > 0
+}
 
-	var upstreams []config.UpstreamConfig
+// GetUpstreams 获取指定类型的渠道配置列表（配置快照，调用方不应修改）
+func (s *ChannelScheduler) GetUpstreams(kind ChannelKind) []config.UpstreamConfig {
+	cfg := s.configManager.GetConfig()
 	switch kind {
 	case ChannelKindResponses:
-		upstreams = cfg.ResponsesUpstream
+		return cfg.ResponsesUpstream
 	case ChannelKindGemini:
-		upstreams = cfg.GeminiUpstream
+		return cfg.GeminiUpstream
 	case ChannelKindChat:
-		upstreams = cfg.ChatUpstream
+		return cfg.ChatUpstream
 	default:
-		upstreams = cfg.Upstream
+		return cfg.Upstream
 	}
+}
 
+// getUpstreamByIndex 根据索引获取上游配置
+// 注意：返回的是副本，避免指向 slice 元素的指针在 slice 重分配后失效
+func (s *ChannelScheduler) getUpstreamByIndex(index int, kind ChannelKind) *config.UpstreamConfig {
+	upstreams := s.GetUpstreams(kind)
 	if index >= 0 && index < len(upstreams) {
 		// 返回副本，避免返回指向 slice 元素的指针
 		upstream := upstreams[index]
@@ -366,6 +442,22 @@ func (s *ChannelScheduler) getUpstreamByIndex(index int, kind ChannelKind) *conf
 	return nil
 }
 
+// SelectPinnedChannel 按客户端指定的索引选择渠道（跳过调度），已禁用的渠道不可指定
+func (s *ChannelScheduler) SelectPinnedChannel(index int, kind ChannelKind) (*SelectionResult, error) {
+	upstream := s.getUpstreamByIndex(index, kind)
+	if upstream == nil {
+		return nil, fmt.Errorf("渠道索引 %d 不存在", index)
+	}
+	if config.GetChannelStatus(upstream) == "disabled" {
+		return nil, fmt.Errorf("渠道 [%d] %s 已禁用", index, upstream.Name)
+	}
+	return &SelectionResult{
+		Upstream:     upstream,
+		ChannelIndex: index,
+		Reason:       "header_pinned",
+	}, nil
+}
+
 // RecordSuccess 记录渠道成功（使用 baseURL + apiKey）
 func (s *ChannelScheduler) RecordSuccess(baseURL, apiKey string, kind ChannelKind) {
 	s.getMetricsManager(kind).RecordSuccess(baseURL, apiKey)
@@ -391,6 +483,22 @@ func (s *ChannelScheduler) RecordRequestEnd(baseURL, apiKey string, kind Channel
 	s.getMetricsManager(kind).RecordRequestEnd(baseURL, apiKey)
 }
 
+// RecordGlobalUsage 记录一次成功请求到全局限流窗口
+func (s *ChannelScheduler) RecordGlobalUsage(usage *types.Usage) {
+	tokens := 0
+	if usage != nil {
+		tokens = usage.InputTokens + usage.OutputTokens
+	}
+	s.globalRateLimiter.Record(tokens)
+}
+
+// CheckGlobalRateLimit 检查是否超过全局 RPM/TPM 上限（未配置时始终放行）
+// 超限时返回需要等待的时间
+func (s *ChannelScheduler) CheckGlobalRateLimit() (bool, time.Duration) {
+	maxRPM, maxTPM := s.configManager.GetGlobalRateLimit()
+	return s.globalRateLimiter.Allow(maxRPM, maxTPM)
+}
+
 // SetTraceAffinity 设置 Trace 亲和（按 kind 隔离）
 func (s *ChannelScheduler) SetTraceAffinity(userID string, channelIndex int, kind ChannelKind) {
 	if userID != "" {
@@ -399,6 +507,43 @@ func (s *ChannelScheduler) SetTraceAffinity(userID string, channelIndex int, kin
 	}
 }
 
+// MarkTraceAffinityFailed 标记会话在亲和渠道上发生 failover 错误（按 kind 隔离）
+// sticky 模式下该会话的下一次选择将按优先级重新选择渠道，成功后重新固定
+func (s *ChannelScheduler) MarkTraceAffinityFailed(userID string, channelIndex int, kind ChannelKind) {
+	if userID != "" {
+		compositeKey := string(kind) + ":" + userID
+		s.traceAffinity.MarkFailed(compositeKey, channelIndex)
+	}
+}
+
+// selectStickyChannel sticky 模式下选择会话固定的渠道
+// 仅要求渠道仍为 active、支持该模型、有可用密钥且未在本次请求/该会话中失败，不检查健康度
+func (s *ChannelScheduler) selectStickyChannel(activeChannels []ChannelInfo, userID string, failedChannels map[int]bool, kind ChannelKind) *SelectionResult {
+	compositeKey := string(kind) + ":" + userID
+	stickyIdx, ok := s.traceAffinity.GetStickyChannel(compositeKey)
+	if !ok || failedChannels[stickyIdx] {
+		return nil
+	}
+
+	for _, ch := range activeChannels {
+		if ch.Index != stickyIdx || ch.Status != "active" {
+			continue
+		}
+		upstream := s.getUpstreamByIndex(stickyIdx, kind)
+		if upstream == nil || len(upstream.APIKeys) == 0 {
+			return nil
+		}
+		prefix := kindSchedulerLogPrefix(kind)
+		log.Printf("[%s-Affinity] Sticky亲和选择渠道: [%d] %s (user: %s)", prefix, stickyIdx, upstream.Name, maskUserID(userID))
+		return &SelectionResult{
+			Upstream:     upstream,
+			ChannelIndex: stickyIdx,
+			Reason:       selectionReasonStickyAffinity,
+		}
+	}
+	return nil
+}
+
 // UpdateTraceAffinity 更新 Trace 亲和时间（续期，按 kind 隔离）
 func (s *ChannelScheduler) UpdateTraceAffinity(userID string, kind ChannelKind) {
 	if userID != "" {
@@ -432,6 +577,11 @@ func (s *ChannelScheduler) GetTraceAffinityManager() *session.TraceAffinityManag
 	return s.traceAffinity
 }
 
+// GetDeadLetterStore 获取死信存储（所有渠道都失败的请求）
+func (s *ChannelScheduler) GetDeadLetterStore() *metrics.DeadLetterStore {
+	return s.deadLetterStore
+}
+
 // GetChannelLogStore 根据渠道类型获取对应的日志存储
 func (s *ChannelScheduler) GetChannelLogStore(kind ChannelKind) *metrics.ChannelLogStore {
 	switch kind {
@@ -463,6 +613,39 @@ func (s *ChannelScheduler) ResetChannelMetrics(channelIndex int, kind ChannelKin
 	log.Printf("[%s-Reset] 渠道 [%d] %s 的熔断状态已重置（保留历史统计）", prefix, channelIndex, upstream.Name)
 }
 
+// ChannelAPIKeys 返回渠道当前 API Key 列表的副本（更新渠道前记录，配合 ResetAddedKeysFailureState 使用）
+func (s *ChannelScheduler) ChannelAPIKeys(channelIndex int, kind ChannelKind) []string {
+	upstream := s.getUpstreamByIndex(channelIndex, kind)
+	if upstream == nil {
+		return nil
+	}
+	return slices.Clone(upstream.APIKeys)
+}
+
+// ResetAddedKeysFailureState 重置渠道中新增 Key（不在 previousKeys 中）的熔断/失败状态
+// 新增的 Key 可能是从历史列表恢复的，其旧的熔断状态不应影响重新加入后的调度
+func (s *ChannelScheduler) ResetAddedKeysFailureState(channelIndex int, kind ChannelKind, previousKeys []string) {
+	upstream := s.getUpstreamByIndex(channelIndex, kind)
+	if upstream == nil {
+		return
+	}
+	metricsManager := s.getMetricsManager(kind)
+	added := 0
+	for _, apiKey := range upstream.APIKeys {
+		if slices.Contains(previousKeys, apiKey) {
+			continue
+		}
+		added++
+		for _, baseURL := range upstream.GetAllBaseURLs() {
+			metricsManager.ResetKeyFailureState(baseURL, apiKey)
+		}
+	}
+	if added > 0 {
+		prefix := kindSchedulerLogPrefix(kind)
+		log.Printf("[%s-Reset] 渠道 [%d] %s 新增 %d 个 Key，已重置其熔断状态", prefix, channelIndex, upstream.Name, added)
+	}
+}
+
 // ResetKeyMetrics 重置单个 Key 的指标
 func (s *ChannelScheduler) ResetKeyMetrics(baseURL, apiKey string, kind ChannelKind) {
 	s.getMetricsManager(kind).ResetKey(baseURL, apiKey)
@@ -596,6 +779,37 @@ func (s *ChannelScheduler) IsMultiChannelMode(kind ChannelKind) bool {
 	return s.GetActiveChannelCount(kind) > 1
 }
 
+// AllChannelsUnhealthy 判断可调度的渠道是否全部不健康（用于快速失败）
+// 存在促销期渠道时返回 false：促销渠道绕过健康检查，仍应尝试
+func (s *ChannelScheduler) AllChannelsUnhealthy(kind ChannelKind, model string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	activeChannels := s.getActiveChannels(kind, model)
+	if promoted := s.findPromotedChannel(activeChannels, kind); promoted != nil {
+		if upstream := s.getUpstreamByIndex(promoted.Index, kind); upstream != nil && len(upstream.APIKeys) > 0 {
+			return false
+		}
+	}
+
+	metricsManager := s.getMetricsManager(kind)
+	candidates := 0
+	for _, ch := range activeChannels {
+		if ch.Status != "active" {
+			continue
+		}
+		upstream := s.getUpstreamByIndex(ch.Index, kind)
+		if upstream == nil || len(upstream.APIKeys) == 0 {
+			continue
+		}
+		if metricsManager.IsChannelHealthyWithKeys(upstream.BaseURL, upstream.APIKeys) {
+			return false
+		}
+		candidates++
+	}
+	return candidates > 0
+}
+
 // maskUserID 掩码 user_id（保护隐私）
 func maskUserID(userID string) string {
 	if len(userID) <= 16 {
@@ -647,6 +861,13 @@ func (s *ChannelScheduler) InvalidateURLCache(kind ChannelKind, channelIndex int
 	}
 }
 
+// InvalidateAllURLCaches 清除所有渠道的 URL 状态（配置重载后渠道索引/URL 可能变化）
+func (s *ChannelScheduler) InvalidateAllURLCaches() {
+	if s.urlManager != nil {
+		s.urlManager.InvalidateAll()
+	}
+}
+
 // GetURLManagerStats 获取 URL 管理器统计
 func (s *ChannelScheduler) GetURLManagerStats() map[string]interface{} {
 	if s.urlManager != nil {
@@ -226,6 +226,85 @@ func TestNonPromotedChannelStillChecksHealth(t *testing.T) {
 	}
 }
 
+// TestStickyAffinityPinsUntilFailure 测试 sticky 亲和模式：成功期间固定渠道，失败后重新选择并重新固定
+func TestStickyAffinityPinsUntilFailure(t *testing.T) {
+	cfg := config.Config{
+		AffinityMode: config.AffinityModeSticky,
+		Upstream: []config.UpstreamConfig{
+			{
+				Name:     "primary",
+				BaseURL:  "https://primary.example.com",
+				APIKeys:  []string{"sk-primary-key"},
+				Status:   "active",
+				Priority: 1,
+			},
+			{
+				Name:     "secondary",
+				BaseURL:  "https://secondary.example.com",
+				APIKeys:  []string{"sk-secondary-key"},
+				Status:   "active",
+				Priority: 2,
+			},
+		},
+	}
+
+	scheduler, cleanup := createTestScheduler(t, cfg)
+	defer cleanup()
+
+	const userID = "sticky-user"
+	selectIndex := func() int {
+		t.Helper()
+		result, err := scheduler.SelectChannel(context.Background(), userID, make(map[int]bool), ChannelKindMessages, "")
+		if err != nil {
+			t.Fatalf("选择渠道失败: %v", err)
+		}
+		return result.ChannelIndex
+	}
+
+	// 会话固定在低优先级渠道，且该渠道健康度下降（default 模式下会漂移回 primary）
+	scheduler.SetTraceAffinity(userID, 1, ChannelKindMessages)
+	for i := 0; i < 10; i++ {
+		scheduler.messagesMetricsManager.RecordFailure("https://secondary.example.com", "sk-secondary-key")
+	}
+
+	for i := 0; i < 3; i++ {
+		if idx := selectIndex(); idx != 1 {
+			t.Fatalf("第 %d 次选择: 期望保持固定渠道 index=1，实际 index=%d", i+1, idx)
+		}
+		scheduler.SetTraceAffinity(userID, 1, ChannelKindMessages)
+	}
+
+	// 其他渠道的失败不影响固定关系
+	scheduler.MarkTraceAffinityFailed(userID, 0, ChannelKindMessages)
+	if idx := selectIndex(); idx != 1 {
+		t.Fatalf("非亲和渠道失败后期望仍为 index=1，实际 index=%d", idx)
+	}
+
+	// 该会话在固定渠道上发生 failover 错误后重新选择
+	scheduler.MarkTraceAffinityFailed(userID, 1, ChannelKindMessages)
+	if idx := selectIndex(); idx != 0 {
+		t.Fatalf("固定渠道失败后期望重新选择 index=0，实际 index=%d", idx)
+	}
+
+	// 新渠道成功后重新固定，之后即使 primary 不健康也保持
+	scheduler.SetTraceAffinity(userID, 0, ChannelKindMessages)
+	for i := 0; i < 10; i++ {
+		scheduler.messagesMetricsManager.RecordFailure("https://primary.example.com", "sk-primary-key")
+	}
+	if idx := selectIndex(); idx != 0 {
+		t.Fatalf("重新固定后期望 index=0，实际 index=%d", idx)
+	}
+
+	// 本次请求中已失败的渠道不会被 sticky 选中
+	result, err := scheduler.SelectChannel(context.Background(), userID, map[int]bool{0: true}, ChannelKindMessages, "")
+	if err != nil {
+		t.Fatalf("选择渠道失败: %v", err)
+	}
+	if result.ChannelIndex == 0 {
+		t.Fatalf("本次请求已失败的渠道不应被 sticky 选中")
+	}
+}
+
 // TestExpiredPromotionNotBypassHealthCheck 测试过期的促销不绕过健康检查
 func TestExpiredPromotionNotBypassHealthCheck(t *testing.T) {
 	// 设置促销截止时间为过去
@@ -525,3 +604,45 @@ func hasMetricsKey(allMetrics []*metrics.KeyMetrics, metricsKey string) bool {
 	}
 	return false
 }
+
+// TestResetAddedKeysFailureState 新增 Key 时仅重置新增 Key 的熔断状态
+func TestResetAddedKeysFailureState(t *testing.T) {
+	cfg := config.Config{
+		ChatUpstream: []config.UpstreamConfig{
+			{
+				Name:     "chat-channel",
+				BaseURLs: []string{"https://a.example.com", "https://b.example.com"},
+				APIKeys:  []string{"sk-old", "sk-restored"},
+				Status:   "active",
+				Priority: 1,
+			},
+		},
+	}
+
+	scheduler, cleanup := createTestScheduler(t, cfg)
+	defer cleanup()
+
+	metricsManager := scheduler.chatMetricsManager
+	for _, baseURL := range cfg.ChatUpstream[0].BaseURLs {
+		for i := 0; i < 10; i++ {
+			metricsManager.RecordFailure(baseURL, "sk-old")
+			metricsManager.RecordFailure(baseURL, "sk-restored")
+		}
+	}
+
+	// sk-restored 为本次新增（如从历史列表恢复）的 Key
+	scheduler.ResetAddedKeysFailureState(0, ChannelKindChat, []string{"sk-old"})
+
+	for _, baseURL := range cfg.ChatUpstream[0].BaseURLs {
+		if !metricsManager.IsKeyHealthy(baseURL, "sk-restored") {
+			t.Errorf("新增 Key 在 %s 上的熔断状态应已重置", baseURL)
+		}
+		if metricsManager.IsKeyHealthy(baseURL, "sk-old") {
+			t.Errorf("原有 Key 在 %s 上的熔断状态不应被重置", baseURL)
+		}
+	}
+
+	if keys := scheduler.ChannelAPIKeys(0, ChannelKindChat); len(keys) != 2 {
+		t.Errorf("ChannelAPIKeys() = %v, want 2 个 Key", keys)
+	}
+}
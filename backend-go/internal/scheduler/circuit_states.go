@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"sort"
+
+	"github.com/BenedictKing/ccx/internal/metrics"
+)
+
+// KeyCircuitStatus Key 的实时熔断状态（附带所属渠道）
+type KeyCircuitStatus struct {
+	metrics.KeyCircuitState
+	ChannelIndex int    `json:"channelIndex"` // -1 表示已不在当前配置中
+	ChannelName  string `json:"channelName,omitempty"`
+}
+
+// GetCircuitStates 返回指定类型所有 Key 的内存熔断状态
+// 熔断中的 Key 排在前面（按剩余恢复时间降序），其余按渠道顺序排列
+func (s *ChannelScheduler) GetCircuitStates(kind ChannelKind) []KeyCircuitStatus {
+	type channelRef struct {
+		index int
+		name  string
+	}
+	owners := make(map[string]channelRef)
+	for index, upstream := range s.GetUpstreams(kind) {
+		allKeys := append([]string{}, upstream.APIKeys...)
+		allKeys = append(allKeys, upstream.HistoricalAPIKeys...)
+		for _, baseURL := range upstream.GetAllBaseURLs() {
+			for _, apiKey := range allKeys {
+				owners[metrics.GenerateMetricsKey(baseURL, apiKey)] = channelRef{index: index, name: upstream.Name}
+			}
+		}
+	}
+
+	states := s.getMetricsManager(kind).GetKeyCircuitStates()
+	result := make([]KeyCircuitStatus, 0, len(states))
+	for _, state := range states {
+		item := KeyCircuitStatus{KeyCircuitState: state, ChannelIndex: -1}
+		if ref, ok := owners[state.MetricsKey]; ok {
+			item.ChannelIndex = ref.index
+			item.ChannelName = ref.name
+		}
+		result = append(result, item)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		iOpen, jOpen := result[i].State == metrics.CircuitStateOpen, result[j].State == metrics.CircuitStateOpen
+		if iOpen != jOpen {
+			return iOpen
+		}
+		if iOpen && result[i].RecoversInSeconds != result[j].RecoversInSeconds {
+			return result[i].RecoversInSeconds > result[j].RecoversInSeconds
+		}
+		if result[i].ChannelIndex != result[j].ChannelIndex {
+			return result[i].ChannelIndex < result[j].ChannelIndex
+		}
+		return result[i].MetricsKey < result[j].MetricsKey
+	})
+	return result
+}
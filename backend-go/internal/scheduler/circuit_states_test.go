@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+)
+
+// TestGetCircuitStates 熔断的 Key 返回 open 状态与剩余恢复时间，并排在正常 Key 之前
+func TestGetCircuitStates(t *testing.T) {
+	cfg := config.Config{
+		Upstream: []config.UpstreamConfig{
+			{Name: "alpha", BaseURL: "https://alpha.example.com", APIKeys: []string{"sk-alpha-1"}},
+			{Name: "beta", BaseURL: "https://beta.example.com", APIKeys: []string{"sk-beta-1"}},
+		},
+	}
+	s, cleanup := createTestScheduler(t, cfg)
+	defer cleanup()
+
+	mm := s.GetMetricsManagerByKind(ChannelKindMessages)
+	mm.RecordSuccess("https://alpha.example.com", "sk-alpha-1")
+	for range 10 {
+		mm.RecordFailure("https://beta.example.com", "sk-beta-1")
+	}
+	if !mm.IsKeyHealthy("https://alpha.example.com", "sk-alpha-1") || mm.IsKeyHealthy("https://beta.example.com", "sk-beta-1") {
+		t.Fatal("前置条件：beta 的 Key 应已熔断")
+	}
+
+	states := s.GetCircuitStates(ChannelKindMessages)
+	if len(states) != 2 {
+		t.Fatalf("len=%d, want 2", len(states))
+	}
+
+	broken := states[0]
+	if broken.ChannelName != "beta" || broken.ChannelIndex != 1 || broken.State != metrics.CircuitStateOpen {
+		t.Fatalf("states[0] = %+v, want beta open", broken)
+	}
+	if broken.BrokenAt == nil || broken.ConsecutiveFailures != 10 {
+		t.Fatalf("brokenAt=%v consecutiveFailures=%d", broken.BrokenAt, broken.ConsecutiveFailures)
+	}
+	wantRemaining := (mm.GetCircuitRecoveryTime() - time.Since(*broken.BrokenAt)).Seconds()
+	if broken.RecoversInSeconds <= 0 || broken.RecoversInSeconds < wantRemaining-1 || broken.RecoversInSeconds > wantRemaining+1 {
+		t.Fatalf("recoversInSeconds=%v, want ≈%v", broken.RecoversInSeconds, wantRemaining)
+	}
+
+	healthy := states[1]
+	if healthy.ChannelName != "alpha" || healthy.State != metrics.CircuitStateClosed || healthy.BrokenAt != nil || healthy.RecoversInSeconds != 0 {
+		t.Fatalf("states[1] = %+v, want alpha closed", healthy)
+	}
+
+	// 恢复时间缩短到已超过熔断时长：仍为 open（等待后台恢复），剩余时间为 0
+	mm.SetCircuitRecoveryTime(time.Nanosecond)
+	if got := s.GetCircuitStates(ChannelKindMessages)[0]; got.State != metrics.CircuitStateOpen || got.RecoversInSeconds != 0 {
+		t.Fatalf("到期未恢复的 Key = %+v, want open with 0 remaining", got)
+	}
+}
@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+)
+
+// keyReorderWindow Key 自动重排参考的成功率统计窗口
+const keyReorderWindow = 15 * time.Minute
+
+// StartKeyAutoReorder 启动 Key 自动重排后台任务（interval <= 0 时不启动）
+// 仅对开启 AutoReorderKeys 的渠道生效，通过 Stop 停止
+func (s *ChannelScheduler) StartKeyAutoReorder(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+				s.ReorderKeysByHealth()
+			}
+		}
+	}()
+
+	log.Printf("[Scheduler-KeyReorder] Key 自动重排已启动 (周期: %v)", interval)
+}
+
+// Stop 停止调度器的后台任务（幂等）
+func (s *ChannelScheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+}
+
+// ReorderKeysByHealth 按近 15 分钟成功率重排所有开启 AutoReorderKeys 渠道的 Key
+// 返回实际发生重排的渠道数量
+func (s *ChannelScheduler) ReorderKeysByHealth() int {
+	cfg := s.configManager.GetConfig()
+
+	kinds := []struct {
+		kind      ChannelKind
+		upstreams []config.UpstreamConfig
+	}{
+		{ChannelKindMessages, cfg.Upstream},
+		{ChannelKindResponses, cfg.ResponsesUpstream},
+		{ChannelKindGemini, cfg.GeminiUpstream},
+		{ChannelKindChat, cfg.ChatUpstream},
+	}
+
+	reordered := 0
+	for _, k := range kinds {
+		for i := range k.upstreams {
+			upstream := &k.upstreams[i]
+			if !upstream.AutoReorderKeys || len(upstream.APIKeys) < 2 {
+				continue
+			}
+			if s.reorderUpstreamKeys(k.kind, i, upstream) {
+				reordered++
+			}
+		}
+	}
+	return reordered
+}
+
+// reorderUpstreamKeys 重排单个渠道的 Key，返回是否发生了变化
+// 近期没有请求记录的 Key 保持原位置，仅在有记录的 Key 之间按成功率稳定排序
+func (s *ChannelScheduler) reorderUpstreamKeys(kind ChannelKind, channelIndex int, upstream *config.UpstreamConfig) bool {
+	metricsManager := s.getMetricsManager(kind)
+	if metricsManager == nil {
+		return false
+	}
+
+	baseURLs := upstream.GetAllBaseURLs()
+	used := make(map[string]bool)
+	for _, info := range metricsManager.GetChannelKeyUsageInfoMultiURL(baseURLs, upstream.APIKeys) {
+		if info.RequestCount > 0 {
+			used[info.APIKey] = true
+		}
+	}
+
+	type keyScore struct {
+		key         string
+		successRate float64
+	}
+	var positions []int
+	var scored []keyScore
+	for pos, key := range upstream.APIKeys {
+		if !used[key] {
+			continue
+		}
+		var requestCount, successCount int64
+		for _, baseURL := range baseURLs {
+			stats := metricsManager.GetTimeWindowStatsForKey(baseURL, key, keyReorderWindow)
+			requestCount += stats.RequestCount
+			successCount += stats.SuccessCount
+		}
+		if requestCount == 0 {
+			continue
+		}
+		positions = append(positions, pos)
+		scored = append(scored, keyScore{key: key, successRate: float64(successCount) / float64(requestCount)})
+	}
+
+	if len(scored) < 2 {
+		return false
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].successRate > scored[j].successRate
+	})
+
+	desired := make([]string, len(upstream.APIKeys))
+	copy(desired, upstream.APIKeys)
+	changed := false
+	for i, pos := range positions {
+		if desired[pos] != scored[i].key {
+			desired[pos] = scored[i].key
+			changed = true
+		}
+	}
+	if !changed {
+		return false
+	}
+
+	if err := s.configManager.ReorderAPIKeys(string(kind), channelIndex, desired); err != nil {
+		log.Printf("[Scheduler-KeyReorder] 警告: %s 渠道 [%d] %s 重排 Key 失败: %v", kind, channelIndex, upstream.Name, err)
+		return false
+	}
+
+	log.Printf("[Scheduler-KeyReorder] %s 渠道 [%d] %s 已按近期成功率重排 %d 个 Key", kind, channelIndex, upstream.Name, len(scored))
+	return true
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+)
+
+// recordKeyResults 为指定 Key 写入成功/失败请求记录
+func recordKeyResults(s *ChannelScheduler, kind ChannelKind, baseURL, apiKey string, success, failure int) {
+	mm := s.getMetricsManager(kind)
+	for i := 0; i < success; i++ {
+		id := mm.RecordRequestConnected(baseURL, apiKey, "test-model")
+		mm.RecordRequestFinalizeSuccess(baseURL, apiKey, id, nil)
+	}
+	for i := 0; i < failure; i++ {
+		id := mm.RecordRequestConnected(baseURL, apiKey, "test-model")
+		mm.RecordRequestFinalizeFailure(baseURL, apiKey, id)
+	}
+}
+
+func TestReorderKeysByHealth(t *testing.T) {
+	const baseURL = "https://chat.example.com"
+
+	tests := []struct {
+		name        string
+		autoReorder bool
+		seed        func(s *ChannelScheduler)
+		wantKeys    []string
+		wantChanged int
+	}{
+		{
+			name:        "成功率高的 Key 前移",
+			autoReorder: true,
+			seed: func(s *ChannelScheduler) {
+				recordKeyResults(s, ChannelKindChat, baseURL, "sk-a", 1, 4)
+				recordKeyResults(s, ChannelKindChat, baseURL, "sk-b", 4, 1)
+				recordKeyResults(s, ChannelKindChat, baseURL, "sk-c", 5, 0)
+			},
+			wantKeys:    []string{"sk-c", "sk-b", "sk-a"},
+			wantChanged: 1,
+		},
+		{
+			name:        "无近期记录的 Key 保持原位",
+			autoReorder: true,
+			seed: func(s *ChannelScheduler) {
+				recordKeyResults(s, ChannelKindChat, baseURL, "sk-a", 0, 3)
+				recordKeyResults(s, ChannelKindChat, baseURL, "sk-c", 3, 0)
+			},
+			wantKeys:    []string{"sk-c", "sk-b", "sk-a"},
+			wantChanged: 1,
+		},
+		{
+			name:        "已按成功率排序时不变",
+			autoReorder: true,
+			seed: func(s *ChannelScheduler) {
+				recordKeyResults(s, ChannelKindChat, baseURL, "sk-a", 5, 0)
+				recordKeyResults(s, ChannelKindChat, baseURL, "sk-b", 3, 2)
+			},
+			wantKeys:    []string{"sk-a", "sk-b", "sk-c"},
+			wantChanged: 0,
+		},
+		{
+			name:        "未开启 AutoReorderKeys 时不重排",
+			autoReorder: false,
+			seed: func(s *ChannelScheduler) {
+				recordKeyResults(s, ChannelKindChat, baseURL, "sk-a", 0, 5)
+				recordKeyResults(s, ChannelKindChat, baseURL, "sk-c", 5, 0)
+			},
+			wantKeys:    []string{"sk-a", "sk-b", "sk-c"},
+			wantChanged: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{
+				ChatUpstream: []config.UpstreamConfig{
+					{
+						Name:            "chat-reorder",
+						BaseURL:         baseURL,
+						APIKeys:         []string{"sk-a", "sk-b", "sk-c"},
+						ServiceType:     "openai",
+						Status:          "active",
+						AutoReorderKeys: tt.autoReorder,
+					},
+				},
+			}
+			s, cleanup := createTestScheduler(t, cfg)
+			defer cleanup()
+			defer s.Stop()
+
+			tt.seed(s)
+
+			if got := s.ReorderKeysByHealth(); got != tt.wantChanged {
+				t.Errorf("ReorderKeysByHealth() = %d, want %d", got, tt.wantChanged)
+			}
+
+			keys := s.configManager.GetConfig().ChatUpstream[0].APIKeys
+			if len(keys) != len(tt.wantKeys) {
+				t.Fatalf("keys = %v, want %v", keys, tt.wantKeys)
+			}
+			for i := range keys {
+				if keys[i] != tt.wantKeys[i] {
+					t.Fatalf("keys = %v, want %v", keys, tt.wantKeys)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/httpclient"
+)
+
+// latencySampleTimeout 单个 URL 探测的超时时间
+const latencySampleTimeout = 5 * time.Second
+
+// StartLatencySampler 启动多端点渠道的后台延迟探测（interval <= 0 时不启动）
+// 启动时立即探测一轮，之后按周期探测，使 URL 排序在真实流量到来前即有延迟数据，通过 Stop 停止
+func (s *ChannelScheduler) StartLatencySampler(interval time.Duration) {
+	if interval <= 0 || s.urlManager == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		s.SampleURLLatencies()
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+				s.SampleURLLatencies()
+			}
+		}
+	}()
+
+	log.Printf("[Scheduler-LatencySampler] URL 延迟探测已启动 (周期: %v)", interval)
+}
+
+// SampleURLLatencies 对所有多端点渠道的每个 BaseURL 发送 HEAD 请求并记录延迟
+// 只要收到 HTTP 响应（任意状态码）即视为可达并记录延迟；连接失败的 URL 不记录，交由真实请求的 failover 处理
+// 单 URL 渠道与已禁用渠道跳过，返回成功记录延迟的 URL 数量
+func (s *ChannelScheduler) SampleURLLatencies() int {
+	if s.urlManager == nil {
+		return 0
+	}
+	cfg := s.configManager.GetConfig()
+
+	kinds := []struct {
+		kind      ChannelKind
+		upstreams []config.UpstreamConfig
+	}{
+		{ChannelKindMessages, cfg.Upstream},
+		{ChannelKindResponses, cfg.ResponsesUpstream},
+		{ChannelKindGemini, cfg.GeminiUpstream},
+		{ChannelKindChat, cfg.ChatUpstream},
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		sampled int
+	)
+	for _, k := range kinds {
+		for i := range k.upstreams {
+			upstream := &k.upstreams[i]
+			urls := upstream.GetAllBaseURLs()
+			if len(urls) < 2 || config.GetChannelStatus(upstream) == "disabled" {
+				continue
+			}
+			channelKey := urlManagerChannelKey(k.kind, i)
+			for _, url := range urls {
+				wg.Add(1)
+				go func(upstream *config.UpstreamConfig, url string) {
+					defer wg.Done()
+					latency, ok := probeURLLatency(upstream, url)
+					if !ok {
+						return
+					}
+					s.urlManager.RecordLatency(channelKey, urls, url, latency)
+					mu.Lock()
+					sampled++
+					mu.Unlock()
+				}(upstream, url)
+			}
+		}
+	}
+	wg.Wait()
+	return sampled
+}
+
+// probeURLLatency 向 URL 发送一次 HEAD 请求，返回收到响应头的耗时
+func probeURLLatency(upstream *config.UpstreamConfig, url string) (time.Duration, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), latencySampleTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, false
+	}
+	client := httpclient.GetManager().GetStandardClientForUpstream(latencySampleTimeout, 0, upstream, upstream.ProxyURL)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, false
+	}
+	latency := time.Since(start)
+	resp.Body.Close()
+	return latency, true
+}
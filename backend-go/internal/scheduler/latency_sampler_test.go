@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+)
+
+// TestSampleURLLatencies 无真实流量时，后台探测即可记录延迟并使更快的 URL 排在前面
+func TestSampleURLLatencies(t *testing.T) {
+	var probes atomic.Int64
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		time.Sleep(80 * time.Millisecond)
+		w.WriteHeader(http.StatusNotFound) // 任意状态码均视为可达
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		if r.Method != http.MethodHead {
+			t.Errorf("method=%s, want HEAD", r.Method)
+		}
+	}))
+	defer fast.Close()
+	single := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("单 URL 渠道不应被探测")
+	}))
+	defer single.Close()
+
+	s, cleanup := createTestScheduler(t, config.Config{
+		Upstream: []config.UpstreamConfig{
+			{Name: "multi", BaseURLs: []string{slow.URL, fast.URL}, APIKeys: []string{"sk-1"}, Status: "active"},
+			{Name: "single", BaseURL: single.URL, APIKeys: []string{"sk-2"}, Status: "active"},
+		},
+	})
+	defer cleanup()
+	defer s.Stop()
+
+	urls := []string{slow.URL, fast.URL}
+	if got := s.GetSortedURLsForChannel(ChannelKindMessages, 0, urls); got[0].URL != slow.URL {
+		t.Fatalf("无延迟数据时应保持原始顺序, got %s first", got[0].URL)
+	}
+
+	if n := s.SampleURLLatencies(); n != 2 {
+		t.Fatalf("SampleURLLatencies() = %d, want 2", n)
+	}
+	if probes.Load() != 2 {
+		t.Fatalf("probes=%d, want 2", probes.Load())
+	}
+
+	got := s.GetSortedURLsForChannel(ChannelKindMessages, 0, urls)
+	if got[0].URL != fast.URL || got[0].OriginalIdx != 1 {
+		t.Fatalf("探测后更快的 URL 应排在前面, got %+v", got)
+	}
+
+	stats := s.GetURLManagerStats()
+	channels := stats["channels"].(map[int]interface{})
+	if len(channels) != 1 {
+		t.Fatalf("只应记录多 URL 渠道的状态, got %d", len(channels))
+	}
+	for _, ch := range channels {
+		for _, u := range ch.(map[string]interface{})["urls"].([]map[string]interface{}) {
+			if u["total_requests"].(int64) != 0 {
+				t.Fatalf("探测不应计入请求数: %+v", u)
+			}
+			if u["url"] == slow.URL && u["latency_ms"].(int64) < 80 {
+				t.Fatalf("慢 URL 延迟应不低于 80ms: %+v", u)
+			}
+		}
+	}
+}
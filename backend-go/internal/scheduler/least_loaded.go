@@ -0,0 +1,53 @@
+package scheduler
+
+import (
+	"log"
+
+	"github.com/BenedictKing/ccx/internal/config"
+)
+
+// selectionReasonLeastLoaded least_loaded 调度模式下按负载选择
+const selectionReasonLeastLoaded = "least_loaded"
+
+// GetChannelActiveRequests 获取渠道当前进行中的请求数（聚合所有 BaseURL 与 Key）
+func (s *ChannelScheduler) GetChannelActiveRequests(channelIndex int, kind ChannelKind) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.channelActiveRequests(s.getUpstreamByIndex(channelIndex, kind), kind)
+}
+
+// channelActiveRequests 统计渠道进行中的请求数（调用方需持有 s.mu 读锁）
+func (s *ChannelScheduler) channelActiveRequests(upstream *config.UpstreamConfig, kind ChannelKind) int64 {
+	if upstream == nil {
+		return 0
+	}
+	return s.getMetricsManager(kind).GetActiveRequests(upstream.GetAllBaseURLs(), upstream.APIKeys)
+}
+
+// selectLeastLoadedChannel 在候选健康渠道中选择进行中请求数最少的（候选按优先级排序，负载相同时取优先级高者）
+func (s *ChannelScheduler) selectLeastLoadedChannel(candidates []ChannelInfo, kind ChannelKind) *SelectionResult {
+	var best *config.UpstreamConfig
+	bestIndex := -1
+	var bestActive int64
+	for _, ch := range candidates {
+		upstream := s.getUpstreamByIndex(ch.Index, kind)
+		if upstream == nil {
+			continue
+		}
+		active := s.channelActiveRequests(upstream, kind)
+		if best == nil || active < bestActive {
+			best, bestIndex, bestActive = upstream, ch.Index, active
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	prefix := kindSchedulerLogPrefix(kind)
+	log.Printf("[%s-LeastLoaded] 选择负载最低的渠道: [%d] %s (进行中请求: %d, 候选: %d)", prefix, bestIndex, best.Name, bestActive, len(candidates))
+	return &SelectionResult{
+		Upstream:     best,
+		ChannelIndex: bestIndex,
+		Reason:       selectionReasonLeastLoaded,
+	}
+}
@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+)
+
+// TestSelectChannel_LeastLoaded least_loaded 模式下选择进行中请求最少的健康渠道，促销与亲和仍然优先
+func TestSelectChannel_LeastLoaded(t *testing.T) {
+	cfg := config.Config{
+		SchedulingMode: config.SchedulingModeLeastLoaded,
+		Upstream: []config.UpstreamConfig{
+			{Name: "primary", BaseURL: "https://primary.example.com", APIKeys: []string{"sk-p1", "sk-p2"}, Status: "active", Priority: 1},
+			{Name: "secondary", BaseURL: "https://secondary.example.com", BaseURLs: []string{"https://secondary.example.com", "https://secondary-2.example.com"}, APIKeys: []string{"sk-s"}, Status: "active", Priority: 2},
+			{Name: "idle-but-broken", BaseURL: "https://broken.example.com", APIKeys: []string{"sk-b"}, Status: "active", Priority: 3},
+		},
+	}
+	s, cleanup := createTestScheduler(t, cfg)
+	defer cleanup()
+
+	m := s.messagesMetricsManager
+	load := func(baseURL, apiKey string, n int) {
+		for range n {
+			m.RecordRequestStart(baseURL, apiKey)
+		}
+	}
+	// primary 跨两个 Key 共 4 个进行中请求，secondary 跨两个 BaseURL 共 2 个，broken 空闲但已熔断
+	load("https://primary.example.com", "sk-p1", 3)
+	load("https://primary.example.com", "sk-p2", 1)
+	load("https://secondary.example.com", "sk-s", 1)
+	load("https://secondary-2.example.com", "sk-s", 1)
+	for range 10 {
+		m.RecordFailure("https://broken.example.com", "sk-b")
+	}
+
+	if got := s.GetChannelActiveRequests(0, ChannelKindMessages); got != 4 {
+		t.Fatalf("GetChannelActiveRequests(primary) = %d, want 4", got)
+	}
+	if got := s.GetChannelActiveRequests(1, ChannelKindMessages); got != 2 {
+		t.Fatalf("GetChannelActiveRequests(secondary) = %d, want 2", got)
+	}
+
+	tests := []struct {
+		name      string
+		userID    string
+		failed    map[int]bool
+		setup     func()
+		wantIndex int
+		wantWhy   string
+	}{
+		{name: "选择负载最低的健康渠道", wantIndex: 1, wantWhy: selectionReasonLeastLoaded},
+		{name: "跳过已失败渠道", failed: map[int]bool{1: true}, wantIndex: 0, wantWhy: selectionReasonLeastLoaded},
+		{
+			name:      "负载相同时按优先级",
+			setup:     func() { load("https://secondary.example.com", "sk-s", 2) },
+			wantIndex: 0, wantWhy: selectionReasonLeastLoaded,
+		},
+		{
+			name:   "Trace 亲和优先于负载",
+			userID: "conv-affinity",
+			setup: func() {
+				load("https://primary.example.com", "sk-p1", 5)
+				s.SetTraceAffinity("conv-affinity", 0, ChannelKindMessages)
+			},
+			wantIndex: 0, wantWhy: selectionReasonTraceAffinity,
+		},
+		{
+			name: "促销渠道优先于负载",
+			setup: func() {
+				until := time.Now().Add(time.Hour)
+				if _, err := s.configManager.UpdateUpstream(0, config.UpstreamUpdate{PromotionUntil: &until}); err != nil {
+					t.Fatalf("设置促销期失败: %v", err)
+				}
+			},
+			wantIndex: 0, wantWhy: selectionReasonPromotion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup()
+			}
+			result, err := s.SelectChannel(context.Background(), tt.userID, tt.failed, ChannelKindMessages, "")
+			if err != nil {
+				t.Fatalf("SelectChannel() err = %v", err)
+			}
+			if result.ChannelIndex != tt.wantIndex || result.Reason != tt.wantWhy {
+				t.Fatalf("SelectChannel() = [%d] %s, want [%d] %s", result.ChannelIndex, result.Reason, tt.wantIndex, tt.wantWhy)
+			}
+		})
+	}
+}
+
+// TestSelectChannel_PriorityModeIgnoresLoad 默认 priority 模式不受负载影响
+func TestSelectChannel_PriorityModeIgnoresLoad(t *testing.T) {
+	cfg := config.Config{
+		Upstream: []config.UpstreamConfig{
+			{Name: "primary", BaseURL: "https://primary.example.com", APIKeys: []string{"sk-p"}, Status: "active", Priority: 1},
+			{Name: "secondary", BaseURL: "https://secondary.example.com", APIKeys: []string{"sk-s"}, Status: "active", Priority: 2},
+		},
+	}
+	s, cleanup := createTestScheduler(t, cfg)
+	defer cleanup()
+
+	for range 5 {
+		s.messagesMetricsManager.RecordRequestStart("https://primary.example.com", "sk-p")
+	}
+	result, err := s.SelectChannel(context.Background(), "", nil, ChannelKindMessages, "")
+	if err != nil || result.ChannelIndex != 0 || result.Reason != "priority_order" {
+		t.Fatalf("SelectChannel() = %+v, %v, want primary priority_order", result, err)
+	}
+}
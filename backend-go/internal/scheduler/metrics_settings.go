@@ -0,0 +1,20 @@
+package scheduler
+
+import (
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+)
+
+// NewKindMetricsManager 按接口类型的独立配置创建指标管理器（store 为 nil 时使用纯内存模式）
+func NewKindMetricsManager(envCfg *config.EnvConfig, kind ChannelKind, store metrics.PersistenceStore) *metrics.MetricsManager {
+	settings := envCfg.MetricsSettingsFor(string(kind))
+	var mm *metrics.MetricsManager
+	if store != nil {
+		mm = metrics.NewMetricsManagerWithPersistence(settings.WindowSize, settings.FailureThreshold, store, string(kind))
+	} else {
+		mm = metrics.NewMetricsManagerWithConfig(settings.WindowSize, settings.FailureThreshold)
+		mm.SetAPIType(string(kind))
+	}
+	mm.SetCircuitRecoveryTime(settings.CircuitRecoveryTime)
+	return mm
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/session"
+	"github.com/BenedictKing/ccx/internal/warmup"
+)
+
+// TestNewKindMetricsManager_PerKindSettings 各接口类型的指标管理器使用各自的窗口大小、失败率阈值与恢复时间
+func TestNewKindMetricsManager_PerKindSettings(t *testing.T) {
+	envCfg := &config.EnvConfig{
+		MetricsWindowSize:       10,
+		MetricsFailureThreshold: 0.5,
+		MetricsCircuitRecovery:  900,
+		MetricsKindOverrides: map[string]config.MetricsKindSettings{
+			"responses": {WindowSize: 5},
+			"gemini":    {WindowSize: 20, FailureThreshold: 0.8, CircuitRecoveryTime: 5 * time.Minute},
+			"chat":      {FailureThreshold: 0.3, CircuitRecoveryTime: time.Minute},
+		},
+	}
+
+	tests := []struct {
+		kind          ChannelKind
+		wantWindow    int
+		wantThreshold float64
+		wantRecovery  time.Duration
+	}{
+		{kind: ChannelKindMessages, wantWindow: 10, wantThreshold: 0.5, wantRecovery: 15 * time.Minute},
+		{kind: ChannelKindResponses, wantWindow: 5, wantThreshold: 0.5, wantRecovery: 15 * time.Minute},
+		{kind: ChannelKindGemini, wantWindow: 20, wantThreshold: 0.8, wantRecovery: 5 * time.Minute},
+		{kind: ChannelKindChat, wantWindow: 10, wantThreshold: 0.3, wantRecovery: time.Minute},
+	}
+
+	messagesMetrics := NewKindMetricsManager(envCfg, ChannelKindMessages, nil)
+	responsesMetrics := NewKindMetricsManager(envCfg, ChannelKindResponses, nil)
+	geminiMetrics := NewKindMetricsManager(envCfg, ChannelKindGemini, nil)
+	chatMetrics := NewKindMetricsManager(envCfg, ChannelKindChat, nil)
+	defer func() {
+		messagesMetrics.Stop()
+		responsesMetrics.Stop()
+		geminiMetrics.Stop()
+		chatMetrics.Stop()
+	}()
+
+	cfgManager, cleanup := createTestConfigManager(t, config.Config{})
+	defer cleanup()
+	s := NewChannelScheduler(cfgManager, messagesMetrics, responsesMetrics, geminiMetrics, chatMetrics, session.NewTraceAffinityManager(), warmup.NewURLManager(30*time.Second, 3))
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			mm := s.GetMetricsManagerByKind(tt.kind)
+			if got := mm.GetWindowSize(); got != tt.wantWindow {
+				t.Errorf("GetWindowSize()=%d, want %d", got, tt.wantWindow)
+			}
+			if got := mm.GetFailureThreshold(); got != tt.wantThreshold {
+				t.Errorf("GetFailureThreshold()=%v, want %v", got, tt.wantThreshold)
+			}
+			if got := mm.GetCircuitRecoveryTime(); got != tt.wantRecovery {
+				t.Errorf("GetCircuitRecoveryTime()=%v, want %v", got, tt.wantRecovery)
+			}
+		})
+	}
+}
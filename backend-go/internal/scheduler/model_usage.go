@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/metrics"
+)
+
+// GetModelUsageSummary 汇总指定接口类型在时间窗口内按模型分组的用量
+// 多个 kind 时合并同名模型；配置了模型定价时附带估算费用。结果按请求数降序排列
+func (s *ChannelScheduler) GetModelUsageSummary(kinds []ChannelKind, duration time.Duration) []metrics.ModelUsageSummary {
+	merged := make(map[string]*metrics.ModelUsageSummary)
+	for _, kind := range kinds {
+		mergeModelUsage(merged, s.getMetricsManager(kind).GetModelUsageSummary(duration))
+	}
+	return s.finalizeModelUsage(merged)
+}
+
+// GetGlobalUsageSummary 汇总指定接口类型在时间窗口内所有渠道的用量（含持久化的历史数据）
+// 返回合并后的总览与按模型分组的用量，duration 需调用方按 GetStatsRetention 截断
+func (s *ChannelScheduler) GetGlobalUsageSummary(kinds []ChannelKind, duration time.Duration) (metrics.GlobalStatsSummary, []metrics.ModelUsageSummary) {
+	total := metrics.GlobalStatsSummary{Duration: duration.String()}
+	merged := make(map[string]*metrics.ModelUsageSummary)
+	for _, kind := range kinds {
+		summary, models := s.getMetricsManager(kind).GetUsageSummary(duration)
+		total.TotalRequests += summary.TotalRequests
+		total.TotalSuccess += summary.TotalSuccess
+		total.TotalFailure += summary.TotalFailure
+		total.TotalInputTokens += summary.TotalInputTokens
+		total.TotalOutputTokens += summary.TotalOutputTokens
+		total.TotalCacheCreationTokens += summary.TotalCacheCreationTokens
+		total.TotalCacheReadTokens += summary.TotalCacheReadTokens
+		total.TotalThinkingTokens += summary.TotalThinkingTokens
+		mergeModelUsage(merged, models)
+	}
+	if total.TotalRequests > 0 {
+		total.AvgSuccessRate = float64(total.TotalSuccess) / float64(total.TotalRequests) * 100
+	}
+	return total, s.finalizeModelUsage(merged)
+}
+
+// GetStatsRetention 返回指定接口类型可查询统计的最长时间范围（取各类型中最短的保留时长）
+func (s *ChannelScheduler) GetStatsRetention(kinds []ChannelKind) time.Duration {
+	var retention time.Duration
+	for _, kind := range kinds {
+		r := s.getMetricsManager(kind).GetStatsRetention()
+		if retention == 0 || r < retention {
+			retention = r
+		}
+	}
+	return retention
+}
+
+// mergeModelUsage 将单个接口类型的模型用量合并到 merged（同名模型累加）
+func mergeModelUsage(merged map[string]*metrics.ModelUsageSummary, models map[string]*metrics.ModelUsageSummary) {
+	for model, summary := range models {
+		existing, ok := merged[model]
+		if !ok {
+			copied := *summary
+			merged[model] = &copied
+			continue
+		}
+		existing.RequestCount += summary.RequestCount
+		existing.SuccessCount += summary.SuccessCount
+		existing.FailureCount += summary.FailureCount
+		existing.InputTokens += summary.InputTokens
+		existing.OutputTokens += summary.OutputTokens
+		existing.CacheCreationInputTokens += summary.CacheCreationInputTokens
+		existing.CacheReadInputTokens += summary.CacheReadInputTokens
+		existing.ThinkingTokens += summary.ThinkingTokens
+	}
+}
+
+// finalizeModelUsage 计算成功率与估算费用，并按请求数降序排列
+func (s *ChannelScheduler) finalizeModelUsage(merged map[string]*metrics.ModelUsageSummary) []metrics.ModelUsageSummary {
+	result := make([]metrics.ModelUsageSummary, 0, len(merged))
+	for model, summary := range merged {
+		summary.SuccessRate = float64(summary.SuccessCount) / float64(summary.RequestCount) * 100
+		if price, ok := s.configManager.GetModelPrice(model); ok {
+			cost := price.EstimateCost(summary.InputTokens, summary.OutputTokens, summary.CacheReadInputTokens, summary.CacheCreationInputTokens)
+			summary.EstimatedCost = &cost
+		}
+		result = append(result, *summary)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].RequestCount != result[j].RequestCount {
+			return result[i].RequestCount > result[j].RequestCount
+		}
+		return result[i].Model < result[j].Model
+	})
+	return result
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/metrics"
+)
+
+// OrphanMetrics 孤立指标：存在于指标存储中，但当前配置里已没有对应的 (BaseURL, APIKey) 组合
+// 通常由轮换 Key 或修改 BaseURL 后未清理产生
+type OrphanMetrics struct {
+	MetricsKey     string     `json:"metricsKey"`
+	BaseURL        string     `json:"baseUrl"`
+	KeyMask        string     `json:"keyMask"`
+	RequestCount   int64      `json:"requestCount"`
+	LastActivityAt *time.Time `json:"lastActivityAt,omitempty"` // 最近一次成功或失败的时间
+}
+
+// collectConfiguredMetricsKeys 收集当前配置可推导出的所有 metricsKey（含历史 Key）
+func (s *ChannelScheduler) collectConfiguredMetricsKeys(kind ChannelKind) map[string]bool {
+	configured := make(map[string]bool)
+	for _, upstream := range s.GetUpstreams(kind) {
+		allKeys := append([]string{}, upstream.APIKeys...)
+		allKeys = append(allKeys, upstream.HistoricalAPIKeys...)
+		for _, baseURL := range upstream.GetAllBaseURLs() {
+			for _, apiKey := range allKeys {
+				configured[metrics.GenerateMetricsKey(baseURL, apiKey)] = true
+			}
+		}
+	}
+	return configured
+}
+
+// FindOrphanMetrics 列出指定类型中未映射到任何当前配置的指标（按最近活动时间倒序）
+func (s *ChannelScheduler) FindOrphanMetrics(kind ChannelKind) []OrphanMetrics {
+	configured := s.collectConfiguredMetricsKeys(kind)
+
+	orphans := make([]OrphanMetrics, 0)
+	for _, km := range s.getMetricsManager(kind).GetAllKeyMetrics() {
+		if configured[km.MetricsKey] {
+			continue
+		}
+		lastActivity := km.LastSuccessAt
+		if km.LastFailureAt != nil && (lastActivity == nil || km.LastFailureAt.After(*lastActivity)) {
+			lastActivity = km.LastFailureAt
+		}
+		orphans = append(orphans, OrphanMetrics{
+			MetricsKey:     km.MetricsKey,
+			BaseURL:        km.BaseURL,
+			KeyMask:        km.KeyMask,
+			RequestCount:   km.RequestCount,
+			LastActivityAt: lastActivity,
+		})
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		a, b := orphans[i].LastActivityAt, orphans[j].LastActivityAt
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return a.After(*b)
+	})
+	return orphans
+}
+
+// PurgeOrphanMetrics 删除指定类型中的孤立指标（内存 + 持久化）
+// 返回值：删除的 metricsKey 数量，以及从持久化存储删除的记录数
+func (s *ChannelScheduler) PurgeOrphanMetrics(kind ChannelKind) (purged int, deletedRecords int64) {
+	orphans := s.FindOrphanMetrics(kind)
+	if len(orphans) == 0 {
+		return 0, 0
+	}
+
+	metricsKeys := make([]string, 0, len(orphans))
+	for _, orphan := range orphans {
+		metricsKeys = append(metricsKeys, orphan.MetricsKey)
+	}
+	deletedRecords = s.getMetricsManager(kind).DeleteByMetricsKeys(metricsKeys)
+
+	prefix := kindSchedulerLogPrefix(kind)
+	log.Printf("[%s-Orphan] 已清理 %d 个孤立指标（持久化记录: %d）", prefix, len(metricsKeys), deletedRecords)
+	return len(metricsKeys), deletedRecords
+}
@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/metrics"
+)
+
+// TestOrphanMetrics_DetectAndPurge 轮换掉的 Key 的指标被识别为孤立指标并可清理，配置中的 Key（含历史 Key）保留
+func TestOrphanMetrics_DetectAndPurge(t *testing.T) {
+	cfg := config.Config{
+		Upstream: []config.UpstreamConfig{
+			{
+				Name:              "channel",
+				BaseURL:           "https://example.com",
+				APIKeys:           []string{"sk-current"},
+				HistoricalAPIKeys: []string{"sk-historical"},
+				Status:            "active",
+				Priority:          1,
+			},
+		},
+	}
+
+	scheduler, cleanup := createTestScheduler(t, cfg)
+	defer cleanup()
+
+	metricsManager := scheduler.messagesMetricsManager
+	metricsManager.RecordSuccess("https://example.com", "sk-current")
+	metricsManager.RecordSuccess("https://example.com", "sk-historical")
+	metricsManager.RecordFailure("https://example.com", "sk-rotated")
+	metricsManager.RecordSuccess("https://old.example.com", "sk-current")
+
+	orphans := scheduler.FindOrphanMetrics(ChannelKindMessages)
+	if len(orphans) != 2 {
+		t.Fatalf("孤立指标数量 = %d, want 2: %+v", len(orphans), orphans)
+	}
+	wantOrphans := map[string]bool{
+		metrics.GenerateMetricsKey("https://example.com", "sk-rotated"):     true,
+		metrics.GenerateMetricsKey("https://old.example.com", "sk-current"): true,
+	}
+	for _, orphan := range orphans {
+		if !wantOrphans[orphan.MetricsKey] {
+			t.Errorf("意外的孤立指标: %+v", orphan)
+		}
+		if orphan.LastActivityAt == nil {
+			t.Errorf("孤立指标 %s 缺少最近活动时间", orphan.MetricsKey)
+		}
+	}
+	if others := scheduler.FindOrphanMetrics(ChannelKindChat); len(others) != 0 {
+		t.Errorf("Chat 类型不应有孤立指标: %+v", others)
+	}
+
+	if purged, _ := scheduler.PurgeOrphanMetrics(ChannelKindMessages); purged != 2 {
+		t.Fatalf("PurgeOrphanMetrics() purged = %d, want 2", purged)
+	}
+	if orphans := scheduler.FindOrphanMetrics(ChannelKindMessages); len(orphans) != 0 {
+		t.Fatalf("清理后仍有孤立指标: %+v", orphans)
+	}
+
+	allMetrics := metricsManager.GetAllKeyMetrics()
+	for _, apiKey := range []string{"sk-current", "sk-historical"} {
+		if !hasMetricsKey(allMetrics, metrics.GenerateMetricsKey("https://example.com", apiKey)) {
+			t.Errorf("配置中的 Key %s 的指标不应被清理", apiKey)
+		}
+	}
+}
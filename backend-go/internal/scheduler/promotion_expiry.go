@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+)
+
+// StartPromotionExpiryCleanup 启动促销期到期清理后台任务（interval <= 0 时不启动）
+// IsChannelInPromotion 仅在调度时惰性判断到期，已到期的 promotionUntil 会一直留在配置中，
+// 定期清除可保证管理界面展示的促销状态准确，通过 Stop 停止
+func (s *ChannelScheduler) StartPromotionExpiryCleanup(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+				s.CleanupExpiredPromotions()
+			}
+		}
+	}()
+
+	log.Printf("[Scheduler-Promotion] 促销期到期清理已启动 (周期: %v)", interval)
+}
+
+// CleanupExpiredPromotions 清除所有已到期的渠道促销期，返回清除的渠道数量
+func (s *ChannelScheduler) CleanupExpiredPromotions() int {
+	expired, err := s.configManager.ClearExpiredPromotions(time.Now())
+	if err != nil {
+		log.Printf("[Scheduler-Promotion] 警告: 清除到期促销期失败: %v", err)
+		return 0
+	}
+
+	for _, p := range expired {
+		log.Printf("[Scheduler-Promotion] %s 渠道 [%d] %s 促销期已结束 (截止: %s)，恢复正常调度",
+			p.Kind, p.ChannelIndex, p.ChannelName, p.Until.Format(time.RFC3339))
+	}
+	return len(expired)
+}
@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+)
+
+func TestStartPromotionExpiryCleanup(t *testing.T) {
+	s, cleanup := createTestScheduler(t, config.Config{
+		Upstream: []config.UpstreamConfig{
+			{Name: "short", BaseURL: "https://short.example.com", APIKeys: []string{"sk-1"}, Status: "active"},
+		},
+		ResponsesUpstream: []config.UpstreamConfig{
+			{Name: "long", BaseURL: "https://long.example.com", APIKeys: []string{"sk-2"}, Status: "active"},
+		},
+	})
+	defer cleanup()
+	defer s.Stop()
+
+	if err := s.configManager.SetChannelPromotion(0, 50*time.Millisecond); err != nil {
+		t.Fatalf("SetChannelPromotion() err = %v", err)
+	}
+	if err := s.configManager.SetResponsesChannelPromotion(0, time.Hour); err != nil {
+		t.Fatalf("SetResponsesChannelPromotion() err = %v", err)
+	}
+
+	s.StartPromotionExpiryCleanup(20 * time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.configManager.GetConfig().Upstream[0].PromotionUntil != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("到期的促销期应被后台任务清除")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if s.configManager.GetConfig().ResponsesUpstream[0].PromotionUntil == nil {
+		t.Fatal("未到期的促销期不应被清除")
+	}
+	if n := s.CleanupExpiredPromotions(); n != 0 {
+		t.Fatalf("CleanupExpiredPromotions() = %d, want 0（已无到期促销）", n)
+	}
+}
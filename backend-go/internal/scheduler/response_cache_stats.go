@@ -0,0 +1,18 @@
+package scheduler
+
+// RecordResponseCacheHit 记录一次响应缓存命中（请求由缓存直接返回，未调用上游）
+func (s *ChannelScheduler) RecordResponseCacheHit(kind ChannelKind) {
+	s.responseCacheMu.Lock()
+	defer s.responseCacheMu.Unlock()
+	if s.responseCacheHits == nil {
+		s.responseCacheHits = make(map[ChannelKind]int64)
+	}
+	s.responseCacheHits[kind]++
+}
+
+// GetResponseCacheHits 获取指定类型的响应缓存累计命中次数
+func (s *ChannelScheduler) GetResponseCacheHits(kind ChannelKind) int64 {
+	s.responseCacheMu.Lock()
+	defer s.responseCacheMu.Unlock()
+	return s.responseCacheHits[kind]
+}
@@ -0,0 +1,89 @@
+package scheduler
+
+// recordChannelSelection 记录一次渠道被调度选中（与请求最终是否成功无关）
+func (s *ChannelScheduler) recordChannelSelection(kind ChannelKind, channelIndex int) {
+	s.selectionMu.Lock()
+	defer s.selectionMu.Unlock()
+	if s.selectionCounts == nil {
+		s.selectionCounts = make(map[ChannelKind]map[int]int64)
+	}
+	if s.selectionCounts[kind] == nil {
+		s.selectionCounts[kind] = make(map[int]int64)
+	}
+	s.selectionCounts[kind][channelIndex]++
+}
+
+// GetChannelSelectionCount 获取渠道被调度选中的累计次数
+// 与成功数对比可发现"经常被选中但失败"的渠道（调度与实际可用性不匹配）
+func (s *ChannelScheduler) GetChannelSelectionCount(kind ChannelKind, channelIndex int) int64 {
+	s.selectionMu.Lock()
+	defer s.selectionMu.Unlock()
+	return s.selectionCounts[kind][channelIndex]
+}
+
+// GetChannelSelectionCounts 获取指定类型所有渠道被调度选中的累计次数（key: 渠道索引）
+func (s *ChannelScheduler) GetChannelSelectionCounts(kind ChannelKind) map[int]int64 {
+	s.selectionMu.Lock()
+	defer s.selectionMu.Unlock()
+
+	counts := make(map[int]int64, len(s.selectionCounts[kind]))
+	for channelIndex, count := range s.selectionCounts[kind] {
+		counts[channelIndex] = count
+	}
+	return counts
+}
+
+// ResetChannelSelectionCounts 清空指定类型的渠道选中计数（删除渠道导致索引变化时调用）
+func (s *ChannelScheduler) ResetChannelSelectionCounts(kind ChannelKind) {
+	s.selectionMu.Lock()
+	defer s.selectionMu.Unlock()
+	delete(s.selectionCounts, kind)
+}
+
+// 选择原因（SelectionResult.Reason）取值中用于调度质量统计的几类
+const (
+	selectionReasonFallback       = "fallback"
+	selectionReasonTraceAffinity  = "trace_affinity"
+	selectionReasonStickyAffinity = "sticky_affinity"
+	selectionReasonPromotion      = "promotion_priority"
+	selectionReasonCanary         = "canary"
+)
+
+// SelectionReasonStats 调度质量统计：降级选择频繁说明健康渠道经常全部不可用
+type SelectionReasonStats struct {
+	Fallback  int64            `json:"fallback"`  // 所有健康渠道都不可用时的降级选择次数
+	Affinity  int64            `json:"affinity"`  // Trace 亲和命中次数（含 sticky 模式）
+	Promotion int64            `json:"promotion"` // 促销期渠道被选中次数
+	ByReason  map[string]int64 `json:"byReason"`  // 按选择原因的完整计数
+}
+
+// recordSelectionReason 记录一次按指定原因的渠道选择
+func (s *ChannelScheduler) recordSelectionReason(kind ChannelKind, reason string) {
+	s.selectionMu.Lock()
+	defer s.selectionMu.Unlock()
+	if s.selectionReasons == nil {
+		s.selectionReasons = make(map[ChannelKind]map[string]int64)
+	}
+	if s.selectionReasons[kind] == nil {
+		s.selectionReasons[kind] = make(map[string]int64)
+	}
+	s.selectionReasons[kind][reason]++
+}
+
+// GetSelectionReasonStats 获取指定类型的选择原因统计（降级、亲和命中、促销选择次数）
+func (s *ChannelScheduler) GetSelectionReasonStats(kind ChannelKind) SelectionReasonStats {
+	s.selectionMu.Lock()
+	defer s.selectionMu.Unlock()
+
+	reasons := s.selectionReasons[kind]
+	stats := SelectionReasonStats{
+		Fallback:  reasons[selectionReasonFallback],
+		Affinity:  reasons[selectionReasonTraceAffinity] + reasons[selectionReasonStickyAffinity],
+		Promotion: reasons[selectionReasonPromotion],
+		ByReason:  make(map[string]int64, len(reasons)),
+	}
+	for reason, count := range reasons {
+		stats.ByReason[reason] = count
+	}
+	return stats
+}
@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/BenedictKing/ccx/internal/config"
+)
+
+// TestChannelSelectionCount_IndependentOfSuccess 选中计数在每次 SelectChannel 时递增，与请求成功与否无关
+func TestChannelSelectionCount_IndependentOfSuccess(t *testing.T) {
+	cfg := config.Config{
+		ChatUpstream: []config.UpstreamConfig{
+			{Name: "primary", BaseURL: "https://primary.example.com", APIKeys: []string{"sk-primary"}, Status: "active", Priority: 1},
+			{Name: "secondary", BaseURL: "https://secondary.example.com", APIKeys: []string{"sk-secondary"}, Status: "active", Priority: 2},
+		},
+	}
+
+	scheduler, cleanup := createTestScheduler(t, cfg)
+	defer cleanup()
+
+	// 两次选中 primary：一次成功、一次失败
+	for range 2 {
+		result, err := scheduler.SelectChannel(context.Background(), "", nil, ChannelKindChat, "")
+		if err != nil || result.ChannelIndex != 0 {
+			t.Fatalf("SelectChannel() = %+v, %v, want channel 0", result, err)
+		}
+	}
+	scheduler.chatMetricsManager.RecordSuccess("https://primary.example.com", "sk-primary")
+	scheduler.chatMetricsManager.RecordFailure("https://primary.example.com", "sk-primary")
+
+	// primary 本次请求已失败，转而选中 secondary（未记录任何结果）
+	if result, err := scheduler.SelectChannel(context.Background(), "", map[int]bool{0: true}, ChannelKindChat, ""); err != nil || result.ChannelIndex != 1 {
+		t.Fatalf("SelectChannel() = %+v, %v, want channel 1", result, err)
+	}
+
+	if got := scheduler.GetChannelSelectionCount(ChannelKindChat, 0); got != 2 {
+		t.Errorf("primary selectionCount = %d, want 2", got)
+	}
+	if got := scheduler.GetChannelSelectionCount(ChannelKindChat, 1); got != 1 {
+		t.Errorf("secondary selectionCount = %d, want 1", got)
+	}
+	if got := scheduler.GetChannelSelectionCount(ChannelKindMessages, 0); got != 0 {
+		t.Errorf("messages selectionCount = %d, want 0（按类型独立计数）", got)
+	}
+
+	counts := scheduler.GetChannelSelectionCounts(ChannelKindChat)
+	if counts[0] != 2 || counts[1] != 1 {
+		t.Errorf("GetChannelSelectionCounts() = %v, want map[0:2 1:1]", counts)
+	}
+
+	scheduler.ResetChannelSelectionCounts(ChannelKindChat)
+	if got := scheduler.GetChannelSelectionCount(ChannelKindChat, 0); got != 0 {
+		t.Errorf("重置后 selectionCount = %d, want 0", got)
+	}
+}
+
+// TestSelectionReasonStats_CountsFallback 所有渠道都不健康时的降级选择按类型计数，并与亲和命中分开统计
+func TestSelectionReasonStats_CountsFallback(t *testing.T) {
+	cfg := config.Config{
+		Upstream: []config.UpstreamConfig{
+			{Name: "primary", BaseURL: "https://primary.example.com", APIKeys: []string{"sk-primary"}, Status: "active", Priority: 1},
+			{Name: "secondary", BaseURL: "https://secondary.example.com", APIKeys: []string{"sk-secondary"}, Status: "active", Priority: 2},
+		},
+	}
+
+	scheduler, cleanup := createTestScheduler(t, cfg)
+	defer cleanup()
+
+	// 健康时按优先级选择，不计入降级
+	if result, err := scheduler.SelectChannel(context.Background(), "", nil, ChannelKindMessages, ""); err != nil || result.Reason != "priority_order" {
+		t.Fatalf("SelectChannel() = %+v, %v, want priority_order", result, err)
+	}
+
+	// Trace 亲和命中
+	scheduler.SetTraceAffinity("user-1", 1, ChannelKindMessages)
+	if result, err := scheduler.SelectChannel(context.Background(), "user-1", nil, ChannelKindMessages, ""); err != nil || result.Reason != selectionReasonTraceAffinity {
+		t.Fatalf("SelectChannel() = %+v, %v, want trace_affinity", result, err)
+	}
+
+	// 两个渠道都熔断：只能降级选择
+	for range 10 {
+		scheduler.messagesMetricsManager.RecordFailure("https://primary.example.com", "sk-primary")
+		scheduler.messagesMetricsManager.RecordFailure("https://secondary.example.com", "sk-secondary")
+	}
+	for range 3 {
+		result, err := scheduler.SelectChannel(context.Background(), "", nil, ChannelKindMessages, "")
+		if err != nil || result.Reason != selectionReasonFallback {
+			t.Fatalf("SelectChannel() = %+v, %v, want fallback", result, err)
+		}
+	}
+
+	stats := scheduler.GetSelectionReasonStats(ChannelKindMessages)
+	if stats.Fallback != 3 {
+		t.Errorf("Fallback = %d, want 3", stats.Fallback)
+	}
+	if stats.Affinity != 1 {
+		t.Errorf("Affinity = %d, want 1", stats.Affinity)
+	}
+	if stats.Promotion != 0 {
+		t.Errorf("Promotion = %d, want 0", stats.Promotion)
+	}
+	if stats.ByReason["priority_order"] != 1 {
+		t.Errorf("ByReason = %v, want priority_order=1", stats.ByReason)
+	}
+	if got := scheduler.GetSelectionReasonStats(ChannelKindChat).Fallback; got != 0 {
+		t.Errorf("chat Fallback = %d, want 0（按类型独立计数）", got)
+	}
+}
@@ -0,0 +1,62 @@
+package scheduler
+
+import "log"
+
+// StartShadowReplayWorkers 启动影子请求重放工作池（concurrency <= 0 时不启动，重放退化为每次请求单独起 goroutine）
+// 固定数量的 worker 从有界队列中取任务执行，队列满时丢弃最旧的任务，避免镜像流量挤占主请求资源。
+// 需在开始处理请求前调用，通过 Stop 停止
+func (s *ChannelScheduler) StartShadowReplayWorkers(concurrency, queueSize int) {
+	if concurrency <= 0 {
+		return
+	}
+	if queueSize <= 0 {
+		queueSize = concurrency
+	}
+
+	s.shadowTasks = make(chan func(), queueSize)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			for {
+				select {
+				case <-s.stopCh:
+					return
+				case task := <-s.shadowTasks:
+					task()
+				}
+			}
+		}()
+	}
+
+	log.Printf("[Scheduler-Shadow] 影子重放工作池已启动 (并发: %d, 队列: %d)", concurrency, queueSize)
+}
+
+// SubmitShadowReplay 提交影子重放任务，不会阻塞调用方
+// 队列已满时丢弃最旧的任务并计入丢弃数；工作池未启动时直接在新 goroutine 中执行
+func (s *ChannelScheduler) SubmitShadowReplay(task func()) {
+	if s.shadowTasks == nil {
+		go task()
+		return
+	}
+
+	for {
+		select {
+		case s.shadowTasks <- task:
+			return
+		default:
+		}
+
+		// 队列已满：丢弃最旧的任务后重试（worker 可能同时取走任务，因此循环直到入队成功）
+		select {
+		case <-s.shadowTasks:
+			if dropped := s.shadowDropped.Add(1); dropped == 1 || dropped%100 == 0 {
+				log.Printf("[Scheduler-Shadow] 警告: 影子重放队列已满，丢弃最旧的任务 (累计丢弃: %d)", dropped)
+			}
+		default:
+		}
+	}
+}
+
+// GetShadowReplayStats 获取影子重放队列中等待的任务数与累计丢弃数
+func (s *ChannelScheduler) GetShadowReplayStats() (queued int, dropped int64) {
+	return len(s.shadowTasks), s.shadowDropped.Load()
+}
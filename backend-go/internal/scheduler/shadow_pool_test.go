@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+)
+
+// TestShadowReplayPool_DropsOldestWhenFull 队列满时丢弃最旧的任务并计数，提交方不阻塞
+func TestShadowReplayPool_DropsOldestWhenFull(t *testing.T) {
+	s, cleanup := createTestScheduler(t, config.Config{})
+	defer cleanup()
+	defer s.Stop()
+
+	const queueSize = 2
+	s.StartShadowReplayWorkers(1, queueSize)
+
+	// 唯一的 worker 被阻塞任务占用
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s.SubmitShadowReplay(func() {
+		close(started)
+		<-release
+	})
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("worker 未开始执行任务")
+	}
+
+	var mu sync.Mutex
+	var executed []int
+	var wg sync.WaitGroup
+	wg.Add(queueSize)
+
+	const flood = 20
+	submitted := make(chan struct{})
+	go func() {
+		for i := 0; i < flood; i++ {
+			i := i
+			s.SubmitShadowReplay(func() {
+				mu.Lock()
+				executed = append(executed, i)
+				mu.Unlock()
+				wg.Done()
+			})
+		}
+		close(submitted)
+	}()
+	select {
+	case <-submitted:
+	case <-time.After(time.Second):
+		t.Fatal("队列满时提交不应阻塞")
+	}
+
+	queued, dropped := s.GetShadowReplayStats()
+	if queued != queueSize || dropped != flood-queueSize {
+		t.Fatalf("queued=%d dropped=%d, want %d/%d", queued, dropped, queueSize, flood-queueSize)
+	}
+
+	close(release)
+	wg.Wait()
+
+	// 保留的应是最新提交的任务
+	mu.Lock()
+	defer mu.Unlock()
+	if len(executed) != queueSize || executed[0] != flood-2 || executed[1] != flood-1 {
+		t.Fatalf("executed=%v, want [%d %d]", executed, flood-2, flood-1)
+	}
+}
+
+// TestShadowReplayPool_NotStarted 未启动工作池时任务直接在新 goroutine 中执行
+func TestShadowReplayPool_NotStarted(t *testing.T) {
+	s, cleanup := createTestScheduler(t, config.Config{})
+	defer cleanup()
+
+	done := make(chan struct{})
+	s.SubmitShadowReplay(func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("未启动工作池时任务应直接执行")
+	}
+	if _, dropped := s.GetShadowReplayStats(); dropped != 0 {
+		t.Fatalf("dropped=%d, want 0", dropped)
+	}
+}
@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/metrics"
+)
+
+// TopKeyUsage 按 token 用量排名的 Key（附带所属渠道，便于定位成本来源）
+type TopKeyUsage struct {
+	metrics.KeyTokenUsage
+	ChannelIndex int    `json:"channelIndex"` // -1 表示已不在当前配置中（如轮换后遗留的 Key）
+	ChannelName  string `json:"channelName,omitempty"`
+}
+
+// GetTopKeysByTokens 返回指定类型在时间窗口内 token 总量（input + output + 缓存）最高的 Key
+// 与按最近使用排序的 SelectTopKeys 互补；limit <= 0 时返回全部
+func (s *ChannelScheduler) GetTopKeysByTokens(kind ChannelKind, duration time.Duration, limit int) []TopKeyUsage {
+	type channelRef struct {
+		index int
+		name  string
+	}
+	owners := make(map[string]channelRef)
+	for index, upstream := range s.GetUpstreams(kind) {
+		allKeys := append([]string{}, upstream.APIKeys...)
+		allKeys = append(allKeys, upstream.HistoricalAPIKeys...)
+		for _, baseURL := range upstream.GetAllBaseURLs() {
+			for _, apiKey := range allKeys {
+				owners[metrics.GenerateMetricsKey(baseURL, apiKey)] = channelRef{index: index, name: upstream.Name}
+			}
+		}
+	}
+
+	usages := s.getMetricsManager(kind).GetKeyTokenUsage(duration)
+	result := make([]TopKeyUsage, 0, len(usages))
+	for _, usage := range usages {
+		item := TopKeyUsage{KeyTokenUsage: usage, ChannelIndex: -1}
+		if ref, ok := owners[usage.MetricsKey]; ok {
+			item.ChannelIndex = ref.index
+			item.ChannelName = ref.name
+		}
+		result = append(result, item)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalTokens != result[j].TotalTokens {
+			return result[i].TotalTokens > result[j].TotalTokens
+		}
+		return result[i].MetricsKey < result[j].MetricsKey
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/ccx/internal/config"
+	"github.com/BenedictKing/ccx/internal/types"
+)
+
+// TestGetTopKeysByTokens 按 token 总量（含缓存）降序排名，并按 limit 截断
+func TestGetTopKeysByTokens(t *testing.T) {
+	cfg := config.Config{
+		Upstream: []config.UpstreamConfig{
+			{Name: "alpha", BaseURL: "https://alpha.example.com", APIKeys: []string{"sk-alpha-1", "sk-alpha-2"}},
+			{Name: "beta", BaseURL: "https://beta.example.com", APIKeys: []string{"sk-beta-1"}},
+		},
+	}
+	s, cleanup := createTestScheduler(t, cfg)
+	defer cleanup()
+
+	mm := s.GetMetricsManagerByKind(ChannelKindMessages)
+	// alpha-1：请求多但 token 少
+	for range 5 {
+		mm.RecordSuccessWithUsage("https://alpha.example.com", "sk-alpha-1", &types.Usage{InputTokens: 10, OutputTokens: 10})
+	}
+	// alpha-2：缓存读取占大头
+	mm.RecordSuccessWithUsage("https://alpha.example.com", "sk-alpha-2", &types.Usage{InputTokens: 100, OutputTokens: 50, CacheReadInputTokens: 5000})
+	// beta-1：输出量大
+	mm.RecordSuccessWithUsage("https://beta.example.com", "sk-beta-1", &types.Usage{InputTokens: 200, OutputTokens: 800})
+	// 已不在配置中的 Key 仍参与排名
+	mm.RecordSuccessWithUsage("https://old.example.com", "sk-old", &types.Usage{InputTokens: 1, OutputTokens: 1})
+
+	all := s.GetTopKeysByTokens(ChannelKindMessages, time.Hour, 0)
+	want := []struct {
+		channel string
+		index   int
+		total   int64
+	}{
+		{channel: "alpha", index: 0, total: 5150},
+		{channel: "beta", index: 1, total: 1000},
+		{channel: "alpha", index: 0, total: 100},
+		{channel: "", index: -1, total: 2},
+	}
+	if len(all) != len(want) {
+		t.Fatalf("len=%d, want %d", len(all), len(want))
+	}
+	for i, w := range want {
+		if all[i].ChannelName != w.channel || all[i].ChannelIndex != w.index || all[i].TotalTokens != w.total {
+			t.Fatalf("[%d] = {%q %d %d}, want {%q %d %d}", i, all[i].ChannelName, all[i].ChannelIndex, all[i].TotalTokens, w.channel, w.index, w.total)
+		}
+		if all[i].KeyMask == "" {
+			t.Fatalf("[%d] KeyMask 为空", i)
+		}
+	}
+	if all[2].RequestCount != 5 {
+		t.Fatalf("alpha-1 requestCount=%d, want 5", all[2].RequestCount)
+	}
+
+	top := s.GetTopKeysByTokens(ChannelKindMessages, time.Hour, 2)
+	if len(top) != 2 || top[0].TotalTokens != 5150 || top[1].TotalTokens != 1000 {
+		t.Fatalf("limit=2 结果不符: %+v", top)
+	}
+
+	if got := s.GetTopKeysByTokens(ChannelKindResponses, time.Hour, 10); len(got) != 0 {
+		t.Fatalf("responses 无用量时应返回空列表，got %d", len(got))
+	}
+}
@@ -1,8 +1,10 @@
 package session
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,6 +17,7 @@
 type TraceAffinity struct {
 	ChannelIndex int
 	LastUsedAt   time.Time
+	Failed       bool // 该会话在亲和渠道上发生过 failover 错误（sticky 模式下据此重新选择渠道）
 }
 
 // TraceAffinityManager 管理 trace 与渠道的亲和性
@@ -22,7 +25,8 @@ type TraceAffinityManager struct {
 	mu       sync.RWMutex
 	affinity map[string]*TraceAffinity // key: user_id
 	ttl      time.Duration
-	stopCh   chan struct{} // 用于停止清理 goroutine
+	kindTTL  map[string]time.Duration // 按 kind 覆盖的 TTL（key 为复合键中的 kind 前缀）
+	stopCh   chan struct{}            // 用于停止清理 goroutine
 }
 
 // NewTraceAffinityManager 创建 Trace 亲和性管理器
@@ -30,6 +34,7 @@ func NewTraceAffinityManager() *TraceAffinityManager {
 	mgr := &TraceAffinityManager{
 		affinity: make(map[string]*TraceAffinity),
 		ttl:      30 * time.Minute, // 默认 30 分钟无活动后过期
+		kindTTL:  make(map[string]time.Duration),
 		stopCh:   make(chan struct{}),
 	}
 
@@ -48,6 +53,7 @@ func NewTraceAffinityManagerWithTTL(ttl time.Duration) *TraceAffinityManager {
 	mgr := &TraceAffinityManager{
 		affinity: make(map[string]*TraceAffinity),
 		ttl:      ttl,
+		kindTTL:  make(map[string]time.Duration),
 		stopCh:   make(chan struct{}),
 	}
 
@@ -72,13 +78,55 @@ func (m *TraceAffinityManager) GetPreferredChannel(userID string) (int, bool) {
 	}
 
 	// 检查是否过期
-	if time.Since(affinity.LastUsedAt) > m.ttl {
+	if time.Since(affinity.LastUsedAt) > m.ttlForKeyLocked(userID) {
 		return -1, false
 	}
 
 	return affinity.ChannelIndex, true
 }
 
+// GetStickyChannel 获取 sticky 模式下 user_id 固定的渠道
+// 亲和渠道已在该会话中失败时返回 false，由调用方重新选择并重新固定
+func (m *TraceAffinityManager) GetStickyChannel(userID string) (int, bool) {
+	if userID == "" {
+		return -1, false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	affinity, exists := m.affinity[userID]
+	if !exists || affinity.Failed {
+		return -1, false
+	}
+
+	if time.Since(affinity.LastUsedAt) > m.ttlForKeyLocked(userID) {
+		return -1, false
+	}
+
+	return affinity.ChannelIndex, true
+}
+
+// MarkFailed 标记 user_id 在亲和渠道上失败
+// 仅当失败渠道与当前亲和渠道一致时生效，下次成功时 SetPreferredChannel 会重新固定并清除标记
+func (m *TraceAffinityManager) MarkFailed(userID string, channelIndex int) {
+	if userID == "" {
+		return
+	}
+
+	marked := false
+	m.mu.Lock()
+	if affinity, exists := m.affinity[userID]; exists && affinity.ChannelIndex == channelIndex && !affinity.Failed {
+		affinity.Failed = true
+		marked = true
+	}
+	m.mu.Unlock()
+
+	if affinityDebug && marked {
+		log.Printf("[Affinity-Failed] 用户亲和渠道失败: %s -> 渠道[%d]，下次请求将重新选择", maskUserID(userID), channelIndex)
+	}
+}
+
 // SetPreferredChannel 设置 user_id 偏好的渠道
 func (m *TraceAffinityManager) SetPreferredChannel(userID string, channelIndex int) {
 	if userID == "" {
@@ -165,16 +213,15 @@ func (m *TraceAffinityManager) Cleanup() int {
 	now := time.Now()
 	cleaned := 0
 	for userID, affinity := range m.affinity {
-		if now.Sub(affinity.LastUsedAt) > m.ttl {
+		if now.Sub(affinity.LastUsedAt) > m.ttlForKeyLocked(userID) {
 			delete(m.affinity, userID)
 			cleaned++
 		}
 	}
-	ttl := m.ttl
 	m.mu.Unlock()
 
 	if affinityDebug && cleaned > 0 {
-		log.Printf("[Affinity-Cleanup] 清理了 %d 条过期亲和记录 (TTL: %v)", cleaned, ttl)
+		log.Printf("[Affinity-Cleanup] 清理了 %d 条过期亲和记录", cleaned)
 	}
 
 	return cleaned
@@ -212,6 +259,66 @@ func (m *TraceAffinityManager) GetTTL() time.Duration {
 	return m.ttl
 }
 
+// SetKindTTL 设置指定 kind 的 TTL（如 messages 对话需要更长的粘性）
+// ttl <= 0 时移除覆盖，恢复使用默认 TTL
+func (m *TraceAffinityManager) SetKindTTL(kind string, ttl time.Duration) {
+	m.mu.Lock()
+	if ttl <= 0 {
+		delete(m.kindTTL, kind)
+	} else {
+		m.kindTTL[kind] = ttl
+	}
+	m.mu.Unlock()
+
+	if affinityDebug {
+		log.Printf("[Affinity-TTL] %s TTL 已设置为 %v", kind, m.GetKindTTL(kind))
+	}
+}
+
+// GetKindTTL 获取指定 kind 生效的 TTL（未覆盖时返回默认 TTL）
+func (m *TraceAffinityManager) GetKindTTL(kind string) time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if ttl, ok := m.kindTTL[kind]; ok {
+		return ttl
+	}
+	return m.ttl
+}
+
+// ttlForKeyLocked 按复合键（kind:user_id）中的 kind 前缀返回生效的 TTL，调用方需持有锁
+func (m *TraceAffinityManager) ttlForKeyLocked(key string) time.Duration {
+	if len(m.kindTTL) > 0 {
+		if kind, _, ok := strings.Cut(key, ":"); ok {
+			if ttl, exists := m.kindTTL[kind]; exists {
+				return ttl
+			}
+		}
+	}
+	return m.ttl
+}
+
+// ParseKindTTLs 解析按 kind 配置的 TTL，格式: "messages=2h,chat=10m"
+func ParseKindTTLs(spec string) (map[string]time.Duration, error) {
+	result := make(map[string]time.Duration)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kind, value, ok := strings.Cut(item, "=")
+		kind = strings.TrimSpace(kind)
+		if !ok || kind == "" {
+			return nil, fmt.Errorf("无效的 TTL 配置项: %q", item)
+		}
+		ttl, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("无效的 TTL 配置项: %q", item)
+		}
+		result[kind] = ttl
+	}
+	return result, nil
+}
+
 // GetAll 获取所有亲和记录（用于调试）
 func (m *TraceAffinityManager) GetAll() map[string]TraceAffinity {
 	m.mu.RLock()
@@ -0,0 +1,65 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTraceAffinityManager_KindTTL(t *testing.T) {
+	m := NewTraceAffinityManagerWithTTL(10 * time.Minute)
+	defer m.Stop()
+	m.SetKindTTL("messages", time.Hour)
+
+	m.SetPreferredChannel("messages:user-1", 1)
+	m.SetPreferredChannel("chat:user-1", 2)
+
+	// 模拟 20 分钟无活动：超过默认 TTL，但未超过 messages 的 TTL
+	m.mu.Lock()
+	for _, affinity := range m.affinity {
+		affinity.LastUsedAt = time.Now().Add(-20 * time.Minute)
+	}
+	m.mu.Unlock()
+
+	if idx, ok := m.GetPreferredChannel("messages:user-1"); !ok || idx != 1 {
+		t.Fatalf("messages 亲和应在 1h TTL 内保持, got idx=%d ok=%v", idx, ok)
+	}
+	if _, ok := m.GetStickyChannel("chat:user-1"); ok {
+		t.Fatal("chat 亲和应按默认 10m TTL 过期")
+	}
+
+	if cleaned := m.Cleanup(); cleaned != 1 {
+		t.Fatalf("Cleanup() = %d, want 1", cleaned)
+	}
+	if _, ok := m.GetAll()["messages:user-1"]; !ok {
+		t.Fatal("Cleanup 不应清理 messages 亲和记录")
+	}
+
+	if got := m.GetKindTTL("messages"); got != time.Hour {
+		t.Fatalf("GetKindTTL(messages) = %v, want 1h", got)
+	}
+	if got := m.GetKindTTL("chat"); got != 10*time.Minute {
+		t.Fatalf("GetKindTTL(chat) = %v, want 10m", got)
+	}
+
+	// 移除覆盖后恢复默认 TTL
+	m.SetKindTTL("messages", 0)
+	if _, ok := m.GetPreferredChannel("messages:user-1"); ok {
+		t.Fatal("移除覆盖后 messages 亲和应按默认 TTL 过期")
+	}
+}
+
+func TestParseKindTTLs(t *testing.T) {
+	got, err := ParseKindTTLs(" messages=2h, chat=10m ,")
+	if err != nil {
+		t.Fatalf("ParseKindTTLs() err = %v", err)
+	}
+	if len(got) != 2 || got["messages"] != 2*time.Hour || got["chat"] != 10*time.Minute {
+		t.Fatalf("ParseKindTTLs() = %v", got)
+	}
+
+	for _, spec := range []string{"messages", "=1h", "chat=abc", "chat=-1m"} {
+		if _, err := ParseKindTTLs(spec); err == nil {
+			t.Fatalf("ParseKindTTLs(%q) 应返回错误", spec)
+		}
+	}
+}
@@ -23,6 +23,7 @@ type GeminiRequest struct {
 	Contents          []GeminiContent         `json:"contents"`
 	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
 	Tools             []GeminiTool            `json:"tools,omitempty"`
+	ToolConfig        *GeminiToolConfig       `json:"toolConfig,omitempty"`
 	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
 	SafetySettings    []GeminiSafetySetting   `json:"safetySettings,omitempty"`
 }
@@ -130,6 +131,17 @@ type GeminiTool struct {
 	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations,omitempty"`
 }
 
+// GeminiToolConfig 工具调用配置
+type GeminiToolConfig struct {
+	FunctionCallingConfig *GeminiFunctionCallingConfig `json:"functionCallingConfig,omitempty"`
+}
+
+// GeminiFunctionCallingConfig 函数调用模式
+type GeminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode,omitempty"`                 // AUTO（默认）、ANY（必须调用函数）、NONE（禁止调用函数）
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"` // 仅 mode 为 ANY 时生效，限制可调用的函数
+}
+
 // GeminiFunctionDeclaration 函数声明
 type GeminiFunctionDeclaration struct {
 	Name        string      `json:"name"`
@@ -123,9 +123,13 @@ type Usage struct {
 	CacheCreation5mInputTokens int    `json:"cache_creation_5m_input_tokens,omitempty"` // 5分钟 TTL
 	CacheCreation1hInputTokens int    `json:"cache_creation_1h_input_tokens,omitempty"` // 1小时 TTL
 	CacheTTL                   string `json:"cache_ttl,omitempty"`                      // "5m" | "1h" | "mixed"
+	// 扩展思考（extended thinking）tokens，上游单独返回时记录
+	ThinkingTokens int `json:"thinking_tokens,omitempty"`
 	// OpenAI 兼容字段
 	PromptTokens     int `json:"prompt_tokens,omitempty"`
 	CompletionTokens int `json:"completion_tokens,omitempty"`
+	// Estimated 上游未返回 usage，由请求体与输出文本估算得出（不序列化）
+	Estimated bool `json:"-"`
 }
 
 // ProviderRequest 提供商请求（通用）
@@ -1,10 +1,13 @@
 package utils
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // PrepareUpstreamHeaders 准备上游请求头（统一头部处理逻辑）
@@ -32,6 +35,44 @@ func PrepareUpstreamHeaders(c *gin.Context, targetHost string) http.Header {
 	return headers
 }
 
+// alwaysForwardedHeaders 仅转发白名单模式下仍保留的请求头（请求本身必需）
+var alwaysForwardedHeaders = []string{"host", "content-type"}
+
+// FilterForwardedHeaders 按渠道的请求头转发策略过滤客户端请求头（在 PrepareUpstreamHeaders 之后、设置认证与自定义头之前调用）
+// - stripHeaders：移除命中的请求头，优先于白名单
+// - forwardHeaderAllowlist：非空时仅保留命中的请求头（Host、Content-Type 始终保留）
+// 两个列表都为空时保持透传；名称不区分大小写，以 * 结尾表示前缀匹配
+func FilterForwardedHeaders(headers http.Header, allowlist, strip []string) {
+	if len(allowlist) == 0 && len(strip) == 0 {
+		return
+	}
+	for key := range headers {
+		lowerKey := strings.ToLower(key)
+		if matchHeaderPattern(strip, lowerKey) {
+			headers.Del(key)
+			continue
+		}
+		if len(allowlist) > 0 && !matchHeaderPattern(alwaysForwardedHeaders, lowerKey) && !matchHeaderPattern(allowlist, lowerKey) {
+			headers.Del(key)
+		}
+	}
+}
+
+// matchHeaderPattern 判断小写的请求头名是否命中任一规则（规则不区分大小写，以 * 结尾表示前缀匹配）
+func matchHeaderPattern(patterns []string, lowerKey string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(lowerKey, prefix) {
+				return true
+			}
+		} else if lowerKey == pattern {
+			return true
+		}
+	}
+	return false
+}
+
 // PrepareMinimalHeaders 准备最小化请求头（适用于非Claude渠道如OpenAI、Gemini等）
 // 只保留必要的头部：Content-Type和Host，不包含任何Anthropic特定头部
 // 注意：不设置Accept-Encoding，让Go的http.Client自动处理gzip压缩
@@ -71,17 +112,96 @@ func SetGeminiAuthenticationHeader(headers http.Header, apiKey string) {
 	headers.Set("x-goog-api-key", apiKey)
 }
 
+// SetAzureAuthenticationHeader 设置 Azure OpenAI 认证头部（api-key）
+func SetAzureAuthenticationHeader(headers http.Header, apiKey string) {
+	headers.Del("authorization")
+	headers.Del("x-api-key")
+	headers.Del("x-goog-api-key")
+	headers.Set("api-key", apiKey)
+}
+
+// HeaderTemplateVars 自定义请求头模板变量（在构建上游请求时解析）
+type HeaderTemplateVars struct {
+	Model  string // 重定向后的实际模型
+	APIKey string // 本次使用的 API Key（仅以脱敏形式输出）
+}
+
+// headerTemplateVarNames 支持的模板变量：{{model}}、{{uuid}}、{{keyMask}}
+var headerTemplateVarNames = map[string]bool{
+	"model":   true,
+	"uuid":    true,
+	"keyMask": true,
+}
+
+// ValidateHeaderTemplate 校验自定义请求头值中的模板变量（不含 {{ 的值视为字面量）
+func ValidateHeaderTemplate(value string) error {
+	rest := value
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			return nil
+		}
+		end := strings.Index(rest[start:], "}}")
+		if end < 0 {
+			return fmt.Errorf("模板变量未闭合: %q", value)
+		}
+		name := strings.TrimSpace(rest[start+2 : start+end])
+		if !headerTemplateVarNames[name] {
+			return fmt.Errorf("未知的模板变量 {{%s}}（可选: model, uuid, keyMask）", name)
+		}
+		rest = rest[start+end+2:]
+	}
+}
+
+// renderHeaderTemplate 解析请求头值中的模板变量，同一请求内的 {{uuid}} 保持一致
+func renderHeaderTemplate(value string, vars HeaderTemplateVars, requestUUID *string) string {
+	if !strings.Contains(value, "{{") {
+		return value
+	}
+	var b strings.Builder
+	rest := value
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			b.WriteString(rest)
+			return b.String()
+		}
+		end := strings.Index(rest[start:], "}}")
+		if end < 0 {
+			b.WriteString(rest)
+			return b.String()
+		}
+		b.WriteString(rest[:start])
+		switch strings.TrimSpace(rest[start+2 : start+end]) {
+		case "model":
+			b.WriteString(vars.Model)
+		case "uuid":
+			if *requestUUID == "" {
+				*requestUUID = uuid.NewString()
+			}
+			b.WriteString(*requestUUID)
+		case "keyMask":
+			b.WriteString(MaskAPIKey(vars.APIKey))
+		default:
+			// 未知变量已在配置加载时拦截，此处原样保留
+			b.WriteString(rest[start : start+end+2])
+		}
+		rest = rest[start+end+2:]
+	}
+}
+
 // ApplyCustomHeaders 应用自定义请求头（覆盖或添加）
 // 使用 http.Header.Set 会自动规范化 key 为 CanonicalHeaderKey 格式
-// 跳过空白 key 或 value
-func ApplyCustomHeaders(headers http.Header, customHeaders map[string]string) {
+// 跳过空白 key 或 value；值中的 {{model}}、{{uuid}}、{{keyMask}} 按 vars 解析
+func ApplyCustomHeaders(headers http.Header, customHeaders map[string]string, vars HeaderTemplateVars) {
+	var requestUUID string
 	for key, value := range customHeaders {
 		key = strings.TrimSpace(key)
 		value = strings.TrimSpace(value)
 		if key == "" || value == "" {
 			continue
 		}
-		headers.Set(key, value)
+		headers.Set(key, renderHeaderTemplate(value, vars, &requestUUID))
 	}
 }
 
@@ -117,7 +237,51 @@ func ForwardResponseHeaders(upstreamHeaders http.Header, clientWriter http.Respo
 			continue
 		}
 
-		// 转发头部（可能有多个值）
+		// 转发头部（可能有多个值），先删除已存在的同名头部避免重复
+		clientWriter.Header().Del(key)
+		for _, value := range values {
+			clientWriter.Header().Add(key, value)
+		}
+	}
+}
+
+// DefaultForwardedResponseHeaders 默认始终转发给客户端的上游响应头（限流与重试相关）
+const DefaultForwardedResponseHeaders = "anthropic-ratelimit-*,x-ratelimit-*,retry-after,retry-after-ms,request-id,x-request-id"
+
+// forwardedResponseHeaders 响应头白名单（小写；以 * 结尾表示前缀匹配）
+var forwardedResponseHeaders atomic.Pointer[[]string]
+
+// SetForwardedResponseHeaders 设置始终转发给客户端的上游响应头白名单（逗号分隔，支持 "前缀*" 通配）
+// 白名单中的头部在成功响应与 failover 最终错误响应中都会转发，供客户端 SDK 做限流节奏控制
+func SetForwardedResponseHeaders(list string) {
+	patterns := make([]string, 0)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item != "" {
+			patterns = append(patterns, item)
+		}
+	}
+	forwardedResponseHeaders.Store(&patterns)
+}
+
+// isForwardedResponseHeader 判断响应头是否在白名单中
+func isForwardedResponseHeader(key string) bool {
+	patterns := forwardedResponseHeaders.Load()
+	if patterns == nil {
+		SetForwardedResponseHeaders(DefaultForwardedResponseHeaders)
+		patterns = forwardedResponseHeaders.Load()
+	}
+	return matchHeaderPattern(*patterns, strings.ToLower(key))
+}
+
+// ForwardAllowlistedResponseHeaders 仅转发白名单中的上游响应头（如限流、retry-after）
+// 用于协议转换后的响应和 failover 最终错误响应，这些场景不适合透传全部上游头部
+func ForwardAllowlistedResponseHeaders(upstreamHeaders http.Header, clientWriter http.ResponseWriter) {
+	for key, values := range upstreamHeaders {
+		if !isForwardedResponseHeader(key) {
+			continue
+		}
+		clientWriter.Header().Del(key)
 		for _, value := range values {
 			clientWriter.Header().Add(key, value)
 		}
@@ -86,6 +86,76 @@ func TestPrepareUpstreamHeaders(t *testing.T) {
 	}
 }
 
+func TestFilterForwardedHeaders(t *testing.T) {
+	newHeaders := func() http.Header {
+		h := http.Header{}
+		h.Set("Host", "upstream.api.com")
+		h.Set("Content-Type", "application/json")
+		h.Set("User-Agent", "TestClient/1.0")
+		h.Set("Anthropic-Version", "2023-06-01")
+		h.Set("Anthropic-Beta", "tools-2024-04-04")
+		h.Set("X-Stainless-Os", "MacOS")
+		h.Set("X-Stainless-Lang", "js")
+		return h
+	}
+
+	tests := []struct {
+		name      string
+		allowlist []string
+		strip     []string
+		want      []string
+		wantGone  []string
+	}{
+		{
+			name: "未配置时全部透传",
+			want: []string{"Host", "Content-Type", "User-Agent", "Anthropic-Version", "X-Stainless-Os"},
+		},
+		{
+			name:     "stripHeaders 按前缀移除",
+			strip:    []string{"x-stainless-*"},
+			want:     []string{"Host", "Content-Type", "User-Agent", "Anthropic-Version"},
+			wantGone: []string{"X-Stainless-Os", "X-Stainless-Lang"},
+		},
+		{
+			name:     "stripHeaders 精确匹配不区分大小写",
+			strip:    []string{"USER-AGENT"},
+			want:     []string{"X-Stainless-Os"},
+			wantGone: []string{"User-Agent"},
+		},
+		{
+			name:      "仅转发白名单（Host、Content-Type 始终保留）",
+			allowlist: []string{"anthropic-version"},
+			want:      []string{"Host", "Content-Type", "Anthropic-Version"},
+			wantGone:  []string{"User-Agent", "Anthropic-Beta", "X-Stainless-Os"},
+		},
+		{
+			name:      "stripHeaders 优先于白名单",
+			allowlist: []string{"anthropic-*"},
+			strip:     []string{"anthropic-beta"},
+			want:      []string{"Anthropic-Version"},
+			wantGone:  []string{"Anthropic-Beta", "User-Agent"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := newHeaders()
+			FilterForwardedHeaders(headers, tt.allowlist, tt.strip)
+
+			for _, key := range tt.want {
+				if headers.Get(key) == "" {
+					t.Errorf("Header %s 应保留", key)
+				}
+			}
+			for _, key := range tt.wantGone {
+				if headers.Get(key) != "" {
+					t.Errorf("Header %s 应被移除, got %q", key, headers.Get(key))
+				}
+			}
+		})
+	}
+}
+
 func TestSetAuthenticationHeader(t *testing.T) {
 	tests := []struct {
 		name              string
@@ -265,7 +335,7 @@ func TestApplyCustomHeaders(t *testing.T) {
 				headers.Set(k, v)
 			}
 
-			ApplyCustomHeaders(headers, tt.custom)
+			ApplyCustomHeaders(headers, tt.custom, HeaderTemplateVars{})
 
 			for k, want := range tt.wantHeaders {
 				if got := headers.Get(k); got != want {
@@ -769,6 +769,7 @@ func MaskSensitiveHeaders(headers map[string]string) map[string]string {
 		"authorization":  true,
 		"x-api-key":      true,
 		"x-goog-api-key": true,
+		"api-key":        true,
 	}
 
 	masked := make(map[string]string, len(headers))
@@ -74,7 +74,7 @@ func (s *StreamSynthesizer) ProcessLine(line string) {
 	switch s.serviceType {
 	case "gemini":
 		s.processGemini(data)
-	case "openai":
+	case "openai", "azure":
 		s.processOpenAI(data)
 	case "claude":
 		s.processClaude(data)
@@ -3,6 +3,7 @@
 
 import (
 	"log"
+	"math"
 	"sort"
 	"sync"
 	"time"
@@ -18,12 +19,16 @@ type URLLatencyResult struct {
 // URLState URL 状态信息
 type URLState struct {
 	URL             string
-	OriginalIdx     int       // 原始索引（用于指标记录）
-	FailCount       int       // 连续失败次数
-	LastFailTime    time.Time // 最后失败时间
-	LastSuccessTime time.Time // 最后成功时间
-	TotalRequests   int64     // 总请求数
-	TotalFailures   int64     // 总失败数
+	OriginalIdx     int           // 原始索引（用于指标记录）
+	FailCount       int           // 连续失败次数
+	LastFailTime    time.Time     // 最后失败时间
+	LastSuccessTime time.Time     // 最后成功时间
+	TotalRequests   int64         // 总请求数
+	TotalFailures   int64         // 总失败数
+	Latency         time.Duration // 后台探测延迟（指数平滑，0 表示尚无探测数据）
+	LatencySampleAt time.Time     // 最后一次探测时间
+	HealthPenalty   float64       // 健康度扣分（0 表示完全健康，每次失败向 1 逼近，随时间指数衰减）
+	HealthUpdatedAt time.Time     // 健康度扣分最后更新时间（衰减起点）
 }
 
 // ChannelURLState 渠道 URL 状态
@@ -39,8 +44,17 @@ type URLManager struct {
 	channelStates   map[int]*ChannelURLState // key: channelIndex
 	failureCooldown time.Duration            // 失败冷却时间（过后允许重试）
 	maxFailCount    int                      // 最大连续失败次数（超过则移到末尾）
+	now             func() time.Time
 }
 
+// 健康度记忆：每次失败使健康分减半，扣分按半衰期指数衰减；
+// 冷却期已过且健康分恢复到阈值以上的 URL 视为恢复，无需真实成功请求即可重新排到前面
+const (
+	healthFailureFactor      = 0.5
+	healthRecoveryHalfLife   = 5 * time.Minute
+	healthRecoveredThreshold = 0.95
+)
+
 // NewURLManager 创建 URL 管理器
 func NewURLManager(failureCooldown time.Duration, maxFailCount int) *URLManager {
 	if failureCooldown <= 0 {
@@ -53,7 +67,21 @@ func NewURLManager(failureCooldown time.Duration, maxFailCount int) *URLManager
 		channelStates:   make(map[int]*ChannelURLState),
 		failureCooldown: failureCooldown,
 		maxFailCount:    maxFailCount,
+		now:             time.Now,
+	}
+}
+
+// healthScore 计算 URL 在 now 时刻的健康分（0~1，1 表示完全健康）
+func healthScore(urlState *URLState, now time.Time) float64 {
+	if urlState.HealthPenalty == 0 {
+		return 1
+	}
+	elapsed := now.Sub(urlState.HealthUpdatedAt)
+	if elapsed < 0 {
+		elapsed = 0
 	}
+	decay := math.Pow(0.5, float64(elapsed)/float64(healthRecoveryHalfLife))
+	return 1 - urlState.HealthPenalty*decay
 }
 
 // GetSortedURLs 获取排序后的 URL 列表（非阻塞，立即返回）
@@ -79,7 +107,7 @@ func (m *URLManager) GetSortedURLs(channelIndex int, urls []string) []URLLatency
 	m.sortURLs(state)
 
 	// 构建排序后的结果
-	now := time.Now()
+	now := m.now()
 	results := make([]URLLatencyResult, len(state.URLs))
 
 	for i, urlState := range state.URLs {
@@ -103,18 +131,21 @@ func (m *URLManager) MarkSuccess(channelIndex int, url string) {
 		return
 	}
 
+	now := m.now()
 	for _, urlState := range state.URLs {
 		if urlState.URL == url {
 			urlState.FailCount = 0
-			urlState.LastSuccessTime = time.Now()
+			urlState.LastSuccessTime = now
 			urlState.TotalRequests++
+			urlState.HealthPenalty = 0
+			urlState.HealthUpdatedAt = now
 			break
 		}
 	}
 
 	// 成功后重新排序：成功的 URL 提升到前面
 	m.sortURLs(state)
-	state.UpdatedAt = time.Now()
+	state.UpdatedAt = now
 }
 
 // MarkFailure 标记 URL 失败
@@ -127,13 +158,15 @@ func (m *URLManager) MarkFailure(channelIndex int, url string) {
 		return
 	}
 
-	now := time.Now()
+	now := m.now()
 	for _, urlState := range state.URLs {
 		if urlState.URL == url {
 			urlState.FailCount++
 			urlState.LastFailTime = now
 			urlState.TotalRequests++
 			urlState.TotalFailures++
+			urlState.HealthPenalty = 1 - healthScore(urlState, now)*healthFailureFactor
+			urlState.HealthUpdatedAt = now
 			log.Printf("[URLManager] URL 失败: 渠道 [%d], URL: %s, 连续失败: %d", channelIndex, url, urlState.FailCount)
 			break
 		}
@@ -141,7 +174,37 @@ func (m *URLManager) MarkFailure(channelIndex int, url string) {
 
 	// 失败后重新排序：失败的 URL 移到后面
 	m.sortURLs(state)
-	state.UpdatedAt = time.Now()
+	state.UpdatedAt = now
+}
+
+// latencySmoothing 探测延迟的指数平滑系数（新样本权重）
+const latencySmoothing = 0.3
+
+// RecordLatency 记录后台探测得到的 URL 延迟（不计入请求数与失败数）
+// 渠道状态尚未建立时按 urls 初始化，使启动后首批请求即可按延迟排序
+func (m *URLManager) RecordLatency(channelIndex int, urls []string, url string, latency time.Duration) {
+	if latency <= 0 {
+		latency = time.Microsecond
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state := m.ensureChannelState(channelIndex, urls)
+	for _, urlState := range state.URLs {
+		if urlState.URL == url {
+			if urlState.Latency == 0 {
+				urlState.Latency = latency
+			} else {
+				urlState.Latency = time.Duration(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(urlState.Latency))
+			}
+			urlState.LatencySampleAt = m.now()
+			break
+		}
+	}
+
+	m.sortURLs(state)
+	state.UpdatedAt = m.now()
 }
 
 // ensureChannelState 确保渠道状态存在，并同步 URL 列表
@@ -153,7 +216,7 @@ func (m *URLManager) ensureChannelState(channelIndex int, urls []string) *Channe
 		state = &ChannelURLState{
 			ChannelIndex: channelIndex,
 			URLs:         make([]*URLState, len(urls)),
-			UpdatedAt:    time.Now(),
+			UpdatedAt:    m.now(),
 		}
 		for i, url := range urls {
 			state.URLs[i] = &URLState{
@@ -171,7 +234,7 @@ func (m *URLManager) ensureChannelState(channelIndex int, urls []string) *Channe
 		state = &ChannelURLState{
 			ChannelIndex: channelIndex,
 			URLs:         make([]*URLState, len(urls)),
-			UpdatedAt:    time.Now(),
+			UpdatedAt:    m.now(),
 		}
 		for i, url := range urls {
 			state.URLs[i] = &URLState{
@@ -204,11 +267,23 @@ func (m *URLManager) urlsMatch(states []*URLState, urls []string) bool {
 
 // sortURLs 对 URL 列表排序
 // 排序规则：
-// 1. 无失败记录的 URL 在最前（按原始索引排序）
-// 2. 冷却期已过的失败 URL 次之（按失败次数升序）
+// 1. 无失败记录的 URL 在最前（有探测延迟的按延迟升序在前，其余按原始索引排序）
+// 2. 冷却期已过的失败 URL 次之（按健康分降序）
 // 3. 仍在冷却期的失败 URL 在最后（按冷却剩余时间升序）
+//
+// 冷却期已过且健康分已衰减恢复的 URL 清除连续失败计数，重新按无失败记录参与排序
 func (m *URLManager) sortURLs(state *ChannelURLState) {
-	now := time.Now()
+	now := m.now()
+
+	for _, urlState := range state.URLs {
+		if urlState.FailCount == 0 || now.Sub(urlState.LastFailTime) < m.failureCooldown {
+			continue
+		}
+		if healthScore(urlState, now) >= healthRecoveredThreshold {
+			log.Printf("[URLManager] URL 健康度已恢复: 渠道 [%d], URL: %s, 此前连续失败: %d", state.ChannelIndex, urlState.URL, urlState.FailCount)
+			urlState.FailCount = 0
+		}
+	}
 
 	sort.SliceStable(state.URLs, func(i, j int) bool {
 		ui, uj := state.URLs[i], state.URLs[j]
@@ -220,7 +295,14 @@ func (m *URLManager) sortURLs(state *ChannelURLState) {
 			return iNoFail
 		}
 		if iNoFail && jNoFail {
-			// 都无失败，按原始索引
+			// 都无失败：有探测延迟的优先并按延迟排序，否则按原始索引
+			iSampled, jSampled := ui.Latency > 0, uj.Latency > 0
+			if iSampled != jSampled {
+				return iSampled
+			}
+			if iSampled && ui.Latency != uj.Latency {
+				return ui.Latency < uj.Latency
+			}
 			return ui.OriginalIdx < uj.OriginalIdx
 		}
 
@@ -233,9 +315,10 @@ func (m *URLManager) sortURLs(state *ChannelURLState) {
 		}
 
 		if iCooldownPassed && jCooldownPassed {
-			// 都过了冷却期，失败次数少的优先
-			if ui.FailCount != uj.FailCount {
-				return ui.FailCount < uj.FailCount
+			// 都过了冷却期，健康分高的优先
+			iScore, jScore := healthScore(ui, now), healthScore(uj, now)
+			if iScore != jScore {
+				return iScore > jScore
 			}
 			return ui.OriginalIdx < uj.OriginalIdx
 		}
@@ -268,6 +351,7 @@ func (m *URLManager) GetStats() map[string]interface{} {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	now := m.now()
 	channelStats := make(map[int]interface{})
 	for idx, state := range m.channelStates {
 		urlStats := make([]map[string]interface{}, len(state.URLs))
@@ -280,6 +364,8 @@ func (m *URLManager) GetStats() map[string]interface{} {
 				"total_failures":    urlState.TotalFailures,
 				"last_fail_time":    urlState.LastFailTime,
 				"last_success_time": urlState.LastSuccessTime,
+				"latency_ms":        urlState.Latency.Milliseconds(),
+				"health_score":      math.Round(healthScore(urlState, now)*1000) / 1000,
 			}
 		}
 		channelStats[idx] = map[string]interface{}{
@@ -292,6 +378,7 @@ func (m *URLManager) GetStats() map[string]interface{} {
 		"total_channels":   len(m.channelStates),
 		"failure_cooldown": m.failureCooldown.String(),
 		"max_fail_count":   m.maxFailCount,
+		"health_half_life": healthRecoveryHalfLife.String(),
 		"channels":         channelStats,
 	}
 }
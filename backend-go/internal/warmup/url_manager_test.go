@@ -0,0 +1,90 @@
+package warmup
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestURLManager() (*URLManager, *time.Time) {
+	m := NewURLManager(30*time.Second, 3)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+func urlHealthScore(t *testing.T, m *URLManager, channelIndex int, url string) float64 {
+	t.Helper()
+	channels := m.GetStats()["channels"].(map[int]interface{})
+	urls := channels[channelIndex].(map[string]interface{})["urls"].([]map[string]interface{})
+	for _, u := range urls {
+		if u["url"] == url {
+			return u["health_score"].(float64)
+		}
+	}
+	t.Fatalf("GetStats() 中未找到 URL %s", url)
+	return 0
+}
+
+// TestURLManager_HealthScoreDecay 失败后健康分随时间衰减恢复，恢复后无需成功请求即可重新排到前面
+func TestURLManager_HealthScoreDecay(t *testing.T) {
+	m, now := newTestURLManager()
+	urls := []string{"https://a.example.com", "https://b.example.com"}
+	m.GetSortedURLs(0, urls)
+
+	m.MarkFailure(0, urls[0])
+	if got := urlHealthScore(t, m, 0, urls[0]); got != 0.5 {
+		t.Fatalf("失败后健康分 = %v, want 0.5", got)
+	}
+	if got := urlHealthScore(t, m, 0, urls[1]); got != 1 {
+		t.Fatalf("未失败 URL 健康分 = %v, want 1", got)
+	}
+
+	tests := []struct {
+		name      string
+		advance   time.Duration
+		wantScore float64
+		wantFirst string
+	}{
+		{name: "冷却期内", advance: 10 * time.Second, wantScore: 0.511, wantFirst: urls[1]},
+		{name: "一个半衰期", advance: healthRecoveryHalfLife - 10*time.Second, wantScore: 0.75, wantFirst: urls[1]},
+		{name: "两个半衰期", advance: healthRecoveryHalfLife, wantScore: 0.875, wantFirst: urls[1]},
+		{name: "恢复到阈值以上", advance: 3 * healthRecoveryHalfLife, wantScore: 0.984, wantFirst: urls[0]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*now = now.Add(tt.advance)
+			if got := urlHealthScore(t, m, 0, urls[0]); got != tt.wantScore {
+				t.Fatalf("健康分 = %v, want %v", got, tt.wantScore)
+			}
+			if got := m.GetSortedURLs(0, urls)[0].URL; got != tt.wantFirst {
+				t.Fatalf("首选 URL = %s, want %s", got, tt.wantFirst)
+			}
+		})
+	}
+}
+
+// TestURLManager_HealthScoreRepeatedFailures 连续失败叠加扣分，成功立即恢复满分
+func TestURLManager_HealthScoreRepeatedFailures(t *testing.T) {
+	m, now := newTestURLManager()
+	urls := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"}
+	m.GetSortedURLs(0, urls)
+
+	m.MarkFailure(0, urls[0])
+	m.MarkFailure(0, urls[0])
+	m.MarkFailure(0, urls[1])
+	if got := urlHealthScore(t, m, 0, urls[0]); got != 0.25 {
+		t.Fatalf("两次失败后健康分 = %v, want 0.25", got)
+	}
+
+	// 冷却期已过、尚未恢复：健康分高的失败 URL 优先
+	*now = now.Add(time.Minute)
+	sorted := m.GetSortedURLs(0, urls)
+	if sorted[0].URL != urls[2] || sorted[1].URL != urls[1] || sorted[2].URL != urls[0] {
+		t.Fatalf("排序 = %v, want c, b, a", sorted)
+	}
+
+	m.MarkSuccess(0, urls[0])
+	if got := urlHealthScore(t, m, 0, urls[0]); got != 1 {
+		t.Fatalf("成功后健康分 = %v, want 1", got)
+	}
+}
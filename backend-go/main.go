@@ -22,6 +22,7 @@
 	"github.com/BenedictKing/ccx/internal/middleware"
 	"github.com/BenedictKing/ccx/internal/scheduler"
 	"github.com/BenedictKing/ccx/internal/session"
+	"github.com/BenedictKing/ccx/internal/utils"
 	"github.com/BenedictKing/ccx/internal/warmup"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -56,6 +57,9 @@ func main() {
 		log.Fatalf("初始化日志系统失败: %v", err)
 	}
 
+	// 设置始终转发给客户端的上游响应头白名单
+	utils.SetForwardedResponseHeaders(envCfg.ForwardResponseHeaders)
+
 	cfgManager, err := config.NewConfigManager(".config/config.json")
 	if err != nil {
 		log.Fatalf("初始化配置管理器失败: %v", err)
@@ -75,7 +79,7 @@ func main() {
 	if envCfg.MetricsPersistenceEnabled {
 		var err error
 		metricsStore, err = metrics.NewSQLiteStore(&metrics.SQLiteStoreConfig{
-			DBPath:        ".config/metrics.db",
+			DBPath:        envCfg.MetricsDBPath,
 			RetentionDays: envCfg.MetricsRetentionDays,
 		})
 		if err != nil {
@@ -86,32 +90,115 @@ func main() {
 		log.Printf("[Metrics-Init] 指标持久化已禁用，使用纯内存模式")
 	}
 
-	// 初始化多渠道调度器（Messages、Responses、Gemini 和 Chat 使用独立的指标管理器）
-	var messagesMetricsManager, responsesMetricsManager, geminiMetricsManager, chatMetricsManager *metrics.MetricsManager
+	// 初始化多渠道调度器（Messages、Responses、Gemini 和 Chat 使用独立的指标管理器，熔断参数可按类型单独配置）
+	var persistenceStore metrics.PersistenceStore
 	if metricsStore != nil {
-		messagesMetricsManager = metrics.NewMetricsManagerWithPersistence(
-			envCfg.MetricsWindowSize, envCfg.MetricsFailureThreshold, metricsStore, "messages")
-		responsesMetricsManager = metrics.NewMetricsManagerWithPersistence(
-			envCfg.MetricsWindowSize, envCfg.MetricsFailureThreshold, metricsStore, "responses")
-		geminiMetricsManager = metrics.NewMetricsManagerWithPersistence(
-			envCfg.MetricsWindowSize, envCfg.MetricsFailureThreshold, metricsStore, "gemini")
-		chatMetricsManager = metrics.NewMetricsManagerWithPersistence(
-			envCfg.MetricsWindowSize, envCfg.MetricsFailureThreshold, metricsStore, "chat")
+		persistenceStore = metricsStore
+	}
+	messagesMetricsManager := scheduler.NewKindMetricsManager(envCfg, scheduler.ChannelKindMessages, persistenceStore)
+	responsesMetricsManager := scheduler.NewKindMetricsManager(envCfg, scheduler.ChannelKindResponses, persistenceStore)
+	geminiMetricsManager := scheduler.NewKindMetricsManager(envCfg, scheduler.ChannelKindGemini, persistenceStore)
+	chatMetricsManager := scheduler.NewKindMetricsManager(envCfg, scheduler.ChannelKindChat, persistenceStore)
+	if envCfg.MetricsTPMIncludeThinking {
+		for _, mm := range []*metrics.MetricsManager{messagesMetricsManager, responsesMetricsManager, geminiMetricsManager, chatMetricsManager} {
+			mm.SetTPMIncludeThinking(true)
+		}
+	}
+	for _, mm := range []*metrics.MetricsManager{messagesMetricsManager, responsesMetricsManager, geminiMetricsManager, chatMetricsManager} {
+		mm.SetStaleKeyCleanup(envCfg.MetricsCleanupChunkSize, time.Duration(envCfg.MetricsCleanupJitter)*time.Second)
+		mm.SetDashboardMinSamples(envCfg.MetricsDashboardMinSamples)
+	}
+	if envCfg.AdaptiveThresholdEnabled {
+		adaptive := &metrics.AdaptiveThresholdConfig{
+			LowRPM:           envCfg.AdaptiveThresholdLowRPM,
+			HighRPM:          envCfg.AdaptiveThresholdHighRPM,
+			LenientThreshold: envCfg.AdaptiveThresholdLenient,
+			StrictThreshold:  envCfg.AdaptiveThresholdStrict,
+		}
+		if err := adaptive.Validate(); err != nil {
+			log.Printf("[Metrics-Init] 警告: 自适应熔断阈值配置无效，使用固定阈值: %v", err)
+		} else {
+			for _, mm := range []*metrics.MetricsManager{messagesMetricsManager, responsesMetricsManager, geminiMetricsManager, chatMetricsManager} {
+				_ = mm.SetAdaptiveThreshold(adaptive)
+			}
+			log.Printf("[Metrics-Init] 自适应熔断阈值已启用 (RPM %.0f→%.0f, 阈值 %.0f%%→%.0f%%)",
+				adaptive.LowRPM, adaptive.HighRPM, adaptive.LenientThreshold*100, adaptive.StrictThreshold*100)
+		}
+	}
+	if healthAggregation, err := metrics.ParseHealthAggregation(envCfg.MetricsHealthAggregation); err != nil {
+		log.Printf("[Metrics-Init] 警告: %v，使用 pooled", err)
+	} else {
+		for _, mm := range []*metrics.MetricsManager{messagesMetricsManager, responsesMetricsManager, geminiMetricsManager, chatMetricsManager} {
+			mm.SetHealthAggregation(healthAggregation)
+		}
+	}
+	if tpmDefinition, err := metrics.ParseTPMDefinition(envCfg.MetricsTPMDefinition); err != nil {
+		log.Printf("[Metrics-Init] 警告: %v，使用 output", err)
 	} else {
-		messagesMetricsManager = metrics.NewMetricsManagerWithConfig(envCfg.MetricsWindowSize, envCfg.MetricsFailureThreshold)
-		responsesMetricsManager = metrics.NewMetricsManagerWithConfig(envCfg.MetricsWindowSize, envCfg.MetricsFailureThreshold)
-		geminiMetricsManager = metrics.NewMetricsManagerWithConfig(envCfg.MetricsWindowSize, envCfg.MetricsFailureThreshold)
-		chatMetricsManager = metrics.NewMetricsManagerWithConfig(envCfg.MetricsWindowSize, envCfg.MetricsFailureThreshold)
+		for _, mm := range []*metrics.MetricsManager{messagesMetricsManager, responsesMetricsManager, geminiMetricsManager, chatMetricsManager} {
+			mm.SetTPMDefinition(tpmDefinition)
+		}
+	}
+	// 每日 token 预算：按接口类型注入对应指标管理器的今日用量检查
+	for apiType, mm := range map[string]*metrics.MetricsManager{
+		"Messages":  messagesMetricsManager,
+		"Responses": responsesMetricsManager,
+		"Gemini":    geminiMetricsManager,
+		"Chat":      chatMetricsManager,
+	} {
+		mm := mm
+		cfgManager.SetKeyBudgetChecker(apiType, func(upstream *config.UpstreamConfig, apiKey string) bool {
+			return mm.IsKeyOverBudget(upstream.GetAllBaseURLs(), apiKey, upstream.DailyTokenBudget)
+		})
+	}
+	// 渠道级熔断覆盖：低质量渠道或显式配置的渠道使用独立的失败率阈值与恢复时间
+	// 配置变更时推送到指标管理器，熔断判断不在持有指标锁时回调配置
+	circuitMetrics := map[string]*metrics.MetricsManager{
+		"messages":  messagesMetricsManager,
+		"responses": responsesMetricsManager,
+		"gemini":    geminiMetricsManager,
+		"chat":      chatMetricsManager,
 	}
+	cfgManager.AddConfigChangeHook(func(cfg *config.Config) {
+		for kind, mm := range circuitMetrics {
+			overrides := make(map[string]metrics.CircuitOverride)
+			for baseURL, override := range cfg.CircuitOverridesByBaseURL(kind) {
+				overrides[baseURL] = metrics.CircuitOverride{FailureThreshold: override.FailureThreshold, RecoveryTime: override.RecoveryTime}
+			}
+			mm.SetCircuitOverrides(overrides)
+		}
+	})
 	traceAffinityManager := session.NewTraceAffinityManager()
+	if envCfg.TraceAffinityKindTTLs != "" {
+		kindTTLs, err := session.ParseKindTTLs(envCfg.TraceAffinityKindTTLs)
+		if err != nil {
+			log.Printf("[Affinity-Init] 警告: TRACE_AFFINITY_KIND_TTLS 配置无效，使用默认 TTL: %v", err)
+		} else {
+			for kind, ttl := range kindTTLs {
+				traceAffinityManager.SetKindTTL(kind, ttl)
+				log.Printf("[Affinity-Init] %s 亲和 TTL: %v", kind, ttl)
+			}
+		}
+	}
 
 	// 初始化 URL 管理器（非阻塞，动态排序）
 	urlManager := warmup.NewURLManager(30*time.Second, 3) // 30秒冷却期，连续3次失败后移到末尾
 	log.Printf("[URLManager-Init] URL管理器已初始化 (冷却期: 30秒, 最大连续失败: 3)")
 
 	channelScheduler := scheduler.NewChannelScheduler(cfgManager, messagesMetricsManager, responsesMetricsManager, geminiMetricsManager, chatMetricsManager, traceAffinityManager, urlManager)
-	log.Printf("[Scheduler-Init] 多渠道调度器已初始化 (失败率阈值: %.0f%%, 滑动窗口: %d)",
-		messagesMetricsManager.GetFailureThreshold()*100, messagesMetricsManager.GetWindowSize())
+	log.Printf("[Scheduler-Init] 多渠道调度器已初始化")
+	for _, kind := range []scheduler.ChannelKind{scheduler.ChannelKindMessages, scheduler.ChannelKindResponses, scheduler.ChannelKindGemini, scheduler.ChannelKindChat} {
+		mm := channelScheduler.GetMetricsManagerByKind(kind)
+		log.Printf("[Scheduler-Init] %s 指标配置 (失败率阈值: %.0f%%, 滑动窗口: %d, 熔断恢复: %v)",
+			kind, mm.GetFailureThreshold()*100, mm.GetWindowSize(), mm.GetCircuitRecoveryTime())
+	}
+	channelScheduler.StartKeyAutoReorder(time.Duration(envCfg.KeyReorderInterval) * time.Second)
+	channelScheduler.StartPromotionExpiryCleanup(time.Minute)
+	if envCfg.LatencySamplerEnabled {
+		channelScheduler.StartLatencySampler(time.Duration(envCfg.LatencySamplerInterval) * time.Second)
+	}
+	channelScheduler.StartShadowReplayWorkers(envCfg.ShadowConcurrency, envCfg.ShadowQueueSize)
+	defer channelScheduler.Stop()
 
 	// 设置 Gin 模式
 	if envCfg.IsProduction() {
@@ -151,8 +238,9 @@ func main() {
 		apiGroup.POST("/messages/channels", messages.AddUpstream(cfgManager))
 		apiGroup.PUT("/messages/channels/:id", messages.UpdateUpstream(cfgManager, channelScheduler))
 		apiGroup.DELETE("/messages/channels/:id", messages.DeleteUpstream(cfgManager, channelScheduler))
-		apiGroup.POST("/messages/channels/:id/keys", messages.AddApiKey(cfgManager))
+		apiGroup.POST("/messages/channels/:id/keys", messages.AddApiKey(cfgManager, channelScheduler))
 		apiGroup.DELETE("/messages/channels/:id/keys/:apiKey", messages.DeleteApiKey(cfgManager))
+		apiGroup.POST("/messages/channels/:id/keys/rotate", handlers.RotateChannelKey(cfgManager, messagesMetricsManager, "messages"))
 		apiGroup.POST("/messages/channels/:id/keys/:apiKey/top", messages.MoveApiKeyToTop(cfgManager))
 		apiGroup.POST("/messages/channels/:id/keys/:apiKey/bottom", messages.MoveApiKeyToBottom(cfgManager))
 
@@ -172,6 +260,8 @@ func main() {
 		apiGroup.POST("/messages/channels/:id/models", messages.GetChannelModels(cfgManager))
 		apiGroup.GET("/messages/models/stats/history", handlers.GetModelStatsHistory(messagesMetricsManager))
 		apiGroup.GET("/messages/channels/:id/logs", handlers.GetChannelLogs(channelScheduler.GetChannelLogStore(scheduler.ChannelKindMessages)))
+		apiGroup.GET("/messages/channels/:id/keys/errors", handlers.GetChannelKeyErrors(channelScheduler.GetChannelLogStore(scheduler.ChannelKindMessages)))
+		apiGroup.POST("/messages/channels/:id/probe", handlers.ProbeChannel(cfgManager, "messages"))
 		apiGroup.POST("/messages/channels/:id/capability-test", handlers.TestChannelCapability(cfgManager, "messages"))
 		apiGroup.GET("/messages/channels/:id/capability-test/:jobId", handlers.GetCapabilityTestJobStatus(cfgManager, "messages"))
 		apiGroup.DELETE("/messages/channels/:id/capability-test/:jobId", handlers.CancelCapabilityTestJob(cfgManager, "messages"))
@@ -182,8 +272,9 @@ func main() {
 		apiGroup.POST("/responses/channels", responses.AddUpstream(cfgManager))
 		apiGroup.PUT("/responses/channels/:id", responses.UpdateUpstream(cfgManager, channelScheduler))
 		apiGroup.DELETE("/responses/channels/:id", responses.DeleteUpstream(cfgManager, channelScheduler))
-		apiGroup.POST("/responses/channels/:id/keys", responses.AddApiKey(cfgManager))
+		apiGroup.POST("/responses/channels/:id/keys", responses.AddApiKey(cfgManager, channelScheduler))
 		apiGroup.DELETE("/responses/channels/:id/keys/:apiKey", responses.DeleteApiKey(cfgManager))
+		apiGroup.POST("/responses/channels/:id/keys/rotate", handlers.RotateChannelKey(cfgManager, responsesMetricsManager, "responses"))
 		apiGroup.POST("/responses/channels/:id/keys/:apiKey/top", responses.MoveApiKeyToTop(cfgManager))
 		apiGroup.POST("/responses/channels/:id/keys/:apiKey/bottom", responses.MoveApiKeyToBottom(cfgManager))
 
@@ -199,6 +290,8 @@ func main() {
 		apiGroup.POST("/responses/channels/:id/models", responses.GetChannelModels(cfgManager))
 		apiGroup.GET("/responses/models/stats/history", handlers.GetModelStatsHistory(responsesMetricsManager))
 		apiGroup.GET("/responses/channels/:id/logs", handlers.GetChannelLogs(channelScheduler.GetChannelLogStore(scheduler.ChannelKindResponses)))
+		apiGroup.GET("/responses/channels/:id/keys/errors", handlers.GetChannelKeyErrors(channelScheduler.GetChannelLogStore(scheduler.ChannelKindResponses)))
+		apiGroup.POST("/responses/channels/:id/probe", handlers.ProbeChannel(cfgManager, "responses"))
 		apiGroup.POST("/responses/channels/:id/capability-test", handlers.TestChannelCapability(cfgManager, "responses"))
 		apiGroup.GET("/responses/channels/:id/capability-test/:jobId", handlers.GetCapabilityTestJobStatus(cfgManager, "responses"))
 		apiGroup.DELETE("/responses/channels/:id/capability-test/:jobId", handlers.CancelCapabilityTestJob(cfgManager, "responses"))
@@ -209,8 +302,9 @@ func main() {
 		apiGroup.POST("/gemini/channels", gemini.AddUpstream(cfgManager))
 		apiGroup.PUT("/gemini/channels/:id", gemini.UpdateUpstream(cfgManager, channelScheduler))
 		apiGroup.DELETE("/gemini/channels/:id", gemini.DeleteUpstream(cfgManager, channelScheduler))
-		apiGroup.POST("/gemini/channels/:id/keys", gemini.AddApiKey(cfgManager))
+		apiGroup.POST("/gemini/channels/:id/keys", gemini.AddApiKey(cfgManager, channelScheduler))
 		apiGroup.DELETE("/gemini/channels/:id/keys/:apiKey", gemini.DeleteApiKey(cfgManager))
+		apiGroup.POST("/gemini/channels/:id/keys/rotate", handlers.RotateChannelKey(cfgManager, geminiMetricsManager, "gemini"))
 		apiGroup.POST("/gemini/channels/:id/keys/:apiKey/top", gemini.MoveApiKeyToTop(cfgManager))
 		apiGroup.POST("/gemini/channels/:id/keys/:apiKey/bottom", gemini.MoveApiKeyToBottom(cfgManager))
 
@@ -227,6 +321,8 @@ func main() {
 		apiGroup.POST("/gemini/channels/:id/models", gemini.GetChannelModels(cfgManager))
 		apiGroup.GET("/gemini/models/stats/history", handlers.GetModelStatsHistory(geminiMetricsManager))
 		apiGroup.GET("/gemini/channels/:id/logs", handlers.GetChannelLogs(channelScheduler.GetChannelLogStore(scheduler.ChannelKindGemini)))
+		apiGroup.GET("/gemini/channels/:id/keys/errors", handlers.GetChannelKeyErrors(channelScheduler.GetChannelLogStore(scheduler.ChannelKindGemini)))
+		apiGroup.POST("/gemini/channels/:id/probe", handlers.ProbeChannel(cfgManager, "gemini"))
 		apiGroup.POST("/gemini/channels/:id/capability-test", handlers.TestChannelCapability(cfgManager, "gemini"))
 		apiGroup.GET("/gemini/channels/:id/capability-test/:jobId", handlers.GetCapabilityTestJobStatus(cfgManager, "gemini"))
 		apiGroup.DELETE("/gemini/channels/:id/capability-test/:jobId", handlers.CancelCapabilityTestJob(cfgManager, "gemini"))
@@ -237,8 +333,9 @@ func main() {
 		apiGroup.POST("/chat/channels", chat.AddUpstream(cfgManager))
 		apiGroup.PUT("/chat/channels/:id", chat.UpdateUpstream(cfgManager, channelScheduler))
 		apiGroup.DELETE("/chat/channels/:id", chat.DeleteUpstream(cfgManager, channelScheduler))
-		apiGroup.POST("/chat/channels/:id/keys", chat.AddApiKey(cfgManager))
+		apiGroup.POST("/chat/channels/:id/keys", chat.AddApiKey(cfgManager, channelScheduler))
 		apiGroup.DELETE("/chat/channels/:id/keys/:apiKey", chat.DeleteApiKey(cfgManager))
+		apiGroup.POST("/chat/channels/:id/keys/rotate", handlers.RotateChannelKey(cfgManager, chatMetricsManager, "chat"))
 		apiGroup.POST("/chat/channels/:id/keys/:apiKey/top", chat.MoveApiKeyToTop(cfgManager))
 		apiGroup.POST("/chat/channels/:id/keys/:apiKey/bottom", chat.MoveApiKeyToBottom(cfgManager))
 
@@ -256,12 +353,53 @@ func main() {
 		apiGroup.POST("/chat/channels/:id/models", chat.GetChannelModels(cfgManager))
 		apiGroup.GET("/chat/models/stats/history", handlers.GetModelStatsHistory(chatMetricsManager))
 		apiGroup.GET("/chat/channels/:id/logs", handlers.GetChannelLogs(channelScheduler.GetChannelLogStore(scheduler.ChannelKindChat)))
+		apiGroup.GET("/chat/channels/:id/keys/errors", handlers.GetChannelKeyErrors(channelScheduler.GetChannelLogStore(scheduler.ChannelKindChat)))
+		apiGroup.POST("/chat/channels/:id/probe", handlers.ProbeChannel(cfgManager, "chat"))
 		apiGroup.POST("/chat/channels/:id/capability-test", handlers.TestChannelCapability(cfgManager, "chat"))
 		apiGroup.GET("/chat/channels/:id/capability-test/:jobId", handlers.GetCapabilityTestJobStatus(cfgManager, "chat"))
 		apiGroup.DELETE("/chat/channels/:id/capability-test/:jobId", handlers.CancelCapabilityTestJob(cfgManager, "chat"))
 		apiGroup.POST("/chat/channels/:id/capability-test/:jobId/retry", handlers.RetryCapabilityTestModel(cfgManager, "chat"))
 		apiGroup.GET("/chat/channels/scheduler/stats", handlers.GetSchedulerStats(channelScheduler))
 
+		// 按模型汇总用量（跨渠道，可按接口类型筛选）
+		apiGroup.GET("/models/usage/summary", handlers.GetModelUsageSummary(channelScheduler))
+
+		// 按 token 用量排名的 Key（定位成本来源）
+		apiGroup.GET("/keys/top-tokens", handlers.GetTopKeysByTokens(channelScheduler))
+
+		// 每个 Key 的实时熔断状态（含剩余恢复时间）
+		apiGroup.GET("/keys/circuit-states", handlers.GetCircuitStates(channelScheduler))
+
+		// 添加渠道前测试单个 BaseURL + Key（不影响真实指标）
+		apiGroup.POST("/upstream/test-key", handlers.TestUpstreamKey(cfgManager))
+
+		// 预览经模型映射与协议转换后的上游请求（不发送）
+		apiGroup.POST("/upstream/preview-request", handlers.PreviewUpstreamRequest(cfgManager))
+
+		// 全局用量汇总（跨渠道，支持 today/24h/7d 范围）
+		apiGroup.GET("/stats/global", handlers.GetGlobalStats(channelScheduler))
+
+		// 多渠道指标历史批量查询（仪表盘一次获取所有渠道时间序列）
+		apiGroup.GET("/channels/metrics/history/batch", handlers.GetMultiChannelHistory(channelScheduler))
+
+		// 所有渠道都失败的请求记录（死信）
+		apiGroup.GET("/dead-letters", handlers.GetDeadLetters(channelScheduler.GetDeadLetterStore()))
+		apiGroup.POST("/dead-letters/:id/replay", handlers.ReplayDeadLetter(envCfg, cfgManager, sessionManager, channelScheduler))
+
+		// 按请求历史修正 Key 聚合计数（计数漂移时使用）
+		apiGroup.POST("/metrics/reconcile", handlers.ReconcileMetrics(channelScheduler))
+
+		// 未映射到当前配置的孤立指标（Key 轮换后遗留）
+		apiGroup.GET("/metrics/orphans", handlers.GetOrphanMetrics(channelScheduler))
+		apiGroup.DELETE("/metrics/orphans", handlers.PurgeOrphanMetrics(channelScheduler))
+
+		// 请求历史归档备份与恢复
+		apiGroup.GET("/metrics/backup", handlers.BackupMetrics(channelScheduler))
+		apiGroup.POST("/metrics/restore", handlers.RestoreMetrics(channelScheduler))
+
+		// 从磁盘热重载配置（外部编辑配置文件后使用）
+		apiGroup.POST("/config/reload", handlers.ReloadConfig(cfgManager, channelScheduler))
+
 		// Fuzzy 模式设置
 		apiGroup.GET("/settings/fuzzy-mode", handlers.GetFuzzyMode(cfgManager))
 		apiGroup.PUT("/settings/fuzzy-mode", handlers.SetFuzzyMode(cfgManager))
@@ -269,6 +407,30 @@ func main() {
 		// 移除计费头设置
 		apiGroup.GET("/settings/strip-billing-header", handlers.GetStripBillingHeader(cfgManager))
 		apiGroup.PUT("/settings/strip-billing-header", handlers.SetStripBillingHeader(cfgManager))
+
+		// 模型映射解析（调试渠道模型重定向规则）
+		apiGroup.GET("/model-mapping/resolve", handlers.ResolveModel(cfgManager))
+
+		// 渠道生效配置（展开状态、优先级等隐式默认值，便于排查调度行为）
+		apiGroup.GET("/channels/effective", handlers.GetEffectiveChannelConfig(cfgManager))
+
+		// 影子渠道设置（镜像非流式流量用于新渠道验证）
+		apiGroup.GET("/settings/shadow-channels", handlers.GetShadowChannels(cfgManager))
+		apiGroup.PUT("/settings/shadow-channels", handlers.SetShadowChannel(cfgManager))
+
+		// 全局限流设置（跨接口、跨渠道的 RPM/TPM 上限）
+		apiGroup.GET("/settings/global-rate-limit", handlers.GetGlobalRateLimit(cfgManager))
+		apiGroup.PUT("/settings/global-rate-limit", handlers.SetGlobalRateLimit(cfgManager))
+
+		// Trace 亲和模式设置（sticky: 固定渠道直到该会话实际失败）
+		apiGroup.GET("/settings/affinity-mode", handlers.GetAffinityMode(cfgManager))
+		apiGroup.PUT("/settings/affinity-mode", handlers.SetAffinityMode(cfgManager))
+		apiGroup.GET("/settings/scheduling-mode", handlers.GetSchedulingMode(cfgManager))
+		apiGroup.PUT("/settings/scheduling-mode", handlers.SetSchedulingMode(cfgManager))
+
+		// 模型访问控制（按接口类型配置 allowedModels/deniedModels，支持 xxx* 前缀通配）
+		apiGroup.GET("/settings/model-access", handlers.GetModelAccess(cfgManager))
+		apiGroup.PUT("/settings/model-access", handlers.SetModelAccess(cfgManager))
 	}
 
 	// 代理端点 - Messages API
@@ -375,6 +537,14 @@ func main() {
 			log.Println("[Server-Shutdown] 服务器已安全关闭")
 		}
 
+		// 停止指标后台任务并刷新缓冲中的持久化记录
+		for _, mm := range []*metrics.MetricsManager{messagesMetricsManager, responsesMetricsManager, geminiMetricsManager, chatMetricsManager} {
+			if err := mm.Shutdown(ctx); err != nil {
+				log.Printf("[Metrics-Shutdown] 警告: 刷新指标记录超时: %v", err)
+				break
+			}
+		}
+
 		// 关闭指标持久化存储
 		if metricsStore != nil {
 			if err := metricsStore.Close(); err != nil {
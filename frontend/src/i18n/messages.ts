@@ -89,6 +89,11 @@ export type MessageKey =
   | 'orchestration.cache'
   | 'orchestration.requestStats'
   | 'orchestration.cacheStats'
+  | 'orchestration.uptimeStats'
+  | 'orchestration.activityProfile'
+  | 'orchestration.maxIdleGap'
+  | 'orchestration.meanGap'
+  | 'orchestration.activeMinutes'
   | 'orchestration.minutes15'
   | 'orchestration.hour1'
   | 'orchestration.hours6'
@@ -233,6 +238,7 @@ export type MessageKey =
   | 'channelLogs.autoRefreshing'
   | 'channelLogs.empty'
   | 'channelLogs.retry'
+  | 'channelLogs.cacheHit'
   | 'store.channel.updated'
   | 'store.channel.added'
   | 'store.channel.deleted'
@@ -274,6 +280,7 @@ export type MessageKey =
   | 'status.unknown'
   | 'status.metrics.requests'
   | 'status.metrics.successRate'
+  | 'status.metrics.insufficientData'
   | 'status.metrics.consecutiveFailures'
   | 'status.metrics.lastSuccess'
   | 'status.metrics.lastFailure'
@@ -415,6 +422,11 @@ export const messages: Record<SupportedLocale, Record<MessageKey, string>> = {
     'orchestration.cache': 'Cache',
     'orchestration.requestStats': 'Request stats',
     'orchestration.cacheStats': 'Cache stats (Token)',
+    'orchestration.uptimeStats': 'Uptime (at least one key available)',
+    'orchestration.activityProfile': 'Traffic pattern (24 hours)',
+    'orchestration.maxIdleGap': 'Max idle gap',
+    'orchestration.meanGap': 'Mean gap',
+    'orchestration.activeMinutes': 'Active minutes',
     'orchestration.minutes15': '15 min',
     'orchestration.hour1': '1 hour',
     'orchestration.hours6': '6 hours',
@@ -559,6 +571,7 @@ export const messages: Record<SupportedLocale, Record<MessageKey, string>> = {
     'channelLogs.autoRefreshing': 'Auto refreshing',
     'channelLogs.empty': 'No logs yet',
     'channelLogs.retry': 'Retry',
+    'channelLogs.cacheHit': 'Cache hit',
     'store.channel.updated': 'Channel updated successfully',
     'store.channel.added': 'Channel added successfully',
     'store.channel.deleted': 'Channel deleted successfully',
@@ -600,6 +613,7 @@ export const messages: Record<SupportedLocale, Record<MessageKey, string>> = {
     'status.unknown': 'Unknown',
     'status.metrics.requests': 'Requests',
     'status.metrics.successRate': 'Success rate',
+    'status.metrics.insufficientData': 'Insufficient data',
     'status.metrics.consecutiveFailures': 'Consecutive failures',
     'status.metrics.lastSuccess': 'Last success',
     'status.metrics.lastFailure': 'Last failure',
@@ -740,6 +754,11 @@ export const messages: Record<SupportedLocale, Record<MessageKey, string>> = {
     'orchestration.cache': 'Cache',
     'orchestration.requestStats': 'Statistik request',
     'orchestration.cacheStats': 'Statistik cache (Token)',
+    'orchestration.uptimeStats': 'Uptime (minimal satu key tersedia)',
+    'orchestration.activityProfile': 'Pola trafik (24 jam)',
+    'orchestration.maxIdleGap': 'Jeda idle terlama',
+    'orchestration.meanGap': 'Jeda rata-rata',
+    'orchestration.activeMinutes': 'Menit aktif',
     'orchestration.minutes15': '15 menit',
     'orchestration.hour1': '1 jam',
     'orchestration.hours6': '6 jam',
@@ -884,6 +903,7 @@ export const messages: Record<SupportedLocale, Record<MessageKey, string>> = {
     'channelLogs.autoRefreshing': 'Sedang auto refresh',
     'channelLogs.empty': 'Belum ada log',
     'channelLogs.retry': 'Retry',
+    'channelLogs.cacheHit': 'Cache hit',
     'store.channel.updated': 'Channel berhasil diperbarui',
     'store.channel.added': 'Channel berhasil ditambahkan',
     'store.channel.deleted': 'Channel berhasil dihapus',
@@ -925,6 +945,7 @@ export const messages: Record<SupportedLocale, Record<MessageKey, string>> = {
     'status.unknown': 'Tidak diketahui',
     'status.metrics.requests': 'Request',
     'status.metrics.successRate': 'Tingkat sukses',
+    'status.metrics.insufficientData': 'Data belum cukup',
     'status.metrics.consecutiveFailures': 'Gagal beruntun',
     'status.metrics.lastSuccess': 'Sukses terakhir',
     'status.metrics.lastFailure': 'Gagal terakhir',
@@ -1065,6 +1086,11 @@ export const messages: Record<SupportedLocale, Record<MessageKey, string>> = {
     'orchestration.cache': '缓存',
     'orchestration.requestStats': '请求统计',
     'orchestration.cacheStats': '缓存统计 (Token)',
+    'orchestration.uptimeStats': '可用率（至少一个 Key 未熔断）',
+    'orchestration.activityProfile': '流量分布（24小时）',
+    'orchestration.maxIdleGap': '最长空闲',
+    'orchestration.meanGap': '平均间隔',
+    'orchestration.activeMinutes': '活跃分钟数',
     'orchestration.minutes15': '15分钟',
     'orchestration.hour1': '1小时',
     'orchestration.hours6': '6小时',
@@ -1209,6 +1235,7 @@ export const messages: Record<SupportedLocale, Record<MessageKey, string>> = {
     'channelLogs.autoRefreshing': '自动刷新中',
     'channelLogs.empty': '暂无日志记录',
     'channelLogs.retry': '重试',
+    'channelLogs.cacheHit': '缓存命中',
     'store.channel.updated': '渠道更新成功',
     'store.channel.added': '渠道添加成功',
     'store.channel.deleted': '渠道删除成功',
@@ -1250,6 +1277,7 @@ export const messages: Record<SupportedLocale, Record<MessageKey, string>> = {
     'status.unknown': '未知',
     'status.metrics.requests': '请求数',
     'status.metrics.successRate': '成功率',
+    'status.metrics.insufficientData': '样本不足',
     'status.metrics.consecutiveFailures': '连续失败',
     'status.metrics.lastSuccess': '最后成功',
     'status.metrics.lastFailure': '最后失败',
@@ -68,12 +68,14 @@ export interface ChannelMetrics {
   requestCount: number
   successCount: number
   failureCount: number
-  successRate: number       // 0-100
-  errorRate: number         // 0-100
+  successRate: number | null // 0-100，样本数低于最小样本数时为 null
+  errorRate: number | null   // 0-100，样本数低于最小样本数时为 null
+  insufficientData?: boolean // 样本数低于最小样本数，成功率不具参考意义
   consecutiveFailures: number
   latency: number           // ms
   lastSuccessAt?: string
   lastFailureAt?: string
+  selectionCount?: number   // 被调度选中的次数（与成功数对比可发现调度不匹配）
   // 分时段统计 (15m, 1h, 6h, 24h)
   timeWindows?: {
     '15m': TimeWindowStats
@@ -81,6 +83,22 @@ export interface ChannelMetrics {
     '6h': TimeWindowStats
     '24h': TimeWindowStats
   }
+  // 分时段可用率 0-1（至少一个 Key 未熔断的时间占比）
+  uptime?: {
+    '15m': number
+    '1h': number
+    '6h': number
+    '24h': number
+  }
+  // 近 24 小时流量分布（区分持续少量请求与偶发突发）
+  activityProfile?: ChannelActivityProfile
+}
+
+export interface ChannelActivityProfile {
+  requestCount: number
+  maxIdleGapSeconds: number  // 最长空闲时间（含窗口起点到首个请求、末个请求到现在）
+  meanGapSeconds: number     // 相邻请求的平均间隔
+  activeMinutes: number      // 有请求的分钟数
 }
 
 export interface Channel {
@@ -143,6 +161,17 @@ export interface PingResult {
   error?: string
 }
 
+// 渠道探测结果（认证失败时渠道会被自动暂停）
+export interface ChannelProbeResult {
+  success: boolean
+  statusCode: number
+  latency: number
+  model: string
+  suspended: boolean
+  error?: string
+  errorBody?: string
+}
+
 // ============== 能力测试类型 ==============
 
 export interface CapabilityTestJobStartResponse {
@@ -351,6 +380,10 @@ export interface ChannelLogEntry {
   errorInfo: string
   isRetry: boolean
   interfaceType?: string  // 接口类型（Messages/Responses/Gemini）
+  inputTokens?: number    // 成功请求的输入 token
+  outputTokens?: number   // 成功请求的输出 token
+  cacheHit?: boolean      // 命中响应缓存（未调用上游）
+  requestId?: string      // 请求关联 ID（与响应头 X-CCX-Request-Id 一致）
 }
 
 export interface ChannelLogsResponse {
@@ -367,6 +400,7 @@ export interface ActivitySegment {
   failureCount: number
   inputTokens: number
   outputTokens: number
+  tpmTokens?: number  // 按后端 TPM 统计口径计入的 tokens
 }
 
 // 渠道最近活跃度数据（稀疏格式，减少 JSON 体积）
@@ -613,6 +647,20 @@ export class ApiService {
     })
   }
 
+  async probeChannel(type: 'messages' | 'chat' | 'gemini' | 'responses', id: number): Promise<ChannelProbeResult> {
+    return this.request(`/${type}/channels/${id}/probe`, {
+      method: 'POST'
+    })
+  }
+
+  // 原地轮换 API Key，旧 Key 的统计迁移到新 Key
+  async rotateApiKey(type: 'messages' | 'chat' | 'gemini' | 'responses', id: number, oldKey: string, newKey: string): Promise<{ success: boolean; metricsCarried: boolean }> {
+    return this.request(`/${type}/channels/${id}/keys/rotate`, {
+      method: 'POST',
+      body: JSON.stringify({ oldKey, newKey })
+    })
+  }
+
   // ============== 能力测试 API ==============
 
   async startChannelCapabilityTest(type: 'messages' | 'chat' | 'gemini' | 'responses', id: number, previousJobId?: string): Promise<CapabilityTestJobStartResponse> {